@@ -3,6 +3,8 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"net/url"
@@ -11,7 +13,9 @@ import (
 
 	"github.com/morezero/capabilities-registry/internal/config"
 	"github.com/morezero/capabilities-registry/internal/server"
+	"github.com/morezero/capabilities-registry/pkg/commsutil"
 	"github.com/morezero/capabilities-registry/pkg/db"
+	"github.com/morezero/capabilities-registry/pkg/registry"
 )
 
 const usage = `Usage: registry [command]
@@ -22,6 +26,8 @@ const usage = `Usage: registry [command]
        registry ensure-db [name]    Create database if missing (default name: registry_test). Uses DATABASE_URL host/user.
        registry clear               Truncate all registry tables; schema is preserved.
        registry seed [file]         Seed from capabilities metadata (e.g. registry/capabilities/metadata.json).
+       registry lock [flags] cap...  Resolve caps (e.g. more0.doc.ingest@^3) and print a lockfile.
+       registry lock verify <file>  Verify a lockfile against the registry; exit 1 if it is no longer valid.
 
 Commands:
   serve           (default) Start the capabilities registry.
@@ -31,6 +37,8 @@ Commands:
   ensure-db [name] Create database (e.g. registry_test) on same host as DATABASE_URL; then run tests with that URL.
   clear           Truncate registry data; schema preserved.
   seed [file]     Seed from capabilities metadata (path derived from bootstrap file or REGISTRY_BOOTSTRAP_FILE).
+  lock            Generate a lockfile. Flags: -env, -tenant, -release, -o <file> (default stdout).
+  lock verify     Report locked versions that became deprecated, disabled, yanked, missing or changed.
 
 Environment: DATABASE_URL (required), MIGRATION_PATH, REGISTRY_HTTP_ADDR (default 0.0.0.0:8080), REGISTRY_BOOTSTRAP_FILE. See README.
 `
@@ -79,6 +87,24 @@ func main() {
 			log.Fatalf("registry seed: %v", err)
 		}
 		return
+	case "lock":
+		if len(args) > 1 && args[1] == "verify" {
+			if len(args) < 3 {
+				log.Fatalf("registry lock verify: require lockfile path")
+			}
+			valid, err := runLockVerify(args[2])
+			if err != nil {
+				log.Fatalf("registry lock verify: %v", err)
+			}
+			if !valid {
+				os.Exit(1)
+			}
+			return
+		}
+		if err := runLock(args[1:]); err != nil {
+			log.Fatalf("registry lock: %v", err)
+		}
+		return
 	case "ensure-db":
 		dbName := "registry_test"
 		if len(args) > 1 && args[1] != "" {
@@ -240,3 +266,108 @@ func runSeed(bootstrapFileOverride string) error {
 	}
 	return nil
 }
+
+// newCLIRegistry connects to the database and returns a registry without a publisher,
+// configured like serve (tenant shards, NATS URL) so lockfiles match served resolves.
+// The returned close func releases the pool.
+func newCLIRegistry(ctx context.Context) (*registry.Registry, func(), error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, nil, fmt.Errorf("load config: %w", err)
+	}
+	if err := cfg.ValidateForDB(); err != nil {
+		return nil, nil, err
+	}
+	pool, err := db.NewPool(ctx, cfg.DatabaseURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("connect database: %w", err)
+	}
+	reg := registry.NewRegistry(registry.NewRegistryParams{
+		Repo:   db.NewRepository(pool),
+		Config: cliRegistryConfig(cfg),
+	})
+	return reg, pool.Close, nil
+}
+
+// cliRegistryConfig is the registry config of CLI commands; without a bootstrap file the
+// registry subject is REGISTRY_SUBJECT or the default.
+func cliRegistryConfig(cfg *config.Config) registry.Config {
+	registrySubject := cfg.RegistrySubject
+	if registrySubject == "" {
+		registrySubject = commsutil.SubjectRegistry
+	}
+	return server.RegistryConfig(cfg, registrySubject)
+}
+
+func runLock(args []string) error {
+	fs := flag.NewFlagSet("lock", flag.ContinueOnError)
+	env := fs.String("env", "", "environment to resolve defaults in (default: registry default env)")
+	tenant := fs.String("tenant", "", "tenant ID for access checks")
+	release := fs.String("release", "", "resolve pinned capabilities from this release")
+	out := fs.String("o", "", "write lockfile to this path instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("require at least one capability ref")
+	}
+
+	input := &registry.LockInput{Caps: fs.Args()}
+	if *env != "" || *tenant != "" || *release != "" {
+		input.Ctx = &registry.ResolutionContext{Env: *env, TenantID: *tenant, Release: *release}
+	}
+
+	ctx := context.Background()
+	reg, closeFn, err := newCLIRegistry(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	lock, err := reg.Lock(ctx, input)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode lockfile: %w", err)
+	}
+	data = append(data, '\n')
+	if *out == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		return fmt.Errorf("write lockfile: %w", err)
+	}
+	fmt.Printf("Wrote %d entries to %s.\n", len(lock.Entries), *out)
+	return nil
+}
+
+func runLockVerify(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("read lockfile: %w", err)
+	}
+	var lock registry.Lockfile
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return false, fmt.Errorf("parse lockfile: %w", err)
+	}
+
+	ctx := context.Background()
+	reg, closeFn, err := newCLIRegistry(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer closeFn()
+
+	result, err := reg.VerifyLock(ctx, &registry.VerifyLockInput{Lock: lock})
+	if err != nil {
+		return false, err
+	}
+	for _, issue := range result.Issues {
+		fmt.Printf("%-10s %s@%s: %s\n", issue.Problem, issue.Cap, issue.Version, issue.Message)
+	}
+	fmt.Printf("Checked %d entries; valid=%t.\n", result.Checked, result.Valid)
+	return result.Valid, nil
+}
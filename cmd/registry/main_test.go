@@ -1,8 +1,13 @@
 package main
 
 import (
+	"context"
 	"strings"
 	"testing"
+
+	"github.com/morezero/capabilities-registry/internal/config"
+	"github.com/morezero/capabilities-registry/pkg/commsutil"
+	"github.com/morezero/capabilities-registry/pkg/registry"
 )
 
 const mainTestPrefix = "cmd/registry:main_test"
@@ -14,10 +19,46 @@ func TestUsage_NonEmpty(t *testing.T) {
 }
 
 func TestUsage_ContainsCommands(t *testing.T) {
-	required := []string{"serve", "migrate", "clear", "seed", "DATABASE_URL"}
+	required := []string{"serve", "migrate", "clear", "seed", "lock", "DATABASE_URL"}
 	for _, word := range required {
 		if !strings.Contains(usage, word) {
 			t.Errorf("%s - usage should contain %q", mainTestPrefix, word)
 		}
 	}
 }
+
+func TestCLIRegistryConfig_LockUsesTenantShards(t *testing.T) {
+	t.Setenv("REGISTRY_TENANT_SHARDS", "3")
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("%s - LoadConfig failed: %v", mainTestPrefix, err)
+	}
+
+	ctx := context.Background()
+	store, _ := registry.NewLocalStore("")
+	if _, err := store.ApplyCatalogCapability(ctx, registry.CatalogCapability{
+		App: "more0", Name: "doc.ingest",
+		SubjectTemplate: "{prefix}.{app}.{name}.v{major}.s{tenantShard}",
+		Defaults:        []registry.CatalogDefault{{Env: "production", Major: 1}},
+		Versions: []registry.CatalogVersion{{Major: 1, Minor: 0, Patch: 0, Status: "active",
+			Methods: []registry.MethodDefinition{{Name: "ingest"}}}},
+	}); err != nil {
+		t.Fatalf("%s - apply failed: %v", mainTestPrefix, err)
+	}
+	reg := registry.NewRegistry(registry.NewRegistryParams{Catalog: store, Config: cliRegistryConfig(cfg)})
+
+	tenant := "tenant-a"
+	lock, err := reg.Lock(ctx, &registry.LockInput{
+		Caps: []string{"more0.doc.ingest"},
+		Ctx:  &registry.ResolutionContext{Env: "production", TenantID: tenant},
+	})
+	if err != nil || len(lock.Entries) != 1 {
+		t.Fatalf("%s - Lock failed: %v", mainTestPrefix, err)
+	}
+	want := commsutil.BuildSubject("{prefix}.{app}.{name}.v{major}.s{tenantShard}", commsutil.SubjectParams{
+		App: "more0", Name: "doc.ingest", Major: 1, TenantID: tenant, TenantShards: 3,
+	})
+	if lock.Entries[0].Subject != want {
+		t.Errorf("%s - lock subject = %q, want %q (REGISTRY_TENANT_SHARDS=3)", mainTestPrefix, lock.Entries[0].Subject, want)
+	}
+}
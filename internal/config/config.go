@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
+
+	"github.com/morezero/capabilities-registry/pkg/commsutil"
 )
 
 const logPrefix = "config:LoadConfig"
@@ -21,10 +23,80 @@ type Config struct {
 	// Registry subject overrides (empty = derive from bootstrap)
 	RegistrySubject    string `envconfig:"REGISTRY_SUBJECT"`
 	ChangeEventSubject string `envconfig:"REGISTRY_CHANGE_EVENT_SUBJECT"`
+	// ChangeSubjectPattern is the per-capability change subject; {env} and {tenant} scope it
+	// so clients can subscribe to their own env and tenant (advertised in bootstrap)
+	ChangeSubjectPattern string `envconfig:"REGISTRY_CHANGE_SUBJECT_PATTERN" default:"registry.changed.{app}.{capability}"`
+	// TenantShards is the bucket count for the {tenantShard} subject template token
+	TenantShards int `envconfig:"REGISTRY_TENANT_SHARDS" default:"16"`
 
 	// Timeouts
 	RequestTimeout time.Duration `envconfig:"REGISTRY_REQUEST_TIMEOUT" default:"25s"`
 
+	// Provider instance leases: default lease length and how often expired leases are reaped (0 = never)
+	InstanceLeaseTTL     time.Duration `envconfig:"REGISTRY_INSTANCE_LEASE_TTL" default:"30s"`
+	InstanceReapInterval time.Duration `envconfig:"REGISTRY_INSTANCE_REAP_INTERVAL" default:"10s"`
+
+	// Responder probing of capability subjects: how often (0 = never) and the per-request timeout
+	ProbeInterval time.Duration `envconfig:"REGISTRY_PROBE_INTERVAL" default:"30s"`
+	ProbeTimeout  time.Duration `envconfig:"REGISTRY_PROBE_TIMEOUT" default:"2s"`
+
+	// NATS micro service import: how often $SRV.INFO is polled and imported (0 = never)
+	MicroImportInterval time.Duration `envconfig:"REGISTRY_MICRO_IMPORT_INTERVAL" default:"0s"`
+
+	// Remote registries: how often their health is checked (0 = never), and the circuit breaker
+	// that fails requests fast after FailureThreshold consecutive failures for Cooldown
+	FederationHealthInterval   time.Duration `envconfig:"REGISTRY_FEDERATION_HEALTH_INTERVAL" default:"30s"`
+	FederationFailureThreshold int           `envconfig:"REGISTRY_FEDERATION_FAILURE_THRESHOLD" default:"3"`
+	FederationCooldown         time.Duration `envconfig:"REGISTRY_FEDERATION_COOLDOWN" default:"30s"`
+	// FederationMaxStale is how long past their TTL cached federated resolve answers may be
+	// served while the remote is unavailable; 0 disables stale answers
+	FederationMaxStale time.Duration `envconfig:"REGISTRY_FEDERATION_MAX_STALE" default:"5m"`
+	// FederationID identifies this registry in the trace of forwarded requests (empty =
+	// "<NATS client URL>/<registry subject>"); FederationMaxHops limits forwards per request
+	FederationID      string `envconfig:"REGISTRY_FEDERATION_ID"`
+	FederationMaxHops int    `envconfig:"REGISTRY_FEDERATION_MAX_HOPS" default:"4"`
+
+	// Mirror mode: the registry alias whose catalog is copied (empty = not a mirror) and how
+	// often it is fully re-synced between change events
+	MirrorUpstream     string        `envconfig:"REGISTRY_MIRROR_UPSTREAM"`
+	MirrorSyncInterval time.Duration `envconfig:"REGISTRY_MIRROR_SYNC_INTERVAL" default:"5m"`
+	// MirrorStore is where a mirror keeps its copy: "postgres" (the database) or "local" (a
+	// JSON file at MirrorStorePath, in memory when empty; no database is used). A local mirror
+	// reaches its upstream at MirrorUpstreamURL and MirrorUpstreamSubject (empty = "registry")
+	MirrorStore           string `envconfig:"REGISTRY_MIRROR_STORE" default:"postgres"`
+	MirrorStorePath       string `envconfig:"REGISTRY_MIRROR_STORE_PATH"`
+	MirrorUpstreamURL     string `envconfig:"REGISTRY_MIRROR_UPSTREAM_URL"`
+	MirrorUpstreamSubject string `envconfig:"REGISTRY_MIRROR_UPSTREAM_SUBJECT"`
+
+	// Watches: the subject prefix watch inboxes may have besides "_INBOX." (empty = only "_INBOX.")
+	WatchInboxPrefix string `envconfig:"REGISTRY_WATCH_INBOX_PREFIX"`
+
+	// Change feed (changesSince): how long recorded changes are kept (0 = forever)
+	ChangeRetention time.Duration `envconfig:"REGISTRY_CHANGE_RETENTION" default:"168h"`
+
+	// Change event transport: "core" publishes with core NATS; "jetstream" also stores events
+	// in ChangeStream for ChangeStreamMaxAge (0 = forever), up to ChangeStreamMaxMsgs (0 = unlimited)
+	ChangeEventTransport string        `envconfig:"REGISTRY_CHANGE_EVENT_TRANSPORT" default:"core"`
+	ChangeStream         string        `envconfig:"REGISTRY_CHANGE_STREAM" default:"REGISTRY_CHANGES"`
+	ChangeStreamMaxAge   time.Duration `envconfig:"REGISTRY_CHANGE_STREAM_MAX_AGE" default:"168h"`
+	ChangeStreamMaxMsgs  int64         `envconfig:"REGISTRY_CHANGE_STREAM_MAX_MSGS" default:"0"`
+
+	// Change event encoding: "json" publishes the bare event, "cloudevents" a CloudEvents 1.0
+	// JSON envelope, "cloudevents-binary" the bare event with the CloudEvents attributes in ce- headers
+	ChangeEventFormat string `envconfig:"REGISTRY_CHANGE_EVENT_FORMAT" default:"json"`
+
+	// ResolutionBucket is the JetStream KV bucket the default resolution of every capability
+	// and env is materialized into (empty = disabled)
+	ResolutionBucket string `envconfig:"REGISTRY_RESOLUTION_BUCKET"`
+
+	// Webhooks: HTTP timeout per attempt, attempts per delivery, and failed deliveries in a
+	// row after which a webhook is disabled (0 = never)
+	WebhookTimeout      time.Duration `envconfig:"REGISTRY_WEBHOOK_TIMEOUT" default:"10s"`
+	WebhookMaxAttempts  int           `envconfig:"REGISTRY_WEBHOOK_MAX_ATTEMPTS" default:"8"`
+	WebhookDisableAfter int           `envconfig:"REGISTRY_WEBHOOK_DISABLE_AFTER" default:"5"`
+	// WebhookAllowPrivate lets webhooks target loopback, private and link-local addresses
+	WebhookAllowPrivate bool `envconfig:"REGISTRY_WEBHOOK_ALLOW_PRIVATE" default:"false"`
+
 	// Bootstrap
 	BootstrapFile string `envconfig:"REGISTRY_BOOTSTRAP_FILE"`
 
@@ -37,6 +109,10 @@ type Config struct {
 	HTTPAddr          string        `envconfig:"REGISTRY_HTTP_ADDR"`
 	HTTPPort          int           `envconfig:"HTTP_PORT" default:"8080"`
 	HealthCheckTimeout time.Duration `envconfig:"HEALTH_CHECK_TIMEOUT" default:"5s"`
+	// /events Server-Sent Events: concurrent streams allowed (0 = endpoint disabled) and how
+	// often an idle stream gets a heartbeat comment (0 = never)
+	SSEMaxStreams int           `envconfig:"REGISTRY_SSE_MAX_STREAMS" default:"100"`
+	SSEHeartbeat  time.Duration `envconfig:"REGISTRY_SSE_HEARTBEAT" default:"15s"`
 
 	// Logging
 	LogLevel string `envconfig:"LOG_LEVEL" default:"info"`
@@ -53,7 +129,7 @@ func LoadConfig() (*Config, error) {
 
 // ValidateForServe checks required config when running the registry server.
 func (c *Config) ValidateForServe() error {
-	if c.DatabaseURL == "" {
+	if c.DatabaseURL == "" && c.MirrorStore != "local" {
 		return fmt.Errorf("%s - DATABASE_URL is required for serve", logPrefix)
 	}
 	if c.RequestTimeout <= 0 {
@@ -62,6 +138,96 @@ func (c *Config) ValidateForServe() error {
 	if c.HealthCheckTimeout <= 0 {
 		return fmt.Errorf("%s - HEALTH_CHECK_TIMEOUT must be positive", logPrefix)
 	}
+	if c.TenantShards < 0 {
+		return fmt.Errorf("%s - REGISTRY_TENANT_SHARDS must not be negative", logPrefix)
+	}
+	if c.InstanceLeaseTTL < 0 || c.InstanceLeaseTTL > time.Hour {
+		return fmt.Errorf("%s - REGISTRY_INSTANCE_LEASE_TTL must be at most 1h", logPrefix)
+	}
+	if c.InstanceReapInterval < 0 {
+		return fmt.Errorf("%s - REGISTRY_INSTANCE_REAP_INTERVAL must not be negative", logPrefix)
+	}
+	if c.ProbeInterval < 0 {
+		return fmt.Errorf("%s - REGISTRY_PROBE_INTERVAL must not be negative", logPrefix)
+	}
+	if c.ProbeTimeout < 0 {
+		return fmt.Errorf("%s - REGISTRY_PROBE_TIMEOUT must not be negative", logPrefix)
+	}
+	if c.MicroImportInterval < 0 {
+		return fmt.Errorf("%s - REGISTRY_MICRO_IMPORT_INTERVAL must not be negative", logPrefix)
+	}
+	if c.FederationHealthInterval < 0 {
+		return fmt.Errorf("%s - REGISTRY_FEDERATION_HEALTH_INTERVAL must not be negative", logPrefix)
+	}
+	if c.FederationFailureThreshold < 0 {
+		return fmt.Errorf("%s - REGISTRY_FEDERATION_FAILURE_THRESHOLD must not be negative", logPrefix)
+	}
+	if c.FederationCooldown < 0 {
+		return fmt.Errorf("%s - REGISTRY_FEDERATION_COOLDOWN must not be negative", logPrefix)
+	}
+	if c.FederationMaxStale < 0 {
+		return fmt.Errorf("%s - REGISTRY_FEDERATION_MAX_STALE must not be negative", logPrefix)
+	}
+	if c.FederationMaxHops < 0 {
+		return fmt.Errorf("%s - REGISTRY_FEDERATION_MAX_HOPS must not be negative", logPrefix)
+	}
+	if c.MirrorSyncInterval < 0 {
+		return fmt.Errorf("%s - REGISTRY_MIRROR_SYNC_INTERVAL must not be negative", logPrefix)
+	}
+	switch c.MirrorStore {
+	case "", "postgres":
+	case "local":
+		if c.MirrorUpstream == "" || c.MirrorUpstreamURL == "" {
+			return fmt.Errorf("%s - REGISTRY_MIRROR_STORE=local needs REGISTRY_MIRROR_UPSTREAM and REGISTRY_MIRROR_UPSTREAM_URL", logPrefix)
+		}
+		if c.ResolutionBucket != "" {
+			return fmt.Errorf("%s - REGISTRY_RESOLUTION_BUCKET needs REGISTRY_MIRROR_STORE=postgres", logPrefix)
+		}
+	default:
+		return fmt.Errorf("%s - REGISTRY_MIRROR_STORE must be postgres or local", logPrefix)
+	}
+	if c.ChangeRetention < 0 {
+		return fmt.Errorf("%s - REGISTRY_CHANGE_RETENTION must not be negative", logPrefix)
+	}
+	switch c.ChangeEventTransport {
+	case "", "core", "jetstream":
+	default:
+		return fmt.Errorf("%s - REGISTRY_CHANGE_EVENT_TRANSPORT must be core or jetstream", logPrefix)
+	}
+	if c.ChangeEventTransport == "jetstream" && c.ChangeStream == "" {
+		return fmt.Errorf("%s - REGISTRY_CHANGE_STREAM is required for the jetstream transport", logPrefix)
+	}
+	if c.ChangeSubjectPattern != "" {
+		if err := commsutil.ValidateChangeSubjectPattern(c.ChangeSubjectPattern); err != nil {
+			return fmt.Errorf("%s - REGISTRY_CHANGE_SUBJECT_PATTERN: %v", logPrefix, err)
+		}
+	}
+	switch c.ChangeEventFormat {
+	case "", "json", "cloudevents", "cloudevents-binary":
+	default:
+		return fmt.Errorf("%s - REGISTRY_CHANGE_EVENT_FORMAT must be json, cloudevents or cloudevents-binary", logPrefix)
+	}
+	if c.SSEMaxStreams < 0 {
+		return fmt.Errorf("%s - REGISTRY_SSE_MAX_STREAMS must not be negative", logPrefix)
+	}
+	if c.SSEHeartbeat < 0 {
+		return fmt.Errorf("%s - REGISTRY_SSE_HEARTBEAT must not be negative", logPrefix)
+	}
+	if c.ChangeStreamMaxAge < 0 {
+		return fmt.Errorf("%s - REGISTRY_CHANGE_STREAM_MAX_AGE must not be negative", logPrefix)
+	}
+	if c.ChangeStreamMaxMsgs < 0 {
+		return fmt.Errorf("%s - REGISTRY_CHANGE_STREAM_MAX_MSGS must not be negative", logPrefix)
+	}
+	if c.WebhookTimeout < 0 {
+		return fmt.Errorf("%s - REGISTRY_WEBHOOK_TIMEOUT must not be negative", logPrefix)
+	}
+	if c.WebhookMaxAttempts < 0 {
+		return fmt.Errorf("%s - REGISTRY_WEBHOOK_MAX_ATTEMPTS must not be negative", logPrefix)
+	}
+	if c.WebhookDisableAfter < 0 {
+		return fmt.Errorf("%s - REGISTRY_WEBHOOK_DISABLE_AFTER must not be negative", logPrefix)
+	}
 	return nil
 }
 
@@ -11,8 +11,8 @@ func TestLoadConfig_Defaults(t *testing.T) {
 	// Clear all environment variables that might interfere
 	envVars := []string{
 		"COMMS_URL", "SERVICE_NAME",
-		"REGISTRY_SUBJECT", "REGISTRY_CHANGE_EVENT_SUBJECT",
-		"REGISTRY_REQUEST_TIMEOUT", "REGISTRY_BOOTSTRAP_FILE",
+		"REGISTRY_SUBJECT", "REGISTRY_CHANGE_EVENT_SUBJECT", "REGISTRY_TENANT_SHARDS",
+		"REGISTRY_REQUEST_TIMEOUT", "REGISTRY_INSTANCE_LEASE_TTL", "REGISTRY_INSTANCE_REAP_INTERVAL", "REGISTRY_BOOTSTRAP_FILE",
 		"DATABASE_URL", "RUN_MIGRATIONS", "MIGRATION_PATH",
 		"REGISTRY_HTTP_ADDR", "HTTP_PORT", "HEALTH_CHECK_TIMEOUT", "LOG_LEVEL",
 	}
@@ -62,6 +62,69 @@ func TestLoadConfig_Defaults(t *testing.T) {
 	if cfg.LogLevel != "info" {
 		t.Errorf("config:config_test - LogLevel = %q, want %q", cfg.LogLevel, "info")
 	}
+	if cfg.TenantShards != 16 {
+		t.Errorf("config:config_test - TenantShards = %d, want 16", cfg.TenantShards)
+	}
+	if cfg.InstanceLeaseTTL != 30*time.Second {
+		t.Errorf("config:config_test - InstanceLeaseTTL = %v, want 30s", cfg.InstanceLeaseTTL)
+	}
+	if cfg.InstanceReapInterval != 10*time.Second {
+		t.Errorf("config:config_test - InstanceReapInterval = %v, want 10s", cfg.InstanceReapInterval)
+	}
+	if cfg.ProbeInterval != 30*time.Second {
+		t.Errorf("config:config_test - ProbeInterval = %v, want 30s", cfg.ProbeInterval)
+	}
+	if cfg.ProbeTimeout != 2*time.Second {
+		t.Errorf("config:config_test - ProbeTimeout = %v, want 2s", cfg.ProbeTimeout)
+	}
+	if cfg.MicroImportInterval != 0 {
+		t.Errorf("config:config_test - MicroImportInterval = %v, want 0 (disabled)", cfg.MicroImportInterval)
+	}
+	if cfg.FederationHealthInterval != 30*time.Second {
+		t.Errorf("config:config_test - FederationHealthInterval = %v, want 30s", cfg.FederationHealthInterval)
+	}
+	if cfg.FederationFailureThreshold != 3 {
+		t.Errorf("config:config_test - FederationFailureThreshold = %d, want 3", cfg.FederationFailureThreshold)
+	}
+	if cfg.FederationCooldown != 30*time.Second {
+		t.Errorf("config:config_test - FederationCooldown = %v, want 30s", cfg.FederationCooldown)
+	}
+	if cfg.FederationMaxStale != 5*time.Minute {
+		t.Errorf("config:config_test - FederationMaxStale = %v, want 5m", cfg.FederationMaxStale)
+	}
+	if cfg.FederationMaxHops != 4 || cfg.FederationID != "" {
+		t.Errorf("config:config_test - FederationMaxHops = %d, FederationID = %q, want 4 and empty", cfg.FederationMaxHops, cfg.FederationID)
+	}
+	if cfg.MirrorUpstream != "" || cfg.MirrorSyncInterval != 5*time.Minute {
+		t.Errorf("config:config_test - MirrorUpstream = %q, MirrorSyncInterval = %v, want empty and 5m", cfg.MirrorUpstream, cfg.MirrorSyncInterval)
+	}
+	if cfg.MirrorStore != "postgres" || cfg.MirrorStorePath != "" || cfg.MirrorUpstreamURL != "" {
+		t.Errorf("config:config_test - MirrorStore = %q, MirrorStorePath = %q, MirrorUpstreamURL = %q, want postgres and empty", cfg.MirrorStore, cfg.MirrorStorePath, cfg.MirrorUpstreamURL)
+	}
+	if cfg.ChangeRetention != 168*time.Hour {
+		t.Errorf("config:config_test - ChangeRetention = %v, want 168h", cfg.ChangeRetention)
+	}
+	if cfg.ChangeEventTransport != "core" || cfg.ChangeStream != "REGISTRY_CHANGES" || cfg.ChangeStreamMaxAge != 168*time.Hour || cfg.ChangeStreamMaxMsgs != 0 {
+		t.Errorf("config:config_test - change stream = %q/%q/%v/%d, want core/REGISTRY_CHANGES/168h/0", cfg.ChangeEventTransport, cfg.ChangeStream, cfg.ChangeStreamMaxAge, cfg.ChangeStreamMaxMsgs)
+	}
+	if cfg.ChangeSubjectPattern != "registry.changed.{app}.{capability}" {
+		t.Errorf("config:config_test - ChangeSubjectPattern = %q, want registry.changed.{app}.{capability}", cfg.ChangeSubjectPattern)
+	}
+	if cfg.ChangeEventFormat != "json" {
+		t.Errorf("config:config_test - ChangeEventFormat = %q, want json", cfg.ChangeEventFormat)
+	}
+	if cfg.ResolutionBucket != "" {
+		t.Errorf("config:config_test - ResolutionBucket = %q, want empty", cfg.ResolutionBucket)
+	}
+	if cfg.SSEMaxStreams != 100 || cfg.SSEHeartbeat != 15*time.Second {
+		t.Errorf("config:config_test - SSE = %d/%v, want 100/15s", cfg.SSEMaxStreams, cfg.SSEHeartbeat)
+	}
+	if cfg.WebhookTimeout != 10*time.Second || cfg.WebhookMaxAttempts != 8 || cfg.WebhookDisableAfter != 5 {
+		t.Errorf("config:config_test - webhooks = %v/%d/%d, want 10s/8/5", cfg.WebhookTimeout, cfg.WebhookMaxAttempts, cfg.WebhookDisableAfter)
+	}
+	if cfg.WebhookAllowPrivate {
+		t.Errorf("config:config_test - webhooks must not reach private addresses by default")
+	}
 }
 
 func TestLoadConfig_EnvironmentOverrides(t *testing.T) {
@@ -182,6 +245,202 @@ func TestValidateForServe_ZeroHealthCheckTimeout(t *testing.T) {
 	}
 }
 
+func TestValidateForServe_NegativeTenantShards(t *testing.T) {
+	cfg := &Config{DatabaseURL: "postgres://localhost/db", RequestTimeout: 5 * time.Second, HealthCheckTimeout: 5 * time.Second, TenantShards: -1}
+	err := cfg.ValidateForServe()
+	if err == nil {
+		t.Fatal("config:config_test - expected error for negative REGISTRY_TENANT_SHARDS")
+	}
+	if !strings.Contains(err.Error(), "REGISTRY_TENANT_SHARDS") {
+		t.Errorf("config:config_test - error should mention REGISTRY_TENANT_SHARDS, got %v", err)
+	}
+}
+
+func TestValidateForServe_InstanceLeaseTTLTooLong(t *testing.T) {
+	cfg := &Config{DatabaseURL: "postgres://localhost/db", RequestTimeout: 5 * time.Second, HealthCheckTimeout: 5 * time.Second, InstanceLeaseTTL: 2 * time.Hour}
+	err := cfg.ValidateForServe()
+	if err == nil {
+		t.Fatal("config:config_test - expected error for REGISTRY_INSTANCE_LEASE_TTL over 1h")
+	}
+	if !strings.Contains(err.Error(), "REGISTRY_INSTANCE_LEASE_TTL") {
+		t.Errorf("config:config_test - error should mention REGISTRY_INSTANCE_LEASE_TTL, got %v", err)
+	}
+}
+
+func TestValidateForServe_NegativeProbeInterval(t *testing.T) {
+	cfg := &Config{DatabaseURL: "postgres://localhost/db", RequestTimeout: 5 * time.Second, HealthCheckTimeout: 5 * time.Second, ProbeInterval: -time.Second}
+	err := cfg.ValidateForServe()
+	if err == nil {
+		t.Fatal("config:config_test - expected error for negative REGISTRY_PROBE_INTERVAL")
+	}
+	if !strings.Contains(err.Error(), "REGISTRY_PROBE_INTERVAL") {
+		t.Errorf("config:config_test - error should mention REGISTRY_PROBE_INTERVAL, got %v", err)
+	}
+}
+
+func TestValidateForServe_NegativeFederationCooldown(t *testing.T) {
+	cfg := &Config{DatabaseURL: "postgres://localhost/db", RequestTimeout: 5 * time.Second, HealthCheckTimeout: 5 * time.Second, FederationCooldown: -time.Second}
+	err := cfg.ValidateForServe()
+	if err == nil {
+		t.Fatal("config:config_test - expected error for negative REGISTRY_FEDERATION_COOLDOWN")
+	}
+	if !strings.Contains(err.Error(), "REGISTRY_FEDERATION_COOLDOWN") {
+		t.Errorf("config:config_test - error should mention REGISTRY_FEDERATION_COOLDOWN, got %v", err)
+	}
+}
+
+func TestValidateForServe_NegativeFederationMaxStale(t *testing.T) {
+	cfg := &Config{DatabaseURL: "postgres://localhost/db", RequestTimeout: 5 * time.Second, HealthCheckTimeout: 5 * time.Second, FederationMaxStale: -time.Second}
+	err := cfg.ValidateForServe()
+	if err == nil {
+		t.Fatal("config:config_test - expected error for negative REGISTRY_FEDERATION_MAX_STALE")
+	}
+	if !strings.Contains(err.Error(), "REGISTRY_FEDERATION_MAX_STALE") {
+		t.Errorf("config:config_test - error should mention REGISTRY_FEDERATION_MAX_STALE, got %v", err)
+	}
+}
+
+func TestValidateForServe_NegativeFederationMaxHops(t *testing.T) {
+	cfg := &Config{DatabaseURL: "postgres://localhost/db", RequestTimeout: 5 * time.Second, HealthCheckTimeout: 5 * time.Second, FederationMaxHops: -1}
+	err := cfg.ValidateForServe()
+	if err == nil {
+		t.Fatal("config:config_test - expected error for negative REGISTRY_FEDERATION_MAX_HOPS")
+	}
+	if !strings.Contains(err.Error(), "REGISTRY_FEDERATION_MAX_HOPS") {
+		t.Errorf("config:config_test - error should mention REGISTRY_FEDERATION_MAX_HOPS, got %v", err)
+	}
+}
+
+func TestValidateForServe_NegativeMirrorSyncInterval(t *testing.T) {
+	cfg := &Config{DatabaseURL: "postgres://localhost/db", RequestTimeout: 5 * time.Second, HealthCheckTimeout: 5 * time.Second, MirrorSyncInterval: -time.Second}
+	err := cfg.ValidateForServe()
+	if err == nil {
+		t.Fatal("config:config_test - expected error for negative REGISTRY_MIRROR_SYNC_INTERVAL")
+	}
+	if !strings.Contains(err.Error(), "REGISTRY_MIRROR_SYNC_INTERVAL") {
+		t.Errorf("config:config_test - error should mention REGISTRY_MIRROR_SYNC_INTERVAL, got %v", err)
+	}
+}
+
+func TestValidateForServe_MirrorStore(t *testing.T) {
+	base := Config{DatabaseURL: "postgres://localhost/db", RequestTimeout: 5 * time.Second, HealthCheckTimeout: 5 * time.Second}
+
+	cfg := base
+	cfg.MirrorStore = "sqlite"
+	if err := cfg.ValidateForServe(); err == nil || !strings.Contains(err.Error(), "REGISTRY_MIRROR_STORE") {
+		t.Errorf("config:config_test - expected a REGISTRY_MIRROR_STORE error for sqlite, got %v", err)
+	}
+	cfg = base
+	cfg.MirrorStore, cfg.MirrorUpstream = "local", "central"
+	if err := cfg.ValidateForServe(); err == nil || !strings.Contains(err.Error(), "REGISTRY_MIRROR_UPSTREAM_URL") {
+		t.Errorf("config:config_test - expected a REGISTRY_MIRROR_UPSTREAM_URL error, got %v", err)
+	}
+	cfg.MirrorUpstreamURL, cfg.ResolutionBucket = "nats://central:4222", "resolutions"
+	if err := cfg.ValidateForServe(); err == nil || !strings.Contains(err.Error(), "REGISTRY_RESOLUTION_BUCKET") {
+		t.Errorf("config:config_test - expected a REGISTRY_RESOLUTION_BUCKET error, got %v", err)
+	}
+	// A local mirror needs no database
+	cfg.ResolutionBucket, cfg.DatabaseURL = "", ""
+	if err := cfg.ValidateForServe(); err != nil {
+		t.Errorf("config:config_test - expected a local mirror without DATABASE_URL to be valid, got %v", err)
+	}
+}
+
+func TestValidateForServe_NegativeChangeRetention(t *testing.T) {
+	cfg := &Config{DatabaseURL: "postgres://localhost/db", RequestTimeout: 5 * time.Second, HealthCheckTimeout: 5 * time.Second, ChangeRetention: -time.Hour}
+	err := cfg.ValidateForServe()
+	if err == nil {
+		t.Fatal("config:config_test - expected error for negative REGISTRY_CHANGE_RETENTION")
+	}
+	if !strings.Contains(err.Error(), "REGISTRY_CHANGE_RETENTION") {
+		t.Errorf("config:config_test - error should mention REGISTRY_CHANGE_RETENTION, got %v", err)
+	}
+}
+
+func TestValidateForServe_ChangeEventTransport(t *testing.T) {
+	cfg := &Config{DatabaseURL: "postgres://localhost/db", RequestTimeout: 5 * time.Second, HealthCheckTimeout: 5 * time.Second, ChangeEventTransport: "kafka"}
+	err := cfg.ValidateForServe()
+	if err == nil || !strings.Contains(err.Error(), "REGISTRY_CHANGE_EVENT_TRANSPORT") {
+		t.Errorf("config:config_test - expected a REGISTRY_CHANGE_EVENT_TRANSPORT error, got %v", err)
+	}
+	cfg.ChangeEventTransport = "jetstream"
+	err = cfg.ValidateForServe()
+	if err == nil || !strings.Contains(err.Error(), "REGISTRY_CHANGE_STREAM") {
+		t.Errorf("config:config_test - expected a REGISTRY_CHANGE_STREAM error, got %v", err)
+	}
+	cfg.ChangeStream = "REGISTRY_CHANGES"
+	if err := cfg.ValidateForServe(); err != nil {
+		t.Errorf("config:config_test - unexpected error: %v", err)
+	}
+}
+
+func TestValidateForServe_ChangeSubjectPattern(t *testing.T) {
+	cfg := &Config{DatabaseURL: "postgres://localhost/db", RequestTimeout: 5 * time.Second, HealthCheckTimeout: 5 * time.Second, ChangeSubjectPattern: "events.{env}.{app}.{capability}"}
+	err := cfg.ValidateForServe()
+	if err == nil || !strings.Contains(err.Error(), "REGISTRY_CHANGE_SUBJECT_PATTERN") {
+		t.Errorf("config:config_test - expected a REGISTRY_CHANGE_SUBJECT_PATTERN error, got %v", err)
+	}
+	cfg.ChangeSubjectPattern = "registry.changed.{env}.{tenant}.{app}.{capability}"
+	if err := cfg.ValidateForServe(); err != nil {
+		t.Errorf("config:config_test - unexpected error: %v", err)
+	}
+}
+
+func TestValidateForServe_ChangeEventFormat(t *testing.T) {
+	cfg := &Config{DatabaseURL: "postgres://localhost/db", RequestTimeout: 5 * time.Second, HealthCheckTimeout: 5 * time.Second, ChangeEventFormat: "avro"}
+	err := cfg.ValidateForServe()
+	if err == nil || !strings.Contains(err.Error(), "REGISTRY_CHANGE_EVENT_FORMAT") {
+		t.Errorf("config:config_test - expected a REGISTRY_CHANGE_EVENT_FORMAT error, got %v", err)
+	}
+	for _, format := range []string{"", "json", "cloudevents", "cloudevents-binary"} {
+		cfg.ChangeEventFormat = format
+		if err := cfg.ValidateForServe(); err != nil {
+			t.Errorf("config:config_test - format %q: unexpected error: %v", format, err)
+		}
+	}
+}
+
+func TestValidateForServe_NegativeChangeStreamLimits(t *testing.T) {
+	cfg := &Config{DatabaseURL: "postgres://localhost/db", RequestTimeout: 5 * time.Second, HealthCheckTimeout: 5 * time.Second, ChangeStreamMaxAge: -time.Hour}
+	if err := cfg.ValidateForServe(); err == nil || !strings.Contains(err.Error(), "REGISTRY_CHANGE_STREAM_MAX_AGE") {
+		t.Errorf("config:config_test - expected a REGISTRY_CHANGE_STREAM_MAX_AGE error, got %v", err)
+	}
+	cfg.ChangeStreamMaxAge, cfg.ChangeStreamMaxMsgs = 0, -1
+	if err := cfg.ValidateForServe(); err == nil || !strings.Contains(err.Error(), "REGISTRY_CHANGE_STREAM_MAX_MSGS") {
+		t.Errorf("config:config_test - expected a REGISTRY_CHANGE_STREAM_MAX_MSGS error, got %v", err)
+	}
+}
+
+func TestValidateForServe_NegativeSSELimits(t *testing.T) {
+	cfg := &Config{DatabaseURL: "postgres://localhost/db", RequestTimeout: 5 * time.Second, HealthCheckTimeout: 5 * time.Second, SSEMaxStreams: -1}
+	if err := cfg.ValidateForServe(); err == nil || !strings.Contains(err.Error(), "REGISTRY_SSE_MAX_STREAMS") {
+		t.Errorf("config:config_test - expected a REGISTRY_SSE_MAX_STREAMS error, got %v", err)
+	}
+	cfg.SSEMaxStreams, cfg.SSEHeartbeat = 0, -time.Second
+	if err := cfg.ValidateForServe(); err == nil || !strings.Contains(err.Error(), "REGISTRY_SSE_HEARTBEAT") {
+		t.Errorf("config:config_test - expected a REGISTRY_SSE_HEARTBEAT error, got %v", err)
+	}
+}
+
+func TestValidateForServe_NegativeWebhookSettings(t *testing.T) {
+	base := Config{DatabaseURL: "postgres://localhost/db", RequestTimeout: 5 * time.Second, HealthCheckTimeout: 5 * time.Second}
+	tests := []struct {
+		envVar string
+		mutate func(*Config)
+	}{
+		{"REGISTRY_WEBHOOK_TIMEOUT", func(c *Config) { c.WebhookTimeout = -time.Second }},
+		{"REGISTRY_WEBHOOK_MAX_ATTEMPTS", func(c *Config) { c.WebhookMaxAttempts = -1 }},
+		{"REGISTRY_WEBHOOK_DISABLE_AFTER", func(c *Config) { c.WebhookDisableAfter = -1 }},
+	}
+	for _, tt := range tests {
+		cfg := base
+		tt.mutate(&cfg)
+		if err := cfg.ValidateForServe(); err == nil || !strings.Contains(err.Error(), tt.envVar) {
+			t.Errorf("config:config_test - expected a %s error, got %v", tt.envVar, err)
+		}
+	}
+}
+
 func TestValidateForServe_Valid(t *testing.T) {
 	cfg := &Config{
 		DatabaseURL:        "postgres://localhost/db",
@@ -14,11 +14,14 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	masterminds "github.com/Masterminds/semver/v3"
 	"github.com/jackc/pgx/v5/pgxpool"
 	comms "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
 
 	"github.com/morezero/capabilities-registry/internal/config"
 	"github.com/morezero/capabilities-registry/pkg/bootstrap"
@@ -47,13 +50,21 @@ type bootstrapResponse struct {
 	ChangeEvents         bootstrap.ChangeEventSubjects             `json:"changeEventSubjects"`
 }
 
+// bootstrapRequest is the optional bootstrap request body. Clients may send a resolution
+// context (env, release) to receive the capabilities that apply to them; an empty body uses defaults.
+type bootstrapRequest struct {
+	Ctx *registry.ResolutionContext `json:"ctx,omitempty"`
+}
+
 // registryForServer is the subset of registry used by the server (for testability).
 type registryForServer interface {
 	Health(ctx context.Context) *registry.HealthOutput
 	Discover(ctx context.Context, input *registry.DiscoverInput) (*registry.DiscoverOutput, error)
 	Describe(ctx context.Context, input *registry.DescribeInput) (*registry.DescribeOutput, error)
-	GetBootstrapCapabilities(ctx context.Context, env string, includeMethods, includeSchemas bool) (map[string]*registry.ResolveOutput, error)
+	GetBootstrapCapabilities(ctx context.Context, rctx *registry.ResolutionContext, includeMethods, includeSchemas bool) (map[string]*registry.ResolveOutput, error)
 	LoadRegistryAliases(ctx context.Context) (map[string]string, string, error)
+	TenantNatsUrl(ctx context.Context, tenantID string) (natsUrl, cell string)
+	ChangesSince(ctx context.Context, input *registry.ChangesSinceInput) (*registry.ChangesSinceOutput, error)
 	Close()
 }
 
@@ -64,6 +75,10 @@ type Server struct {
 	pool       *pgxpool.Pool
 	httpServer *http.Server
 	reg        registryForServer
+	// sseStreams counts the open /events streams; shutdown is closed when the HTTP server
+	// shuts down, ending them.
+	sseStreams atomic.Int64
+	shutdown   chan struct{}
 }
 
 // Run starts the server, blocks until shutdown signal, then cleans up.
@@ -95,13 +110,15 @@ func Run() error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	s := &Server{cfg: cfg}
+	s := &Server{cfg: cfg, shutdown: make(chan struct{})}
 
 	// Step 1: Load bootstrap config
 	bootstrapCfg, err := bootstrap.LoadBootstrapConfig(cfg.BootstrapFile)
 	if err != nil {
 		return fmt.Errorf("%s - failed to load bootstrap config: %w", logPrefix, err)
 	}
+	// Bootstrap advertises the change subjects this registry publishes to
+	bootstrapCfg.ChangeEvents = changeEventSubjects(cfg, bootstrapCfg.ChangeEvents)
 	resolved := bootstrap.CreateResolvedBootstrap(bootstrapCfg)
 
 	// Determine registry subject
@@ -115,10 +132,7 @@ func Run() error {
 	slog.Info(fmt.Sprintf("%s - Registry subject: %s", logPrefix, registrySubject))
 
 	// Client-facing NATS URL (returned to clients via GET /connection and in resolve/bootstrap)
-	natsClientURL := strings.TrimSpace(cfg.NATSClientURL)
-	if natsClientURL == "" {
-		natsClientURL = cfg.COMMSURL
-	}
+	natsClientURL := clientNATSURL(cfg)
 
 	// Step 2: Connect to NATS
 	nc, err := commsutil.Connect(cfg.COMMSURL, cfg.COMMSName)
@@ -128,64 +142,107 @@ func Run() error {
 	s.nc = nc
 	slog.Info(fmt.Sprintf("%s - Connected to NATS at %s", logPrefix, cfg.COMMSURL))
 
-	// Step 3: Ensure database exists (create on platform if missing), then connect
-	if err := db.EnsureDatabase(ctx, cfg.DatabaseURL); err != nil {
-		nc.Close()
-		return fmt.Errorf("%s - ensure database: %w", logPrefix, err)
-	}
-	pool, err := db.NewPool(ctx, cfg.DatabaseURL)
-	if err != nil {
-		nc.Close()
-		return fmt.Errorf("%s - failed to connect to database: %w", logPrefix, err)
-	}
-	s.pool = pool
-
-	// Step 3b: Run migrations if enabled
-	if cfg.RunMigrations {
-		migrationSQL, err := db.LoadMigrationFiles(cfg.MigrationPath)
+	// Step 3: Open the catalog store: the database, or for a local mirror a file (no database)
+	var pool *pgxpool.Pool
+	var repo *db.Repository
+	var catalog registry.CatalogStore
+	if cfg.MirrorStore == "local" {
+		store, err := registry.NewLocalStore(cfg.MirrorStorePath)
 		if err != nil {
-			pool.Close()
 			nc.Close()
-			return fmt.Errorf("%s - failed to load migrations: %w", logPrefix, err)
+			return fmt.Errorf("%s - failed to open the local mirror store: %w", logPrefix, err)
 		}
-		if err := db.RunMigrations(ctx, pool, migrationSQL); err != nil {
-			pool.Close()
+		catalog = store
+		slog.Info(fmt.Sprintf("%s - Mirroring into the local store %q without a database", logPrefix, cfg.MirrorStorePath))
+	} else {
+		pool, err = openDatabase(ctx, cfg, bootstrapCfg)
+		if err != nil {
 			nc.Close()
-			return fmt.Errorf("%s - failed to run migrations: %w", logPrefix, err)
+			return err
 		}
-		// Seed capability metadata from registry/capabilities/metadata.json (e.g. system.registry).
-		// Bootstrap file is not used for seeding; bootstrap response is built from DB.
-		metadataPath := filepath.Clean(filepath.Join(filepath.Dir(cfg.BootstrapFile), "..", "capabilities", "metadata.json"))
-		if err := db.SeedFromCapabilityMetadataFile(ctx, pool, metadataPath, ""); err != nil {
+		repo = db.NewRepository(pool)
+	}
+	s.pool = pool
+	closePool := func() {
+		if pool != nil {
 			pool.Close()
-			nc.Close()
-			return fmt.Errorf("%s - failed to seed capability metadata: %w", logPrefix, err)
 		}
 	}
 
 	// Step 4: Create registry (with NatsUrl for resolve responses — use client-facing URL so clients match default connection)
-	repo := db.NewRepository(pool)
-	publisherOpts := &events.CommsPublisherOpts{}
-	if cfg.ChangeEventSubject != "" {
-		publisherOpts.GlobalChangeSubject = cfg.ChangeEventSubject
+	var publisher events.EventPublisher
+	if cfg.ChangeEventTransport == "jetstream" {
+		// Durable change events: stored in a stream so offline subscribers can replay them
+		jsPublisher, err := events.NewJetStreamPublisher(nc, &events.JetStreamPublisherOpts{
+			GlobalChangeSubject:  cfg.ChangeEventSubject,
+			ChangeSubjectPattern: cfg.ChangeSubjectPattern,
+			Stream:               cfg.ChangeStream,
+			MaxAge:               cfg.ChangeStreamMaxAge,
+			MaxMsgs:              cfg.ChangeStreamMaxMsgs,
+			CloudEvents:          changeEventEncoding(cfg.ChangeEventFormat),
+		})
+		if err != nil {
+			closePool()
+			nc.Close()
+			return fmt.Errorf("%s - failed to set up the change stream: %w", logPrefix, err)
+		}
+		publisher = jsPublisher
+	} else {
+		publisherOpts := &events.CommsPublisherOpts{
+			ChangeSubjectPattern: cfg.ChangeSubjectPattern,
+			CloudEvents:          changeEventEncoding(cfg.ChangeEventFormat),
+		}
+		if cfg.ChangeEventSubject != "" {
+			publisherOpts.GlobalChangeSubject = cfg.ChangeEventSubject
+		}
+		publisher = events.NewCommsPublisher(nc, publisherOpts)
 	}
-	publisher := events.NewCommsPublisher(nc, publisherOpts)
-	regConfig := registry.DefaultConfig()
-	regConfig.NatsUrl = natsClientURL
+	regConfig := RegistryConfig(cfg, registrySubject)
 	reg := registry.NewRegistry(registry.NewRegistryParams{
 		Repo:      repo,
 		Publisher: publisher,
 		Config:    regConfig,
+		Conn:      nc,
+		Catalog:   catalog,
 	})
 	s.reg = reg
 
-	// Step 6: Create dispatcher and subscribe
+	// Step 4b: Materialize default resolutions into a KV bucket before anything mutates the
+	// registry, so every change after the reconcile updates it
+	if cfg.ResolutionBucket != "" {
+		if err := reg.EnableResolutionKV(ctx, cfg.ResolutionBucket); err != nil {
+			closePool()
+			nc.Close()
+			return fmt.Errorf("%s - failed to set up the resolution bucket: %w", logPrefix, err)
+		}
+	}
+
+	// Step 5: Reap expired provider instance leases, probe capability subjects, check remote
+	// registries and deliver webhooks in the background (stops with ctx). A mirror syncs its
+	// catalog from the upstream instead of importing micro services.
+	reg.StartInstanceReaper(ctx, cfg.InstanceReapInterval)
+	reg.StartProber(ctx, cfg.ProbeInterval)
+	if reg.ReadOnly() {
+		reg.StartMirror(ctx, cfg.MirrorSyncInterval)
+	} else {
+		reg.StartMicroImporter(ctx, cfg.MicroImportInterval)
+	}
+	reg.StartRegistryHealthChecker(ctx, cfg.FederationHealthInterval)
+	reg.StartChangePruner(ctx, cfg.ChangeRetention)
+	reg.StartWebhookDispatcher(ctx, registry.WebhookOptions{
+		Timeout:      cfg.WebhookTimeout,
+		MaxAttempts:  cfg.WebhookMaxAttempts,
+		DisableAfter: cfg.WebhookDisableAfter,
+	})
+
+	// Step 6: Create dispatcher and serve it as a NATS micro service endpoint, so
+	// $SRV.PING/INFO/STATS answer for system.registry
 	disp := dispatcher.NewDispatcher(reg)
 
 	requestTimeout := cfg.RequestTimeout
-	sub, err := nc.Subscribe(registrySubject, func(msg *comms.Msg) {
-		if len(msg.Data) > maxNATSRequestBytes {
-			slog.Error(fmt.Sprintf("%s - request too large: %d bytes", logPrefix, len(msg.Data)))
+	handleRequest := func(msg micro.Request) {
+		if len(msg.Data()) > maxNATSRequestBytes {
+			slog.Error(fmt.Sprintf("%s - request too large: %d bytes", logPrefix, len(msg.Data())))
 			resp := &dispatcher.RegistryResponse{
 				Ok: false,
 				Error: &dispatcher.ErrorDetail{
@@ -198,7 +255,7 @@ func Run() error {
 			return
 		}
 		var req dispatcher.RegistryRequest
-		if err := json.Unmarshal(msg.Data, &req); err != nil {
+		if err := json.Unmarshal(msg.Data(), &req); err != nil {
 			slog.Error(fmt.Sprintf("%s - failed to decode request: %v", logPrefix, err))
 			resp := &dispatcher.RegistryResponse{
 				Ok: false,
@@ -239,19 +296,38 @@ func Run() error {
 			return
 		}
 		msg.Respond(data)
+	}
+	svc, err := micro.AddService(nc, micro.Config{
+		Name:        commsutil.MicroServiceName("system", "registry"),
+		Version:     registryServiceVersion(bootstrapCfg),
+		Description: "Capability registry",
+		Metadata:    map[string]string{commsutil.MicroMetadataCapability: "system.registry"},
 	})
+	if err == nil {
+		err = svc.AddEndpoint("registry", micro.HandlerFunc(handleRequest), micro.WithEndpointSubject(registrySubject))
+		if err != nil {
+			svc.Stop()
+		}
+	}
 	if err != nil {
-		pool.Close()
+		closePool()
 		nc.Close()
 		return fmt.Errorf("%s - failed to subscribe to %s: %w", logPrefix, registrySubject, err)
 	}
-	slog.Info(fmt.Sprintf("%s - Subscribed to %s", logPrefix, registrySubject))
+	slog.Info(fmt.Sprintf("%s - Subscribed to %s as micro service %s", logPrefix, registrySubject, svc.Info().Name))
 
 	// Step 5b: Subscribe to bootstrap subject. Response is the same shape as resolve: capabilities map to ResolveOutput (no expiration).
 	// Bootstrap config file supplies envelope (name, version, minimum_capabilities, changeEventSubjects, aliases).
 	bootstrapSub, err := nc.Subscribe(commsutil.SubjectBootstrap, func(msg *comms.Msg) {
+		var bootReq bootstrapRequest
+		if len(msg.Data) > 0 && len(msg.Data) <= maxNATSRequestBytes {
+			if err := json.Unmarshal(msg.Data, &bootReq); err != nil {
+				slog.Debug(fmt.Sprintf("%s - bootstrap request body ignored: %v", logPrefix, err))
+			}
+		}
+
 		// Load capabilities from DB in resolve shape (include methods; optional schemas)
-		caps, err := reg.GetBootstrapCapabilities(ctx, "production", true, false)
+		caps, err := reg.GetBootstrapCapabilities(ctx, bootReq.Ctx, true, false)
 		if err != nil {
 			slog.Error(fmt.Sprintf("%s - bootstrap get capabilities: %v", logPrefix, err))
 			msg.Respond([]byte(`{"capabilities":{}}`))
@@ -295,8 +371,8 @@ func Run() error {
 		msg.Respond(data)
 	})
 	if err != nil {
-		sub.Unsubscribe()
-		pool.Close()
+		svc.Stop()
+		closePool()
 		nc.Close()
 		return fmt.Errorf("%s - failed to subscribe to %s: %w", logPrefix, commsutil.SubjectBootstrap, err)
 	}
@@ -326,21 +402,16 @@ func Run() error {
 	})
 
 	// Connection data for clients: NATS URL to use (registry-first flow; natsClientURL computed above).
-	mux.HandleFunc("/connection", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			w.Header().Set("Allow", "GET")
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"natsUrl": natsClientURL})
-	})
+	mux.HandleFunc("/connection", s.handleConnection(natsClientURL))
+	// Change events as Server-Sent Events for browsers and tools without NATS
+	mux.HandleFunc("/events", s.handleEvents())
 
 	httpAddr := cfg.HTTPAddr
 	if httpAddr == "" {
 		httpAddr = fmt.Sprintf(":%d", cfg.HTTPPort)
 	}
 	s.httpServer = &http.Server{Addr: httpAddr, Handler: mux}
+	s.httpServer.RegisterOnShutdown(func() { close(s.shutdown) })
 	listenPort := ""
 	if _, port, err := net.SplitHostPort(httpAddr); err == nil {
 		listenPort = port
@@ -363,16 +434,144 @@ func Run() error {
 	slog.Info(fmt.Sprintf("%s - Received signal %s, shutting down", logPrefix, sig))
 
 	// Graceful shutdown
-	sub.Unsubscribe()
+	svc.Stop()
 	s.httpServer.Shutdown(ctx)
 	reg.Close()
 	nc.Drain()
-	pool.Close()
+	closePool()
 
 	slog.Info(fmt.Sprintf("%s - Shutdown complete", logPrefix))
 	return nil
 }
 
+// openDatabase ensures the database exists, connects to it, runs the migrations and seeds
+// when enabled, and loads the bootstrap aliases and subjects.
+func openDatabase(ctx context.Context, cfg *config.Config, bootstrapCfg *bootstrap.BootstrapConfig) (*pgxpool.Pool, error) {
+	// Ensure database exists (create on platform if missing), then connect
+	if err := db.EnsureDatabase(ctx, cfg.DatabaseURL); err != nil {
+		return nil, fmt.Errorf("%s - ensure database: %w", logPrefix, err)
+	}
+	pool, err := db.NewPool(ctx, cfg.DatabaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("%s - failed to connect to database: %w", logPrefix, err)
+	}
+
+	// Run migrations if enabled
+	if cfg.RunMigrations {
+		migrationSQL, err := db.LoadMigrationFiles(cfg.MigrationPath)
+		if err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("%s - failed to load migrations: %w", logPrefix, err)
+		}
+		if err := db.RunMigrations(ctx, pool, migrationSQL); err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("%s - failed to run migrations: %w", logPrefix, err)
+		}
+		// Seed capability metadata from registry/capabilities/metadata.json (e.g. system.registry).
+		// Bootstrap file is not used for seeding; bootstrap response is built from DB.
+		metadataPath := filepath.Clean(filepath.Join(filepath.Dir(cfg.BootstrapFile), "..", "capabilities", "metadata.json"))
+		if err := db.SeedFromCapabilityMetadataFile(ctx, pool, metadataPath, ""); err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("%s - failed to seed capability metadata: %w", logPrefix, err)
+		}
+	}
+
+	// Load bootstrap aliases into the capability alias table so old refs keep resolving
+	if err := db.SeedCapabilityAliases(ctx, pool, bootstrapCfg.Aliases); err != nil {
+		slog.Warn(fmt.Sprintf("%s - failed to load bootstrap aliases: %v", logPrefix, err))
+	}
+	// Bootstrap subjects that differ from the default scheme become the capability's subject template
+	if err := db.SeedBootstrapSubjects(ctx, pool, bootstrapCfg.Capabilities); err != nil {
+		slog.Warn(fmt.Sprintf("%s - failed to load bootstrap subjects: %v", logPrefix, err))
+	}
+	return pool, nil
+}
+
+// registryServiceVersion returns the system.registry version from the bootstrap config for the
+// micro service identity, or 1.0.0 when it is missing or not strict semver.
+func registryServiceVersion(cfg *bootstrap.BootstrapConfig) string {
+	if cfg != nil {
+		if c, ok := cfg.Capabilities["system.registry"]; ok {
+			if _, err := masterminds.StrictNewVersion(c.Version); err == nil {
+				return c.Version
+			}
+		}
+	}
+	return "1.0.0"
+}
+
+// changeEventSubjects returns the change subjects the registry publishes to, as advertised in
+// bootstrap: the configured global subject and pattern take precedence over the bootstrap
+// file's, and AllScope is set when the pattern is scoped by env or tenant.
+func changeEventSubjects(cfg *config.Config, fromFile bootstrap.ChangeEventSubjects) bootstrap.ChangeEventSubjects {
+	subjects := fromFile
+	if cfg.ChangeEventSubject != "" {
+		subjects.Global = cfg.ChangeEventSubject
+	}
+	if subjects.Global == "" {
+		subjects.Global = commsutil.SubjectChangeEvent
+	}
+	if cfg.ChangeSubjectPattern != "" {
+		subjects.Pattern = cfg.ChangeSubjectPattern
+	}
+	if subjects.Pattern == "" {
+		subjects.Pattern = commsutil.DefaultChangeSubjectPattern
+	}
+	subjects.AllScope = ""
+	if strings.Contains(subjects.Pattern, commsutil.TokenEnv) || strings.Contains(subjects.Pattern, commsutil.ChangeTokenTenant) {
+		subjects.AllScope = commsutil.ChangeScopeAll
+	}
+	return subjects
+}
+
+// clientNATSURL is the client-facing NATS URL: NATS_CLIENT_URL, or COMMS_URL when unset.
+func clientNATSURL(cfg *config.Config) string {
+	if u := strings.TrimSpace(cfg.NATSClientURL); u != "" {
+		return u
+	}
+	return cfg.COMMSURL
+}
+
+// RegistryConfig maps the loaded config onto the registry configuration. registrySubject is
+// the subject the registry answers on and names it in the federation trace by default. The
+// CLI builds its registry with it too, so lockfiles match what serve resolves.
+func RegistryConfig(cfg *config.Config, registrySubject string) registry.Config {
+	natsClientURL := clientNATSURL(cfg)
+	regConfig := registry.DefaultConfig()
+	regConfig.NatsUrl = natsClientURL
+	regConfig.TenantShards = cfg.TenantShards
+	regConfig.InstanceTTLSeconds = int(cfg.InstanceLeaseTTL / time.Second)
+	regConfig.ProbeTimeout = cfg.ProbeTimeout
+	regConfig.FederationFailureThreshold = cfg.FederationFailureThreshold
+	regConfig.FederationCooldown = cfg.FederationCooldown
+	regConfig.FederationMaxStale = cfg.FederationMaxStale
+	regConfig.FederationID = cfg.FederationID
+	if regConfig.FederationID == "" {
+		regConfig.FederationID = natsClientURL + "/" + registrySubject
+	}
+	regConfig.FederationMaxHops = cfg.FederationMaxHops
+	regConfig.MirrorUpstream = cfg.MirrorUpstream
+	regConfig.MirrorUpstreamURL = cfg.MirrorUpstreamURL
+	regConfig.MirrorUpstreamSubject = cfg.MirrorUpstreamSubject
+	if regConfig.MirrorUpstreamURL != "" && regConfig.MirrorUpstreamSubject == "" {
+		regConfig.MirrorUpstreamSubject = commsutil.SubjectRegistry
+	}
+	regConfig.WatchInboxPrefix = cfg.WatchInboxPrefix
+	regConfig.WebhookAllowPrivate = cfg.WebhookAllowPrivate
+	return regConfig
+}
+
+// changeEventEncoding maps REGISTRY_CHANGE_EVENT_FORMAT to the publishers' CloudEvents mode.
+func changeEventEncoding(format string) events.CloudEventsMode {
+	switch format {
+	case "cloudevents":
+		return events.CloudEventsStructured
+	case "cloudevents-binary":
+		return events.CloudEventsBinary
+	}
+	return events.CloudEventsOff
+}
+
 // homePageTemplate is the HTML for the registry home page (white bg, black/blue text).
 const homePageTemplate = `<!DOCTYPE html>
 <html lang="en">
@@ -407,6 +606,30 @@ const homePageTemplate = `<!DOCTYPE html>
     <p>Timestamp: {{.Health.Timestamp}}</p>
   </section>
 
+  {{if .Health.Registries}}
+  <section>
+    <h2>Remote registries</h2>
+    <table>
+      <thead>
+        <tr><th>Alias</th><th>Status</th><th>Circuit</th><th>Failures</th><th>Latency (ms)</th><th>Last checked</th><th>Last error</th></tr>
+      </thead>
+      <tbody>
+        {{range .Health.Registries}}
+        <tr>
+          <td>@{{.Alias}}</td>
+          <td><span class="status-{{.Status}}">{{.Status}}</span></td>
+          <td>{{if eq .Circuit "closed"}}{{.Circuit}}{{else}}<span class="error">{{.Circuit}}</span>{{end}}</td>
+          <td>{{.ConsecutiveFailures}}</td>
+          <td>{{if .LatencyMs}}{{.LatencyMs}}{{end}}</td>
+          <td>{{.LastChecked}}</td>
+          <td>{{if .LastError}}<span class="error">{{.LastError}}</span>{{end}}</td>
+        </tr>
+        {{end}}
+      </tbody>
+    </table>
+  </section>
+  {{end}}
+
   <section>
     <h2>Statistics</h2>
     {{if .DiscoverError}}
@@ -427,7 +650,7 @@ const homePageTemplate = `<!DOCTYPE html>
     {{else}}
     <table>
       <thead>
-        <tr><th>Capability</th><th>App</th><th>Name</th><th>Default major</th><th>Latest version</th><th>Status</th></tr>
+        <tr><th>Capability</th><th>App</th><th>Name</th><th>Default major</th><th>Latest version</th><th>Status</th><th>Instances</th></tr>
       </thead>
       <tbody>
         {{range .Discover.Capabilities}}
@@ -438,6 +661,7 @@ const homePageTemplate = `<!DOCTYPE html>
           <td>{{.DefaultMajor}}</td>
           <td>{{.LatestVersion}}</td>
           <td>{{.Status}}</td>
+          <td>{{.Instances}}</td>
         </tr>
         {{end}}
       </tbody>
@@ -492,6 +716,7 @@ const capabilityDetailPageTemplate = `<!DOCTYPE html>
       <tr><th>Version</th><td>{{.Describe.Version}}</td></tr>
       <tr><th>Major</th><td>{{.Describe.Major}}</td></tr>
       <tr><th>Status</th><td>{{.Describe.Status}}</td></tr>
+      <tr><th>Live instances</th><td>{{.Describe.Instances}} (liveness policy: {{.Describe.LivenessPolicy}})</td></tr>
       {{if .Describe.Tags}}
       <tr><th>Tags</th><td>{{range .Describe.Tags}}{{.}} {{end}}</td></tr>
       {{end}}
@@ -543,6 +768,27 @@ type homeData struct {
 	DiscoverError string
 }
 
+// handleConnection serves GET /connection: the NATS URL clients should connect to.
+// With ?tenantId= a tenant routed to a cell gets the cell URL and name instead.
+func (s *Server) handleConnection(natsClientURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		resp := map[string]string{"natsUrl": natsClientURL}
+		if tenantID := r.URL.Query().Get("tenantId"); tenantID != "" {
+			if natsUrl, cell := s.reg.TenantNatsUrl(r.Context(), tenantID); cell != "" {
+				resp["natsUrl"] = natsUrl
+				resp["cell"] = cell
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
 // handleHome returns an HTTP handler for the registry home page.
 func (s *Server) handleHome() http.HandlerFunc {
 	tmpl := template.Must(template.New("home").Parse(homePageTemplate))
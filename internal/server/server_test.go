@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"github.com/morezero/capabilities-registry/internal/config"
+	"github.com/morezero/capabilities-registry/pkg/bootstrap"
+	"github.com/morezero/capabilities-registry/pkg/events"
 	"github.com/morezero/capabilities-registry/pkg/registry"
 )
 
@@ -22,6 +24,8 @@ type mockRegistry struct {
 	discoverErr error
 	describe *registry.DescribeOutput
 	describeErr error
+	cells    map[string]string
+	changes  []*registry.ChangesSinceOutput
 }
 
 func (m *mockRegistry) Health(context.Context) *registry.HealthOutput {
@@ -39,7 +43,7 @@ func (m *mockRegistry) Describe(ctx context.Context, input *registry.DescribeInp
 	return m.describe, m.describeErr
 }
 
-func (m *mockRegistry) GetBootstrapCapabilities(context.Context, string, bool, bool) (map[string]*registry.ResolveOutput, error) {
+func (m *mockRegistry) GetBootstrapCapabilities(context.Context, *registry.ResolutionContext, bool, bool) (map[string]*registry.ResolveOutput, error) {
 	return nil, nil
 }
 
@@ -47,6 +51,23 @@ func (m *mockRegistry) LoadRegistryAliases(context.Context) (map[string]string,
 	return nil, "", nil
 }
 
+func (m *mockRegistry) TenantNatsUrl(_ context.Context, tenantID string) (string, string) {
+	if url, ok := m.cells[tenantID]; ok {
+		return url, "cell-" + tenantID
+	}
+	return "", ""
+}
+
+// ChangesSince returns the configured pages in order.
+func (m *mockRegistry) ChangesSince(context.Context, *registry.ChangesSinceInput) (*registry.ChangesSinceOutput, error) {
+	if len(m.changes) == 0 {
+		return &registry.ChangesSinceOutput{}, nil
+	}
+	out := m.changes[0]
+	m.changes = m.changes[1:]
+	return out, nil
+}
+
 func (m *mockRegistry) Close() {}
 
 // testServer returns a Server with mock registry and test config for HTTP handler tests.
@@ -189,7 +210,7 @@ func TestHandleHome_Success(t *testing.T) {
 	reg := &mockRegistry{
 		health: &registry.HealthOutput{Status: "healthy", Checks: registry.HealthChecks{Database: true}, Timestamp: time.Now().UTC().Format(time.RFC3339)},
 		discover: &registry.DiscoverOutput{
-			Capabilities: []registry.DiscoveredCapability{{Cap: "more0.test", App: "more0", Name: "test", DefaultMajor: 1, LatestVersion: "1.0.0", Status: "active"}},
+			Capabilities: []registry.DiscoveredCapability{{Cap: "more0.test", App: "more0", Name: "test", DefaultMajor: 1, LatestVersion: "1.0.0", Status: "active", Instances: 3}},
 			Pagination:   registry.Pagination{Page: 1, Limit: 100, Total: 1, TotalPages: 1},
 		},
 	}
@@ -211,6 +232,36 @@ func TestHandleHome_Success(t *testing.T) {
 	if !strings.Contains(body, "healthy") || !strings.Contains(body, "more0.test") {
 		t.Errorf("%s - body should contain health and capability", serverTestPrefix)
 	}
+	if !strings.Contains(body, "<th>Instances</th>") || !strings.Contains(body, "<td>3</td>") {
+		t.Errorf("%s - body should show the instance count", serverTestPrefix)
+	}
+}
+
+func TestHandleHome_RemoteRegistries(t *testing.T) {
+	latency := 12
+	reg := &mockRegistry{
+		health: &registry.HealthOutput{
+			Status:    "healthy",
+			Checks:    registry.HealthChecks{Database: true},
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Registries: []registry.RemoteRegistryHealth{
+				{Alias: "partner", Status: "healthy", Circuit: registry.CircuitClosed, LatencyMs: &latency},
+				{Alias: "sandbox", Status: "unreachable", Circuit: registry.CircuitOpen, ConsecutiveFailures: 3, LastError: "no responders available for request"},
+			},
+		},
+		discover: &registry.DiscoverOutput{Pagination: registry.Pagination{Page: 1, Limit: 100}},
+	}
+	s := testServer(t, reg)
+	rec := httptest.NewRecorder()
+	s.handleHome().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	body := rec.Body.String()
+	if !strings.Contains(body, "Remote registries") || !strings.Contains(body, "@partner") || !strings.Contains(body, "<td>12</td>") {
+		t.Errorf("%s - body should list the healthy remote with its latency", serverTestPrefix)
+	}
+	if !strings.Contains(body, `<span class="error">open</span>`) || !strings.Contains(body, "no responders available") {
+		t.Errorf("%s - body should flag the open circuit and its last error", serverTestPrefix)
+	}
 }
 
 func TestHandleHome_DiscoverError(t *testing.T) {
@@ -317,17 +368,10 @@ func TestReadyHandler(t *testing.T) {
 }
 
 func TestConnectionHandler_GET(t *testing.T) {
+	s := testServer(t, &mockRegistry{})
 	req := httptest.NewRequest(http.MethodGet, "/connection", nil)
 	rec := httptest.NewRecorder()
-	func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			w.Header().Set("Allow", "GET")
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"natsUrl": "nats://127.0.0.1:4222"})
-	}(rec, req)
+	s.handleConnection("nats://127.0.0.1:4222")(rec, req)
 	if rec.Code != http.StatusOK {
 		t.Errorf("%s - connection GET got status %d, want 200", serverTestPrefix, rec.Code)
 	}
@@ -340,16 +384,35 @@ func TestConnectionHandler_GET(t *testing.T) {
 	}
 }
 
+func TestConnectionHandler_TenantCell(t *testing.T) {
+	s := testServer(t, &mockRegistry{cells: map[string]string{"acme": "nats://eu.example:4222"}})
+
+	rec := httptest.NewRecorder()
+	s.handleConnection("nats://127.0.0.1:4222")(rec, httptest.NewRequest(http.MethodGet, "/connection?tenantId=acme", nil))
+	var out map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&out); err != nil {
+		t.Fatalf("%s - decode connection: %v", serverTestPrefix, err)
+	}
+	if out["natsUrl"] != "nats://eu.example:4222" || out["cell"] != "cell-acme" {
+		t.Errorf("%s - tenant connection = %v, want cell URL", serverTestPrefix, out)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleConnection("nats://127.0.0.1:4222")(rec, httptest.NewRequest(http.MethodGet, "/connection?tenantId=globex", nil))
+	out = nil
+	if err := json.NewDecoder(rec.Body).Decode(&out); err != nil {
+		t.Fatalf("%s - decode connection: %v", serverTestPrefix, err)
+	}
+	if out["natsUrl"] != "nats://127.0.0.1:4222" || out["cell"] != "" {
+		t.Errorf("%s - unmapped tenant connection = %v, want client URL", serverTestPrefix, out)
+	}
+}
+
 func TestConnectionHandler_MethodNotAllowed(t *testing.T) {
+	s := testServer(t, &mockRegistry{})
 	req := httptest.NewRequest(http.MethodPost, "/connection", nil)
 	rec := httptest.NewRecorder()
-	func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			w.Header().Set("Allow", "GET")
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-	}(rec, req)
+	s.handleConnection("nats://127.0.0.1:4222")(rec, req)
 	if rec.Code != http.StatusMethodNotAllowed {
 		t.Errorf("%s - connection POST got status %d, want 405", serverTestPrefix, rec.Code)
 	}
@@ -428,3 +491,51 @@ func TestHandleCapabilityDetail_RedirectWhenNoCap(t *testing.T) {
 		t.Errorf("%s - /capability/ got status %d, want 302 redirect", serverTestPrefix, rec.Code)
 	}
 }
+
+func TestChangeEventSubjects(t *testing.T) {
+	fromFile := bootstrap.ChangeEventSubjects{Global: "registry.changed", Pattern: "registry.changed.{app}.{capability}"}
+	got := changeEventSubjects(&config.Config{}, fromFile)
+	if got != fromFile {
+		t.Errorf("%s - without overrides got %+v, want the bootstrap file's", serverTestPrefix, got)
+	}
+
+	cfg := &config.Config{ChangeEventSubject: "registry.changed.all", ChangeSubjectPattern: "registry.changed.{env}.{tenant}.{app}.{capability}"}
+	got = changeEventSubjects(cfg, fromFile)
+	want := bootstrap.ChangeEventSubjects{Global: "registry.changed.all", Pattern: "registry.changed.{env}.{tenant}.{app}.{capability}", AllScope: "_all"}
+	if got != want {
+		t.Errorf("%s - changeEventSubjects = %+v, want %+v", serverTestPrefix, got, want)
+	}
+
+	got = changeEventSubjects(&config.Config{}, bootstrap.ChangeEventSubjects{})
+	if got.Global != "registry.changed" || got.Pattern != "registry.changed.{app}.{capability}" || got.AllScope != "" {
+		t.Errorf("%s - defaults = %+v", serverTestPrefix, got)
+	}
+}
+
+func TestChangeEventEncoding(t *testing.T) {
+	tests := map[string]events.CloudEventsMode{
+		"":                   events.CloudEventsOff,
+		"json":               events.CloudEventsOff,
+		"cloudevents":        events.CloudEventsStructured,
+		"cloudevents-binary": events.CloudEventsBinary,
+	}
+	for format, want := range tests {
+		if got := changeEventEncoding(format); got != want {
+			t.Errorf("%s - changeEventEncoding(%q) = %q, want %q", serverTestPrefix, format, got, want)
+		}
+	}
+}
+
+func TestRegistryServiceVersion(t *testing.T) {
+	if got := registryServiceVersion(nil); got != "1.0.0" {
+		t.Errorf("%s - registryServiceVersion(nil) = %q, want 1.0.0", serverTestPrefix, got)
+	}
+	cfg := &bootstrap.BootstrapConfig{Capabilities: map[string]bootstrap.BootstrapCapability{"system.registry": {Version: "1.4.2"}}}
+	if got := registryServiceVersion(cfg); got != "1.4.2" {
+		t.Errorf("%s - registryServiceVersion = %q, want 1.4.2", serverTestPrefix, got)
+	}
+	cfg.Capabilities["system.registry"] = bootstrap.BootstrapCapability{Version: "v1"}
+	if got := registryServiceVersion(cfg); got != "1.0.0" {
+		t.Errorf("%s - registryServiceVersion with invalid version = %q, want 1.0.0", serverTestPrefix, got)
+	}
+}
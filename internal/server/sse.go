@@ -0,0 +1,205 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	comms "github.com/nats-io/nats.go"
+
+	"github.com/morezero/capabilities-registry/pkg/commsutil"
+	"github.com/morezero/capabilities-registry/pkg/events"
+	"github.com/morezero/capabilities-registry/pkg/registry"
+)
+
+const (
+	sseLogPrefix = "server:sse"
+	// sseBufferSize is how many live events a stream holds while its client is slow; NATS
+	// drops events beyond it and the client can backfill from the gap in event IDs.
+	sseBufferSize    = 256
+	sseBackfillLimit = 1000
+)
+
+// changeFilter selects the change events of an event stream; empty fields match everything.
+type changeFilter struct {
+	app        string
+	capability string
+	env        string
+}
+
+// match reports whether event passes the filter. Events without an env apply to every env.
+func (f changeFilter) match(event *events.RegistryChangedEvent) bool {
+	if f.app != "" && event.App != f.app {
+		return false
+	}
+	if f.capability != "" && event.Capability != f.capability {
+		return false
+	}
+	return f.env == "" || event.Env == "" || event.Env == f.env
+}
+
+// handleEvents serves GET /events: registry change events as Server-Sent Events, filtered
+// by ?app=, ?capability= and ?env=. Each event's id is its registry sequence; a client
+// reconnecting with Last-Event-ID (or ?lastEventId=) first receives the changes it missed,
+// or a "resync" event when they are no longer available. Comment lines are sent as
+// heartbeats. At most REGISTRY_SSE_MAX_STREAMS streams are open at once.
+func (s *Server) handleEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if s.cfg.SSEMaxStreams == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok || s.nc == nil {
+			http.Error(w, "event streaming unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		var since int64
+		resume := false
+		lastEventID := r.Header.Get("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = r.URL.Query().Get("lastEventId")
+		}
+		if lastEventID != "" {
+			seq, err := strconv.ParseInt(lastEventID, 10, 64)
+			if err != nil || seq < 0 {
+				http.Error(w, "Last-Event-ID must be a registry sequence", http.StatusBadRequest)
+				return
+			}
+			since, resume = seq, true
+		}
+
+		if !s.acquireStream() {
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, "too many event streams", http.StatusServiceUnavailable)
+			return
+		}
+		defer s.releaseStream()
+
+		filter := changeFilter{
+			app:        r.URL.Query().Get("app"),
+			capability: r.URL.Query().Get("capability"),
+			env:        r.URL.Query().Get("env"),
+		}
+		// Subscribe before backfilling so no change between the two is missed
+		live := make(chan *comms.Msg, sseBufferSize)
+		sub, err := s.nc.ChanSubscribe(commsutil.SubjectChangeEvent+".>", live)
+		if err != nil {
+			slog.Error(fmt.Sprintf("%s - subscribe failed: %v", sseLogPrefix, err))
+			http.Error(w, "event streaming unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		defer sub.Unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, ": connected\n\n")
+		flusher.Flush()
+
+		last := since
+		if resume {
+			if last, err = s.backfillEvents(w, r, filter, since); err != nil {
+				slog.Warn(fmt.Sprintf("%s - backfill after %d failed: %v", sseLogPrefix, since, err))
+				return
+			}
+			flusher.Flush()
+		}
+
+		var heartbeat <-chan time.Time
+		if s.cfg.SSEHeartbeat > 0 {
+			ticker := time.NewTicker(s.cfg.SSEHeartbeat)
+			defer ticker.Stop()
+			heartbeat = ticker.C
+		}
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-s.shutdown:
+				return
+			case <-heartbeat:
+				fmt.Fprint(w, ": heartbeat\n\n")
+				flusher.Flush()
+			case msg := <-live:
+				event, err := events.DecodeChangeEvent(msg)
+				if err != nil {
+					continue
+				}
+				if event.Sequence > 0 {
+					if event.Sequence <= last {
+						// Already sent by the backfill
+						continue
+					}
+					last = event.Sequence
+				}
+				if filter.match(event) {
+					writeChangeEvent(w, event)
+					flusher.Flush()
+				}
+			}
+		}
+	}
+}
+
+// backfillEvents writes the matching changes after since and returns the last sequence
+// covered. When they are no longer available, it writes a "resync" event with the latest
+// sequence instead.
+func (s *Server) backfillEvents(w http.ResponseWriter, r *http.Request, filter changeFilter, since int64) (int64, error) {
+	for {
+		out, err := s.reg.ChangesSince(r.Context(), &registry.ChangesSinceInput{Since: since, Limit: sseBackfillLimit})
+		if err != nil {
+			return since, err
+		}
+		if out.Resync {
+			data, _ := json.Marshal(map[string]int64{"latest": out.Latest})
+			fmt.Fprintf(w, "id: %d\nevent: resync\ndata: %s\n\n", out.Latest, data)
+			return out.Latest, nil
+		}
+		for i := range out.Changes {
+			if filter.match(&out.Changes[i]) {
+				writeChangeEvent(w, &out.Changes[i])
+			}
+		}
+		since = out.Next
+		if !out.HasMore {
+			return since, nil
+		}
+	}
+}
+
+// writeChangeEvent writes event as an SSE message, with its sequence as the event id.
+func writeChangeEvent(w http.ResponseWriter, event *events.RegistryChangedEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	if event.Sequence > 0 {
+		fmt.Fprintf(w, "id: %d\n", event.Sequence)
+	}
+	fmt.Fprintf(w, "data: %s\n\n", data)
+}
+
+// acquireStream reserves one of the REGISTRY_SSE_MAX_STREAMS event streams.
+func (s *Server) acquireStream() bool {
+	if s.sseStreams.Add(1) > int64(s.cfg.SSEMaxStreams) {
+		s.sseStreams.Add(-1)
+		return false
+	}
+	return true
+}
+
+func (s *Server) releaseStream() {
+	s.sseStreams.Add(-1)
+}
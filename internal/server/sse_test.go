@@ -0,0 +1,234 @@
+package server
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	commsserver "github.com/nats-io/nats-server/v2/server"
+	comms "github.com/nats-io/nats.go"
+
+	"github.com/morezero/capabilities-registry/internal/config"
+	"github.com/morezero/capabilities-registry/pkg/events"
+	"github.com/morezero/capabilities-registry/pkg/registry"
+)
+
+const sseTestPrefix = "server:sse_test"
+
+// sseTestServer serves /events from a Server backed by reg and an embedded NATS server.
+func sseTestServer(t *testing.T, reg registryForServer, maxStreams int, heartbeat time.Duration) (*httptest.Server, *comms.Conn) {
+	t.Helper()
+	ns, err := commsserver.NewServer(&commsserver.Options{Host: "127.0.0.1", Port: -1, NoLog: true, NoSigs: true})
+	if err != nil {
+		t.Fatalf("%s - failed to create NATS server: %v", sseTestPrefix, err)
+	}
+	go ns.Start()
+	if !ns.ReadyForConnections(10 * time.Second) {
+		t.Fatalf("%s - NATS server failed to start", sseTestPrefix)
+	}
+	nc, err := comms.Connect(ns.ClientURL())
+	if err != nil {
+		ns.Shutdown()
+		t.Fatalf("%s - failed to connect: %v", sseTestPrefix, err)
+	}
+
+	s := &Server{
+		cfg:      &config.Config{SSEMaxStreams: maxStreams, SSEHeartbeat: heartbeat},
+		nc:       nc,
+		reg:      reg,
+		shutdown: make(chan struct{}),
+	}
+	ts := httptest.NewServer(s.handleEvents())
+	t.Cleanup(func() {
+		close(s.shutdown)
+		ts.Close()
+		nc.Close()
+		ns.Shutdown()
+	})
+	return ts, nc
+}
+
+// openStream opens an event stream and returns its SSE messages (blank-line separated blocks).
+func openStream(t *testing.T, url, lastEventID string) (*http.Response, <-chan string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s - request failed: %v", sseTestPrefix, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	blocks := make(chan string, 16)
+	go func() {
+		defer close(blocks)
+		scanner := bufio.NewScanner(resp.Body)
+		var block []string
+		for scanner.Scan() {
+			if line := scanner.Text(); line != "" {
+				block = append(block, line)
+				continue
+			}
+			blocks <- strings.Join(block, "\n")
+			block = nil
+		}
+	}()
+	return resp, blocks
+}
+
+// nextBlock returns the next SSE message, skipping comments (connected, heartbeats).
+func nextBlock(t *testing.T, blocks <-chan string) string {
+	t.Helper()
+	for {
+		select {
+		case block, ok := <-blocks:
+			if !ok {
+				t.Fatalf("%s - stream closed", sseTestPrefix)
+			}
+			if strings.HasPrefix(block, ":") {
+				continue
+			}
+			return block
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s - timeout waiting for an event", sseTestPrefix)
+		}
+	}
+}
+
+func TestChangeFilter_Match(t *testing.T) {
+	event := &events.RegistryChangedEvent{App: "more0", Capability: "doc-ingest", Env: "staging"}
+	tests := []struct {
+		filter changeFilter
+		want   bool
+	}{
+		{changeFilter{}, true},
+		{changeFilter{app: "more0"}, true},
+		{changeFilter{app: "acme"}, false},
+		{changeFilter{app: "more0", capability: "doc-ingest"}, true},
+		{changeFilter{capability: "billing"}, false},
+		{changeFilter{env: "staging"}, true},
+		{changeFilter{env: "production"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.match(event); got != tt.want {
+			t.Errorf("%s - %+v.match = %v, want %v", sseTestPrefix, tt.filter, got, tt.want)
+		}
+	}
+	if !(changeFilter{env: "production"}).match(&events.RegistryChangedEvent{App: "more0"}) {
+		t.Errorf("%s - an event without an env applies to every env", sseTestPrefix)
+	}
+}
+
+func TestHandleEvents_StreamsFilteredEvents(t *testing.T) {
+	ts, nc := sseTestServer(t, &mockRegistry{}, 10, 0)
+	resp, blocks := openStream(t, ts.URL+"?app=more0", "")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("%s - status %d, content type %q", sseTestPrefix, resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	<-blocks // ": connected"
+
+	_ = nc.Publish("registry.changed.acme.billing", []byte(`{"app":"acme","capability":"billing","sequence":6}`))
+	_ = nc.Publish("registry.changed.more0.doc-ingest", []byte(`{"app":"more0","capability":"doc-ingest","revision":3,"sequence":7}`))
+	block := nextBlock(t, blocks)
+	if !strings.HasPrefix(block, "id: 7\ndata: ") || !strings.Contains(block, `"capability":"doc-ingest"`) {
+		t.Errorf("%s - unexpected event:\n%s", sseTestPrefix, block)
+	}
+}
+
+func TestHandleEvents_DecodesCloudEvents(t *testing.T) {
+	ts, nc := sseTestServer(t, &mockRegistry{}, 10, 0)
+	_, blocks := openStream(t, ts.URL, "")
+	<-blocks // ": connected"
+
+	msg := comms.NewMsg("registry.changed.more0.doc-ingest")
+	msg.Header.Set("content-type", events.CloudEventsContentType)
+	msg.Data = []byte(`{"specversion":"1.0","id":"change-9","source":"/capabilities-registry","type":"registry.changed.deprecated","data":{"kind":"deprecated","app":"more0","capability":"doc-ingest","sequence":9}}`)
+	_ = nc.PublishMsg(msg)
+	block := nextBlock(t, blocks)
+	if !strings.HasPrefix(block, "id: 9\ndata: ") || !strings.Contains(block, `"kind":"deprecated"`) || strings.Contains(block, "specversion") {
+		t.Errorf("%s - expected the event without its envelope, got\n%s", sseTestPrefix, block)
+	}
+}
+
+func TestHandleEvents_ResumesFromLastEventID(t *testing.T) {
+	reg := &mockRegistry{changes: []*registry.ChangesSinceOutput{
+		{Changes: []events.RegistryChangedEvent{{App: "more0", Capability: "doc-ingest", Sequence: 5}}, Next: 5, Latest: 6, HasMore: true},
+		{Changes: []events.RegistryChangedEvent{{App: "more0", Capability: "doc-ingest", Sequence: 6}}, Next: 6, Latest: 6},
+	}}
+	ts, nc := sseTestServer(t, reg, 10, 0)
+	_, blocks := openStream(t, ts.URL, "4")
+
+	for _, want := range []string{"id: 5\n", "id: 6\n"} {
+		if block := nextBlock(t, blocks); !strings.HasPrefix(block, want) {
+			t.Errorf("%s - backfill: got\n%s\nwant prefix %q", sseTestPrefix, block, want)
+		}
+	}
+	// A live event already sent by the backfill is skipped
+	_ = nc.Publish("registry.changed.more0.doc-ingest", []byte(`{"app":"more0","capability":"doc-ingest","sequence":6}`))
+	_ = nc.Publish("registry.changed.more0.doc-ingest", []byte(`{"app":"more0","capability":"doc-ingest","sequence":7}`))
+	if block := nextBlock(t, blocks); !strings.HasPrefix(block, "id: 7\n") {
+		t.Errorf("%s - live: got\n%s\nwant sequence 7", sseTestPrefix, block)
+	}
+}
+
+func TestHandleEvents_ResyncWhenChangesArePruned(t *testing.T) {
+	reg := &mockRegistry{changes: []*registry.ChangesSinceOutput{{Resync: true, Next: 40, Latest: 40}}}
+	ts, _ := sseTestServer(t, reg, 10, 0)
+	_, blocks := openStream(t, ts.URL, "3")
+
+	block := nextBlock(t, blocks)
+	if block != "id: 40\nevent: resync\ndata: {\"latest\":40}" {
+		t.Errorf("%s - expected a resync event, got\n%s", sseTestPrefix, block)
+	}
+}
+
+func TestHandleEvents_Heartbeat(t *testing.T) {
+	ts, _ := sseTestServer(t, &mockRegistry{}, 10, 20*time.Millisecond)
+	_, blocks := openStream(t, ts.URL, "")
+	<-blocks // ": connected"
+
+	select {
+	case block := <-blocks:
+		if block != ": heartbeat" {
+			t.Errorf("%s - expected a heartbeat, got %q", sseTestPrefix, block)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s - timeout waiting for a heartbeat", sseTestPrefix)
+	}
+}
+
+func TestHandleEvents_LimitsConcurrentStreams(t *testing.T) {
+	ts, _ := sseTestServer(t, &mockRegistry{}, 1, 0)
+	_, blocks := openStream(t, ts.URL, "")
+	<-blocks // the first stream is open
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("%s - request failed: %v", sseTestPrefix, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("%s - second stream: status %d, want 503 with Retry-After", sseTestPrefix, resp.StatusCode)
+	}
+}
+
+func TestHandleEvents_DisabledWithoutStreams(t *testing.T) {
+	ts, _ := sseTestServer(t, &mockRegistry{}, 0, 0)
+	resp, _ := openStream(t, ts.URL, "")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("%s - status %d, want 404", sseTestPrefix, resp.StatusCode)
+	}
+}
+
+func TestHandleEvents_InvalidLastEventID(t *testing.T) {
+	ts, _ := sseTestServer(t, &mockRegistry{}, 10, 0)
+	resp, _ := openStream(t, ts.URL, "abc")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("%s - status %d, want 400", sseTestPrefix, resp.StatusCode)
+	}
+}
@@ -0,0 +1,42 @@
+-- Migration: 0009_create_releases
+-- Description: Named, immutable release bundles pinning capabilities to exact versions
+
+CREATE TABLE IF NOT EXISTS releases (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+
+    -- Identity (e.g. release-2026.10)
+    name TEXT NOT NULL,
+    description TEXT,
+
+    -- Standard fields
+    object TEXT NOT NULL DEFAULT 'release',
+    created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_by UUID NOT NULL,
+    config JSONB DEFAULT '{}',
+    ext JSONB DEFAULT '{}',
+
+    -- Constraints
+    CONSTRAINT uq_releases_name UNIQUE (name)
+);
+
+CREATE TABLE IF NOT EXISTS release_pins (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+
+    -- References (releases are immutable: a pinned capability or version cannot be deleted)
+    release_id UUID NOT NULL REFERENCES releases(id) ON DELETE CASCADE,
+    capability_id UUID NOT NULL REFERENCES capabilities(id) ON DELETE RESTRICT,
+    version_id UUID NOT NULL REFERENCES capability_versions(id) ON DELETE RESTRICT,
+
+    -- Standard fields
+    object TEXT NOT NULL DEFAULT 'release_pin',
+    created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    -- Constraints: one pinned version per capability per release
+    CONSTRAINT uq_release_pin_capability UNIQUE (release_id, capability_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_release_pins_release_id ON release_pins(release_id);
+CREATE INDEX IF NOT EXISTS idx_release_pins_capability_id ON release_pins(capability_id);
+
+COMMENT ON TABLE releases IS 'Immutable release bundles: a tested-together set of capability versions';
+COMMENT ON TABLE release_pins IS 'Exact version pinned for a capability within a release';
@@ -0,0 +1,32 @@
+-- Migration: 0010_version_env_availability
+-- Description: Per-version environment availability and promotion history
+
+-- NULL means the version is available in every environment (versions created before this migration)
+ALTER TABLE capability_versions ADD COLUMN IF NOT EXISTS envs TEXT[];
+
+CREATE INDEX IF NOT EXISTS idx_capability_versions_envs ON capability_versions USING GIN(envs);
+
+COMMENT ON COLUMN capability_versions.envs IS 'Environments the version is available in; NULL means all environments';
+
+CREATE TABLE IF NOT EXISTS capability_promotions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+
+    -- References
+    capability_id UUID NOT NULL REFERENCES capabilities(id) ON DELETE CASCADE,
+    version_id UUID NOT NULL REFERENCES capability_versions(id) ON DELETE CASCADE,
+
+    -- Promotion details
+    from_env TEXT,
+    to_env TEXT NOT NULL,
+    set_default BOOLEAN NOT NULL DEFAULT FALSE,
+
+    -- Standard fields
+    object TEXT NOT NULL DEFAULT 'capability_promotion',
+    created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_by UUID NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_capability_promotions_capability_id ON capability_promotions(capability_id);
+CREATE INDEX IF NOT EXISTS idx_capability_promotions_version_id ON capability_promotions(version_id);
+
+COMMENT ON TABLE capability_promotions IS 'Audit log of versions promoted between environments';
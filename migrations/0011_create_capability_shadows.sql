@@ -0,0 +1,34 @@
+-- Migration: 0011_create_capability_shadows
+-- Description: Per-capability shadow-traffic configuration returned from resolve
+
+CREATE TABLE IF NOT EXISTS capability_shadows (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+
+    -- Reference to capability (one shadow config per capability)
+    capability_id UUID NOT NULL REFERENCES capabilities(id) ON DELETE CASCADE,
+
+    -- Shadow target: an exact version or a major (latest in that major)
+    target_version TEXT,
+    target_major INTEGER,
+
+    -- Share of requests to mirror, 0.0-1.0
+    sample_rate DOUBLE PRECISION NOT NULL,
+
+    -- Optional tenant filter; NULL mirrors traffic from all tenants
+    tenant_ids TEXT[],
+
+    -- Standard fields
+    object TEXT NOT NULL DEFAULT 'capability_shadow',
+    created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_by UUID NOT NULL,
+    modified TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    modified_by UUID NOT NULL,
+
+    -- Constraints
+    CONSTRAINT uq_capability_shadow UNIQUE (capability_id),
+    CONSTRAINT chk_capability_shadow_target CHECK (target_version IS NOT NULL OR target_major IS NOT NULL),
+    CONSTRAINT chk_capability_shadow_sample_rate CHECK (sample_rate >= 0 AND sample_rate <= 1)
+);
+
+COMMENT ON TABLE capability_shadows IS 'Shadow-traffic config: mirror a share of requests to a target version';
+COMMENT ON COLUMN capability_shadows.tenant_ids IS 'Tenants whose traffic is mirrored; NULL means all tenants';
@@ -0,0 +1,28 @@
+-- Migration: 0012_create_capability_aliases
+-- Description: Old capability refs (renamed or moved to another app) mapped to their current app.name
+
+CREATE TABLE IF NOT EXISTS capability_aliases (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+
+    -- Old reference (e.g. "oldapp.doc.ingest", or a short name like "registry")
+    alias TEXT NOT NULL,
+
+    -- Current capability the alias points to
+    target_app TEXT NOT NULL,
+    target_name TEXT NOT NULL,
+
+    -- Where the alias came from: 'api' (addCapabilityAlias) or 'bootstrap' (config file)
+    source TEXT NOT NULL DEFAULT 'api',
+
+    -- Standard fields
+    object TEXT NOT NULL DEFAULT 'capability_alias',
+    created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_by UUID NOT NULL,
+
+    -- Constraints
+    CONSTRAINT uq_capability_alias UNIQUE (alias)
+);
+
+CREATE INDEX IF NOT EXISTS idx_capability_aliases_target ON capability_aliases(target_app, target_name);
+
+COMMENT ON TABLE capability_aliases IS 'Aliases and renames: old capability refs that keep resolving to the current capability';
@@ -0,0 +1,7 @@
+-- Migration: 0013_capability_subject_templates
+-- Description: Per-capability COMMS subject template (NULL = registry default
+--              {prefix}.{app}.{name}.v{major})
+
+ALTER TABLE capabilities ADD COLUMN IF NOT EXISTS subject_template TEXT;
+
+COMMENT ON COLUMN capabilities.subject_template IS 'Subject template with {prefix}, {app}, {name}, {major}, {minor}, {env}, {tenantShard} tokens; NULL uses the default';
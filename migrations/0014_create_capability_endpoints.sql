@@ -0,0 +1,39 @@
+-- Migration: 0014_create_capability_endpoints
+-- Description: Provider endpoints per capability version (NATS cluster, subject override,
+--              region/zone labels, priority and weight) returned from resolve and bootstrap
+
+CREATE TABLE IF NOT EXISTS capability_endpoints (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+
+    -- Reference to version
+    version_id UUID NOT NULL REFERENCES capability_versions(id) ON DELETE CASCADE,
+
+    -- Where the provider is reachable
+    nats_url TEXT NOT NULL,
+    subject TEXT,
+
+    -- Placement labels
+    region TEXT,
+    zone TEXT,
+
+    -- Selection: lower priority is preferred; weight splits traffic within a priority
+    priority INTEGER NOT NULL DEFAULT 0,
+    weight INTEGER NOT NULL DEFAULT 100,
+
+    -- Standard fields
+    object TEXT NOT NULL DEFAULT 'capability_endpoint',
+    created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_by UUID NOT NULL,
+    modified TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    modified_by UUID NOT NULL,
+
+    -- Constraints
+    CONSTRAINT chk_capability_endpoint_priority CHECK (priority >= 0),
+    CONSTRAINT chk_capability_endpoint_weight CHECK (weight >= 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_capability_endpoints_version ON capability_endpoints(version_id);
+
+COMMENT ON TABLE capability_endpoints IS 'Provider endpoints per capability version; none means the registry NATS URL';
+COMMENT ON COLUMN capability_endpoints.subject IS 'Subject template override for this endpoint; NULL uses the capability subject';
+COMMENT ON COLUMN capability_endpoints.priority IS 'Lower is preferred after region/zone affinity';
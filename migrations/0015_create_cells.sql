@@ -0,0 +1,51 @@
+-- Migration: 0015_create_cells
+-- Description: NATS cells and tenant-to-cell routing; resolve, bootstrap and /connection
+--              return the tenant's cell URL instead of the single client URL
+
+CREATE TABLE IF NOT EXISTS cells (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+
+    -- Identity
+    name TEXT NOT NULL,
+
+    -- Where the cell's tenants connect, and the subject prefix used inside the cell
+    nats_url TEXT NOT NULL,
+    subject_prefix TEXT,
+
+    -- Metadata
+    description TEXT,
+
+    -- Standard fields
+    object TEXT NOT NULL DEFAULT 'cell',
+    created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_by UUID NOT NULL,
+    modified TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    modified_by UUID NOT NULL,
+
+    -- Constraints
+    CONSTRAINT uq_cells_name UNIQUE (name)
+);
+
+CREATE TABLE IF NOT EXISTS tenant_cells (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+
+    -- Mapping (one cell per tenant; a cell with tenants cannot be removed)
+    tenant_id TEXT NOT NULL,
+    cell_id UUID NOT NULL REFERENCES cells(id) ON DELETE RESTRICT,
+
+    -- Standard fields
+    object TEXT NOT NULL DEFAULT 'tenant_cell',
+    created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_by UUID NOT NULL,
+    modified TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    modified_by UUID NOT NULL,
+
+    -- Constraints
+    CONSTRAINT uq_tenant_cells_tenant UNIQUE (tenant_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_tenant_cells_cell ON tenant_cells(cell_id);
+
+COMMENT ON TABLE cells IS 'NATS cells tenants can be routed to';
+COMMENT ON COLUMN cells.subject_prefix IS 'Replaces the registry subject prefix for tenants in this cell; NULL keeps it';
+COMMENT ON TABLE tenant_cells IS 'Tenant-to-cell routing; unmapped tenants use the registry client URL';
@@ -0,0 +1,46 @@
+-- Migration: 0016_create_capability_instances
+-- Description: Live provider instances per capability version, held by leases that providers
+--              refresh with heartbeats; plus a per-capability policy for versions without instances
+
+CREATE TABLE IF NOT EXISTS capability_instances (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+
+    -- Reference to version
+    version_id UUID NOT NULL REFERENCES capability_versions(id) ON DELETE CASCADE,
+
+    -- Provider-chosen (or registry-generated) instance identity
+    instance_id TEXT NOT NULL,
+
+    -- Where the instance runs
+    nats_url TEXT,
+    region TEXT,
+    zone TEXT,
+
+    -- Lease: the instance is live until lease_expires_at; heartbeats push it forward
+    lease_expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_heartbeat TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    -- Standard fields
+    object TEXT NOT NULL DEFAULT 'capability_instance',
+    created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_by UUID NOT NULL,
+    modified TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    modified_by UUID NOT NULL,
+
+    -- Constraints
+    CONSTRAINT uq_capability_instances_instance UNIQUE (instance_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_capability_instances_version ON capability_instances(version_id, lease_expires_at);
+CREATE INDEX IF NOT EXISTS idx_capability_instances_lease ON capability_instances(lease_expires_at);
+
+COMMENT ON TABLE capability_instances IS 'Provider instances serving a capability version; expired leases are reaped';
+COMMENT ON COLUMN capability_instances.lease_expires_at IS 'Instance counts as live until this time';
+
+ALTER TABLE capabilities ADD COLUMN IF NOT EXISTS liveness_policy TEXT;
+
+ALTER TABLE capabilities DROP CONSTRAINT IF EXISTS chk_capabilities_liveness_policy;
+ALTER TABLE capabilities ADD CONSTRAINT chk_capabilities_liveness_policy
+    CHECK (liveness_policy IS NULL OR liveness_policy IN ('none', 'warn', 'skip'));
+
+COMMENT ON COLUMN capabilities.liveness_policy IS 'How resolve treats versions with no live instances: none, warn or skip; NULL = none';
@@ -0,0 +1,32 @@
+-- Migration: 0017_create_capability_probes
+-- Description: Latest responder probe per capability version (reachability, latency, last seen)
+
+CREATE TABLE IF NOT EXISTS capability_probes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+
+    -- Reference to version (one row per version, replaced on every probe)
+    version_id UUID NOT NULL REFERENCES capability_versions(id) ON DELETE CASCADE,
+
+    -- Probe result
+    subject TEXT NOT NULL,
+    status TEXT NOT NULL,
+    latency_ms INTEGER,
+    error TEXT,
+    last_probed TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_seen TIMESTAMP WITH TIME ZONE,
+
+    -- Standard fields
+    object TEXT NOT NULL DEFAULT 'capability_probe',
+    created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    modified TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    -- Constraints
+    CONSTRAINT uq_capability_probes_version UNIQUE (version_id),
+    CONSTRAINT chk_capability_probe_status CHECK (status IN ('reachable', 'unreachable', 'timeout', 'error'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_capability_probes_status ON capability_probes(status);
+
+COMMENT ON TABLE capability_probes IS 'Latest responder probe of each capability version subject';
+COMMENT ON COLUMN capability_probes.status IS 'reachable (a responder replied), unreachable (no responders), timeout or error';
+COMMENT ON COLUMN capability_probes.last_seen IS 'Last time a probe got a reply; kept across failed probes';
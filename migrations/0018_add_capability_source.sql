@@ -0,0 +1,9 @@
+-- Migration: 0018_add_capability_source
+-- Description: Record where a capability came from (registered through upsert or imported from NATS micro services)
+
+ALTER TABLE capabilities ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT 'registry';
+
+ALTER TABLE capabilities DROP CONSTRAINT IF EXISTS chk_capability_source;
+ALTER TABLE capabilities ADD CONSTRAINT chk_capability_source CHECK (source IN ('registry', 'micro'));
+
+COMMENT ON COLUMN capabilities.source IS 'registry (upsert or seed) or micro (imported from $SRV.INFO); a direct upsert turns an imported capability into a registry one';
@@ -0,0 +1,7 @@
+-- Migration: 0019_add_mirror_source
+-- Description: Allow capabilities copied from an upstream registry by a mirror
+
+ALTER TABLE capabilities DROP CONSTRAINT IF EXISTS chk_capability_source;
+ALTER TABLE capabilities ADD CONSTRAINT chk_capability_source CHECK (source IN ('registry', 'micro', 'mirror'));
+
+COMMENT ON COLUMN capabilities.source IS 'registry (upsert or seed), micro (imported from $SRV.INFO) or mirror (synced from an upstream registry); a direct upsert turns an imported capability into a registry one';
@@ -0,0 +1,24 @@
+-- Migration: 0020_create_registry_changes
+-- Description: Change feed of every registry mutation under a global sequence, for changesSince
+
+CREATE TABLE IF NOT EXISTS registry_changes (
+    -- Global sequence; assigned in commit order, so a reader never sees a later sequence
+    -- before an earlier one
+    seq BIGINT PRIMARY KEY,
+
+    -- Changed capability (system.registry for registry-level changes such as tenant cells)
+    app TEXT NOT NULL,
+    capability TEXT NOT NULL,
+
+    -- The published RegistryChangedEvent
+    event JSONB NOT NULL,
+
+    -- Standard fields
+    object TEXT NOT NULL DEFAULT 'registry_change',
+    created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_registry_changes_created ON registry_changes(created);
+
+COMMENT ON TABLE registry_changes IS 'Change feed of registry mutations, read with changesSince; pruned after REGISTRY_CHANGE_RETENTION';
+COMMENT ON COLUMN registry_changes.seq IS 'Global, gapless registry sequence; the latest change is never pruned';
@@ -0,0 +1,72 @@
+-- Migration: 0021_create_webhooks
+-- Description: Outgoing HTTP webhooks for registry change events, and their delivery log
+
+CREATE TABLE IF NOT EXISTS webhooks (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+
+    -- Identity
+    name TEXT NOT NULL,
+
+    -- Where change events are POSTed, and the HMAC-SHA256 signing key
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+
+    -- Filter (NULL or empty matches everything)
+    app TEXT,
+    capability TEXT,
+    changed_fields TEXT[] NOT NULL DEFAULT '{}',
+
+    -- Health: disabled after too many failed deliveries in a row
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    consecutive_failures INT NOT NULL DEFAULT 0,
+    disabled_reason TEXT,
+
+    -- Metadata
+    description TEXT,
+
+    -- Standard fields
+    object TEXT NOT NULL DEFAULT 'webhook',
+    created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_by UUID NOT NULL,
+    modified TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    modified_by UUID NOT NULL,
+
+    -- Constraints
+    CONSTRAINT uq_webhooks_name UNIQUE (name)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    webhook_id UUID NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+
+    -- The change event (registry sequence, 0 when unrecorded) and the exact body sent
+    seq BIGINT NOT NULL DEFAULT 0,
+    app TEXT NOT NULL,
+    capability TEXT NOT NULL,
+    payload JSONB NOT NULL,
+
+    -- pending until delivered (succeeded), out of attempts (failed) or its webhook was
+    -- disabled (cancelled)
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    -- Last attempt
+    status_code INT,
+    error TEXT,
+    last_attempt TIMESTAMP WITH TIME ZONE,
+
+    -- Standard fields
+    object TEXT NOT NULL DEFAULT 'webhook_delivery',
+    created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    -- Constraints
+    CONSTRAINT chk_webhook_deliveries_status CHECK (status IN ('pending', 'succeeded', 'failed', 'cancelled'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(next_attempt) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, created DESC);
+
+COMMENT ON TABLE webhooks IS 'HTTP endpoints that receive registry change events, signed with HMAC-SHA256';
+COMMENT ON COLUMN webhooks.consecutive_failures IS 'Failed deliveries in a row; reset by a successful delivery or setWebhook';
+COMMENT ON TABLE webhook_deliveries IS 'One change event to one webhook, retried with exponential backoff; pruned after REGISTRY_CHANGE_RETENTION';
@@ -0,0 +1,7 @@
+-- Migration: 0022_add_probe_unknown_status
+-- Description: Allow an unknown probe status for versions served only on other NATS deployments
+
+ALTER TABLE capability_probes DROP CONSTRAINT IF EXISTS chk_capability_probe_status;
+ALTER TABLE capability_probes ADD CONSTRAINT chk_capability_probe_status CHECK (status IN ('reachable', 'unreachable', 'timeout', 'error', 'unknown'));
+
+COMMENT ON COLUMN capability_probes.status IS 'reachable (a responder replied), unreachable (no responders), timeout, error, or unknown (served through endpoints or cells on other NATS deployments the registry cannot probe)';
@@ -0,0 +1,12 @@
+-- Migration: 0023_add_capability_alias_kind
+-- Description: Tell bootstrap shorthand aliases (resolved silently) apart from renames (resolved with a warning)
+
+ALTER TABLE capability_aliases ADD COLUMN IF NOT EXISTS kind TEXT NOT NULL DEFAULT 'rename';
+
+ALTER TABLE capability_aliases DROP CONSTRAINT IF EXISTS chk_capability_alias_kind;
+ALTER TABLE capability_aliases ADD CONSTRAINT chk_capability_alias_kind CHECK (kind IN ('rename', 'shorthand'));
+
+-- Aliases seeded from the bootstrap config are shorthands
+UPDATE capability_aliases SET kind = 'shorthand' WHERE source = 'bootstrap' AND kind <> 'shorthand';
+
+COMMENT ON COLUMN capability_aliases.kind IS 'rename (an old ref of a renamed or moved capability; resolving it warns) or shorthand (a short name from the bootstrap config, e.g. registry)';
@@ -173,6 +173,7 @@ func MergeBootstrapConfigs(base, override *BootstrapConfig) *BootstrapConfig {
 	}
 	if override.ChangeEvents.Pattern != "" {
 		merged.ChangeEvents.Pattern = override.ChangeEvents.Pattern
+		merged.ChangeEvents.AllScope = override.ChangeEvents.AllScope
 	}
 
 	return &merged
@@ -84,6 +84,19 @@ func TestCreateResolvedBootstrap(t *testing.T) {
 	}
 }
 
+func TestChangeSubscriptionSubjects(t *testing.T) {
+	cfg := GetDefaultBootstrapConfig()
+	if got := CreateResolvedBootstrap(cfg).ChangeSubscriptionSubjects("production", "acme"); len(got) != 1 || got[0] != "registry.changed.*.>" {
+		t.Errorf("expected the unscoped wildcard, got %v", got)
+	}
+
+	cfg.ChangeEvents = ChangeEventSubjects{Global: "registry.changed", Pattern: "registry.changed.{env}.{app}.{capability}", AllScope: "_all"}
+	got := CreateResolvedBootstrap(cfg).ChangeSubscriptionSubjects("production", "acme")
+	if len(got) != 2 || got[0] != "registry.changed._all.*.>" || got[1] != "registry.changed.production.*.>" {
+		t.Errorf("expected the production and all-env subjects, got %v", got)
+	}
+}
+
 func TestResolveAlias(t *testing.T) {
 	cfg := GetDefaultBootstrapConfig()
 	resolved := CreateResolvedBootstrap(cfg)
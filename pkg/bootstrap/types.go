@@ -1,6 +1,8 @@
 // Package bootstrap provides bootstrap configuration loading for system capabilities.
 package bootstrap
 
+import "github.com/morezero/capabilities-registry/pkg/commsutil"
+
 // BootstrapMethodMetadata holds optional per-method metadata (description, schemas, modes, tags, examples).
 // When present in bootstrap, this metadata is persisted so describe returns full method details.
 type BootstrapMethodMetadata struct {
@@ -45,10 +47,15 @@ type BootstrapConfig struct {
 	ChangeEvents         ChangeEventSubjects              `json:"changeEventSubjects"`
 }
 
-// ChangeEventSubjects defines event subject patterns.
+// ChangeEventSubjects defines event subject patterns. Pattern is the per-capability subject
+// (commsutil change subject tokens); {env} and {tenant} in it scope changes so clients can
+// subscribe to their own env and tenant.
 type ChangeEventSubjects struct {
 	Global  string `json:"global"`
 	Pattern string `json:"pattern"`
+	// AllScope replaces {env} or {tenant} for changes that apply to every env or tenant;
+	// set when Pattern is scoped.
+	AllScope string `json:"allScope,omitempty"`
 }
 
 // ResolvedBootstrap provides fast lookup of bootstrap capabilities.
@@ -109,6 +116,12 @@ func (rb *ResolvedBootstrap) GlobalChangeSubject() string {
 	return rb.changeEvents.Global
 }
 
+// ChangeSubscriptionSubjects returns the change subjects a client in env and tenant
+// subscribes to for the changes that concern it (see commsutil.ChangeSubscriptionSubjects).
+func (rb *ResolvedBootstrap) ChangeSubscriptionSubjects(env, tenant string) []string {
+	return commsutil.ChangeSubscriptionSubjects(rb.changeEvents.Pattern, env, tenant)
+}
+
 // Name returns the bootstrap config name (for versioning/cache invalidation).
 func (rb *ResolvedBootstrap) Name() string {
 	return rb.name
@@ -0,0 +1,32 @@
+package commsutil
+
+import "strings"
+
+// MicroMetadataCapability is the NATS micro service metadata key naming the capability
+// ("app.name") a service provides. It takes precedence over the service name.
+const MicroMetadataCapability = "capability"
+
+// MicroMetadataSubjectTemplate is the NATS micro service metadata key holding the
+// capability's subject template.
+const MicroMetadataSubjectTemplate = "subject_template"
+
+// MicroServiceName returns the NATS micro service name for a capability: micro names only
+// allow letters, digits, hyphens and underscores, so "system.registry" becomes "system_registry".
+func MicroServiceName(app, name string) string {
+	return app + "_" + strings.ReplaceAll(name, ".", "_")
+}
+
+// CapabilityFromMicroService maps a NATS micro service to a capability app and name. The
+// capability metadata entry wins; otherwise the service name is split at its first
+// underscore into app and name, and the remaining underscores in the name become dots.
+func CapabilityFromMicroService(serviceName string, metadata map[string]string) (app, name string, ok bool) {
+	if ref := metadata[MicroMetadataCapability]; ref != "" {
+		app, name, ok = strings.Cut(ref, ".")
+		return app, name, ok && app != "" && name != ""
+	}
+	app, name, ok = strings.Cut(serviceName, "_")
+	if !ok || app == "" || name == "" {
+		return "", "", false
+	}
+	return app, strings.ReplaceAll(name, "_", "."), true
+}
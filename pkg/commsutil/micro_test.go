@@ -0,0 +1,38 @@
+package commsutil
+
+import "testing"
+
+func TestMicroServiceName(t *testing.T) {
+	if got := MicroServiceName("system", "registry"); got != "system_registry" {
+		t.Errorf("MicroServiceName = %q, want system_registry", got)
+	}
+	if got := MicroServiceName("more0", "doc.ingest"); got != "more0_doc_ingest" {
+		t.Errorf("MicroServiceName = %q, want more0_doc_ingest", got)
+	}
+}
+
+func TestCapabilityFromMicroService(t *testing.T) {
+	tests := []struct {
+		name     string
+		service  string
+		metadata map[string]string
+		wantApp  string
+		wantName string
+		wantOk   bool
+	}{
+		{"from name", "more0_doc_ingest", nil, "more0", "doc.ingest", true},
+		{"round trip", MicroServiceName("system", "registry"), nil, "system", "registry", true},
+		{"metadata wins", "ingester", map[string]string{MicroMetadataCapability: "more0.doc_ingest"}, "more0", "doc_ingest", true},
+		{"no separator", "ingester", nil, "", "", false},
+		{"empty name", "more0_", nil, "", "", false},
+		{"bad metadata", "more0_doc", map[string]string{MicroMetadataCapability: "more0"}, "more0", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, name, ok := CapabilityFromMicroService(tt.service, tt.metadata)
+			if ok != tt.wantOk || (ok && (app != tt.wantApp || name != tt.wantName)) {
+				t.Errorf("CapabilityFromMicroService(%q) = %q, %q, %v; want %q, %q, %v", tt.service, app, name, ok, tt.wantApp, tt.wantName, tt.wantOk)
+			}
+		})
+	}
+}
@@ -2,6 +2,9 @@ package commsutil
 
 import (
 	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -12,13 +15,236 @@ const (
 	SubjectChangeEvent = "registry.changed"
 )
 
-// BuildChangeSubject builds a granular change event subject.
+// Subject template tokens. A capability's subject template is a dot-separated
+// subject in which these tokens are substituted at resolve time.
+const (
+	TokenPrefix      = "{prefix}"
+	TokenApp         = "{app}"
+	TokenName        = "{name}"
+	TokenMajor       = "{major}"
+	TokenMinor       = "{minor}"
+	TokenEnv         = "{env}"
+	TokenTenantShard = "{tenantShard}"
+)
+
+// DefaultSubjectPrefix is the subject prefix used when none is configured.
+const DefaultSubjectPrefix = "cap"
+
+// DefaultSubjectTemplate produces prefix.app.name_with_underscores.vN.
+const DefaultSubjectTemplate = TokenPrefix + "." + TokenApp + "." + TokenName + ".v" + TokenMajor
+
+// MaxSubjectTemplateLength bounds stored templates.
+const MaxSubjectTemplateLength = 256
+
+var subjectTokens = []string{TokenPrefix, TokenApp, TokenName, TokenMajor, TokenMinor, TokenEnv, TokenTenantShard}
+
+// SubjectParams holds the values substituted into a subject template.
+type SubjectParams struct {
+	Prefix string
+	App    string
+	Name   string
+	Major  int
+	Minor  int
+	Env    string
+	// TenantID is hashed into one of TenantShards buckets for {tenantShard}.
+	// An empty tenant maps to shard 0.
+	TenantID     string
+	TenantShards int
+}
+
+// Change subject pattern tokens. A change subject pattern is SubjectChangeEvent followed by
+// dot-separated tokens; {env} (TokenEnv) and {tenant} scope the subject so clients can
+// subscribe to the changes of their env or tenant only.
+const (
+	ChangeTokenApp        = "{app}"
+	ChangeTokenCapability = "{capability}"
+	ChangeTokenTenant     = "{tenant}"
+)
+
+// DefaultChangeSubjectPattern is the unscoped granular change subject registry.changed.<app>.<capability>.
+const DefaultChangeSubjectPattern = SubjectChangeEvent + "." + ChangeTokenApp + "." + ChangeTokenCapability
+
+// ChangeScopeAll replaces {env} or {tenant} in the subject of a change that applies to every
+// env or tenant.
+const ChangeScopeAll = "_all"
+
+// BuildChangeSubject builds the granular change event subject under
+// DefaultChangeSubjectPattern.
+//
+// Deprecated: registries may publish under a configured pattern; use
+// BuildScopedChangeSubject with that pattern instead.
 func BuildChangeSubject(app, capability string) string {
-	return fmt.Sprintf("registry.changed.%s.%s", app, capability)
+	return BuildScopedChangeSubject(DefaultChangeSubjectPattern, app, capability, "", "")
+}
+
+// BuildScopedChangeSubject renders a change subject pattern. An empty pattern uses
+// DefaultChangeSubjectPattern; an empty env or tenant renders as ChangeScopeAll. Characters
+// that are not valid in a subject token are replaced in env and tenant with '_'.
+func BuildScopedChangeSubject(pattern, app, capability, env, tenant string) string {
+	if pattern == "" {
+		pattern = DefaultChangeSubjectPattern
+	}
+	r := strings.NewReplacer(
+		ChangeTokenApp, app,
+		ChangeTokenCapability, capability,
+		TokenEnv, changeScopeToken(env),
+		ChangeTokenTenant, changeScopeToken(tenant),
+	)
+	return r.Replace(pattern)
+}
+
+// changeScopeToken returns value as a single subject token, or ChangeScopeAll when empty.
+func changeScopeToken(value string) string {
+	if value == "" {
+		return ChangeScopeAll
+	}
+	return subjectToken(value)
+}
+
+// subjectToken replaces every character of value that is not valid in a subject token
+// (including '.', '*', '>' and whitespace) with '_', so value stays one literal token.
+func subjectToken(value string) string {
+	return strings.Map(func(c rune) rune {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' {
+			return c
+		}
+		return '_'
+	}, value)
+}
+
+// ChangeSubscriptionSubjects returns the wildcard subjects that receive every change of
+// concern to a client in env and tenant under pattern: for each of {env} and {tenant} in the
+// pattern, the client's own value and ChangeScopeAll. An empty env or tenant matches every
+// value of that scope.
+func ChangeSubscriptionSubjects(pattern, env, tenant string) []string {
+	if pattern == "" {
+		pattern = DefaultChangeSubjectPattern
+	}
+	pattern = strings.NewReplacer(ChangeTokenApp, "*", ChangeTokenCapability, ">").Replace(pattern)
+	subjects := []string{pattern}
+	for token, value := range map[string]string{TokenEnv: env, ChangeTokenTenant: tenant} {
+		if !strings.Contains(pattern, token) {
+			continue
+		}
+		var scoped []string
+		for _, subject := range subjects {
+			if value == "" {
+				scoped = append(scoped, strings.Replace(subject, token, "*", 1))
+				continue
+			}
+			scoped = append(scoped,
+				strings.Replace(subject, token, changeScopeToken(value), 1),
+				strings.Replace(subject, token, ChangeScopeAll, 1))
+		}
+		subjects = scoped
+	}
+	sort.Strings(subjects)
+	return subjects
 }
 
-// BuildCapabilitySubject builds a COMMS subject for a capability.
+// ValidateChangeSubjectPattern checks that a change subject pattern is under
+// SubjectChangeEvent, has {app} once and ends with {capability} (capability names may
+// contain dots), uses {env} and {tenant} at most once, and has only valid literal tokens.
+func ValidateChangeSubjectPattern(pattern string) error {
+	rest, ok := strings.CutPrefix(pattern, SubjectChangeEvent+".")
+	if !ok {
+		return fmt.Errorf("change subject pattern %q must start with %s.", pattern, SubjectChangeEvent)
+	}
+	parts := strings.Split(rest, ".")
+	if parts[len(parts)-1] != ChangeTokenCapability {
+		return fmt.Errorf("change subject pattern %q must end with %s", pattern, ChangeTokenCapability)
+	}
+	seen := make(map[string]bool)
+	for _, part := range parts {
+		switch part {
+		case ChangeTokenApp, ChangeTokenCapability, TokenEnv, ChangeTokenTenant:
+			if seen[part] {
+				return fmt.Errorf("change subject pattern %q repeats %s", pattern, part)
+			}
+			seen[part] = true
+			continue
+		case "":
+			return fmt.Errorf("change subject pattern %q has an empty subject token", pattern)
+		}
+		if strings.ContainsAny(part, "{}") {
+			return fmt.Errorf("change subject pattern %q has an unknown or malformed token", pattern)
+		}
+		if changeScopeToken(part) != part {
+			return fmt.Errorf("change subject pattern %q contains an invalid character in %q", pattern, part)
+		}
+	}
+	if !seen[ChangeTokenApp] {
+		return fmt.Errorf("change subject pattern %q must contain %s", pattern, ChangeTokenApp)
+	}
+	return nil
+}
+
+// BuildCapabilitySubject builds a COMMS subject for a capability using the default template.
 func BuildCapabilitySubject(app, name string, major int) string {
-	safe := strings.ReplaceAll(name, ".", "_")
-	return fmt.Sprintf("cap.%s.%s.v%d", app, safe, major)
+	return BuildSubject(DefaultSubjectTemplate, SubjectParams{Prefix: DefaultSubjectPrefix, App: app, Name: name, Major: major})
+}
+
+// BuildSubject renders a subject template. An empty template uses DefaultSubjectTemplate
+// and an empty prefix uses DefaultSubjectPrefix. Dots in the name become underscores so
+// the name stays a single subject token; the env is caller-supplied, so any character not
+// valid in a token (dots and wildcards included) becomes an underscore.
+func BuildSubject(template string, p SubjectParams) string {
+	if template == "" {
+		template = DefaultSubjectTemplate
+	}
+	prefix := p.Prefix
+	if prefix == "" {
+		prefix = DefaultSubjectPrefix
+	}
+	r := strings.NewReplacer(
+		TokenPrefix, prefix,
+		TokenApp, p.App,
+		TokenName, strings.ReplaceAll(p.Name, ".", "_"),
+		TokenMajor, strconv.Itoa(p.Major),
+		TokenMinor, strconv.Itoa(p.Minor),
+		TokenEnv, subjectToken(p.Env),
+		TokenTenantShard, strconv.Itoa(TenantShard(p.TenantID, p.TenantShards)),
+	)
+	return r.Replace(template)
+}
+
+// TenantShard maps a tenant to a stable shard in [0, shards). Returns 0 for an empty
+// tenant or when sharding is disabled (shards < 2).
+func TenantShard(tenantID string, shards int) int {
+	if tenantID == "" || shards < 2 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(tenantID))
+	return int(h.Sum32() % uint32(shards))
+}
+
+// ValidateSubjectTemplate checks that a template only uses known tokens and renders to
+// a valid NATS subject: non-empty dot-separated tokens of letters, digits, '-' and '_',
+// with no wildcards or whitespace.
+func ValidateSubjectTemplate(template string) error {
+	if template == "" {
+		return fmt.Errorf("subject template must not be empty")
+	}
+	if len(template) > MaxSubjectTemplateLength {
+		return fmt.Errorf("subject template exceeds %d characters", MaxSubjectTemplateLength)
+	}
+	rest := template
+	for _, tok := range subjectTokens {
+		rest = strings.ReplaceAll(rest, tok, "x")
+	}
+	if i := strings.IndexAny(rest, "{}"); i >= 0 {
+		return fmt.Errorf("subject template %q has an unknown or malformed token", template)
+	}
+	for _, part := range strings.Split(rest, ".") {
+		if part == "" {
+			return fmt.Errorf("subject template %q has an empty subject token", template)
+		}
+		for _, c := range part {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return fmt.Errorf("subject template %q contains invalid character %q", template, c)
+			}
+		}
+	}
+	return nil
 }
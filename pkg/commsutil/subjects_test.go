@@ -1,6 +1,9 @@
 package commsutil
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestBuildChangeSubject(t *testing.T) {
 	tests := []struct {
@@ -23,6 +26,95 @@ func TestBuildChangeSubject(t *testing.T) {
 	}
 }
 
+func TestBuildScopedChangeSubject(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		env     string
+		tenant  string
+		want    string
+	}{
+		{"default", "", "production", "acme", "registry.changed.more0.doc.ingest"},
+		{"env", "registry.changed.{env}.{app}.{capability}", "staging", "", "registry.changed.staging.more0.doc.ingest"},
+		{"every env", "registry.changed.{env}.{app}.{capability}", "", "", "registry.changed._all.more0.doc.ingest"},
+		{"env and tenant", "registry.changed.{env}.{tenant}.{app}.{capability}", "production", "acme", "registry.changed.production.acme.more0.doc.ingest"},
+		{"every tenant", "registry.changed.{env}.{tenant}.{app}.{capability}", "production", "", "registry.changed.production._all.more0.doc.ingest"},
+		{"tenant with dots", "registry.changed.{tenant}.{app}.{capability}", "", "acme.eu *", "registry.changed.acme_eu__.more0.doc.ingest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BuildScopedChangeSubject(tt.pattern, "more0", "doc.ingest", tt.env, tt.tenant); got != tt.want {
+				t.Errorf("BuildScopedChangeSubject(%q) = %q, want %q", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChangeSubscriptionSubjects(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		env     string
+		tenant  string
+		want    []string
+	}{
+		{"unscoped", "", "production", "acme", []string{"registry.changed.*.>"}},
+		{"env", "registry.changed.{env}.{app}.{capability}", "production", "acme", []string{
+			"registry.changed._all.*.>",
+			"registry.changed.production.*.>",
+		}},
+		{"any env", "registry.changed.{env}.{app}.{capability}", "", "", []string{"registry.changed.*.*.>"}},
+		{"env and tenant", "registry.changed.{env}.{tenant}.{app}.{capability}", "production", "acme", []string{
+			"registry.changed._all._all.*.>",
+			"registry.changed._all.acme.*.>",
+			"registry.changed.production._all.*.>",
+			"registry.changed.production.acme.*.>",
+		}},
+		{"env, any tenant", "registry.changed.{env}.{tenant}.{app}.{capability}", "staging", "", []string{
+			"registry.changed._all.*.*.>",
+			"registry.changed.staging.*.*.>",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ChangeSubscriptionSubjects(tt.pattern, tt.env, tt.tenant)
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("ChangeSubscriptionSubjects(%q, %q, %q) = %v, want %v", tt.pattern, tt.env, tt.tenant, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateChangeSubjectPattern(t *testing.T) {
+	valid := []string{
+		DefaultChangeSubjectPattern,
+		"registry.changed.{env}.{app}.{capability}",
+		"registry.changed.{env}.{tenant}.{app}.{capability}",
+		"registry.changed.v2.{tenant}.{app}.{capability}",
+	}
+	for _, pattern := range valid {
+		if err := ValidateChangeSubjectPattern(pattern); err != nil {
+			t.Errorf("ValidateChangeSubjectPattern(%q) unexpected error: %v", pattern, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"events.{env}.{app}.{capability}",
+		"registry.changed.{app}.{capability}.{env}",
+		"registry.changed.{env}.{capability}",
+		"registry.changed.{env}.{env}.{app}.{capability}",
+		"registry.changed.{region}.{app}.{capability}",
+		"registry.changed..{app}.{capability}",
+		"registry.changed.*.{app}.{capability}",
+	}
+	for _, pattern := range invalid {
+		if err := ValidateChangeSubjectPattern(pattern); err == nil {
+			t.Errorf("ValidateChangeSubjectPattern(%q) expected error", pattern)
+		}
+	}
+}
+
 func TestBuildCapabilitySubject(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -45,3 +137,74 @@ func TestBuildCapabilitySubject(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildSubject(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		params   SubjectParams
+		want     string
+	}{
+		{"empty template uses default", "", SubjectParams{App: "more0", Name: "doc.ingest", Major: 2}, "cap.more0.doc_ingest.v2"},
+		{"custom prefix", DefaultSubjectTemplate, SubjectParams{Prefix: "svc", App: "more0", Name: "auth", Major: 1}, "svc.more0.auth.v1"},
+		{"env partitioned", "{env}.{app}.{name}.v{major}", SubjectParams{App: "more0", Name: "auth", Major: 1, Env: "staging"}, "staging.more0.auth.v1"},
+		{"minor", "{prefix}.{app}.{name}.v{major}_{minor}", SubjectParams{App: "more0", Name: "auth", Major: 1, Minor: 4}, "cap.more0.auth.v1_4"},
+		{"no tenant is shard 0", "{app}.{name}.s{tenantShard}", SubjectParams{App: "more0", Name: "auth", TenantShards: 8}, "more0.auth.s0"},
+		{"literal", "cap.system.registry.v1", SubjectParams{App: "system", Name: "registry", Major: 3}, "cap.system.registry.v1"},
+		{"env with dots stays one token", "{env}.{app}.{name}.v{major}", SubjectParams{App: "more0", Name: "auth", Major: 1, Env: "a.b"}, "a_b.more0.auth.v1"},
+		{"wildcard env is literal", "{env}.{app}.{name}.v{major}", SubjectParams{App: "more0", Name: "auth", Major: 1, Env: ">"}, "_.more0.auth.v1"},
+		{"star env is literal", "{prefix}.{app}.{name}.{env}", SubjectParams{App: "more0", Name: "auth", Env: "* x"}, "cap.more0.auth.__x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BuildSubject(tt.template, tt.params)
+			if got != tt.want {
+				t.Errorf("BuildSubject(%q) = %q, want %q", tt.template, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTenantShard(t *testing.T) {
+	if got := TenantShard("tenant-a", 0); got != 0 {
+		t.Errorf("TenantShard with sharding disabled = %d, want 0", got)
+	}
+	first := TenantShard("tenant-a", 16)
+	if first < 0 || first >= 16 {
+		t.Fatalf("TenantShard out of range: %d", first)
+	}
+	if again := TenantShard("tenant-a", 16); again != first {
+		t.Errorf("TenantShard not stable: %d then %d", first, again)
+	}
+}
+
+func TestValidateSubjectTemplate(t *testing.T) {
+	valid := []string{
+		DefaultSubjectTemplate,
+		"{env}.{prefix}.{app}.{name}.v{major}",
+		"{prefix}.{app}.{name}.v{major}.shard{tenantShard}",
+		"cap.system.registry.v1",
+	}
+	for _, tmpl := range valid {
+		if err := ValidateSubjectTemplate(tmpl); err != nil {
+			t.Errorf("ValidateSubjectTemplate(%q) unexpected error: %v", tmpl, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"{prefix}.{application}.{name}",
+		"{prefix}.{app}.{name",
+		"{prefix}..{name}",
+		".{app}.{name}",
+		"{prefix}.{app}.*",
+		"{prefix}.{app}.>",
+		"{prefix}.{app} .{name}",
+	}
+	for _, tmpl := range invalid {
+		if err := ValidateSubjectTemplate(tmpl); err == nil {
+			t.Errorf("ValidateSubjectTemplate(%q) expected error", tmpl)
+		}
+	}
+}
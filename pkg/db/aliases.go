@@ -0,0 +1,180 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const aliasesLogPrefix = "db:aliases"
+
+const aliasColumns = `id, alias, target_app, target_name, source, kind, object, created, created_by`
+
+// Capability alias kinds stored in capability_aliases.kind.
+const (
+	// CapabilityAliasRename is an old ref of a renamed or moved capability; resolving it warns.
+	CapabilityAliasRename = "rename"
+	// CapabilityAliasShorthand is a short name from the bootstrap config (e.g. "registry").
+	CapabilityAliasShorthand = "shorthand"
+)
+
+// GetCapabilityAlias returns the alias with the given name. Returns nil, nil when not found.
+func (r *Repository) GetCapabilityAlias(ctx context.Context, alias string) (*CapabilityAlias, error) {
+	var a CapabilityAlias
+	err := r.pool.QueryRow(ctx,
+		`SELECT `+aliasColumns+`
+		 FROM capability_aliases
+		 WHERE alias = $1`, alias,
+	).Scan(&a.ID, &a.Alias, &a.TargetApp, &a.TargetName, &a.Source, &a.Kind, &a.Object, &a.Created, &a.CreatedBy)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s - GetCapabilityAlias failed: %w", aliasesLogPrefix, err)
+	}
+	return &a, nil
+}
+
+// ListCapabilityAliases returns all aliases ordered by alias.
+func (r *Repository) ListCapabilityAliases(ctx context.Context) ([]CapabilityAlias, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT `+aliasColumns+`
+		 FROM capability_aliases
+		 ORDER BY alias ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("%s - ListCapabilityAliases failed: %w", aliasesLogPrefix, err)
+	}
+	defer rows.Close()
+
+	var out []CapabilityAlias
+	for rows.Next() {
+		var a CapabilityAlias
+		if err := rows.Scan(&a.ID, &a.Alias, &a.TargetApp, &a.TargetName, &a.Source, &a.Kind, &a.Object, &a.Created, &a.CreatedBy); err != nil {
+			return nil, fmt.Errorf("%s - ListCapabilityAliases scan failed: %w", aliasesLogPrefix, err)
+		}
+		out = append(out, a)
+	}
+	return out, nil
+}
+
+// AddCapabilityAliasParams holds parameters for AddCapabilityAlias.
+type AddCapabilityAliasParams struct {
+	Alias      string
+	TargetApp  string
+	TargetName string
+	UserID     string
+}
+
+// AddCapabilityAlias creates or repoints a rename alias. An alias added through the API
+// takes ownership from a bootstrap-loaded one.
+func (r *Repository) AddCapabilityAlias(ctx context.Context, params AddCapabilityAliasParams) (*CapabilityAlias, error) {
+	slog.Info(fmt.Sprintf("%s - AddCapabilityAlias %s -> %s.%s", aliasesLogPrefix, params.Alias, params.TargetApp, params.TargetName))
+
+	var a CapabilityAlias
+	err := r.pool.QueryRow(ctx,
+		`INSERT INTO capability_aliases (alias, target_app, target_name, source, kind, created_by, created)
+		 VALUES ($1, $2, $3, 'api', 'rename', $4, $5)
+		 ON CONFLICT (alias) DO UPDATE SET
+		   target_app = $2,
+		   target_name = $3,
+		   source = 'api',
+		   kind = 'rename'
+		 RETURNING `+aliasColumns,
+		params.Alias, params.TargetApp, params.TargetName, params.UserID, time.Now().UTC(),
+	).Scan(&a.ID, &a.Alias, &a.TargetApp, &a.TargetName, &a.Source, &a.Kind, &a.Object, &a.Created, &a.CreatedBy)
+	if err != nil {
+		return nil, fmt.Errorf("%s - AddCapabilityAlias failed: %w", aliasesLogPrefix, err)
+	}
+	return &a, nil
+}
+
+// RemoveCapabilityAlias deletes an alias. Returns false when it did not exist.
+func (r *Repository) RemoveCapabilityAlias(ctx context.Context, alias string) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM capability_aliases WHERE alias = $1`, alias)
+	if err != nil {
+		return false, fmt.Errorf("%s - RemoveCapabilityAlias failed: %w", aliasesLogPrefix, err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ReplaceMirroredAliases makes aliases the aliases a mirror copied for targetApp.targetName,
+// in one transaction. Only aliases with source 'mirror' are repointed or removed; an alias
+// defined on the mirror itself (api or bootstrap) keeps its target.
+func (r *Repository) ReplaceMirroredAliases(ctx context.Context, targetApp, targetName string, aliases []CapabilityAlias, userID string) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s - ReplaceMirroredAliases begin failed: %w", aliasesLogPrefix, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM capability_aliases
+		 WHERE target_app = $1 AND target_name = $2 AND source = 'mirror'`,
+		targetApp, targetName); err != nil {
+		return fmt.Errorf("%s - ReplaceMirroredAliases delete failed: %w", aliasesLogPrefix, err)
+	}
+	now := time.Now().UTC()
+	for _, a := range aliases {
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO capability_aliases (alias, target_app, target_name, source, kind, created_by, created)
+			 VALUES ($1, $2, $3, 'mirror', $4, $5, $6)
+			 ON CONFLICT (alias) DO UPDATE SET
+			   target_app = $2,
+			   target_name = $3,
+			   kind = $4
+			 WHERE capability_aliases.source = 'mirror'`,
+			a.Alias, targetApp, targetName, a.Kind, userID, now); err != nil {
+			return fmt.Errorf("%s - ReplaceMirroredAliases insert %s failed: %w", aliasesLogPrefix, a.Alias, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s - ReplaceMirroredAliases commit failed: %w", aliasesLogPrefix, err)
+	}
+	return nil
+}
+
+// SeedCapabilityAliases loads bootstrap config aliases (alias -> "app.name") into capability_aliases
+// as shorthands, which resolve without a rename warning.
+// Idempotent: bootstrap-sourced rows are updated, aliases managed through the API are left untouched.
+func SeedCapabilityAliases(ctx context.Context, pool *pgxpool.Pool, aliases map[string]string) error {
+	if len(aliases) == 0 {
+		return nil
+	}
+	slog.Info(fmt.Sprintf("%s - seeding %d bootstrap aliases", aliasesLogPrefix, len(aliases)))
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s - begin tx: %w", aliasesLogPrefix, err)
+	}
+	defer tx.Rollback(ctx)
+
+	for alias, target := range aliases {
+		app, name := parseCapRef(target)
+		if alias == "" || app == "" || name == "" {
+			slog.Warn(fmt.Sprintf("%s - skip invalid alias %q -> %q", aliasesLogPrefix, alias, target))
+			continue
+		}
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO capability_aliases (alias, target_app, target_name, source, kind, created_by)
+			 VALUES ($1, $2, $3, 'bootstrap', 'shorthand', $4::uuid)
+			 ON CONFLICT (alias) DO UPDATE SET
+			   target_app = EXCLUDED.target_app,
+			   target_name = EXCLUDED.target_name,
+			   kind = EXCLUDED.kind
+			 WHERE capability_aliases.source = 'bootstrap'`,
+			alias, app, name, systemUserID,
+		); err != nil {
+			return fmt.Errorf("%s - seed alias %q: %w", aliasesLogPrefix, alias, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s - commit: %w", aliasesLogPrefix, err)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const cellsLogPrefix = "db:cells"
+
+const cellColumns = `c.id, c.name, c.nats_url, c.subject_prefix, c.description,
+	        c.object, c.created, c.created_by, c.modified, c.modified_by`
+
+// GetCell returns a cell by name. Returns nil, nil when it does not exist.
+func (r *Repository) GetCell(ctx context.Context, name string) (*Cell, error) {
+	row := r.pool.QueryRow(ctx,
+		`SELECT `+cellColumns+`
+		 FROM cells c
+		 WHERE c.name = $1`, name)
+	c, err := scanCell(row)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s - GetCell failed: %w", cellsLogPrefix, err)
+	}
+	return c, nil
+}
+
+// ListCells returns all cells ordered by name.
+func (r *Repository) ListCells(ctx context.Context) ([]Cell, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT `+cellColumns+`
+		 FROM cells c
+		 ORDER BY c.name ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("%s - ListCells failed: %w", cellsLogPrefix, err)
+	}
+	defer rows.Close()
+
+	var out []Cell
+	for rows.Next() {
+		c, err := scanCell(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s - ListCells scan failed: %w", cellsLogPrefix, err)
+		}
+		out = append(out, *c)
+	}
+	return out, rows.Err()
+}
+
+// CountCellTenants returns the number of tenants mapped to each cell, keyed by cell name.
+func (r *Repository) CountCellTenants(ctx context.Context) (map[string]int, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT c.name, COUNT(*)::int
+		 FROM tenant_cells t
+		 JOIN cells c ON c.id = t.cell_id
+		 GROUP BY c.name`)
+	if err != nil {
+		return nil, fmt.Errorf("%s - CountCellTenants failed: %w", cellsLogPrefix, err)
+	}
+	defer rows.Close()
+
+	out := make(map[string]int)
+	for rows.Next() {
+		var name string
+		var n int
+		if err := rows.Scan(&name, &n); err != nil {
+			return nil, fmt.Errorf("%s - CountCellTenants scan failed: %w", cellsLogPrefix, err)
+		}
+		out[name] = n
+	}
+	return out, rows.Err()
+}
+
+// UpsertCellParams holds parameters for UpsertCell.
+type UpsertCellParams struct {
+	Name          string
+	NatsUrl       string
+	SubjectPrefix *string
+	Description   *string
+	UserID        string
+}
+
+// UpsertCell creates a cell or replaces its URL, subject prefix and description.
+func (r *Repository) UpsertCell(ctx context.Context, params UpsertCellParams) (*Cell, error) {
+	slog.Info(fmt.Sprintf("%s - UpsertCell name=%s natsUrl=%s", cellsLogPrefix, params.Name, params.NatsUrl))
+
+	now := time.Now().UTC()
+	row := r.pool.QueryRow(ctx,
+		`INSERT INTO cells AS c (name, nats_url, subject_prefix, description, created_by, modified_by, created, modified)
+		 VALUES ($1, $2, $3, $4, $5, $5, $6, $6)
+		 ON CONFLICT (name) DO UPDATE SET
+		   nats_url = $2,
+		   subject_prefix = $3,
+		   description = $4,
+		   modified = $6,
+		   modified_by = $5
+		 RETURNING `+cellColumns,
+		params.Name, params.NatsUrl, params.SubjectPrefix, params.Description, params.UserID, now)
+	c, err := scanCell(row)
+	if err != nil {
+		return nil, fmt.Errorf("%s - UpsertCell failed: %w", cellsLogPrefix, err)
+	}
+	return c, nil
+}
+
+// DeleteCell removes a cell. Returns false when it did not exist. Fails while tenants are mapped to it.
+func (r *Repository) DeleteCell(ctx context.Context, name string) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM cells WHERE name = $1`, name)
+	if err != nil {
+		return false, fmt.Errorf("%s - DeleteCell failed: %w", cellsLogPrefix, err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetTenantCell returns the cell a tenant is mapped to. Returns nil, nil for unmapped tenants.
+func (r *Repository) GetTenantCell(ctx context.Context, tenantID string) (*Cell, error) {
+	row := r.pool.QueryRow(ctx,
+		`SELECT `+cellColumns+`
+		 FROM tenant_cells t
+		 JOIN cells c ON c.id = t.cell_id
+		 WHERE t.tenant_id = $1`, tenantID)
+	c, err := scanCell(row)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s - GetTenantCell failed: %w", cellsLogPrefix, err)
+	}
+	return c, nil
+}
+
+// ListTenantCells returns the cell name of every mapped tenant, keyed by tenant ID.
+func (r *Repository) ListTenantCells(ctx context.Context) (map[string]string, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT t.tenant_id, c.name
+		 FROM tenant_cells t
+		 JOIN cells c ON c.id = t.cell_id`)
+	if err != nil {
+		return nil, fmt.Errorf("%s - ListTenantCells failed: %w", cellsLogPrefix, err)
+	}
+	defer rows.Close()
+
+	out := make(map[string]string)
+	for rows.Next() {
+		var tenantID, cell string
+		if err := rows.Scan(&tenantID, &cell); err != nil {
+			return nil, fmt.Errorf("%s - ListTenantCells scan failed: %w", cellsLogPrefix, err)
+		}
+		out[tenantID] = cell
+	}
+	return out, rows.Err()
+}
+
+// AssignTenantCell maps a tenant to a cell and returns the cell it was mapped to before
+// (nil when it was unmapped).
+func (r *Repository) AssignTenantCell(ctx context.Context, tenantID, cellID, userID string) (*Cell, error) {
+	slog.Info(fmt.Sprintf("%s - AssignTenantCell tenant=%s cellID=%s", cellsLogPrefix, tenantID, cellID))
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s - begin tx: %w", cellsLogPrefix, err)
+	}
+	defer tx.Rollback(ctx)
+
+	previous, err := scanCell(tx.QueryRow(ctx,
+		`SELECT `+cellColumns+`
+		 FROM tenant_cells t
+		 JOIN cells c ON c.id = t.cell_id
+		 WHERE t.tenant_id = $1
+		 FOR UPDATE OF t`, tenantID))
+	if err == pgx.ErrNoRows {
+		previous = nil
+	} else if err != nil {
+		return nil, fmt.Errorf("%s - read previous cell failed: %w", cellsLogPrefix, err)
+	}
+
+	now := time.Now().UTC()
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO tenant_cells (tenant_id, cell_id, created_by, modified_by, created, modified)
+		 VALUES ($1, $2, $3, $3, $4, $4)
+		 ON CONFLICT (tenant_id) DO UPDATE SET
+		   cell_id = $2,
+		   modified = $4,
+		   modified_by = $3`,
+		tenantID, cellID, userID, now); err != nil {
+		return nil, fmt.Errorf("%s - AssignTenantCell failed: %w", cellsLogPrefix, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%s - commit: %w", cellsLogPrefix, err)
+	}
+	return previous, nil
+}
+
+// RemoveTenantCell unmaps a tenant and returns the cell it was mapped to (nil when unmapped).
+func (r *Repository) RemoveTenantCell(ctx context.Context, tenantID string) (*Cell, error) {
+	row := r.pool.QueryRow(ctx,
+		`DELETE FROM tenant_cells t
+		 USING cells c
+		 WHERE c.id = t.cell_id AND t.tenant_id = $1
+		 RETURNING `+cellColumns, tenantID)
+	c, err := scanCell(row)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s - RemoveTenantCell failed: %w", cellsLogPrefix, err)
+	}
+	return c, nil
+}
+
+func scanCell(row pgx.Row) (*Cell, error) {
+	var c Cell
+	if err := row.Scan(
+		&c.ID, &c.Name, &c.NatsUrl, &c.SubjectPrefix, &c.Description,
+		&c.Object, &c.Created, &c.CreatedBy, &c.Modified, &c.ModifiedBy,
+	); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const changesLogPrefix = "db:changes"
+
+const changeColumns = `seq, app, capability, event, object, created`
+
+// changeSequenceLock is the transaction advisory lock that serializes sequence assignment.
+const changeSequenceLock = 0x72656763 // "regc"
+
+// RecordChange stores a change event under the next global sequence and returns it. The
+// sequence is assigned under an advisory lock in the inserting transaction, so sequences
+// become visible in order and without gaps.
+func (r *Repository) RecordChange(ctx context.Context, app, capability string, event []byte) (int64, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s - RecordChange begin failed: %w", changesLogPrefix, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, changeSequenceLock); err != nil {
+		return 0, fmt.Errorf("%s - RecordChange lock failed: %w", changesLogPrefix, err)
+	}
+	var seq int64
+	if err := tx.QueryRow(ctx,
+		`INSERT INTO registry_changes (seq, app, capability, event, created)
+		 SELECT COALESCE(MAX(seq), 0) + 1, $1, $2, $3, $4 FROM registry_changes
+		 RETURNING seq`,
+		app, capability, event, time.Now().UTC(),
+	).Scan(&seq); err != nil {
+		return 0, fmt.Errorf("%s - RecordChange failed: %w", changesLogPrefix, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("%s - RecordChange commit failed: %w", changesLogPrefix, err)
+	}
+	return seq, nil
+}
+
+// ListChangesSince returns up to limit changes with a sequence after since, oldest first.
+func (r *Repository) ListChangesSince(ctx context.Context, since int64, limit int) ([]RegistryChange, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT `+changeColumns+`
+		 FROM registry_changes
+		 WHERE seq > $1
+		 ORDER BY seq ASC
+		 LIMIT $2`, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s - ListChangesSince failed: %w", changesLogPrefix, err)
+	}
+	defer rows.Close()
+
+	var out []RegistryChange
+	for rows.Next() {
+		c, err := scanChange(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s - ListChangesSince scan failed: %w", changesLogPrefix, err)
+		}
+		out = append(out, *c)
+	}
+	return out, rows.Err()
+}
+
+// ChangeSequenceBounds returns the oldest and latest stored sequence; both are 0 when the
+// feed is empty.
+func (r *Repository) ChangeSequenceBounds(ctx context.Context) (oldest, latest int64, err error) {
+	if err := r.pool.QueryRow(ctx,
+		`SELECT COALESCE(MIN(seq), 0), COALESCE(MAX(seq), 0) FROM registry_changes`,
+	).Scan(&oldest, &latest); err != nil {
+		return 0, 0, fmt.Errorf("%s - ChangeSequenceBounds failed: %w", changesLogPrefix, err)
+	}
+	return oldest, latest, nil
+}
+
+// PruneChanges deletes changes created before the cutoff, except the latest one, which
+// keeps the current sequence known. It returns the number of deleted changes.
+func (r *Repository) PruneChanges(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := r.pool.Exec(ctx,
+		`DELETE FROM registry_changes
+		 WHERE created < $1 AND seq < (SELECT MAX(seq) FROM registry_changes)`, before)
+	if err != nil {
+		return 0, fmt.Errorf("%s - PruneChanges failed: %w", changesLogPrefix, err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+func scanChange(row pgx.Row) (*RegistryChange, error) {
+	var c RegistryChange
+	if err := row.Scan(&c.Seq, &c.App, &c.Capability, &c.Event, &c.Object, &c.Created); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
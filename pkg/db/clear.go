@@ -11,8 +11,11 @@ import (
 
 const clearLogPrefix = "db:clear"
 
-// ClearRegistry truncates all registry tables (capability_methods, capability_versions,
-// capability_defaults, capability_tenant_rules, capabilities) in dependency order.
+// ClearRegistry truncates all registry tables (release_pins, releases, capability_promotions,
+// capability_shadows, capability_aliases, capability_endpoints, capability_instances, capability_probes,
+// capability_methods, capability_versions, capability_defaults, capability_tenant_rules, capabilities,
+// tenant_cells, cells, registry_changes, webhook_deliveries, webhooks)
+// in dependency order.
 // Schema is preserved; only data is removed. RESTART IDENTITY resets sequences.
 func ClearRegistry(ctx context.Context, pool *pgxpool.Pool) error {
 	slog.Info(fmt.Sprintf("%s - Clearing registry tables", clearLogPrefix))
@@ -20,11 +23,24 @@ func ClearRegistry(ctx context.Context, pool *pgxpool.Pool) error {
 	// Truncate in dependency order: children first, then capabilities.
 	// CASCADE handles any other tables that reference these.
 	_, err := pool.Exec(ctx, `TRUNCATE TABLE
+		release_pins,
+		releases,
+		capability_promotions,
+		capability_shadows,
+		capability_aliases,
+		capability_endpoints,
+		capability_instances,
+		capability_probes,
 		capability_methods,
 		capability_versions,
 		capability_defaults,
 		capability_tenant_rules,
-		capabilities
+		capabilities,
+		tenant_cells,
+		cells,
+		registry_changes,
+		webhook_deliveries,
+		webhooks
 		RESTART IDENTITY CASCADE`)
 	if err != nil {
 		return fmt.Errorf("%s - truncate failed: %w", clearLogPrefix, err)
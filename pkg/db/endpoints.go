@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const endpointsLogPrefix = "db:endpoints"
+
+const endpointColumns = `id, version_id, nats_url, subject, region, zone, priority, weight,
+	        object, created, created_by, modified, modified_by`
+
+// EndpointParams describes one provider endpoint for ReplaceEndpoints.
+type EndpointParams struct {
+	NatsUrl  string
+	Subject  *string
+	Region   *string
+	Zone     *string
+	Priority int
+	Weight   int
+}
+
+// ListEndpoints returns the endpoints registered for a version, ordered by priority.
+func (r *Repository) ListEndpoints(ctx context.Context, versionID string) ([]CapabilityEndpoint, error) {
+	byVersion, err := r.ListEndpointsForVersions(ctx, []string{versionID})
+	if err != nil {
+		return nil, err
+	}
+	return byVersion[versionID], nil
+}
+
+// ListEndpointsForVersions returns endpoints for several versions keyed by version ID.
+// Versions without endpoints are absent from the map.
+func (r *Repository) ListEndpointsForVersions(ctx context.Context, versionIDs []string) (map[string][]CapabilityEndpoint, error) {
+	out := make(map[string][]CapabilityEndpoint)
+	if len(versionIDs) == 0 {
+		return out, nil
+	}
+	rows, err := r.pool.Query(ctx,
+		`SELECT `+endpointColumns+`
+		 FROM capability_endpoints
+		 WHERE version_id = ANY($1::uuid[])
+		 ORDER BY version_id, priority ASC, weight DESC, nats_url ASC`, versionIDs)
+	if err != nil {
+		return nil, fmt.Errorf("%s - ListEndpointsForVersions failed: %w", endpointsLogPrefix, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanEndpoint(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s - ListEndpointsForVersions scan failed: %w", endpointsLogPrefix, err)
+		}
+		out[e.VersionID] = append(out[e.VersionID], *e)
+	}
+	return out, rows.Err()
+}
+
+// ReplaceEndpoints replaces all endpoints of a version in one transaction.
+// An empty list removes them, so resolve falls back to the registry NATS URL.
+func (r *Repository) ReplaceEndpoints(ctx context.Context, versionID string, endpoints []EndpointParams, userID string) ([]CapabilityEndpoint, error) {
+	slog.Info(fmt.Sprintf("%s - ReplaceEndpoints versionID=%s count=%d", endpointsLogPrefix, versionID, len(endpoints)))
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s - begin tx: %w", endpointsLogPrefix, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM capability_endpoints WHERE version_id = $1`, versionID); err != nil {
+		return nil, fmt.Errorf("%s - delete endpoints failed: %w", endpointsLogPrefix, err)
+	}
+
+	now := time.Now().UTC()
+	out := make([]CapabilityEndpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		row := tx.QueryRow(ctx,
+			`INSERT INTO capability_endpoints
+			   (version_id, nats_url, subject, region, zone, priority, weight, created_by, modified_by, created, modified)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8, $9, $9)
+			 RETURNING `+endpointColumns,
+			versionID, ep.NatsUrl, ep.Subject, ep.Region, ep.Zone, ep.Priority, ep.Weight, userID, now)
+		e, err := scanEndpoint(row)
+		if err != nil {
+			return nil, fmt.Errorf("%s - insert endpoint %s failed: %w", endpointsLogPrefix, ep.NatsUrl, err)
+		}
+		out = append(out, *e)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%s - commit: %w", endpointsLogPrefix, err)
+	}
+	return out, nil
+}
+
+func scanEndpoint(row pgx.Row) (*CapabilityEndpoint, error) {
+	var e CapabilityEndpoint
+	if err := row.Scan(
+		&e.ID, &e.VersionID, &e.NatsUrl, &e.Subject, &e.Region, &e.Zone, &e.Priority, &e.Weight,
+		&e.Object, &e.Created, &e.CreatedBy, &e.Modified, &e.ModifiedBy,
+	); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
@@ -0,0 +1,181 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const instancesLogPrefix = "db:instances"
+
+// instanceColumns selects an instance row (aliased i) joined with its version (v) and capability (c).
+const instanceColumns = `i.id, i.version_id, i.instance_id, i.nats_url, i.region, i.zone,
+	        i.lease_expires_at, i.last_heartbeat, i.object, i.created, i.created_by, i.modified, i.modified_by,
+	        c.id, c.app, c.name, v.major,
+	        COALESCE(v.version_string, v.major::text || '.' || v.minor::text || '.' || v.patch::text)`
+
+// instanceJoin joins the instance rows of CTE i to their version and capability.
+const instanceJoin = ` FROM i
+	 JOIN capability_versions v ON v.id = i.version_id
+	 JOIN capabilities c ON c.id = v.capability_id`
+
+// RegisterInstanceParams holds parameters for RegisterInstance.
+type RegisterInstanceParams struct {
+	VersionID  string
+	InstanceID string
+	NatsUrl    *string
+	Region     *string
+	Zone       *string
+	TTL        time.Duration
+	UserID     string
+}
+
+// RegisterInstance creates an instance lease, or renews and re-points an existing one
+// (same instance_id) to the given version.
+func (r *Repository) RegisterInstance(ctx context.Context, params RegisterInstanceParams) (*CapabilityInstance, error) {
+	slog.Info(fmt.Sprintf("%s - RegisterInstance versionID=%s instance=%s", instancesLogPrefix, params.VersionID, params.InstanceID))
+
+	now := time.Now().UTC()
+	row := r.pool.QueryRow(ctx,
+		`WITH i AS (
+		   INSERT INTO capability_instances
+		     (version_id, instance_id, nats_url, region, zone, lease_expires_at, last_heartbeat, created_by, modified_by, created, modified)
+		   VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8, $7, $7)
+		   ON CONFLICT (instance_id) DO UPDATE SET
+		     version_id = EXCLUDED.version_id,
+		     nats_url = EXCLUDED.nats_url,
+		     region = EXCLUDED.region,
+		     zone = EXCLUDED.zone,
+		     lease_expires_at = EXCLUDED.lease_expires_at,
+		     last_heartbeat = EXCLUDED.last_heartbeat,
+		     modified = EXCLUDED.modified,
+		     modified_by = EXCLUDED.modified_by
+		   RETURNING *
+		 )
+		 SELECT `+instanceColumns+instanceJoin,
+		params.VersionID, params.InstanceID, params.NatsUrl, params.Region, params.Zone, now.Add(params.TTL), now, params.UserID)
+	inst, err := scanInstance(row)
+	if err != nil {
+		return nil, fmt.Errorf("%s - RegisterInstance failed: %w", instancesLogPrefix, err)
+	}
+	return inst, nil
+}
+
+// GetInstance returns an instance by its instance ID, live or expired, or nil if unknown.
+func (r *Repository) GetInstance(ctx context.Context, instanceID string) (*CapabilityInstance, error) {
+	row := r.pool.QueryRow(ctx,
+		`WITH i AS (SELECT * FROM capability_instances WHERE instance_id = $1)
+		 SELECT `+instanceColumns+instanceJoin, instanceID)
+	inst, err := scanInstance(row)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s - GetInstance failed: %w", instancesLogPrefix, err)
+	}
+	return inst, nil
+}
+
+// HeartbeatInstance extends a live lease by ttl from now. Returns nil when the instance is
+// unknown or its lease already expired; the provider must register again.
+func (r *Repository) HeartbeatInstance(ctx context.Context, instanceID string, ttl time.Duration) (*CapabilityInstance, error) {
+	now := time.Now().UTC()
+	row := r.pool.QueryRow(ctx,
+		`WITH i AS (
+		   UPDATE capability_instances
+		   SET lease_expires_at = $2, last_heartbeat = $3
+		   WHERE instance_id = $1 AND lease_expires_at > $3
+		   RETURNING *
+		 )
+		 SELECT `+instanceColumns+instanceJoin,
+		instanceID, now.Add(ttl), now)
+	inst, err := scanInstance(row)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s - HeartbeatInstance failed: %w", instancesLogPrefix, err)
+	}
+	return inst, nil
+}
+
+// DeregisterInstance deletes an instance lease and returns it, or nil if it was unknown.
+func (r *Repository) DeregisterInstance(ctx context.Context, instanceID string) (*CapabilityInstance, error) {
+	slog.Info(fmt.Sprintf("%s - DeregisterInstance instance=%s", instancesLogPrefix, instanceID))
+
+	row := r.pool.QueryRow(ctx,
+		`WITH i AS (DELETE FROM capability_instances WHERE instance_id = $1 RETURNING *)
+		 SELECT `+instanceColumns+instanceJoin, instanceID)
+	inst, err := scanInstance(row)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s - DeregisterInstance failed: %w", instancesLogPrefix, err)
+	}
+	return inst, nil
+}
+
+// ReapExpiredInstances deletes every instance whose lease expired and returns the deleted rows.
+func (r *Repository) ReapExpiredInstances(ctx context.Context) ([]CapabilityInstance, error) {
+	rows, err := r.pool.Query(ctx,
+		`WITH i AS (DELETE FROM capability_instances WHERE lease_expires_at <= $1 RETURNING *)
+		 SELECT `+instanceColumns+instanceJoin, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("%s - ReapExpiredInstances failed: %w", instancesLogPrefix, err)
+	}
+	defer rows.Close()
+
+	var out []CapabilityInstance
+	for rows.Next() {
+		inst, err := scanInstance(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s - ReapExpiredInstances scan failed: %w", instancesLogPrefix, err)
+		}
+		out = append(out, *inst)
+	}
+	return out, rows.Err()
+}
+
+// CountLiveInstances returns the number of instances with an unexpired lease per version ID.
+// Versions without live instances are absent from the map.
+func (r *Repository) CountLiveInstances(ctx context.Context, versionIDs []string) (map[string]int, error) {
+	out := make(map[string]int)
+	if len(versionIDs) == 0 {
+		return out, nil
+	}
+	rows, err := r.pool.Query(ctx,
+		`SELECT version_id, COUNT(*)::int
+		 FROM capability_instances
+		 WHERE version_id = ANY($1::uuid[]) AND lease_expires_at > $2
+		 GROUP BY version_id`, versionIDs, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("%s - CountLiveInstances failed: %w", instancesLogPrefix, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var versionID string
+		var n int
+		if err := rows.Scan(&versionID, &n); err != nil {
+			return nil, fmt.Errorf("%s - CountLiveInstances scan failed: %w", instancesLogPrefix, err)
+		}
+		out[versionID] = n
+	}
+	return out, rows.Err()
+}
+
+func scanInstance(row pgx.Row) (*CapabilityInstance, error) {
+	var i CapabilityInstance
+	if err := row.Scan(
+		&i.ID, &i.VersionID, &i.InstanceID, &i.NatsUrl, &i.Region, &i.Zone,
+		&i.LeaseExpiresAt, &i.LastHeartbeat, &i.Object, &i.Created, &i.CreatedBy, &i.Modified, &i.ModifiedBy,
+		&i.CapabilityID, &i.App, &i.Name, &i.Major, &i.VersionString,
+	); err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
@@ -490,3 +490,161 @@ func TestIntegration_SeedFromCapabilityMetadataFile(t *testing.T) {
 		t.Errorf("%s - capability = %s.%s, want seedapp.seedcap", dbIntegrationPrefix, cap.App, cap.Name)
 	}
 }
+
+func TestIntegration_ReleasePinBlocksVersionDelete(t *testing.T) {
+	ctx, repo, cleanup := setupIntegrationDB(t)
+	defer cleanup()
+
+	cap, err := repo.UpsertCapability(ctx, UpsertCapabilityParams{App: "testrel", Name: "pinned.cap", UserID: testUserID})
+	if err != nil {
+		t.Fatalf("%s - UpsertCapability failed: %v", dbIntegrationPrefix, err)
+	}
+	v, err := repo.UpsertVersion(ctx, UpsertVersionParams{CapabilityID: cap.ID, Major: 1, UserID: testUserID})
+	if err != nil {
+		t.Fatalf("%s - UpsertVersion failed: %v", dbIntegrationPrefix, err)
+	}
+	if _, err := repo.CreateRelease(ctx, CreateReleaseParams{
+		Name:   "testrel-pin-restrict",
+		Pins:   []CreateReleasePin{{CapabilityID: cap.ID, VersionID: v.ID}},
+		UserID: testUserID,
+	}); err != nil {
+		t.Fatalf("%s - CreateRelease failed: %v", dbIntegrationPrefix, err)
+	}
+
+	// Releases are immutable: the pinned version must not disappear from under them
+	if _, err := repo.pool.Exec(ctx, `DELETE FROM capability_versions WHERE id = $1`, v.ID); err == nil {
+		t.Errorf("%s - deleting a pinned version must fail", dbIntegrationPrefix)
+	}
+	if _, err := repo.pool.Exec(ctx, `DELETE FROM capabilities WHERE id = $1`, cap.ID); err == nil {
+		t.Errorf("%s - deleting a pinned capability must fail", dbIntegrationPrefix)
+	}
+}
+
+func TestIntegration_CapabilityAliasKinds(t *testing.T) {
+	ctx, pool, cleanup := setupIntegrationPool(t)
+	defer cleanup()
+	repo := NewRepository(pool)
+
+	if err := SeedCapabilityAliases(ctx, pool, map[string]string{"testalias-short": "system.registry"}); err != nil {
+		t.Fatalf("%s - SeedCapabilityAliases failed: %v", dbIntegrationPrefix, err)
+	}
+	seeded, err := repo.GetCapabilityAlias(ctx, "testalias-short")
+	if err != nil || seeded == nil || seeded.Kind != CapabilityAliasShorthand || seeded.Source != "bootstrap" {
+		t.Fatalf("%s - seeded alias = %+v, %v; want a bootstrap shorthand", dbIntegrationPrefix, seeded, err)
+	}
+
+	// An alias added through the API takes the name over as a rename
+	added, err := repo.AddCapabilityAlias(ctx, AddCapabilityAliasParams{
+		Alias: "testalias-short", TargetApp: "system", TargetName: "registry", UserID: testUserID,
+	})
+	if err != nil || added.Kind != CapabilityAliasRename || added.Source != "api" {
+		t.Errorf("%s - API alias = %+v, %v; want an api rename", dbIntegrationPrefix, added, err)
+	}
+	if _, err := repo.RemoveCapabilityAlias(ctx, "testalias-short"); err != nil {
+		t.Errorf("%s - RemoveCapabilityAlias failed: %v", dbIntegrationPrefix, err)
+	}
+}
+
+func TestIntegration_ReplaceTenantRules(t *testing.T) {
+	ctx, repo, cleanup := setupIntegrationDB(t)
+	defer cleanup()
+
+	cap, err := repo.UpsertCapability(ctx, UpsertCapabilityParams{App: "testrules", Name: "tenant.rules", UserID: testUserID})
+	if err != nil {
+		t.Fatalf("%s - UpsertCapability failed: %v", dbIntegrationPrefix, err)
+	}
+	tenant := "6f1c2a5e-0000-4000-8000-000000000001"
+	rules := []CapabilityTenantRule{
+		{TenantID: &tenant, RuleType: "deny", DeniedMajors: []int{2}, Priority: 10},
+		{RuleType: "allow", AllowedMajors: []int{1, 2}, Priority: 20},
+	}
+	if err := repo.ReplaceTenantRules(ctx, cap.ID, rules, testUserID); err != nil {
+		t.Fatalf("%s - ReplaceTenantRules failed: %v", dbIntegrationPrefix, err)
+	}
+	listed, err := repo.ListTenantRules(ctx, []string{cap.ID})
+	if err != nil || len(listed[cap.ID]) != 2 || listed[cap.ID][0].RuleType != "deny" {
+		t.Fatalf("%s - ListTenantRules = %+v, %v", dbIntegrationPrefix, listed, err)
+	}
+	if allowed, _ := repo.CheckTenantAccess(ctx, cap.ID, 2, ResolutionContext{TenantID: tenant}); allowed {
+		t.Errorf("%s - the deny rule must block major 2", dbIntegrationPrefix)
+	}
+
+	// Replacing with no rules removes them
+	if err := repo.ReplaceTenantRules(ctx, cap.ID, nil, testUserID); err != nil {
+		t.Fatalf("%s - ReplaceTenantRules failed: %v", dbIntegrationPrefix, err)
+	}
+	if allowed, reason := repo.CheckTenantAccess(ctx, cap.ID, 2, ResolutionContext{TenantID: tenant}); !allowed {
+		t.Errorf("%s - no rules must allow access, got %s", dbIntegrationPrefix, reason)
+	}
+}
+
+func TestIntegration_ReplaceMirroredAliases(t *testing.T) {
+	ctx, repo, cleanup := setupIntegrationDB(t)
+	defer cleanup()
+
+	if _, err := repo.AddCapabilityAlias(ctx, AddCapabilityAliasParams{
+		Alias: "testmirror.local", TargetApp: "testmirror", TargetName: "other", UserID: testUserID,
+	}); err != nil {
+		t.Fatalf("%s - AddCapabilityAlias failed: %v", dbIntegrationPrefix, err)
+	}
+	defer repo.RemoveCapabilityAlias(ctx, "testmirror.local")
+	defer repo.RemoveCapabilityAlias(ctx, "testmirror.old")
+
+	aliases := []CapabilityAlias{
+		{Alias: "testmirror.old", Kind: CapabilityAliasRename},
+		{Alias: "testmirror.local", Kind: CapabilityAliasRename},
+	}
+	if err := repo.ReplaceMirroredAliases(ctx, "testmirror", "target", aliases, testUserID); err != nil {
+		t.Fatalf("%s - ReplaceMirroredAliases failed: %v", dbIntegrationPrefix, err)
+	}
+	old, err := repo.GetCapabilityAlias(ctx, "testmirror.old")
+	if err != nil || old == nil || old.TargetName != "target" || old.Source != "mirror" {
+		t.Fatalf("%s - mirrored alias = %+v, %v", dbIntegrationPrefix, old, err)
+	}
+	if local, _ := repo.GetCapabilityAlias(ctx, "testmirror.local"); local == nil || local.TargetName != "other" {
+		t.Errorf("%s - an alias added locally must keep its target, got %+v", dbIntegrationPrefix, local)
+	}
+
+	// Replacing with none removes only the mirrored aliases
+	if err := repo.ReplaceMirroredAliases(ctx, "testmirror", "target", nil, testUserID); err != nil {
+		t.Fatalf("%s - ReplaceMirroredAliases failed: %v", dbIntegrationPrefix, err)
+	}
+	if old, _ := repo.GetCapabilityAlias(ctx, "testmirror.old"); old != nil {
+		t.Errorf("%s - expected the mirrored alias to be removed, got %+v", dbIntegrationPrefix, old)
+	}
+	if local, _ := repo.GetCapabilityAlias(ctx, "testmirror.local"); local == nil {
+		t.Errorf("%s - the local alias must stay", dbIntegrationPrefix)
+	}
+}
+
+func TestIntegration_ListShadowsAndTenantCells(t *testing.T) {
+	ctx, repo, cleanup := setupIntegrationDB(t)
+	defer cleanup()
+
+	cap, err := repo.UpsertCapability(ctx, UpsertCapabilityParams{App: "testmirror", Name: "shadowed", UserID: testUserID})
+	if err != nil {
+		t.Fatalf("%s - UpsertCapability failed: %v", dbIntegrationPrefix, err)
+	}
+	major := 2
+	if _, err := repo.UpsertShadow(ctx, UpsertShadowParams{CapabilityID: cap.ID, TargetMajor: &major, SampleRate: 0.1, UserID: testUserID}); err != nil {
+		t.Fatalf("%s - UpsertShadow failed: %v", dbIntegrationPrefix, err)
+	}
+	shadows, err := repo.ListShadows(ctx, []string{cap.ID, "00000000-0000-0000-0000-00000000ffff"})
+	if err != nil || len(shadows) != 1 || shadows[cap.ID] == nil || *shadows[cap.ID].TargetMajor != 2 {
+		t.Errorf("%s - ListShadows = %+v, %v", dbIntegrationPrefix, shadows, err)
+	}
+
+	cell, err := repo.UpsertCell(ctx, UpsertCellParams{Name: "testmirror-eu", NatsUrl: "nats://eu.example:4222", UserID: testUserID})
+	if err != nil {
+		t.Fatalf("%s - UpsertCell failed: %v", dbIntegrationPrefix, err)
+	}
+	defer repo.DeleteCell(ctx, cell.Name)
+	if _, err := repo.AssignTenantCell(ctx, "testmirror-tenant", cell.ID, testUserID); err != nil {
+		t.Fatalf("%s - AssignTenantCell failed: %v", dbIntegrationPrefix, err)
+	}
+	defer repo.RemoveTenantCell(ctx, "testmirror-tenant")
+	tenants, err := repo.ListTenantCells(ctx)
+	if err != nil || tenants["testmirror-tenant"] != "testmirror-eu" {
+		t.Errorf("%s - ListTenantCells = %+v, %v", dbIntegrationPrefix, tenants, err)
+	}
+}
@@ -18,6 +18,14 @@ type Capability struct {
 	ModifiedBy  string    `json:"modified_by"`
 	Config      []byte    `json:"config,omitempty"`
 	Ext         []byte    `json:"ext,omitempty"`
+	// SubjectTemplate overrides the default COMMS subject scheme; nil uses the registry default.
+	SubjectTemplate *string `json:"subject_template,omitempty"`
+	// LivenessPolicy is how resolve treats versions without live instances
+	// ("none", "warn", "skip"); nil means none.
+	LivenessPolicy *string `json:"liveness_policy,omitempty"`
+	// Source is "registry" (upsert or seed), "micro" (imported from a NATS micro service) or
+	// "mirror" (synced from an upstream registry).
+	Source string `json:"source"`
 }
 
 // CapabilityVersion represents a row in the capability_versions table.
@@ -44,6 +52,8 @@ type CapabilityVersion struct {
 	ModifiedBy        string     `json:"modified_by"`
 	Config            []byte     `json:"config,omitempty"`
 	Ext               []byte     `json:"ext,omitempty"`
+	// Envs lists the environments the version is available in; nil means all.
+	Envs []string `json:"envs,omitempty"`
 }
 
 // CapabilityMethod represents a row in the capability_methods table.
@@ -82,6 +92,117 @@ type CapabilityDefault struct {
 	Ext          []byte    `json:"ext,omitempty"`
 }
 
+// CapabilityPromotion represents a row in the capability_promotions table.
+type CapabilityPromotion struct {
+	ID           string    `json:"id"`
+	CapabilityID string    `json:"capability_id"`
+	VersionID    string    `json:"version_id"`
+	FromEnv      *string   `json:"from_env,omitempty"`
+	ToEnv        string    `json:"to_env"`
+	SetDefault   bool      `json:"set_default"`
+	Object       string    `json:"object"`
+	Created      time.Time `json:"created"`
+	CreatedBy    string    `json:"created_by"`
+}
+
+// CapabilityShadow represents a row in the capability_shadows table.
+type CapabilityShadow struct {
+	ID            string    `json:"id"`
+	CapabilityID  string    `json:"capability_id"`
+	TargetVersion *string   `json:"target_version,omitempty"`
+	TargetMajor   *int      `json:"target_major,omitempty"`
+	SampleRate    float64   `json:"sample_rate"`
+	TenantIDs     []string  `json:"tenant_ids,omitempty"`
+	Object        string    `json:"object"`
+	Created       time.Time `json:"created"`
+	CreatedBy     string    `json:"created_by"`
+	Modified      time.Time `json:"modified"`
+	ModifiedBy    string    `json:"modified_by"`
+}
+
+// CapabilityAlias represents a row in the capability_aliases table.
+type CapabilityAlias struct {
+	ID         string    `json:"id"`
+	Alias      string    `json:"alias"`
+	TargetApp  string    `json:"target_app"`
+	TargetName string    `json:"target_name"`
+	Source     string    `json:"source"`
+	Kind       string    `json:"kind"`
+	Object     string    `json:"object"`
+	Created    time.Time `json:"created"`
+	CreatedBy  string    `json:"created_by"`
+}
+
+// CapabilityEndpoint represents a row in the capability_endpoints table.
+type CapabilityEndpoint struct {
+	ID         string    `json:"id"`
+	VersionID  string    `json:"version_id"`
+	NatsUrl    string    `json:"nats_url"`
+	Subject    *string   `json:"subject,omitempty"`
+	Region     *string   `json:"region,omitempty"`
+	Zone       *string   `json:"zone,omitempty"`
+	Priority   int       `json:"priority"`
+	Weight     int       `json:"weight"`
+	Object     string    `json:"object"`
+	Created    time.Time `json:"created"`
+	CreatedBy  string    `json:"created_by"`
+	Modified   time.Time `json:"modified"`
+	ModifiedBy string    `json:"modified_by"`
+}
+
+// Cell represents a row in the cells table.
+type Cell struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	NatsUrl       string    `json:"nats_url"`
+	SubjectPrefix *string   `json:"subject_prefix,omitempty"`
+	Description   *string   `json:"description,omitempty"`
+	Object        string    `json:"object"`
+	Created       time.Time `json:"created"`
+	CreatedBy     string    `json:"created_by"`
+	Modified      time.Time `json:"modified"`
+	ModifiedBy    string    `json:"modified_by"`
+}
+
+// CapabilityInstance is a capability_instances row joined with its capability and version.
+type CapabilityInstance struct {
+	ID             string    `json:"id"`
+	VersionID      string    `json:"version_id"`
+	InstanceID     string    `json:"instance_id"`
+	NatsUrl        *string   `json:"nats_url,omitempty"`
+	Region         *string   `json:"region,omitempty"`
+	Zone           *string   `json:"zone,omitempty"`
+	LeaseExpiresAt time.Time `json:"lease_expires_at"`
+	LastHeartbeat  time.Time `json:"last_heartbeat"`
+	Object         string    `json:"object"`
+	Created        time.Time `json:"created"`
+	CreatedBy      string    `json:"created_by"`
+	Modified       time.Time `json:"modified"`
+	ModifiedBy     string    `json:"modified_by"`
+	// Joined from the version and capability.
+	CapabilityID  string `json:"capability_id"`
+	App           string `json:"app"`
+	Name          string `json:"name"`
+	Major         int    `json:"major"`
+	VersionString string `json:"version_string"`
+}
+
+// CapabilityProbe represents a row in the capability_probes table: the latest responder
+// probe of a version subject.
+type CapabilityProbe struct {
+	ID         string     `json:"id"`
+	VersionID  string     `json:"version_id"`
+	Subject    string     `json:"subject"`
+	Status     string     `json:"status"`
+	LatencyMs  *int       `json:"latency_ms,omitempty"`
+	Error      *string    `json:"error,omitempty"`
+	LastProbed time.Time  `json:"last_probed"`
+	LastSeen   *time.Time `json:"last_seen,omitempty"`
+	Object     string     `json:"object"`
+	Created    time.Time  `json:"created"`
+	Modified   time.Time  `json:"modified"`
+}
+
 // CapabilityTenantRule represents a row in the capability_tenant_rules table.
 type CapabilityTenantRule struct {
 	ID               string   `json:"id"`
@@ -113,6 +234,34 @@ type RegistryEntry struct {
 	Modified        time.Time `json:"modified"`
 }
 
+// Release represents a row in the releases table.
+// Releases are immutable once created: their pins never change.
+type Release struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description *string   `json:"description,omitempty"`
+	Object      string    `json:"object"`
+	Created     time.Time `json:"created"`
+	CreatedBy   string    `json:"created_by"`
+	PinCount    int       `json:"pin_count"`
+}
+
+// ReleasePin is a release_pins row joined with its capability and version,
+// so callers can answer resolve/describe without further lookups.
+type ReleasePin struct {
+	ReleaseID     string `json:"release_id"`
+	CapabilityID  string `json:"capability_id"`
+	VersionID     string `json:"version_id"`
+	App           string `json:"app"`
+	Name          string `json:"name"`
+	Major         int    `json:"major"`
+	VersionString string `json:"version_string"`
+	VersionStatus string `json:"version_status"`
+	Minor         int    `json:"minor"`
+	// SubjectTemplate is the pinned capability's subject template ("" = registry default).
+	SubjectTemplate string `json:"subject_template,omitempty"`
+}
+
 // ResolutionContext provides multi-tenant context for resolution.
 type ResolutionContext struct {
 	TenantID string
@@ -120,3 +269,53 @@ type ResolutionContext struct {
 	Aud      string
 	Features []string
 }
+
+// RegistryChange represents a row in the registry_changes table: one published change event
+// under its global sequence.
+type RegistryChange struct {
+	Seq        int64     `json:"seq"`
+	App        string    `json:"app"`
+	Capability string    `json:"capability"`
+	Event      []byte    `json:"event"`
+	Object     string    `json:"object"`
+	Created    time.Time `json:"created"`
+}
+
+// Webhook represents a row in the webhooks table: an HTTP endpoint that receives change events.
+type Webhook struct {
+	ID                  string    `json:"id"`
+	Name                string    `json:"name"`
+	URL                 string    `json:"url"`
+	Secret              string    `json:"-"`
+	App                 *string   `json:"app,omitempty"`
+	Capability          *string   `json:"capability,omitempty"`
+	ChangedFields       []string  `json:"changed_fields"`
+	Enabled             bool      `json:"enabled"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	DisabledReason      *string   `json:"disabled_reason,omitempty"`
+	Description         *string   `json:"description,omitempty"`
+	Object              string    `json:"object"`
+	Created             time.Time `json:"created"`
+	CreatedBy           string    `json:"created_by"`
+	Modified            time.Time `json:"modified"`
+	ModifiedBy          string    `json:"modified_by"`
+}
+
+// WebhookDelivery represents a row in the webhook_deliveries table: one change event sent
+// (or being retried) to one webhook.
+type WebhookDelivery struct {
+	ID          string     `json:"id"`
+	WebhookID   string     `json:"webhook_id"`
+	Seq         int64      `json:"seq"`
+	App         string     `json:"app"`
+	Capability  string     `json:"capability"`
+	Payload     []byte     `json:"payload"`
+	Status      string     `json:"status"`
+	Attempts    int        `json:"attempts"`
+	NextAttempt time.Time  `json:"next_attempt"`
+	StatusCode  *int       `json:"status_code,omitempty"`
+	Error       *string    `json:"error,omitempty"`
+	LastAttempt *time.Time `json:"last_attempt,omitempty"`
+	Object      string     `json:"object"`
+	Created     time.Time  `json:"created"`
+}
@@ -0,0 +1,128 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const probesLogPrefix = "db:probes"
+
+const probeColumns = `id, version_id, subject, status, latency_ms, error, last_probed, last_seen, object, created, modified`
+
+// Probe statuses stored in capability_probes.status.
+const (
+	ProbeStatusReachable   = "reachable"
+	ProbeStatusUnreachable = "unreachable"
+	ProbeStatusTimeout     = "timeout"
+	ProbeStatusError       = "error"
+	// ProbeStatusUnknown is recorded for versions served only where the registry's own NATS
+	// connection cannot reach, so no probe was sent.
+	ProbeStatusUnknown = "unknown"
+)
+
+// RecordProbeParams holds parameters for RecordProbe.
+type RecordProbeParams struct {
+	VersionID string
+	Subject   string
+	Status    string
+	LatencyMs *int
+	Error     *string
+	ProbedAt  time.Time
+}
+
+// RecordProbe stores the latest probe of a version, replacing the previous one. last_seen is
+// set to the probe time when the probe reached a responder and kept otherwise.
+func (r *Repository) RecordProbe(ctx context.Context, params RecordProbeParams) (*CapabilityProbe, error) {
+	row := r.pool.QueryRow(ctx,
+		`INSERT INTO capability_probes (version_id, subject, status, latency_ms, error, last_probed, last_seen, created, modified)
+		 VALUES ($1, $2, $3, $4, $5, $6, CASE WHEN $3 = 'reachable' THEN $6::timestamptz END, $6, $6)
+		 ON CONFLICT (version_id) DO UPDATE SET
+		   subject = EXCLUDED.subject,
+		   status = EXCLUDED.status,
+		   latency_ms = EXCLUDED.latency_ms,
+		   error = EXCLUDED.error,
+		   last_probed = EXCLUDED.last_probed,
+		   last_seen = COALESCE(EXCLUDED.last_seen, capability_probes.last_seen),
+		   modified = EXCLUDED.modified
+		 RETURNING `+probeColumns,
+		params.VersionID, params.Subject, params.Status, params.LatencyMs, params.Error, params.ProbedAt)
+	p, err := scanProbe(row)
+	if err != nil {
+		return nil, fmt.Errorf("%s - RecordProbe failed: %w", probesLogPrefix, err)
+	}
+	return p, nil
+}
+
+// GetProbes returns the latest probe per version ID. Versions never probed are absent from the map.
+func (r *Repository) GetProbes(ctx context.Context, versionIDs []string) (map[string]*CapabilityProbe, error) {
+	out := make(map[string]*CapabilityProbe)
+	if len(versionIDs) == 0 {
+		return out, nil
+	}
+	rows, err := r.pool.Query(ctx,
+		`SELECT `+probeColumns+` FROM capability_probes WHERE version_id = ANY($1::uuid[])`, versionIDs)
+	if err != nil {
+		return nil, fmt.Errorf("%s - GetProbes failed: %w", probesLogPrefix, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p, err := scanProbe(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s - GetProbes scan failed: %w", probesLogPrefix, err)
+		}
+		out[p.VersionID] = p
+	}
+	return out, rows.Err()
+}
+
+// ProbeSummary counts the latest probes of served versions by status.
+type ProbeSummary struct {
+	ByStatus   map[string]int
+	LastProbed *time.Time
+}
+
+// SummarizeProbes counts the latest probes of non-disabled versions of Active capabilities by status.
+func (r *Repository) SummarizeProbes(ctx context.Context) (*ProbeSummary, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT p.status, COUNT(*)::int, MAX(p.last_probed)
+		 FROM capability_probes p
+		 JOIN capability_versions v ON v.id = p.version_id
+		 JOIN capabilities c ON c.id = v.capability_id
+		 WHERE v.status <> 'disabled' AND c.status = 'Active'
+		 GROUP BY p.status`)
+	if err != nil {
+		return nil, fmt.Errorf("%s - SummarizeProbes failed: %w", probesLogPrefix, err)
+	}
+	defer rows.Close()
+
+	out := &ProbeSummary{ByStatus: make(map[string]int)}
+	for rows.Next() {
+		var status string
+		var n int
+		var last time.Time
+		if err := rows.Scan(&status, &n, &last); err != nil {
+			return nil, fmt.Errorf("%s - SummarizeProbes scan failed: %w", probesLogPrefix, err)
+		}
+		out.ByStatus[status] = n
+		if out.LastProbed == nil || last.After(*out.LastProbed) {
+			l := last
+			out.LastProbed = &l
+		}
+	}
+	return out, rows.Err()
+}
+
+func scanProbe(row pgx.Row) (*CapabilityProbe, error) {
+	var p CapabilityProbe
+	if err := row.Scan(
+		&p.ID, &p.VersionID, &p.Subject, &p.Status, &p.LatencyMs, &p.Error,
+		&p.LastProbed, &p.LastSeen, &p.Object, &p.Created, &p.Modified,
+	); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
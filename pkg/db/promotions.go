@@ -0,0 +1,74 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const promotionsLogPrefix = "db:promotions"
+
+// PromoteVersionParams holds parameters for PromoteVersion.
+type PromoteVersionParams struct {
+	CapabilityID string
+	VersionID    string
+	Major        int
+	FromEnv      *string
+	ToEnv        string
+	SetDefault   bool
+	UserID       string
+}
+
+// PromoteVersion makes a version available in ToEnv, optionally sets its major as the
+// default there, and records the promotion, all in a single transaction.
+// Versions with NULL envs are already available everywhere and are left unrestricted.
+func (r *Repository) PromoteVersion(ctx context.Context, params PromoteVersionParams) (*CapabilityPromotion, error) {
+	slog.Info(fmt.Sprintf("%s - PromoteVersion versionID=%s toEnv=%s setDefault=%t", promotionsLogPrefix, params.VersionID, params.ToEnv, params.SetDefault))
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s - PromoteVersion begin failed: %w", promotionsLogPrefix, err)
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now().UTC()
+	if _, err := tx.Exec(ctx,
+		`UPDATE capability_versions
+		 SET envs = array_append(envs, $2), modified = $3, modified_by = $4
+		 WHERE id = $1 AND envs IS NOT NULL AND NOT ($2 = ANY(envs))`,
+		params.VersionID, params.ToEnv, now, params.UserID,
+	); err != nil {
+		return nil, fmt.Errorf("%s - PromoteVersion update envs failed: %w", promotionsLogPrefix, err)
+	}
+
+	if params.SetDefault {
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO capability_defaults (capability_id, default_major, env, created_by, modified_by, created, modified)
+			 VALUES ($1, $2, $3, $4, $4, $5, $5)
+			 ON CONFLICT (capability_id, env) DO UPDATE SET
+			   default_major = $2,
+			   modified = $5,
+			   modified_by = $4`,
+			params.CapabilityID, params.Major, params.ToEnv, params.UserID, now,
+		); err != nil {
+			return nil, fmt.Errorf("%s - PromoteVersion set default failed: %w", promotionsLogPrefix, err)
+		}
+	}
+
+	var p CapabilityPromotion
+	err = tx.QueryRow(ctx,
+		`INSERT INTO capability_promotions (capability_id, version_id, from_env, to_env, set_default, created_by, created)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 RETURNING id, capability_id, version_id, from_env, to_env, set_default, object, created, created_by`,
+		params.CapabilityID, params.VersionID, params.FromEnv, params.ToEnv, params.SetDefault, params.UserID, now,
+	).Scan(&p.ID, &p.CapabilityID, &p.VersionID, &p.FromEnv, &p.ToEnv, &p.SetDefault, &p.Object, &p.Created, &p.CreatedBy)
+	if err != nil {
+		return nil, fmt.Errorf("%s - PromoteVersion insert failed: %w", promotionsLogPrefix, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%s - PromoteVersion commit failed: %w", promotionsLogPrefix, err)
+	}
+	return &p, nil
+}
@@ -4,60 +4,55 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 )
 
 const registriesLogPrefix = "db:registries"
 
+const registryColumns = `id, alias, nats_url, registry_subject, is_default, config, created, modified`
+
 // GetRegistryByAlias retrieves a registry entry by its alias.
 func (r *Repository) GetRegistryByAlias(ctx context.Context, alias string) (*RegistryEntry, error) {
 	slog.Debug(fmt.Sprintf("%s - GetRegistryByAlias alias=%s", registriesLogPrefix, alias))
 
-	var e RegistryEntry
-	err := r.pool.QueryRow(ctx,
-		`SELECT id, alias, nats_url, registry_subject, is_default, config, created, modified
+	e, err := scanRegistry(r.pool.QueryRow(ctx,
+		`SELECT `+registryColumns+`
 		 FROM registries
 		 WHERE alias = $1
 		 LIMIT 1`, alias,
-	).Scan(
-		&e.ID, &e.Alias, &e.NatsUrl, &e.RegistrySubject,
-		&e.IsDefault, &e.Config, &e.Created, &e.Modified,
-	)
+	))
 	if err == pgx.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, fmt.Errorf("%s - GetRegistryByAlias failed: %w", registriesLogPrefix, err)
 	}
-	return &e, nil
+	return e, nil
 }
 
 // GetDefaultRegistry returns the registry entry marked as default.
 func (r *Repository) GetDefaultRegistry(ctx context.Context) (*RegistryEntry, error) {
-	var e RegistryEntry
-	err := r.pool.QueryRow(ctx,
-		`SELECT id, alias, nats_url, registry_subject, is_default, config, created, modified
+	e, err := scanRegistry(r.pool.QueryRow(ctx,
+		`SELECT `+registryColumns+`
 		 FROM registries
 		 WHERE is_default = true
 		 LIMIT 1`,
-	).Scan(
-		&e.ID, &e.Alias, &e.NatsUrl, &e.RegistrySubject,
-		&e.IsDefault, &e.Config, &e.Created, &e.Modified,
-	)
+	))
 	if err == pgx.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, fmt.Errorf("%s - GetDefaultRegistry failed: %w", registriesLogPrefix, err)
 	}
-	return &e, nil
+	return e, nil
 }
 
 // ListRegistries returns all active registry entries.
 func (r *Repository) ListRegistries(ctx context.Context) ([]RegistryEntry, error) {
 	rows, err := r.pool.Query(ctx,
-		`SELECT id, alias, nats_url, registry_subject, is_default, config, created, modified
+		`SELECT `+registryColumns+`
 		 FROM registries
 		 ORDER BY alias ASC`)
 	if err != nil {
@@ -67,14 +62,122 @@ func (r *Repository) ListRegistries(ctx context.Context) ([]RegistryEntry, error
 
 	var entries []RegistryEntry
 	for rows.Next() {
-		var e RegistryEntry
-		if err := rows.Scan(
-			&e.ID, &e.Alias, &e.NatsUrl, &e.RegistrySubject,
-			&e.IsDefault, &e.Config, &e.Created, &e.Modified,
-		); err != nil {
+		e, err := scanRegistry(rows)
+		if err != nil {
 			return nil, fmt.Errorf("%s - ListRegistries scan failed: %w", registriesLogPrefix, err)
 		}
-		entries = append(entries, e)
+		entries = append(entries, *e)
+	}
+	return entries, rows.Err()
+}
+
+// SaveRegistryParams holds parameters for CreateRegistry and UpdateRegistry.
+// For UpdateRegistry nil fields are left unchanged.
+type SaveRegistryParams struct {
+	Alias           string
+	NatsUrl         *string
+	RegistrySubject *string
+	IsDefault       *bool
+	Config          []byte
+}
+
+// CreateRegistry inserts a registry alias. Returns nil, nil when the alias already exists.
+// Making it the default clears the flag on every other alias.
+func (r *Repository) CreateRegistry(ctx context.Context, params SaveRegistryParams) (*RegistryEntry, error) {
+	slog.Info(fmt.Sprintf("%s - CreateRegistry alias=%s", registriesLogPrefix, params.Alias))
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s - begin tx: %w", registriesLogPrefix, err)
+	}
+	defer tx.Rollback(ctx)
+
+	isDefault := params.IsDefault != nil && *params.IsDefault
+	if isDefault {
+		if _, err := tx.Exec(ctx, `UPDATE registries SET is_default = false, modified = $1 WHERE is_default = true`, time.Now().UTC()); err != nil {
+			return nil, fmt.Errorf("%s - clear default failed: %w", registriesLogPrefix, err)
+		}
+	}
+	config := params.Config
+	if config == nil {
+		config = []byte("{}")
+	}
+	now := time.Now().UTC()
+	e, err := scanRegistry(tx.QueryRow(ctx,
+		`INSERT INTO registries (alias, nats_url, registry_subject, is_default, config, created, modified)
+		 VALUES ($1, $2, $3, $4, $5, $6, $6)
+		 ON CONFLICT (alias) DO NOTHING
+		 RETURNING `+registryColumns,
+		params.Alias, params.NatsUrl, params.RegistrySubject, isDefault, config, now))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s - CreateRegistry failed: %w", registriesLogPrefix, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%s - commit failed: %w", registriesLogPrefix, err)
+	}
+	return e, nil
+}
+
+// UpdateRegistry changes the non-nil fields of a registry alias. Returns nil, nil when the
+// alias does not exist. Making it the default clears the flag on every other alias.
+func (r *Repository) UpdateRegistry(ctx context.Context, params SaveRegistryParams) (*RegistryEntry, error) {
+	slog.Info(fmt.Sprintf("%s - UpdateRegistry alias=%s", registriesLogPrefix, params.Alias))
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s - begin tx: %w", registriesLogPrefix, err)
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now().UTC()
+	if params.IsDefault != nil && *params.IsDefault {
+		if _, err := tx.Exec(ctx,
+			`UPDATE registries SET is_default = false, modified = $1 WHERE is_default = true AND alias <> $2`,
+			now, params.Alias); err != nil {
+			return nil, fmt.Errorf("%s - clear default failed: %w", registriesLogPrefix, err)
+		}
+	}
+	e, err := scanRegistry(tx.QueryRow(ctx,
+		`UPDATE registries SET
+		   nats_url = COALESCE($2, nats_url),
+		   registry_subject = COALESCE($3, registry_subject),
+		   is_default = COALESCE($4, is_default),
+		   config = COALESCE($5, config),
+		   modified = $6
+		 WHERE alias = $1
+		 RETURNING `+registryColumns,
+		params.Alias, params.NatsUrl, params.RegistrySubject, params.IsDefault, params.Config, now))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s - UpdateRegistry failed: %w", registriesLogPrefix, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%s - commit failed: %w", registriesLogPrefix, err)
 	}
-	return entries, nil
+	return e, nil
+}
+
+// DeleteRegistry removes a registry alias. Returns false when it did not exist.
+func (r *Repository) DeleteRegistry(ctx context.Context, alias string) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM registries WHERE alias = $1`, alias)
+	if err != nil {
+		return false, fmt.Errorf("%s - DeleteRegistry failed: %w", registriesLogPrefix, err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func scanRegistry(row pgx.Row) (*RegistryEntry, error) {
+	var e RegistryEntry
+	if err := row.Scan(
+		&e.ID, &e.Alias, &e.NatsUrl, &e.RegistrySubject,
+		&e.IsDefault, &e.Config, &e.Created, &e.Modified,
+	); err != nil {
+		return nil, err
+	}
+	return &e, nil
 }
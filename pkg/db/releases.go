@@ -0,0 +1,168 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const releasesLogPrefix = "db:releases"
+
+const releaseColumns = `r.id, r.name, r.description, r.object, r.created, r.created_by,
+	        (SELECT COUNT(*)::int FROM release_pins p WHERE p.release_id = r.id)`
+
+const releasePinColumns = `p.release_id, p.capability_id, p.version_id, c.app, c.name, v.major,
+	        COALESCE(v.version_string, v.major::text || '.' || v.minor::text || '.' || v.patch::text), v.status,
+	        v.minor, COALESCE(c.subject_template, '')`
+
+// CreateReleaseParams holds parameters for CreateRelease.
+type CreateReleaseParams struct {
+	Name        string
+	Description *string
+	Pins        []CreateReleasePin
+	UserID      string
+}
+
+// CreateReleasePin pins one capability to one version within a new release.
+type CreateReleasePin struct {
+	CapabilityID string
+	VersionID    string
+}
+
+// CreateRelease inserts a release and all of its pins in a single transaction.
+// Releases are immutable, so there is no update counterpart. Returns nil, nil when a
+// release with the name already exists.
+func (r *Repository) CreateRelease(ctx context.Context, params CreateReleaseParams) (*Release, error) {
+	slog.Info(fmt.Sprintf("%s - CreateRelease name=%s pins=%d", releasesLogPrefix, params.Name, len(params.Pins)))
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s - CreateRelease begin failed: %w", releasesLogPrefix, err)
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now().UTC()
+	var rel Release
+	err = tx.QueryRow(ctx,
+		`INSERT INTO releases (name, description, created_by, created)
+		 VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (name) DO NOTHING
+		 RETURNING id, name, description, object, created, created_by`,
+		params.Name, params.Description, params.UserID, now,
+	).Scan(&rel.ID, &rel.Name, &rel.Description, &rel.Object, &rel.Created, &rel.CreatedBy)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s - CreateRelease insert failed: %w", releasesLogPrefix, err)
+	}
+
+	for _, pin := range params.Pins {
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO release_pins (release_id, capability_id, version_id, created)
+			 VALUES ($1, $2, $3, $4)`,
+			rel.ID, pin.CapabilityID, pin.VersionID, now,
+		); err != nil {
+			return nil, fmt.Errorf("%s - CreateRelease pin insert failed: %w", releasesLogPrefix, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%s - CreateRelease commit failed: %w", releasesLogPrefix, err)
+	}
+	rel.PinCount = len(params.Pins)
+	return &rel, nil
+}
+
+// GetReleaseByName retrieves a release by name. Returns nil, nil when not found.
+func (r *Repository) GetReleaseByName(ctx context.Context, name string) (*Release, error) {
+	slog.Debug(fmt.Sprintf("%s - GetReleaseByName name=%s", releasesLogPrefix, name))
+
+	var rel Release
+	err := r.pool.QueryRow(ctx,
+		`SELECT `+releaseColumns+`
+		 FROM releases r
+		 WHERE r.name = $1
+		 LIMIT 1`, name,
+	).Scan(&rel.ID, &rel.Name, &rel.Description, &rel.Object, &rel.Created, &rel.CreatedBy, &rel.PinCount)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s - GetReleaseByName failed: %w", releasesLogPrefix, err)
+	}
+	return &rel, nil
+}
+
+// ListReleases returns all releases, newest first.
+func (r *Repository) ListReleases(ctx context.Context) ([]Release, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT `+releaseColumns+`
+		 FROM releases r
+		 ORDER BY r.created DESC, r.name ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("%s - ListReleases failed: %w", releasesLogPrefix, err)
+	}
+	defer rows.Close()
+
+	var out []Release
+	for rows.Next() {
+		var rel Release
+		if err := rows.Scan(&rel.ID, &rel.Name, &rel.Description, &rel.Object, &rel.Created, &rel.CreatedBy, &rel.PinCount); err != nil {
+			return nil, fmt.Errorf("%s - ListReleases scan failed: %w", releasesLogPrefix, err)
+		}
+		out = append(out, rel)
+	}
+	return out, nil
+}
+
+// GetReleasePins returns all pins of a release ordered by app and name.
+func (r *Repository) GetReleasePins(ctx context.Context, releaseID string) ([]ReleasePin, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT `+releasePinColumns+`
+		 FROM release_pins p
+		 JOIN capabilities c ON c.id = p.capability_id
+		 JOIN capability_versions v ON v.id = p.version_id
+		 WHERE p.release_id = $1
+		 ORDER BY c.app ASC, c.name ASC`, releaseID)
+	if err != nil {
+		return nil, fmt.Errorf("%s - GetReleasePins failed: %w", releasesLogPrefix, err)
+	}
+	defer rows.Close()
+
+	var out []ReleasePin
+	for rows.Next() {
+		var p ReleasePin
+		if err := rows.Scan(&p.ReleaseID, &p.CapabilityID, &p.VersionID, &p.App, &p.Name,
+			&p.Major, &p.VersionString, &p.VersionStatus, &p.Minor, &p.SubjectTemplate); err != nil {
+			return nil, fmt.Errorf("%s - GetReleasePins scan failed: %w", releasesLogPrefix, err)
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// GetReleasePin returns the pin for one capability in a release. Returns nil, nil when the
+// capability is not part of the release.
+func (r *Repository) GetReleasePin(ctx context.Context, releaseID, capabilityID string) (*ReleasePin, error) {
+	var p ReleasePin
+	err := r.pool.QueryRow(ctx,
+		`SELECT `+releasePinColumns+`
+		 FROM release_pins p
+		 JOIN capabilities c ON c.id = p.capability_id
+		 JOIN capability_versions v ON v.id = p.version_id
+		 WHERE p.release_id = $1 AND p.capability_id = $2
+		 LIMIT 1`, releaseID, capabilityID,
+	).Scan(&p.ReleaseID, &p.CapabilityID, &p.VersionID, &p.App, &p.Name,
+		&p.Major, &p.VersionString, &p.VersionStatus, &p.Minor, &p.SubjectTemplate)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s - GetReleasePin failed: %w", releasesLogPrefix, err)
+	}
+	return &p, nil
+}
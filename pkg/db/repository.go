@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -33,7 +35,7 @@ func (r *Repository) GetCapability(ctx context.Context, app, name string) (*Capa
 
 	row := r.pool.QueryRow(ctx,
 		`SELECT id, app, name, description, tags, status, object, revision,
-		        created, created_by, modified, modified_by, config, ext
+		        created, created_by, modified, modified_by, config, ext, subject_template, liveness_policy, source
 		 FROM capabilities
 		 WHERE app = $1 AND name = $2
 		 LIMIT 1`, app, name)
@@ -45,7 +47,7 @@ func (r *Repository) GetCapability(ctx context.Context, app, name string) (*Capa
 func (r *Repository) GetCapabilityByID(ctx context.Context, id string) (*Capability, error) {
 	row := r.pool.QueryRow(ctx,
 		`SELECT id, app, name, description, tags, status, object, revision,
-		        created, created_by, modified, modified_by, config, ext
+		        created, created_by, modified, modified_by, config, ext, subject_template, liveness_policy, source
 		 FROM capabilities
 		 WHERE id = $1
 		 LIMIT 1`, id)
@@ -58,19 +60,26 @@ func (r *Repository) UpsertCapability(ctx context.Context, params UpsertCapabili
 	slog.Info(fmt.Sprintf("%s - UpsertCapability app=%s name=%s", repoLogPrefix, params.App, params.Name))
 
 	now := time.Now().UTC()
+	source := params.Source
+	if source == "" {
+		source = CapabilitySourceRegistry
+	}
 
 	row := r.pool.QueryRow(ctx,
-		`INSERT INTO capabilities (app, name, description, tags, created_by, modified_by, created, modified)
-		 VALUES ($1, $2, $3, $4, $5, $5, $6, $6)
+		`INSERT INTO capabilities (app, name, description, tags, created_by, modified_by, created, modified, subject_template, liveness_policy, source)
+		 VALUES ($1, $2, $3, $4, $5, $5, $6, $6, $7, $8, $9)
 		 ON CONFLICT (app, name) DO UPDATE SET
 		   description = COALESCE($3, capabilities.description),
 		   tags = COALESCE($4, capabilities.tags),
+		   subject_template = COALESCE($7, capabilities.subject_template),
+		   liveness_policy = COALESCE($8, capabilities.liveness_policy),
+		   source = $9,
 		   revision = capabilities.revision + 1,
 		   modified = $6,
 		   modified_by = $5
 		 RETURNING id, app, name, description, tags, status, object, revision,
-		           created, created_by, modified, modified_by, config, ext`,
-		params.App, params.Name, params.Description, params.Tags, params.UserID, now)
+		           created, created_by, modified, modified_by, config, ext, subject_template, liveness_policy, source`,
+		params.App, params.Name, params.Description, params.Tags, params.UserID, now, params.SubjectTemplate, params.LivenessPolicy, source)
 
 	return scanCapability(row)
 }
@@ -81,8 +90,21 @@ type UpsertCapabilityParams struct {
 	Name        string
 	Description *string
 	Tags        []string
-	UserID      string
-}
+	// SubjectTemplate replaces the stored template when non-nil; nil keeps the current one.
+	SubjectTemplate *string
+	// LivenessPolicy replaces the stored policy when non-nil; nil keeps the current one.
+	LivenessPolicy *string
+	// Source replaces the stored source; empty means CapabilitySourceRegistry.
+	Source string
+	UserID string
+}
+
+// Capability sources stored in capabilities.source.
+const (
+	CapabilitySourceRegistry = "registry"
+	CapabilitySourceMicro    = "micro"
+	CapabilitySourceMirror   = "mirror"
+)
 
 // MaxDiscoverLimit is the maximum limit allowed for ListCapabilities/Discover (DoS protection).
 const MaxDiscoverLimit = 500
@@ -104,7 +126,7 @@ func (r *Repository) ListCapabilities(ctx context.Context, params ListCapabiliti
 
 	// Build query dynamically
 	query := `SELECT id, app, name, description, tags, status, object, revision,
-	                 created, created_by, modified, modified_by, config, ext
+	                 created, created_by, modified, modified_by, config, ext, subject_template, liveness_policy, source
 	          FROM capabilities WHERE 1=1`
 	countQuery := `SELECT COUNT(*)::int FROM capabilities WHERE 1=1`
 	args := []interface{}{}
@@ -148,7 +170,11 @@ func (r *Repository) ListCapabilities(ctx context.Context, params ListCapabiliti
 	}
 
 	// Data
-	query += ` ORDER BY modified DESC`
+	if params.SortByName {
+		query += ` ORDER BY app ASC, name ASC`
+	} else {
+		query += ` ORDER BY modified DESC`
+	}
 	query += fmt.Sprintf(` LIMIT $%d OFFSET $%d`, argIdx, argIdx+1)
 	args = append(args, limit, offset)
 
@@ -178,6 +204,9 @@ type ListCapabilitiesParams struct {
 	Status string
 	Page   int
 	Limit  int
+	// SortByName orders by app and name, which keeps pages stable while capabilities change;
+	// the default is most recently modified first.
+	SortByName bool
 }
 
 // =========================================================================
@@ -189,7 +218,7 @@ func (r *Repository) GetVersions(ctx context.Context, capabilityID string) ([]Ca
 	rows, err := r.pool.Query(ctx,
 		`SELECT id, capability_id, major, minor, patch, prerelease, build_metadata,
 		        version_string, status, deprecation_reason, deprecated_at, disabled_at,
-		        description, changelog, metadata, object, created, created_by, modified, modified_by, config, ext
+		        description, changelog, metadata, object, created, created_by, modified, modified_by, config, ext, envs
 		 FROM capability_versions
 		 WHERE capability_id = $1
 		 ORDER BY major DESC, minor DESC, patch DESC`, capabilityID)
@@ -209,7 +238,7 @@ func (r *Repository) GetVersionsByCapabilityIDs(ctx context.Context, capabilityI
 	rows, err := r.pool.Query(ctx,
 		`SELECT id, capability_id, major, minor, patch, prerelease, build_metadata,
 		        version_string, status, deprecation_reason, deprecated_at, disabled_at,
-		        description, changelog, metadata, object, created, created_by, modified, modified_by, config, ext
+		        description, changelog, metadata, object, created, created_by, modified, modified_by, config, ext, envs
 		 FROM capability_versions
 		 WHERE capability_id = ANY($1)
 		 ORDER BY capability_id, major DESC, minor DESC, patch DESC`, capabilityIDs)
@@ -226,7 +255,7 @@ func (r *Repository) GetVersionsByCapabilityIDs(ctx context.Context, capabilityI
 			&v.Prerelease, &v.BuildMetadata, &v.VersionString,
 			&v.Status, &v.DeprecationReason, &v.DeprecatedAt, &v.DisabledAt,
 			&v.Description, &v.Changelog, &v.Metadata,
-			&v.Object, &v.Created, &v.CreatedBy, &v.Modified, &v.ModifiedBy, &v.Config, &v.Ext,
+			&v.Object, &v.Created, &v.CreatedBy, &v.Modified, &v.ModifiedBy, &v.Config, &v.Ext, &v.Envs,
 		); err != nil {
 			return nil, fmt.Errorf("%s - GetVersionsByCapabilityIDs scan failed: %w", repoLogPrefix, err)
 		}
@@ -263,12 +292,60 @@ func (r *Repository) GetDefaultsBatch(ctx context.Context, capabilityIDs []strin
 	return result, nil
 }
 
+// ListDefaults returns the default majors of every env per capability, keyed by capability_id.
+func (r *Repository) ListDefaults(ctx context.Context, capabilityIDs []string) (map[string][]CapabilityDefault, error) {
+	if len(capabilityIDs) == 0 {
+		return map[string][]CapabilityDefault{}, nil
+	}
+	rows, err := r.pool.Query(ctx,
+		`SELECT id, capability_id, default_major, env, object, created, created_by, modified, modified_by, config, ext
+		 FROM capability_defaults
+		 WHERE capability_id = ANY($1)
+		 ORDER BY env ASC`, capabilityIDs)
+	if err != nil {
+		return nil, fmt.Errorf("%s - ListDefaults failed: %w", repoLogPrefix, err)
+	}
+	defer rows.Close()
+
+	result := make(map[string][]CapabilityDefault)
+	for rows.Next() {
+		var d CapabilityDefault
+		if err := rows.Scan(
+			&d.ID, &d.CapabilityID, &d.DefaultMajor, &d.Env,
+			&d.Object, &d.Created, &d.CreatedBy, &d.Modified, &d.ModifiedBy, &d.Config, &d.Ext,
+		); err != nil {
+			return nil, fmt.Errorf("%s - ListDefaults scan failed: %w", repoLogPrefix, err)
+		}
+		result[d.CapabilityID] = append(result[d.CapabilityID], d)
+	}
+	return result, rows.Err()
+}
+
+// ListDefaultEnvs returns every env that has a default major for some capability, sorted.
+func (r *Repository) ListDefaultEnvs(ctx context.Context) ([]string, error) {
+	rows, err := r.pool.Query(ctx, `SELECT DISTINCT env FROM capability_defaults ORDER BY env`)
+	if err != nil {
+		return nil, fmt.Errorf("%s - ListDefaultEnvs failed: %w", repoLogPrefix, err)
+	}
+	defer rows.Close()
+
+	var envs []string
+	for rows.Next() {
+		var env string
+		if err := rows.Scan(&env); err != nil {
+			return nil, fmt.Errorf("%s - ListDefaultEnvs scan failed: %w", repoLogPrefix, err)
+		}
+		envs = append(envs, env)
+	}
+	return envs, rows.Err()
+}
+
 // GetVersionsByMajor returns versions for a specific major, ordered descending.
 func (r *Repository) GetVersionsByMajor(ctx context.Context, capabilityID string, major int) ([]CapabilityVersion, error) {
 	rows, err := r.pool.Query(ctx,
 		`SELECT id, capability_id, major, minor, patch, prerelease, build_metadata,
 		        version_string, status, deprecation_reason, deprecated_at, disabled_at,
-		        description, changelog, metadata, object, created, created_by, modified, modified_by, config, ext
+		        description, changelog, metadata, object, created, created_by, modified, modified_by, config, ext, envs
 		 FROM capability_versions
 		 WHERE capability_id = $1 AND major = $2
 		 ORDER BY minor DESC, patch DESC`, capabilityID, major)
@@ -284,7 +361,7 @@ func (r *Repository) GetVersionsByMajor(ctx context.Context, capabilityID string
 func (r *Repository) GetVersion(ctx context.Context, params GetVersionParams) (*CapabilityVersion, error) {
 	query := `SELECT id, capability_id, major, minor, patch, prerelease, build_metadata,
 	                 version_string, status, deprecation_reason, deprecated_at, disabled_at,
-	                 description, changelog, metadata, object, created, created_by, modified, modified_by, config, ext
+	                 description, changelog, metadata, object, created, created_by, modified, modified_by, config, ext, envs
 	          FROM capability_versions
 	          WHERE capability_id = $1 AND major = $2 AND minor = $3 AND patch = $4`
 	args := []interface{}{params.CapabilityID, params.Major, params.Minor, params.Patch}
@@ -322,8 +399,8 @@ func (r *Repository) UpsertVersion(ctx context.Context, params UpsertVersionPara
 
 	row := r.pool.QueryRow(ctx,
 		`INSERT INTO capability_versions
-		   (capability_id, major, minor, patch, prerelease, description, changelog, metadata, created_by, modified_by, created, modified)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9, $10, $10)
+		   (capability_id, major, minor, patch, prerelease, description, changelog, metadata, created_by, modified_by, created, modified, envs)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9, $10, $10, $11)
 		 ON CONFLICT (capability_id, major, minor, patch, prerelease) DO UPDATE SET
 		   description = COALESCE($6, capability_versions.description),
 		   changelog = COALESCE($7, capability_versions.changelog),
@@ -332,10 +409,10 @@ func (r *Repository) UpsertVersion(ctx context.Context, params UpsertVersionPara
 		   modified_by = $9
 		 RETURNING id, capability_id, major, minor, patch, prerelease, build_metadata,
 		           version_string, status, deprecation_reason, deprecated_at, disabled_at,
-		           description, changelog, metadata, object, created, created_by, modified, modified_by, config, ext`,
+		           description, changelog, metadata, object, created, created_by, modified, modified_by, config, ext, envs`,
 		params.CapabilityID, params.Major, params.Minor, params.Patch,
 		params.Prerelease, params.Description, params.Changelog,
-		metadataJSON, params.UserID, now)
+		metadataJSON, params.UserID, now, params.Envs)
 
 	return scanVersion(row)
 }
@@ -350,7 +427,10 @@ type UpsertVersionParams struct {
 	Description  *string
 	Changelog    *string
 	Metadata     map[string]interface{}
-	UserID       string
+	// Envs restricts a newly created version to these environments; nil means all.
+	// Availability of an existing version is only changed by PromoteVersion.
+	Envs   []string
+	UserID string
 }
 
 // UpdateVersionStatus updates the status of a version.
@@ -377,7 +457,7 @@ func (r *Repository) UpdateVersionStatus(ctx context.Context, params UpdateVersi
 
 	query += ` RETURNING id, capability_id, major, minor, patch, prerelease, build_metadata,
 	           version_string, status, deprecation_reason, deprecated_at, disabled_at,
-	           description, changelog, metadata, object, created, created_by, modified, modified_by, config, ext`
+	           description, changelog, metadata, object, created, created_by, modified, modified_by, config, ext, envs`
 
 	row := r.pool.QueryRow(ctx, query, args...)
 	return scanVersion(row)
@@ -619,7 +699,30 @@ func (r *Repository) CheckTenantAccess(ctx context.Context, capabilityID string,
 		slog.Error(fmt.Sprintf("%s - CheckTenantAccess failed to get rules: %v", repoLogPrefix, err))
 		return false, "Tenant access check unavailable"
 	}
+	return EvaluateTenantRules(rules, major, rctx)
+}
+
+// MatchTenantRules returns the rules GetTenantRules would select for rctx, ordered by
+// priority, for stores that keep tenant rules outside Postgres.
+func MatchTenantRules(rules []CapabilityTenantRule, rctx ResolutionContext) []CapabilityTenantRule {
+	var out []CapabilityTenantRule
+	for _, rule := range rules {
+		if rctx.TenantID != "" && rule.TenantID != nil && !strings.EqualFold(*rule.TenantID, rctx.TenantID) {
+			continue
+		}
+		if rctx.Env != "" && rule.Env != nil && *rule.Env != rctx.Env {
+			continue
+		}
+		out = append(out, rule)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Priority < out[j].Priority })
+	return out
+}
 
+// EvaluateTenantRules checks major against rules in order: a deny rule blocks its denied
+// majors (all when none are listed), an allow rule blocks majors outside its allowed list.
+// Rules whose required features the caller lacks are skipped.
+func EvaluateTenantRules(rules []CapabilityTenantRule, major int, rctx ResolutionContext) (bool, string) {
 	for _, rule := range rules {
 		// Check feature requirements
 		if len(rule.RequiredFeatures) > 0 {
@@ -658,6 +761,75 @@ func (r *Repository) CheckTenantAccess(ctx context.Context, capabilityID string,
 	return true, ""
 }
 
+// ListTenantRules returns every tenant rule of the given capabilities ordered by priority,
+// keyed by capability ID.
+func (r *Repository) ListTenantRules(ctx context.Context, capabilityIDs []string) (map[string][]CapabilityTenantRule, error) {
+	out := make(map[string][]CapabilityTenantRule)
+	if len(capabilityIDs) == 0 {
+		return out, nil
+	}
+	rows, err := r.pool.Query(ctx,
+		`SELECT id, capability_id, tenant_id, env, aud, rule_type,
+		        allowed_majors, denied_majors, required_features, priority,
+		        object, status, created_by, modified_by
+		 FROM capability_tenant_rules
+		 WHERE capability_id = ANY($1)
+		 ORDER BY priority ASC, created ASC`, capabilityIDs)
+	if err != nil {
+		return nil, fmt.Errorf("%s - ListTenantRules failed: %w", repoLogPrefix, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rule CapabilityTenantRule
+		if err := rows.Scan(
+			&rule.ID, &rule.CapabilityID, &rule.TenantID, &rule.Env, &rule.Aud,
+			&rule.RuleType, &rule.AllowedMajors, &rule.DeniedMajors,
+			&rule.RequiredFeatures, &rule.Priority, &rule.Object, &rule.Status,
+			&rule.CreatedBy, &rule.ModifiedBy,
+		); err != nil {
+			return nil, fmt.Errorf("%s - ListTenantRules scan failed: %w", repoLogPrefix, err)
+		}
+		out[rule.CapabilityID] = append(out[rule.CapabilityID], rule)
+	}
+	return out, rows.Err()
+}
+
+// ReplaceTenantRules replaces all tenant rules of a capability in one transaction, for a
+// mirror copying the rules of its upstream. An empty list removes them.
+func (r *Repository) ReplaceTenantRules(ctx context.Context, capabilityID string, rules []CapabilityTenantRule, userID string) error {
+	slog.Info(fmt.Sprintf("%s - ReplaceTenantRules capabilityID=%s count=%d", repoLogPrefix, capabilityID, len(rules)))
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s - ReplaceTenantRules begin failed: %w", repoLogPrefix, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM capability_tenant_rules WHERE capability_id = $1`, capabilityID); err != nil {
+		return fmt.Errorf("%s - ReplaceTenantRules delete failed: %w", repoLogPrefix, err)
+	}
+	now := time.Now().UTC()
+	for _, rule := range rules {
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO capability_tenant_rules
+			   (capability_id, tenant_id, env, aud, rule_type, allowed_majors, denied_majors,
+			    required_features, priority, created_by, modified_by, created, modified)
+			 VALUES ($1, $2, $3, $4, $5, COALESCE($6::int[], '{}'), COALESCE($7::int[], '{}'),
+			         COALESCE($8::text[], '{}'), $9, $10, $10, $11, $11)`,
+			capabilityID, rule.TenantID, rule.Env, rule.Aud, rule.RuleType, rule.AllowedMajors,
+			rule.DeniedMajors, rule.RequiredFeatures, rule.Priority, userID, now,
+		); err != nil {
+			return fmt.Errorf("%s - ReplaceTenantRules insert failed: %w", repoLogPrefix, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s - ReplaceTenantRules commit failed: %w", repoLogPrefix, err)
+	}
+	return nil
+}
+
 // =========================================================================
 // INCREMENT REVISION
 // =========================================================================
@@ -684,7 +856,7 @@ func scanCapability(row pgx.Row) (*Capability, error) {
 	var c Capability
 	err := row.Scan(
 		&c.ID, &c.App, &c.Name, &c.Description, &c.Tags, &c.Status, &c.Object, &c.Revision,
-		&c.Created, &c.CreatedBy, &c.Modified, &c.ModifiedBy, &c.Config, &c.Ext,
+		&c.Created, &c.CreatedBy, &c.Modified, &c.ModifiedBy, &c.Config, &c.Ext, &c.SubjectTemplate, &c.LivenessPolicy, &c.Source,
 	)
 	if err == pgx.ErrNoRows {
 		return nil, nil
@@ -699,7 +871,7 @@ func scanCapabilityFromRows(rows pgx.Rows) (*Capability, error) {
 	var c Capability
 	err := rows.Scan(
 		&c.ID, &c.App, &c.Name, &c.Description, &c.Tags, &c.Status, &c.Object, &c.Revision,
-		&c.Created, &c.CreatedBy, &c.Modified, &c.ModifiedBy, &c.Config, &c.Ext,
+		&c.Created, &c.CreatedBy, &c.Modified, &c.ModifiedBy, &c.Config, &c.Ext, &c.SubjectTemplate, &c.LivenessPolicy, &c.Source,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("%s - scan capability from rows failed: %w", repoLogPrefix, err)
@@ -714,7 +886,7 @@ func scanVersion(row pgx.Row) (*CapabilityVersion, error) {
 		&v.Prerelease, &v.BuildMetadata, &v.VersionString,
 		&v.Status, &v.DeprecationReason, &v.DeprecatedAt, &v.DisabledAt,
 		&v.Description, &v.Changelog, &v.Metadata,
-		&v.Object, &v.Created, &v.CreatedBy, &v.Modified, &v.ModifiedBy, &v.Config, &v.Ext,
+		&v.Object, &v.Created, &v.CreatedBy, &v.Modified, &v.ModifiedBy, &v.Config, &v.Ext, &v.Envs,
 	)
 	if err == pgx.ErrNoRows {
 		return nil, nil
@@ -734,7 +906,7 @@ func scanVersions(rows pgx.Rows) ([]CapabilityVersion, error) {
 			&v.Prerelease, &v.BuildMetadata, &v.VersionString,
 			&v.Status, &v.DeprecationReason, &v.DeprecatedAt, &v.DisabledAt,
 			&v.Description, &v.Changelog, &v.Metadata,
-			&v.Object, &v.Created, &v.CreatedBy, &v.Modified, &v.ModifiedBy, &v.Config, &v.Ext,
+			&v.Object, &v.Created, &v.CreatedBy, &v.Modified, &v.ModifiedBy, &v.Config, &v.Ext, &v.Envs,
 		); err != nil {
 			return nil, fmt.Errorf("%s - scan versions failed: %w", repoLogPrefix, err)
 		}
@@ -752,27 +924,43 @@ type BootstrapEntry struct {
 	VersionString string
 	VersionStatus string
 	VersionID     string
+	Minor         int
+	// SubjectTemplate is the capability's subject template ("" = registry default).
+	SubjectTemplate string
 }
 
 // ListBootstrapEntries returns all capabilities that have a default version for the given env,
 // with that version's version_string, status, and version_id (for loading methods). Used to build bootstrap response from DB.
 func (r *Repository) ListBootstrapEntries(ctx context.Context, env string) ([]BootstrapEntry, error) {
+	return r.listBootstrapEntries(ctx, env, "", "")
+}
+
+// ListBootstrapEntriesFor returns the bootstrap entry of one capability for env: none when
+// the capability has no default version there.
+func (r *Repository) ListBootstrapEntriesFor(ctx context.Context, env, app, name string) ([]BootstrapEntry, error) {
+	return r.listBootstrapEntries(ctx, env, app, name)
+}
+
+// listBootstrapEntries lists the bootstrap entries of env, restricted to app.name unless app is empty.
+func (r *Repository) listBootstrapEntries(ctx context.Context, env, app, name string) ([]BootstrapEntry, error) {
 	query := `
 WITH def AS (
   SELECT capability_id, default_major FROM capability_defaults WHERE env = $1
 ),
 latest_ver AS (
-  SELECT DISTINCT ON (capability_id, major) id, capability_id, major,
+  SELECT DISTINCT ON (capability_id, major) id, capability_id, major, minor,
          COALESCE(version_string, major::text || '.0.0') AS version_string, status
   FROM capability_versions
+  WHERE envs IS NULL OR $1 = ANY(envs)
   ORDER BY capability_id, major, minor DESC, patch DESC
 )
 SELECT c.app, c.name, COALESCE(c.description, ''), d.default_major,
-       lv.version_string, lv.status, lv.id AS version_id
+       lv.version_string, lv.status, lv.id AS version_id, lv.minor, COALESCE(c.subject_template, '')
 FROM capabilities c
 JOIN def d ON d.capability_id = c.id
-JOIN latest_ver lv ON lv.capability_id = c.id AND lv.major = d.default_major`
-	rows, err := r.pool.Query(ctx, query, env)
+JOIN latest_ver lv ON lv.capability_id = c.id AND lv.major = d.default_major
+WHERE $2 = '' OR (c.app = $2 AND c.name = $3)`
+	rows, err := r.pool.Query(ctx, query, env, app, name)
 	if err != nil {
 		return nil, fmt.Errorf("%s - ListBootstrapEntries failed: %w", repoLogPrefix, err)
 	}
@@ -781,7 +969,7 @@ JOIN latest_ver lv ON lv.capability_id = c.id AND lv.major = d.default_major`
 	for rows.Next() {
 		var e BootstrapEntry
 		if err := rows.Scan(&e.App, &e.Name, &e.Description, &e.DefaultMajor,
-			&e.VersionString, &e.VersionStatus, &e.VersionID); err != nil {
+			&e.VersionString, &e.VersionStatus, &e.VersionID, &e.Minor, &e.SubjectTemplate); err != nil {
 			return nil, fmt.Errorf("%s - ListBootstrapEntries scan failed: %w", repoLogPrefix, err)
 		}
 		out = append(out, e)
@@ -11,6 +11,7 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/morezero/capabilities-registry/pkg/bootstrap"
+	"github.com/morezero/capabilities-registry/pkg/commsutil"
 )
 
 const seedBootstrapLogPrefix = "db:seed_bootstrap"
@@ -46,19 +47,20 @@ func SeedBootstrap(ctx context.Context, pool *pgxpool.Pool, bootstrapFilePath st
 			continue
 		}
 
-		// 1. Insert or update capability (sync description)
+		// 1. Insert or update capability (sync description and subject)
 		var capID string
 		desc := cap.Description
 		status := "Active"
 		err := tx.QueryRow(ctx,
-			`INSERT INTO capabilities (app, name, description, tags, status, created_by, modified_by)
-			 VALUES ($1, $2, $3, '{}', $4, $5::uuid, $5::uuid)
+			`INSERT INTO capabilities (app, name, description, tags, status, created_by, modified_by, subject_template)
+			 VALUES ($1, $2, $3, '{}', $4, $5::uuid, $5::uuid, $6)
 			 ON CONFLICT (app, name) DO UPDATE SET
 			   description = COALESCE(EXCLUDED.description, capabilities.description),
+			   subject_template = COALESCE(EXCLUDED.subject_template, capabilities.subject_template),
 			   modified = NOW(),
 			   modified_by = EXCLUDED.modified_by
 			 RETURNING id`,
-			app, name, desc, status, systemUserID).Scan(&capID)
+			app, name, desc, status, systemUserID, bootstrapSubjectTemplate(app, name, cap)).Scan(&capID)
 		if err != nil {
 			return fmt.Errorf("%s - insert capability %s: %w", seedBootstrapLogPrefix, capRef, err)
 		}
@@ -150,6 +152,51 @@ func SeedBootstrap(ctx context.Context, pool *pgxpool.Pool, bootstrapFilePath st
 	return nil
 }
 
+// SeedBootstrapSubjects stores the subject of each bootstrap capability as its subject
+// template when it differs from the default scheme, so resolve and bootstrap answer with
+// the subject the bootstrap config declares. Only capabilities already in the DB are updated.
+func SeedBootstrapSubjects(ctx context.Context, pool *pgxpool.Pool, caps map[string]bootstrap.BootstrapCapability) error {
+	for capRef, cap := range caps {
+		app, name := parseCapRef(capRef)
+		if app == "" || name == "" {
+			continue
+		}
+		tmpl := bootstrapSubjectTemplate(app, name, cap)
+		if tmpl == nil {
+			continue
+		}
+		if _, err := pool.Exec(ctx,
+			`UPDATE capabilities SET subject_template = $3
+			 WHERE app = $1 AND name = $2 AND subject_template IS DISTINCT FROM $3`,
+			app, name, *tmpl,
+		); err != nil {
+			return fmt.Errorf("%s - seed subject %s: %w", seedBootstrapLogPrefix, capRef, err)
+		}
+	}
+	return nil
+}
+
+// bootstrapSubjectTemplate returns the bootstrap subject as a (literal) subject template,
+// or nil when it is empty, invalid, or what the default template already produces.
+func bootstrapSubjectTemplate(app, name string, cap bootstrap.BootstrapCapability) *string {
+	if cap.Subject == "" {
+		return nil
+	}
+	major := cap.Major
+	if major < 1 {
+		major = 1
+	}
+	if cap.Subject == commsutil.BuildCapabilitySubject(app, name, major) {
+		return nil
+	}
+	if err := commsutil.ValidateSubjectTemplate(cap.Subject); err != nil {
+		slog.Warn(fmt.Sprintf("%s - ignore subject for %s.%s: %v", seedBootstrapLogPrefix, app, name, err))
+		return nil
+	}
+	subject := cap.Subject
+	return &subject
+}
+
 // parseCapRef splits "app.name" into app and name (e.g. "system.registry" -> "system", "registry").
 func parseCapRef(capRef string) (app, name string) {
 	parts := strings.SplitN(capRef, ".", 2)
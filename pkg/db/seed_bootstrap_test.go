@@ -0,0 +1,37 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/morezero/capabilities-registry/pkg/bootstrap"
+)
+
+const seedBootstrapTestPrefix = "db:seed_bootstrap_test"
+
+func TestBootstrapSubjectTemplate(t *testing.T) {
+	tests := []struct {
+		name string
+		cap  bootstrap.BootstrapCapability
+		want string
+	}{
+		{"empty subject", bootstrap.BootstrapCapability{Major: 1}, ""},
+		{"matches default", bootstrap.BootstrapCapability{Subject: "cap.system.registry.v1", Major: 1}, ""},
+		{"custom subject", bootstrap.BootstrapCapability{Subject: "svc.system.registry.v1", Major: 1}, "svc.system.registry.v1"},
+		{"invalid subject", bootstrap.BootstrapCapability{Subject: "svc.system.*", Major: 1}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := bootstrapSubjectTemplate("system", "registry", tt.cap)
+			if tt.want == "" {
+				if got != nil {
+					t.Errorf("%s - expected nil, got %q", seedBootstrapTestPrefix, *got)
+				}
+				return
+			}
+			if got == nil || *got != tt.want {
+				t.Errorf("%s - got %v, want %q", seedBootstrapTestPrefix, got, tt.want)
+			}
+		})
+	}
+}
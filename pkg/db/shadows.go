@@ -0,0 +1,112 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const shadowsLogPrefix = "db:shadows"
+
+const shadowColumns = `id, capability_id, target_version, target_major, sample_rate, tenant_ids,
+	        object, created, created_by, modified, modified_by`
+
+// GetShadow returns the shadow config for a capability. Returns nil, nil when none is set.
+func (r *Repository) GetShadow(ctx context.Context, capabilityID string) (*CapabilityShadow, error) {
+	row := r.pool.QueryRow(ctx,
+		`SELECT `+shadowColumns+`
+		 FROM capability_shadows
+		 WHERE capability_id = $1`, capabilityID)
+	s, err := scanShadow(row)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s - GetShadow failed: %w", shadowsLogPrefix, err)
+	}
+	return s, nil
+}
+
+// ListShadows returns the shadow configs of the given capabilities keyed by capability ID.
+// Capabilities without one are absent from the map.
+func (r *Repository) ListShadows(ctx context.Context, capabilityIDs []string) (map[string]*CapabilityShadow, error) {
+	out := make(map[string]*CapabilityShadow)
+	if len(capabilityIDs) == 0 {
+		return out, nil
+	}
+	rows, err := r.pool.Query(ctx,
+		`SELECT `+shadowColumns+`
+		 FROM capability_shadows
+		 WHERE capability_id = ANY($1)`, capabilityIDs)
+	if err != nil {
+		return nil, fmt.Errorf("%s - ListShadows failed: %w", shadowsLogPrefix, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		s, err := scanShadow(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s - ListShadows scan failed: %w", shadowsLogPrefix, err)
+		}
+		out[s.CapabilityID] = s
+	}
+	return out, rows.Err()
+}
+
+// UpsertShadowParams holds parameters for UpsertShadow.
+type UpsertShadowParams struct {
+	CapabilityID  string
+	TargetVersion *string
+	TargetMajor   *int
+	SampleRate    float64
+	TenantIDs     []string
+	UserID        string
+}
+
+// UpsertShadow creates or replaces the shadow config for a capability.
+func (r *Repository) UpsertShadow(ctx context.Context, params UpsertShadowParams) (*CapabilityShadow, error) {
+	slog.Info(fmt.Sprintf("%s - UpsertShadow capabilityID=%s rate=%g", shadowsLogPrefix, params.CapabilityID, params.SampleRate))
+
+	now := time.Now().UTC()
+	row := r.pool.QueryRow(ctx,
+		`INSERT INTO capability_shadows
+		   (capability_id, target_version, target_major, sample_rate, tenant_ids, created_by, modified_by, created, modified)
+		 VALUES ($1, $2, $3, $4, $5, $6, $6, $7, $7)
+		 ON CONFLICT (capability_id) DO UPDATE SET
+		   target_version = $2,
+		   target_major = $3,
+		   sample_rate = $4,
+		   tenant_ids = $5,
+		   modified = $7,
+		   modified_by = $6
+		 RETURNING `+shadowColumns,
+		params.CapabilityID, params.TargetVersion, params.TargetMajor, params.SampleRate, params.TenantIDs, params.UserID, now)
+	s, err := scanShadow(row)
+	if err != nil {
+		return nil, fmt.Errorf("%s - UpsertShadow failed: %w", shadowsLogPrefix, err)
+	}
+	return s, nil
+}
+
+// DeleteShadow removes the shadow config for a capability. Returns false when none existed.
+func (r *Repository) DeleteShadow(ctx context.Context, capabilityID string) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM capability_shadows WHERE capability_id = $1`, capabilityID)
+	if err != nil {
+		return false, fmt.Errorf("%s - DeleteShadow failed: %w", shadowsLogPrefix, err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func scanShadow(row pgx.Row) (*CapabilityShadow, error) {
+	var s CapabilityShadow
+	if err := row.Scan(
+		&s.ID, &s.CapabilityID, &s.TargetVersion, &s.TargetMajor, &s.SampleRate, &s.TenantIDs,
+		&s.Object, &s.Created, &s.CreatedBy, &s.Modified, &s.ModifiedBy,
+	); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
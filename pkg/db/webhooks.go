@@ -0,0 +1,307 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const webhooksLogPrefix = "db:webhooks"
+
+const webhookColumns = `w.id, w.name, w.url, w.secret, w.app, w.capability, w.changed_fields,
+	        w.enabled, w.consecutive_failures, w.disabled_reason, w.description,
+	        w.object, w.created, w.created_by, w.modified, w.modified_by`
+
+const webhookDeliveryColumns = `d.id, d.webhook_id, d.seq, d.app, d.capability, d.payload, d.status,
+	        d.attempts, d.next_attempt, d.status_code, d.error, d.last_attempt, d.object, d.created`
+
+// GetWebhook returns a webhook by name. Returns nil, nil when it does not exist.
+func (r *Repository) GetWebhook(ctx context.Context, name string) (*Webhook, error) {
+	row := r.pool.QueryRow(ctx,
+		`SELECT `+webhookColumns+`
+		 FROM webhooks w
+		 WHERE w.name = $1`, name)
+	w, err := scanWebhook(row)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s - GetWebhook failed: %w", webhooksLogPrefix, err)
+	}
+	return w, nil
+}
+
+// ListWebhooks returns all webhooks ordered by name; with enabledOnly, only the enabled ones.
+func (r *Repository) ListWebhooks(ctx context.Context, enabledOnly bool) ([]Webhook, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT `+webhookColumns+`
+		 FROM webhooks w
+		 WHERE w.enabled OR NOT $1
+		 ORDER BY w.name ASC`, enabledOnly)
+	if err != nil {
+		return nil, fmt.Errorf("%s - ListWebhooks failed: %w", webhooksLogPrefix, err)
+	}
+	defer rows.Close()
+
+	var out []Webhook
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s - ListWebhooks scan failed: %w", webhooksLogPrefix, err)
+		}
+		out = append(out, *w)
+	}
+	return out, rows.Err()
+}
+
+// UpsertWebhookParams holds parameters for UpsertWebhook.
+type UpsertWebhookParams struct {
+	Name string
+	URL  string
+	// Secret replaces the signing key; empty keeps the existing one.
+	Secret        string
+	App           *string
+	Capability    *string
+	ChangedFields []string
+	Description   *string
+	UserID        string
+}
+
+// UpsertWebhook creates a webhook or replaces its URL, filter and description. It (re-)enables
+// the webhook and resets its failure count.
+func (r *Repository) UpsertWebhook(ctx context.Context, params UpsertWebhookParams) (*Webhook, error) {
+	slog.Info(fmt.Sprintf("%s - UpsertWebhook name=%s url=%s", webhooksLogPrefix, params.Name, params.URL))
+
+	changedFields := params.ChangedFields
+	if changedFields == nil {
+		changedFields = []string{}
+	}
+	now := time.Now().UTC()
+	row := r.pool.QueryRow(ctx,
+		`INSERT INTO webhooks AS w (name, url, secret, app, capability, changed_fields, description, created_by, modified_by, created, modified)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8, $9, $9)
+		 ON CONFLICT (name) DO UPDATE SET
+		   url = $2,
+		   secret = CASE WHEN $3 = '' THEN w.secret ELSE $3 END,
+		   app = $4,
+		   capability = $5,
+		   changed_fields = $6,
+		   description = $7,
+		   enabled = TRUE,
+		   consecutive_failures = 0,
+		   disabled_reason = NULL,
+		   modified = $9,
+		   modified_by = $8
+		 RETURNING `+webhookColumns,
+		params.Name, params.URL, params.Secret, params.App, params.Capability, changedFields, params.Description, params.UserID, now)
+	w, err := scanWebhook(row)
+	if err != nil {
+		return nil, fmt.Errorf("%s - UpsertWebhook failed: %w", webhooksLogPrefix, err)
+	}
+	return w, nil
+}
+
+// DeleteWebhook removes a webhook and its delivery log. Returns false when it did not exist.
+func (r *Repository) DeleteWebhook(ctx context.Context, name string) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM webhooks WHERE name = $1`, name)
+	if err != nil {
+		return false, fmt.Errorf("%s - DeleteWebhook failed: %w", webhooksLogPrefix, err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// EnqueueWebhookDeliveries adds a pending delivery of one change event to each webhook, due now.
+func (r *Repository) EnqueueWebhookDeliveries(ctx context.Context, webhookIDs []string, seq int64, app, capability string, payload []byte) error {
+	if _, err := r.pool.Exec(ctx,
+		`INSERT INTO webhook_deliveries (webhook_id, seq, app, capability, payload, next_attempt, created)
+		 SELECT id, $2, $3, $4, $5, $6, $6 FROM unnest($1::uuid[]) AS id`,
+		webhookIDs, seq, app, capability, payload, time.Now().UTC(),
+	); err != nil {
+		return fmt.Errorf("%s - EnqueueWebhookDeliveries failed: %w", webhooksLogPrefix, err)
+	}
+	return nil
+}
+
+// ClaimedWebhookDelivery is a due delivery with the URL and secret of its webhook.
+type ClaimedWebhookDelivery struct {
+	WebhookDelivery
+	URL    string
+	Secret string
+}
+
+// ClaimWebhookDeliveries returns up to limit pending deliveries of enabled webhooks that are
+// due, oldest first, and moves their next attempt to now+lease so no other replica claims
+// them meanwhile. A claim that is never completed is retried after the lease.
+func (r *Repository) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]ClaimedWebhookDelivery, error) {
+	now := time.Now().UTC()
+	rows, err := r.pool.Query(ctx,
+		`WITH due AS (
+		   SELECT d.id
+		   FROM webhook_deliveries d
+		   JOIN webhooks w ON w.id = d.webhook_id
+		   WHERE d.status = 'pending' AND d.next_attempt <= $2 AND w.enabled
+		   ORDER BY d.next_attempt ASC, d.seq ASC
+		   LIMIT $1
+		   FOR UPDATE OF d SKIP LOCKED
+		 )
+		 UPDATE webhook_deliveries d
+		 SET next_attempt = $3
+		 FROM due, webhooks w
+		 WHERE d.id = due.id AND w.id = d.webhook_id
+		 RETURNING `+webhookDeliveryColumns+`, w.url, w.secret`,
+		limit, now, now.Add(lease))
+	if err != nil {
+		return nil, fmt.Errorf("%s - ClaimWebhookDeliveries failed: %w", webhooksLogPrefix, err)
+	}
+	defer rows.Close()
+
+	var out []ClaimedWebhookDelivery
+	for rows.Next() {
+		var c ClaimedWebhookDelivery
+		if err := rows.Scan(
+			&c.ID, &c.WebhookID, &c.Seq, &c.App, &c.Capability, &c.Payload, &c.Status,
+			&c.Attempts, &c.NextAttempt, &c.StatusCode, &c.Error, &c.LastAttempt, &c.Object, &c.Created,
+			&c.URL, &c.Secret,
+		); err != nil {
+			return nil, fmt.Errorf("%s - ClaimWebhookDeliveries scan failed: %w", webhooksLogPrefix, err)
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// WebhookAttemptParams holds the outcome of one delivery attempt for RecordWebhookAttempt.
+type WebhookAttemptParams struct {
+	DeliveryID string
+	WebhookID  string
+	// Status is the delivery's new status: succeeded, failed (out of attempts), or pending
+	// to retry at NextAttempt.
+	Status      string
+	NextAttempt time.Time
+	StatusCode  *int
+	Error       *string
+	// DisableAfter disables the webhook once this many deliveries in a row failed; 0 never does.
+	DisableAfter int
+}
+
+// RecordWebhookAttempt stores the outcome of a delivery attempt. A succeeded delivery resets
+// the webhook's failure count; a failed one increments it and, at DisableAfter, disables the
+// webhook and cancels its pending deliveries. It reports whether the webhook was disabled.
+func (r *Repository) RecordWebhookAttempt(ctx context.Context, params WebhookAttemptParams) (bool, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("%s - begin tx: %w", webhooksLogPrefix, err)
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now().UTC()
+	tag, err := tx.Exec(ctx,
+		`UPDATE webhook_deliveries
+		 SET status = $2, attempts = attempts + 1, next_attempt = $3, status_code = $4, error = $5, last_attempt = $6
+		 WHERE id = $1 AND status = 'pending'`,
+		params.DeliveryID, params.Status, params.NextAttempt, params.StatusCode, params.Error, now)
+	if err != nil {
+		return false, fmt.Errorf("%s - RecordWebhookAttempt failed: %w", webhooksLogPrefix, err)
+	}
+	if tag.RowsAffected() == 0 {
+		// Completed or cancelled meanwhile
+		return false, nil
+	}
+
+	disabled := false
+	switch params.Status {
+	case "succeeded":
+		if _, err := tx.Exec(ctx,
+			`UPDATE webhooks SET consecutive_failures = 0 WHERE id = $1 AND consecutive_failures > 0`,
+			params.WebhookID); err != nil {
+			return false, fmt.Errorf("%s - reset failures failed: %w", webhooksLogPrefix, err)
+		}
+	case "failed":
+		if err := tx.QueryRow(ctx,
+			`UPDATE webhooks
+			 SET consecutive_failures = consecutive_failures + 1,
+			     enabled = enabled AND ($2 = 0 OR consecutive_failures + 1 < $2),
+			     disabled_reason = CASE WHEN enabled AND $2 > 0 AND consecutive_failures + 1 >= $2
+			       THEN format('%s deliveries in a row failed', consecutive_failures + 1)
+			       ELSE disabled_reason END,
+			     modified = $3
+			 WHERE id = $1
+			 RETURNING NOT enabled AND consecutive_failures = $2`,
+			params.WebhookID, params.DisableAfter, now).Scan(&disabled); err != nil {
+			return false, fmt.Errorf("%s - count failure failed: %w", webhooksLogPrefix, err)
+		}
+		if disabled {
+			if _, err := tx.Exec(ctx,
+				`UPDATE webhook_deliveries SET status = 'cancelled' WHERE webhook_id = $1 AND status = 'pending'`,
+				params.WebhookID); err != nil {
+				return false, fmt.Errorf("%s - cancel deliveries failed: %w", webhooksLogPrefix, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("%s - commit: %w", webhooksLogPrefix, err)
+	}
+	return disabled, nil
+}
+
+// ListWebhookDeliveries returns up to limit deliveries of a webhook, newest first.
+func (r *Repository) ListWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]WebhookDelivery, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT `+webhookDeliveryColumns+`
+		 FROM webhook_deliveries d
+		 WHERE d.webhook_id = $1
+		 ORDER BY d.created DESC, d.seq DESC
+		 LIMIT $2`, webhookID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s - ListWebhookDeliveries failed: %w", webhooksLogPrefix, err)
+	}
+	defer rows.Close()
+
+	var out []WebhookDelivery
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s - ListWebhookDeliveries scan failed: %w", webhooksLogPrefix, err)
+		}
+		out = append(out, *d)
+	}
+	return out, rows.Err()
+}
+
+// PruneWebhookDeliveries deletes finished deliveries created before the cutoff and returns
+// the number deleted. Pending deliveries are kept.
+func (r *Repository) PruneWebhookDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := r.pool.Exec(ctx,
+		`DELETE FROM webhook_deliveries WHERE created < $1 AND status <> 'pending'`, before)
+	if err != nil {
+		return 0, fmt.Errorf("%s - PruneWebhookDeliveries failed: %w", webhooksLogPrefix, err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+func scanWebhook(row pgx.Row) (*Webhook, error) {
+	var w Webhook
+	if err := row.Scan(
+		&w.ID, &w.Name, &w.URL, &w.Secret, &w.App, &w.Capability, &w.ChangedFields,
+		&w.Enabled, &w.ConsecutiveFailures, &w.DisabledReason, &w.Description,
+		&w.Object, &w.Created, &w.CreatedBy, &w.Modified, &w.ModifiedBy,
+	); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+func scanWebhookDelivery(row pgx.Row) (*WebhookDelivery, error) {
+	var d WebhookDelivery
+	if err := row.Scan(
+		&d.ID, &d.WebhookID, &d.Seq, &d.App, &d.Capability, &d.Payload, &d.Status,
+		&d.Attempts, &d.NextAttempt, &d.StatusCode, &d.Error, &d.LastAttempt, &d.Object, &d.Created,
+	); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
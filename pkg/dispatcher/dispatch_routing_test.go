@@ -183,6 +183,12 @@ func TestRegistryErrorToResponse_RegistryError(t *testing.T) {
 			message:       "Bad input",
 			wantRetryable: false,
 		},
+		{
+			name:          "REGISTRY_UNAVAILABLE is retryable",
+			code:          "REGISTRY_UNAVAILABLE",
+			message:       "Registry alias partner is unavailable",
+			wantRetryable: true,
+		},
 		{
 			name:          "FORBIDDEN is not retryable",
 			code:          "FORBIDDEN",
@@ -240,6 +246,7 @@ func TestInvCtxToResCtx_FullMapping(t *testing.T) {
 		Env:           "staging",
 		Aud:           "api",
 		Features:      []string{"beta", "preview"},
+		Release:       "release-2026.10",
 		Roles:         []string{"admin"},
 		DeadlineMs:    5000,
 		TimeoutMs:     3000,
@@ -262,6 +269,9 @@ func TestInvCtxToResCtx_FullMapping(t *testing.T) {
 	if resCtx.Features[0] != "beta" || resCtx.Features[1] != "preview" {
 		t.Errorf("dispatcher:dispatch_routing_test - Features = %v, want [beta, preview]", resCtx.Features)
 	}
+	if resCtx.Release != "release-2026.10" {
+		t.Errorf("dispatcher:dispatch_routing_test - Release = %q, want %q", resCtx.Release, "release-2026.10")
+	}
 }
 
 func TestInvCtxToResCtx_EmptyFields(t *testing.T) {
@@ -311,6 +321,38 @@ func TestDispatch_WithNilRepoRegistry(t *testing.T) {
 		{"discover", `{"page":1,"limit":10}`},
 		{"describe", `{"cap":"more0.test"}`},
 		{"listMajors", `{"cap":"more0.test"}`},
+		{"createRelease", `{"name":"release-2026.10","pins":[{"cap":"more0.test","version":"1.0.0"}]}`},
+		{"describeRelease", `{"name":"release-2026.10"}`},
+		{"listReleases", `{}`},
+		{"setCell", `{"name":"cell-eu","natsUrl":"nats://eu.example:4222"}`},
+		{"removeCell", `{"name":"cell-eu"}`},
+		{"listCells", `{}`},
+		{"assignTenantCell", `{"tenantId":"acme","cell":"cell-eu"}`},
+		{"removeTenantCell", `{"tenantId":"acme"}`},
+		{"registerInstance", `{"cap":"more0.test","version":"1.0.0","ttlSeconds":30}`},
+		{"heartbeat", `{"instanceId":"worker-1"}`},
+		{"deregisterInstance", `{"instanceId":"worker-1"}`},
+		{"probe", `{"cap":"more0.test","version":"1.0.0"}`},
+		{"addRegistry", `{"alias":"partner","natsUrl":"nats://partner.example:4222"}`},
+		{"updateRegistry", `{"alias":"partner","isDefault":false}`},
+		{"removeRegistry", `{"alias":"partner"}`},
+		{"listRegistries", `{}`},
+		{"addCapabilityAlias", `{"alias":"old.test","target":"more0.test"}`},
+		{"removeCapabilityAlias", `{"alias":"old.test"}`},
+		{"setShadow", `{"cap":"more0.test","targetMajor":2,"sampleRate":0.1}`},
+		{"getShadow", `{"cap":"more0.test"}`},
+		{"removeShadow", `{"cap":"more0.test"}`},
+		{"promote", `{"cap":"more0.test","version":"1.0.0","toEnv":"production"}`},
+		{"lock", `{"caps":["more0.test@^1"]}`},
+		{"verifyLock", `{"lock":{"lockfileVersion":1,"entries":[{"cap":"more0.test","version":"1.0.0"}]}}`},
+		{"exportCatalog", `{"page":1,"limit":50}`},
+		{"changesSince", `{"since":42,"limit":100}`},
+		{"watch", `{"refs":["more0.test@^1"],"inbox":"_INBOX.client.1"}`},
+		{"unwatch", `{"watchId":"4f1c2a"}`},
+		{"setWebhook", `{"name":"chat-ops","url":"https://hooks.example/registry","changedFields":["deprecated"]}`},
+		{"removeWebhook", `{"name":"chat-ops"}`},
+		{"listWebhooks", `{}`},
+		{"listWebhookDeliveries", `{"name":"chat-ops","limit":20}`},
 	}
 	for _, tt := range tests {
 		t.Run(tt.method, func(t *testing.T) {
@@ -330,6 +372,28 @@ func TestDispatch_WithNilRepoRegistry(t *testing.T) {
 	}
 }
 
+// TestDispatch_MirrorRejectsCatalogMutations verifies a mirror answers catalog mutations
+// with READ_ONLY and still serves reads and local instance registration.
+func TestDispatch_MirrorRejectsCatalogMutations(t *testing.T) {
+	cfg := registry.DefaultConfig()
+	cfg.MirrorUpstream = "upstream"
+	disp := NewDispatcher(registry.NewRegistry(registry.NewRegistryParams{Config: cfg}))
+	ctx := context.Background()
+
+	for _, method := range []string{"upsert", "deprecate", "promote", "setCell", "addCapabilityAlias", "createRelease"} {
+		resp := disp.Dispatch(ctx, &RegistryRequest{ID: "req-1", Method: method, Params: json.RawMessage(`{}`)})
+		if resp.Error == nil || resp.Error.Code != "READ_ONLY" || resp.Error.Retryable {
+			t.Errorf("dispatcher:dispatch_routing_test - %s on a mirror: error = %+v, want READ_ONLY", method, resp.Error)
+		}
+	}
+	for _, method := range []string{"resolve", "registerInstance", "exportCatalog"} {
+		resp := disp.Dispatch(ctx, &RegistryRequest{ID: "req-1", Method: method, Params: json.RawMessage(`{"cap":"more0.test"}`)})
+		if resp.Error == nil || resp.Error.Code == "READ_ONLY" {
+			t.Errorf("dispatcher:dispatch_routing_test - %s on a mirror: error = %+v, want it served (INTERNAL_ERROR with nil repo)", method, resp.Error)
+		}
+	}
+}
+
 // TestDispatch_Health_WithNilRepoRegistry verifies health returns Ok with unhealthy status when repo is nil.
 func TestDispatch_Health_WithNilRepoRegistry(t *testing.T) {
 	reg := registry.NewRegistry(registry.NewRegistryParams{
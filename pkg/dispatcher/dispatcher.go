@@ -11,6 +11,25 @@ import (
 
 const logPrefix = "dispatcher:dispatch"
 
+// catalogMutations are the methods a mirror rejects with READ_ONLY: its catalog is a copy
+// of the upstream registry's. Instances, probes, registry aliases and lockfiles stay local.
+var catalogMutations = map[string]bool{
+	"upsert":                true,
+	"setDefaultMajor":       true,
+	"deprecate":             true,
+	"disable":               true,
+	"promote":               true,
+	"setShadow":             true,
+	"removeShadow":          true,
+	"addCapabilityAlias":    true,
+	"removeCapabilityAlias": true,
+	"setCell":               true,
+	"removeCell":            true,
+	"assignTenantCell":      true,
+	"removeTenantCell":      true,
+	"createRelease":         true,
+}
+
 // Dispatcher routes COMMS requests to registry methods.
 type Dispatcher struct {
 	registry *registry.Registry
@@ -25,11 +44,20 @@ func NewDispatcher(reg *registry.Registry) *Dispatcher {
 func (d *Dispatcher) Dispatch(ctx context.Context, req *RegistryRequest) *RegistryResponse {
 	slog.Debug(fmt.Sprintf("%s - method=%s id=%s", logPrefix, req.Method, req.ID))
 
+	// Requests forwarded by another registry carry their trace into any further forward
+	ctx = registry.WithFederationTrace(ctx, req.Federation)
+
 	// Extract userID from context
 	userID := "system"
 	if req.Ctx != nil && req.Ctx.UserID != "" {
 		userID = req.Ctx.UserID
 	}
+	// Change events published while serving the request name its user and ID
+	ctx = registry.WithActor(ctx, userID, req.ID)
+
+	if catalogMutations[req.Method] && d.registry.ReadOnly() {
+		return errorResponse(req.ID, "READ_ONLY", fmt.Sprintf("%s is not allowed on a mirror; send it to the upstream registry", req.Method), false)
+	}
 
 	switch req.Method {
 	case "resolve":
@@ -46,10 +74,74 @@ func (d *Dispatcher) Dispatch(ctx context.Context, req *RegistryRequest) *Regist
 		return d.handleDeprecate(ctx, req, userID)
 	case "disable":
 		return d.handleDisable(ctx, req, userID)
+	case "promote":
+		return d.handlePromote(ctx, req, userID)
+	case "setShadow":
+		return d.handleSetShadow(ctx, req, userID)
+	case "getShadow":
+		return d.handleGetShadow(ctx, req)
+	case "removeShadow":
+		return d.handleRemoveShadow(ctx, req)
+	case "addCapabilityAlias":
+		return d.handleAddCapabilityAlias(ctx, req, userID)
+	case "removeCapabilityAlias":
+		return d.handleRemoveCapabilityAlias(ctx, req)
+	case "setCell":
+		return d.handleSetCell(ctx, req, userID)
+	case "removeCell":
+		return d.handleRemoveCell(ctx, req)
+	case "listCells":
+		return d.handleListCells(ctx, req)
+	case "assignTenantCell":
+		return d.handleAssignTenantCell(ctx, req, userID)
+	case "removeTenantCell":
+		return d.handleRemoveTenantCell(ctx, req)
+	case "registerInstance":
+		return d.handleRegisterInstance(ctx, req, userID)
+	case "heartbeat":
+		return d.handleHeartbeat(ctx, req)
+	case "deregisterInstance":
+		return d.handleDeregisterInstance(ctx, req)
+	case "probe":
+		return d.handleProbe(ctx, req)
+	case "addRegistry":
+		return d.handleAddRegistry(ctx, req)
+	case "updateRegistry":
+		return d.handleUpdateRegistry(ctx, req)
+	case "removeRegistry":
+		return d.handleRemoveRegistry(ctx, req)
+	case "listRegistries":
+		return d.handleListRegistries(ctx, req)
 	case "listMajors":
 		return d.handleListMajors(ctx, req)
 	case "health":
 		return d.handleHealth(ctx, req)
+	case "createRelease":
+		return d.handleCreateRelease(ctx, req, userID)
+	case "describeRelease":
+		return d.handleDescribeRelease(ctx, req)
+	case "listReleases":
+		return d.handleListReleases(ctx, req)
+	case "lock":
+		return d.handleLock(ctx, req)
+	case "verifyLock":
+		return d.handleVerifyLock(ctx, req)
+	case "exportCatalog":
+		return d.handleExportCatalog(ctx, req)
+	case "changesSince":
+		return d.handleChangesSince(ctx, req)
+	case "watch":
+		return d.handleWatch(ctx, req)
+	case "unwatch":
+		return d.handleUnwatch(ctx, req)
+	case "setWebhook":
+		return d.handleSetWebhook(ctx, req, userID)
+	case "removeWebhook":
+		return d.handleRemoveWebhook(ctx, req)
+	case "listWebhooks":
+		return d.handleListWebhooks(ctx, req)
+	case "listWebhookDeliveries":
+		return d.handleListWebhookDeliveries(ctx, req)
 	default:
 		return &RegistryResponse{
 			ID: req.ID,
@@ -161,6 +253,243 @@ func (d *Dispatcher) handleDisable(ctx context.Context, req *RegistryRequest, us
 	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
 }
 
+func (d *Dispatcher) handlePromote(ctx context.Context, req *RegistryRequest, userID string) *RegistryResponse {
+	var input registry.PromoteInput
+	if err := json.Unmarshal(req.Params, &input); err != nil {
+		return errorResponse(req.ID, "INVALID_ARGUMENT", "Failed to parse promote params", false)
+	}
+
+	result, err := d.registry.Promote(ctx, &input, userID)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleSetShadow(ctx context.Context, req *RegistryRequest, userID string) *RegistryResponse {
+	var input registry.SetShadowInput
+	if err := json.Unmarshal(req.Params, &input); err != nil {
+		return errorResponse(req.ID, "INVALID_ARGUMENT", "Failed to parse setShadow params", false)
+	}
+
+	result, err := d.registry.SetShadow(ctx, &input, userID)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleGetShadow(ctx context.Context, req *RegistryRequest) *RegistryResponse {
+	var input registry.ShadowInput
+	if err := json.Unmarshal(req.Params, &input); err != nil {
+		return errorResponse(req.ID, "INVALID_ARGUMENT", "Failed to parse getShadow params", false)
+	}
+
+	result, err := d.registry.GetShadow(ctx, &input)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleRemoveShadow(ctx context.Context, req *RegistryRequest) *RegistryResponse {
+	var input registry.ShadowInput
+	if err := json.Unmarshal(req.Params, &input); err != nil {
+		return errorResponse(req.ID, "INVALID_ARGUMENT", "Failed to parse removeShadow params", false)
+	}
+
+	result, err := d.registry.RemoveShadow(ctx, &input)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleAddCapabilityAlias(ctx context.Context, req *RegistryRequest, userID string) *RegistryResponse {
+	var input registry.AddCapabilityAliasInput
+	if err := json.Unmarshal(req.Params, &input); err != nil {
+		return errorResponse(req.ID, "INVALID_ARGUMENT", "Failed to parse addCapabilityAlias params", false)
+	}
+
+	result, err := d.registry.AddCapabilityAlias(ctx, &input, userID)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleRemoveCapabilityAlias(ctx context.Context, req *RegistryRequest) *RegistryResponse {
+	var input registry.RemoveCapabilityAliasInput
+	if err := json.Unmarshal(req.Params, &input); err != nil {
+		return errorResponse(req.ID, "INVALID_ARGUMENT", "Failed to parse removeCapabilityAlias params", false)
+	}
+
+	result, err := d.registry.RemoveCapabilityAlias(ctx, &input)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleSetCell(ctx context.Context, req *RegistryRequest, userID string) *RegistryResponse {
+	var input registry.SetCellInput
+	if err := json.Unmarshal(req.Params, &input); err != nil {
+		return errorResponse(req.ID, "INVALID_ARGUMENT", "Failed to parse setCell params", false)
+	}
+
+	result, err := d.registry.SetCell(ctx, &input, userID)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleRemoveCell(ctx context.Context, req *RegistryRequest) *RegistryResponse {
+	var input registry.RemoveCellInput
+	if err := json.Unmarshal(req.Params, &input); err != nil {
+		return errorResponse(req.ID, "INVALID_ARGUMENT", "Failed to parse removeCell params", false)
+	}
+
+	result, err := d.registry.RemoveCell(ctx, &input)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleListCells(ctx context.Context, req *RegistryRequest) *RegistryResponse {
+	result, err := d.registry.ListCells(ctx)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleAssignTenantCell(ctx context.Context, req *RegistryRequest, userID string) *RegistryResponse {
+	var input registry.AssignTenantCellInput
+	if err := json.Unmarshal(req.Params, &input); err != nil {
+		return errorResponse(req.ID, "INVALID_ARGUMENT", "Failed to parse assignTenantCell params", false)
+	}
+
+	result, err := d.registry.AssignTenantCell(ctx, &input, userID)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleRemoveTenantCell(ctx context.Context, req *RegistryRequest) *RegistryResponse {
+	var input registry.RemoveTenantCellInput
+	if err := json.Unmarshal(req.Params, &input); err != nil {
+		return errorResponse(req.ID, "INVALID_ARGUMENT", "Failed to parse removeTenantCell params", false)
+	}
+
+	result, err := d.registry.RemoveTenantCell(ctx, &input)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleRegisterInstance(ctx context.Context, req *RegistryRequest, userID string) *RegistryResponse {
+	var input registry.RegisterInstanceInput
+	if err := json.Unmarshal(req.Params, &input); err != nil {
+		return errorResponse(req.ID, "INVALID_ARGUMENT", "Failed to parse registerInstance params", false)
+	}
+
+	result, err := d.registry.RegisterInstance(ctx, &input, userID)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleHeartbeat(ctx context.Context, req *RegistryRequest) *RegistryResponse {
+	var input registry.HeartbeatInput
+	if err := json.Unmarshal(req.Params, &input); err != nil {
+		return errorResponse(req.ID, "INVALID_ARGUMENT", "Failed to parse heartbeat params", false)
+	}
+
+	result, err := d.registry.Heartbeat(ctx, &input)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleDeregisterInstance(ctx context.Context, req *RegistryRequest) *RegistryResponse {
+	var input registry.DeregisterInstanceInput
+	if err := json.Unmarshal(req.Params, &input); err != nil {
+		return errorResponse(req.ID, "INVALID_ARGUMENT", "Failed to parse deregisterInstance params", false)
+	}
+
+	result, err := d.registry.DeregisterInstance(ctx, &input)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleProbe(ctx context.Context, req *RegistryRequest) *RegistryResponse {
+	var input registry.ProbeInput
+	if err := json.Unmarshal(req.Params, &input); err != nil {
+		return errorResponse(req.ID, "INVALID_ARGUMENT", "Failed to parse probe params", false)
+	}
+
+	result, err := d.registry.Probe(ctx, &input)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleAddRegistry(ctx context.Context, req *RegistryRequest) *RegistryResponse {
+	var input registry.AddRegistryInput
+	if err := json.Unmarshal(req.Params, &input); err != nil {
+		return errorResponse(req.ID, "INVALID_ARGUMENT", "Failed to parse addRegistry params", false)
+	}
+
+	result, err := d.registry.AddRegistry(ctx, &input)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleUpdateRegistry(ctx context.Context, req *RegistryRequest) *RegistryResponse {
+	var input registry.UpdateRegistryInput
+	if err := json.Unmarshal(req.Params, &input); err != nil {
+		return errorResponse(req.ID, "INVALID_ARGUMENT", "Failed to parse updateRegistry params", false)
+	}
+
+	result, err := d.registry.UpdateRegistry(ctx, &input)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleRemoveRegistry(ctx context.Context, req *RegistryRequest) *RegistryResponse {
+	var input registry.RemoveRegistryInput
+	if err := json.Unmarshal(req.Params, &input); err != nil {
+		return errorResponse(req.ID, "INVALID_ARGUMENT", "Failed to parse removeRegistry params", false)
+	}
+
+	result, err := d.registry.RemoveRegistry(ctx, &input)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleListRegistries(ctx context.Context, req *RegistryRequest) *RegistryResponse {
+	result, err := d.registry.ListRegistries(ctx)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
 func (d *Dispatcher) handleListMajors(ctx context.Context, req *RegistryRequest) *RegistryResponse {
 	var input registry.ListMajorsInput
 	if err := json.Unmarshal(req.Params, &input); err != nil {
@@ -179,6 +508,169 @@ func (d *Dispatcher) handleHealth(ctx context.Context, req *RegistryRequest) *Re
 	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
 }
 
+func (d *Dispatcher) handleCreateRelease(ctx context.Context, req *RegistryRequest, userID string) *RegistryResponse {
+	var input registry.CreateReleaseInput
+	if err := json.Unmarshal(req.Params, &input); err != nil {
+		return errorResponse(req.ID, "INVALID_ARGUMENT", "Failed to parse createRelease params", false)
+	}
+
+	result, err := d.registry.CreateRelease(ctx, &input, userID)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleDescribeRelease(ctx context.Context, req *RegistryRequest) *RegistryResponse {
+	var input registry.DescribeReleaseInput
+	if err := json.Unmarshal(req.Params, &input); err != nil {
+		return errorResponse(req.ID, "INVALID_ARGUMENT", "Failed to parse describeRelease params", false)
+	}
+
+	result, err := d.registry.DescribeRelease(ctx, &input)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleListReleases(ctx context.Context, req *RegistryRequest) *RegistryResponse {
+	result, err := d.registry.ListReleases(ctx)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleLock(ctx context.Context, req *RegistryRequest) *RegistryResponse {
+	var input registry.LockInput
+	if err := json.Unmarshal(req.Params, &input); err != nil {
+		return errorResponse(req.ID, "INVALID_ARGUMENT", "Failed to parse lock params", false)
+	}
+	// Inject context
+	if input.Ctx == nil && req.Ctx != nil {
+		input.Ctx = invCtxToResCtx(req.Ctx)
+	}
+
+	result, err := d.registry.Lock(ctx, &input)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleVerifyLock(ctx context.Context, req *RegistryRequest) *RegistryResponse {
+	var input registry.VerifyLockInput
+	if err := json.Unmarshal(req.Params, &input); err != nil {
+		return errorResponse(req.ID, "INVALID_ARGUMENT", "Failed to parse verifyLock params", false)
+	}
+
+	result, err := d.registry.VerifyLock(ctx, &input)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleExportCatalog(ctx context.Context, req *RegistryRequest) *RegistryResponse {
+	var input registry.ExportCatalogInput
+	if err := json.Unmarshal(req.Params, &input); err != nil {
+		return errorResponse(req.ID, "INVALID_ARGUMENT", "Failed to parse exportCatalog params", false)
+	}
+
+	result, err := d.registry.ExportCatalog(ctx, &input)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleChangesSince(ctx context.Context, req *RegistryRequest) *RegistryResponse {
+	var input registry.ChangesSinceInput
+	if err := json.Unmarshal(req.Params, &input); err != nil {
+		return errorResponse(req.ID, "INVALID_ARGUMENT", "Failed to parse changesSince params", false)
+	}
+
+	result, err := d.registry.ChangesSince(ctx, &input)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleWatch(ctx context.Context, req *RegistryRequest) *RegistryResponse {
+	var input registry.WatchInput
+	if err := json.Unmarshal(req.Params, &input); err != nil {
+		return errorResponse(req.ID, "INVALID_ARGUMENT", "Failed to parse watch params", false)
+	}
+
+	result, err := d.registry.Watch(ctx, &input)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleUnwatch(ctx context.Context, req *RegistryRequest) *RegistryResponse {
+	var input registry.UnwatchInput
+	if err := json.Unmarshal(req.Params, &input); err != nil {
+		return errorResponse(req.ID, "INVALID_ARGUMENT", "Failed to parse unwatch params", false)
+	}
+
+	result, err := d.registry.Unwatch(ctx, &input)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleSetWebhook(ctx context.Context, req *RegistryRequest, userID string) *RegistryResponse {
+	var input registry.SetWebhookInput
+	if err := json.Unmarshal(req.Params, &input); err != nil {
+		return errorResponse(req.ID, "INVALID_ARGUMENT", "Failed to parse setWebhook params", false)
+	}
+
+	result, err := d.registry.SetWebhook(ctx, &input, userID)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleRemoveWebhook(ctx context.Context, req *RegistryRequest) *RegistryResponse {
+	var input registry.RemoveWebhookInput
+	if err := json.Unmarshal(req.Params, &input); err != nil {
+		return errorResponse(req.ID, "INVALID_ARGUMENT", "Failed to parse removeWebhook params", false)
+	}
+
+	result, err := d.registry.RemoveWebhook(ctx, &input)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleListWebhooks(ctx context.Context, req *RegistryRequest) *RegistryResponse {
+	result, err := d.registry.ListWebhooks(ctx)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
+func (d *Dispatcher) handleListWebhookDeliveries(ctx context.Context, req *RegistryRequest) *RegistryResponse {
+	var input registry.ListWebhookDeliveriesInput
+	if err := json.Unmarshal(req.Params, &input); err != nil {
+		return errorResponse(req.ID, "INVALID_ARGUMENT", "Failed to parse listWebhookDeliveries params", false)
+	}
+
+	result, err := d.registry.ListWebhookDeliveries(ctx, &input)
+	if err != nil {
+		return registryErrorToResponse(req.ID, err)
+	}
+	return &RegistryResponse{ID: req.ID, Ok: true, Result: result}
+}
+
 // --- helpers ---
 
 func errorResponse(id, code, message string, retryable bool) *RegistryResponse {
@@ -195,7 +687,7 @@ func errorResponse(id, code, message string, retryable bool) *RegistryResponse {
 
 func registryErrorToResponse(id string, err error) *RegistryResponse {
 	if regErr, ok := err.(*registry.RegistryError); ok {
-		retryable := regErr.Code == "INTERNAL_ERROR"
+		retryable := regErr.Code == "INTERNAL_ERROR" || regErr.Code == "REGISTRY_UNAVAILABLE"
 		return &RegistryResponse{
 			ID: id,
 			Ok: false,
@@ -219,5 +711,6 @@ func invCtxToResCtx(invCtx *InvocationContext) *registry.ResolutionContext {
 		Env:      invCtx.Env,
 		Aud:      invCtx.Aud,
 		Features: invCtx.Features,
+		Release:  invCtx.Release,
 	}
 }
@@ -34,6 +34,18 @@ func TestRegistryRequest_Unmarshal(t *testing.T) {
 	}
 }
 
+func TestRegistryRequest_UnmarshalFederationTrace(t *testing.T) {
+	raw := `{"id": "fed-1", "method": "resolve", "params": {}, "federation": {"hops": 2, "visited": ["nats://a:4222/cap.system.registry.v1", "nats://b:4222/cap.system.registry.v1"]}}`
+
+	var req RegistryRequest
+	if err := json.Unmarshal([]byte(raw), &req); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if req.Federation == nil || req.Federation.Hops != 2 || len(req.Federation.Visited) != 2 {
+		t.Errorf("expected a trace of 2 hops, got %+v", req.Federation)
+	}
+}
+
 func TestRegistryResponse_Marshal(t *testing.T) {
 	resp := &RegistryResponse{
 		ID: "req-1",
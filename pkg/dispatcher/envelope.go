@@ -1,7 +1,11 @@
 // Package dispatcher routes incoming COMMS messages to registry methods.
 package dispatcher
 
-import "encoding/json"
+import (
+	"encoding/json"
+
+	"github.com/morezero/capabilities-registry/pkg/registry"
+)
 
 // RegistryRequest is the JSON envelope for incoming COMMS registry requests.
 type RegistryRequest struct {
@@ -11,6 +15,8 @@ type RegistryRequest struct {
 	Method string                 `json:"method"`
 	Params json.RawMessage        `json:"params"`
 	Ctx    *InvocationContext     `json:"ctx,omitempty"`
+	// Federation is set on requests forwarded by another registry.
+	Federation *registry.FederationTrace `json:"federation,omitempty"`
 }
 
 // RegistryResponse is the JSON envelope for COMMS registry responses.
@@ -38,6 +44,7 @@ type InvocationContext struct {
 	Env           string   `json:"env,omitempty"`
 	Aud           string   `json:"aud,omitempty"`
 	Features      []string `json:"features,omitempty"`
+	Release       string   `json:"release,omitempty"`
 	Roles         []string `json:"roles,omitempty"`
 	DeadlineMs    int      `json:"deadlineMs,omitempty"`
 	TimeoutMs     int      `json:"timeoutMs,omitempty"`
@@ -0,0 +1,145 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	comms "github.com/nats-io/nats.go"
+
+	"github.com/morezero/capabilities-registry/pkg/commsutil"
+)
+
+// CloudEventsMode selects how publishers encode change events.
+type CloudEventsMode string
+
+const (
+	// CloudEventsOff publishes the bare RegistryChangedEvent JSON (the default).
+	CloudEventsOff CloudEventsMode = ""
+	// CloudEventsStructured publishes a CloudEvents 1.0 JSON envelope whose data is the event.
+	CloudEventsStructured CloudEventsMode = "structured"
+	// CloudEventsBinary publishes the event JSON with the CloudEvents attributes in ce- headers.
+	CloudEventsBinary CloudEventsMode = "binary"
+)
+
+const (
+	// CloudEventsSpecVersion is the CloudEvents version of encoded change events.
+	CloudEventsSpecVersion = "1.0"
+	// DefaultCloudEventsSource is the source attribute when publishers are not given one.
+	DefaultCloudEventsSource = "/capabilities-registry"
+	// CloudEventsContentType is the content-type header of structured change events.
+	CloudEventsContentType = "application/cloudevents+json"
+
+	cloudEventsTypePrefix = "registry.changed"
+	jsonContentType       = "application/json"
+	contentTypeHeader     = "content-type"
+	cloudEventsHeader     = "ce-"
+)
+
+// CloudEvent is the structured-mode envelope of a change event.
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            string          `json:"time,omitempty"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// CloudEventType returns the CloudEvents type of event: "registry.changed.<kind>", or
+// "registry.changed" for an event without a kind.
+func CloudEventType(event *RegistryChangedEvent) string {
+	if event.Kind == "" {
+		return cloudEventsTypePrefix
+	}
+	return cloudEventsTypePrefix + "." + event.Kind
+}
+
+// changeEncoder encodes change events in the configured mode.
+type changeEncoder struct {
+	mode   CloudEventsMode
+	source string
+}
+
+func newChangeEncoder(mode CloudEventsMode, source string) changeEncoder {
+	if source == "" {
+		source = DefaultCloudEventsSource
+	}
+	return changeEncoder{mode: mode, source: source}
+}
+
+// encode returns the payload and headers of event; the bare event JSON has no headers.
+func (e changeEncoder) encode(event *RegistryChangedEvent) ([]byte, comms.Header, error) {
+	data, err := commsutil.EncodePayload(event)
+	if err != nil {
+		return nil, nil, err
+	}
+	header := comms.Header{}
+	switch e.mode {
+	case CloudEventsOff:
+		return data, nil, nil
+	case CloudEventsStructured:
+		envelope, err := commsutil.EncodePayload(CloudEvent{
+			SpecVersion:     CloudEventsSpecVersion,
+			ID:              changeMessageID(event),
+			Source:          e.source,
+			Type:            CloudEventType(event),
+			Subject:         event.App + "." + event.Capability,
+			Time:            event.Timestamp,
+			DataContentType: jsonContentType,
+			Data:            data,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		header.Set(contentTypeHeader, CloudEventsContentType)
+		return envelope, header, nil
+	case CloudEventsBinary:
+		header.Set(contentTypeHeader, jsonContentType)
+		header.Set(cloudEventsHeader+"specversion", CloudEventsSpecVersion)
+		header.Set(cloudEventsHeader+"id", changeMessageID(event))
+		header.Set(cloudEventsHeader+"source", e.source)
+		header.Set(cloudEventsHeader+"type", CloudEventType(event))
+		header.Set(cloudEventsHeader+"subject", event.App+"."+event.Capability)
+		if event.Timestamp != "" {
+			header.Set(cloudEventsHeader+"time", event.Timestamp)
+		}
+		return data, header, nil
+	}
+	return nil, nil, fmt.Errorf("unknown CloudEvents mode %q", e.mode)
+}
+
+// DecodeChangeEvent decodes a change event message in any of the publisher encodings: the
+// bare event JSON, a structured CloudEvent or a binary-mode CloudEvent.
+func DecodeChangeEvent(msg *comms.Msg) (*RegistryChangedEvent, error) {
+	data := msg.Data
+	if isStructuredCloudEvent(msg) {
+		var envelope CloudEvent
+		if err := json.Unmarshal(msg.Data, &envelope); err != nil {
+			return nil, err
+		}
+		if envelope.SpecVersion == "" || len(envelope.Data) == 0 {
+			return nil, fmt.Errorf("CloudEvent without specversion or data")
+		}
+		data = envelope.Data
+	}
+	var event RegistryChangedEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// isStructuredCloudEvent reports whether msg carries a structured CloudEvent: by its
+// content-type header, or for header-less messages by a specversion member.
+func isStructuredCloudEvent(msg *comms.Msg) bool {
+	if contentType := msg.Header.Get(contentTypeHeader); contentType != "" {
+		return strings.HasPrefix(contentType, CloudEventsContentType)
+	}
+	var probe struct {
+		SpecVersion string `json:"specversion"`
+	}
+	return json.Unmarshal(msg.Data, &probe) == nil && probe.SpecVersion != ""
+}
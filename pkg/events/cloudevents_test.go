@@ -0,0 +1,120 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+
+	comms "github.com/nats-io/nats.go"
+)
+
+const cloudEventsTestPrefix = "events:cloudevents_test"
+
+func testChangeEvent() *RegistryChangedEvent {
+	major := 2
+	return &RegistryChangedEvent{
+		Kind:            KindDefaultChanged,
+		App:             "more0",
+		Capability:      "doc-ingest",
+		ChangedFields:   []string{"defaultMajor"},
+		NewDefaultMajor: &major,
+		AffectedMajors:  []int{2},
+		Revision:        4,
+		Etag:            "cap-1-4",
+		Timestamp:       "2026-01-02T03:04:05Z",
+		Sequence:        42,
+		Env:             "production",
+		Actor:           "user-1",
+		RequestID:       "req-9",
+		Previous:        map[string]interface{}{"defaultMajor": 1},
+		New:             map[string]interface{}{"defaultMajor": 2},
+	}
+}
+
+func TestCloudEventType(t *testing.T) {
+	if got := CloudEventType(testChangeEvent()); got != "registry.changed.defaultChanged" {
+		t.Errorf("%s - type = %q, want registry.changed.defaultChanged", cloudEventsTestPrefix, got)
+	}
+	if got := CloudEventType(&RegistryChangedEvent{}); got != "registry.changed" {
+		t.Errorf("%s - type without kind = %q, want registry.changed", cloudEventsTestPrefix, got)
+	}
+}
+
+func TestChangeEncoder_Structured(t *testing.T) {
+	data, header, err := newChangeEncoder(CloudEventsStructured, "").encode(testChangeEvent())
+	if err != nil {
+		t.Fatalf("%s - encode failed: %v", cloudEventsTestPrefix, err)
+	}
+	if header.Get("content-type") != CloudEventsContentType {
+		t.Errorf("%s - content-type = %q", cloudEventsTestPrefix, header.Get("content-type"))
+	}
+	var envelope CloudEvent
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		t.Fatalf("%s - invalid envelope: %v", cloudEventsTestPrefix, err)
+	}
+	if envelope.SpecVersion != "1.0" || envelope.ID != "change-42" || envelope.Source != DefaultCloudEventsSource ||
+		envelope.Type != "registry.changed.defaultChanged" || envelope.Subject != "more0.doc-ingest" ||
+		envelope.Time != "2026-01-02T03:04:05Z" || envelope.DataContentType != "application/json" {
+		t.Errorf("%s - unexpected envelope: %+v", cloudEventsTestPrefix, envelope)
+	}
+}
+
+func TestChangeEncoder_Binary(t *testing.T) {
+	data, header, err := newChangeEncoder(CloudEventsBinary, "/registry/eu").encode(testChangeEvent())
+	if err != nil {
+		t.Fatalf("%s - encode failed: %v", cloudEventsTestPrefix, err)
+	}
+	want := map[string]string{
+		"content-type":   "application/json",
+		"ce-specversion": "1.0",
+		"ce-id":          "change-42",
+		"ce-source":      "/registry/eu",
+		"ce-type":        "registry.changed.defaultChanged",
+		"ce-subject":     "more0.doc-ingest",
+		"ce-time":        "2026-01-02T03:04:05Z",
+	}
+	for key, value := range want {
+		if got := header.Get(key); got != value {
+			t.Errorf("%s - header %s = %q, want %q", cloudEventsTestPrefix, key, got, value)
+		}
+	}
+	var event RegistryChangedEvent
+	if err := json.Unmarshal(data, &event); err != nil || event.Kind != KindDefaultChanged {
+		t.Errorf("%s - binary payload must be the bare event, got %s", cloudEventsTestPrefix, data)
+	}
+}
+
+func TestChangeEncoder_JSONHasNoHeaders(t *testing.T) {
+	_, header, err := newChangeEncoder(CloudEventsOff, "").encode(testChangeEvent())
+	if err != nil || len(header) != 0 {
+		t.Errorf("%s - plain JSON: headers %v, err %v", cloudEventsTestPrefix, header, err)
+	}
+	if _, _, err := newChangeEncoder("avro", "").encode(testChangeEvent()); err == nil {
+		t.Errorf("%s - expected an error for an unknown mode", cloudEventsTestPrefix)
+	}
+}
+
+func TestDecodeChangeEvent(t *testing.T) {
+	for _, mode := range []CloudEventsMode{CloudEventsOff, CloudEventsStructured, CloudEventsBinary} {
+		data, header, err := newChangeEncoder(mode, "").encode(testChangeEvent())
+		if err != nil {
+			t.Fatalf("%s - %q: encode failed: %v", cloudEventsTestPrefix, mode, err)
+		}
+		event, err := DecodeChangeEvent(&comms.Msg{Subject: "registry.changed", Data: data, Header: header})
+		if err != nil {
+			t.Fatalf("%s - %q: decode failed: %v", cloudEventsTestPrefix, mode, err)
+		}
+		if event.Kind != KindDefaultChanged || event.App != "more0" || event.Sequence != 42 || event.Actor != "user-1" ||
+			event.RequestID != "req-9" || event.Env != "production" || event.Previous["defaultMajor"] != float64(1) {
+			t.Errorf("%s - %q: unexpected event %+v", cloudEventsTestPrefix, mode, event)
+		}
+	}
+
+	// A structured event that lost its headers (e.g. bridged through a header-less transport)
+	data, _, _ := newChangeEncoder(CloudEventsStructured, "").encode(testChangeEvent())
+	if event, err := DecodeChangeEvent(&comms.Msg{Data: data}); err != nil || event.App != "more0" {
+		t.Errorf("%s - header-less structured event: %+v, %v", cloudEventsTestPrefix, event, err)
+	}
+	if _, err := DecodeChangeEvent(&comms.Msg{Data: []byte("not json")}); err == nil {
+		t.Errorf("%s - expected an error for an invalid payload", cloudEventsTestPrefix)
+	}
+}
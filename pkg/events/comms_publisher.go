@@ -16,42 +16,56 @@ const commsPublisherLogPrefix = "events:comms_publisher"
 type CommsPublisherOpts struct {
 	// GlobalChangeSubject overrides the global change event subject (e.g. from REGISTRY_CHANGE_EVENT_SUBJECT).
 	GlobalChangeSubject string
+	// ChangeSubjectPattern is the granular change subject, scoped by {env} and {tenant}
+	// (default commsutil.DefaultChangeSubjectPattern; see commsutil.BuildScopedChangeSubject).
+	ChangeSubjectPattern string
+	// CloudEvents publishes events as CloudEvents 1.0 in structured or binary mode
+	// (default: the bare event JSON).
+	CloudEvents CloudEventsMode
+	// CloudEventsSource is the CloudEvents source attribute (default DefaultCloudEventsSource).
+	CloudEventsSource string
 }
 
 // CommsPublisher publishes registry change events to COMMS subjects.
 type CommsPublisher struct {
-	nc                  *comms.Conn
-	globalChangeSubject string
+	nc                   *comms.Conn
+	globalChangeSubject  string
+	changeSubjectPattern string
+	encoder              changeEncoder
 }
 
 // NewCommsPublisher creates a new CommsPublisher. Pass nil for opts to use defaults.
 func NewCommsPublisher(nc *comms.Conn, opts *CommsPublisherOpts) *CommsPublisher {
+	if opts == nil {
+		opts = &CommsPublisherOpts{}
+	}
 	globalSubject := commsutil.SubjectChangeEvent
-	if opts != nil && opts.GlobalChangeSubject != "" {
+	if opts.GlobalChangeSubject != "" {
 		globalSubject = opts.GlobalChangeSubject
 	}
-	return &CommsPublisher{nc: nc, globalChangeSubject: globalSubject}
+	return &CommsPublisher{
+		nc:                   nc,
+		globalChangeSubject:  globalSubject,
+		changeSubjectPattern: opts.ChangeSubjectPattern,
+		encoder:              newChangeEncoder(opts.CloudEvents, opts.CloudEventsSource),
+	}
 }
 
 // PublishChanged publishes a RegistryChangedEvent to both the granular
-// and global change event subjects.
+// and global change event subjects. The granular subject is scoped by the event's
+// Env and TenantID when the pattern has {env} or {tenant}.
 func (p *CommsPublisher) PublishChanged(_ context.Context, event *RegistryChangedEvent) error {
-	data, err := commsutil.EncodePayload(event)
+	data, header, err := p.encoder.encode(event)
 	if err != nil {
 		return fmt.Errorf("%s - failed to encode event: %w", commsPublisherLogPrefix, err)
 	}
 
-	// Publish to granular subject
-	granularSubject := commsutil.BuildChangeSubject(event.App, event.Capability)
-	if err := p.nc.Publish(granularSubject, data); err != nil {
-		slog.Error(fmt.Sprintf("%s - failed to publish to %s: %v", commsPublisherLogPrefix, granularSubject, err))
-		return err
-	}
-
-	// Publish to global subject
-	if err := p.nc.Publish(p.globalChangeSubject, data); err != nil {
-		slog.Error(fmt.Sprintf("%s - failed to publish to %s: %v", commsPublisherLogPrefix, p.globalChangeSubject, err))
-		return err
+	granularSubject := commsutil.BuildScopedChangeSubject(p.changeSubjectPattern, event.App, event.Capability, event.Env, event.TenantID)
+	for _, subject := range []string{granularSubject, p.globalChangeSubject} {
+		if err := p.nc.PublishMsg(&comms.Msg{Subject: subject, Data: data, Header: header}); err != nil {
+			slog.Error(fmt.Sprintf("%s - failed to publish to %s: %v", commsPublisherLogPrefix, subject, err))
+			return err
+		}
 	}
 
 	slog.Debug(fmt.Sprintf("%s - Published change event for %s.%s", commsPublisherLogPrefix, event.App, event.Capability))
@@ -352,3 +352,79 @@ func TestNewCommsPublisher_EmptyGlobalSubject(t *testing.T) {
 			publisher.globalChangeSubject, "registry.changed")
 	}
 }
+
+func TestCommsPublisher_CloudEvents(t *testing.T) {
+	nc, cleanup := startTestServer(t, 14237)
+	defer cleanup()
+
+	received := make(chan *comms.Msg, 4)
+	sub, err := nc.ChanSubscribe("registry.changed", received)
+	if err != nil {
+		t.Fatalf("events:comms_publisher_integration_test - failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	event := &RegistryChangedEvent{Kind: KindDeprecated, App: "more0", Capability: "doc-ingest", Revision: 3, Sequence: 8}
+	for _, mode := range []CloudEventsMode{CloudEventsStructured, CloudEventsBinary} {
+		publisher := NewCommsPublisher(nc, &CommsPublisherOpts{CloudEvents: mode})
+		if err := publisher.PublishChanged(context.Background(), event); err != nil {
+			t.Fatalf("events:comms_publisher_integration_test - PublishChanged failed: %v", err)
+		}
+		select {
+		case msg := <-received:
+			if mode == CloudEventsBinary && msg.Header.Get("ce-type") != "registry.changed.deprecated" {
+				t.Errorf("events:comms_publisher_integration_test - binary ce-type = %q", msg.Header.Get("ce-type"))
+			}
+			got, err := DecodeChangeEvent(msg)
+			if err != nil || got.Kind != KindDeprecated || got.Sequence != 8 {
+				t.Errorf("events:comms_publisher_integration_test - %s: decoded %+v, %v", mode, got, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("events:comms_publisher_integration_test - %s: timeout waiting for event", mode)
+		}
+	}
+}
+
+func TestCommsPublisher_ScopedChangeSubject(t *testing.T) {
+	nc, cleanup := startTestServer(t, 14238)
+	defer cleanup()
+
+	publisher := NewCommsPublisher(nc, &CommsPublisherOpts{ChangeSubjectPattern: "registry.changed.{env}.{app}.{capability}"})
+
+	received := make(chan *comms.Msg, 4)
+	for _, subject := range []string{"registry.changed.staging.*.>", "registry.changed._all.*.>"} {
+		sub, err := nc.ChanSubscribe(subject, received)
+		if err != nil {
+			t.Fatalf("events:comms_publisher_integration_test - failed to subscribe: %v", err)
+		}
+		defer sub.Unsubscribe()
+	}
+
+	changes := []*RegistryChangedEvent{
+		{App: "more0", Capability: "doc-ingest", Env: "production", Revision: 1},
+		{App: "more0", Capability: "doc-ingest", Env: "staging", Revision: 2},
+		{App: "more0", Capability: "doc-ingest", Revision: 3},
+	}
+	for _, event := range changes {
+		if err := publisher.PublishChanged(context.Background(), event); err != nil {
+			t.Fatalf("events:comms_publisher_integration_test - PublishChanged failed: %v", err)
+		}
+	}
+	nc.Flush()
+
+	for _, want := range []string{"registry.changed.staging.more0.doc-ingest", "registry.changed._all.more0.doc-ingest"} {
+		select {
+		case msg := <-received:
+			if msg.Subject != want {
+				t.Errorf("events:comms_publisher_integration_test - subject = %q, want %q", msg.Subject, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("events:comms_publisher_integration_test - timeout waiting for %s", want)
+		}
+	}
+	select {
+	case msg := <-received:
+		t.Errorf("events:comms_publisher_integration_test - a production change reached a staging subscriber: %s", msg.Subject)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
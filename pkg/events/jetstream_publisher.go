@@ -0,0 +1,221 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	comms "github.com/nats-io/nats.go"
+
+	"github.com/morezero/capabilities-registry/pkg/commsutil"
+)
+
+const (
+	jetStreamPublisherLogPrefix = "events:jetstream_publisher"
+	// DefaultChangeStream is the name of the JetStream stream that stores change events.
+	DefaultChangeStream = "REGISTRY_CHANGES"
+)
+
+// JetStreamPublisherOpts configures JetStreamPublisher. Nil or zero values use defaults.
+type JetStreamPublisherOpts struct {
+	// GlobalChangeSubject overrides the global change event subject (e.g. from REGISTRY_CHANGE_EVENT_SUBJECT).
+	GlobalChangeSubject string
+	// ChangeSubjectPattern is the granular change subject, as in CommsPublisherOpts. It must
+	// be under registry.changed, which the stream covers.
+	ChangeSubjectPattern string
+	// Stream is the name of the stream covering the change subjects (default DefaultChangeStream).
+	Stream string
+	// MaxAge is how long events are kept in the stream (0 = forever).
+	MaxAge time.Duration
+	// MaxMsgs limits the number of events kept in the stream (0 = unlimited).
+	MaxMsgs int64
+	// CloudEvents and CloudEventsSource select the event encoding, as in CommsPublisherOpts.
+	CloudEvents       CloudEventsMode
+	CloudEventsSource string
+}
+
+// JetStreamPublisher publishes registry change events to the same subjects as
+// CommsPublisher, through a JetStream stream that keeps them for subscribers that were
+// offline. Core NATS subscribers still receive every event.
+type JetStreamPublisher struct {
+	js                   comms.JetStreamContext
+	stream               string
+	globalChangeSubject  string
+	changeSubjectPattern string
+	encoder              changeEncoder
+}
+
+// NewJetStreamPublisher creates a JetStreamPublisher, creating its stream or updating an
+// existing one so it covers the change subjects with the configured retention. Pass nil
+// for opts to use defaults.
+func NewJetStreamPublisher(nc *comms.Conn, opts *JetStreamPublisherOpts) (*JetStreamPublisher, error) {
+	if opts == nil {
+		opts = &JetStreamPublisherOpts{}
+	}
+	p := &JetStreamPublisher{
+		stream:               opts.Stream,
+		globalChangeSubject:  opts.GlobalChangeSubject,
+		changeSubjectPattern: opts.ChangeSubjectPattern,
+		encoder:              newChangeEncoder(opts.CloudEvents, opts.CloudEventsSource),
+	}
+	if p.stream == "" {
+		p.stream = DefaultChangeStream
+	}
+	if p.globalChangeSubject == "" {
+		p.globalChangeSubject = commsutil.SubjectChangeEvent
+	}
+
+	js, err := nc.JetStream()
+	if err != nil {
+		return nil, fmt.Errorf("%s - JetStream unavailable: %w", jetStreamPublisherLogPrefix, err)
+	}
+	p.js = js
+	if err := p.ensureStream(opts.MaxAge, opts.MaxMsgs); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ensureStream creates the stream or adds the missing change subjects and retention limits
+// to an existing one. A stream that deletes events once consumed cannot be replayed and is
+// rejected.
+func (p *JetStreamPublisher) ensureStream(maxAge time.Duration, maxMsgs int64) error {
+	subjects := changeStreamSubjects(p.globalChangeSubject)
+	if maxMsgs <= 0 {
+		maxMsgs = -1
+	}
+
+	info, err := p.js.StreamInfo(p.stream)
+	if errors.Is(err, comms.ErrStreamNotFound) {
+		_, err = p.js.AddStream(&comms.StreamConfig{
+			Name:        p.stream,
+			Description: "Registry change events",
+			Subjects:    subjects,
+			Retention:   comms.LimitsPolicy,
+			Storage:     comms.FileStorage,
+			MaxAge:      maxAge,
+			MaxMsgs:     maxMsgs,
+		})
+		if err != nil {
+			return fmt.Errorf("%s - creating stream %s failed: %w", jetStreamPublisherLogPrefix, p.stream, err)
+		}
+		slog.Info(fmt.Sprintf("%s - created stream %s for %s", jetStreamPublisherLogPrefix, p.stream, strings.Join(subjects, ", ")))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s - looking up stream %s failed: %w", jetStreamPublisherLogPrefix, p.stream, err)
+	}
+
+	cfg := info.Config
+	if cfg.Retention != comms.LimitsPolicy {
+		return fmt.Errorf("%s - stream %s has %s retention; change events need limits retention to be replayed", jetStreamPublisherLogPrefix, p.stream, cfg.Retention)
+	}
+	changed := false
+	for _, subject := range subjects {
+		if !slices.Contains(cfg.Subjects, subject) {
+			cfg.Subjects = append(cfg.Subjects, subject)
+			changed = true
+		}
+	}
+	if cfg.MaxAge != maxAge || cfg.MaxMsgs != maxMsgs {
+		cfg.MaxAge, cfg.MaxMsgs = maxAge, maxMsgs
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	if _, err := p.js.UpdateStream(&cfg); err != nil {
+		return fmt.Errorf("%s - updating stream %s failed: %w", jetStreamPublisherLogPrefix, p.stream, err)
+	}
+	slog.Info(fmt.Sprintf("%s - updated stream %s to cover %s", jetStreamPublisherLogPrefix, p.stream, strings.Join(cfg.Subjects, ", ")))
+	return nil
+}
+
+// changeStreamSubjects returns the subjects the change stream must cover: every granular
+// subject and the global subject, unless the granular wildcard already matches it.
+func changeStreamSubjects(globalSubject string) []string {
+	granular := commsutil.SubjectChangeEvent + ".>"
+	if strings.HasPrefix(globalSubject, commsutil.SubjectChangeEvent+".") {
+		return []string{granular}
+	}
+	return []string{globalSubject, granular}
+}
+
+// PublishChanged stores a RegistryChangedEvent in the stream on both the granular and
+// global change event subjects. Each message carries an ID derived from the event, so a
+// retried publish is stored once.
+func (p *JetStreamPublisher) PublishChanged(ctx context.Context, event *RegistryChangedEvent) error {
+	data, header, err := p.encoder.encode(event)
+	if err != nil {
+		return fmt.Errorf("%s - failed to encode event: %w", jetStreamPublisherLogPrefix, err)
+	}
+
+	id := changeMessageID(event)
+	granularSubject := commsutil.BuildScopedChangeSubject(p.changeSubjectPattern, event.App, event.Capability, event.Env, event.TenantID)
+	for _, subject := range []string{granularSubject, p.globalChangeSubject} {
+		msg := &comms.Msg{Subject: subject, Data: data, Header: header}
+		if _, err := p.js.PublishMsg(msg, comms.MsgId(id+"@"+subject), comms.Context(ctx)); err != nil {
+			slog.Error(fmt.Sprintf("%s - failed to publish to %s: %v", jetStreamPublisherLogPrefix, subject, err))
+			return err
+		}
+	}
+
+	slog.Debug(fmt.Sprintf("%s - Published change event %s for %s.%s", jetStreamPublisherLogPrefix, id, event.App, event.Capability))
+	return nil
+}
+
+// changeMessageID identifies a change event for JetStream dedupe: its registry sequence
+// when it has one, otherwise its capability, etag and timestamp.
+func changeMessageID(event *RegistryChangedEvent) string {
+	if event.Sequence > 0 {
+		return fmt.Sprintf("change-%d", event.Sequence)
+	}
+	return fmt.Sprintf("%s.%s/%s/%s", event.App, event.Capability, event.Etag, event.Timestamp)
+}
+
+// ReplayOpts selects the change events a replay delivers. StartSequence (a stream
+// sequence) takes precedence over StartTime; with neither, every retained event is replayed.
+type ReplayOpts struct {
+	// Subject filters the replay (default: the global change subject, one message per change).
+	Subject       string
+	StartSequence uint64
+	StartTime     time.Time
+}
+
+// Replay delivers the stored change events selected by opts, in order, to handler and then
+// keeps delivering new ones until the returned subscription is unsubscribed. handler
+// receives each event with its stream sequence, which a consumer can pass as
+// StartSequence to resume after a restart.
+func (p *JetStreamPublisher) Replay(opts ReplayOpts, handler func(event *RegistryChangedEvent, streamSeq uint64)) (*comms.Subscription, error) {
+	subject := opts.Subject
+	if subject == "" {
+		subject = p.globalChangeSubject
+	}
+	start := comms.DeliverAll()
+	if opts.StartSequence > 0 {
+		start = comms.StartSequence(opts.StartSequence)
+	} else if !opts.StartTime.IsZero() {
+		start = comms.StartTime(opts.StartTime)
+	}
+
+	sub, err := p.js.Subscribe(subject, func(msg *comms.Msg) {
+		meta, err := msg.Metadata()
+		if err != nil {
+			return
+		}
+		event, err := DecodeChangeEvent(msg)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("%s - skipping invalid event at stream sequence %d: %v", jetStreamPublisherLogPrefix, meta.Sequence.Stream, err))
+			return
+		}
+		handler(event, meta.Sequence.Stream)
+	}, comms.BindStream(p.stream), comms.OrderedConsumer(), start)
+	if err != nil {
+		return nil, fmt.Errorf("%s - replaying %s failed: %w", jetStreamPublisherLogPrefix, subject, err)
+	}
+	return sub, nil
+}
@@ -0,0 +1,164 @@
+package events
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	commsserver "github.com/nats-io/nats-server/v2/server"
+	comms "github.com/nats-io/nats.go"
+)
+
+const jetStreamTestPrefix = "events:jetstream_publisher_integration_test"
+
+// startJetStreamServer starts an in-process NATS server with JetStream enabled.
+func startJetStreamServer(t *testing.T, port int) *comms.Conn {
+	t.Helper()
+
+	ns, err := commsserver.NewServer(&commsserver.Options{
+		Host:      "127.0.0.1",
+		Port:      port,
+		NoLog:     true,
+		NoSigs:    true,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("%s - failed to create server: %v", jetStreamTestPrefix, err)
+	}
+	go ns.Start()
+	if !ns.ReadyForConnections(10 * time.Second) {
+		t.Fatalf("%s - server failed to start", jetStreamTestPrefix)
+	}
+
+	nc, err := comms.Connect(ns.ClientURL(), comms.Timeout(5*time.Second))
+	if err != nil {
+		ns.Shutdown()
+		t.Fatalf("%s - failed to connect: %v", jetStreamTestPrefix, err)
+	}
+	t.Cleanup(func() {
+		nc.Close()
+		ns.Shutdown()
+		ns.WaitForShutdown()
+	})
+	return nc
+}
+
+func TestJetStreamPublisher_CreatesStream(t *testing.T) {
+	nc := startJetStreamServer(t, 14240)
+
+	if _, err := NewJetStreamPublisher(nc, &JetStreamPublisherOpts{MaxAge: time.Hour, MaxMsgs: 500}); err != nil {
+		t.Fatalf("%s - NewJetStreamPublisher failed: %v", jetStreamTestPrefix, err)
+	}
+	js, _ := nc.JetStream()
+	info, err := js.StreamInfo(DefaultChangeStream)
+	if err != nil {
+		t.Fatalf("%s - StreamInfo failed: %v", jetStreamTestPrefix, err)
+	}
+	if !slices.Equal(info.Config.Subjects, []string{"registry.changed", "registry.changed.>"}) {
+		t.Errorf("%s - subjects = %v", jetStreamTestPrefix, info.Config.Subjects)
+	}
+	if info.Config.MaxAge != time.Hour || info.Config.MaxMsgs != 500 || info.Config.Retention != comms.LimitsPolicy {
+		t.Errorf("%s - retention = %s/%s/%d", jetStreamTestPrefix, info.Config.Retention, info.Config.MaxAge, info.Config.MaxMsgs)
+	}
+
+	// A second registry verifies the stream and applies its own retention
+	if _, err := NewJetStreamPublisher(nc, &JetStreamPublisherOpts{MaxAge: 2 * time.Hour}); err != nil {
+		t.Fatalf("%s - NewJetStreamPublisher on an existing stream failed: %v", jetStreamTestPrefix, err)
+	}
+	info, _ = js.StreamInfo(DefaultChangeStream)
+	if info.Config.MaxAge != 2*time.Hour || info.Config.MaxMsgs != -1 || len(info.Config.Subjects) != 2 {
+		t.Errorf("%s - updated stream = %+v", jetStreamTestPrefix, info.Config)
+	}
+}
+
+func TestJetStreamPublisher_VerifiesExistingStream(t *testing.T) {
+	nc := startJetStreamServer(t, 14241)
+	js, _ := nc.JetStream()
+
+	if _, err := js.AddStream(&comms.StreamConfig{Name: "CHANGES", Subjects: []string{"registry.changed.>"}}); err != nil {
+		t.Fatalf("%s - AddStream failed: %v", jetStreamTestPrefix, err)
+	}
+	if _, err := NewJetStreamPublisher(nc, &JetStreamPublisherOpts{Stream: "CHANGES", GlobalChangeSubject: "events.registry"}); err != nil {
+		t.Fatalf("%s - NewJetStreamPublisher failed: %v", jetStreamTestPrefix, err)
+	}
+	info, _ := js.StreamInfo("CHANGES")
+	if !slices.Contains(info.Config.Subjects, "events.registry") {
+		t.Errorf("%s - the global subject should be added, got %v", jetStreamTestPrefix, info.Config.Subjects)
+	}
+
+	if _, err := js.AddStream(&comms.StreamConfig{Name: "QUEUE", Subjects: []string{"queue.>"}, Retention: comms.WorkQueuePolicy}); err != nil {
+		t.Fatalf("%s - AddStream failed: %v", jetStreamTestPrefix, err)
+	}
+	if _, err := NewJetStreamPublisher(nc, &JetStreamPublisherOpts{Stream: "QUEUE", GlobalChangeSubject: "queue.changed"}); err == nil {
+		t.Errorf("%s - a work-queue stream must be rejected", jetStreamTestPrefix)
+	}
+}
+
+func TestJetStreamPublisher_DedupesAndReplays(t *testing.T) {
+	nc := startJetStreamServer(t, 14242)
+	publisher, err := NewJetStreamPublisher(nc, nil)
+	if err != nil {
+		t.Fatalf("%s - NewJetStreamPublisher failed: %v", jetStreamTestPrefix, err)
+	}
+
+	ctx := context.Background()
+	for seq := int64(1); seq <= 3; seq++ {
+		event := &RegistryChangedEvent{App: "more0", Capability: "doc-ingest", AffectedMajors: []int{1}, Sequence: seq}
+		if err := publisher.PublishChanged(ctx, event); err != nil {
+			t.Fatalf("%s - PublishChanged failed: %v", jetStreamTestPrefix, err)
+		}
+		// A retried publish of the same change is stored once
+		if err := publisher.PublishChanged(ctx, event); err != nil {
+			t.Fatalf("%s - PublishChanged retry failed: %v", jetStreamTestPrefix, err)
+		}
+	}
+	js, _ := nc.JetStream()
+	info, _ := js.StreamInfo(DefaultChangeStream)
+	if info.State.Msgs != 6 {
+		t.Fatalf("%s - stream holds %d messages, want 6 (3 changes on 2 subjects)", jetStreamTestPrefix, info.State.Msgs)
+	}
+
+	replay := func(opts ReplayOpts, want int) []uint64 {
+		t.Helper()
+		got := make(chan uint64, 10)
+		streamSeqs := make(chan uint64, 10)
+		sub, err := publisher.Replay(opts, func(event *RegistryChangedEvent, streamSeq uint64) {
+			got <- uint64(event.Sequence)
+			streamSeqs <- streamSeq
+		})
+		if err != nil {
+			t.Fatalf("%s - Replay failed: %v", jetStreamTestPrefix, err)
+		}
+		defer sub.Unsubscribe()
+		var seqs, stream []uint64
+		for len(seqs) < want {
+			select {
+			case seq := <-got:
+				seqs = append(seqs, seq)
+				stream = append(stream, <-streamSeqs)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("%s - replayed %v, want %d events", jetStreamTestPrefix, seqs, want)
+			}
+		}
+		for i := range seqs {
+			if i > 0 && seqs[i] <= seqs[i-1] {
+				t.Errorf("%s - replay out of order: %v", jetStreamTestPrefix, seqs)
+			}
+		}
+		return stream
+	}
+
+	// The global subject holds one message per change
+	stream := replay(ReplayOpts{}, 3)
+	fromSecond := replay(ReplayOpts{StartSequence: stream[1]}, 2)
+	if fromSecond[0] != stream[1] {
+		t.Errorf("%s - replay from stream sequence %d started at %d", jetStreamTestPrefix, stream[1], fromSecond[0])
+	}
+	replay(ReplayOpts{Subject: "registry.changed.more0.doc-ingest", StartTime: time.Now().Add(-time.Minute)}, 3)
+
+	if got := changeMessageID(&RegistryChangedEvent{App: "a", Capability: "b", Etag: "cap-1-2", Timestamp: "t"}); got != "a.b/cap-1-2/t" {
+		t.Errorf("%s - message ID without a sequence = %q", jetStreamTestPrefix, got)
+	}
+}
@@ -1,8 +1,28 @@
 // Package events defines event types and publisher interfaces for registry change events.
 package events
 
+// Event kinds. Kind says what happened; ChangedFields is kept for consumers that predate it.
+const (
+	KindVersionPublished  = "versionPublished"
+	KindVersionUpdated    = "versionUpdated"
+	KindDefaultChanged    = "defaultChanged"
+	KindPromoted          = "promoted"
+	KindDeprecated        = "deprecated"
+	KindDisabled          = "disabled"
+	KindAliasChanged      = "aliasChanged"
+	KindShadowChanged     = "shadowChanged"
+	KindInstancesChanged  = "instancesChanged"
+	KindTenantCellChanged = "tenantCellChanged"
+	KindMirrored          = "mirrored"
+	// KindTenantRuleChanged is reserved for tenant rule writes; tenant rules are only
+	// read by the registry today, so no event carries it yet.
+	KindTenantRuleChanged = "tenantRuleChanged"
+)
+
 // RegistryChangedEvent is emitted when a capability's registry entry changes.
 type RegistryChangedEvent struct {
+	// Kind is one of the Kind constants (empty on events from older registries).
+	Kind            string   `json:"kind,omitempty"`
 	App             string   `json:"app"`
 	Capability      string   `json:"capability"`
 	ChangedFields   []string `json:"changedFields"`
@@ -11,5 +31,29 @@ type RegistryChangedEvent struct {
 	Revision        int      `json:"revision"`
 	Etag            string   `json:"etag"`
 	Timestamp       string   `json:"timestamp"`
-	Env             string   `json:"env,omitempty"`
+	// Sequence is the event's position in the registry's global change feed (changesSince);
+	// consumers that see a gap can backfill from the last sequence they applied. 0 when the
+	// change could not be recorded.
+	Sequence int64  `json:"sequence,omitempty"`
+	Env      string `json:"env,omitempty"`
+	// TenantID, Cell and NatsUrl are set on tenant routing changes; Reconnect tells
+	// the tenant's clients to reconnect to NatsUrl.
+	TenantID  string `json:"tenantId,omitempty"`
+	Cell      string `json:"cell,omitempty"`
+	NatsUrl   string `json:"natsUrl,omitempty"`
+	Reconnect bool   `json:"reconnect,omitempty"`
+	// Version and LiveInstances are set on instance liveness transitions (a version
+	// gaining its first live instance or losing its last one).
+	Version       string `json:"version,omitempty"`
+	LiveInstances *int   `json:"liveInstances,omitempty"`
+	// Actor is the user and RequestID the request that made the change; both are empty
+	// for changes the registry makes on its own (lease expiry, mirror sync).
+	Actor     string `json:"actor,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+	// AffectedVersions lists the exact versions the change applies to.
+	AffectedVersions []string `json:"affectedVersions,omitempty"`
+	// Previous and New hold the changed values before and after the change, keyed by
+	// field (e.g. {"defaultMajor": 1} and {"defaultMajor": 2}).
+	Previous map[string]interface{} `json:"previous,omitempty"`
+	New      map[string]interface{} `json:"new,omitempty"`
 }
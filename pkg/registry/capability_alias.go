@@ -0,0 +1,180 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/morezero/capabilities-registry/pkg/db"
+	"github.com/morezero/capabilities-registry/pkg/events"
+	"github.com/morezero/capabilities-registry/pkg/semver"
+)
+
+const capAliasLogPrefix = "registry:capabilityAlias"
+
+// AddCapabilityAlias maps an old capability ref to an existing capability, so callers
+// using the old ref keep resolving after a rename or move to another app.
+func (r *Registry) AddCapabilityAlias(ctx context.Context, input *AddCapabilityAliasInput, userID string) (*CapabilityAliasInfo, error) {
+	slog.Info(fmt.Sprintf("%s - addCapabilityAlias %s -> %s", capAliasLogPrefix, input.Alias, input.Target))
+
+	if err := r.requireRepo(); err != nil {
+		return nil, err
+	}
+	alias := strings.TrimSpace(input.Alias)
+	if alias == "" || strings.ContainsAny(alias, "@/ ") {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: "alias must be a capability ref without version or registry alias"}
+	}
+
+	target, err := semver.ParseCapabilityRef(input.Target)
+	if err != nil {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: err.Error()}
+	}
+	if target.Range != "" {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: "target must not include a version"}
+	}
+	if alias == target.Full {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: "alias and target must differ"}
+	}
+	targetCap, err := r.repo.GetCapability(ctx, target.App, target.Name)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	if targetCap == nil {
+		return nil, &RegistryError{Code: "NOT_FOUND", Message: fmt.Sprintf("Capability not found: %s", target.Full)}
+	}
+	// A live capability always wins over an alias, so an alias shadowing one would never apply.
+	if parsed, err := semver.ParseCapabilityRef(alias); err == nil {
+		existing, err := r.repo.GetCapability(ctx, parsed.App, parsed.Name)
+		if err != nil {
+			return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+		}
+		if existing != nil {
+			return nil, &RegistryError{Code: "ALREADY_EXISTS", Message: fmt.Sprintf("Capability %s exists; remove or rename it before aliasing", alias)}
+		}
+	}
+
+	a, err := r.repo.AddCapabilityAlias(ctx, db.AddCapabilityAliasParams{
+		Alias:      alias,
+		TargetApp:  target.App,
+		TargetName: target.Name,
+		UserID:     userID,
+	})
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+
+	r.publishAliasChanged(ctx, targetCap, nil, map[string]interface{}{"alias": a.Alias})
+	return capabilityAliasToInfo(a), nil
+}
+
+// RemoveCapabilityAlias deletes a capability alias; callers using it stop resolving.
+func (r *Registry) RemoveCapabilityAlias(ctx context.Context, input *RemoveCapabilityAliasInput) (*RemoveCapabilityAliasOutput, error) {
+	slog.Info(fmt.Sprintf("%s - removeCapabilityAlias %s", capAliasLogPrefix, input.Alias))
+
+	if err := r.requireRepo(); err != nil {
+		return nil, err
+	}
+	existing, err := r.repo.GetCapabilityAlias(ctx, input.Alias)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	if existing == nil {
+		return &RemoveCapabilityAliasOutput{Removed: false}, nil
+	}
+	removed, err := r.repo.RemoveCapabilityAlias(ctx, input.Alias)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	if removed {
+		if targetCap, _ := r.repo.GetCapability(ctx, existing.TargetApp, existing.TargetName); targetCap != nil {
+			r.publishAliasChanged(ctx, targetCap, map[string]interface{}{"alias": existing.Alias}, nil)
+		}
+	}
+	return &RemoveCapabilityAliasOutput{Removed: removed}, nil
+}
+
+// lookupCapabilityFollowingAliases parses ref and loads its capability. When no capability
+// exists under the ref, the capability alias table is consulted and, for a rename alias, a
+// "renamed" warning is returned alongside the target. The returned ref keeps the requested version range.
+func (r *Registry) lookupCapabilityFollowingAliases(ctx context.Context, ref string) (*semver.ParsedCapabilityRef, *db.Capability, []Warning, *RegistryError) {
+	parsed, parseErr := semver.ParseCapabilityRef(ref)
+	if parseErr == nil {
+		cap, err := r.catalog.GetCapability(ctx, parsed.App, parsed.Name)
+		if err != nil {
+			return nil, nil, nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+		}
+		if cap != nil {
+			return parsed, cap, nil, nil
+		}
+	}
+
+	capPart, rangeStr, _ := strings.Cut(strings.TrimSpace(ref), "@")
+	alias, err := r.catalog.GetCapabilityAlias(ctx, capPart)
+	if err != nil {
+		return nil, nil, nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	if alias == nil {
+		if parseErr != nil {
+			return nil, nil, nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: parseErr.Error()}
+		}
+		return nil, nil, nil, &RegistryError{Code: "NOT_FOUND", Message: fmt.Sprintf("Capability not found: %s", parsed.Full)}
+	}
+
+	target := &semver.ParsedCapabilityRef{
+		Full:  alias.TargetApp + "." + alias.TargetName,
+		App:   alias.TargetApp,
+		Name:  alias.TargetName,
+		Range: rangeStr,
+		Raw:   ref,
+	}
+	cap, err := r.catalog.GetCapability(ctx, target.App, target.Name)
+	if err != nil {
+		return nil, nil, nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	if cap == nil {
+		return nil, nil, nil, &RegistryError{Code: "NOT_FOUND", Message: fmt.Sprintf("Capability not found: %s (alias of %s)", target.Full, capPart)}
+	}
+	return target, cap, aliasWarnings(alias, capPart, target.Full), nil
+}
+
+// aliasWarnings returns the "renamed" warning of a rename alias; a shorthand from the
+// bootstrap config is a documented name and resolves without one.
+func aliasWarnings(alias *db.CapabilityAlias, ref, target string) []Warning {
+	if alias.Kind == db.CapabilityAliasShorthand {
+		return nil
+	}
+	return []Warning{{
+		Code:    "renamed",
+		Message: fmt.Sprintf("%s has been renamed to %s; update references", ref, target),
+	}}
+}
+
+// publishAliasChanged publishes an aliasChanged event on the alias target; previous holds a
+// removed alias and next an added one.
+func (r *Registry) publishAliasChanged(ctx context.Context, cap *db.Capability, previous, next map[string]interface{}) {
+	revision, _ := r.repo.IncrementRevision(ctx, cap.ID)
+	_ = r.publisher.PublishChanged(ctx, &events.RegistryChangedEvent{
+		Kind:           events.KindAliasChanged,
+		App:            cap.App,
+		Capability:     cap.Name,
+		ChangedFields:  []string{"aliases"},
+		AffectedMajors: []int{},
+		Previous:       previous,
+		New:            next,
+		Revision:       revision,
+		Etag:           fmt.Sprintf("%s-%d", cap.ID, revision),
+		Timestamp:      time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+func capabilityAliasToInfo(a *db.CapabilityAlias) *CapabilityAliasInfo {
+	return &CapabilityAliasInfo{
+		Alias:   a.Alias,
+		Target:  a.TargetApp + "." + a.TargetName,
+		Source:  a.Source,
+		Kind:    a.Kind,
+		Created: a.Created.UTC().Format(time.RFC3339),
+	}
+}
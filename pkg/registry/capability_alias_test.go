@@ -0,0 +1,35 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/morezero/capabilities-registry/pkg/db"
+)
+
+const capAliasTestPrefix = "registry:capability_alias_test"
+
+func TestCapabilityAliasMethods_RequireRepo(t *testing.T) {
+	reg := NewRegistry(NewRegistryParams{Repo: nil, Publisher: nil, Config: DefaultConfig()})
+	ctx := context.Background()
+
+	_, err := reg.AddCapabilityAlias(ctx, &AddCapabilityAliasInput{Alias: "oldapp.doc.ingest", Target: "more0.doc.ingest"}, "test-user")
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INTERNAL_ERROR" {
+		t.Errorf("%s - AddCapabilityAlias: expected INTERNAL_ERROR, got %v", capAliasTestPrefix, err)
+	}
+	_, err = reg.RemoveCapabilityAlias(ctx, &RemoveCapabilityAliasInput{Alias: "oldapp.doc.ingest"})
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INTERNAL_ERROR" {
+		t.Errorf("%s - RemoveCapabilityAlias: expected INTERNAL_ERROR, got %v", capAliasTestPrefix, err)
+	}
+}
+
+func TestAliasWarnings(t *testing.T) {
+	rename := &db.CapabilityAlias{Alias: "oldapp.doc.ingest", Kind: db.CapabilityAliasRename}
+	if w := aliasWarnings(rename, "oldapp.doc.ingest", "more0.doc.ingest"); len(w) != 1 || w[0].Code != "renamed" {
+		t.Errorf("%s - a rename alias must warn, got %+v", capAliasTestPrefix, w)
+	}
+	shorthand := &db.CapabilityAlias{Alias: "registry", Kind: db.CapabilityAliasShorthand}
+	if w := aliasWarnings(shorthand, "registry", "system.registry"); len(w) != 0 {
+		t.Errorf("%s - a bootstrap shorthand must resolve without warnings, got %+v", capAliasTestPrefix, w)
+	}
+}
@@ -0,0 +1,341 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/morezero/capabilities-registry/pkg/db"
+	"github.com/morezero/capabilities-registry/pkg/semver"
+)
+
+// CatalogStore holds the catalog a registry serves: capabilities with their versions,
+// methods, provider endpoints, default majors, tenant rules, shadows and aliases, and the
+// cells and releases that route them. resolve, discover, describe, listMajors, lockfiles,
+// releases and bootstrap read it, and a mirror writes the catalog it copies into it. The
+// Postgres repository is the store of every registry that has one; a mirror without a
+// database keeps its copy in a LocalStore.
+type CatalogStore interface {
+	GetCapability(ctx context.Context, app, name string) (*db.Capability, error)
+	ListCapabilities(ctx context.Context, params db.ListCapabilitiesParams) ([]db.Capability, int, error)
+	// GetVersions returns the versions of a capability, ordered by semver descending.
+	GetVersions(ctx context.Context, capabilityID string) ([]db.CapabilityVersion, error)
+	GetVersionsByCapabilityIDs(ctx context.Context, capabilityIDs []string) (map[string][]db.CapabilityVersion, error)
+	// GetDefault returns the default major of a capability in env, or nil when it has none.
+	GetDefault(ctx context.Context, capabilityID, env string) (*db.CapabilityDefault, error)
+	GetDefaultsBatch(ctx context.Context, capabilityIDs []string, env string) (map[string]*db.CapabilityDefault, error)
+	// GetMethods returns the methods of a version ordered by name.
+	GetMethods(ctx context.Context, versionID string) ([]db.CapabilityMethod, error)
+	// ListEndpoints returns the provider endpoints of a version ordered by priority.
+	ListEndpoints(ctx context.Context, versionID string) ([]db.CapabilityEndpoint, error)
+	ListEndpointsForVersions(ctx context.Context, versionIDs []string) (map[string][]db.CapabilityEndpoint, error)
+	// ListBootstrapEntries returns every capability with a default major in env, with the
+	// latest version of that major available in env.
+	ListBootstrapEntries(ctx context.Context, env string) ([]db.BootstrapEntry, error)
+	// CheckTenantAccess evaluates the capability's tenant rules for major in rctx, failing
+	// closed when they cannot be read.
+	CheckTenantAccess(ctx context.Context, capabilityID string, major int, rctx db.ResolutionContext) (bool, string)
+	// GetShadow returns the shadow config of a capability, or nil when it has none.
+	GetShadow(ctx context.Context, capabilityID string) (*db.CapabilityShadow, error)
+	// GetCapabilityAlias returns the alias named alias ("app.name"), or nil.
+	GetCapabilityAlias(ctx context.Context, alias string) (*db.CapabilityAlias, error)
+	// GetTenantCell returns the cell a tenant is mapped to, or nil.
+	GetTenantCell(ctx context.Context, tenantID string) (*db.Cell, error)
+	// GetReleaseByName returns a release by name, or nil.
+	GetReleaseByName(ctx context.Context, name string) (*db.Release, error)
+	// ListReleases returns all releases, newest first.
+	ListReleases(ctx context.Context) ([]db.Release, error)
+	// GetReleasePins returns the pins of a release ordered by app and name.
+	GetReleasePins(ctx context.Context, releaseID string) ([]db.ReleasePin, error)
+	// GetReleasePin returns the pin of one capability in a release, or nil.
+	GetReleasePin(ctx context.Context, releaseID, capabilityID string) (*db.ReleasePin, error)
+
+	// ApplyCatalogCapability writes one exported capability with its versions, methods,
+	// endpoints, statuses, defaults, tenant rules, shadow and aliases, and returns it at its
+	// new local revision. It returns nil, leaving the capability alone, when the capability
+	// was registered locally rather than by a mirror. Versions and defaults that c no longer
+	// lists are left in place; tenant rules and aliases are replaced when c has them, and the
+	// shadow always is.
+	ApplyCatalogCapability(ctx context.Context, c CatalogCapability) (*db.Capability, error)
+	// ApplyCatalogRouting replaces the cells and tenant cell assignments with the exported
+	// ones and adds the releases it does not have yet. A release pinning a version that is
+	// not in the store is skipped until a later apply.
+	ApplyCatalogRouting(ctx context.Context, routing CatalogRouting) error
+	// Flush makes the capabilities applied so far durable.
+	Flush(ctx context.Context) error
+}
+
+// repoCatalog is the CatalogStore of a registry with a Postgres repository.
+type repoCatalog struct {
+	*db.Repository
+}
+
+// ApplyCatalogCapability writes c through the repository; see CatalogStore.
+func (s repoCatalog) ApplyCatalogCapability(ctx context.Context, c CatalogCapability) (*db.Capability, error) {
+	key := c.App + "." + c.Name
+	existing, err := s.GetCapability(ctx, c.App, c.Name)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.Source != db.CapabilitySourceMirror {
+		slog.Warn(fmt.Sprintf("%s - skipping %s: registered locally with source %s", mirrorLogPrefix, key, existing.Source))
+		return nil, nil
+	}
+
+	cap, err := s.UpsertCapability(ctx, db.UpsertCapabilityParams{
+		App:             c.App,
+		Name:            c.Name,
+		Description:     optionalString(c.Description),
+		Tags:            c.Tags,
+		SubjectTemplate: optionalString(c.SubjectTemplate),
+		LivenessPolicy:  optionalString(c.LivenessPolicy),
+		Source:          db.CapabilitySourceMirror,
+		UserID:          mirrorUserID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range c.Versions {
+		if err := s.applyCatalogVersion(ctx, cap.ID, v); err != nil {
+			return nil, fmt.Errorf("%s@%d.%d.%d: %w", key, v.Major, v.Minor, v.Patch, err)
+		}
+	}
+	for _, d := range c.Defaults {
+		if _, err := s.SetDefault(ctx, db.SetDefaultParams{
+			CapabilityID: cap.ID,
+			Major:        d.Major,
+			Env:          d.Env,
+			UserID:       mirrorUserID,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if c.TenantRules != nil {
+		if err := s.ReplaceTenantRules(ctx, cap.ID, tenantRuleRows(cap.ID, c.TenantRules), mirrorUserID); err != nil {
+			return nil, err
+		}
+	}
+	if c.Shadow != nil {
+		if _, err := s.UpsertShadow(ctx, db.UpsertShadowParams{
+			CapabilityID:  cap.ID,
+			TargetVersion: optionalString(c.Shadow.TargetVersion),
+			TargetMajor:   c.Shadow.TargetMajor,
+			SampleRate:    c.Shadow.SampleRate,
+			TenantIDs:     c.Shadow.TenantIDs,
+			UserID:        mirrorUserID,
+		}); err != nil {
+			return nil, err
+		}
+	} else if _, err := s.DeleteShadow(ctx, cap.ID); err != nil {
+		return nil, err
+	}
+	if c.Aliases != nil {
+		aliases := make([]db.CapabilityAlias, len(c.Aliases))
+		for i, a := range c.Aliases {
+			aliases[i] = db.CapabilityAlias{Alias: a.Alias, Kind: a.Kind}
+		}
+		if err := s.ReplaceMirroredAliases(ctx, c.App, c.Name, aliases, mirrorUserID); err != nil {
+			return nil, err
+		}
+	}
+
+	revision, err := s.IncrementRevision(ctx, cap.ID)
+	if err != nil {
+		slog.Error(fmt.Sprintf("%s - IncrementRevision failed: %v", mirrorLogPrefix, err))
+		return cap, nil
+	}
+	cap.Revision = revision
+	return cap, nil
+}
+
+// applyCatalogVersion writes one exported version of capabilityID. A version created by the
+// mirror gets the upstream envs; an existing scoped version is promoted to envs it lacks.
+func (s repoCatalog) applyCatalogVersion(ctx context.Context, capabilityID string, v CatalogVersion) error {
+	version, err := s.UpsertVersion(ctx, db.UpsertVersionParams{
+		CapabilityID: capabilityID,
+		Major:        v.Major,
+		Minor:        v.Minor,
+		Patch:        v.Patch,
+		Prerelease:   optionalString(v.Prerelease),
+		Description:  optionalString(v.Description),
+		Changelog:    optionalString(v.Changelog),
+		Metadata:     v.Metadata,
+		Envs:         v.Envs,
+		UserID:       mirrorUserID,
+	})
+	if err != nil {
+		return err
+	}
+	if version.Envs != nil && v.Envs != nil {
+		for _, env := range v.Envs {
+			if slices.Contains(version.Envs, env) {
+				continue
+			}
+			if _, err := s.PromoteVersion(ctx, db.PromoteVersionParams{
+				CapabilityID: capabilityID,
+				VersionID:    version.ID,
+				Major:        v.Major,
+				ToEnv:        env,
+				UserID:       mirrorUserID,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := s.DeleteMethods(ctx, version.ID); err != nil {
+		return err
+	}
+	for _, method := range v.Methods {
+		if _, err := s.UpsertMethod(ctx, db.UpsertMethodParams{
+			VersionID:    version.ID,
+			Name:         method.Name,
+			Description:  optionalString(method.Description),
+			InputSchema:  method.InputSchema,
+			OutputSchema: method.OutputSchema,
+			Modes:        method.Modes,
+			Tags:         method.Tags,
+			Policies:     method.Policies,
+			Examples:     method.Examples,
+			UserID:       mirrorUserID,
+		}); err != nil {
+			return err
+		}
+	}
+	if _, err := s.ReplaceEndpoints(ctx, version.ID, endpointParams(v.Endpoints), mirrorUserID); err != nil {
+		return err
+	}
+	if version.Status != v.Status {
+		if _, err := s.UpdateVersionStatus(ctx, db.UpdateVersionStatusParams{
+			VersionID: version.ID,
+			Status:    v.Status,
+			Reason:    optionalString(v.DeprecationReason),
+			UserID:    mirrorUserID,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyCatalogRouting writes routing through the repository; see CatalogStore. Cells are
+// upserted before tenants move to them and deleted only after their tenants moved away.
+func (s repoCatalog) ApplyCatalogRouting(ctx context.Context, routing CatalogRouting) error {
+	cells, err := s.ListCells(ctx)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]db.Cell, len(cells))
+	for _, c := range cells {
+		existing[c.Name] = c
+	}
+	cellIDs := make(map[string]string, len(routing.Cells))
+	for _, c := range routing.Cells {
+		if old, ok := existing[c.Name]; ok && old.NatsUrl == c.NatsUrl &&
+			ptrStringOr(old.SubjectPrefix, "") == c.SubjectPrefix && ptrStringOr(old.Description, "") == c.Description {
+			cellIDs[c.Name] = old.ID
+			continue
+		}
+		cell, err := s.UpsertCell(ctx, db.UpsertCellParams{
+			Name:          c.Name,
+			NatsUrl:       c.NatsUrl,
+			SubjectPrefix: optionalString(c.SubjectPrefix),
+			Description:   optionalString(c.Description),
+			UserID:        mirrorUserID,
+		})
+		if err != nil {
+			return err
+		}
+		cellIDs[c.Name] = cell.ID
+	}
+
+	current, err := s.ListTenantCells(ctx)
+	if err != nil {
+		return err
+	}
+	wanted := make(map[string]bool, len(routing.TenantCells))
+	for _, tc := range routing.TenantCells {
+		wanted[tc.TenantID] = true
+		cellID, ok := cellIDs[tc.Cell]
+		if !ok || current[tc.TenantID] == tc.Cell {
+			continue
+		}
+		if _, err := s.AssignTenantCell(ctx, tc.TenantID, cellID, mirrorUserID); err != nil {
+			return err
+		}
+	}
+	for tenantID := range current {
+		if wanted[tenantID] {
+			continue
+		}
+		if _, err := s.RemoveTenantCell(ctx, tenantID); err != nil {
+			return err
+		}
+	}
+	for name := range existing {
+		if _, ok := cellIDs[name]; ok {
+			continue
+		}
+		if _, err := s.DeleteCell(ctx, name); err != nil {
+			return err
+		}
+	}
+
+	for _, rel := range routing.Releases {
+		if err := s.applyCatalogRelease(ctx, rel); err != nil {
+			return fmt.Errorf("release %s: %w", rel.Name, err)
+		}
+	}
+	return nil
+}
+
+// applyCatalogRelease creates an exported release the repository does not have yet.
+// Releases are immutable, so one that exists is left alone.
+func (s repoCatalog) applyCatalogRelease(ctx context.Context, rel CatalogRelease) error {
+	existing, err := s.GetReleaseByName(ctx, rel.Name)
+	if err != nil || existing != nil {
+		return err
+	}
+	pins := make([]db.CreateReleasePin, 0, len(rel.Pins))
+	for _, p := range rel.Pins {
+		parsed, err := semver.ParseCapabilityRef(p.Cap)
+		if err != nil {
+			return err
+		}
+		cap, err := s.GetCapability(ctx, parsed.App, parsed.Name)
+		if err != nil {
+			return err
+		}
+		var versionID string
+		if cap != nil {
+			versions, err := s.GetVersions(ctx, cap.ID)
+			if err != nil {
+				return err
+			}
+			for _, v := range dbVersionsToRecords(versions) {
+				if v.VersionString == p.Version {
+					versionID = v.ID
+					break
+				}
+			}
+		}
+		if versionID == "" {
+			slog.Warn(fmt.Sprintf("%s - skipping release %s: %s@%s is not mirrored yet", mirrorLogPrefix, rel.Name, p.Cap, p.Version))
+			return nil
+		}
+		pins = append(pins, db.CreateReleasePin{CapabilityID: cap.ID, VersionID: versionID})
+	}
+	_, err = s.CreateRelease(ctx, db.CreateReleaseParams{
+		Name:        rel.Name,
+		Description: optionalString(rel.Description),
+		Pins:        pins,
+		UserID:      mirrorUserID,
+	})
+	return err
+}
+
+// Flush does nothing: every repository write is committed when it returns.
+func (s repoCatalog) Flush(ctx context.Context) error {
+	return nil
+}
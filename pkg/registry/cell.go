@@ -0,0 +1,253 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/morezero/capabilities-registry/pkg/commsutil"
+	"github.com/morezero/capabilities-registry/pkg/db"
+	"github.com/morezero/capabilities-registry/pkg/events"
+	"github.com/morezero/capabilities-registry/pkg/semver"
+)
+
+const (
+	cellLogPrefix = "registry:cell"
+
+	// Routing events are published under the registry's own capability.
+	cellEventApp        = "system"
+	cellEventCapability = "registry"
+)
+
+// SetCell creates a NATS cell or updates its URL, subject prefix and description.
+// Changing the URL of a cell publishes a reconnect event for the cell's tenants.
+func (r *Registry) SetCell(ctx context.Context, input *SetCellInput, userID string) (*CellInfo, error) {
+	slog.Info(fmt.Sprintf("%s - setCell name=%s natsUrl=%s", cellLogPrefix, input.Name, input.NatsUrl))
+
+	if err := r.requireRepo(); err != nil {
+		return nil, err
+	}
+	if err := validateSetCellInput(input); err != nil {
+		return nil, err
+	}
+
+	existing, err := r.repo.GetCell(ctx, input.Name)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	cell, err := r.repo.UpsertCell(ctx, db.UpsertCellParams{
+		Name:          input.Name,
+		NatsUrl:       input.NatsUrl,
+		SubjectPrefix: optionalString(input.SubjectPrefix),
+		Description:   optionalString(input.Description),
+		UserID:        userID,
+	})
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+
+	if existing != nil && (existing.NatsUrl != cell.NatsUrl || ptrStringOr(existing.SubjectPrefix, "") != input.SubjectPrefix) {
+		r.publishCellChanged(ctx, "", cell.Name, cell.NatsUrl, existing)
+	}
+
+	counts, err := r.repo.CountCellTenants(ctx)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	info := cellToInfo(cell, counts[cell.Name])
+	return &info, nil
+}
+
+// RemoveCell deletes a cell. A cell that still has tenants cannot be removed.
+func (r *Registry) RemoveCell(ctx context.Context, input *RemoveCellInput) (*RemoveCellOutput, error) {
+	slog.Info(fmt.Sprintf("%s - removeCell name=%s", cellLogPrefix, input.Name))
+
+	if err := r.requireRepo(); err != nil {
+		return nil, err
+	}
+	if input.Name == "" {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: "name is required"}
+	}
+	counts, err := r.repo.CountCellTenants(ctx)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	if n := counts[input.Name]; n > 0 {
+		return nil, &RegistryError{
+			Code:    "INVALID_ARGUMENT",
+			Message: fmt.Sprintf("Cell %s still has %d tenants; move them before removing it", input.Name, n),
+		}
+	}
+	removed, err := r.repo.DeleteCell(ctx, input.Name)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	return &RemoveCellOutput{Removed: removed}, nil
+}
+
+// ListCells returns all cells with their tenant counts.
+func (r *Registry) ListCells(ctx context.Context) (*ListCellsOutput, error) {
+	slog.Info(fmt.Sprintf("%s - listCells", cellLogPrefix))
+
+	if err := r.requireRepo(); err != nil {
+		return nil, err
+	}
+	cells, err := r.repo.ListCells(ctx)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	counts, err := r.repo.CountCellTenants(ctx)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	out := &ListCellsOutput{Cells: make([]CellInfo, len(cells))}
+	for i := range cells {
+		out.Cells[i] = cellToInfo(&cells[i], counts[cells[i].Name])
+	}
+	return out, nil
+}
+
+// AssignTenantCell routes a tenant to a cell. Moving a tenant publishes a reconnect
+// event so the tenant's clients switch to the new cell URL.
+func (r *Registry) AssignTenantCell(ctx context.Context, input *AssignTenantCellInput, userID string) (*TenantCellOutput, error) {
+	slog.Info(fmt.Sprintf("%s - assignTenantCell tenant=%s cell=%s", cellLogPrefix, input.TenantID, input.Cell))
+
+	if err := r.requireRepo(); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(input.TenantID) == "" || input.Cell == "" {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: "tenantId and cell are required"}
+	}
+	cell, err := r.repo.GetCell(ctx, input.Cell)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	if cell == nil {
+		return nil, &RegistryError{Code: "NOT_FOUND", Message: fmt.Sprintf("Cell not found: %s", input.Cell)}
+	}
+
+	previous, err := r.repo.AssignTenantCell(ctx, input.TenantID, cell.ID, userID)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+
+	out := &TenantCellOutput{TenantID: input.TenantID, Cell: cell.Name, NatsUrl: cell.NatsUrl}
+	if previous != nil {
+		out.PreviousCell = previous.Name
+	}
+	if previous == nil || previous.ID != cell.ID {
+		out.Moved = true
+		r.publishCellChanged(ctx, input.TenantID, cell.Name, cell.NatsUrl, previous)
+	}
+	return out, nil
+}
+
+// RemoveTenantCell unmaps a tenant so it uses the registry client URL again.
+func (r *Registry) RemoveTenantCell(ctx context.Context, input *RemoveTenantCellInput) (*TenantCellOutput, error) {
+	slog.Info(fmt.Sprintf("%s - removeTenantCell tenant=%s", cellLogPrefix, input.TenantID))
+
+	if err := r.requireRepo(); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(input.TenantID) == "" {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: "tenantId is required"}
+	}
+	previous, err := r.repo.RemoveTenantCell(ctx, input.TenantID)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+
+	out := &TenantCellOutput{TenantID: input.TenantID, NatsUrl: r.localNatsUrl()}
+	if previous != nil {
+		out.PreviousCell = previous.Name
+		out.Moved = true
+		r.publishCellChanged(ctx, input.TenantID, "", out.NatsUrl, previous)
+	}
+	return out, nil
+}
+
+// TenantNatsUrl returns the NATS URL and cell for a tenant's clients. Unmapped tenants
+// (or an empty tenant) get the registry client URL and no cell.
+func (r *Registry) TenantNatsUrl(ctx context.Context, tenantID string) (natsUrl, cell string) {
+	if c := r.tenantCell(ctx, &ResolutionContext{TenantID: tenantID}); c != nil {
+		return c.NatsUrl, c.Name
+	}
+	return r.localNatsUrl(), ""
+}
+
+// tenantCell returns the cell the caller's tenant is routed to, or nil. Lookup failures
+// are logged and fall back to the registry URL.
+func (r *Registry) tenantCell(ctx context.Context, rctx *ResolutionContext) *db.Cell {
+	tenantID := tenantOf(rctx)
+	if tenantID == "" || r.catalog == nil {
+		return nil
+	}
+	cell, err := r.catalog.GetTenantCell(ctx, tenantID)
+	if err != nil {
+		slog.Error(fmt.Sprintf("%s - GetTenantCell failed for tenant %s: %v", cellLogPrefix, tenantID, err))
+		return nil
+	}
+	return cell
+}
+
+func cellName(c *db.Cell) string {
+	if c == nil {
+		return ""
+	}
+	return c.Name
+}
+
+// publishCellChanged tells the clients of tenantID (all tenants of cell when empty) to
+// reconnect to natsUrl; previous is the cell as it was before the change, if any.
+func (r *Registry) publishCellChanged(ctx context.Context, tenantID, cell, natsUrl string, previous *db.Cell) {
+	event := &events.RegistryChangedEvent{
+		Kind:           events.KindTenantCellChanged,
+		App:            cellEventApp,
+		Capability:     cellEventCapability,
+		ChangedFields:  []string{"tenantCell"},
+		AffectedMajors: []int{},
+		Timestamp:      time.Now().UTC().Format(time.RFC3339),
+		TenantID:       tenantID,
+		Cell:           cell,
+		NatsUrl:        natsUrl,
+		Reconnect:      true,
+		New:            map[string]interface{}{"cell": cell, "natsUrl": natsUrl},
+	}
+	if previous != nil {
+		event.Previous = map[string]interface{}{"cell": previous.Name, "natsUrl": previous.NatsUrl}
+	}
+	if err := r.publisher.PublishChanged(ctx, event); err != nil {
+		slog.Error(fmt.Sprintf("%s - PublishChanged failed: %v", cellLogPrefix, err))
+	}
+}
+
+func validateSetCellInput(input *SetCellInput) *RegistryError {
+	if !semver.ValidateAppName(input.Name) {
+		return &RegistryError{Code: "INVALID_ARGUMENT", Message: "name must be lowercase alphanumeric with hyphens only"}
+	}
+	if err := validateNatsUrl(input.NatsUrl); err != nil {
+		return &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("natsUrl %v", err)}
+	}
+	if input.SubjectPrefix != "" {
+		if strings.ContainsAny(input.SubjectPrefix, "{}") {
+			return &RegistryError{Code: "INVALID_ARGUMENT", Message: "subjectPrefix must not contain template tokens"}
+		}
+		if err := commsutil.ValidateSubjectTemplate(input.SubjectPrefix); err != nil {
+			return &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("subjectPrefix: %v", err)}
+		}
+	}
+	return nil
+}
+
+func cellToInfo(c *db.Cell, tenantCount int) CellInfo {
+	return CellInfo{
+		Name:          c.Name,
+		NatsUrl:       c.NatsUrl,
+		SubjectPrefix: ptrStringOr(c.SubjectPrefix, ""),
+		Description:   ptrStringOr(c.Description, ""),
+		TenantCount:   tenantCount,
+		Modified:      c.Modified.UTC().Format(time.RFC3339),
+	}
+}
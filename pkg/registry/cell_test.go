@@ -0,0 +1,94 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/morezero/capabilities-registry/pkg/commsutil"
+	"github.com/morezero/capabilities-registry/pkg/db"
+)
+
+const cellTestPrefix = "registry:cell_test"
+
+func TestValidateSetCellInput(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     SetCellInput
+		expectErr bool
+	}{
+		{"valid", SetCellInput{Name: "eu-1", NatsUrl: "nats://eu-1.example:4222"}, false},
+		{"valid prefix", SetCellInput{Name: "eu-1", NatsUrl: "tls://eu-1.example:4222", SubjectPrefix: "eu1.cap"}, false},
+		{"bad name", SetCellInput{Name: "EU 1", NatsUrl: "nats://eu-1.example:4222"}, true},
+		{"missing url", SetCellInput{Name: "eu-1"}, true},
+		{"http url", SetCellInput{Name: "eu-1", NatsUrl: "http://eu-1.example"}, true},
+		{"token prefix", SetCellInput{Name: "eu-1", NatsUrl: "nats://eu-1.example:4222", SubjectPrefix: "{env}"}, true},
+		{"wildcard prefix", SetCellInput{Name: "eu-1", NatsUrl: "nats://eu-1.example:4222", SubjectPrefix: "eu.*"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSetCellInput(&tt.input)
+			if tt.expectErr && (err == nil || err.Code != "INVALID_ARGUMENT") {
+				t.Errorf("%s - expected INVALID_ARGUMENT, got %v", cellTestPrefix, err)
+			}
+			if !tt.expectErr && err != nil {
+				t.Errorf("%s - unexpected error: %v", cellTestPrefix, err)
+			}
+		})
+	}
+}
+
+func TestSelectEndpoints_Cell(t *testing.T) {
+	reg := NewRegistry(NewRegistryParams{Repo: nil, Publisher: nil, Config: Config{NatsUrl: "nats://registry:4222"}})
+	prefix := "eu1"
+	cell := &db.Cell{Name: "eu-1", NatsUrl: "nats://eu-1.example:4222", SubjectPrefix: &prefix}
+	params := commsutil.SubjectParams{App: "more0", Name: "doc.ingest", Major: 2}
+
+	got := reg.selectEndpoints(nil, "", params, nil, cell)
+	if got.NatsUrl != "nats://eu-1.example:4222" || got.Subject != "eu1.more0.doc_ingest.v2" {
+		t.Errorf("%s - cell fallback = %+v", cellTestPrefix, got)
+	}
+
+	// Registered endpoints still win over the cell URL.
+	eps := []db.CapabilityEndpoint{{NatsUrl: "nats://provider:4222", Weight: 100}}
+	got = reg.selectEndpoints(eps, "", params, nil, cell)
+	if got.NatsUrl != "nats://provider:4222" || got.Subject != "eu1.more0.doc_ingest.v2" {
+		t.Errorf("%s - endpoint with cell = %+v", cellTestPrefix, got)
+	}
+}
+
+func TestTenantNatsUrl_NoRepo(t *testing.T) {
+	reg := NewRegistry(NewRegistryParams{Repo: nil, Publisher: nil, Config: Config{NatsUrl: "nats://registry:4222"}})
+	url, cell := reg.TenantNatsUrl(context.Background(), "acme")
+	if url != "nats://registry:4222" || cell != "" {
+		t.Errorf("%s - TenantNatsUrl = %q %q, want registry URL", cellTestPrefix, url, cell)
+	}
+	if got := cellName(nil); got != "" {
+		t.Errorf("%s - cellName(nil) = %q", cellTestPrefix, got)
+	}
+}
+
+func TestCellMethods_RequireRepo(t *testing.T) {
+	reg := NewRegistry(NewRegistryParams{Repo: nil, Publisher: nil, Config: DefaultConfig()})
+	ctx := context.Background()
+
+	_, err := reg.SetCell(ctx, &SetCellInput{Name: "eu-1", NatsUrl: "nats://eu-1.example:4222"}, "test-user")
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INTERNAL_ERROR" {
+		t.Errorf("%s - SetCell: expected INTERNAL_ERROR, got %v", cellTestPrefix, err)
+	}
+	_, err = reg.RemoveCell(ctx, &RemoveCellInput{Name: "eu-1"})
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INTERNAL_ERROR" {
+		t.Errorf("%s - RemoveCell: expected INTERNAL_ERROR, got %v", cellTestPrefix, err)
+	}
+	_, err = reg.ListCells(ctx)
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INTERNAL_ERROR" {
+		t.Errorf("%s - ListCells: expected INTERNAL_ERROR, got %v", cellTestPrefix, err)
+	}
+	_, err = reg.AssignTenantCell(ctx, &AssignTenantCellInput{TenantID: "acme", Cell: "eu-1"}, "test-user")
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INTERNAL_ERROR" {
+		t.Errorf("%s - AssignTenantCell: expected INTERNAL_ERROR, got %v", cellTestPrefix, err)
+	}
+	_, err = reg.RemoveTenantCell(ctx, &RemoveTenantCellInput{TenantID: "acme"})
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INTERNAL_ERROR" {
+		t.Errorf("%s - RemoveTenantCell: expected INTERNAL_ERROR, got %v", cellTestPrefix, err)
+	}
+}
@@ -0,0 +1,203 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/morezero/capabilities-registry/pkg/db"
+	"github.com/morezero/capabilities-registry/pkg/events"
+)
+
+const (
+	changesLogPrefix    = "registry:changes"
+	changesDefaultLimit = 100
+	changesMaxLimit     = 1000
+	// changePruneInterval is how often changes older than the retention are deleted.
+	changePruneInterval = time.Hour
+)
+
+// changeGapKind is the kind of the marker recorded in the change feed in place of changes
+// that could not be recorded; changesSince answers resync to readers before it.
+const changeGapKind = "changeGap"
+
+// changeRecorder is the EventPublisher of a registry with a repository: it stores every
+// change event in the change feed, which assigns its global sequence, and then publishes it.
+type changeRecorder struct {
+	repo *db.Repository
+	next events.EventPublisher
+
+	// mu guards gap, which is set while a change that could not be recorded has no gap
+	// marker in the feed yet.
+	mu  sync.Mutex
+	gap bool
+}
+
+// PublishChanged records event and sets its Sequence before publishing. An event that
+// could not be recorded is still published, without a sequence, and a gap marker is
+// recorded as soon as the feed accepts writes again.
+func (p *changeRecorder) PublishChanged(ctx context.Context, event *events.RegistryChangedEvent) error {
+	stampActor(ctx, event)
+	p.recordGap(ctx)
+	event.Sequence = 0
+	data, err := json.Marshal(event)
+	if err == nil {
+		event.Sequence, err = p.repo.RecordChange(ctx, event.App, event.Capability, data)
+	}
+	if err != nil {
+		slog.Error(fmt.Sprintf("%s - recording change of %s.%s failed: %v", changesLogPrefix, event.App, event.Capability, err))
+		p.mu.Lock()
+		p.gap = true
+		p.mu.Unlock()
+	}
+	return p.next.PublishChanged(ctx, event)
+}
+
+// recordGap records a gap marker after a change could not be recorded, so feed readers
+// resync instead of silently missing the change. It keeps trying on later calls until
+// the marker is stored.
+func (p *changeRecorder) recordGap(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.gap {
+		return
+	}
+	marker, err := json.Marshal(&events.RegistryChangedEvent{
+		Kind:       changeGapKind,
+		App:        "system",
+		Capability: "registry",
+		Timestamp:  time.Now().UTC().Format(time.RFC3339),
+	})
+	if err == nil {
+		_, err = p.repo.RecordChange(ctx, "system", "registry", marker)
+	}
+	if err != nil {
+		slog.Error(fmt.Sprintf("%s - recording the change gap marker failed: %v", changesLogPrefix, err))
+		return
+	}
+	slog.Warn(fmt.Sprintf("%s - recorded a change gap marker; feed readers will resync", changesLogPrefix))
+	p.gap = false
+}
+
+type changeActorKey struct{}
+
+// changeActor is the user and request a mutation is made for.
+type changeActor struct {
+	userID    string
+	requestID string
+}
+
+// WithActor returns ctx carrying the user and request a mutation is made for, so the change
+// events it publishes name them as Actor and RequestID.
+func WithActor(ctx context.Context, userID, requestID string) context.Context {
+	return context.WithValue(ctx, changeActorKey{}, changeActor{userID: userID, requestID: requestID})
+}
+
+// stampActor sets the Actor and RequestID of event from ctx unless they are already set.
+func stampActor(ctx context.Context, event *events.RegistryChangedEvent) {
+	actor, ok := ctx.Value(changeActorKey{}).(changeActor)
+	if !ok {
+		return
+	}
+	if event.Actor == "" {
+		event.Actor = actor.userID
+	}
+	if event.RequestID == "" {
+		event.RequestID = actor.requestID
+	}
+}
+
+// ChangesSince returns the change events after input.Since in sequence order. Next is the
+// sequence to pass to the following call and HasMore is set while more changes are
+// waiting. Resync is set, without changes, when the changes after Since were pruned, a
+// change after Since could not be recorded (a gap marker), or Since is ahead of the feed
+// (the registry was reset): the caller must rebuild its state and continue from Latest.
+func (r *Registry) ChangesSince(ctx context.Context, input *ChangesSinceInput) (*ChangesSinceOutput, error) {
+	slog.Debug(fmt.Sprintf("%s - since=%d limit=%d", changesLogPrefix, input.Since, input.Limit))
+
+	if err := r.requireRepo(); err != nil {
+		return nil, err
+	}
+	if r.recorder != nil {
+		r.recorder.recordGap(ctx)
+	}
+	if input.Since < 0 {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: "since must not be negative"}
+	}
+	limit := input.Limit
+	if limit < 1 {
+		limit = changesDefaultLimit
+	}
+	if limit > changesMaxLimit {
+		limit = changesMaxLimit
+	}
+
+	oldest, latest, err := r.repo.ChangeSequenceBounds(ctx)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	out := &ChangesSinceOutput{Changes: []events.RegistryChangedEvent{}, Next: input.Since, Latest: latest}
+	if input.Since > latest || (oldest > 0 && input.Since < oldest-1) {
+		out.Resync = true
+		out.Next = latest
+		return out, nil
+	}
+
+	// One extra row tells whether another page follows
+	rows, err := r.repo.ListChangesSince(ctx, input.Since, limit+1)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	if len(rows) > limit {
+		rows, out.HasMore = rows[:limit], true
+	}
+	for _, row := range rows {
+		var event events.RegistryChangedEvent
+		if err := json.Unmarshal(row.Event, &event); err != nil {
+			slog.Error(fmt.Sprintf("%s - change %d has an invalid event: %v", changesLogPrefix, row.Seq, err))
+			event = events.RegistryChangedEvent{App: row.App, Capability: row.Capability, AffectedMajors: []int{}}
+		}
+		if event.Kind == changeGapKind {
+			return &ChangesSinceOutput{Changes: []events.RegistryChangedEvent{}, Next: latest, Latest: latest, Resync: true}, nil
+		}
+		event.Sequence = row.Seq
+		out.Changes = append(out.Changes, event)
+		out.Next = row.Seq
+	}
+	return out, nil
+}
+
+// StartChangePruner deletes changes and finished webhook deliveries older than retention
+// every hour until ctx is cancelled.
+// It does nothing without a repository or with a non-positive retention (keep forever).
+func (r *Registry) StartChangePruner(ctx context.Context, retention time.Duration) {
+	if r.repo == nil || retention <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(changePruneInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				pruned, err := r.repo.PruneChanges(ctx, time.Now().Add(-retention))
+				if err != nil {
+					slog.Error(fmt.Sprintf("%s - prune failed: %v", changesLogPrefix, err))
+				} else if pruned > 0 {
+					slog.Info(fmt.Sprintf("%s - pruned %d changes older than %s", changesLogPrefix, pruned, retention))
+				}
+				pruned, err = r.repo.PruneWebhookDeliveries(ctx, time.Now().Add(-retention))
+				if err != nil {
+					slog.Error(fmt.Sprintf("%s - prune webhook deliveries failed: %v", changesLogPrefix, err))
+				} else if pruned > 0 {
+					slog.Info(fmt.Sprintf("%s - pruned %d webhook deliveries older than %s", changesLogPrefix, pruned, retention))
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,48 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/morezero/capabilities-registry/pkg/events"
+)
+
+const changesTestPrefix = "registry:changes_test"
+
+func TestChangesSince_RequiresRepo(t *testing.T) {
+	r := NewRegistry(NewRegistryParams{Config: DefaultConfig()})
+	_, err := r.ChangesSince(context.Background(), &ChangesSinceInput{Since: 5})
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INTERNAL_ERROR" {
+		t.Errorf("%s - expected INTERNAL_ERROR, got %v", changesTestPrefix, err)
+	}
+}
+
+func TestNewRegistry_RecordsChangesOnlyWithRepo(t *testing.T) {
+	r := NewRegistry(NewRegistryParams{Config: DefaultConfig()})
+	if _, ok := r.publisher.(*changeRecorder); ok {
+		t.Errorf("%s - a registry without a repository must not record changes", changesTestPrefix)
+	}
+	if _, ok := r.publisher.(*events.NoOpPublisher); !ok {
+		t.Errorf("%s - expected the NoOpPublisher, got %T", changesTestPrefix, r.publisher)
+	}
+}
+
+func TestStampActor(t *testing.T) {
+	event := &events.RegistryChangedEvent{App: "more0", Capability: "doc-ingest"}
+	stampActor(context.Background(), event)
+	if event.Actor != "" || event.RequestID != "" {
+		t.Errorf("%s - a context without an actor must not stamp, got %q/%q", changesTestPrefix, event.Actor, event.RequestID)
+	}
+
+	ctx := WithActor(context.Background(), "user-1", "req-9")
+	stampActor(ctx, event)
+	if event.Actor != "user-1" || event.RequestID != "req-9" {
+		t.Errorf("%s - actor = %q/%q, want user-1/req-9", changesTestPrefix, event.Actor, event.RequestID)
+	}
+
+	forwarded := &events.RegistryChangedEvent{Actor: "upstream-user", RequestID: "req-1"}
+	stampActor(ctx, forwarded)
+	if forwarded.Actor != "upstream-user" || forwarded.RequestID != "req-1" {
+		t.Errorf("%s - an event's own actor must be kept, got %q/%q", changesTestPrefix, forwarded.Actor, forwarded.RequestID)
+	}
+}
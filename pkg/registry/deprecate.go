@@ -41,6 +41,7 @@ func (r *Registry) Deprecate(ctx context.Context, input *DeprecateInput, userID
 
 	var affectedVersions []string
 	affectedMajorsMap := make(map[int]bool)
+	previousStatus := make(map[string]string)
 
 	for _, v := range versions {
 		pre := ""
@@ -71,6 +72,7 @@ func (r *Registry) Deprecate(ctx context.Context, input *DeprecateInput, userID
 			}
 			affectedVersions = append(affectedVersions, vStr)
 			affectedMajorsMap[v.Major] = true
+			previousStatus[vStr] = v.Status
 		}
 	}
 
@@ -82,13 +84,17 @@ func (r *Registry) Deprecate(ctx context.Context, input *DeprecateInput, userID
 	// Publish event
 	revision, _ := r.repo.IncrementRevision(ctx, cap.ID)
 	_ = r.publisher.PublishChanged(ctx, &events.RegistryChangedEvent{
-		App:            parsed.App,
-		Capability:     parsed.Name,
-		ChangedFields:  []string{"status"},
-		AffectedMajors: affectedMajors,
-		Revision:       revision,
-		Etag:           fmt.Sprintf("%s-%d", cap.ID, revision),
-		Timestamp:      time.Now().UTC().Format(time.RFC3339),
+		Kind:             events.KindDeprecated,
+		App:              parsed.App,
+		Capability:       parsed.Name,
+		ChangedFields:    []string{"status"},
+		AffectedMajors:   affectedMajors,
+		AffectedVersions: affectedVersions,
+		Previous:         map[string]interface{}{"status": previousStatus},
+		New:              map[string]interface{}{"status": "deprecated", "reason": input.Reason},
+		Revision:         revision,
+		Etag:             fmt.Sprintf("%s-%d", cap.ID, revision),
+		Timestamp:        time.Now().UTC().Format(time.RFC3339),
 	})
 
 	return &DeprecateOutput{
@@ -125,6 +131,7 @@ func (r *Registry) Disable(ctx context.Context, input *DisableInput, userID stri
 
 	var affectedVersions []string
 	affectedMajorsMap := make(map[int]bool)
+	previousStatus := make(map[string]string)
 
 	for _, v := range versions {
 		pre := ""
@@ -155,6 +162,7 @@ func (r *Registry) Disable(ctx context.Context, input *DisableInput, userID stri
 			}
 			affectedVersions = append(affectedVersions, vStr)
 			affectedMajorsMap[v.Major] = true
+			previousStatus[vStr] = v.Status
 		}
 	}
 
@@ -166,13 +174,17 @@ func (r *Registry) Disable(ctx context.Context, input *DisableInput, userID stri
 	// Publish event
 	revision, _ := r.repo.IncrementRevision(ctx, cap.ID)
 	_ = r.publisher.PublishChanged(ctx, &events.RegistryChangedEvent{
-		App:            parsed.App,
-		Capability:     parsed.Name,
-		ChangedFields:  []string{"status"},
-		AffectedMajors: affectedMajors,
-		Revision:       revision,
-		Etag:           fmt.Sprintf("%s-%d", cap.ID, revision),
-		Timestamp:      time.Now().UTC().Format(time.RFC3339),
+		Kind:             events.KindDisabled,
+		App:              parsed.App,
+		Capability:       parsed.Name,
+		ChangedFields:    []string{"status"},
+		AffectedMajors:   affectedMajors,
+		AffectedVersions: affectedVersions,
+		Previous:         map[string]interface{}{"status": previousStatus},
+		New:              map[string]interface{}{"status": "disabled", "reason": input.Reason},
+		Revision:         revision,
+		Etag:             fmt.Sprintf("%s-%d", cap.ID, revision),
+		Timestamp:        time.Now().UTC().Format(time.RFC3339),
 	})
 
 	return &DisableOutput{
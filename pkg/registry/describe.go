@@ -18,24 +18,26 @@ const describeLogPrefix = "registry:describe"
 func (r *Registry) Describe(ctx context.Context, input *DescribeInput) (*DescribeOutput, error) {
 	slog.Info(fmt.Sprintf("%s - cap=%s", describeLogPrefix, input.Cap))
 
-	if err := r.requireRepo(); err != nil {
+	if err := r.requireCatalog(); err != nil {
 		return nil, err
 	}
 
-	parsed, err := semver.ParseCapabilityRef(input.Cap)
-	if err != nil {
-		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: err.Error()}
+	// "@alias/app/name" describes a capability of another registry
+	if alias, capRef := extractAlias(input.Cap); alias != "" {
+		if alias != r.defaultAlias() {
+			return r.describeRemote(ctx, input, alias, capRef)
+		}
+		local := *input
+		local.Cap = dottedCapRef(capRef)
+		input = &local
 	}
 
-	cap, err := r.repo.GetCapability(ctx, parsed.App, parsed.Name)
-	if err != nil {
-		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
-	}
-	if cap == nil {
-		return nil, &RegistryError{Code: "NOT_FOUND", Message: fmt.Sprintf("Capability not found: %s", parsed.Full)}
+	parsed, cap, warnings, regErr := r.lookupCapabilityFollowingAliases(ctx, input.Cap)
+	if regErr != nil {
+		return nil, regErr
 	}
 
-	versions, err := r.repo.GetVersions(ctx, cap.ID)
+	versions, err := r.catalog.GetVersions(ctx, cap.ID)
 	if err != nil {
 		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
 	}
@@ -81,7 +83,7 @@ func (r *Registry) Describe(ctx context.Context, input *DescribeInput) (*Describ
 		}
 	}
 
-	methods, err := r.repo.GetMethods(ctx, targetVersion.ID)
+	methods, err := r.catalog.GetMethods(ctx, targetVersion.ID)
 	if err != nil {
 		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
 	}
@@ -119,16 +121,21 @@ func (r *Registry) Describe(ctx context.Context, input *DescribeInput) (*Describ
 	}
 
 	return &DescribeOutput{
-		Cap:         fmt.Sprintf("%s.%s", cap.App, cap.Name),
-		App:         cap.App,
-		Name:        cap.Name,
-		Description: desc,
-		Version:     semver.ToVersionString(targetVersion.Major, targetVersion.Minor, targetVersion.Patch, pre),
-		Major:       targetVersion.Major,
-		Status:      targetVersion.Status,
-		Methods:     methodDescs,
-		Tags:        cap.Tags,
-		Changelog:   changelog,
+		Cap:            fmt.Sprintf("%s.%s", cap.App, cap.Name),
+		App:            cap.App,
+		Name:           cap.Name,
+		Description:    desc,
+		Version:        semver.ToVersionString(targetVersion.Major, targetVersion.Minor, targetVersion.Patch, pre),
+		Major:          targetVersion.Major,
+		Status:         targetVersion.Status,
+		Methods:        methodDescs,
+		Tags:           cap.Tags,
+		Changelog:      changelog,
+		Warnings:       warnings,
+		Instances:      r.liveInstanceCount(ctx, targetVersion.ID),
+		LivenessPolicy: livenessPolicy(cap),
+		Availability:   r.versionAvailability(ctx, targetVersion.ID),
+		Source:         cap.Source,
 	}, nil
 }
 
@@ -142,3 +149,21 @@ func jsonBytesToSlice(data []byte) []interface{} {
 	}
 	return s
 }
+
+// describeRemote describes a capability through the federation pool and qualifies the
+// returned cap with the alias it came from.
+func (r *Registry) describeRemote(ctx context.Context, input *DescribeInput, alias string, capRef string) (*DescribeOutput, error) {
+	slog.Info(fmt.Sprintf("%s - Federated describe alias=%s cap=%s", describeLogPrefix, alias, capRef))
+
+	if r.federationPool == nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: "Federation pool not initialized"}
+	}
+	remote := *input
+	remote.Cap = capRef
+	out, err := r.federationPool.Describe(ctx, alias, &remote)
+	if err != nil {
+		return nil, err
+	}
+	out.Cap = qualifiedCap(alias, out.App, out.Name)
+	return out, nil
+}
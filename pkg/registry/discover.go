@@ -5,24 +5,31 @@ import (
 	"fmt"
 	"log/slog"
 	"math"
+	"strings"
+	"sync"
 
 	"github.com/morezero/capabilities-registry/pkg/db"
 	"github.com/morezero/capabilities-registry/pkg/semver"
 )
 
 const (
-	discoverLogPrefix   = "registry:discover"
+	discoverLogPrefix    = "registry:discover"
 	discoverDefaultLimit = 20
 	discoverMaxLimit     = 500
+	discoverMaxAliases   = 16
 )
 
-// Discover lists capabilities matching filters.
+// Discover lists capabilities matching filters. With aliases it searches each selected
+// registry (see discoverFederated).
 func (r *Registry) Discover(ctx context.Context, input *DiscoverInput) (*DiscoverOutput, error) {
 	slog.Info(fmt.Sprintf("%s - app=%s query=%s", discoverLogPrefix, input.App, input.Query))
 
-	if err := r.requireRepo(); err != nil {
+	if err := r.requireCatalog(); err != nil {
 		return nil, err
 	}
+	if len(input.Aliases) > 0 {
+		return r.discoverFederated(ctx, input)
+	}
 
 	page := input.Page
 	if page < 1 {
@@ -41,7 +48,7 @@ func (r *Registry) Discover(ctx context.Context, input *DiscoverInput) (*Discove
 		status = "Active"
 	}
 
-	caps, total, err := r.repo.ListCapabilities(ctx, db.ListCapabilitiesParams{
+	caps, total, err := r.catalog.ListCapabilities(ctx, db.ListCapabilitiesParams{
 		App:    input.App,
 		Tags:   input.Tags,
 		Query:  input.Query,
@@ -60,19 +67,49 @@ func (r *Registry) Discover(ctx context.Context, input *DiscoverInput) (*Discove
 		capIDs = append(capIDs, c.ID)
 	}
 
-	versionsByCap, err := r.repo.GetVersionsByCapabilityIDs(ctx, capIDs)
+	versionsByCap, err := r.catalog.GetVersionsByCapabilityIDs(ctx, capIDs)
 	if err != nil {
 		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
 	}
-	defaultsByCap, err := r.repo.GetDefaultsBatch(ctx, capIDs, env)
+	defaultsByCap, err := r.catalog.GetDefaultsBatch(ctx, capIDs, env)
 	if err != nil {
 		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
 	}
 
+	// Pins of the release named in ctx override the default major
+	pinsByCap := map[string]db.ReleasePin{}
+	release, regErr := r.releaseFromContext(ctx, input.Ctx)
+	if regErr != nil {
+		return nil, regErr
+	}
+	if release != nil {
+		pins, err := r.catalog.GetReleasePins(ctx, release.ID)
+		if err != nil {
+			return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+		}
+		for _, p := range pins {
+			pinsByCap[p.CapabilityID] = p
+		}
+	}
+
+	// Live instance counts for every version available in the env, in one query
+	recordsByCap := make(map[string][]semver.VersionRecord, len(caps))
+	var versionIDs []string
+	for _, cap := range caps {
+		records := semver.FilterByEnv(dbVersionsToRecords(versionsByCap[cap.ID]), env)
+		recordsByCap[cap.ID] = records
+		for _, v := range records {
+			versionIDs = append(versionIDs, v.ID)
+		}
+	}
+	liveByVersion, probesByVersion, regErr := r.versionLiveness(ctx, versionIDs)
+	if regErr != nil {
+		return nil, regErr
+	}
+
 	capabilities := make([]DiscoveredCapability, 0, len(caps))
 	for _, cap := range caps {
-		versions := versionsByCap[cap.ID]
-		records := dbVersionsToRecords(versions)
+		records := recordsByCap[cap.ID]
 		majors := semver.GetUniqueMajors(records)
 
 		defaultEntry := defaultsByCap[cap.ID]
@@ -83,9 +120,28 @@ func (r *Registry) Discover(ctx context.Context, input *DiscoverInput) (*Discove
 			defaultMajor = majors[0]
 		}
 
+		releaseVersion := ""
+		if pin, ok := pinsByCap[cap.ID]; ok {
+			defaultMajor = pin.Major
+			releaseVersion = pin.VersionString
+		}
+
 		latestVersion := "0.0.0"
+		availability := ""
 		if len(records) > 0 {
 			latestVersion = records[0].VersionString
+			if p := probesByVersion[records[0].ID]; p != nil {
+				availability = p.Status
+			}
+		}
+
+		instances := 0
+		var unreachable []string
+		for _, v := range records {
+			instances += liveByVersion[v.ID]
+			if p := probesByVersion[v.ID]; p != nil && p.Status == db.ProbeStatusUnreachable {
+				unreachable = append(unreachable, v.VersionString)
+			}
 		}
 
 		desc := ""
@@ -94,15 +150,20 @@ func (r *Registry) Discover(ctx context.Context, input *DiscoverInput) (*Discove
 		}
 
 		capabilities = append(capabilities, DiscoveredCapability{
-			Cap:           fmt.Sprintf("%s.%s", cap.App, cap.Name),
-			App:           cap.App,
-			Name:          cap.Name,
-			Description:   desc,
-			Tags:          cap.Tags,
-			DefaultMajor:  defaultMajor,
-			LatestVersion: latestVersion,
-			Majors:        majors,
-			Status:        cap.Status,
+			Cap:                 fmt.Sprintf("%s.%s", cap.App, cap.Name),
+			App:                 cap.App,
+			Name:                cap.Name,
+			Description:         desc,
+			Tags:                cap.Tags,
+			DefaultMajor:        defaultMajor,
+			LatestVersion:       latestVersion,
+			Majors:              majors,
+			Status:              cap.Status,
+			ReleaseVersion:      releaseVersion,
+			Instances:           instances,
+			Availability:        availability,
+			UnreachableVersions: unreachable,
+			Source:              cap.Source,
 		})
 	}
 
@@ -116,3 +177,97 @@ func (r *Registry) Discover(ctx context.Context, input *DiscoverInput) (*Discove
 		},
 	}, nil
 }
+
+// versionLiveness returns the live instance counts and latest probes of versionIDs. A
+// registry without a repository tracks neither, so both are empty.
+func (r *Registry) versionLiveness(ctx context.Context, versionIDs []string) (map[string]int, map[string]*db.CapabilityProbe, *RegistryError) {
+	if r.repo == nil {
+		return map[string]int{}, map[string]*db.CapabilityProbe{}, nil
+	}
+	live, err := r.repo.CountLiveInstances(ctx, versionIDs)
+	if err != nil {
+		return nil, nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	probes, err := r.repo.GetProbes(ctx, versionIDs)
+	if err != nil {
+		return nil, nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	return live, probes, nil
+}
+
+// discoverFederated runs discover against every selected alias concurrently: the default
+// alias searches the local database, others go through the federation pool with the same
+// filters and page. Remote capabilities get alias-qualified cap values. A failing alias is
+// reported in AliasErrors instead of failing the whole call. Totals are summed and
+// TotalPages is the largest of any alias.
+func (r *Registry) discoverFederated(ctx context.Context, input *DiscoverInput) (*DiscoverOutput, error) {
+	var aliases []string
+	seen := make(map[string]bool)
+	for _, a := range input.Aliases {
+		a = strings.TrimPrefix(strings.TrimSpace(a), "@")
+		if a == "" {
+			return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: "aliases must not contain empty entries"}
+		}
+		if !seen[a] {
+			seen[a] = true
+			aliases = append(aliases, a)
+		}
+	}
+	if len(aliases) > discoverMaxAliases {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("at most %d aliases may be searched", discoverMaxAliases)}
+	}
+
+	single := *input
+	single.Aliases = nil
+	localAlias := r.defaultAlias()
+
+	results := make([]*DiscoverOutput, len(aliases))
+	errs := make([]error, len(aliases))
+	var wg sync.WaitGroup
+	for i, alias := range aliases {
+		wg.Add(1)
+		go func(i int, alias string) {
+			defer wg.Done()
+			if alias == localAlias {
+				results[i], errs[i] = r.Discover(ctx, &single)
+				return
+			}
+			if r.federationPool == nil {
+				errs[i] = &RegistryError{Code: "INTERNAL_ERROR", Message: "Federation pool not initialized"}
+				return
+			}
+			results[i], errs[i] = r.federationPool.Discover(ctx, alias, &single)
+		}(i, alias)
+	}
+	wg.Wait()
+
+	out := &DiscoverOutput{Capabilities: []DiscoveredCapability{}}
+	for i, alias := range aliases {
+		if errs[i] != nil {
+			code, message := "INTERNAL_ERROR", errs[i].Error()
+			if regErr, ok := errs[i].(*RegistryError); ok {
+				code, message = regErr.Code, regErr.Message
+			}
+			out.AliasErrors = append(out.AliasErrors, AliasError{Alias: alias, Code: code, Message: message})
+			continue
+		}
+		for _, c := range results[i].Capabilities {
+			c.Alias = alias
+			if alias != localAlias {
+				c.Cap = qualifiedCap(alias, c.App, c.Name)
+			}
+			out.Capabilities = append(out.Capabilities, c)
+		}
+		p := results[i].Pagination
+		out.Pagination.Page, out.Pagination.Limit = p.Page, p.Limit
+		out.Pagination.Total += p.Total
+		if p.TotalPages > out.Pagination.TotalPages {
+			out.Pagination.TotalPages = p.TotalPages
+		}
+	}
+	if out.Pagination.Page == 0 {
+		out.Pagination.Page = max(input.Page, 1)
+		out.Pagination.Limit = input.Limit
+	}
+	return out, nil
+}
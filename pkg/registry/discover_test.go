@@ -34,3 +34,12 @@ func TestDiscover_DefaultPageAndLimit(t *testing.T) {
 		t.Fatalf("%s - expected error (nil repo)", discoverTestPrefix)
 	}
 }
+
+func TestDiscover_AliasesRequireRepo(t *testing.T) {
+	reg := NewRegistry(NewRegistryParams{Repo: nil, Publisher: nil, Config: DefaultConfig()})
+
+	_, err := reg.Discover(context.Background(), &DiscoverInput{Aliases: []string{"main", "partner"}})
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INTERNAL_ERROR" {
+		t.Errorf("%s - expected INTERNAL_ERROR, got %v", discoverTestPrefix, err)
+	}
+}
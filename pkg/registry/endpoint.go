@@ -0,0 +1,181 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sort"
+
+	"github.com/morezero/capabilities-registry/pkg/commsutil"
+	"github.com/morezero/capabilities-registry/pkg/db"
+)
+
+const (
+	endpointLogPrefix     = "registry:endpoint"
+	maxVersionEndpoints   = 32
+	maxEndpointLabelLen   = 64
+	maxEndpointWeight     = 10000
+	defaultEndpointWeight = 100
+)
+
+// validateEndpoints checks provider endpoints registered through upsert.
+func validateEndpoints(endpoints []EndpointInput) *RegistryError {
+	if len(endpoints) > maxVersionEndpoints {
+		return &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("endpoints count exceeds maximum %d", maxVersionEndpoints)}
+	}
+	for i, ep := range endpoints {
+		if err := validateNatsUrl(ep.NatsUrl); err != nil {
+			return &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("endpoints[%d].natsUrl %v", i, err)}
+		}
+		if ep.Subject != "" {
+			if err := commsutil.ValidateSubjectTemplate(ep.Subject); err != nil {
+				return &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("endpoints[%d].subject: %v", i, err)}
+			}
+		}
+		if len(ep.Region) > maxEndpointLabelLen || len(ep.Zone) > maxEndpointLabelLen {
+			return &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("endpoints[%d] region and zone must be at most %d characters", i, maxEndpointLabelLen)}
+		}
+		if ep.Priority < 0 {
+			return &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("endpoints[%d].priority must not be negative", i)}
+		}
+		if ep.Weight != nil && (*ep.Weight < 0 || *ep.Weight > maxEndpointWeight) {
+			return &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("endpoints[%d].weight must be 0-%d", i, maxEndpointWeight)}
+		}
+	}
+	return nil
+}
+
+// validateNatsUrl checks that s is a nats, tls, ws or wss URL with a host.
+func validateNatsUrl(s string) error {
+	u, err := url.Parse(s)
+	if s == "" || err != nil || u.Host == "" {
+		return fmt.Errorf("must be a URL with a host")
+	}
+	switch u.Scheme {
+	case "nats", "tls", "ws", "wss":
+		return nil
+	default:
+		return fmt.Errorf("scheme must be nats, tls, ws or wss")
+	}
+}
+
+// endpointParams converts validated endpoint input to repository params.
+func endpointParams(endpoints []EndpointInput) []db.EndpointParams {
+	out := make([]db.EndpointParams, len(endpoints))
+	for i, ep := range endpoints {
+		weight := defaultEndpointWeight
+		if ep.Weight != nil {
+			weight = *ep.Weight
+		}
+		out[i] = db.EndpointParams{
+			NatsUrl:  ep.NatsUrl,
+			Subject:  optionalString(ep.Subject),
+			Region:   optionalString(ep.Region),
+			Zone:     optionalString(ep.Zone),
+			Priority: ep.Priority,
+			Weight:   weight,
+		}
+	}
+	return out
+}
+
+// orderEndpoints sorts endpoints for a caller: same zone first, then same region,
+// then the rest; within each group lower priority, then higher weight, wins.
+func orderEndpoints(endpoints []db.CapabilityEndpoint, region, zone string) []db.CapabilityEndpoint {
+	affinity := func(e db.CapabilityEndpoint) int {
+		epRegion := ptrStringOr(e.Region, "")
+		if region == "" || epRegion != region {
+			return 2
+		}
+		if zone != "" && ptrStringOr(e.Zone, "") == zone {
+			return 0
+		}
+		return 1
+	}
+	out := make([]db.CapabilityEndpoint, len(endpoints))
+	copy(out, endpoints)
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if ai, bi := affinity(a), affinity(b); ai != bi {
+			return ai < bi
+		}
+		if a.Priority != b.Priority {
+			return a.Priority < b.Priority
+		}
+		if a.Weight != b.Weight {
+			return a.Weight > b.Weight
+		}
+		return a.NatsUrl < b.NatsUrl
+	})
+	return out
+}
+
+// resolvedEndpoints is the preferred endpoint plus ordered alternates for one version.
+type resolvedEndpoints struct {
+	NatsUrl    string
+	Subject    string
+	Region     string
+	Alternates []Endpoint
+}
+
+// selectEndpoints orders a version's endpoints for the caller and renders their subjects.
+// Without registered endpoints the caller's cell URL (or the registry's own NATS URL) and
+// the capability subject are used. A cell's subject prefix applies to every subject.
+func (r *Registry) selectEndpoints(endpoints []db.CapabilityEndpoint, template string, params commsutil.SubjectParams, rctx *ResolutionContext, cell *db.Cell) resolvedEndpoints {
+	fallbackUrl := r.localNatsUrl()
+	if cell != nil {
+		fallbackUrl = cell.NatsUrl
+		params.Prefix = ptrStringOr(cell.SubjectPrefix, "")
+	}
+	subject := r.buildSubject(template, params)
+	if len(endpoints) == 0 {
+		return resolvedEndpoints{NatsUrl: fallbackUrl, Subject: subject}
+	}
+	region, zone := "", ""
+	if rctx != nil {
+		region, zone = rctx.Region, rctx.Zone
+	}
+	ordered := orderEndpoints(endpoints, region, zone)
+	rendered := make([]Endpoint, len(ordered))
+	for i, e := range ordered {
+		epSubject := subject
+		if e.Subject != nil && *e.Subject != "" {
+			epSubject = r.buildSubject(*e.Subject, params)
+		}
+		rendered[i] = Endpoint{
+			NatsUrl:  e.NatsUrl,
+			Subject:  epSubject,
+			Region:   ptrStringOr(e.Region, ""),
+			Zone:     ptrStringOr(e.Zone, ""),
+			Priority: e.Priority,
+			Weight:   e.Weight,
+		}
+	}
+	out := resolvedEndpoints{
+		NatsUrl: rendered[0].NatsUrl,
+		Subject: rendered[0].Subject,
+		Region:  rendered[0].Region,
+	}
+	if len(rendered) > 1 {
+		out.Alternates = rendered[1:]
+	}
+	return out
+}
+
+// versionEndpoints loads a version's endpoints; a lookup failure falls back to the registry URL.
+func (r *Registry) versionEndpoints(ctx context.Context, versionID string) []db.CapabilityEndpoint {
+	endpoints, err := r.catalog.ListEndpoints(ctx, versionID)
+	if err != nil {
+		slog.Error(fmt.Sprintf("%s - ListEndpoints failed for version %s: %v", endpointLogPrefix, versionID, err))
+		return nil
+	}
+	return endpoints
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,128 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/morezero/capabilities-registry/pkg/commsutil"
+	"github.com/morezero/capabilities-registry/pkg/db"
+)
+
+const endpointTestPrefix = "registry:endpoint_test"
+
+func TestValidateEndpoints(t *testing.T) {
+	weight := 50
+	tooHeavy := maxEndpointWeight + 1
+	tests := []struct {
+		name      string
+		endpoints []EndpointInput
+		expectErr bool
+	}{
+		{"none", nil, false},
+		{"nats", []EndpointInput{{NatsUrl: "nats://eu.example:4222", Region: "eu-west", Weight: &weight}}, false},
+		{"tls with subject", []EndpointInput{{NatsUrl: "tls://us.example:4222", Subject: "us.{app}.{name}.v{major}"}}, false},
+		{"missing url", []EndpointInput{{Region: "eu-west"}}, true},
+		{"http scheme", []EndpointInput{{NatsUrl: "http://eu.example:4222"}}, true},
+		{"no host", []EndpointInput{{NatsUrl: "nats://"}}, true},
+		{"bad subject", []EndpointInput{{NatsUrl: "nats://eu.example:4222", Subject: "{app}.*"}}, true},
+		{"negative priority", []EndpointInput{{NatsUrl: "nats://eu.example:4222", Priority: -1}}, true},
+		{"weight too high", []EndpointInput{{NatsUrl: "nats://eu.example:4222", Weight: &tooHeavy}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateEndpoints(tt.endpoints)
+			if tt.expectErr && (err == nil || err.Code != "INVALID_ARGUMENT") {
+				t.Errorf("%s - expected INVALID_ARGUMENT, got %v", endpointTestPrefix, err)
+			}
+			if !tt.expectErr && err != nil {
+				t.Errorf("%s - unexpected error: %v", endpointTestPrefix, err)
+			}
+		})
+	}
+
+	many := make([]EndpointInput, maxVersionEndpoints+1)
+	for i := range many {
+		many[i] = EndpointInput{NatsUrl: "nats://example:4222"}
+	}
+	if err := validateEndpoints(many); err == nil {
+		t.Errorf("%s - expected error above %d endpoints", endpointTestPrefix, maxVersionEndpoints)
+	}
+}
+
+func TestEndpointParams_DefaultWeight(t *testing.T) {
+	zero := 0
+	params := endpointParams([]EndpointInput{
+		{NatsUrl: "nats://a:4222"},
+		{NatsUrl: "nats://b:4222", Weight: &zero, Region: "eu-west"},
+	})
+	if params[0].Weight != defaultEndpointWeight || params[0].Region != nil {
+		t.Errorf("%s - unexpected defaults: %+v", endpointTestPrefix, params[0])
+	}
+	if params[1].Weight != 0 || params[1].Region == nil || *params[1].Region != "eu-west" {
+		t.Errorf("%s - unexpected params: %+v", endpointTestPrefix, params[1])
+	}
+}
+
+func TestOrderEndpoints(t *testing.T) {
+	eu, us := "eu-west", "us-east"
+	euA, euB := "eu-west-a", "eu-west-b"
+	endpoints := []db.CapabilityEndpoint{
+		{NatsUrl: "nats://us:4222", Region: &us, Priority: 0},
+		{NatsUrl: "nats://eu-b:4222", Region: &eu, Zone: &euB, Priority: 0},
+		{NatsUrl: "nats://eu-a:4222", Region: &eu, Zone: &euA, Priority: 1},
+		{NatsUrl: "nats://global:4222", Priority: 0, Weight: 10},
+		{NatsUrl: "nats://global-heavy:4222", Priority: 0, Weight: 90},
+	}
+
+	got := urls(orderEndpoints(endpoints, "eu-west", "eu-west-a"))
+	want := []string{"nats://eu-a:4222", "nats://eu-b:4222", "nats://global-heavy:4222", "nats://global:4222", "nats://us:4222"}
+	assertURLs(t, "eu-west-a caller", got, want)
+
+	got = urls(orderEndpoints(endpoints, "", ""))
+	want = []string{"nats://global-heavy:4222", "nats://global:4222", "nats://eu-b:4222", "nats://us:4222", "nats://eu-a:4222"}
+	assertURLs(t, "no hint", got, want)
+}
+
+func TestSelectEndpoints(t *testing.T) {
+	reg := NewRegistry(NewRegistryParams{Config: Config{NatsUrl: "nats://registry:4222"}})
+	params := commsutil.SubjectParams{App: "more0", Name: "doc.ingest", Major: 2}
+
+	local := reg.selectEndpoints(nil, "", params, nil, nil)
+	if local.NatsUrl != "nats://registry:4222" || local.Subject != "cap.more0.doc_ingest.v2" || local.Alternates != nil {
+		t.Errorf("%s - unexpected fallback: %+v", endpointTestPrefix, local)
+	}
+
+	eu, us := "eu-west", "us-east"
+	override := "us.{app}.{name}.v{major}"
+	endpoints := []db.CapabilityEndpoint{
+		{NatsUrl: "nats://us:4222", Region: &us, Subject: &override, Weight: 100},
+		{NatsUrl: "nats://eu:4222", Region: &eu, Weight: 100},
+	}
+	got := reg.selectEndpoints(endpoints, "", params, &ResolutionContext{Region: "eu-west"}, nil)
+	if got.NatsUrl != "nats://eu:4222" || got.Subject != "cap.more0.doc_ingest.v2" || got.Region != "eu-west" {
+		t.Errorf("%s - unexpected preferred endpoint: %+v", endpointTestPrefix, got)
+	}
+	if len(got.Alternates) != 1 || got.Alternates[0].Subject != "us.more0.doc_ingest.v2" || got.Alternates[0].Region != "us-east" {
+		t.Errorf("%s - unexpected alternates: %+v", endpointTestPrefix, got.Alternates)
+	}
+}
+
+func urls(endpoints []db.CapabilityEndpoint) []string {
+	out := make([]string, len(endpoints))
+	for i, e := range endpoints {
+		out[i] = e.NatsUrl
+	}
+	return out
+}
+
+func assertURLs(t *testing.T, name string, got, want []string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s - %s: got %v, want %v", endpointTestPrefix, name, got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("%s - %s: got %v, want %v", endpointTestPrefix, name, got, want)
+			return
+		}
+	}
+}
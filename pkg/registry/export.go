@@ -0,0 +1,322 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/morezero/capabilities-registry/pkg/db"
+	"github.com/morezero/capabilities-registry/pkg/semver"
+)
+
+const (
+	exportLogPrefix    = "registry:exportCatalog"
+	exportDefaultLimit = 50
+	exportMaxLimit     = 200
+)
+
+// ExportCatalog returns a page of the full catalog (every capability with all versions,
+// methods, endpoints and default majors), ordered by app and name, for mirrors to copy.
+// Cap exports a single capability; App restricts the export to one app. Routing exports the
+// cells, tenant cells and releases instead.
+func (r *Registry) ExportCatalog(ctx context.Context, input *ExportCatalogInput) (*ExportCatalogOutput, error) {
+	slog.Info(fmt.Sprintf("%s - cap=%s app=%s page=%d routing=%t", exportLogPrefix, input.Cap, input.App, input.Page, input.Routing))
+
+	if err := r.requireRepo(); err != nil {
+		return nil, err
+	}
+	if input.Routing {
+		routing, regErr := r.catalogRouting(ctx)
+		if regErr != nil {
+			return nil, regErr
+		}
+		return &ExportCatalogOutput{
+			Capabilities: []CatalogCapability{},
+			Pagination:   Pagination{Page: 1, Limit: 1, TotalPages: 1},
+			Routing:      routing,
+		}, nil
+	}
+
+	page := input.Page
+	if page < 1 {
+		page = 1
+	}
+	limit := input.Limit
+	if limit < 1 {
+		limit = exportDefaultLimit
+	}
+	if limit > exportMaxLimit {
+		limit = exportMaxLimit
+	}
+
+	var caps []db.Capability
+	var total int
+	if input.Cap != "" {
+		parsed, err := semver.ParseCapabilityRef(input.Cap)
+		if err != nil {
+			return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: err.Error()}
+		}
+		cap, err := r.repo.GetCapability(ctx, parsed.App, parsed.Name)
+		if err != nil {
+			return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+		}
+		if cap == nil {
+			return nil, &RegistryError{Code: "NOT_FOUND", Message: fmt.Sprintf("Capability not found: %s", parsed.Full)}
+		}
+		caps, total, page, limit = []db.Capability{*cap}, 1, 1, 1
+	} else {
+		var err error
+		caps, total, err = r.repo.ListCapabilities(ctx, db.ListCapabilitiesParams{
+			App:        input.App,
+			Status:     "all",
+			Page:       page,
+			Limit:      limit,
+			SortByName: true,
+		})
+		if err != nil {
+			return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+		}
+	}
+
+	out, regErr := r.catalogCapabilities(ctx, caps)
+	if regErr != nil {
+		return nil, regErr
+	}
+	return &ExportCatalogOutput{
+		Capabilities: out,
+		Pagination: Pagination{
+			Page:       page,
+			Limit:      limit,
+			Total:      total,
+			TotalPages: int(math.Ceil(float64(total) / float64(limit))),
+		},
+	}, nil
+}
+
+// catalogCapabilities loads the versions, methods, endpoints, defaults, tenant rules, shadows
+// and aliases of caps.
+func (r *Registry) catalogCapabilities(ctx context.Context, caps []db.Capability) ([]CatalogCapability, *RegistryError) {
+	capIDs := make([]string, len(caps))
+	for i, c := range caps {
+		capIDs[i] = c.ID
+	}
+	versionsByCap, err := r.repo.GetVersionsByCapabilityIDs(ctx, capIDs)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	defaultsByCap, err := r.repo.ListDefaults(ctx, capIDs)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	var versionIDs []string
+	for _, versions := range versionsByCap {
+		for _, v := range versions {
+			versionIDs = append(versionIDs, v.ID)
+		}
+	}
+	endpointsByVersion, err := r.repo.ListEndpointsForVersions(ctx, versionIDs)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	rulesByCap, err := r.repo.ListTenantRules(ctx, capIDs)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	shadowsByCap, err := r.repo.ListShadows(ctx, capIDs)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	aliases, err := r.repo.ListCapabilityAliases(ctx)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	aliasesByTarget := make(map[string][]CatalogAlias)
+	for _, a := range aliases {
+		key := a.TargetApp + "." + a.TargetName
+		aliasesByTarget[key] = append(aliasesByTarget[key], CatalogAlias{Alias: a.Alias, Kind: a.Kind})
+	}
+
+	out := make([]CatalogCapability, 0, len(caps))
+	for _, c := range caps {
+		entry := CatalogCapability{
+			App:             c.App,
+			Name:            c.Name,
+			Description:     ptrStringOr(c.Description, ""),
+			Tags:            c.Tags,
+			SubjectTemplate: subjectTemplate(&c),
+			LivenessPolicy:  ptrStringOr(c.LivenessPolicy, ""),
+			Revision:        c.Revision,
+			Defaults:        []CatalogDefault{},
+			Versions:        []CatalogVersion{},
+			TenantRules:     catalogTenantRules(rulesByCap[c.ID]),
+			Shadow:          catalogShadow(shadowsByCap[c.ID]),
+			Aliases:         aliasesByTarget[c.App+"."+c.Name],
+		}
+		if entry.Aliases == nil {
+			entry.Aliases = []CatalogAlias{}
+		}
+		for _, d := range defaultsByCap[c.ID] {
+			entry.Defaults = append(entry.Defaults, CatalogDefault{Env: d.Env, Major: d.DefaultMajor})
+		}
+		for _, v := range versionsByCap[c.ID] {
+			methods, err := r.repo.GetMethods(ctx, v.ID)
+			if err != nil {
+				return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+			}
+			entry.Versions = append(entry.Versions, catalogVersion(v, methods, endpointsByVersion[v.ID]))
+		}
+		out = append(out, entry)
+	}
+	return out, nil
+}
+
+// catalogVersion maps a stored version with its methods and endpoints to its export form.
+func catalogVersion(v db.CapabilityVersion, methods []db.CapabilityMethod, endpoints []db.CapabilityEndpoint) CatalogVersion {
+	cv := CatalogVersion{
+		Major:             v.Major,
+		Minor:             v.Minor,
+		Patch:             v.Patch,
+		Prerelease:        ptrStringOr(v.Prerelease, ""),
+		Status:            v.Status,
+		DeprecationReason: ptrStringOr(v.DeprecationReason, ""),
+		Description:       ptrStringOr(v.Description, ""),
+		Changelog:         ptrStringOr(v.Changelog, ""),
+		Metadata:          jsonBytesToMap(v.Metadata),
+		Envs:              v.Envs,
+		Methods:           make([]MethodDefinition, len(methods)),
+		Endpoints:         make([]EndpointInput, len(endpoints)),
+	}
+	for i, m := range methods {
+		cv.Methods[i] = MethodDefinition{
+			Name:         m.Name,
+			Description:  ptrStringOr(m.Description, ""),
+			InputSchema:  jsonBytesToMap(m.InputSchema),
+			OutputSchema: jsonBytesToMap(m.OutputSchema),
+			Modes:        m.Modes,
+			Tags:         m.Tags,
+			Policies:     jsonBytesToMap(m.Policies),
+			Examples:     jsonBytesToSlice(m.Examples),
+		}
+	}
+	for i, e := range endpoints {
+		weight := e.Weight
+		cv.Endpoints[i] = EndpointInput{
+			NatsUrl:  e.NatsUrl,
+			Subject:  ptrStringOr(e.Subject, ""),
+			Region:   ptrStringOr(e.Region, ""),
+			Zone:     ptrStringOr(e.Zone, ""),
+			Priority: e.Priority,
+			Weight:   &weight,
+		}
+	}
+	return cv
+}
+
+// catalogTenantRules maps stored tenant rules to their export form; never nil, so a mirror
+// clears rules removed upstream.
+func catalogTenantRules(rules []db.CapabilityTenantRule) []CatalogTenantRule {
+	out := make([]CatalogTenantRule, len(rules))
+	for i, rule := range rules {
+		out[i] = CatalogTenantRule{
+			TenantID:         ptrStringOr(rule.TenantID, ""),
+			Env:              ptrStringOr(rule.Env, ""),
+			Aud:              ptrStringOr(rule.Aud, ""),
+			RuleType:         rule.RuleType,
+			AllowedMajors:    rule.AllowedMajors,
+			DeniedMajors:     rule.DeniedMajors,
+			RequiredFeatures: rule.RequiredFeatures,
+			Priority:         rule.Priority,
+		}
+	}
+	return out
+}
+
+// tenantRuleRows maps exported tenant rules of capabilityID to capability_tenant_rules rows.
+func tenantRuleRows(capabilityID string, rules []CatalogTenantRule) []db.CapabilityTenantRule {
+	out := make([]db.CapabilityTenantRule, len(rules))
+	for i, rule := range rules {
+		out[i] = db.CapabilityTenantRule{
+			ID:               capabilityID + "#rule" + strconv.Itoa(i),
+			CapabilityID:     capabilityID,
+			TenantID:         optionalString(rule.TenantID),
+			Env:              optionalString(rule.Env),
+			Aud:              optionalString(rule.Aud),
+			RuleType:         rule.RuleType,
+			AllowedMajors:    rule.AllowedMajors,
+			DeniedMajors:     rule.DeniedMajors,
+			RequiredFeatures: rule.RequiredFeatures,
+			Priority:         rule.Priority,
+			Object:           "capability_tenant_rule",
+			Status:           "Active",
+			CreatedBy:        mirrorUserID,
+			ModifiedBy:       mirrorUserID,
+		}
+	}
+	return out
+}
+
+// catalogShadow maps a stored shadow config to its export form; nil when there is none.
+func catalogShadow(s *db.CapabilityShadow) *CatalogShadow {
+	if s == nil {
+		return nil
+	}
+	return &CatalogShadow{
+		TargetVersion: ptrStringOr(s.TargetVersion, ""),
+		TargetMajor:   s.TargetMajor,
+		SampleRate:    s.SampleRate,
+		TenantIDs:     s.TenantIDs,
+	}
+}
+
+// catalogRouting loads every cell, tenant cell assignment and release.
+func (r *Registry) catalogRouting(ctx context.Context) (*CatalogRouting, *RegistryError) {
+	cells, err := r.repo.ListCells(ctx)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	tenantCells, err := r.repo.ListTenantCells(ctx)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	releases, err := r.repo.ListReleases(ctx)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+
+	out := &CatalogRouting{
+		Cells:       make([]CatalogCell, len(cells)),
+		TenantCells: make([]CatalogTenantCell, 0, len(tenantCells)),
+		Releases:    make([]CatalogRelease, 0, len(releases)),
+	}
+	for i, c := range cells {
+		out.Cells[i] = CatalogCell{
+			Name:          c.Name,
+			NatsUrl:       c.NatsUrl,
+			SubjectPrefix: ptrStringOr(c.SubjectPrefix, ""),
+			Description:   ptrStringOr(c.Description, ""),
+		}
+	}
+	for tenantID, cell := range tenantCells {
+		out.TenantCells = append(out.TenantCells, CatalogTenantCell{TenantID: tenantID, Cell: cell})
+	}
+	sort.Slice(out.TenantCells, func(i, j int) bool { return out.TenantCells[i].TenantID < out.TenantCells[j].TenantID })
+	for _, rel := range releases {
+		pins, err := r.repo.GetReleasePins(ctx, rel.ID)
+		if err != nil {
+			return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+		}
+		cr := CatalogRelease{
+			Name:        rel.Name,
+			Description: ptrStringOr(rel.Description, ""),
+			Pins:        make([]CatalogReleasePin, len(pins)),
+		}
+		for i, p := range pins {
+			cr.Pins[i] = CatalogReleasePin{Cap: p.App + "." + p.Name, Version: p.VersionString}
+		}
+		out.Releases = append(out.Releases, cr)
+	}
+	return out, nil
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -17,11 +18,21 @@ import (
 const federationLogPrefix = "registry:federation"
 
 // FederationPool manages server-to-server NATS connections to remote registries.
-// Keyed by alias (which maps to natsUrl). Connections are persistent.
+// Keyed by alias (which maps to natsUrl). Connections are persistent. Each alias has a
+// circuit breaker that fails requests fast while the remote is down, and resolve answers
+// are cached for their remote TTL (see federation_cache.go).
 type FederationPool struct {
 	mu          sync.RWMutex
 	connections map[string]*federatedConnection
 	repo        *db.Repository
+	opts        FederationPoolOptions
+
+	stateMu sync.Mutex
+	states  map[string]*remoteState
+	now     func() time.Time
+
+	cacheMu sync.Mutex
+	cache   map[string]*cachedResolve
 }
 
 type federatedConnection struct {
@@ -30,13 +41,29 @@ type federatedConnection struct {
 	natsUrl     string
 	registrySub string
 	connectedAt time.Time
+	// changes invalidates cached answers on the remote's change events.
+	changes *comms.Subscription
 }
 
-// NewFederationPool creates a new federation pool.
-func NewFederationPool(repo *db.Repository) *FederationPool {
+// NewFederationPool creates a new federation pool. Zero options use a threshold of 3
+// failures, a 30s cooldown and at most 4 hops.
+func NewFederationPool(repo *db.Repository, opts FederationPoolOptions) *FederationPool {
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = defaultFederationFailureThreshold
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = defaultFederationCooldown
+	}
+	if opts.MaxHops <= 0 {
+		opts.MaxHops = defaultFederationMaxHops
+	}
 	return &FederationPool{
 		connections: make(map[string]*federatedConnection),
 		repo:        repo,
+		opts:        opts,
+		states:      make(map[string]*remoteState),
+		now:         time.Now,
+		cache:       make(map[string]*cachedResolve),
 	}
 }
 
@@ -58,58 +85,315 @@ type FederatedResolveOutput struct {
 	Status            string
 	TTLSeconds        int
 	Etag              string
+	// Warnings are the remote's warnings plus "stale" or "failover" when the answer did
+	// not come fresh from the alias itself.
+	Warnings []Warning
+	// FederationPath lists the aliases the answer came through, e.g. ["@b", "@c"].
+	FederationPath []string
 }
 
-// Resolve performs a federated resolve call to a remote registry via NATS.
+// Resolve performs a federated resolve call to a remote registry via NATS. Answers are
+// cached per alias, ref, range and context for the remote TTL. When the alias is
+// unavailable, an expired answer is served for up to MaxStale, and otherwise the secondary
+// aliases listed under "failover" in the alias's config are tried in order.
 func (fp *FederationPool) Resolve(ctx context.Context, input *FederatedResolveInput) (*FederatedResolveOutput, error) {
 	slog.Info(fmt.Sprintf("%s - Resolving alias=%s cap=%s", federationLogPrefix, input.Alias, input.Cap))
 
-	// Look up alias in registries table
-	entry, err := fp.repo.GetRegistryByAlias(ctx, input.Alias)
+	key := resolveCacheKey(input)
+	cached := fp.cacheGet(key)
+	if cached != nil && fp.now().Before(cached.expiresAt) {
+		return fp.freshAnswer(cached), nil
+	}
+
+	entry, regErr := fp.lookupAlias(ctx, input.Alias)
+	if regErr != nil {
+		return nil, regErr
+	}
+	out, app, name, err := fp.resolveAt(ctx, entry, input)
+	if err == nil {
+		fp.cachePut(key, input.Alias, app, name, out)
+		return out, nil
+	}
+	if !isRegistryUnavailable(err) {
+		return nil, err
+	}
+	if stale := fp.staleAnswer(cached, err); stale != nil {
+		return stale, nil
+	}
+	for _, secondary := range parseRemoteConfig(entry).Failover {
+		if secondary == input.Alias {
+			continue
+		}
+		secondaryEntry, regErr := fp.lookupAlias(ctx, secondary)
+		if regErr != nil {
+			slog.Warn(fmt.Sprintf("%s - failover alias %s of %s: %s", federationLogPrefix, secondary, input.Alias, regErr.Message))
+			continue
+		}
+		out, app, name, ferr := fp.resolveAt(ctx, secondaryEntry, input)
+		if ferr != nil {
+			slog.Warn(fmt.Sprintf("%s - failover alias %s of %s: %v", federationLogPrefix, secondary, input.Alias, ferr))
+			continue
+		}
+		out.Warnings = append(out.Warnings, Warning{
+			Code:    "failover",
+			Message: fmt.Sprintf("Registry alias %s is unavailable; resolved through %s", input.Alias, secondary),
+		})
+		// Cached under the requested alias, invalidated by the secondary's change events
+		fp.cachePut(key, secondary, app, name, out)
+		return out, nil
+	}
+	return nil, err
+}
+
+// resolveAt resolves input at the remote registry of entry. The canonical identity names
+// entry's alias, and app and name are the capability the remote resolved (after any
+// capability alias it followed). The alias's trust policy is checked for the caller's
+// tenant and for both the requested and the resolved app.
+func (fp *FederationPool) resolveAt(ctx context.Context, entry *db.RegistryEntry, input *FederatedResolveInput) (out *FederatedResolveOutput, app, name string, err error) {
+	trust := parseRemoteConfig(entry).Trust
+	tenantID := ""
+	if input.Ctx != nil {
+		tenantID = input.Ctx.TenantID
+	}
+	if regErr := trust.allowTenant(entry.Alias, tenantID); regErr != nil {
+		return nil, "", "", regErr
+	}
+	if regErr := trust.allowApp(entry.Alias, capRefApp(input.Cap)); regErr != nil {
+		return nil, "", "", regErr
+	}
+
+	params := map[string]interface{}{
+		"cap": dottedCapRef(input.Cap),
+		"ver": input.Ver,
+	}
+	if input.Ctx != nil {
+		params["ctx"] = input.Ctx
+	}
+
+	// Decode the resolve result
+	var remoteResult struct {
+		CanonicalIdentity string    `json:"canonicalIdentity"`
+		Subject           string    `json:"subject"`
+		ResolvedVersion   string    `json:"resolvedVersion"`
+		Major             int       `json:"major"`
+		Status            string    `json:"status"`
+		TTLSeconds        int       `json:"ttlSeconds"`
+		Etag              string    `json:"etag"`
+		Warnings          []Warning `json:"warnings"`
+		FederationPath    []string  `json:"federationPath"`
+	}
+	if err := fp.callEntry(ctx, entry, "resolve", params, &remoteResult); err != nil {
+		return nil, "", "", err
+	}
+
+	// Align with local resolve format: cap:@alias/app/name@version (app and name as separate path segments)
+	app, name, ok := canonicalCapability(remoteResult.CanonicalIdentity)
+	if !ok {
+		app, name = splitCapRef(innermostCapRef(input.Cap))
+	}
+	if regErr := trust.allowApp(entry.Alias, app); regErr != nil {
+		return nil, "", "", regErr
+	}
+	canonicalIdentity := fmt.Sprintf("cap:@%s/%s/%s@%s", entry.Alias, app, name, remoteResult.ResolvedVersion)
+
+	return &FederatedResolveOutput{
+		NatsUrl:           *entry.NatsUrl,
+		Subject:           remoteResult.Subject,
+		CanonicalIdentity: canonicalIdentity,
+		ResolvedVersion:   remoteResult.ResolvedVersion,
+		Major:             remoteResult.Major,
+		Status:            remoteResult.Status,
+		TTLSeconds:        remoteResult.TTLSeconds,
+		Etag:              remoteResult.Etag,
+		Warnings:          remoteResult.Warnings,
+		FederationPath:    append([]string{"@" + entry.Alias}, remoteResult.FederationPath...),
+	}, app, name, nil
+}
+
+// canonicalCapability extracts app and name from a canonical identity
+// "cap:@alias/app/name@version".
+func canonicalCapability(identity string) (app, name string, ok bool) {
+	rest, found := strings.CutPrefix(identity, "cap:@")
+	if !found {
+		return "", "", false
+	}
+	parts := strings.SplitN(rest, "/", 3)
+	if len(parts) != 3 {
+		return "", "", false
+	}
+	name, _, _ = strings.Cut(parts[2], "@")
+	if parts[1] == "" || name == "" {
+		return "", "", false
+	}
+	return parts[1], name, true
+}
+
+// isRegistryUnavailable reports whether err means the remote could not be reached.
+func isRegistryUnavailable(err error) bool {
+	regErr, ok := err.(*RegistryError)
+	return ok && regErr.Code == "REGISTRY_UNAVAILABLE"
+}
+
+// Describe forwards describe for a capability ref (without its @alias prefix) to the remote
+// registry behind alias.
+func (fp *FederationPool) Describe(ctx context.Context, alias string, input *DescribeInput) (*DescribeOutput, error) {
+	slog.Info(fmt.Sprintf("%s - Describing alias=%s cap=%s", federationLogPrefix, alias, input.Cap))
+
+	remoteInput := *input
+	remoteInput.Cap = dottedCapRef(input.Cap)
+	var out DescribeOutput
+	if _, err := fp.call(ctx, alias, capRefApp(input.Cap), "", "describe", &remoteInput, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListMajors forwards listMajors for a capability ref (without its @alias prefix) to the
+// remote registry behind alias.
+func (fp *FederationPool) ListMajors(ctx context.Context, alias string, input *ListMajorsInput) (*ListMajorsOutput, error) {
+	slog.Info(fmt.Sprintf("%s - Listing majors alias=%s cap=%s", federationLogPrefix, alias, input.Cap))
+
+	remoteInput := *input
+	remoteInput.Cap = dottedCapRef(input.Cap)
+	var out ListMajorsOutput
+	if _, err := fp.call(ctx, alias, capRefApp(input.Cap), "", "listMajors", &remoteInput, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Discover forwards discover to the remote registry behind alias. The input must not
+// carry aliases; each remote only answers for its own capabilities. Capabilities of apps
+// the alias does not trust are left out.
+func (fp *FederationPool) Discover(ctx context.Context, alias string, input *DiscoverInput) (*DiscoverOutput, error) {
+	slog.Info(fmt.Sprintf("%s - Discovering alias=%s", federationLogPrefix, alias))
+
+	tenantID := ""
+	if input.Ctx != nil {
+		tenantID = input.Ctx.TenantID
+	}
+	var out DiscoverOutput
+	entry, err := fp.call(ctx, alias, input.App, tenantID, "discover", input, &out)
 	if err != nil {
-		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: fmt.Sprintf("Failed to look up alias %s: %v", input.Alias, err)}
+		return nil, err
+	}
+	trust := parseRemoteConfig(entry).Trust
+	trusted := out.Capabilities[:0]
+	for _, c := range out.Capabilities {
+		if trust.allowApp(alias, c.App) == nil {
+			trusted = append(trusted, c)
+		}
+	}
+	out.Pagination.Total -= len(out.Capabilities) - len(trusted)
+	out.Capabilities = trusted
+	return &out, nil
+}
+
+// call sends a system.registry method request to the remote registry behind alias and
+// decodes its result into out. It returns the alias entry, whose NATS URL is set. While the
+// alias's circuit is open it fails with REGISTRY_UNAVAILABLE without contacting the remote.
+// The alias's trust policy is checked for tenantID and, when set, the requested app.
+func (fp *FederationPool) call(ctx context.Context, alias, app, tenantID, method string, params interface{}, out interface{}) (*db.RegistryEntry, error) {
+	entry, regErr := fp.lookupAlias(ctx, alias)
+	if regErr != nil {
+		return nil, regErr
+	}
+	trust := parseRemoteConfig(entry).Trust
+	if regErr := trust.allowTenant(alias, tenantID); regErr != nil {
+		return nil, regErr
+	}
+	if app != "" {
+		if regErr := trust.allowApp(alias, app); regErr != nil {
+			return nil, regErr
+		}
+	}
+	if err := fp.callEntry(ctx, entry, method, params, out); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// lookupAlias returns the static entry or registries row of alias, which has a NATS URL and
+// subject.
+func (fp *FederationPool) lookupAlias(ctx context.Context, alias string) (*db.RegistryEntry, *RegistryError) {
+	var entry *db.RegistryEntry
+	for i := range fp.opts.Static {
+		if fp.opts.Static[i].Alias == alias {
+			entry = &fp.opts.Static[i]
+			break
+		}
+	}
+	if entry == nil && fp.repo != nil {
+		// Look up alias in registries table
+		var err error
+		entry, err = fp.repo.GetRegistryByAlias(ctx, alias)
+		if err != nil {
+			return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: fmt.Sprintf("Failed to look up alias %s: %v", alias, err)}
+		}
 	}
 	if entry == nil {
-		return nil, &RegistryError{Code: "UNKNOWN_ALIAS", Message: fmt.Sprintf("Unknown registry alias: %s", input.Alias)}
+		return nil, &RegistryError{Code: "UNKNOWN_ALIAS", Message: fmt.Sprintf("Unknown registry alias: %s", alias)}
 	}
 	if entry.NatsUrl == nil || *entry.NatsUrl == "" {
-		return nil, &RegistryError{Code: "REGISTRY_UNAVAILABLE", Message: fmt.Sprintf("Registry alias %s has no NATS URL configured", input.Alias)}
+		return nil, &RegistryError{Code: "REGISTRY_UNAVAILABLE", Message: fmt.Sprintf("Registry alias %s has no NATS URL configured", alias)}
 	}
 	if entry.RegistrySubject == nil || *entry.RegistrySubject == "" {
-		return nil, &RegistryError{Code: "REGISTRY_UNAVAILABLE", Message: fmt.Sprintf("Registry alias %s has no registry subject configured", input.Alias)}
+		return nil, &RegistryError{Code: "REGISTRY_UNAVAILABLE", Message: fmt.Sprintf("Registry alias %s has no registry subject configured", alias)}
+	}
+	return entry, nil
+}
+
+// callEntry sends a request to the remote of entry unless its circuit is open. A half-open
+// trial ends with the request, whether or not send recorded an outcome.
+func (fp *FederationPool) callEntry(ctx context.Context, entry *db.RegistryEntry, method string, params interface{}, out interface{}) error {
+	if regErr := fp.allow(entry.Alias); regErr != nil {
+		return regErr
+	}
+	defer fp.releaseTrial(entry.Alias)
+	return fp.send(ctx, entry, method, params, out)
+}
+
+// send performs one request to the remote registry of entry and records its outcome in the
+// alias's circuit breaker: a failure to connect or to get a reply counts as a failure, any
+// reply (including an error reply) as a success.
+func (fp *FederationPool) send(ctx context.Context, entry *db.RegistryEntry, method string, params interface{}, out interface{}) error {
+	alias := entry.Alias
+
+	// Refuse to forward a request that already passed this registry or took too many hops
+	trace, regErr := fp.nextTrace(ctx, alias)
+	if regErr != nil {
+		return regErr
 	}
 
 	// Get or create connection
-	nc, err := fp.getOrConnect(input.Alias, *entry.NatsUrl)
+	nc, err := fp.getOrConnect(entry)
 	if err != nil {
-		return nil, &RegistryError{Code: "REGISTRY_UNAVAILABLE", Message: fmt.Sprintf("Failed to connect to remote registry %s: %v", input.Alias, err)}
+		fp.recordFailure(alias, err)
+		return &RegistryError{Code: "REGISTRY_UNAVAILABLE", Message: fmt.Sprintf("Failed to connect to remote registry %s: %v", alias, err)}
 	}
 
-	// Build remote resolve request
+	// Build remote request
 	remoteReq := map[string]interface{}{
-		"id":     fmt.Sprintf("fed-%d", time.Now().UnixNano()),
-		"type":   "invoke",
-		"cap":    "system.registry",
-		"method": "resolve",
-		"params": map[string]interface{}{
-			"cap": input.Cap,
-			"ver": input.Ver,
-		},
-	}
-	if input.Ctx != nil {
-		remoteReq["params"].(map[string]interface{})["ctx"] = input.Ctx
+		"id":         fmt.Sprintf("fed-%d", time.Now().UnixNano()),
+		"type":       "invoke",
+		"cap":        "system.registry",
+		"method":     method,
+		"params":     params,
+		"federation": trace,
 	}
-
 	payload, err := json.Marshal(remoteReq)
 	if err != nil {
-		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: fmt.Sprintf("Failed to marshal federated request: %v", err)}
+		return &RegistryError{Code: "INTERNAL_ERROR", Message: fmt.Sprintf("Failed to marshal federated request: %v", err)}
 	}
 
 	// Send request to remote registry
+	start := time.Now()
 	msg, err := nc.RequestWithContext(ctx, *entry.RegistrySubject, payload)
 	if err != nil {
-		return nil, &RegistryError{Code: "REGISTRY_UNAVAILABLE", Message: fmt.Sprintf("Remote registry %s did not respond: %v", input.Alias, err)}
+		fp.recordFailure(alias, err)
+		return &RegistryError{Code: "REGISTRY_UNAVAILABLE", Message: fmt.Sprintf("Remote registry %s did not respond: %v", alias, err)}
 	}
+	fp.recordSuccess(alias, time.Since(start))
 
 	// Decode response
 	var resp struct {
@@ -121,53 +405,46 @@ func (fp *FederationPool) Resolve(ctx context.Context, input *FederatedResolveIn
 		} `json:"error,omitempty"`
 	}
 	if err := json.Unmarshal(msg.Data, &resp); err != nil {
-		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: fmt.Sprintf("Failed to decode remote response from %s: %v", input.Alias, err)}
+		return &RegistryError{Code: "INTERNAL_ERROR", Message: fmt.Sprintf("Failed to decode remote response from %s: %v", alias, err)}
 	}
 	if !resp.Ok {
 		code := "INTERNAL_ERROR"
-		message := "Remote resolve failed"
+		message := fmt.Sprintf("Remote %s failed", method)
 		if resp.Error != nil {
 			code = resp.Error.Code
 			message = resp.Error.Message
 		}
-		return nil, &RegistryError{Code: code, Message: fmt.Sprintf("Remote registry %s: %s", input.Alias, message)}
-	}
-
-	// Decode the resolve result
-	var remoteResult struct {
-		Subject         string `json:"subject"`
-		ResolvedVersion string `json:"resolvedVersion"`
-		Major           int    `json:"major"`
-		Status          string `json:"status"`
-		TTLSeconds      int    `json:"ttlSeconds"`
-		Etag            string `json:"etag"`
+		return &RegistryError{Code: code, Message: fmt.Sprintf("Remote registry %s: %s", alias, message)}
 	}
-	if err := json.Unmarshal(resp.Result, &remoteResult); err != nil {
-		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: fmt.Sprintf("Failed to decode remote resolve result from %s: %v", input.Alias, err)}
+	if err := json.Unmarshal(resp.Result, out); err != nil {
+		return &RegistryError{Code: "INTERNAL_ERROR", Message: fmt.Sprintf("Failed to decode remote %s result from %s: %v", method, alias, err)}
 	}
+	return nil
+}
 
-	// Align with local resolve format: cap:@alias/app/name@version (app and name as separate path segments)
-	app, name := input.Cap, ""
-	if idx := strings.Index(input.Cap, "/"); idx >= 0 {
-		app = input.Cap[:idx]
-		name = input.Cap[idx+1:]
+// dottedCapRef turns the part of an @alias ref after the alias into a plain ref:
+// "app/name@range" becomes "app.name@range"; dotted refs are unchanged. A multi-hop ref
+// ("@c/app/name") is unchanged too: the remote forwards it to its own alias c.
+func dottedCapRef(capRef string) string {
+	if strings.HasPrefix(capRef, "@") {
+		return capRef
 	}
-	canonicalIdentity := fmt.Sprintf("cap:@%s/%s/%s@%s", input.Alias, app, name, remoteResult.ResolvedVersion)
+	return strings.Replace(capRef, "/", ".", 1)
+}
 
-	return &FederatedResolveOutput{
-		NatsUrl:           *entry.NatsUrl,
-		Subject:           remoteResult.Subject,
-		CanonicalIdentity: canonicalIdentity,
-		ResolvedVersion:   remoteResult.ResolvedVersion,
-		Major:             remoteResult.Major,
-		Status:            remoteResult.Status,
-		TTLSeconds:        remoteResult.TTLSeconds,
-		Etag:              remoteResult.Etag,
-	}, nil
+// qualifiedCap returns the alias-qualified ref of a capability, e.g. "@partner/more0/doc.ingest".
+func qualifiedCap(alias, app, name string) string {
+	return fmt.Sprintf("@%s/%s/%s", alias, app, name)
 }
 
-// getOrConnect gets an existing connection or creates a new one.
-func (fp *FederationPool) getOrConnect(alias, natsUrl string) (*comms.Conn, error) {
+// getOrConnect gets an existing connection or creates a new one, secured as configured in
+// the alias's config. A new connection subscribes to the remote's change events; cached
+// answers of the alias are dropped whenever events may have been missed (new connection
+// or reconnect).
+func (fp *FederationPool) getOrConnect(entry *db.RegistryEntry) (*comms.Conn, error) {
+	alias, natsUrl := entry.Alias, *entry.NatsUrl
+	cfg := parseRemoteConfig(entry)
+
 	fp.mu.RLock()
 	if fc, ok := fp.connections[alias]; ok && fc.nc.IsConnected() {
 		fp.mu.RUnlock()
@@ -189,21 +466,32 @@ func (fp *FederationPool) getOrConnect(alias, natsUrl string) (*comms.Conn, erro
 		delete(fp.connections, alias)
 	}
 
+	secure, err := remoteConnectOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
 	slog.Info(fmt.Sprintf("%s - Connecting to remote NATS alias=%s url=%s", federationLogPrefix, alias, natsUrl))
-	nc, err := comms.Connect(natsUrl,
+	nc, err := comms.Connect(natsUrl, append([]comms.Option{
 		comms.Name(fmt.Sprintf("capabilities-registry-federation-%s", alias)),
 		comms.MaxReconnects(5),
-		comms.ReconnectWait(2*time.Second),
-	)
+		comms.ReconnectWait(2 * time.Second),
+		comms.ReconnectHandler(func(*comms.Conn) { fp.invalidate(alias, "", "") }),
+	}, secure...)...)
 	if err != nil {
 		return nil, err
 	}
+	fp.invalidate(alias, "", "")
+	changes, err := fp.subscribeChanges(nc, alias, cfg.ChangeEventSubject)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("%s - change event subscription failed alias=%s: %v", federationLogPrefix, alias, err))
+	}
 
 	fp.connections[alias] = &federatedConnection{
 		nc:          nc,
 		alias:       alias,
 		natsUrl:     natsUrl,
 		connectedAt: time.Now(),
+		changes:     changes,
 	}
 
 	return nc, nil
@@ -221,10 +509,10 @@ func (fp *FederationPool) CloseAll() {
 	fp.connections = make(map[string]*federatedConnection)
 }
 
-// LoadRegistryAliases loads all registry aliases from the database.
+// LoadRegistryAliases loads all registry aliases from the database and the static entries.
 // Returns a map of alias → natsUrl for inclusion in bootstrap response.
 func (fp *FederationPool) LoadRegistryAliases(ctx context.Context) (map[string]string, string, error) {
-	entries, err := fp.repo.ListRegistries(ctx)
+	entries, err := fp.listRegistries(ctx)
 	if err != nil {
 		return nil, "", err
 	}
@@ -241,3 +529,21 @@ func (fp *FederationPool) LoadRegistryAliases(ctx context.Context) (map[string]s
 	}
 	return aliases, defaultAlias, nil
 }
+
+// listRegistries returns the static entries and the registries rows whose alias is not static.
+func (fp *FederationPool) listRegistries(ctx context.Context) ([]db.RegistryEntry, error) {
+	entries := slices.Clone(fp.opts.Static)
+	if fp.repo == nil {
+		return entries, nil
+	}
+	rows, err := fp.repo.ListRegistries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if !slices.ContainsFunc(fp.opts.Static, func(e db.RegistryEntry) bool { return e.Alias == row.Alias }) {
+			entries = append(entries, row)
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,181 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	comms "github.com/nats-io/nats.go"
+
+	"github.com/morezero/capabilities-registry/pkg/commsutil"
+	"github.com/morezero/capabilities-registry/pkg/db"
+	"github.com/morezero/capabilities-registry/pkg/events"
+)
+
+const (
+	// federationCacheMaxEntries bounds the federated resolve cache; when it is full, expired
+	// entries are dropped and new answers are not cached until there is room.
+	federationCacheMaxEntries = 10000
+
+	defaultFederationMaxStale = 5 * time.Minute
+
+	// remoteChangeSubject is subscribed on each remote connection to invalidate cached
+	// answers; it matches the per-capability change subjects registry.changed.<app>.<cap>,
+	// and their env- and tenant-scoped forms.
+	remoteChangeSubject = commsutil.SubjectChangeEvent + ".>"
+
+	// Registry-level events (tenant cells) are published under system.registry and may
+	// change any answer of the remote.
+	remoteRegistryEventApp        = "system"
+	remoteRegistryEventCapability = "registry"
+)
+
+// remoteConfig is the part of a registries row's config JSONB the federation pool reads.
+type remoteConfig struct {
+	// Failover lists secondary aliases tried in order when the alias is unavailable.
+	Failover []string `json:"failover,omitempty"`
+	// ChangeEventSubject overrides the subject subscribed for the remote's change events.
+	ChangeEventSubject string `json:"changeEventSubject,omitempty"`
+
+	// Connection security (see federation_security.go): TLS and at most one credential.
+	TLS          *remoteTLS `json:"tls,omitempty"`
+	CredsFile    string     `json:"credsFile,omitempty"`
+	NkeySeedFile string     `json:"nkeySeedFile,omitempty"`
+	Token        string     `json:"token,omitempty"`
+	TokenFile    string     `json:"tokenFile,omitempty"`
+	// Trust restricts the remote apps resolved and the local tenants using the alias.
+	Trust remoteTrust `json:"trust,omitempty"`
+}
+
+// parseRemoteConfig decodes a registries row's config; invalid JSON is logged and ignored.
+func parseRemoteConfig(e *db.RegistryEntry) remoteConfig {
+	var cfg remoteConfig
+	if len(e.Config) == 0 {
+		return cfg
+	}
+	if err := json.Unmarshal(e.Config, &cfg); err != nil {
+		slog.Warn(fmt.Sprintf("%s - ignoring invalid config of alias %s: %v", federationLogPrefix, e.Alias, err))
+	}
+	return cfg
+}
+
+// cachedResolve is one cached federated resolve answer.
+type cachedResolve struct {
+	alias     string
+	app       string
+	name      string
+	out       FederatedResolveOutput
+	fetchedAt time.Time
+	expiresAt time.Time
+}
+
+// resolveCacheKey identifies a federated resolve by alias, ref, range and caller context.
+func resolveCacheKey(input *FederatedResolveInput) string {
+	rctx := ""
+	if input.Ctx != nil {
+		b, _ := json.Marshal(input.Ctx)
+		rctx = string(b)
+	}
+	return strings.Join([]string{input.Alias, dottedCapRef(input.Cap), input.Ver, rctx}, "\x00")
+}
+
+// cacheGet returns the cached answer for key, fresh or expired, or nil.
+func (fp *FederationPool) cacheGet(key string) *cachedResolve {
+	fp.cacheMu.Lock()
+	defer fp.cacheMu.Unlock()
+	return fp.cache[key]
+}
+
+// cachePut stores an answer for its remote TTL. Answers without a TTL are not cached.
+func (fp *FederationPool) cachePut(key, alias, app, name string, out *FederatedResolveOutput) {
+	if out.TTLSeconds <= 0 {
+		return
+	}
+	now := fp.now()
+	fp.cacheMu.Lock()
+	defer fp.cacheMu.Unlock()
+
+	if _, ok := fp.cache[key]; !ok && len(fp.cache) >= federationCacheMaxEntries {
+		for k, c := range fp.cache {
+			if now.After(c.expiresAt.Add(fp.opts.MaxStale)) {
+				delete(fp.cache, k)
+			}
+		}
+		if len(fp.cache) >= federationCacheMaxEntries {
+			return
+		}
+	}
+	fp.cache[key] = &cachedResolve{
+		alias:     alias,
+		app:       app,
+		name:      name,
+		out:       *out,
+		fetchedAt: now,
+		expiresAt: now.Add(time.Duration(out.TTLSeconds) * time.Second),
+	}
+}
+
+// invalidate drops the cached answers of alias for one capability, or all of them when
+// app and name are empty.
+func (fp *FederationPool) invalidate(alias, app, name string) {
+	fp.cacheMu.Lock()
+	defer fp.cacheMu.Unlock()
+
+	dropped := 0
+	for k, c := range fp.cache {
+		if c.alias == alias && (app == "" || (c.app == app && c.name == name)) {
+			delete(fp.cache, k)
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		slog.Debug(fmt.Sprintf("%s - invalidated %d cached answers alias=%s cap=%s.%s", federationLogPrefix, dropped, alias, app, name))
+	}
+}
+
+// freshAnswer returns a copy of a cached answer whose TTL is the time left.
+func (fp *FederationPool) freshAnswer(c *cachedResolve) *FederatedResolveOutput {
+	out := c.out
+	out.TTLSeconds = int(math.Ceil(c.expiresAt.Sub(fp.now()).Seconds()))
+	return &out
+}
+
+// staleAnswer returns a copy of an expired cached answer while it is within MaxStale of
+// its expiry, or nil. Its TTL is the circuit cooldown, so clients come back once the
+// remote may have recovered.
+func (fp *FederationPool) staleAnswer(c *cachedResolve, cause error) *FederatedResolveOutput {
+	if c == nil || fp.opts.MaxStale <= 0 || fp.now().After(c.expiresAt.Add(fp.opts.MaxStale)) {
+		return nil
+	}
+	out := c.out
+	out.TTLSeconds = int(math.Min(float64(c.out.TTLSeconds), math.Ceil(fp.opts.Cooldown.Seconds())))
+	out.Warnings = append(append([]Warning{}, out.Warnings...), Warning{
+		Code:    "stale",
+		Message: fmt.Sprintf("Registry alias %s is unavailable (%v); serving the answer cached at %s", c.alias, cause, c.fetchedAt.UTC().Format(time.RFC3339)),
+	})
+	return &out
+}
+
+// subscribeChanges subscribes nc to the remote's change events and invalidates the cached
+// answers of alias for each changed capability. Registry-level events drop every answer
+// of the alias.
+func (fp *FederationPool) subscribeChanges(nc *comms.Conn, alias, subject string) (*comms.Subscription, error) {
+	if subject == "" {
+		subject = remoteChangeSubject
+	}
+	return nc.Subscribe(subject, func(msg *comms.Msg) {
+		event, err := events.DecodeChangeEvent(msg)
+		if err != nil || event.App == "" {
+			fp.invalidate(alias, "", "")
+			return
+		}
+		if event.App == remoteRegistryEventApp && event.Capability == remoteRegistryEventCapability {
+			fp.invalidate(alias, "", "")
+			return
+		}
+		fp.invalidate(alias, event.App, event.Capability)
+	})
+}
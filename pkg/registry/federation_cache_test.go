@@ -0,0 +1,149 @@
+package registry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/morezero/capabilities-registry/pkg/db"
+)
+
+const federationCacheTestPrefix = "registry:federation_cache_test"
+
+func TestResolveCacheKey(t *testing.T) {
+	base := &FederatedResolveInput{Alias: "partner", Cap: "billing/invoice", Ver: "^1"}
+	dotted := &FederatedResolveInput{Alias: "partner", Cap: "billing.invoice", Ver: "^1"}
+	if resolveCacheKey(base) != resolveCacheKey(dotted) {
+		t.Errorf("%s - slash and dotted refs should share a key", federationCacheTestPrefix)
+	}
+	others := []*FederatedResolveInput{
+		{Alias: "partner-dr", Cap: "billing/invoice", Ver: "^1"},
+		{Alias: "partner", Cap: "billing/invoice", Ver: "^2"},
+		{Alias: "partner", Cap: "billing/invoice", Ver: "^1", Ctx: &ResolutionContext{TenantID: "acme"}},
+	}
+	for _, o := range others {
+		if resolveCacheKey(base) == resolveCacheKey(o) {
+			t.Errorf("%s - key of %+v should differ from %+v", federationCacheTestPrefix, o, base)
+		}
+	}
+}
+
+func TestFederationPool_CacheFreshAndStale(t *testing.T) {
+	fp := NewFederationPool(nil, FederationPoolOptions{Cooldown: 30 * time.Second, MaxStale: time.Minute})
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	fp.now = func() time.Time { return now }
+
+	fp.cachePut("k", "partner", "billing", "invoice", &FederatedResolveOutput{Subject: "cap.billing.invoice.v1", TTLSeconds: 300})
+	now = now.Add(100 * time.Second)
+	c := fp.cacheGet("k")
+	if c == nil || !now.Before(c.expiresAt) {
+		t.Fatalf("%s - expected a fresh cached answer, got %+v", federationCacheTestPrefix, c)
+	}
+	if out := fp.freshAnswer(c); out.TTLSeconds != 200 || out.Subject != "cap.billing.invoice.v1" {
+		t.Errorf("%s - fresh answer = %+v, want ttl 200", federationCacheTestPrefix, out)
+	}
+
+	now = now.Add(230 * time.Second)
+	out := fp.staleAnswer(c, &RegistryError{Code: "REGISTRY_UNAVAILABLE", Message: "no responders"})
+	if out == nil {
+		t.Fatalf("%s - expected a stale answer within MaxStale", federationCacheTestPrefix)
+	}
+	if out.TTLSeconds != 30 || len(out.Warnings) != 1 || out.Warnings[0].Code != "stale" {
+		t.Errorf("%s - stale answer = %+v", federationCacheTestPrefix, out)
+	}
+	if len(c.out.Warnings) != 0 {
+		t.Errorf("%s - the stale warning must not be added to the cached answer", federationCacheTestPrefix)
+	}
+
+	now = now.Add(time.Minute)
+	if fp.staleAnswer(c, nil) != nil {
+		t.Errorf("%s - expected no stale answer past MaxStale", federationCacheTestPrefix)
+	}
+	if NewFederationPool(nil, FederationPoolOptions{}).staleAnswer(c, nil) != nil {
+		t.Errorf("%s - expected no stale answer with MaxStale 0", federationCacheTestPrefix)
+	}
+
+	fp.cachePut("none", "partner", "billing", "invoice", &FederatedResolveOutput{TTLSeconds: 0})
+	if fp.cacheGet("none") != nil {
+		t.Errorf("%s - answers without a TTL must not be cached", federationCacheTestPrefix)
+	}
+}
+
+func TestFederationPool_Invalidate(t *testing.T) {
+	fp := NewFederationPool(nil, FederationPoolOptions{})
+	out := &FederatedResolveOutput{TTLSeconds: 60}
+	fp.cachePut("a", "partner", "billing", "invoice", out)
+	fp.cachePut("b", "partner", "billing", "refund", out)
+	fp.cachePut("c", "sandbox", "billing", "invoice", out)
+
+	fp.invalidate("partner", "billing", "invoice")
+	if fp.cacheGet("a") != nil || fp.cacheGet("b") == nil || fp.cacheGet("c") == nil {
+		t.Errorf("%s - expected only partner billing.invoice to be dropped", federationCacheTestPrefix)
+	}
+	fp.Forget("partner")
+	if fp.cacheGet("b") != nil || fp.cacheGet("c") == nil {
+		t.Errorf("%s - expected Forget to drop every answer of partner only", federationCacheTestPrefix)
+	}
+}
+
+func TestFederationPool_ChangeEventsInvalidate(t *testing.T) {
+	nc := startProbeServer(t)
+	fp := NewFederationPool(nil, FederationPoolOptions{})
+	out := &FederatedResolveOutput{TTLSeconds: 60}
+	fp.cachePut("a", "partner", "billing", "invoice", out)
+	fp.cachePut("b", "partner", "billing", "refund", out)
+
+	sub, err := fp.subscribeChanges(nc, "partner", "")
+	if err != nil {
+		t.Fatalf("%s - subscribe failed: %v", federationCacheTestPrefix, err)
+	}
+	defer sub.Unsubscribe()
+
+	if err := nc.Publish("registry.changed.billing.invoice", []byte(`{"app":"billing","capability":"invoice","revision":2}`)); err != nil {
+		t.Fatalf("%s - publish failed: %v", federationCacheTestPrefix, err)
+	}
+	if err := nc.Flush(); err != nil {
+		t.Fatalf("%s - flush failed: %v", federationCacheTestPrefix, err)
+	}
+	waitFor(t, func() bool { return fp.cacheGet("a") == nil })
+	if fp.cacheGet("b") == nil {
+		t.Errorf("%s - an event for billing.invoice must not drop billing.refund", federationCacheTestPrefix)
+	}
+
+	_ = nc.Publish("registry.changed.system.registry", []byte(`{"app":"system","capability":"registry","tenantId":"acme"}`))
+	_ = nc.Flush()
+	waitFor(t, func() bool { return fp.cacheGet("b") == nil })
+}
+
+func TestParseRemoteConfig(t *testing.T) {
+	cfg := parseRemoteConfig(&db.RegistryEntry{Alias: "partner", Config: []byte(`{"failover":["partner-dr","sandbox"],"changeEventSubject":"partner.changed.>","team":"x"}`)})
+	if len(cfg.Failover) != 2 || cfg.Failover[0] != "partner-dr" || cfg.ChangeEventSubject != "partner.changed.>" {
+		t.Errorf("%s - parseRemoteConfig = %+v", federationCacheTestPrefix, cfg)
+	}
+	if cfg := parseRemoteConfig(&db.RegistryEntry{Alias: "partner", Config: []byte(`{"failover":"partner-dr"}`)}); len(cfg.Failover) != 0 {
+		t.Errorf("%s - expected an invalid failover list to be ignored, got %+v", federationCacheTestPrefix, cfg)
+	}
+}
+
+func TestCanonicalCapability(t *testing.T) {
+	app, name, ok := canonicalCapability("cap:@main/billing/invoice@1.2.0")
+	if !ok || app != "billing" || name != "invoice" {
+		t.Errorf("%s - canonicalCapability = %q %q %v", federationCacheTestPrefix, app, name, ok)
+	}
+	for _, id := range []string{"", "billing.invoice@1.2.0", "cap:@main/billing@1.2.0"} {
+		if _, _, ok := canonicalCapability(id); ok {
+			t.Errorf("%s - canonicalCapability(%q) should fail", federationCacheTestPrefix, id)
+		}
+	}
+}
+
+// waitFor polls cond until it holds or a second has passed.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s - condition not met in time", federationCacheTestPrefix)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
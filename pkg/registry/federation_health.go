@@ -0,0 +1,261 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/morezero/capabilities-registry/pkg/db"
+)
+
+const (
+	defaultFederationFailureThreshold = 3
+	defaultFederationCooldown         = 30 * time.Second
+
+	// Circuit states reported in RemoteRegistryHealth.
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+
+	// remoteStatusUnreachable is reported when a remote did not answer its health check.
+	remoteStatusUnreachable = "unreachable"
+)
+
+// FederationPoolOptions configures the per-alias circuit breaker, the resolve cache and the
+// loop detection of a FederationPool.
+type FederationPoolOptions struct {
+	// FailureThreshold is the number of consecutive failed requests that opens an alias's circuit.
+	FailureThreshold int
+	// Cooldown is how long an open circuit fails fast before one trial request is let through.
+	Cooldown time.Duration
+	// MaxStale is how long past its TTL a cached resolve answer may be served while the
+	// alias is unavailable; 0 disables stale answers.
+	MaxStale time.Duration
+	// SelfID identifies this registry in the trace of forwarded requests; requests that
+	// already passed SelfID are not forwarded again.
+	SelfID string
+	// MaxHops is the number of registry-to-registry forwards a request may take.
+	MaxHops int
+	// Static are aliases known without the registries table (e.g. the upstream of a mirror
+	// without a database); they take precedence over rows with the same alias.
+	Static []db.RegistryEntry
+}
+
+// remoteState is the circuit breaker and last health check of one alias.
+type remoteState struct {
+	failures int
+	// openedAt is set while the circuit is open (or half-open after the cooldown).
+	openedAt time.Time
+	// trial is true while the one half-open trial request is in flight.
+	trial  bool
+	health RemoteRegistryHealth
+}
+
+// allow returns REGISTRY_UNAVAILABLE while the alias's circuit is open. Once the cooldown
+// has passed it lets a single trial request through; its outcome closes or reopens the circuit.
+func (fp *FederationPool) allow(alias string) *RegistryError {
+	fp.stateMu.Lock()
+	defer fp.stateMu.Unlock()
+
+	st := fp.states[alias]
+	if st == nil || st.openedAt.IsZero() {
+		return nil
+	}
+	retryAt := st.openedAt.Add(fp.opts.Cooldown)
+	if fp.now().Before(retryAt) || st.trial {
+		return &RegistryError{
+			Code:    "REGISTRY_UNAVAILABLE",
+			Message: fmt.Sprintf("Registry alias %s is unavailable after %d failed requests; retry after %s", alias, st.failures, retryAt.UTC().Format(time.RFC3339)),
+		}
+	}
+	st.trial = true
+	return nil
+}
+
+// releaseTrial ends a half-open trial request that returned without recording an outcome
+// (a cancelled caller, a refused forward or a request that could not be built), so the next
+// request becomes the trial instead of the circuit failing fast until a health check.
+func (fp *FederationPool) releaseTrial(alias string) {
+	fp.stateMu.Lock()
+	defer fp.stateMu.Unlock()
+
+	if st := fp.states[alias]; st != nil {
+		st.trial = false
+	}
+}
+
+// recordSuccess closes the alias's circuit after a request got an answer.
+func (fp *FederationPool) recordSuccess(alias string, latency time.Duration) {
+	fp.stateMu.Lock()
+	defer fp.stateMu.Unlock()
+
+	st := fp.state(alias)
+	if !st.openedAt.IsZero() {
+		slog.Info(fmt.Sprintf("%s - circuit closed alias=%s", federationLogPrefix, alias))
+	}
+	ms := int(latency.Milliseconds())
+	st.failures, st.openedAt, st.trial = 0, time.Time{}, false
+	st.health.LatencyMs = &ms
+	st.health.LastSuccess = fp.now().UTC().Format(time.RFC3339)
+	st.health.LastError = ""
+}
+
+// recordFailure counts a request that could not reach the alias and opens (or reopens) its
+// circuit once FailureThreshold consecutive requests failed. A cancelled caller is not the
+// remote's fault and is not counted.
+func (fp *FederationPool) recordFailure(alias string, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	fp.stateMu.Lock()
+	defer fp.stateMu.Unlock()
+
+	st := fp.state(alias)
+	st.failures++
+	st.trial = false
+	st.health.LastError = err.Error()
+	if st.failures >= fp.opts.FailureThreshold {
+		if st.openedAt.IsZero() {
+			slog.Warn(fmt.Sprintf("%s - circuit opened alias=%s after %d failures: %v", federationLogPrefix, alias, st.failures, err))
+		}
+		st.openedAt = fp.now()
+	}
+}
+
+// recordHealth stores the outcome of a health check; status is the remote's own health
+// status, or "unreachable" when it did not answer.
+func (fp *FederationPool) recordHealth(alias, status string) {
+	fp.stateMu.Lock()
+	defer fp.stateMu.Unlock()
+
+	st := fp.state(alias)
+	st.health.Status = status
+	st.health.LastChecked = fp.now().UTC().Format(time.RFC3339)
+}
+
+// state returns the alias's state, creating it. stateMu must be held.
+func (fp *FederationPool) state(alias string) *remoteState {
+	st, ok := fp.states[alias]
+	if !ok {
+		st = &remoteState{health: RemoteRegistryHealth{Alias: alias, Status: "unknown"}}
+		fp.states[alias] = st
+	}
+	return st
+}
+
+// RemoteHealth returns the health and circuit state of an alias, or nil when no request
+// or health check has reached it yet.
+func (fp *FederationPool) RemoteHealth(alias string) *RemoteRegistryHealth {
+	fp.stateMu.Lock()
+	defer fp.stateMu.Unlock()
+
+	st, ok := fp.states[alias]
+	if !ok {
+		return nil
+	}
+	h := fp.snapshot(st)
+	return &h
+}
+
+// RemoteHealthAll returns the health of every alias with state, ordered by alias.
+func (fp *FederationPool) RemoteHealthAll() []RemoteRegistryHealth {
+	fp.stateMu.Lock()
+	defer fp.stateMu.Unlock()
+
+	out := make([]RemoteRegistryHealth, 0, len(fp.states))
+	for _, st := range fp.states {
+		out = append(out, fp.snapshot(st))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Alias < out[j].Alias })
+	return out
+}
+
+// snapshot copies st's health with its current circuit state. stateMu must be held.
+func (fp *FederationPool) snapshot(st *remoteState) RemoteRegistryHealth {
+	h := st.health
+	h.ConsecutiveFailures = st.failures
+	switch {
+	case st.openedAt.IsZero():
+		h.Circuit = CircuitClosed
+	case fp.now().Before(st.openedAt.Add(fp.opts.Cooldown)):
+		h.Circuit = CircuitOpen
+	default:
+		h.Circuit = CircuitHalfOpen
+	}
+	return h
+}
+
+// CheckHealth calls the health method of every alias that has a NATS URL and registry
+// subject, except skipAlias (the local registry), concurrently with the given timeout per
+// remote. Health checks bypass an open circuit, so a recovered remote closes it. State of
+// aliases that no longer exist is dropped.
+func (fp *FederationPool) CheckHealth(ctx context.Context, skipAlias string, timeout time.Duration) ([]RemoteRegistryHealth, error) {
+	entries, err := fp.listRegistries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	present := make(map[string]bool, len(entries))
+	var wg sync.WaitGroup
+	for i := range entries {
+		entry := entries[i]
+		present[entry.Alias] = true
+		if entry.Alias == skipAlias || !remoteEntryHasEndpoint(&entry) {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			var out HealthOutput
+			status := remoteStatusUnreachable
+			err := fp.send(checkCtx, &entry, "health", map[string]interface{}{}, &out)
+			if err == nil {
+				status = out.Status
+			} else if regErr, ok := err.(*RegistryError); ok && regErr.Code != "REGISTRY_UNAVAILABLE" {
+				// The remote answered, but not with a health result
+				status = "error"
+			}
+			fp.recordHealth(entry.Alias, status)
+		}()
+	}
+	wg.Wait()
+
+	fp.stateMu.Lock()
+	for alias := range fp.states {
+		if !present[alias] {
+			delete(fp.states, alias)
+		}
+	}
+	fp.stateMu.Unlock()
+
+	return fp.RemoteHealthAll(), nil
+}
+
+// Forget closes the connection to an alias and drops its circuit and health state and
+// cached answers, e.g. after the alias was changed or removed.
+func (fp *FederationPool) Forget(alias string) {
+	fp.mu.Lock()
+	if fc, ok := fp.connections[alias]; ok {
+		fc.nc.Close()
+		delete(fp.connections, alias)
+	}
+	fp.mu.Unlock()
+
+	fp.stateMu.Lock()
+	delete(fp.states, alias)
+	fp.stateMu.Unlock()
+
+	fp.invalidate(alias, "", "")
+}
+
+// remoteEntryHasEndpoint reports whether an alias can be called.
+func remoteEntryHasEndpoint(e *db.RegistryEntry) bool {
+	return e.NatsUrl != nil && *e.NatsUrl != "" && e.RegistrySubject != nil && *e.RegistrySubject != ""
+}
@@ -0,0 +1,193 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	comms "github.com/nats-io/nats.go"
+
+	"github.com/morezero/capabilities-registry/pkg/db"
+)
+
+const federationHealthTestPrefix = "registry:federation_health_test"
+
+func TestFederationPool_CircuitOpensAndRecovers(t *testing.T) {
+	fp := NewFederationPool(nil, FederationPoolOptions{FailureThreshold: 2, Cooldown: time.Minute})
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	fp.now = func() time.Time { return now }
+
+	fp.recordFailure("partner", errors.New("no responders"))
+	if err := fp.allow("partner"); err != nil {
+		t.Fatalf("%s - circuit should stay closed below the threshold, got %v", federationHealthTestPrefix, err)
+	}
+	fp.recordFailure("partner", errors.New("no responders"))
+	err := fp.allow("partner")
+	if err == nil || err.Code != "REGISTRY_UNAVAILABLE" {
+		t.Fatalf("%s - expected REGISTRY_UNAVAILABLE from an open circuit, got %v", federationHealthTestPrefix, err)
+	}
+	if h := fp.RemoteHealth("partner"); h.Circuit != CircuitOpen || h.ConsecutiveFailures != 2 || h.LastError != "no responders" {
+		t.Errorf("%s - health = %+v", federationHealthTestPrefix, h)
+	}
+
+	// After the cooldown one trial request goes through; concurrent ones still fail fast
+	now = now.Add(time.Minute)
+	if h := fp.RemoteHealth("partner"); h.Circuit != CircuitHalfOpen {
+		t.Errorf("%s - Circuit = %q, want half-open", federationHealthTestPrefix, h.Circuit)
+	}
+	if err := fp.allow("partner"); err != nil {
+		t.Fatalf("%s - expected the trial request to be allowed, got %v", federationHealthTestPrefix, err)
+	}
+	if err := fp.allow("partner"); err == nil {
+		t.Errorf("%s - expected a second request during the trial to fail fast", federationHealthTestPrefix)
+	}
+
+	fp.recordSuccess("partner", 15*time.Millisecond)
+	if err := fp.allow("partner"); err != nil {
+		t.Errorf("%s - expected a closed circuit after success, got %v", federationHealthTestPrefix, err)
+	}
+	h := fp.RemoteHealth("partner")
+	if h.Circuit != CircuitClosed || h.ConsecutiveFailures != 0 || h.LastError != "" || h.LatencyMs == nil || *h.LatencyMs != 15 {
+		t.Errorf("%s - health after recovery = %+v", federationHealthTestPrefix, h)
+	}
+}
+
+func TestFederationPool_FailedTrialReopens(t *testing.T) {
+	fp := NewFederationPool(nil, FederationPoolOptions{FailureThreshold: 1, Cooldown: time.Minute})
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	fp.now = func() time.Time { return now }
+
+	fp.recordFailure("partner", errors.New("connection refused"))
+	now = now.Add(time.Minute)
+	if err := fp.allow("partner"); err != nil {
+		t.Fatalf("%s - expected the trial request to be allowed, got %v", federationHealthTestPrefix, err)
+	}
+	fp.recordFailure("partner", errors.New("connection refused"))
+	if err := fp.allow("partner"); err == nil {
+		t.Errorf("%s - expected the circuit to reopen after a failed trial", federationHealthTestPrefix)
+	}
+}
+
+func TestFederationPool_CancelledCallerIsNotAFailure(t *testing.T) {
+	fp := NewFederationPool(nil, FederationPoolOptions{FailureThreshold: 1})
+	fp.recordFailure("partner", context.Canceled)
+	if err := fp.allow("partner"); err != nil {
+		t.Errorf("%s - a cancelled caller should not open the circuit, got %v", federationHealthTestPrefix, err)
+	}
+	if fp.RemoteHealth("partner") != nil {
+		t.Errorf("%s - expected no state for a cancelled request", federationHealthTestPrefix)
+	}
+}
+
+func TestFederationPool_CancelledTrialIsReleased(t *testing.T) {
+	nc := startProbeServer(t)
+	fp := NewFederationPool(nil, FederationPoolOptions{FailureThreshold: 1, Cooldown: time.Minute})
+	defer fp.CloseAll()
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	fp.now = func() time.Time { return now }
+	url := nc.ConnectedUrl()
+	subject := "cap.system.registry.v1"
+	entry := &db.RegistryEntry{Alias: "partner", NatsUrl: &url, RegistrySubject: &subject}
+
+	fp.recordFailure("partner", errors.New("connection refused"))
+	now = now.Add(time.Minute)
+
+	// The trial's caller goes away: no outcome is recorded, but the trial must end
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var out HealthOutput
+	if err := fp.callEntry(ctx, entry, "health", map[string]interface{}{}, &out); err == nil {
+		t.Fatalf("%s - expected the cancelled trial to fail", federationHealthTestPrefix)
+	}
+	if err := fp.allow("partner"); err != nil {
+		t.Errorf("%s - expected the next request to become the trial, got %v", federationHealthTestPrefix, err)
+	}
+	if h := fp.RemoteHealth("partner"); h.Circuit != CircuitHalfOpen || h.ConsecutiveFailures != 1 {
+		t.Errorf("%s - a cancelled trial must leave the circuit half-open, got %+v", federationHealthTestPrefix, h)
+	}
+}
+
+func TestFederationPool_SendRecordsRemoteOutcome(t *testing.T) {
+	nc := startProbeServer(t)
+	sub, err := nc.Subscribe("cap.system.registry.v1", func(msg *comms.Msg) {
+		_ = msg.Respond([]byte(`{"id":"fed","ok":true,"result":{"status":"healthy","checks":{"database":true},"timestamp":"2026-10-01T12:00:00Z"}}`))
+	})
+	if err != nil {
+		t.Fatalf("%s - subscribe failed: %v", federationHealthTestPrefix, err)
+	}
+	defer sub.Unsubscribe()
+
+	fp := NewFederationPool(nil, FederationPoolOptions{FailureThreshold: 1})
+	defer fp.CloseAll()
+	url := nc.ConnectedUrl()
+	subject := "cap.system.registry.v1"
+	ctx := context.Background()
+
+	var out HealthOutput
+	if err := fp.send(ctx, &db.RegistryEntry{Alias: "partner", NatsUrl: &url, RegistrySubject: &subject}, "health", map[string]interface{}{}, &out); err != nil {
+		t.Fatalf("%s - send failed: %v", federationHealthTestPrefix, err)
+	}
+	if out.Status != "healthy" {
+		t.Errorf("%s - remote status = %q", federationHealthTestPrefix, out.Status)
+	}
+	if h := fp.RemoteHealth("partner"); h == nil || h.Circuit != CircuitClosed || h.LastSuccess == "" {
+		t.Errorf("%s - health after success = %+v", federationHealthTestPrefix, h)
+	}
+
+	silent := "cap.system.registry.v9"
+	err = fp.send(ctx, &db.RegistryEntry{Alias: "sandbox", NatsUrl: &url, RegistrySubject: &silent}, "health", map[string]interface{}{}, &out)
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "REGISTRY_UNAVAILABLE" {
+		t.Fatalf("%s - expected REGISTRY_UNAVAILABLE without a responder, got %v", federationHealthTestPrefix, err)
+	}
+	if h := fp.RemoteHealth("sandbox"); h == nil || h.Circuit != CircuitOpen {
+		t.Errorf("%s - expected the sandbox circuit to open, got %+v", federationHealthTestPrefix, h)
+	}
+
+	fp.Forget("sandbox")
+	if fp.RemoteHealth("sandbox") != nil {
+		t.Errorf("%s - expected Forget to drop the alias state", federationHealthTestPrefix)
+	}
+	if all := fp.RemoteHealthAll(); len(all) != 1 || all[0].Alias != "partner" {
+		t.Errorf("%s - RemoteHealthAll = %+v", federationHealthTestPrefix, all)
+	}
+}
+
+func TestValidateRegistryEndpoint(t *testing.T) {
+	if err := validateRegistryEndpoint("", ""); err != nil {
+		t.Errorf("%s - an alias without endpoint is valid, got %v", federationHealthTestPrefix, err)
+	}
+	if err := validateRegistryEndpoint("nats://partner.example:4222", defaultRemoteRegistrySubject()); err != nil {
+		t.Errorf("%s - expected valid endpoint, got %v", federationHealthTestPrefix, err)
+	}
+	if err := validateRegistryEndpoint("http://partner.example", ""); err == nil || err.Code != "INVALID_ARGUMENT" {
+		t.Errorf("%s - expected INVALID_ARGUMENT for an http URL, got %v", federationHealthTestPrefix, err)
+	}
+	if err := validateRegistryEndpoint("nats://partner.example:4222", "cap.{app}.registry"); err == nil {
+		t.Errorf("%s - expected template tokens in the subject to be rejected", federationHealthTestPrefix)
+	}
+	if got := defaultRemoteRegistrySubject(); got != "cap.system.registry.v1" {
+		t.Errorf("%s - defaultRemoteRegistrySubject = %q", federationHealthTestPrefix, got)
+	}
+}
+
+func TestRegistries_RequireRepo(t *testing.T) {
+	reg := NewRegistry(NewRegistryParams{Repo: nil, Publisher: nil, Config: DefaultConfig()})
+	ctx := context.Background()
+
+	_, err := reg.AddRegistry(ctx, &AddRegistryInput{Alias: "partner", NatsUrl: "nats://partner.example:4222"})
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INTERNAL_ERROR" {
+		t.Errorf("%s - AddRegistry: expected INTERNAL_ERROR, got %v", federationHealthTestPrefix, err)
+	}
+	if _, err := reg.ListRegistries(ctx); err == nil {
+		t.Errorf("%s - ListRegistries: expected error without repo", federationHealthTestPrefix)
+	}
+	if _, err := reg.CheckRegistries(ctx); err == nil {
+		t.Errorf("%s - CheckRegistries: expected error without repo", federationHealthTestPrefix)
+	}
+	if h := reg.Health(ctx); len(h.Registries) != 0 {
+		t.Errorf("%s - expected no remote registries without a federation pool, got %+v", federationHealthTestPrefix, h.Registries)
+	}
+	// No repo: the health checker does not start.
+	reg.StartRegistryHealthChecker(ctx, time.Millisecond)
+}
@@ -0,0 +1,146 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	comms "github.com/nats-io/nats.go"
+
+	"github.com/morezero/capabilities-registry/pkg/semver"
+)
+
+// redactedToken replaces an inline token when a registry alias's config is returned.
+const redactedToken = "********"
+
+// remoteTLS is the "tls" object of a registries row's config. An empty object enables TLS
+// with the system roots; tls:// URLs enable it as well.
+type remoteTLS struct {
+	// CAFile is a PEM file of root CAs that verify the remote's certificate.
+	CAFile string `json:"caFile,omitempty"`
+	// CertFile and KeyFile are the client certificate presented to the remote.
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+}
+
+// remoteTrust is the "trust" object of a registries row's config. Empty lists allow all.
+type remoteTrust struct {
+	// Apps are the remote app namespaces that may be resolved through the alias.
+	Apps []string `json:"apps,omitempty"`
+	// Tenants are the local tenants that may use the alias.
+	Tenants []string `json:"tenants,omitempty"`
+}
+
+// allowApp returns FORBIDDEN when the remote app namespace is not trusted.
+func (t *remoteTrust) allowApp(alias, app string) *RegistryError {
+	if len(t.Apps) == 0 || slices.Contains(t.Apps, app) {
+		return nil
+	}
+	return &RegistryError{Code: "FORBIDDEN", Message: fmt.Sprintf("App %s of registry alias %s is not trusted", app, alias)}
+}
+
+// allowTenant returns FORBIDDEN when the alias is restricted to tenants and tenantID is
+// not one of them; a call without a tenant is refused by a restricted alias.
+func (t *remoteTrust) allowTenant(alias, tenantID string) *RegistryError {
+	if len(t.Tenants) == 0 || slices.Contains(t.Tenants, tenantID) {
+		return nil
+	}
+	if tenantID == "" {
+		return &RegistryError{Code: "FORBIDDEN", Message: fmt.Sprintf("Registry alias %s requires a tenant in ctx.tenantId", alias)}
+	}
+	return &RegistryError{Code: "FORBIDDEN", Message: fmt.Sprintf("Tenant %s may not use registry alias %s", tenantID, alias)}
+}
+
+// remoteConnectOptions returns the NATS options that secure a connection to a remote:
+// TLS, and at most one of a creds file, an nkey seed file or a token.
+func remoteConnectOptions(cfg remoteConfig) ([]comms.Option, error) {
+	var opts []comms.Option
+	if cfg.TLS != nil {
+		opts = append(opts, comms.Secure())
+		if cfg.TLS.CAFile != "" {
+			opts = append(opts, comms.RootCAs(cfg.TLS.CAFile))
+		}
+		if cfg.TLS.CertFile != "" {
+			opts = append(opts, comms.ClientCert(cfg.TLS.CertFile, cfg.TLS.KeyFile))
+		}
+	}
+	switch {
+	case cfg.CredsFile != "":
+		opts = append(opts, comms.UserCredentials(cfg.CredsFile))
+	case cfg.NkeySeedFile != "":
+		opt, err := comms.NkeyOptionFromSeed(cfg.NkeySeedFile)
+		if err != nil {
+			return nil, fmt.Errorf("nkeySeedFile: %w", err)
+		}
+		opts = append(opts, opt)
+	case cfg.TokenFile != "":
+		b, err := os.ReadFile(cfg.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("tokenFile: %w", err)
+		}
+		opts = append(opts, comms.Token(strings.TrimSpace(string(b))))
+	case cfg.Token != "":
+		opts = append(opts, comms.Token(cfg.Token))
+	}
+	return opts, nil
+}
+
+// validateRemoteConfig checks the federation settings in a registry alias's config:
+// their types, at most one credential, a TLS client key with its certificate, and failover
+// aliases other than alias itself.
+func validateRemoteConfig(alias string, config []byte) *RegistryError {
+	if config == nil {
+		return nil
+	}
+	var cfg remoteConfig
+	if err := json.NewDecoder(bytes.NewReader(config)).Decode(&cfg); err != nil {
+		return &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("config: %v", err)}
+	}
+	credentials := 0
+	for _, s := range []string{cfg.CredsFile, cfg.NkeySeedFile, cfg.Token, cfg.TokenFile} {
+		if s != "" {
+			credentials++
+		}
+	}
+	if credentials > 1 {
+		return &RegistryError{Code: "INVALID_ARGUMENT", Message: "config: set only one of credsFile, nkeySeedFile, token and tokenFile"}
+	}
+	if cfg.TLS != nil && (cfg.TLS.CertFile == "") != (cfg.TLS.KeyFile == "") {
+		return &RegistryError{Code: "INVALID_ARGUMENT", Message: "config: tls.certFile and tls.keyFile must be set together"}
+	}
+	for _, secondary := range cfg.Failover {
+		if secondary == alias || !semver.ValidateAppName(secondary) {
+			return &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("config: invalid failover alias %q", secondary)}
+		}
+	}
+	return nil
+}
+
+// redactRemoteConfig hides an inline token in a config map returned to callers.
+func redactRemoteConfig(config map[string]interface{}) map[string]interface{} {
+	if token, ok := config["token"].(string); ok && token != "" {
+		config["token"] = redactedToken
+	}
+	return config
+}
+
+// capRefApp returns the app of a capability ref in either "app/name" or "app.name" form;
+// the @alias hops of a multi-hop ref are skipped.
+func capRefApp(capRef string) string {
+	app, _ := splitCapRef(innermostCapRef(capRef))
+	return app
+}
+
+// splitCapRef splits "app/name@range" at the slash, or a dotted "app.name@range" at the
+// first dot, dropping the range.
+func splitCapRef(capRef string) (app, name string) {
+	capRef, _, _ = strings.Cut(capRef, "@")
+	if app, name, ok := strings.Cut(capRef, "/"); ok {
+		return app, name
+	}
+	app, name, _ = strings.Cut(capRef, ".")
+	return app, name
+}
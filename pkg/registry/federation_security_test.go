@@ -0,0 +1,152 @@
+package registry
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	commsserver "github.com/nats-io/nats-server/v2/server"
+	comms "github.com/nats-io/nats.go"
+
+	"github.com/morezero/capabilities-registry/pkg/db"
+)
+
+const federationSecurityTestPrefix = "registry:federation_security_test"
+
+func TestRemoteTrust(t *testing.T) {
+	trust := remoteTrust{Apps: []string{"billing"}, Tenants: []string{"acme"}}
+	if err := trust.allowApp("partner", "billing"); err != nil {
+		t.Errorf("%s - billing should be trusted, got %v", federationSecurityTestPrefix, err)
+	}
+	if err := trust.allowApp("partner", "crm"); err == nil || err.Code != "FORBIDDEN" {
+		t.Errorf("%s - expected FORBIDDEN for crm, got %v", federationSecurityTestPrefix, err)
+	}
+	if err := trust.allowTenant("partner", "acme"); err != nil {
+		t.Errorf("%s - acme should be allowed, got %v", federationSecurityTestPrefix, err)
+	}
+	for _, tenant := range []string{"globex", ""} {
+		if err := trust.allowTenant("partner", tenant); err == nil || err.Code != "FORBIDDEN" {
+			t.Errorf("%s - expected FORBIDDEN for tenant %q, got %v", federationSecurityTestPrefix, tenant, err)
+		}
+	}
+	open := remoteTrust{}
+	if open.allowApp("partner", "crm") != nil || open.allowTenant("partner", "") != nil {
+		t.Errorf("%s - an empty trust policy should allow everything", federationSecurityTestPrefix)
+	}
+}
+
+func TestValidateRemoteConfig(t *testing.T) {
+	valid := []string{
+		`{}`,
+		`{"tls":{},"credsFile":"/etc/registry/partner.creds","trust":{"apps":["billing"]}}`,
+		`{"tls":{"caFile":"ca.pem","certFile":"client.pem","keyFile":"client-key.pem"},"token":"s3cret"}`,
+		`{"failover":["partner-dr"],"team":"payments"}`,
+	}
+	for _, c := range valid {
+		if err := validateRemoteConfig("partner", []byte(c)); err != nil {
+			t.Errorf("%s - %s: expected valid, got %v", federationSecurityTestPrefix, c, err)
+		}
+	}
+	invalid := []string{
+		`{"token":"s3cret","credsFile":"partner.creds"}`,
+		`{"tls":{"certFile":"client.pem"}}`,
+		`{"trust":{"apps":"billing"}}`,
+		`{"failover":["partner"]}`,
+		`{"failover":["Partner DR"]}`,
+	}
+	for _, c := range invalid {
+		if err := validateRemoteConfig("partner", []byte(c)); err == nil || err.Code != "INVALID_ARGUMENT" {
+			t.Errorf("%s - %s: expected INVALID_ARGUMENT, got %v", federationSecurityTestPrefix, c, err)
+		}
+	}
+}
+
+func TestRemoteConnectOptions(t *testing.T) {
+	if _, err := remoteConnectOptions(remoteConfig{NkeySeedFile: filepath.Join(t.TempDir(), "missing.nk")}); err == nil {
+		t.Errorf("%s - expected an error for a missing nkey seed file", federationSecurityTestPrefix)
+	}
+	if _, err := remoteConnectOptions(remoteConfig{TokenFile: filepath.Join(t.TempDir(), "missing.token")}); err == nil {
+		t.Errorf("%s - expected an error for a missing token file", federationSecurityTestPrefix)
+	}
+	opts, err := remoteConnectOptions(remoteConfig{TLS: &remoteTLS{}, Token: "s3cret"})
+	if err != nil || len(opts) != 2 {
+		t.Errorf("%s - expected TLS and token options, got %d, %v", federationSecurityTestPrefix, len(opts), err)
+	}
+}
+
+func TestRedactRemoteConfig(t *testing.T) {
+	m := redactRemoteConfig(map[string]interface{}{"token": "s3cret", "failover": []interface{}{"partner-dr"}})
+	if m["token"] != redactedToken || m["failover"] == nil {
+		t.Errorf("%s - redacted config = %+v", federationSecurityTestPrefix, m)
+	}
+}
+
+func TestFederationPool_TokenAuthenticatedRemote(t *testing.T) {
+	ns, err := commsserver.NewServer(&commsserver.Options{Host: "127.0.0.1", Port: -1, NoLog: true, NoSigs: true, Authorization: "s3cret"})
+	if err != nil {
+		t.Fatalf("%s - failed to create NATS server: %v", federationSecurityTestPrefix, err)
+	}
+	go ns.Start()
+	defer ns.Shutdown()
+	if !ns.ReadyForConnections(10 * time.Second) {
+		t.Fatalf("%s - NATS server failed to start", federationSecurityTestPrefix)
+	}
+	remote, err := comms.Connect(ns.ClientURL(), comms.Token("s3cret"))
+	if err != nil {
+		t.Fatalf("%s - failed to connect: %v", federationSecurityTestPrefix, err)
+	}
+	defer remote.Close()
+	sub, err := remote.Subscribe("cap.system.registry.v1", func(msg *comms.Msg) {
+		_ = msg.Respond([]byte(`{"id":"fed","ok":true,"result":{"status":"healthy","checks":{},"timestamp":"2026-10-01T12:00:00Z"}}`))
+	})
+	if err != nil {
+		t.Fatalf("%s - subscribe failed: %v", federationSecurityTestPrefix, err)
+	}
+	defer sub.Unsubscribe()
+	_ = remote.Flush()
+
+	fp := NewFederationPool(nil, FederationPoolOptions{})
+	defer fp.CloseAll()
+	url, subject := ns.ClientURL(), "cap.system.registry.v1"
+	tokenFile := filepath.Join(t.TempDir(), "partner.token")
+	if err := os.WriteFile(tokenFile, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatalf("%s - write token file: %v", federationSecurityTestPrefix, err)
+	}
+
+	var out HealthOutput
+	entry := &db.RegistryEntry{Alias: "partner", NatsUrl: &url, RegistrySubject: &subject, Config: []byte(`{"tokenFile":"` + tokenFile + `"}`)}
+	if err := fp.send(context.Background(), entry, "health", map[string]interface{}{}, &out); err != nil || out.Status != "healthy" {
+		t.Fatalf("%s - expected the token to authenticate, got %v (%+v)", federationSecurityTestPrefix, err, out)
+	}
+
+	anonymous := &db.RegistryEntry{Alias: "anonymous", NatsUrl: &url, RegistrySubject: &subject}
+	err = fp.send(context.Background(), anonymous, "health", map[string]interface{}{}, &out)
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "REGISTRY_UNAVAILABLE" {
+		t.Errorf("%s - expected REGISTRY_UNAVAILABLE without credentials, got %v", federationSecurityTestPrefix, err)
+	}
+}
+
+func TestFederationPool_ResolveTrustPolicy(t *testing.T) {
+	fp := NewFederationPool(nil, FederationPoolOptions{})
+	// The remote is never contacted: the trust policy refuses first.
+	url, subject := "nats://127.0.0.1:1", "cap.system.registry.v1"
+	entry := &db.RegistryEntry{Alias: "partner", NatsUrl: &url, RegistrySubject: &subject,
+		Config: []byte(`{"trust":{"apps":["billing"],"tenants":["acme"]}}`)}
+
+	cases := []*FederatedResolveInput{
+		{Alias: "partner", Cap: "crm/contact", Ver: "^1", Ctx: &ResolutionContext{TenantID: "acme"}},
+		{Alias: "partner", Cap: "billing/invoice", Ver: "^1", Ctx: &ResolutionContext{TenantID: "globex"}},
+		{Alias: "partner", Cap: "billing/invoice", Ver: "^1"},
+	}
+	for _, input := range cases {
+		_, _, _, err := fp.resolveAt(context.Background(), entry, input)
+		if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "FORBIDDEN" {
+			t.Errorf("%s - %+v: expected FORBIDDEN, got %v", federationSecurityTestPrefix, input, err)
+		}
+	}
+	if fp.RemoteHealth("partner") != nil {
+		t.Errorf("%s - a refused request must not count against the remote", federationSecurityTestPrefix)
+	}
+}
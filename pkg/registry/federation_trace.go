@@ -0,0 +1,72 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const defaultFederationMaxHops = 4
+
+// FederationTrace is the provenance a federated request carries from registry to
+// registry: the number of forwards so far and the identities of the registries that
+// forwarded it, the originating registry first.
+type FederationTrace struct {
+	Hops    int      `json:"hops"`
+	Visited []string `json:"visited,omitempty"`
+}
+
+type federationTraceKey struct{}
+
+// WithFederationTrace returns ctx carrying the trace of an incoming federated request, so
+// requests the registry forwards while serving it extend the trace. A nil trace returns ctx.
+func WithFederationTrace(ctx context.Context, trace *FederationTrace) context.Context {
+	if trace == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, federationTraceKey{}, trace)
+}
+
+// federationTraceFrom returns the trace of the request being served, or nil.
+func federationTraceFrom(ctx context.Context) *FederationTrace {
+	trace, _ := ctx.Value(federationTraceKey{}).(*FederationTrace)
+	return trace
+}
+
+// nextTrace returns the trace sent with a request forwarded to alias. It fails with
+// FEDERATION_LOOP when this registry already forwarded the request once (the request came
+// back around) or when the forward would exceed MaxHops.
+func (fp *FederationPool) nextTrace(ctx context.Context, alias string) (*FederationTrace, *RegistryError) {
+	next := &FederationTrace{Hops: 1}
+	if in := federationTraceFrom(ctx); in != nil {
+		path := strings.Join(append(slices.Clone(in.Visited), fp.opts.SelfID), " -> ")
+		if fp.opts.SelfID != "" && slices.Contains(in.Visited, fp.opts.SelfID) {
+			return nil, &RegistryError{
+				Code:    "FEDERATION_LOOP",
+				Message: fmt.Sprintf("Federated request to alias %s would pass this registry twice: %s", alias, path),
+			}
+		}
+		if in.Hops >= fp.opts.MaxHops {
+			return nil, &RegistryError{
+				Code:    "FEDERATION_LOOP",
+				Message: fmt.Sprintf("Federated request to alias %s exceeds %d hops: %s", alias, fp.opts.MaxHops, path),
+			}
+		}
+		next.Hops = in.Hops + 1
+		next.Visited = slices.Clone(in.Visited)
+	}
+	if fp.opts.SelfID != "" {
+		next.Visited = append(next.Visited, fp.opts.SelfID)
+	}
+	return next, nil
+}
+
+// innermostCapRef strips the @alias hops of a multi-hop ref such as "@c/app/name@range",
+// leaving "app/name@range".
+func innermostCapRef(capRef string) string {
+	for strings.HasPrefix(capRef, "@") {
+		_, capRef = extractAlias(capRef)
+	}
+	return capRef
+}
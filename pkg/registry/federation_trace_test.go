@@ -0,0 +1,115 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	comms "github.com/nats-io/nats.go"
+
+	"github.com/morezero/capabilities-registry/pkg/db"
+)
+
+const federationTraceTestPrefix = "registry:federation_trace_test"
+
+func TestFederationPool_NextTrace(t *testing.T) {
+	fp := NewFederationPool(nil, FederationPoolOptions{SelfID: "a", MaxHops: 3})
+
+	trace, err := fp.nextTrace(context.Background(), "b")
+	if err != nil || trace.Hops != 1 || len(trace.Visited) != 1 || trace.Visited[0] != "a" {
+		t.Fatalf("%s - origin trace = %+v, %v", federationTraceTestPrefix, trace, err)
+	}
+
+	visited := []string{"c", "d"}
+	ctx := WithFederationTrace(context.Background(), &FederationTrace{Hops: 2, Visited: visited})
+	trace, err = fp.nextTrace(ctx, "b")
+	if err != nil || trace.Hops != 3 || len(trace.Visited) != 3 || trace.Visited[2] != "a" {
+		t.Fatalf("%s - forwarded trace = %+v, %v", federationTraceTestPrefix, trace, err)
+	}
+	if len(visited) != 2 {
+		t.Errorf("%s - the incoming trace must not be modified", federationTraceTestPrefix)
+	}
+
+	ctx = WithFederationTrace(context.Background(), &FederationTrace{Hops: 2, Visited: []string{"a", "b"}})
+	if _, err := fp.nextTrace(ctx, "b"); err == nil || err.Code != "FEDERATION_LOOP" {
+		t.Errorf("%s - expected FEDERATION_LOOP for a request that passed a before, got %v", federationTraceTestPrefix, err)
+	}
+	ctx = WithFederationTrace(context.Background(), &FederationTrace{Hops: 3, Visited: []string{"c", "d", "e"}})
+	if _, err := fp.nextTrace(ctx, "b"); err == nil || err.Code != "FEDERATION_LOOP" {
+		t.Errorf("%s - expected FEDERATION_LOOP past MaxHops, got %v", federationTraceTestPrefix, err)
+	}
+
+	if WithFederationTrace(context.Background(), nil) != context.Background() {
+		t.Errorf("%s - a nil trace should leave ctx unchanged", federationTraceTestPrefix)
+	}
+}
+
+func TestFederationPool_LoopIsRefusedBeforeConnecting(t *testing.T) {
+	fp := NewFederationPool(nil, FederationPoolOptions{SelfID: "a"})
+	url, subject := "nats://127.0.0.1:1", "cap.system.registry.v1"
+	ctx := WithFederationTrace(context.Background(), &FederationTrace{Hops: 1, Visited: []string{"a"}})
+
+	var out HealthOutput
+	err := fp.send(ctx, &db.RegistryEntry{Alias: "b", NatsUrl: &url, RegistrySubject: &subject}, "health", map[string]interface{}{}, &out)
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "FEDERATION_LOOP" {
+		t.Fatalf("%s - expected FEDERATION_LOOP, got %v", federationTraceTestPrefix, err)
+	}
+	if fp.RemoteHealth("b") != nil {
+		t.Errorf("%s - a refused loop must not count against the remote", federationTraceTestPrefix)
+	}
+}
+
+func TestFederationPool_MultiHopResolve(t *testing.T) {
+	nc := startProbeServer(t)
+	var got struct {
+		Params     map[string]interface{} `json:"params"`
+		Federation *FederationTrace       `json:"federation"`
+	}
+	// Registry b forwards @c/billing/invoice to its alias c and reports the path it took
+	sub, err := nc.Subscribe("cap.system.registry.v1", func(msg *comms.Msg) {
+		_ = json.Unmarshal(msg.Data, &got)
+		_ = msg.Respond([]byte(`{"id":"fed","ok":true,"result":{"canonicalIdentity":"cap:@c/billing/invoice@1.2.0","subject":"cap.billing.invoice.v1","resolvedVersion":"1.2.0","major":1,"status":"active","ttlSeconds":60,"federationPath":["@c"]}}`))
+	})
+	if err != nil {
+		t.Fatalf("%s - subscribe failed: %v", federationTraceTestPrefix, err)
+	}
+	defer sub.Unsubscribe()
+
+	fp := NewFederationPool(nil, FederationPoolOptions{SelfID: "a"})
+	defer fp.CloseAll()
+	url, subject := nc.ConnectedUrl(), "cap.system.registry.v1"
+	entry := &db.RegistryEntry{Alias: "b", NatsUrl: &url, RegistrySubject: &subject}
+
+	out, _, _, err := fp.resolveAt(context.Background(), entry, &FederatedResolveInput{Alias: "b", Cap: "@c/billing/invoice", Ver: "^1"})
+	if err != nil {
+		t.Fatalf("%s - resolve failed: %v", federationTraceTestPrefix, err)
+	}
+	if got.Params["cap"] != "@c/billing/invoice" {
+		t.Errorf("%s - forwarded cap = %v, want the multi-hop ref unchanged", federationTraceTestPrefix, got.Params["cap"])
+	}
+	if got.Federation == nil || got.Federation.Hops != 1 || len(got.Federation.Visited) != 1 || got.Federation.Visited[0] != "a" {
+		t.Errorf("%s - forwarded trace = %+v", federationTraceTestPrefix, got.Federation)
+	}
+	if out.CanonicalIdentity != "cap:@b/billing/invoice@1.2.0" {
+		t.Errorf("%s - CanonicalIdentity = %q", federationTraceTestPrefix, out.CanonicalIdentity)
+	}
+	if len(out.FederationPath) != 2 || out.FederationPath[0] != "@b" || out.FederationPath[1] != "@c" {
+		t.Errorf("%s - FederationPath = %v, want [@b @c]", federationTraceTestPrefix, out.FederationPath)
+	}
+}
+
+func TestCapRefAppAndInnermostCapRef(t *testing.T) {
+	if got := innermostCapRef("@c/@d/billing/invoice@^1"); got != "billing/invoice@^1" {
+		t.Errorf("%s - innermostCapRef = %q", federationTraceTestPrefix, got)
+	}
+	tests := map[string]string{
+		"@c/billing/invoice": "billing",
+		"my.app/my.cap":      "my.app",
+		"billing.invoice@^1": "billing",
+	}
+	for in, want := range tests {
+		if got := capRefApp(in); got != want {
+			t.Errorf("%s - capRefApp(%q) = %q, want %q", federationTraceTestPrefix, in, got, want)
+		}
+	}
+}
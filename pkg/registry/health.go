@@ -2,20 +2,25 @@ package registry
 
 import (
 	"context"
+	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/morezero/capabilities-registry/pkg/db"
 )
 
-// Health checks the registry service health.
+// Health checks the registry service health. The database check covers the catalog store,
+// so it passes for a mirror with a local store. Availability summarizes the latest responder
+// probes and Registries the remote registries; unreachable providers or remotes do not make
+// the registry itself unhealthy.
 func (r *Registry) Health(ctx context.Context) *HealthOutput {
 	dbOk := true
 
-	if r.repo == nil {
+	if r.catalog == nil {
 		dbOk = false
 	} else {
 		// Simple DB check
-		_, _, err := r.repo.ListCapabilities(ctx, db.ListCapabilitiesParams{Limit: 1})
+		_, _, err := r.catalog.ListCapabilities(ctx, db.ListCapabilitiesParams{Limit: 1})
 		if err != nil {
 			dbOk = false
 		}
@@ -26,11 +31,38 @@ func (r *Registry) Health(ctx context.Context) *HealthOutput {
 		status = "unhealthy"
 	}
 
-	return &HealthOutput{
+	out := &HealthOutput{
 		Status: status,
 		Checks: HealthChecks{
 			Database: dbOk,
+			COMMS:    r.nc != nil && r.nc.IsConnected(),
 		},
 		Timestamp: time.Now().UTC().Format(time.RFC3339),
 	}
+	if dbOk && r.repo != nil {
+		out.Availability = r.availabilitySummary(ctx)
+	}
+	out.Registries = r.remoteRegistryHealth()
+	out.Mirror = r.mirrorHealth()
+	return out
+}
+
+// availabilitySummary counts served versions by latest probe status, or nil on failure.
+func (r *Registry) availabilitySummary(ctx context.Context) *AvailabilitySummary {
+	summary, err := r.repo.SummarizeProbes(ctx)
+	if err != nil {
+		slog.Error(fmt.Sprintf("%s - SummarizeProbes failed: %v", probeLogPrefix, err))
+		return nil
+	}
+	out := &AvailabilitySummary{
+		Reachable:   summary.ByStatus[db.ProbeStatusReachable],
+		Unreachable: summary.ByStatus[db.ProbeStatusUnreachable],
+		Timeout:     summary.ByStatus[db.ProbeStatusTimeout],
+		Error:       summary.ByStatus[db.ProbeStatusError],
+		Unknown:     summary.ByStatus[db.ProbeStatusUnknown],
+	}
+	if summary.LastProbed != nil {
+		out.LastProbed = summary.LastProbed.UTC().Format(time.RFC3339)
+	}
+	return out
 }
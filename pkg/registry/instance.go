@@ -0,0 +1,381 @@
+package registry
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/morezero/capabilities-registry/pkg/db"
+	"github.com/morezero/capabilities-registry/pkg/events"
+	"github.com/morezero/capabilities-registry/pkg/semver"
+)
+
+const (
+	instanceLogPrefix     = "registry:instance"
+	maxInstanceTTLSeconds = 3600
+	maxInstanceIDLen      = 128
+
+	// Liveness policies: how resolve treats a version with no live instances.
+	livenessNone = "none"
+	livenessWarn = "warn"
+	livenessSkip = "skip"
+)
+
+// RegisterInstance records a live provider instance for an exact capability version and
+// returns its lease. The provider keeps the lease with heartbeat calls; an expired lease is
+// reaped. A version gaining its first live instance publishes a change event.
+func (r *Registry) RegisterInstance(ctx context.Context, input *RegisterInstanceInput, userID string) (*InstanceLease, error) {
+	slog.Info(fmt.Sprintf("%s - registerInstance cap=%s version=%s instance=%s", instanceLogPrefix, input.Cap, input.Version, input.InstanceID))
+
+	if err := r.requireRepo(); err != nil {
+		return nil, err
+	}
+	if err := validateRegisterInstanceInput(input); err != nil {
+		return nil, err
+	}
+	ttl, regErr := r.instanceTTL(input.TTLSeconds)
+	if regErr != nil {
+		return nil, regErr
+	}
+
+	parsed, cap, regErr := r.lookupCapability(ctx, input.Cap)
+	if regErr != nil {
+		return nil, regErr
+	}
+	version := input.Version
+	if version == "" {
+		version = parsed.Range
+	}
+	if !semver.IsExactVersion(version) {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: "registerInstance requires an exact version"}
+	}
+	versions, err := r.repo.GetVersions(ctx, cap.ID)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	var match *semver.VersionRecord
+	records := dbVersionsToRecords(versions)
+	for i := range records {
+		if records[i].VersionString == version {
+			match = &records[i]
+			break
+		}
+	}
+	if match == nil {
+		return nil, &RegistryError{Code: "NOT_FOUND", Message: fmt.Sprintf("Version %s not found for: %s", version, parsed.Full)}
+	}
+	if match.Status == "disabled" {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("cannot register instances of disabled version %s of %s", version, parsed.Full)}
+	}
+
+	instanceID := input.InstanceID
+	if instanceID == "" {
+		instanceID = newInstanceID()
+	}
+	previous, err := r.repo.GetInstance(ctx, instanceID)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	now := time.Now()
+	inst, err := r.repo.RegisterInstance(ctx, db.RegisterInstanceParams{
+		VersionID:  match.ID,
+		InstanceID: instanceID,
+		NatsUrl:    optionalString(input.NatsUrl),
+		Region:     optionalString(input.Region),
+		Zone:       optionalString(input.Zone),
+		TTL:        ttl,
+		UserID:     userID,
+	})
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+
+	versionIDs := []string{inst.VersionID}
+	moved := previous != nil && previous.VersionID != inst.VersionID
+	if moved {
+		versionIDs = append(versionIDs, previous.VersionID)
+	}
+	counts, err := r.repo.CountLiveInstances(ctx, versionIDs)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	wasLive := previous != nil && !moved && previous.LeaseExpiresAt.After(now)
+	if !wasLive && counts[inst.VersionID] == 1 {
+		r.publishInstancesChanged(ctx, inst, 1)
+	}
+	if moved && counts[previous.VersionID] == 0 {
+		r.publishInstancesChanged(ctx, previous, 0)
+	}
+	return instanceToLease(inst, ttl, counts[inst.VersionID]), nil
+}
+
+// Heartbeat extends a live instance lease. An unknown instance or an expired lease returns
+// NOT_FOUND; the provider must call registerInstance again.
+func (r *Registry) Heartbeat(ctx context.Context, input *HeartbeatInput) (*InstanceLease, error) {
+	slog.Debug(fmt.Sprintf("%s - heartbeat instance=%s", instanceLogPrefix, input.InstanceID))
+
+	if err := r.requireRepo(); err != nil {
+		return nil, err
+	}
+	if input.InstanceID == "" {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: "instanceId is required"}
+	}
+	ttl, regErr := r.instanceTTL(input.TTLSeconds)
+	if regErr != nil {
+		return nil, regErr
+	}
+	inst, err := r.repo.HeartbeatInstance(ctx, input.InstanceID, ttl)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	if inst == nil {
+		return nil, &RegistryError{
+			Code:    "NOT_FOUND",
+			Message: fmt.Sprintf("Instance %s is unknown or its lease expired; register it again", input.InstanceID),
+		}
+	}
+	counts, err := r.repo.CountLiveInstances(ctx, []string{inst.VersionID})
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	return instanceToLease(inst, ttl, counts[inst.VersionID]), nil
+}
+
+// DeregisterInstance removes an instance lease, e.g. on graceful provider shutdown.
+// A version losing its last live instance publishes a change event.
+func (r *Registry) DeregisterInstance(ctx context.Context, input *DeregisterInstanceInput) (*DeregisterInstanceOutput, error) {
+	slog.Info(fmt.Sprintf("%s - deregisterInstance instance=%s", instanceLogPrefix, input.InstanceID))
+
+	if err := r.requireRepo(); err != nil {
+		return nil, err
+	}
+	if input.InstanceID == "" {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: "instanceId is required"}
+	}
+	inst, err := r.repo.DeregisterInstance(ctx, input.InstanceID)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	if inst == nil {
+		return &DeregisterInstanceOutput{Removed: false}, nil
+	}
+	counts, err := r.repo.CountLiveInstances(ctx, []string{inst.VersionID})
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	if counts[inst.VersionID] == 0 {
+		r.publishInstancesChanged(ctx, inst, 0)
+	}
+	return &DeregisterInstanceOutput{Removed: true, LiveInstances: counts[inst.VersionID]}, nil
+}
+
+// ReapExpiredInstances deletes expired instance leases and publishes a change event for each
+// version left without live instances. Returns the number of reaped instances.
+func (r *Registry) ReapExpiredInstances(ctx context.Context) (int, error) {
+	if err := r.requireRepo(); err != nil {
+		return 0, err
+	}
+	reaped, err := r.repo.ReapExpiredInstances(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(reaped) == 0 {
+		return 0, nil
+	}
+
+	byVersion := make(map[string]*db.CapabilityInstance)
+	versionIDs := make([]string, 0, len(reaped))
+	for i := range reaped {
+		if _, ok := byVersion[reaped[i].VersionID]; !ok {
+			byVersion[reaped[i].VersionID] = &reaped[i]
+			versionIDs = append(versionIDs, reaped[i].VersionID)
+		}
+	}
+	counts, err := r.repo.CountLiveInstances(ctx, versionIDs)
+	if err != nil {
+		return len(reaped), err
+	}
+	for _, id := range versionIDs {
+		if counts[id] == 0 {
+			r.publishInstancesChanged(ctx, byVersion[id], 0)
+		}
+	}
+	slog.Info(fmt.Sprintf("%s - reaped %d expired instances across %d versions", instanceLogPrefix, len(reaped), len(versionIDs)))
+	return len(reaped), nil
+}
+
+// StartInstanceReaper runs ReapExpiredInstances every interval until ctx is cancelled.
+// It does nothing without a repository or with a non-positive interval.
+func (r *Registry) StartInstanceReaper(ctx context.Context, interval time.Duration) {
+	if r.repo == nil || interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := r.ReapExpiredInstances(ctx); err != nil {
+					slog.Error(fmt.Sprintf("%s - reap failed: %v", instanceLogPrefix, err))
+				}
+			}
+		}
+	}()
+}
+
+// applyLivenessPolicy checks the resolved version against the capability's liveness policy.
+// "warn" adds a no_live_instances warning; "skip" re-resolves among versions with live
+// instances and fails with NOT_FOUND when there are none. A pinned (release) version is
+// never replaced, only warned about. Lookup failures, and a registry without a repository to
+// track instances in, leave the resolution unchanged.
+func (r *Registry) applyLivenessPolicy(ctx context.Context, cap *db.Capability, capFull string, params semver.ResolveVersionParams, resolved *semver.VersionRecord, pinned bool) (*semver.VersionRecord, []Warning, *RegistryError) {
+	policy := livenessPolicy(cap)
+	if policy == livenessNone || r.repo == nil {
+		return resolved, nil, nil
+	}
+	versionIDs := make([]string, len(params.Versions))
+	for i, v := range params.Versions {
+		versionIDs[i] = v.ID
+	}
+	counts, err := r.repo.CountLiveInstances(ctx, versionIDs)
+	if err != nil {
+		slog.Error(fmt.Sprintf("%s - CountLiveInstances failed for %s: %v", instanceLogPrefix, capFull, err))
+		return resolved, nil, nil
+	}
+	if counts[resolved.ID] > 0 {
+		return resolved, nil, nil
+	}
+	if policy == livenessWarn || pinned {
+		return resolved, []Warning{{
+			Code:    "no_live_instances",
+			Message: fmt.Sprintf("%s@%s has no live instances", capFull, resolved.VersionString),
+		}}, nil
+	}
+
+	live := make([]semver.VersionRecord, 0, len(params.Versions))
+	for _, v := range params.Versions {
+		if counts[v.ID] > 0 {
+			live = append(live, v)
+		}
+	}
+	params.Versions = live
+	if alt := semver.ResolveVersion(params); alt != nil {
+		return alt, nil, nil
+	}
+	return nil, nil, &RegistryError{
+		Code:    "NOT_FOUND",
+		Message: fmt.Sprintf("No version of %s@%s has live instances", capFull, orDefault(params.Range, "default")),
+	}
+}
+
+// liveInstanceCount returns the number of live instances of one version, or 0 on lookup failure
+// or without a repository.
+func (r *Registry) liveInstanceCount(ctx context.Context, versionID string) int {
+	if r.repo == nil {
+		return 0
+	}
+	counts, err := r.repo.CountLiveInstances(ctx, []string{versionID})
+	if err != nil {
+		slog.Error(fmt.Sprintf("%s - CountLiveInstances failed for version %s: %v", instanceLogPrefix, versionID, err))
+		return 0
+	}
+	return counts[versionID]
+}
+
+func (r *Registry) publishInstancesChanged(ctx context.Context, inst *db.CapabilityInstance, live int) {
+	revision, _ := r.repo.IncrementRevision(ctx, inst.CapabilityID)
+	if err := r.publisher.PublishChanged(ctx, &events.RegistryChangedEvent{
+		Kind:             events.KindInstancesChanged,
+		App:              inst.App,
+		Capability:       inst.Name,
+		ChangedFields:    []string{"instances"},
+		AffectedMajors:   []int{inst.Major},
+		AffectedVersions: []string{inst.VersionString},
+		New:              map[string]interface{}{"liveInstances": live},
+		Revision:         revision,
+		Etag:             fmt.Sprintf("%s-%d", inst.CapabilityID, revision),
+		Timestamp:        time.Now().UTC().Format(time.RFC3339),
+		Version:          inst.VersionString,
+		LiveInstances:    &live,
+	}); err != nil {
+		slog.Error(fmt.Sprintf("%s - PublishChanged failed: %v", instanceLogPrefix, err))
+	}
+}
+
+// instanceTTL returns the lease length for a request, defaulting to the configured TTL.
+func (r *Registry) instanceTTL(seconds int) (time.Duration, *RegistryError) {
+	if seconds == 0 {
+		seconds = r.config.InstanceTTLSeconds
+	}
+	if seconds < 1 || seconds > maxInstanceTTLSeconds {
+		return 0, &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("ttlSeconds must be 1-%d", maxInstanceTTLSeconds)}
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+func validateRegisterInstanceInput(input *RegisterInstanceInput) *RegistryError {
+	if input.Cap == "" {
+		return &RegistryError{Code: "INVALID_ARGUMENT", Message: "cap is required"}
+	}
+	if len(input.InstanceID) > maxInstanceIDLen {
+		return &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("instanceId must be at most %d characters", maxInstanceIDLen)}
+	}
+	for _, c := range input.InstanceID {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.' || c == ':') {
+			return &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("instanceId contains invalid character %q", c)}
+		}
+	}
+	if input.NatsUrl != "" {
+		if err := validateNatsUrl(input.NatsUrl); err != nil {
+			return &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("natsUrl %v", err)}
+		}
+	}
+	if len(input.Region) > maxEndpointLabelLen || len(input.Zone) > maxEndpointLabelLen {
+		return &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("region and zone must be at most %d characters", maxEndpointLabelLen)}
+	}
+	return nil
+}
+
+// validateLivenessPolicy accepts "" (keep current) or one of the known policies.
+func validateLivenessPolicy(policy string) *RegistryError {
+	switch policy {
+	case "", livenessNone, livenessWarn, livenessSkip:
+		return nil
+	default:
+		return &RegistryError{Code: "INVALID_ARGUMENT", Message: "livenessPolicy must be none, warn or skip"}
+	}
+}
+
+// livenessPolicy returns the capability's liveness policy, defaulting to none.
+func livenessPolicy(cap *db.Capability) string {
+	if cap == nil || cap.LivenessPolicy == nil || *cap.LivenessPolicy == "" {
+		return livenessNone
+	}
+	return *cap.LivenessPolicy
+}
+
+func instanceToLease(inst *db.CapabilityInstance, ttl time.Duration, live int) *InstanceLease {
+	return &InstanceLease{
+		InstanceID:     inst.InstanceID,
+		Cap:            inst.App + "." + inst.Name,
+		Version:        inst.VersionString,
+		Major:          inst.Major,
+		TTLSeconds:     int(ttl / time.Second),
+		LeaseExpiresAt: inst.LeaseExpiresAt.UTC().Format(time.RFC3339),
+		LiveInstances:  live,
+	}
+}
+
+func newInstanceID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("inst-%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
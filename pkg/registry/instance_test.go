@@ -0,0 +1,102 @@
+package registry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/morezero/capabilities-registry/pkg/db"
+)
+
+const instanceTestPrefix = "registry:instance_test"
+
+func TestValidateRegisterInstanceInput(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     RegisterInstanceInput
+		expectErr bool
+	}{
+		{"minimal", RegisterInstanceInput{Cap: "more0.doc.ingest", Version: "1.0.0"}, false},
+		{"full", RegisterInstanceInput{Cap: "more0.doc.ingest", Version: "1.0.0", InstanceID: "pod-7:worker_1", NatsUrl: "nats://eu.example:4222", Region: "eu-west"}, false},
+		{"missing cap", RegisterInstanceInput{Version: "1.0.0"}, true},
+		{"bad instance id", RegisterInstanceInput{Cap: "more0.doc.ingest", InstanceID: "pod 7"}, true},
+		{"bad nats url", RegisterInstanceInput{Cap: "more0.doc.ingest", NatsUrl: "http://eu.example"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRegisterInstanceInput(&tt.input)
+			if tt.expectErr && (err == nil || err.Code != "INVALID_ARGUMENT") {
+				t.Errorf("%s - expected INVALID_ARGUMENT, got %v", instanceTestPrefix, err)
+			}
+			if !tt.expectErr && err != nil {
+				t.Errorf("%s - unexpected error: %v", instanceTestPrefix, err)
+			}
+		})
+	}
+}
+
+func TestValidateLivenessPolicy(t *testing.T) {
+	for _, p := range []string{"", "none", "warn", "skip"} {
+		if err := validateLivenessPolicy(p); err != nil {
+			t.Errorf("%s - policy %q: unexpected error %v", instanceTestPrefix, p, err)
+		}
+	}
+	if err := validateLivenessPolicy("drop"); err == nil || err.Code != "INVALID_ARGUMENT" {
+		t.Errorf("%s - expected INVALID_ARGUMENT for unknown policy, got %v", instanceTestPrefix, err)
+	}
+}
+
+func TestLivenessPolicy_Default(t *testing.T) {
+	if got := livenessPolicy(nil); got != livenessNone {
+		t.Errorf("%s - livenessPolicy(nil) = %q, want none", instanceTestPrefix, got)
+	}
+	skip := "skip"
+	if got := livenessPolicy(&db.Capability{LivenessPolicy: &skip}); got != livenessSkip {
+		t.Errorf("%s - livenessPolicy = %q, want skip", instanceTestPrefix, got)
+	}
+}
+
+func TestInstanceTTL(t *testing.T) {
+	reg := NewRegistry(NewRegistryParams{Repo: nil, Publisher: nil, Config: DefaultConfig()})
+	if ttl, err := reg.instanceTTL(0); err != nil || ttl != defaultInstanceTTL*time.Second {
+		t.Errorf("%s - default ttl = %v, %v", instanceTestPrefix, ttl, err)
+	}
+	if ttl, err := reg.instanceTTL(5); err != nil || ttl != 5*time.Second {
+		t.Errorf("%s - ttl(5) = %v, %v", instanceTestPrefix, ttl, err)
+	}
+	for _, bad := range []int{-1, maxInstanceTTLSeconds + 1} {
+		if _, err := reg.instanceTTL(bad); err == nil || err.Code != "INVALID_ARGUMENT" {
+			t.Errorf("%s - ttl(%d): expected INVALID_ARGUMENT, got %v", instanceTestPrefix, bad, err)
+		}
+	}
+}
+
+func TestNewInstanceID_Unique(t *testing.T) {
+	a, b := newInstanceID(), newInstanceID()
+	if a == b || len(a) != 32 {
+		t.Errorf("%s - newInstanceID returned %q and %q", instanceTestPrefix, a, b)
+	}
+}
+
+func TestInstanceMethods_RequireRepo(t *testing.T) {
+	reg := NewRegistry(NewRegistryParams{Repo: nil, Publisher: nil, Config: DefaultConfig()})
+	ctx := context.Background()
+
+	_, err := reg.RegisterInstance(ctx, &RegisterInstanceInput{Cap: "more0.doc.ingest", Version: "1.0.0"}, "test-user")
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INTERNAL_ERROR" {
+		t.Errorf("%s - RegisterInstance: expected INTERNAL_ERROR, got %v", instanceTestPrefix, err)
+	}
+	_, err = reg.Heartbeat(ctx, &HeartbeatInput{InstanceID: "worker-1"})
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INTERNAL_ERROR" {
+		t.Errorf("%s - Heartbeat: expected INTERNAL_ERROR, got %v", instanceTestPrefix, err)
+	}
+	_, err = reg.DeregisterInstance(ctx, &DeregisterInstanceInput{InstanceID: "worker-1"})
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INTERNAL_ERROR" {
+		t.Errorf("%s - DeregisterInstance: expected INTERNAL_ERROR, got %v", instanceTestPrefix, err)
+	}
+	if _, err := reg.ReapExpiredInstances(ctx); err == nil {
+		t.Errorf("%s - ReapExpiredInstances: expected error without repo", instanceTestPrefix)
+	}
+	// Without a repository the reaper is not started.
+	reg.StartInstanceReaper(ctx, time.Millisecond)
+}
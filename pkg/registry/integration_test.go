@@ -4,11 +4,23 @@ package registry
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	commsserver "github.com/nats-io/nats-server/v2/server"
+	comms "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+
+	"github.com/morezero/capabilities-registry/pkg/commsutil"
 	"github.com/morezero/capabilities-registry/pkg/db"
 	"github.com/morezero/capabilities-registry/pkg/events"
 )
@@ -436,7 +448,7 @@ func TestIntegration_GetBootstrapCapabilities_WithData(t *testing.T) {
 		t.Fatalf("%s - Upsert failed: %v", regIntegrationPrefix, err)
 	}
 
-	caps, err := reg.GetBootstrapCapabilities(ctx, "production", true, false)
+	caps, err := reg.GetBootstrapCapabilities(ctx, &ResolutionContext{Env: "production"}, true, false)
 	if err != nil {
 		t.Fatalf("%s - GetBootstrapCapabilities failed: %v", regIntegrationPrefix, err)
 	}
@@ -492,3 +504,1169 @@ func TestIntegration_Resolve_IncludeMethodsAndSchemas(t *testing.T) {
 		t.Error("registry:integration_test - expected schemas in resolve output")
 	}
 }
+
+func TestIntegration_Promote_EnvAvailability(t *testing.T) {
+	ctx, reg, cleanup := setupRegistry(t)
+	defer cleanup()
+
+	// Unique name so availability from earlier runs does not leak into this one
+	name := fmt.Sprintf("promote.cap%d", time.Now().UnixNano())
+	capRef := "intg." + name
+	_, err := reg.Upsert(ctx, &UpsertInput{
+		App: "intg", Name: name,
+		Version:      VersionInput{Major: 1, Minor: 0, Patch: 0},
+		Methods:      []MethodDefinition{{Name: "run", Modes: []string{"sync"}}},
+		SetAsDefault: true,
+		Env:          "staging",
+	}, testUserID)
+	if err != nil {
+		t.Fatalf("%s - Upsert failed: %v", regIntegrationPrefix, err)
+	}
+
+	if _, err := reg.Resolve(ctx, &ResolveInput{Cap: capRef, Ver: "1.0.0", Ctx: &ResolutionContext{Env: "staging"}}); err != nil {
+		t.Fatalf("%s - Resolve in staging failed: %v", regIntegrationPrefix, err)
+	}
+	_, err = reg.Resolve(ctx, &ResolveInput{Cap: capRef, Ver: "1.0.0", Ctx: &ResolutionContext{Env: "production"}})
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "NOT_FOUND" {
+		t.Fatalf("%s - expected NOT_FOUND in production before promotion, got %v", regIntegrationPrefix, err)
+	}
+
+	out, err := reg.Promote(ctx, &PromoteInput{Cap: capRef, Version: "1.0.0", FromEnv: "staging", ToEnv: "production", SetDefault: true}, testUserID)
+	if err != nil {
+		t.Fatalf("%s - Promote failed: %v", regIntegrationPrefix, err)
+	}
+	if !out.DefaultSet || out.PromotedBy != testUserID {
+		t.Errorf("%s - unexpected promote output: %+v", regIntegrationPrefix, out)
+	}
+
+	res, err := reg.Resolve(ctx, &ResolveInput{Cap: capRef, Ctx: &ResolutionContext{Env: "production"}})
+	if err != nil {
+		t.Fatalf("%s - Resolve in production after promotion failed: %v", regIntegrationPrefix, err)
+	}
+	if res.ResolvedVersion != "1.0.0" {
+		t.Errorf("%s - ResolvedVersion = %q, want 1.0.0", regIntegrationPrefix, res.ResolvedVersion)
+	}
+
+	_, err = reg.Promote(ctx, &PromoteInput{Cap: capRef, Version: "1.0.0", FromEnv: "development", ToEnv: "production"}, testUserID)
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INVALID_ARGUMENT" {
+		t.Errorf("%s - expected INVALID_ARGUMENT promoting from an env without the version, got %v", regIntegrationPrefix, err)
+	}
+}
+
+func TestIntegration_SubjectTemplate_UsedByResolveAndBootstrap(t *testing.T) {
+	ctx, reg, cleanup := setupRegistry(t)
+	defer cleanup()
+
+	name := fmt.Sprintf("subject.cap%d", time.Now().UnixNano())
+	capRef := "intg." + name
+	up, err := reg.Upsert(ctx, &UpsertInput{
+		App: "intg", Name: name,
+		Version:         VersionInput{Major: 1, Minor: 2, Patch: 0},
+		Methods:         []MethodDefinition{{Name: "run", Modes: []string{"sync"}}},
+		SetAsDefault:    true,
+		SubjectTemplate: "{env}.{prefix}.{app}.{name}.v{major}",
+	}, testUserID)
+	if err != nil {
+		t.Fatalf("%s - Upsert failed: %v", regIntegrationPrefix, err)
+	}
+	want := "production.cap.intg." + strings.ReplaceAll(name, ".", "_") + ".v1"
+	if up.Subject != want {
+		t.Errorf("%s - upsert Subject = %q, want %q", regIntegrationPrefix, up.Subject, want)
+	}
+
+	res, err := reg.Resolve(ctx, &ResolveInput{Cap: capRef})
+	if err != nil {
+		t.Fatalf("%s - Resolve failed: %v", regIntegrationPrefix, err)
+	}
+	if res.Subject != want {
+		t.Errorf("%s - resolve Subject = %q, want %q", regIntegrationPrefix, res.Subject, want)
+	}
+
+	caps, err := reg.GetBootstrapCapabilities(ctx, nil, false, false)
+	if err != nil {
+		t.Fatalf("%s - GetBootstrapCapabilities failed: %v", regIntegrationPrefix, err)
+	}
+	if ro := caps[capRef]; ro == nil || ro.Subject != want {
+		t.Errorf("%s - bootstrap entry = %+v, want subject %q", regIntegrationPrefix, ro, want)
+	}
+
+	// A later upsert without a template keeps the stored one.
+	up, err = reg.Upsert(ctx, &UpsertInput{
+		App: "intg", Name: name,
+		Version: VersionInput{Major: 1, Minor: 3, Patch: 0},
+		Methods: []MethodDefinition{{Name: "run", Modes: []string{"sync"}}},
+	}, testUserID)
+	if err != nil {
+		t.Fatalf("%s - second Upsert failed: %v", regIntegrationPrefix, err)
+	}
+	if up.Subject != want {
+		t.Errorf("%s - second upsert Subject = %q, want %q", regIntegrationPrefix, up.Subject, want)
+	}
+}
+
+func TestIntegration_Endpoints_OrderedByRegion(t *testing.T) {
+	ctx, reg, cleanup := setupRegistry(t)
+	defer cleanup()
+
+	name := fmt.Sprintf("endpoint.cap%d", time.Now().UnixNano())
+	capRef := "intg." + name
+	_, err := reg.Upsert(ctx, &UpsertInput{
+		App: "intg", Name: name,
+		Version:      VersionInput{Major: 1, Minor: 0, Patch: 0},
+		Methods:      []MethodDefinition{{Name: "run", Modes: []string{"sync"}}},
+		SetAsDefault: true,
+		Endpoints: []EndpointInput{
+			{NatsUrl: "nats://us.example:4222", Region: "us-east"},
+			{NatsUrl: "nats://eu.example:4222", Region: "eu-west", Subject: "eu.{app}.{name}.v{major}"},
+		},
+	}, testUserID)
+	if err != nil {
+		t.Fatalf("%s - Upsert failed: %v", regIntegrationPrefix, err)
+	}
+
+	res, err := reg.Resolve(ctx, &ResolveInput{Cap: capRef, Ctx: &ResolutionContext{Region: "eu-west"}})
+	if err != nil {
+		t.Fatalf("%s - Resolve failed: %v", regIntegrationPrefix, err)
+	}
+	if res.NatsUrl != "nats://eu.example:4222" || res.Region != "eu-west" || !strings.HasPrefix(res.Subject, "eu.intg.") {
+		t.Errorf("%s - unexpected preferred endpoint: natsUrl=%s subject=%s region=%s", regIntegrationPrefix, res.NatsUrl, res.Subject, res.Region)
+	}
+	if len(res.Alternates) != 1 || res.Alternates[0].NatsUrl != "nats://us.example:4222" {
+		t.Errorf("%s - unexpected alternates: %+v", regIntegrationPrefix, res.Alternates)
+	}
+
+	caps, err := reg.GetBootstrapCapabilities(ctx, &ResolutionContext{Region: "us-east"}, false, false)
+	if err != nil {
+		t.Fatalf("%s - GetBootstrapCapabilities failed: %v", regIntegrationPrefix, err)
+	}
+	if ro := caps[capRef]; ro == nil || ro.NatsUrl != "nats://us.example:4222" || len(ro.Alternates) != 1 {
+		t.Errorf("%s - unexpected bootstrap entry: %+v", regIntegrationPrefix, ro)
+	}
+
+	// An empty list removes the endpoints; resolve falls back to the registry URL.
+	_, err = reg.Upsert(ctx, &UpsertInput{
+		App: "intg", Name: name,
+		Version:   VersionInput{Major: 1, Minor: 0, Patch: 0},
+		Methods:   []MethodDefinition{{Name: "run", Modes: []string{"sync"}}},
+		Endpoints: []EndpointInput{},
+	}, testUserID)
+	if err != nil {
+		t.Fatalf("%s - clearing Upsert failed: %v", regIntegrationPrefix, err)
+	}
+	res, err = reg.Resolve(ctx, &ResolveInput{Cap: capRef})
+	if err != nil {
+		t.Fatalf("%s - Resolve after clearing failed: %v", regIntegrationPrefix, err)
+	}
+	if res.NatsUrl != reg.localNatsUrl() || len(res.Alternates) != 0 {
+		t.Errorf("%s - expected registry URL without alternates, got %s %+v", regIntegrationPrefix, res.NatsUrl, res.Alternates)
+	}
+}
+
+func TestIntegration_Shadow_ReturnedFromResolve(t *testing.T) {
+	ctx, reg, cleanup := setupRegistry(t)
+	defer cleanup()
+
+	name := fmt.Sprintf("shadow.cap%d", time.Now().UnixNano())
+	capRef := "intg." + name
+	for _, major := range []int{1, 2} {
+		_, err := reg.Upsert(ctx, &UpsertInput{
+			App: "intg", Name: name,
+			Version:      VersionInput{Major: major, Minor: 0, Patch: 0},
+			Methods:      []MethodDefinition{{Name: "run", Modes: []string{"sync"}}},
+			SetAsDefault: major == 1,
+		}, testUserID)
+		if err != nil {
+			t.Fatalf("%s - Upsert v%d failed: %v", regIntegrationPrefix, major, err)
+		}
+	}
+
+	target := 2
+	if _, err := reg.SetShadow(ctx, &SetShadowInput{Cap: capRef, TargetMajor: &target, SampleRate: 0.25, Tenants: []string{"acme"}}, testUserID); err != nil {
+		t.Fatalf("%s - SetShadow failed: %v", regIntegrationPrefix, err)
+	}
+
+	out, err := reg.Resolve(ctx, &ResolveInput{Cap: capRef, Ctx: &ResolutionContext{TenantID: "acme"}})
+	if err != nil {
+		t.Fatalf("%s - Resolve failed: %v", regIntegrationPrefix, err)
+	}
+	if out.Major != 1 {
+		t.Errorf("%s - primary Major = %d, want 1", regIntegrationPrefix, out.Major)
+	}
+	if out.Shadow == nil {
+		t.Fatalf("%s - expected shadow block", regIntegrationPrefix)
+	}
+	if out.Shadow.Major != 2 || out.Shadow.SampleRate != 0.25 || out.Shadow.Subject != commsutil.BuildCapabilitySubject("intg", name, 2) {
+		t.Errorf("%s - unexpected shadow: %+v", regIntegrationPrefix, out.Shadow)
+	}
+
+	other, err := reg.Resolve(ctx, &ResolveInput{Cap: capRef, Ctx: &ResolutionContext{TenantID: "globex"}})
+	if err != nil {
+		t.Fatalf("%s - Resolve for other tenant failed: %v", regIntegrationPrefix, err)
+	}
+	if other.Shadow != nil {
+		t.Errorf("%s - shadow should not apply to tenant outside the filter", regIntegrationPrefix)
+	}
+
+	removed, err := reg.RemoveShadow(ctx, &ShadowInput{Cap: capRef})
+	if err != nil || !removed.Removed {
+		t.Fatalf("%s - RemoveShadow = %+v, %v", regIntegrationPrefix, removed, err)
+	}
+}
+
+func TestIntegration_CapabilityAlias_Renamed(t *testing.T) {
+	ctx, reg, cleanup := setupRegistry(t)
+	defer cleanup()
+
+	suffix := time.Now().UnixNano()
+	newName := fmt.Sprintf("renamed.cap%d", suffix)
+	oldRef := fmt.Sprintf("intgold.renamed.cap%d", suffix)
+	_, err := reg.Upsert(ctx, &UpsertInput{
+		App: "intg", Name: newName,
+		Version:      VersionInput{Major: 1, Minor: 2, Patch: 0},
+		Methods:      []MethodDefinition{{Name: "run", Modes: []string{"sync"}}},
+		SetAsDefault: true,
+	}, testUserID)
+	if err != nil {
+		t.Fatalf("%s - Upsert failed: %v", regIntegrationPrefix, err)
+	}
+
+	if _, err := reg.AddCapabilityAlias(ctx, &AddCapabilityAliasInput{Alias: oldRef, Target: "intg." + newName}, testUserID); err != nil {
+		t.Fatalf("%s - AddCapabilityAlias failed: %v", regIntegrationPrefix, err)
+	}
+
+	out, err := reg.Resolve(ctx, &ResolveInput{Cap: oldRef + "@1"})
+	if err != nil {
+		t.Fatalf("%s - Resolve via alias failed: %v", regIntegrationPrefix, err)
+	}
+	if out.Subject != commsutil.BuildCapabilitySubject("intg", newName, 1) {
+		t.Errorf("%s - Subject = %q, want the renamed capability's subject", regIntegrationPrefix, out.Subject)
+	}
+	if len(out.Warnings) != 1 || out.Warnings[0].Code != "renamed" {
+		t.Errorf("%s - expected renamed warning, got %+v", regIntegrationPrefix, out.Warnings)
+	}
+
+	desc, err := reg.Describe(ctx, &DescribeInput{Cap: oldRef})
+	if err != nil || len(desc.Warnings) != 1 {
+		t.Errorf("%s - Describe via alias = %+v, %v", regIntegrationPrefix, desc, err)
+	}
+	majors, err := reg.ListMajors(ctx, &ListMajorsInput{Cap: oldRef})
+	if err != nil || len(majors.Warnings) != 1 {
+		t.Errorf("%s - ListMajors via alias = %+v, %v", regIntegrationPrefix, majors, err)
+	}
+
+	removed, err := reg.RemoveCapabilityAlias(ctx, &RemoveCapabilityAliasInput{Alias: oldRef})
+	if err != nil || !removed.Removed {
+		t.Fatalf("%s - RemoveCapabilityAlias = %+v, %v", regIntegrationPrefix, removed, err)
+	}
+	_, err = reg.Resolve(ctx, &ResolveInput{Cap: oldRef})
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "NOT_FOUND" {
+		t.Errorf("%s - expected NOT_FOUND after removing alias, got %v", regIntegrationPrefix, err)
+	}
+}
+
+func TestIntegration_TenantCell_RoutesResolveAndPublishesMove(t *testing.T) {
+	ctx, reg, cleanup := setupRegistry(t)
+	defer cleanup()
+
+	var changed []*events.RegistryChangedEvent
+	reg.publisher = events.NewCallbackPublisher(func(_ context.Context, event *events.RegistryChangedEvent) error {
+		changed = append(changed, event)
+		return nil
+	})
+
+	suffix := time.Now().UnixNano()
+	cellA := fmt.Sprintf("cell-a-%d", suffix)
+	cellB := fmt.Sprintf("cell-b-%d", suffix)
+	tenant := fmt.Sprintf("tenant-%d", suffix)
+	if _, err := reg.SetCell(ctx, &SetCellInput{Name: cellA, NatsUrl: "nats://cell-a.example:4222", SubjectPrefix: "cella"}, testUserID); err != nil {
+		t.Fatalf("%s - SetCell a failed: %v", regIntegrationPrefix, err)
+	}
+	if _, err := reg.SetCell(ctx, &SetCellInput{Name: cellB, NatsUrl: "nats://cell-b.example:4222"}, testUserID); err != nil {
+		t.Fatalf("%s - SetCell b failed: %v", regIntegrationPrefix, err)
+	}
+
+	name := fmt.Sprintf("cell.cap%d", suffix)
+	capRef := "intg." + name
+	_, err := reg.Upsert(ctx, &UpsertInput{
+		App: "intg", Name: name,
+		Version:      VersionInput{Major: 1, Minor: 0, Patch: 0},
+		Methods:      []MethodDefinition{{Name: "run", Modes: []string{"sync"}}},
+		SetAsDefault: true,
+	}, testUserID)
+	if err != nil {
+		t.Fatalf("%s - Upsert failed: %v", regIntegrationPrefix, err)
+	}
+
+	assigned, err := reg.AssignTenantCell(ctx, &AssignTenantCellInput{TenantID: tenant, Cell: cellA}, testUserID)
+	if err != nil || !assigned.Moved {
+		t.Fatalf("%s - AssignTenantCell = %+v, %v", regIntegrationPrefix, assigned, err)
+	}
+	res, err := reg.Resolve(ctx, &ResolveInput{Cap: capRef, Ctx: &ResolutionContext{TenantID: tenant}})
+	if err != nil {
+		t.Fatalf("%s - Resolve failed: %v", regIntegrationPrefix, err)
+	}
+	if res.NatsUrl != "nats://cell-a.example:4222" || res.Cell != cellA || !strings.HasPrefix(res.Subject, "cella.intg.") {
+		t.Errorf("%s - unexpected cell resolve: natsUrl=%s cell=%s subject=%s", regIntegrationPrefix, res.NatsUrl, res.Cell, res.Subject)
+	}
+
+	changed = nil
+	moved, err := reg.AssignTenantCell(ctx, &AssignTenantCellInput{TenantID: tenant, Cell: cellB}, testUserID)
+	if err != nil || !moved.Moved || moved.PreviousCell != cellA {
+		t.Fatalf("%s - move = %+v, %v", regIntegrationPrefix, moved, err)
+	}
+	if len(changed) != 1 || !changed[0].Reconnect || changed[0].TenantID != tenant || changed[0].NatsUrl != "nats://cell-b.example:4222" {
+		t.Errorf("%s - expected one reconnect event for the move, got %+v", regIntegrationPrefix, changed)
+	}
+	if url, cell := reg.TenantNatsUrl(ctx, tenant); url != "nats://cell-b.example:4222" || cell != cellB {
+		t.Errorf("%s - TenantNatsUrl = %s %s", regIntegrationPrefix, url, cell)
+	}
+
+	if _, err := reg.RemoveCell(ctx, &RemoveCellInput{Name: cellB}); err == nil {
+		t.Errorf("%s - expected RemoveCell to refuse a cell with tenants", regIntegrationPrefix)
+	}
+	if out, err := reg.RemoveTenantCell(ctx, &RemoveTenantCellInput{TenantID: tenant}); err != nil || out.PreviousCell != cellB {
+		t.Fatalf("%s - RemoveTenantCell = %+v, %v", regIntegrationPrefix, out, err)
+	}
+	for _, c := range []string{cellA, cellB} {
+		if out, err := reg.RemoveCell(ctx, &RemoveCellInput{Name: c}); err != nil || !out.Removed {
+			t.Errorf("%s - RemoveCell %s = %+v, %v", regIntegrationPrefix, c, out, err)
+		}
+	}
+}
+
+func TestIntegration_Instances_LivenessPolicyAndTransitions(t *testing.T) {
+	ctx, reg, cleanup := setupRegistry(t)
+	defer cleanup()
+
+	var changed []*events.RegistryChangedEvent
+	reg.publisher = events.NewCallbackPublisher(func(_ context.Context, event *events.RegistryChangedEvent) error {
+		changed = append(changed, event)
+		return nil
+	})
+
+	name := fmt.Sprintf("instance.cap%d", time.Now().UnixNano())
+	capRef := "intg." + name
+	for _, minor := range []int{0, 1} {
+		_, err := reg.Upsert(ctx, &UpsertInput{
+			App: "intg", Name: name,
+			Version:        VersionInput{Major: 1, Minor: minor, Patch: 0},
+			Methods:        []MethodDefinition{{Name: "run", Modes: []string{"sync"}}},
+			SetAsDefault:   true,
+			LivenessPolicy: "skip",
+		}, testUserID)
+		if err != nil {
+			t.Fatalf("%s - Upsert 1.%d.0 failed: %v", regIntegrationPrefix, minor, err)
+		}
+	}
+
+	// No live instances at all: skip policy fails resolve
+	if _, err := reg.Resolve(ctx, &ResolveInput{Cap: capRef}); err == nil {
+		t.Fatalf("%s - expected NOT_FOUND without live instances", regIntegrationPrefix)
+	}
+
+	changed = nil
+	lease, err := reg.RegisterInstance(ctx, &RegisterInstanceInput{Cap: capRef, Version: "1.0.0", TTLSeconds: 60}, testUserID)
+	if err != nil {
+		t.Fatalf("%s - RegisterInstance failed: %v", regIntegrationPrefix, err)
+	}
+	if lease.InstanceID == "" || lease.LiveInstances != 1 {
+		t.Errorf("%s - unexpected lease: %+v", regIntegrationPrefix, lease)
+	}
+	if len(changed) != 1 || changed[0].LiveInstances == nil || *changed[0].LiveInstances != 1 || changed[0].Version != "1.0.0" {
+		t.Errorf("%s - expected one instances event for 1.0.0, got %+v", regIntegrationPrefix, changed)
+	}
+
+	// 1.1.0 has no instances, so skip resolves to 1.0.0
+	res, err := reg.Resolve(ctx, &ResolveInput{Cap: capRef})
+	if err != nil {
+		t.Fatalf("%s - Resolve failed: %v", regIntegrationPrefix, err)
+	}
+	if res.ResolvedVersion != "1.0.0" {
+		t.Errorf("%s - ResolvedVersion = %s, want 1.0.0", regIntegrationPrefix, res.ResolvedVersion)
+	}
+
+	if _, err := reg.Heartbeat(ctx, &HeartbeatInput{InstanceID: lease.InstanceID, TTLSeconds: 60}); err != nil {
+		t.Errorf("%s - Heartbeat failed: %v", regIntegrationPrefix, err)
+	}
+	desc, err := reg.Describe(ctx, &DescribeInput{Cap: capRef, Version: "1.0.0"})
+	if err != nil || desc.Instances != 1 || desc.LivenessPolicy != "skip" {
+		t.Errorf("%s - Describe = %+v, %v", regIntegrationPrefix, desc, err)
+	}
+	disc, err := reg.Discover(ctx, &DiscoverInput{App: "intg", Query: name})
+	if err != nil || len(disc.Capabilities) != 1 || disc.Capabilities[0].Instances != 1 {
+		t.Errorf("%s - Discover = %+v, %v", regIntegrationPrefix, disc, err)
+	}
+
+	// An expired lease is reaped and the version's last-instance transition is published
+	short, err := reg.RegisterInstance(ctx, &RegisterInstanceInput{Cap: capRef, Version: "1.1.0", TTLSeconds: 1}, testUserID)
+	if err != nil {
+		t.Fatalf("%s - RegisterInstance 1.1.0 failed: %v", regIntegrationPrefix, err)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	changed = nil
+	if n, err := reg.ReapExpiredInstances(ctx); err != nil || n < 1 {
+		t.Errorf("%s - ReapExpiredInstances = %d, %v", regIntegrationPrefix, n, err)
+	}
+	found := false
+	for _, e := range changed {
+		if e.Capability == name && e.Version == "1.1.0" && e.LiveInstances != nil && *e.LiveInstances == 0 {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("%s - expected reaped transition for 1.1.0, got %+v", regIntegrationPrefix, changed)
+	}
+	if _, err := reg.Heartbeat(ctx, &HeartbeatInput{InstanceID: short.InstanceID}); err == nil {
+		t.Errorf("%s - expected heartbeat on a reaped lease to fail", regIntegrationPrefix)
+	}
+
+	// Warn policy keeps the default version and adds a warning
+	_, err = reg.Upsert(ctx, &UpsertInput{
+		App: "intg", Name: name,
+		Version:        VersionInput{Major: 1, Minor: 1, Patch: 0},
+		Methods:        []MethodDefinition{{Name: "run", Modes: []string{"sync"}}},
+		LivenessPolicy: "warn",
+	}, testUserID)
+	if err != nil {
+		t.Fatalf("%s - Upsert warn policy failed: %v", regIntegrationPrefix, err)
+	}
+	res, err = reg.Resolve(ctx, &ResolveInput{Cap: capRef})
+	if err != nil {
+		t.Fatalf("%s - Resolve with warn policy failed: %v", regIntegrationPrefix, err)
+	}
+	if res.ResolvedVersion != "1.1.0" || len(res.Warnings) != 1 || res.Warnings[0].Code != "no_live_instances" {
+		t.Errorf("%s - expected 1.1.0 with no_live_instances warning, got %s %+v", regIntegrationPrefix, res.ResolvedVersion, res.Warnings)
+	}
+
+	out, err := reg.DeregisterInstance(ctx, &DeregisterInstanceInput{InstanceID: lease.InstanceID})
+	if err != nil || !out.Removed || out.LiveInstances != 0 {
+		t.Errorf("%s - DeregisterInstance = %+v, %v", regIntegrationPrefix, out, err)
+	}
+}
+
+func TestIntegration_Probe_RecordsReachability(t *testing.T) {
+	ctx, reg, cleanup := setupRegistry(t)
+	defer cleanup()
+	nc := startProbeServer(t)
+	reg.nc = nc
+
+	name := fmt.Sprintf("probe.cap%d", time.Now().UnixNano())
+	capRef := "intg." + name
+	for _, major := range []int{1, 2} {
+		_, err := reg.Upsert(ctx, &UpsertInput{
+			App: "intg", Name: name,
+			Version: VersionInput{Major: major, Minor: 0, Patch: 0},
+			Methods: []MethodDefinition{{Name: "run", Modes: []string{"sync"}}},
+		}, testUserID)
+		if err != nil {
+			t.Fatalf("%s - Upsert %d.0.0 failed: %v", regIntegrationPrefix, major, err)
+		}
+	}
+
+	// Only major 1 has a responder
+	subject := reg.buildSubject("", commsutil.SubjectParams{App: "intg", Name: name, Major: 1, Env: reg.config.DefaultEnv})
+	sub, err := nc.Subscribe(subject, func(msg *comms.Msg) { _ = msg.Respond([]byte(`{"ok":true}`)) })
+	if err != nil {
+		t.Fatalf("%s - subscribe failed: %v", regIntegrationPrefix, err)
+	}
+
+	out, err := reg.Probe(ctx, &ProbeInput{Cap: capRef})
+	if err != nil {
+		t.Fatalf("%s - Probe failed: %v", regIntegrationPrefix, err)
+	}
+	statuses := map[string]string{}
+	for _, v := range out.Versions {
+		statuses[v.Version] = v.Availability.Status
+	}
+	if statuses["1.0.0"] != "reachable" || statuses["2.0.0"] != "unreachable" {
+		t.Fatalf("%s - unexpected probe statuses: %v", regIntegrationPrefix, statuses)
+	}
+
+	desc, err := reg.Describe(ctx, &DescribeInput{Cap: capRef, Version: "2.0.0"})
+	if err != nil {
+		t.Fatalf("%s - Describe failed: %v", regIntegrationPrefix, err)
+	}
+	if desc.Availability == nil || desc.Availability.Reachable || desc.Availability.Status != "unreachable" {
+		t.Errorf("%s - expected 2.0.0 unreachable in describe, got %+v", regIntegrationPrefix, desc.Availability)
+	}
+
+	disc, err := reg.Discover(ctx, &DiscoverInput{Query: name})
+	if err != nil {
+		t.Fatalf("%s - Discover failed: %v", regIntegrationPrefix, err)
+	}
+	if len(disc.Capabilities) != 1 || len(disc.Capabilities[0].UnreachableVersions) != 1 || disc.Capabilities[0].UnreachableVersions[0] != "2.0.0" {
+		t.Errorf("%s - expected unreachableVersions [2.0.0], got %+v", regIntegrationPrefix, disc.Capabilities)
+	}
+
+	// The responder goes away: 1.0.0 becomes unreachable but keeps its lastSeen
+	_ = sub.Unsubscribe()
+	out, err = reg.Probe(ctx, &ProbeInput{Cap: capRef, Version: "1.0.0"})
+	if err != nil {
+		t.Fatalf("%s - Probe 1.0.0 failed: %v", regIntegrationPrefix, err)
+	}
+	if len(out.Versions) != 1 || out.Versions[0].Availability.Status != "unreachable" || out.Versions[0].Availability.LastSeen == "" {
+		t.Errorf("%s - expected 1.0.0 unreachable with lastSeen, got %+v", regIntegrationPrefix, out.Versions)
+	}
+
+	health := reg.Health(ctx)
+	if health.Availability == nil || health.Availability.Unreachable < 2 {
+		t.Errorf("%s - expected health availability to count unreachable versions, got %+v", regIntegrationPrefix, health.Availability)
+	}
+}
+
+func TestIntegration_MicroImport_UpsertsServicesAsImported(t *testing.T) {
+	ctx, reg, cleanup := setupRegistry(t)
+	defer cleanup()
+	nc := startProbeServer(t)
+	reg.nc = nc
+	reg.config.ProbeTimeout = 200 * time.Millisecond
+
+	suffix := time.Now().UnixNano()
+	name := fmt.Sprintf("micro%d", suffix)
+	svc, err := micro.AddService(nc, micro.Config{Name: commsutil.MicroServiceName("intg", name), Version: "1.3.0", Description: "imported worker"})
+	if err != nil {
+		t.Fatalf("%s - AddService failed: %v", regIntegrationPrefix, err)
+	}
+	defer svc.Stop()
+	handler := micro.HandlerFunc(func(req micro.Request) { _ = req.Respond(nil) })
+	for _, ep := range []string{"run", "status"} {
+		if err := svc.AddEndpoint(ep, handler); err != nil {
+			t.Fatalf("%s - AddEndpoint %s failed: %v", regIntegrationPrefix, ep, err)
+		}
+	}
+
+	out, err := reg.ImportMicroServices(ctx)
+	if err != nil {
+		t.Fatalf("%s - ImportMicroServices failed: %v", regIntegrationPrefix, err)
+	}
+	ref := fmt.Sprintf("intg.%s@1.3.0", name)
+	found := false
+	for _, imported := range out.Imported {
+		found = found || imported == ref
+	}
+	if !found {
+		t.Fatalf("%s - expected %s imported, got %+v", regIntegrationPrefix, ref, out)
+	}
+
+	desc, err := reg.Describe(ctx, &DescribeInput{Cap: "intg." + name})
+	if err != nil {
+		t.Fatalf("%s - Describe failed: %v", regIntegrationPrefix, err)
+	}
+	if desc.Source != db.CapabilitySourceMicro || desc.Version != "1.3.0" || len(desc.Methods) != 2 {
+		t.Errorf("%s - unexpected imported capability: %+v", regIntegrationPrefix, desc)
+	}
+
+	// A second round leaves the unchanged version alone
+	out, err = reg.ImportMicroServices(ctx)
+	if err != nil {
+		t.Fatalf("%s - second ImportMicroServices failed: %v", regIntegrationPrefix, err)
+	}
+	for _, imported := range out.Imported {
+		if imported == ref {
+			t.Errorf("%s - expected %s unchanged on the second round", regIntegrationPrefix, ref)
+		}
+	}
+
+	// A direct upsert takes the capability over; imports then skip it
+	if _, err := reg.Upsert(ctx, &UpsertInput{
+		App: "intg", Name: name,
+		Version: VersionInput{Major: 1, Minor: 4, Patch: 0},
+		Methods: []MethodDefinition{{Name: "run", Modes: []string{"sync"}}},
+	}, testUserID); err != nil {
+		t.Fatalf("%s - Upsert failed: %v", regIntegrationPrefix, err)
+	}
+	disc, err := reg.Discover(ctx, &DiscoverInput{Query: name})
+	if err != nil {
+		t.Fatalf("%s - Discover failed: %v", regIntegrationPrefix, err)
+	}
+	if len(disc.Capabilities) != 1 || disc.Capabilities[0].Source != db.CapabilitySourceRegistry {
+		t.Errorf("%s - expected source registry after upsert, got %+v", regIntegrationPrefix, disc.Capabilities)
+	}
+	out, err = reg.ImportMicroServices(ctx)
+	if err != nil {
+		t.Fatalf("%s - third ImportMicroServices failed: %v", regIntegrationPrefix, err)
+	}
+	skipped := false
+	for _, s := range out.Skipped {
+		skipped = skipped || s.Service == commsutil.MicroServiceName("intg", name)
+	}
+	if !skipped {
+		t.Errorf("%s - expected the registered capability to be skipped, got %+v", regIntegrationPrefix, out)
+	}
+}
+
+func TestIntegration_Discover_AliasesMergesAndReportsAliasErrors(t *testing.T) {
+	ctx, reg, cleanup := setupRegistry(t)
+	defer cleanup()
+
+	name := fmt.Sprintf("fed%d", time.Now().UnixNano())
+	if _, err := reg.Upsert(ctx, &UpsertInput{
+		App: "intg", Name: name,
+		Version: VersionInput{Major: 1, Minor: 0, Patch: 0},
+		Methods: []MethodDefinition{{Name: "run", Modes: []string{"sync"}}},
+	}, testUserID); err != nil {
+		t.Fatalf("%s - Upsert failed: %v", regIntegrationPrefix, err)
+	}
+
+	out, err := reg.Discover(ctx, &DiscoverInput{Query: name, Aliases: []string{"main", "ghost", "@main"}})
+	if err != nil {
+		t.Fatalf("%s - Discover failed: %v", regIntegrationPrefix, err)
+	}
+	if len(out.Capabilities) != 1 || out.Capabilities[0].Alias != "main" || out.Capabilities[0].Cap != "intg."+name {
+		t.Errorf("%s - expected one local capability tagged main, got %+v", regIntegrationPrefix, out.Capabilities)
+	}
+	if len(out.AliasErrors) != 1 || out.AliasErrors[0].Alias != "ghost" || out.AliasErrors[0].Code != "UNKNOWN_ALIAS" {
+		t.Errorf("%s - expected UNKNOWN_ALIAS for ghost, got %+v", regIntegrationPrefix, out.AliasErrors)
+	}
+
+	// The default alias prefix describes locally
+	desc, err := reg.Describe(ctx, &DescribeInput{Cap: "@main/intg/" + name})
+	if err != nil {
+		t.Fatalf("%s - Describe failed: %v", regIntegrationPrefix, err)
+	}
+	if desc.Cap != "intg."+name {
+		t.Errorf("%s - Describe cap = %q", regIntegrationPrefix, desc.Cap)
+	}
+	if _, err := reg.ListMajors(ctx, &ListMajorsInput{Cap: "@ghost/intg/" + name}); err == nil {
+		t.Errorf("%s - expected ListMajors through an unknown alias to fail", regIntegrationPrefix)
+	}
+}
+
+func TestIntegration_Registries_ManageAliases(t *testing.T) {
+	ctx, reg, cleanup := setupRegistry(t)
+	defer cleanup()
+
+	alias := fmt.Sprintf("peer-%d", time.Now().UnixNano())
+	defer reg.RemoveRegistry(ctx, &RemoveRegistryInput{Alias: alias})
+
+	added, err := reg.AddRegistry(ctx, &AddRegistryInput{
+		Alias:   alias,
+		NatsUrl: "nats://127.0.0.1:1",
+		Config:  map[string]interface{}{"region": "eu"},
+	})
+	if err != nil {
+		t.Fatalf("%s - AddRegistry failed: %v", regIntegrationPrefix, err)
+	}
+	if added.RegistrySubject != "cap.system.registry.v1" || added.IsDefault || added.Config["region"] != "eu" {
+		t.Errorf("%s - unexpected alias: %+v", regIntegrationPrefix, added)
+	}
+	if _, err := reg.AddRegistry(ctx, &AddRegistryInput{Alias: alias, NatsUrl: "nats://127.0.0.1:1"}); err == nil {
+		t.Errorf("%s - expected ALREADY_EXISTS for a duplicate alias", regIntegrationPrefix)
+	} else if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "ALREADY_EXISTS" {
+		t.Errorf("%s - expected ALREADY_EXISTS, got %v", regIntegrationPrefix, err)
+	}
+
+	// Nothing listens on the alias's URL: the health check marks it unreachable
+	health, err := reg.CheckRegistries(ctx)
+	if err != nil {
+		t.Fatalf("%s - CheckRegistries failed: %v", regIntegrationPrefix, err)
+	}
+	found := false
+	for _, h := range health {
+		if h.Alias == alias {
+			found = true
+			if h.Status != "unreachable" || h.ConsecutiveFailures != 1 {
+				t.Errorf("%s - health = %+v", regIntegrationPrefix, h)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("%s - expected %s in health results", regIntegrationPrefix, alias)
+	}
+
+	newURL := "nats://127.0.0.1:2"
+	updated, err := reg.UpdateRegistry(ctx, &UpdateRegistryInput{Alias: alias, NatsUrl: &newURL})
+	if err != nil {
+		t.Fatalf("%s - UpdateRegistry failed: %v", regIntegrationPrefix, err)
+	}
+	if updated.NatsUrl != newURL || updated.Config["region"] != "eu" || updated.Health != nil {
+		t.Errorf("%s - update should change the URL, keep config and reset health: %+v", regIntegrationPrefix, updated)
+	}
+
+	list, err := reg.ListRegistries(ctx)
+	if err != nil {
+		t.Fatalf("%s - ListRegistries failed: %v", regIntegrationPrefix, err)
+	}
+	found = false
+	for _, r := range list.Registries {
+		found = found || r.Alias == alias
+	}
+	if !found {
+		t.Errorf("%s - expected %s in listRegistries", regIntegrationPrefix, alias)
+	}
+
+	removed, err := reg.RemoveRegistry(ctx, &RemoveRegistryInput{Alias: alias})
+	if err != nil || !removed.Removed {
+		t.Fatalf("%s - RemoveRegistry = %+v, %v", regIntegrationPrefix, removed, err)
+	}
+	if _, err := reg.UpdateRegistry(ctx, &UpdateRegistryInput{Alias: alias}); err == nil {
+		t.Errorf("%s - expected NOT_FOUND after removal", regIntegrationPrefix)
+	}
+}
+
+func TestIntegration_Mirror_ExportAndApplyCatalog(t *testing.T) {
+	ctx, reg, cleanup := setupRegistry(t)
+	defer cleanup()
+	reg.mirror = newMirrorState()
+
+	name := fmt.Sprintf("mirror%d", time.Now().UnixNano())
+	if _, err := reg.Upsert(ctx, &UpsertInput{
+		App: "intg", Name: name,
+		Version:      VersionInput{Major: 1, Minor: 2, Patch: 0},
+		Methods:      []MethodDefinition{{Name: "run", Modes: []string{"sync"}}},
+		Endpoints:    []EndpointInput{{NatsUrl: "nats://eu.example:4222", Region: "eu-west"}},
+		SetAsDefault: true,
+	}, testUserID); err != nil {
+		t.Fatalf("%s - Upsert failed: %v", regIntegrationPrefix, err)
+	}
+
+	exported, err := reg.ExportCatalog(ctx, &ExportCatalogInput{Cap: "intg." + name})
+	if err != nil {
+		t.Fatalf("%s - ExportCatalog failed: %v", regIntegrationPrefix, err)
+	}
+	if len(exported.Capabilities) != 1 || len(exported.Capabilities[0].Versions) != 1 || len(exported.Capabilities[0].Defaults) != 1 {
+		t.Fatalf("%s - unexpected export: %+v", regIntegrationPrefix, exported)
+	}
+
+	// The upstream's own capability is registered locally here, so it is not overwritten
+	if regErr := reg.applyCatalogCapability(ctx, exported.Capabilities[0]); regErr != nil {
+		t.Fatalf("%s - apply over a local capability failed: %v", regIntegrationPrefix, regErr)
+	}
+	desc, err := reg.Describe(ctx, &DescribeInput{Cap: "intg." + name})
+	if err != nil || desc.Source != db.CapabilitySourceRegistry {
+		t.Errorf("%s - the local capability must keep its source: %+v, %v", regIntegrationPrefix, desc, err)
+	}
+
+	copied := exported.Capabilities[0]
+	copied.Name = name + "-copy"
+	if regErr := reg.applyCatalogCapability(ctx, copied); regErr != nil {
+		t.Fatalf("%s - apply failed: %v", regIntegrationPrefix, regErr)
+	}
+	desc, err = reg.Describe(ctx, &DescribeInput{Cap: "intg." + copied.Name})
+	if err != nil {
+		t.Fatalf("%s - Describe of the copy failed: %v", regIntegrationPrefix, err)
+	}
+	if desc.Source != db.CapabilitySourceMirror || desc.Version != "1.2.0" || len(desc.Methods) != 1 {
+		t.Errorf("%s - unexpected mirrored capability: %+v", regIntegrationPrefix, desc)
+	}
+	res, err := reg.Resolve(ctx, &ResolveInput{Cap: "intg." + copied.Name})
+	if err != nil || res.ResolvedVersion != "1.2.0" {
+		t.Errorf("%s - the mirrored capability should resolve: %+v, %v", regIntegrationPrefix, res, err)
+	}
+
+	// The same upstream revision is not written again
+	before, _ := reg.repo.GetCapability(ctx, "intg", copied.Name)
+	if regErr := reg.applyCatalogCapability(ctx, copied); regErr != nil {
+		t.Fatalf("%s - second apply failed: %v", regIntegrationPrefix, regErr)
+	}
+	after, _ := reg.repo.GetCapability(ctx, "intg", copied.Name)
+	if before.Revision != after.Revision {
+		t.Errorf("%s - revision changed from %d to %d on an unchanged apply", regIntegrationPrefix, before.Revision, after.Revision)
+	}
+}
+
+func TestIntegration_Mirror_ExportShadowsAliasesAndReleases(t *testing.T) {
+	ctx, reg, cleanup := setupRegistry(t)
+	defer cleanup()
+
+	name := fmt.Sprintf("routing%d", time.Now().UnixNano())
+	if _, err := reg.Upsert(ctx, &UpsertInput{
+		App: "intg", Name: name,
+		Version:      VersionInput{Major: 1, Minor: 0, Patch: 0},
+		Methods:      []MethodDefinition{{Name: "run", Modes: []string{"sync"}}},
+		SetAsDefault: true,
+	}, testUserID); err != nil {
+		t.Fatalf("%s - Upsert failed: %v", regIntegrationPrefix, err)
+	}
+	if _, err := reg.SetShadow(ctx, &SetShadowInput{Cap: "intg." + name, TargetVersion: "1.0.0", SampleRate: 0.5}, testUserID); err != nil {
+		t.Fatalf("%s - SetShadow failed: %v", regIntegrationPrefix, err)
+	}
+	if _, err := reg.AddCapabilityAlias(ctx, &AddCapabilityAliasInput{Alias: "intg." + name + "-old", Target: "intg." + name}, testUserID); err != nil {
+		t.Fatalf("%s - AddCapabilityAlias failed: %v", regIntegrationPrefix, err)
+	}
+	if _, err := reg.CreateRelease(ctx, &CreateReleaseInput{Name: name, Pins: []ReleasePinInput{{Cap: "intg." + name, Version: "1.0.0"}}}, testUserID); err != nil {
+		t.Fatalf("%s - CreateRelease failed: %v", regIntegrationPrefix, err)
+	}
+
+	exported, err := reg.ExportCatalog(ctx, &ExportCatalogInput{Cap: "intg." + name})
+	if err != nil || len(exported.Capabilities) != 1 {
+		t.Fatalf("%s - ExportCatalog = %+v, %v", regIntegrationPrefix, exported, err)
+	}
+	c := exported.Capabilities[0]
+	if c.Shadow == nil || c.Shadow.TargetVersion != "1.0.0" || c.Shadow.SampleRate != 0.5 {
+		t.Errorf("%s - exported shadow = %+v", regIntegrationPrefix, c.Shadow)
+	}
+	if len(c.Aliases) != 1 || c.Aliases[0].Alias != "intg."+name+"-old" {
+		t.Errorf("%s - exported aliases = %+v", regIntegrationPrefix, c.Aliases)
+	}
+
+	routed, err := reg.ExportCatalog(ctx, &ExportCatalogInput{Routing: true})
+	if err != nil || routed.Routing == nil {
+		t.Fatalf("%s - routing export = %+v, %v", regIntegrationPrefix, routed, err)
+	}
+	found := false
+	for _, rel := range routed.Routing.Releases {
+		if rel.Name == name {
+			found = len(rel.Pins) == 1 && rel.Pins[0].Cap == "intg."+name && rel.Pins[0].Version == "1.0.0"
+		}
+	}
+	if !found {
+		t.Errorf("%s - expected release %s with its pin in the routing export", regIntegrationPrefix, name)
+	}
+}
+
+func TestIntegration_ChangesSince_SequencesAndPages(t *testing.T) {
+	ctx, reg, cleanup := setupRegistry(t)
+	defer cleanup()
+
+	start, err := reg.ChangesSince(ctx, &ChangesSinceInput{Limit: 1})
+	if err != nil {
+		t.Fatalf("%s - ChangesSince failed: %v", regIntegrationPrefix, err)
+	}
+
+	name := fmt.Sprintf("changes%d", time.Now().UnixNano())
+	for _, n := range []string{name + "-a", name + "-b"} {
+		if _, err := reg.Upsert(ctx, &UpsertInput{
+			App: "intg", Name: n,
+			Version: VersionInput{Major: 1, Minor: 0, Patch: 0},
+			Methods: []MethodDefinition{{Name: "run", Modes: []string{"sync"}}},
+		}, testUserID); err != nil {
+			t.Fatalf("%s - Upsert of %s failed: %v", regIntegrationPrefix, n, err)
+		}
+	}
+
+	out, err := reg.ChangesSince(ctx, &ChangesSinceInput{Since: start.Latest})
+	if err != nil {
+		t.Fatalf("%s - ChangesSince failed: %v", regIntegrationPrefix, err)
+	}
+	if len(out.Changes) < 2 || out.HasMore || out.Resync || out.Next != out.Latest {
+		t.Fatalf("%s - unexpected changes: %+v", regIntegrationPrefix, out)
+	}
+	for i, event := range out.Changes {
+		if event.Sequence != start.Latest+int64(i)+1 {
+			t.Errorf("%s - change %d has sequence %d, want %d", regIntegrationPrefix, i, event.Sequence, start.Latest+int64(i)+1)
+		}
+	}
+
+	page, err := reg.ChangesSince(ctx, &ChangesSinceInput{Since: start.Latest, Limit: 1})
+	if err != nil || len(page.Changes) != 1 || !page.HasMore || page.Next != start.Latest+1 {
+		t.Errorf("%s - first page: %+v, %v", regIntegrationPrefix, page, err)
+	}
+
+	ahead, err := reg.ChangesSince(ctx, &ChangesSinceInput{Since: out.Latest + 1000})
+	if err != nil || !ahead.Resync || len(ahead.Changes) != 0 || ahead.Next != out.Latest {
+		t.Errorf("%s - a sequence ahead of the feed must resync: %+v, %v", regIntegrationPrefix, ahead, err)
+	}
+}
+
+func TestIntegration_ChangeEvents_KindsActorAndValues(t *testing.T) {
+	ctx, reg, cleanup := setupRegistry(t)
+	defer cleanup()
+
+	var changed []*events.RegistryChangedEvent
+	reg.publisher = &changeRecorder{repo: reg.repo, next: events.NewCallbackPublisher(func(_ context.Context, event *events.RegistryChangedEvent) error {
+		changed = append(changed, event)
+		return nil
+	})}
+	ctx = WithActor(ctx, testUserID, "req-42")
+
+	name := fmt.Sprintf("events.cap%d", time.Now().UnixNano())
+	capRef := "intg." + name
+	for _, major := range []int{1, 1, 2} {
+		if _, err := reg.Upsert(ctx, &UpsertInput{
+			App: "intg", Name: name,
+			Version:      VersionInput{Major: major, Minor: 0, Patch: 0},
+			Methods:      []MethodDefinition{{Name: "run", Modes: []string{"sync"}}},
+			SetAsDefault: major == 1,
+		}, testUserID); err != nil {
+			t.Fatalf("%s - Upsert %d.0.0 failed: %v", regIntegrationPrefix, major, err)
+		}
+	}
+	if len(changed) != 3 || changed[0].Kind != events.KindVersionPublished || changed[1].Kind != events.KindVersionUpdated || changed[2].Kind != events.KindVersionPublished {
+		t.Fatalf("%s - expected published, updated, published events, got %+v", regIntegrationPrefix, changed)
+	}
+	if changed[0].Actor != testUserID || changed[0].RequestID != "req-42" || changed[0].Sequence == 0 || changed[0].AffectedVersions[0] != "1.0.0" {
+		t.Errorf("%s - unexpected publish event: %+v", regIntegrationPrefix, changed[0])
+	}
+
+	changed = nil
+	if _, err := reg.SetDefaultMajor(ctx, &SetDefaultMajorInput{Cap: capRef, Major: 2}, testUserID); err != nil {
+		t.Fatalf("%s - SetDefaultMajor failed: %v", regIntegrationPrefix, err)
+	}
+	if len(changed) != 1 || changed[0].Kind != events.KindDefaultChanged || changed[0].Env != reg.config.DefaultEnv ||
+		changed[0].Previous["defaultMajor"] != 1 || changed[0].New["defaultMajor"] != 2 {
+		t.Errorf("%s - unexpected defaultChanged event: %+v", regIntegrationPrefix, changed)
+	}
+
+	changed = nil
+	if _, err := reg.Deprecate(ctx, &DeprecateInput{Cap: capRef, Version: "1.0.0", Reason: "use 2.x"}, testUserID); err != nil {
+		t.Fatalf("%s - Deprecate failed: %v", regIntegrationPrefix, err)
+	}
+	if len(changed) != 1 || changed[0].Kind != events.KindDeprecated || len(changed[0].AffectedVersions) != 1 || changed[0].AffectedVersions[0] != "1.0.0" {
+		t.Fatalf("%s - unexpected deprecated event: %+v", regIntegrationPrefix, changed)
+	}
+	if previous, _ := changed[0].Previous["status"].(map[string]string); previous["1.0.0"] != "active" {
+		t.Errorf("%s - previous status = %v, want active", regIntegrationPrefix, changed[0].Previous)
+	}
+
+	// The recorded change keeps the new fields for changesSince and webhooks
+	out, err := reg.ChangesSince(ctx, &ChangesSinceInput{Since: changed[0].Sequence - 1, Limit: 1})
+	if err != nil || len(out.Changes) != 1 || out.Changes[0].Kind != events.KindDeprecated || out.Changes[0].RequestID != "req-42" {
+		t.Errorf("%s - recorded change = %+v, %v", regIntegrationPrefix, out, err)
+	}
+}
+
+func TestIntegration_ResolutionKV_MaterializesDefaults(t *testing.T) {
+	ctx, reg, cleanup := setupRegistry(t)
+	defer cleanup()
+
+	ns, err := commsserver.NewServer(&commsserver.Options{Host: "127.0.0.1", Port: -1, NoLog: true, NoSigs: true, JetStream: true, StoreDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("%s - failed to create NATS server: %v", regIntegrationPrefix, err)
+	}
+	go ns.Start()
+	defer ns.Shutdown()
+	if !ns.ReadyForConnections(10 * time.Second) {
+		t.Fatalf("%s - NATS server failed to start", regIntegrationPrefix)
+	}
+	nc, err := comms.Connect(ns.ClientURL())
+	if err != nil {
+		t.Fatalf("%s - failed to connect: %v", regIntegrationPrefix, err)
+	}
+	defer nc.Close()
+	reg.nc = nc
+
+	// A key left from an earlier run is removed by the startup reconcile
+	js, _ := nc.JetStream()
+	bucket, err := js.CreateKeyValue(&comms.KeyValueConfig{Bucket: "resolution"})
+	if err != nil {
+		t.Fatalf("%s - CreateKeyValue failed: %v", regIntegrationPrefix, err)
+	}
+	if _, err := bucket.Put("production.intg.gone", []byte("{}")); err != nil {
+		t.Fatalf("%s - Put failed: %v", regIntegrationPrefix, err)
+	}
+	if err := reg.EnableResolutionKV(ctx, "resolution"); err != nil {
+		t.Fatalf("%s - EnableResolutionKV failed: %v", regIntegrationPrefix, err)
+	}
+	if _, err := bucket.Get("production.intg.gone"); err != comms.ErrKeyNotFound {
+		t.Errorf("%s - the stale key should be deleted, got %v", regIntegrationPrefix, err)
+	}
+
+	name := fmt.Sprintf("kv%d", time.Now().UnixNano())
+	resolved := func() *ResolveOutput {
+		t.Helper()
+		entry, err := bucket.Get("production.intg." + name)
+		if err != nil {
+			t.Fatalf("%s - Get failed: %v", regIntegrationPrefix, err)
+		}
+		var out ResolveOutput
+		if err := json.Unmarshal(entry.Value(), &out); err != nil {
+			t.Fatalf("%s - invalid value: %v", regIntegrationPrefix, err)
+		}
+		return &out
+	}
+	for _, minor := range []int{0, 1} {
+		if _, err := reg.Upsert(ctx, &UpsertInput{
+			App: "intg", Name: name,
+			Version:      VersionInput{Major: 1, Minor: minor, Patch: 0},
+			Methods:      []MethodDefinition{{Name: "run", Modes: []string{"sync"}}},
+			SetAsDefault: true,
+		}, testUserID); err != nil {
+			t.Fatalf("%s - Upsert failed: %v", regIntegrationPrefix, err)
+		}
+		if out := resolved(); out.ResolvedVersion != fmt.Sprintf("1.%d.0", minor) || out.Subject == "" || len(out.Methods) != 1 {
+			t.Errorf("%s - after upserting 1.%d.0: %+v", regIntegrationPrefix, minor, out)
+		}
+	}
+}
+
+func TestIntegration_Webhooks_RetryDeliverAndDisable(t *testing.T) {
+	ctx, reg, cleanup := setupRegistry(t)
+	defer cleanup()
+	// The test receivers listen on loopback
+	reg.config.WebhookAllowPrivate = true
+
+	var mu sync.Mutex
+	var requests []*http.Request
+	var bodies [][]byte
+	failFirst := true
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ := io.ReadAll(req.Body)
+		requests, bodies = append(requests, req), append(bodies, body)
+		if failFirst {
+			failFirst = false
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	name := fmt.Sprintf("hook%d", time.Now().UnixNano())
+	hook, err := reg.SetWebhook(ctx, &SetWebhookInput{Name: name, URL: receiver.URL, App: "intg", Capability: name}, testUserID)
+	if err != nil || hook.Secret == "" || !hook.Enabled {
+		t.Fatalf("%s - SetWebhook: %+v, %v", regIntegrationPrefix, hook, err)
+	}
+	if _, err := reg.SetWebhook(ctx, &SetWebhookInput{Name: name + "-down", URL: failing.URL, App: "intg", Capability: name}, testUserID); err != nil {
+		t.Fatalf("%s - SetWebhook failed: %v", regIntegrationPrefix, err)
+	}
+	defer reg.RemoveWebhook(ctx, &RemoveWebhookInput{Name: name})
+	defer reg.RemoveWebhook(ctx, &RemoveWebhookInput{Name: name + "-down"})
+
+	d := newWebhookDispatcher(reg, WebhookOptions{MaxAttempts: 2, DisableAfter: 1})
+	d.retryBase = 0
+	if _, err := reg.Upsert(ctx, &UpsertInput{
+		App: "intg", Name: name,
+		Version: VersionInput{Major: 1, Minor: 0, Patch: 0},
+		Methods: []MethodDefinition{{Name: "run", Modes: []string{"sync"}}},
+	}, testUserID); err != nil {
+		t.Fatalf("%s - Upsert failed: %v", regIntegrationPrefix, err)
+	}
+	// The first attempts fail, the retries are due at once
+	d.dispatch(ctx)
+	d.dispatch(ctx)
+
+	delivered, err := reg.ListWebhookDeliveries(ctx, &ListWebhookDeliveriesInput{Name: name})
+	if err != nil || len(delivered.Deliveries) != 1 {
+		t.Fatalf("%s - ListWebhookDeliveries: %+v, %v", regIntegrationPrefix, delivered, err)
+	}
+	if got := delivered.Deliveries[0]; got.Status != "succeeded" || got.Attempts != 2 || got.StatusCode != http.StatusOK || got.Sequence == 0 {
+		t.Errorf("%s - unexpected delivery: %+v", regIntegrationPrefix, got)
+	}
+	mu.Lock()
+	if len(requests) != 2 {
+		t.Fatalf("%s - receiver got %d requests, want 2", regIntegrationPrefix, len(requests))
+	}
+	last := requests[1]
+	if last.Header.Get(WebhookSignatureHeader) != SignWebhookPayload(hook.Secret, last.Header.Get(WebhookTimestampHeader), bodies[1]) {
+		t.Errorf("%s - the signature does not verify with the webhook secret", regIntegrationPrefix)
+	}
+	mu.Unlock()
+
+	// The other webhook ran out of attempts and was disabled
+	list, err := reg.ListWebhooks(ctx)
+	if err != nil {
+		t.Fatalf("%s - ListWebhooks failed: %v", regIntegrationPrefix, err)
+	}
+	for _, w := range list.Webhooks {
+		if w.Name == name+"-down" && (w.Enabled || w.ConsecutiveFailures != 1 || w.DisabledReason == "" || w.Secret != "") {
+			t.Errorf("%s - expected a disabled webhook: %+v", regIntegrationPrefix, w)
+		}
+	}
+	down, err := reg.ListWebhookDeliveries(ctx, &ListWebhookDeliveriesInput{Name: name + "-down"})
+	if err != nil || len(down.Deliveries) != 1 || down.Deliveries[0].Status != "failed" || down.Deliveries[0].StatusCode != http.StatusInternalServerError {
+		t.Errorf("%s - failed delivery log: %+v, %v", regIntegrationPrefix, down, err)
+	}
+	again, err := reg.SetWebhook(ctx, &SetWebhookInput{Name: name + "-down", URL: failing.URL}, testUserID)
+	if err != nil || !again.Enabled || again.ConsecutiveFailures != 0 || again.Secret != "" {
+		t.Errorf("%s - setWebhook must re-enable and keep the secret: %+v, %v", regIntegrationPrefix, again, err)
+	}
+}
+
+func TestIntegration_VerifyLock_UnresolvableVersionIsAnIssue(t *testing.T) {
+	ctx, reg, cleanup := setupRegistry(t)
+	defer cleanup()
+
+	name := fmt.Sprintf("lock.skip%d", time.Now().UnixNano())
+	_, err := reg.Upsert(ctx, &UpsertInput{
+		App: "intg", Name: name,
+		Version:        VersionInput{Major: 1, Minor: 0, Patch: 0},
+		Methods:        []MethodDefinition{{Name: "run", Modes: []string{"sync"}}},
+		SetAsDefault:   true,
+		LivenessPolicy: "skip",
+	}, testUserID)
+	if err != nil {
+		t.Fatalf("%s - Upsert failed: %v", regIntegrationPrefix, err)
+	}
+
+	// No live instances: the skip policy makes the locked version unresolvable
+	out, err := reg.VerifyLock(ctx, &VerifyLockInput{Lock: Lockfile{LockfileVersion: 1, Entries: []LockEntry{
+		{Cap: "intg." + name, Version: "1.0.0", Digest: "sha256:locked"},
+		{Cap: "intg." + name + ".gone", Version: "1.0.0"},
+	}}})
+	if err != nil {
+		t.Fatalf("%s - VerifyLock failed: %v", regIntegrationPrefix, err)
+	}
+	problems := map[string]bool{}
+	for _, issue := range out.Issues {
+		problems[issue.Problem] = true
+	}
+	if out.Valid || out.Checked != 2 || !problems["unavailable"] || !problems["missing"] {
+		t.Errorf("%s - expected unavailable and missing issues, got %+v", regIntegrationPrefix, out)
+	}
+}
+
+func TestIntegration_CreateRelease_ConcurrentNameIsAlreadyExists(t *testing.T) {
+	ctx, reg, cleanup := setupRegistry(t)
+	defer cleanup()
+
+	name := fmt.Sprintf("rel.race%d", time.Now().UnixNano())
+	if _, err := reg.Upsert(ctx, &UpsertInput{
+		App: "intg", Name: name,
+		Version:      VersionInput{Major: 1, Minor: 0, Patch: 0},
+		Methods:      []MethodDefinition{{Name: "run", Modes: []string{"sync"}}},
+		SetAsDefault: true,
+	}, testUserID); err != nil {
+		t.Fatalf("%s - Upsert failed: %v", regIntegrationPrefix, err)
+	}
+
+	const creators = 5
+	release := "race-" + name
+	errs := make(chan error, creators)
+	for i := 0; i < creators; i++ {
+		go func() {
+			_, err := reg.CreateRelease(ctx, &CreateReleaseInput{
+				Name: release,
+				Pins: []ReleasePinInput{{Cap: "intg." + name, Version: "1.0.0"}},
+			}, testUserID)
+			errs <- err
+		}()
+	}
+	created := 0
+	for i := 0; i < creators; i++ {
+		err := <-errs
+		if err == nil {
+			created++
+			continue
+		}
+		if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "ALREADY_EXISTS" {
+			t.Errorf("%s - expected ALREADY_EXISTS for a losing create, got %v", regIntegrationPrefix, err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("%s - %d creates succeeded, want 1", regIntegrationPrefix, created)
+	}
+}
+
+func TestIntegration_ChangesSince_GapMarkerForcesResync(t *testing.T) {
+	ctx, reg, cleanup := setupRegistry(t)
+	defer cleanup()
+
+	before, err := reg.ChangesSince(ctx, &ChangesSinceInput{Since: 0, Limit: 1})
+	if err != nil {
+		t.Fatalf("%s - ChangesSince failed: %v", regIntegrationPrefix, err)
+	}
+
+	// A change failed to record: the next feed read records the gap marker
+	reg.recorder.mu.Lock()
+	reg.recorder.gap = true
+	reg.recorder.mu.Unlock()
+	out, err := reg.ChangesSince(ctx, &ChangesSinceInput{Since: before.Latest})
+	if err != nil {
+		t.Fatalf("%s - ChangesSince failed: %v", regIntegrationPrefix, err)
+	}
+	if !out.Resync || len(out.Changes) != 0 || out.Latest != before.Latest+1 || out.Next != out.Latest {
+		t.Errorf("%s - a reader before the gap must resync: %+v", regIntegrationPrefix, out)
+	}
+
+	after, err := reg.ChangesSince(ctx, &ChangesSinceInput{Since: out.Next})
+	if err != nil || after.Resync || len(after.Changes) != 0 {
+		t.Errorf("%s - a reader after the gap continues normally: %+v, %v", regIntegrationPrefix, after, err)
+	}
+}
@@ -15,29 +15,31 @@ const listMajorsLogPrefix = "registry:listMajors"
 func (r *Registry) ListMajors(ctx context.Context, input *ListMajorsInput) (*ListMajorsOutput, error) {
 	slog.Info(fmt.Sprintf("%s - cap=%s", listMajorsLogPrefix, input.Cap))
 
-	if err := r.requireRepo(); err != nil {
+	if err := r.requireCatalog(); err != nil {
 		return nil, err
 	}
 
-	parsed, err := semver.ParseCapabilityRef(input.Cap)
-	if err != nil {
-		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: err.Error()}
+	// "@alias/app/name" lists the majors of a capability of another registry
+	if alias, capRef := extractAlias(input.Cap); alias != "" {
+		if alias != r.defaultAlias() {
+			return r.listMajorsRemote(ctx, input, alias, capRef)
+		}
+		local := *input
+		local.Cap = dottedCapRef(capRef)
+		input = &local
 	}
 
-	cap, err := r.repo.GetCapability(ctx, parsed.App, parsed.Name)
-	if err != nil {
-		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
-	}
-	if cap == nil {
-		return nil, &RegistryError{Code: "NOT_FOUND", Message: fmt.Sprintf("Capability not found: %s", parsed.Full)}
+	_, cap, warnings, regErr := r.lookupCapabilityFollowingAliases(ctx, input.Cap)
+	if regErr != nil {
+		return nil, regErr
 	}
 
-	versions, err := r.repo.GetVersions(ctx, cap.ID)
+	versions, err := r.catalog.GetVersions(ctx, cap.ID)
 	if err != nil {
 		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
 	}
 
-	defaultEntry, _ := r.repo.GetDefault(ctx, cap.ID, r.config.DefaultEnv)
+	defaultEntry, _ := r.catalog.GetDefault(ctx, cap.ID, r.config.DefaultEnv)
 
 	// Group by major
 	type majorGroup struct {
@@ -100,5 +102,17 @@ func (r *Registry) ListMajors(ctx context.Context, input *ListMajorsInput) (*Lis
 		return majors[i].Major > majors[j].Major
 	})
 
-	return &ListMajorsOutput{Majors: majors}, nil
+	return &ListMajorsOutput{Majors: majors, Warnings: warnings}, nil
+}
+
+// listMajorsRemote lists majors through the federation pool.
+func (r *Registry) listMajorsRemote(ctx context.Context, input *ListMajorsInput, alias string, capRef string) (*ListMajorsOutput, error) {
+	slog.Info(fmt.Sprintf("%s - Federated listMajors alias=%s cap=%s", listMajorsLogPrefix, alias, capRef))
+
+	if r.federationPool == nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: "Federation pool not initialized"}
+	}
+	remote := *input
+	remote.Cap = capRef
+	return r.federationPool.ListMajors(ctx, alias, &remote)
 }
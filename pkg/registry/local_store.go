@@ -0,0 +1,843 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/morezero/capabilities-registry/pkg/db"
+	"github.com/morezero/capabilities-registry/pkg/semver"
+)
+
+const (
+	localStoreLogPrefix = "registry:localStore"
+	// localIDPrefix starts the IDs of LocalStore rows: "local:<app>.<name>" for a capability,
+	// "<capability ID>@<version>" for a version, "local:cell:<name>" for a cell and
+	// "local:release:<name>" for a release.
+	localIDPrefix        = "local:"
+	localCellIDPrefix    = localIDPrefix + "cell:"
+	localReleaseIDPrefix = localIDPrefix + "release:"
+)
+
+// LocalStore is a CatalogStore that keeps a mirror's catalog in memory and, when it has a
+// path, in a JSON file, so an edge mirror can run without Postgres and serve its last copy
+// after a restart while the upstream is unreachable. Capabilities, versions and defaults
+// are stored as exported by the upstream, as are cells, tenant cells and releases; the rows
+// read from the store are derived from them.
+type LocalStore struct {
+	path    string
+	mu      sync.RWMutex
+	caps    map[string]*localCapability
+	routing localRouting
+	// dirty is set when capabilities were applied since the last Flush.
+	dirty bool
+	now   func() time.Time
+}
+
+// localCapability is one mirrored capability in a LocalStore.
+type localCapability struct {
+	Catalog CatalogCapability `json:"catalog"`
+	// Revision is the local revision, incremented by every apply.
+	Revision int       `json:"revision"`
+	Created  time.Time `json:"created"`
+	Modified time.Time `json:"modified"`
+}
+
+// localRouting is the mirrored cells, tenant cells and releases of a LocalStore.
+type localRouting struct {
+	Cells       []CatalogCell       `json:"cells"`
+	TenantCells []CatalogTenantCell `json:"tenantCells"`
+	Releases    []*localRelease     `json:"releases"`
+}
+
+// localRelease is one mirrored release in a LocalStore.
+type localRelease struct {
+	Catalog CatalogRelease `json:"catalog"`
+	Created time.Time      `json:"created"`
+}
+
+// localStoreFile is the JSON document a LocalStore persists.
+type localStoreFile struct {
+	Capabilities []*localCapability `json:"capabilities"`
+	Routing      *localRouting      `json:"routing,omitempty"`
+}
+
+// NewLocalStore returns a LocalStore persisted at path, loaded with the catalog stored there
+// by an earlier run (none when the file does not exist yet). An empty path keeps the
+// catalog in memory only.
+func NewLocalStore(path string) (*LocalStore, error) {
+	s := &LocalStore{path: path, caps: make(map[string]*localCapability), now: time.Now}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s - read %s failed: %w", localStoreLogPrefix, path, err)
+	}
+	var file localStoreFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s - decode %s failed: %w", localStoreLogPrefix, path, err)
+	}
+	for _, lc := range file.Capabilities {
+		if lc != nil {
+			s.caps[lc.Catalog.App+"."+lc.Catalog.Name] = lc
+		}
+	}
+	if file.Routing != nil {
+		s.routing = *file.Routing
+	}
+	slog.Info(fmt.Sprintf("%s - loaded %d capabilities from %s", localStoreLogPrefix, len(s.caps), path))
+	return s, nil
+}
+
+// ApplyCatalogCapability stores c; see CatalogStore. Every capability in a LocalStore was
+// written by the mirror, so none is left alone.
+func (s *LocalStore) ApplyCatalogCapability(ctx context.Context, c CatalogCapability) (*db.Capability, error) {
+	key := c.App + "." + c.Name
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now().UTC()
+	lc := s.caps[key]
+	if lc == nil {
+		lc = &localCapability{Catalog: CatalogCapability{App: c.App, Name: c.Name}, Created: now}
+		s.caps[key] = lc
+	}
+	stored := &lc.Catalog
+	// Unset upstream fields keep their stored value, as in the repository
+	if c.Description != "" {
+		stored.Description = c.Description
+	}
+	if c.Tags != nil {
+		stored.Tags = c.Tags
+	}
+	if c.SubjectTemplate != "" {
+		stored.SubjectTemplate = c.SubjectTemplate
+	}
+	if c.LivenessPolicy != "" {
+		stored.LivenessPolicy = c.LivenessPolicy
+	}
+	stored.Revision = c.Revision
+	for _, v := range c.Versions {
+		i := slices.IndexFunc(stored.Versions, func(sv CatalogVersion) bool {
+			return sv.Major == v.Major && sv.Minor == v.Minor && sv.Patch == v.Patch && sv.Prerelease == v.Prerelease
+		})
+		if i < 0 {
+			stored.Versions = append(stored.Versions, v)
+			continue
+		}
+		// A scoped version keeps its envs and gains the upstream ones
+		envs := stored.Versions[i].Envs
+		if envs != nil && v.Envs != nil {
+			for _, env := range v.Envs {
+				if !slices.Contains(envs, env) {
+					envs = append(envs, env)
+				}
+			}
+		}
+		v.Envs = envs
+		stored.Versions[i] = v
+	}
+	for _, d := range c.Defaults {
+		i := slices.IndexFunc(stored.Defaults, func(sd CatalogDefault) bool { return sd.Env == d.Env })
+		if i < 0 {
+			stored.Defaults = append(stored.Defaults, d)
+		} else {
+			stored.Defaults[i] = d
+		}
+	}
+	if c.TenantRules != nil {
+		stored.TenantRules = c.TenantRules
+	}
+	stored.Shadow = c.Shadow
+	if c.Aliases != nil {
+		stored.Aliases = c.Aliases
+	}
+	lc.Revision++
+	lc.Modified = now
+	s.dirty = true
+
+	cap := localCapabilityRow(lc)
+	return &cap, nil
+}
+
+// ApplyCatalogRouting stores routing; see CatalogStore.
+func (s *LocalStore) ApplyCatalogRouting(ctx context.Context, routing CatalogRouting) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := localRouting{
+		Cells:       routing.Cells,
+		TenantCells: routing.TenantCells,
+		Releases:    s.routing.Releases,
+	}
+	for _, rel := range routing.Releases {
+		if s.releaseByName(rel.Name) != nil {
+			continue
+		}
+		if i := slices.IndexFunc(rel.Pins, func(p CatalogReleasePin) bool { _, v := s.pinnedVersion(p); return v == nil }); i >= 0 {
+			slog.Warn(fmt.Sprintf("%s - skipping release %s: %s@%s is not mirrored yet", localStoreLogPrefix, rel.Name, rel.Pins[i].Cap, rel.Pins[i].Version))
+			continue
+		}
+		next.Releases = append(next.Releases, &localRelease{Catalog: rel, Created: s.now().UTC()})
+	}
+
+	before, _ := json.Marshal(s.routing)
+	after, _ := json.Marshal(next)
+	if string(before) != string(after) {
+		s.routing = next
+		s.dirty = true
+	}
+	return nil
+}
+
+// Flush writes the catalog to the store's file when capabilities were applied since the
+// last Flush. The file is replaced atomically, so a crash leaves the previous copy.
+func (s *LocalStore) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.path == "" || !s.dirty {
+		return nil
+	}
+
+	file := localStoreFile{Capabilities: make([]*localCapability, 0, len(s.caps)), Routing: &s.routing}
+	for _, key := range s.sortedKeys() {
+		file.Capabilities = append(file.Capabilities, s.caps[key])
+	}
+	data, err := json.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("%s - encode failed: %w", localStoreLogPrefix, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("%s - create temp file failed: %w", localStoreLogPrefix, err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("%s - write %s failed: %w", localStoreLogPrefix, s.path, err)
+	}
+	s.dirty = false
+	return nil
+}
+
+// GetCapability returns the capability app.name, or nil when it is not stored.
+func (s *LocalStore) GetCapability(ctx context.Context, app, name string) (*db.Capability, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	lc := s.caps[app+"."+name]
+	if lc == nil {
+		return nil, nil
+	}
+	cap := localCapabilityRow(lc)
+	return &cap, nil
+}
+
+// ListCapabilities lists capabilities with the filters and paging of the repository. Every
+// mirrored capability has status Active.
+func (s *LocalStore) ListCapabilities(ctx context.Context, params db.ListCapabilitiesParams) ([]db.Capability, int, error) {
+	page := params.Page
+	if page < 1 {
+		page = 1
+	}
+	limit := params.Limit
+	if limit < 1 {
+		limit = 20
+	}
+	if limit > db.MaxDiscoverLimit {
+		limit = db.MaxDiscoverLimit
+	}
+	query := strings.ToLower(params.Query)
+
+	s.mu.RLock()
+	var matched []db.Capability
+	for _, lc := range s.caps {
+		cap := localCapabilityRow(lc)
+		if params.App != "" && cap.App != params.App {
+			continue
+		}
+		if params.Status != "" && params.Status != "all" && cap.Status != params.Status {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(cap.Name), query) &&
+			!strings.Contains(strings.ToLower(ptrStringOr(cap.Description, "")), query) {
+			continue
+		}
+		if len(params.Tags) > 0 && !slices.ContainsFunc(params.Tags, func(tag string) bool { return slices.Contains(cap.Tags, tag) }) {
+			continue
+		}
+		matched = append(matched, cap)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if !params.SortByName && !a.Modified.Equal(b.Modified) {
+			return a.Modified.After(b.Modified)
+		}
+		if a.App != b.App {
+			return a.App < b.App
+		}
+		return a.Name < b.Name
+	})
+	total := len(matched)
+	offset := (page - 1) * limit
+	if offset >= total {
+		return nil, total, nil
+	}
+	return matched[offset:min(offset+limit, total)], total, nil
+}
+
+// GetVersions returns the versions of a capability, ordered by semver descending.
+func (s *LocalStore) GetVersions(ctx context.Context, capabilityID string) ([]db.CapabilityVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	lc := s.capabilityByID(capabilityID)
+	if lc == nil {
+		return nil, nil
+	}
+	return localVersionRows(lc), nil
+}
+
+// GetVersionsByCapabilityIDs returns the versions of the given capabilities, keyed by capability ID.
+func (s *LocalStore) GetVersionsByCapabilityIDs(ctx context.Context, capabilityIDs []string) (map[string][]db.CapabilityVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string][]db.CapabilityVersion)
+	for _, id := range capabilityIDs {
+		if lc := s.capabilityByID(id); lc != nil {
+			out[id] = localVersionRows(lc)
+		}
+	}
+	return out, nil
+}
+
+// GetDefault returns the default major of a capability in env, or nil when it has none.
+func (s *LocalStore) GetDefault(ctx context.Context, capabilityID, env string) (*db.CapabilityDefault, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.defaultOf(capabilityID, env), nil
+}
+
+// GetDefaultsBatch returns the default major in env of each given capability that has one.
+func (s *LocalStore) GetDefaultsBatch(ctx context.Context, capabilityIDs []string, env string) (map[string]*db.CapabilityDefault, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string]*db.CapabilityDefault)
+	for _, id := range capabilityIDs {
+		if d := s.defaultOf(id, env); d != nil {
+			out[id] = d
+		}
+	}
+	return out, nil
+}
+
+// GetMethods returns the methods of a version ordered by name.
+func (s *LocalStore) GetMethods(ctx context.Context, versionID string) ([]db.CapabilityMethod, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	lc, v := s.versionByID(versionID)
+	if v == nil {
+		return nil, nil
+	}
+	methods := make([]db.CapabilityMethod, len(v.Methods))
+	for i, m := range v.Methods {
+		methods[i] = localMethodRow(versionID, m, lc)
+	}
+	sort.SliceStable(methods, func(i, j int) bool { return methods[i].Name < methods[j].Name })
+	return methods, nil
+}
+
+// ListEndpoints returns the provider endpoints of a version ordered by priority.
+func (s *LocalStore) ListEndpoints(ctx context.Context, versionID string) ([]db.CapabilityEndpoint, error) {
+	byVersion, err := s.ListEndpointsForVersions(ctx, []string{versionID})
+	if err != nil {
+		return nil, err
+	}
+	return byVersion[versionID], nil
+}
+
+// ListEndpointsForVersions returns the endpoints of several versions keyed by version ID.
+// Versions without endpoints are absent from the map.
+func (s *LocalStore) ListEndpointsForVersions(ctx context.Context, versionIDs []string) (map[string][]db.CapabilityEndpoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string][]db.CapabilityEndpoint)
+	for _, id := range versionIDs {
+		lc, v := s.versionByID(id)
+		if v == nil || len(v.Endpoints) == 0 {
+			continue
+		}
+		params := endpointParams(v.Endpoints)
+		endpoints := make([]db.CapabilityEndpoint, len(params))
+		for i, p := range params {
+			endpoints[i] = db.CapabilityEndpoint{
+				ID:         id + "#" + strconv.Itoa(i),
+				VersionID:  id,
+				NatsUrl:    p.NatsUrl,
+				Subject:    p.Subject,
+				Region:     p.Region,
+				Zone:       p.Zone,
+				Priority:   p.Priority,
+				Weight:     p.Weight,
+				Object:     "capability_endpoint",
+				Created:    lc.Modified,
+				CreatedBy:  mirrorUserID,
+				Modified:   lc.Modified,
+				ModifiedBy: mirrorUserID,
+			}
+		}
+		sort.SliceStable(endpoints, func(i, j int) bool {
+			a, b := endpoints[i], endpoints[j]
+			if a.Priority != b.Priority {
+				return a.Priority < b.Priority
+			}
+			if a.Weight != b.Weight {
+				return a.Weight > b.Weight
+			}
+			return a.NatsUrl < b.NatsUrl
+		})
+		out[id] = endpoints
+	}
+	return out, nil
+}
+
+// ListBootstrapEntries returns every capability with a default major in env, with the
+// latest version of that major available in env, ordered by app and name.
+func (s *LocalStore) ListBootstrapEntries(ctx context.Context, env string) ([]db.BootstrapEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []db.BootstrapEntry
+	for _, key := range s.sortedKeys() {
+		lc := s.caps[key]
+		capID := localIDPrefix + key
+		d := s.defaultOf(capID, env)
+		if d == nil {
+			continue
+		}
+		var latest *db.CapabilityVersion
+		for _, v := range localVersionRows(lc) {
+			if v.Major != d.DefaultMajor || (v.Envs != nil && !slices.Contains(v.Envs, env)) {
+				continue
+			}
+			// Versions are ordered by semver descending: the first match is the latest
+			latest = &v
+			break
+		}
+		if latest == nil {
+			continue
+		}
+		out = append(out, db.BootstrapEntry{
+			App:             lc.Catalog.App,
+			Name:            lc.Catalog.Name,
+			Description:     lc.Catalog.Description,
+			DefaultMajor:    d.DefaultMajor,
+			VersionString:   ptrStringOr(latest.VersionString, ""),
+			VersionStatus:   latest.Status,
+			VersionID:       latest.ID,
+			Minor:           latest.Minor,
+			SubjectTemplate: lc.Catalog.SubjectTemplate,
+		})
+	}
+	return out, nil
+}
+
+// CheckTenantAccess evaluates the stored tenant rules of a capability like the repository.
+func (s *LocalStore) CheckTenantAccess(ctx context.Context, capabilityID string, major int, rctx db.ResolutionContext) (bool, string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	lc := s.capabilityByID(capabilityID)
+	if lc == nil {
+		return true, ""
+	}
+	rules := db.MatchTenantRules(tenantRuleRows(capabilityID, lc.Catalog.TenantRules), rctx)
+	return db.EvaluateTenantRules(rules, major, rctx)
+}
+
+// GetShadow returns the stored shadow config of a capability, or nil when it has none.
+func (s *LocalStore) GetShadow(ctx context.Context, capabilityID string) (*db.CapabilityShadow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	lc := s.capabilityByID(capabilityID)
+	if lc == nil || lc.Catalog.Shadow == nil {
+		return nil, nil
+	}
+	shadow := lc.Catalog.Shadow
+	return &db.CapabilityShadow{
+		ID:            capabilityID + "#shadow",
+		CapabilityID:  capabilityID,
+		TargetVersion: optionalString(shadow.TargetVersion),
+		TargetMajor:   shadow.TargetMajor,
+		SampleRate:    shadow.SampleRate,
+		TenantIDs:     shadow.TenantIDs,
+		Object:        "capability_shadow",
+		Created:       lc.Modified,
+		CreatedBy:     mirrorUserID,
+		Modified:      lc.Modified,
+		ModifiedBy:    mirrorUserID,
+	}, nil
+}
+
+// GetCapabilityAlias returns the stored alias named alias, or nil.
+func (s *LocalStore) GetCapabilityAlias(ctx context.Context, alias string) (*db.CapabilityAlias, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, key := range s.sortedKeys() {
+		lc := s.caps[key]
+		for _, a := range lc.Catalog.Aliases {
+			if a.Alias != alias {
+				continue
+			}
+			return &db.CapabilityAlias{
+				ID:         localIDPrefix + "alias:" + alias,
+				Alias:      alias,
+				TargetApp:  lc.Catalog.App,
+				TargetName: lc.Catalog.Name,
+				Source:     db.CapabilitySourceMirror,
+				Kind:       a.Kind,
+				Object:     "capability_alias",
+				Created:    lc.Modified,
+				CreatedBy:  mirrorUserID,
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetTenantCell returns the stored cell a tenant is mapped to, or nil.
+func (s *LocalStore) GetTenantCell(ctx context.Context, tenantID string) (*db.Cell, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	i := slices.IndexFunc(s.routing.TenantCells, func(tc CatalogTenantCell) bool { return tc.TenantID == tenantID })
+	if i < 0 {
+		return nil, nil
+	}
+	name := s.routing.TenantCells[i].Cell
+	for _, c := range s.routing.Cells {
+		if c.Name == name {
+			return &db.Cell{
+				ID:            localCellIDPrefix + c.Name,
+				Name:          c.Name,
+				NatsUrl:       c.NatsUrl,
+				SubjectPrefix: optionalString(c.SubjectPrefix),
+				Description:   optionalString(c.Description),
+				Object:        "cell",
+				CreatedBy:     mirrorUserID,
+				ModifiedBy:    mirrorUserID,
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetReleaseByName returns the stored release named name, or nil.
+func (s *LocalStore) GetReleaseByName(ctx context.Context, name string) (*db.Release, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	lr := s.releaseByName(name)
+	if lr == nil {
+		return nil, nil
+	}
+	rel := localReleaseRow(lr)
+	return &rel, nil
+}
+
+// ListReleases returns the stored releases, newest first.
+func (s *LocalStore) ListReleases(ctx context.Context) ([]db.Release, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]db.Release, len(s.routing.Releases))
+	for i, lr := range s.routing.Releases {
+		out[i] = localReleaseRow(lr)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if !out[i].Created.Equal(out[j].Created) {
+			return out[i].Created.After(out[j].Created)
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out, nil
+}
+
+// GetReleasePins returns the pins of a stored release ordered by app and name. Pins of
+// versions no longer in the store are left out.
+func (s *LocalStore) GetReleasePins(ctx context.Context, releaseID string) ([]db.ReleasePin, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	lr := s.releaseByName(strings.TrimPrefix(releaseID, localReleaseIDPrefix))
+	if lr == nil {
+		return nil, nil
+	}
+	var out []db.ReleasePin
+	for _, p := range lr.Catalog.Pins {
+		if pin := s.releasePinRow(releaseID, p); pin != nil {
+			out = append(out, *pin)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].App != out[j].App {
+			return out[i].App < out[j].App
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out, nil
+}
+
+// GetReleasePin returns the pin of one capability in a stored release, or nil.
+func (s *LocalStore) GetReleasePin(ctx context.Context, releaseID, capabilityID string) (*db.ReleasePin, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	lr := s.releaseByName(strings.TrimPrefix(releaseID, localReleaseIDPrefix))
+	if lr == nil {
+		return nil, nil
+	}
+	for _, p := range lr.Catalog.Pins {
+		if localIDPrefix+p.Cap == capabilityID {
+			return s.releasePinRow(releaseID, p), nil
+		}
+	}
+	return nil, nil
+}
+
+// releaseByName returns the stored release named name, or nil.
+func (s *LocalStore) releaseByName(name string) *localRelease {
+	for _, lr := range s.routing.Releases {
+		if lr.Catalog.Name == name {
+			return lr
+		}
+	}
+	return nil
+}
+
+// pinnedVersion returns the stored version a release pin names and its capability, or nils.
+func (s *LocalStore) pinnedVersion(p CatalogReleasePin) (*localCapability, *CatalogVersion) {
+	return s.versionByID(localIDPrefix + p.Cap + "@" + p.Version)
+}
+
+// releasePinRow returns the release_pins row of a pin, or nil when its version is not stored.
+func (s *LocalStore) releasePinRow(releaseID string, p CatalogReleasePin) *db.ReleasePin {
+	lc, v := s.pinnedVersion(p)
+	if v == nil {
+		return nil
+	}
+	return &db.ReleasePin{
+		ReleaseID:       releaseID,
+		CapabilityID:    localIDPrefix + p.Cap,
+		VersionID:       localIDPrefix + p.Cap + "@" + p.Version,
+		App:             lc.Catalog.App,
+		Name:            lc.Catalog.Name,
+		Major:           v.Major,
+		VersionString:   p.Version,
+		VersionStatus:   v.Status,
+		Minor:           v.Minor,
+		SubjectTemplate: lc.Catalog.SubjectTemplate,
+	}
+}
+
+// localReleaseRow returns the releases row of a stored release.
+func localReleaseRow(lr *localRelease) db.Release {
+	return db.Release{
+		ID:          localReleaseIDPrefix + lr.Catalog.Name,
+		Name:        lr.Catalog.Name,
+		Description: optionalString(lr.Catalog.Description),
+		Object:      "release",
+		Created:     lr.Created,
+		CreatedBy:   mirrorUserID,
+		PinCount:    len(lr.Catalog.Pins),
+	}
+}
+
+// sortedKeys returns the "app.name" keys of the stored capabilities in order.
+func (s *LocalStore) sortedKeys() []string {
+	keys := make([]string, 0, len(s.caps))
+	for key := range s.caps {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// capabilityByID returns the stored capability with a LocalStore capability ID, or nil.
+func (s *LocalStore) capabilityByID(id string) *localCapability {
+	key, ok := strings.CutPrefix(id, localIDPrefix)
+	if !ok {
+		return nil
+	}
+	return s.caps[key]
+}
+
+// versionByID returns the stored version with a LocalStore version ID and its capability.
+func (s *LocalStore) versionByID(id string) (*localCapability, *CatalogVersion) {
+	capID, version, ok := strings.Cut(id, "@")
+	if !ok {
+		return nil, nil
+	}
+	lc := s.capabilityByID(capID)
+	if lc == nil {
+		return nil, nil
+	}
+	for i, v := range lc.Catalog.Versions {
+		if semver.ToVersionString(v.Major, v.Minor, v.Patch, v.Prerelease) == version {
+			return lc, &lc.Catalog.Versions[i]
+		}
+	}
+	return nil, nil
+}
+
+// defaultOf returns the default major of a capability in env, or nil.
+func (s *LocalStore) defaultOf(capabilityID, env string) *db.CapabilityDefault {
+	lc := s.capabilityByID(capabilityID)
+	if lc == nil {
+		return nil
+	}
+	for _, d := range lc.Catalog.Defaults {
+		if d.Env == env {
+			return &db.CapabilityDefault{
+				ID:           capabilityID + "#" + env,
+				CapabilityID: capabilityID,
+				DefaultMajor: d.Major,
+				Env:          env,
+				Object:       "capability_default",
+				Created:      lc.Modified,
+				CreatedBy:    mirrorUserID,
+				Modified:     lc.Modified,
+				ModifiedBy:   mirrorUserID,
+			}
+		}
+	}
+	return nil
+}
+
+// localCapabilityRow returns the capabilities row of a stored capability.
+func localCapabilityRow(lc *localCapability) db.Capability {
+	c := lc.Catalog
+	tags := c.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return db.Capability{
+		ID:              localIDPrefix + c.App + "." + c.Name,
+		App:             c.App,
+		Name:            c.Name,
+		Description:     optionalString(c.Description),
+		Tags:            tags,
+		Status:          "Active",
+		Object:          "capability",
+		Revision:        lc.Revision,
+		Created:         lc.Created,
+		CreatedBy:       mirrorUserID,
+		Modified:        lc.Modified,
+		ModifiedBy:      mirrorUserID,
+		SubjectTemplate: optionalString(c.SubjectTemplate),
+		LivenessPolicy:  optionalString(c.LivenessPolicy),
+		Source:          db.CapabilitySourceMirror,
+	}
+}
+
+// localVersionRows returns the capability_versions rows of a stored capability, ordered by
+// semver descending.
+func localVersionRows(lc *localCapability) []db.CapabilityVersion {
+	capID := localIDPrefix + lc.Catalog.App + "." + lc.Catalog.Name
+	versions := make([]db.CapabilityVersion, len(lc.Catalog.Versions))
+	for i, v := range lc.Catalog.Versions {
+		versionString := semver.ToVersionString(v.Major, v.Minor, v.Patch, v.Prerelease)
+		var metadata []byte
+		if v.Metadata != nil {
+			metadata, _ = json.Marshal(v.Metadata)
+		}
+		versions[i] = db.CapabilityVersion{
+			ID:                capID + "@" + versionString,
+			CapabilityID:      capID,
+			Major:             v.Major,
+			Minor:             v.Minor,
+			Patch:             v.Patch,
+			Prerelease:        optionalString(v.Prerelease),
+			VersionString:     &versionString,
+			Status:            v.Status,
+			DeprecationReason: optionalString(v.DeprecationReason),
+			Description:       optionalString(v.Description),
+			Changelog:         optionalString(v.Changelog),
+			Metadata:          metadata,
+			Object:            "capability_version",
+			Created:           lc.Created,
+			CreatedBy:         mirrorUserID,
+			Modified:          lc.Modified,
+			ModifiedBy:        mirrorUserID,
+			Envs:              v.Envs,
+		}
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		a, b := versions[i], versions[j]
+		if a.Major != b.Major {
+			return a.Major > b.Major
+		}
+		if a.Minor != b.Minor {
+			return a.Minor > b.Minor
+		}
+		return a.Patch > b.Patch
+	})
+	return versions
+}
+
+// localMethodRow returns the capability_methods row of a stored method, with the defaults
+// the repository fills in for missing schemas, policies, examples, modes and tags.
+func localMethodRow(versionID string, m MethodDefinition, lc *localCapability) db.CapabilityMethod {
+	inputSchema, outputSchema := []byte("{}"), []byte("{}")
+	if m.InputSchema != nil {
+		inputSchema, _ = json.Marshal(m.InputSchema)
+	}
+	if m.OutputSchema != nil {
+		outputSchema, _ = json.Marshal(m.OutputSchema)
+	}
+	policies, examples := []byte("{}"), []byte("[]")
+	if m.Policies != nil {
+		policies, _ = json.Marshal(m.Policies)
+	}
+	if m.Examples != nil {
+		examples, _ = json.Marshal(m.Examples)
+	}
+	modes := m.Modes
+	if len(modes) == 0 {
+		modes = []string{"sync"}
+	}
+	tags := m.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return db.CapabilityMethod{
+		ID:           versionID + "#" + m.Name,
+		VersionID:    versionID,
+		Name:         m.Name,
+		Description:  optionalString(m.Description),
+		InputSchema:  inputSchema,
+		OutputSchema: outputSchema,
+		Tags:         tags,
+		Policies:     policies,
+		Examples:     examples,
+		Modes:        modes,
+		Object:       "capability_method",
+		Created:      lc.Modified,
+		CreatedBy:    mirrorUserID,
+		Modified:     lc.Modified,
+		ModifiedBy:   mirrorUserID,
+	}
+}
@@ -0,0 +1,407 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	comms "github.com/nats-io/nats.go"
+
+	"github.com/morezero/capabilities-registry/pkg/db"
+	"github.com/morezero/capabilities-registry/pkg/events"
+)
+
+const localStoreTestPrefix = "registry:local_store_test"
+
+func testCatalogCapability() CatalogCapability {
+	return CatalogCapability{
+		App:         "more0",
+		Name:        "doc.ingest",
+		Description: "Ingest documents",
+		Tags:        []string{"documents"},
+		Revision:    7,
+		Defaults:    []CatalogDefault{{Env: "production", Major: 2}},
+		Versions: []CatalogVersion{
+			{Major: 1, Minor: 4, Patch: 0, Status: "deprecated", DeprecationReason: "use v2",
+				Methods: []MethodDefinition{{Name: "ingest"}}},
+			{Major: 2, Minor: 0, Patch: 0, Status: "active",
+				Methods:   []MethodDefinition{{Name: "ingest", InputSchema: map[string]interface{}{"type": "object"}}, {Name: "cancel"}},
+				Endpoints: []EndpointInput{{NatsUrl: "nats://b.example:4222", Priority: 2}, {NatsUrl: "nats://a.example:4222", Priority: 1}}},
+			{Major: 2, Minor: 1, Patch: 0, Status: "active", Envs: []string{"staging"},
+				Methods: []MethodDefinition{{Name: "ingest"}}},
+		},
+	}
+}
+
+func TestLocalStore_Apply(t *testing.T) {
+	ctx := context.Background()
+	s, _ := NewLocalStore("")
+	cap, err := s.ApplyCatalogCapability(ctx, testCatalogCapability())
+	if err != nil || cap == nil {
+		t.Fatalf("%s - apply failed: %v", localStoreTestPrefix, err)
+	}
+	if cap.Revision != 1 || cap.Source != db.CapabilitySourceMirror || cap.Status != "Active" {
+		t.Errorf("%s - unexpected capability row %+v", localStoreTestPrefix, cap)
+	}
+
+	versions, _ := s.GetVersions(ctx, cap.ID)
+	if len(versions) != 3 || ptrStringOr(versions[0].VersionString, "") != "2.1.0" || ptrStringOr(versions[2].VersionString, "") != "1.4.0" {
+		t.Fatalf("%s - versions must be ordered by semver descending, got %+v", localStoreTestPrefix, versions)
+	}
+	if versions[2].Status != "deprecated" || ptrStringOr(versions[2].DeprecationReason, "") != "use v2" {
+		t.Errorf("%s - deprecated version = %+v", localStoreTestPrefix, versions[2])
+	}
+	methods, _ := s.GetMethods(ctx, versions[1].ID)
+	if len(methods) != 2 || methods[0].Name != "cancel" || string(methods[0].InputSchema) != "{}" {
+		t.Errorf("%s - methods = %+v", localStoreTestPrefix, methods)
+	}
+	endpoints, _ := s.ListEndpoints(ctx, versions[1].ID)
+	if len(endpoints) != 2 || endpoints[0].NatsUrl != "nats://a.example:4222" || endpoints[0].Weight != 100 {
+		t.Errorf("%s - endpoints must be ordered by priority, got %+v", localStoreTestPrefix, endpoints)
+	}
+	if d, _ := s.GetDefault(ctx, cap.ID, "production"); d == nil || d.DefaultMajor != 2 {
+		t.Errorf("%s - production default = %+v", localStoreTestPrefix, d)
+	}
+	if d, _ := s.GetDefault(ctx, cap.ID, "staging"); d != nil {
+		t.Errorf("%s - expected no staging default, got %+v", localStoreTestPrefix, d)
+	}
+
+	// A later export merges into the stored copy like the repository does
+	update := CatalogCapability{
+		App:      "more0",
+		Name:     "doc.ingest",
+		Defaults: []CatalogDefault{{Env: "staging", Major: 2}},
+		Versions: []CatalogVersion{{Major: 2, Minor: 1, Patch: 0, Status: "active", Envs: []string{"production"},
+			Methods: []MethodDefinition{{Name: "ingest"}}}},
+	}
+	cap, err = s.ApplyCatalogCapability(ctx, update)
+	if err != nil || cap.Revision != 2 || ptrStringOr(cap.Description, "") != "Ingest documents" {
+		t.Fatalf("%s - merge: %+v, %v", localStoreTestPrefix, cap, err)
+	}
+	versions, _ = s.GetVersions(ctx, cap.ID)
+	if len(versions) != 3 || len(versions[0].Envs) != 2 {
+		t.Errorf("%s - a scoped version must gain the upstream envs, got %+v", localStoreTestPrefix, versions)
+	}
+	if d, _ := s.GetDefault(ctx, cap.ID, "production"); d == nil || d.DefaultMajor != 2 {
+		t.Errorf("%s - defaults the export no longer lists stay in place, got %+v", localStoreTestPrefix, d)
+	}
+}
+
+func TestLocalStore_FlushAndReload(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "catalog.json")
+	s, err := NewLocalStore(path)
+	if err != nil {
+		t.Fatalf("%s - NewLocalStore failed: %v", localStoreTestPrefix, err)
+	}
+	if _, err := s.ApplyCatalogCapability(ctx, testCatalogCapability()); err != nil {
+		t.Fatalf("%s - apply failed: %v", localStoreTestPrefix, err)
+	}
+	if err := s.Flush(ctx); err != nil {
+		t.Fatalf("%s - Flush failed: %v", localStoreTestPrefix, err)
+	}
+
+	reloaded, err := NewLocalStore(path)
+	if err != nil {
+		t.Fatalf("%s - reload failed: %v", localStoreTestPrefix, err)
+	}
+	cap, _ := reloaded.GetCapability(ctx, "more0", "doc.ingest")
+	if cap == nil || cap.Revision != 1 {
+		t.Fatalf("%s - reloaded capability = %+v", localStoreTestPrefix, cap)
+	}
+	if versions, _ := reloaded.GetVersions(ctx, cap.ID); len(versions) != 3 {
+		t.Errorf("%s - reloaded versions = %+v", localStoreTestPrefix, versions)
+	}
+}
+
+func TestLocalStore_ListCapabilities(t *testing.T) {
+	ctx := context.Background()
+	s, _ := NewLocalStore("")
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	for _, c := range []CatalogCapability{
+		{App: "more0", Name: "doc.ingest", Description: "Ingest documents", Tags: []string{"documents"}},
+		{App: "more0", Name: "mail.send", Tags: []string{"mail"}},
+		{App: "acme", Name: "billing.charge", Description: "Charge a card"},
+	} {
+		now = now.Add(time.Minute)
+		if _, err := s.ApplyCatalogCapability(ctx, c); err != nil {
+			t.Fatalf("%s - apply failed: %v", localStoreTestPrefix, err)
+		}
+	}
+
+	names := func(caps []db.Capability) []string {
+		var out []string
+		for _, c := range caps {
+			out = append(out, c.App+"."+c.Name)
+		}
+		return out
+	}
+	cases := []struct {
+		name   string
+		params db.ListCapabilitiesParams
+		want   []string
+		total  int
+	}{
+		{"modified descending", db.ListCapabilitiesParams{}, []string{"acme.billing.charge", "more0.mail.send", "more0.doc.ingest"}, 3},
+		{"by name", db.ListCapabilitiesParams{SortByName: true}, []string{"acme.billing.charge", "more0.doc.ingest", "more0.mail.send"}, 3},
+		{"app", db.ListCapabilitiesParams{App: "more0", SortByName: true}, []string{"more0.doc.ingest", "more0.mail.send"}, 2},
+		{"query matches description", db.ListCapabilitiesParams{Query: "CARD"}, []string{"acme.billing.charge"}, 1},
+		{"tags", db.ListCapabilitiesParams{Tags: []string{"mail", "other"}}, []string{"more0.mail.send"}, 1},
+		{"status", db.ListCapabilitiesParams{Status: "Inactive"}, nil, 0},
+		{"second page", db.ListCapabilitiesParams{SortByName: true, Page: 2, Limit: 2}, []string{"more0.mail.send"}, 3},
+	}
+	for _, tc := range cases {
+		caps, total, err := s.ListCapabilities(ctx, tc.params)
+		got := names(caps)
+		if err != nil || total != tc.total || len(got) != len(tc.want) {
+			t.Errorf("%s - %s: got %v (total %d, err %v), want %v", localStoreTestPrefix, tc.name, got, total, err, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%s - %s: got %v, want %v", localStoreTestPrefix, tc.name, got, tc.want)
+				break
+			}
+		}
+	}
+}
+
+func TestLocalStore_ListBootstrapEntries(t *testing.T) {
+	ctx := context.Background()
+	s, _ := NewLocalStore("")
+	if _, err := s.ApplyCatalogCapability(ctx, testCatalogCapability()); err != nil {
+		t.Fatalf("%s - apply failed: %v", localStoreTestPrefix, err)
+	}
+	if _, err := s.ApplyCatalogCapability(ctx, CatalogCapability{App: "more0", Name: "no.default",
+		Versions: []CatalogVersion{{Major: 1, Status: "active"}}}); err != nil {
+		t.Fatalf("%s - apply failed: %v", localStoreTestPrefix, err)
+	}
+
+	entries, err := s.ListBootstrapEntries(ctx, "production")
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("%s - entries = %+v, %v", localStoreTestPrefix, entries, err)
+	}
+	// 2.1.0 is only available in staging
+	if e := entries[0]; e.Name != "doc.ingest" || e.DefaultMajor != 2 || e.VersionString != "2.0.0" {
+		t.Errorf("%s - unexpected entry %+v", localStoreTestPrefix, e)
+	}
+	if entries, _ := s.ListBootstrapEntries(ctx, "staging"); len(entries) != 0 {
+		t.Errorf("%s - expected no staging entries without a staging default, got %+v", localStoreTestPrefix, entries)
+	}
+}
+
+// serveUpstream answers exportCatalog and changesSince on subject like an upstream registry
+// whose catalog is caps.
+func serveUpstream(t *testing.T, nc *comms.Conn, subject string, caps []CatalogCapability) {
+	t.Helper()
+	serveUpstreamRouting(t, nc, subject, caps, nil)
+}
+
+// serveUpstreamRouting is serveUpstream for an upstream that also exports routing.
+func serveUpstreamRouting(t *testing.T, nc *comms.Conn, subject string, caps []CatalogCapability, routing *CatalogRouting) {
+	t.Helper()
+	sub, err := nc.Subscribe(subject, func(msg *comms.Msg) {
+		var req struct {
+			Method string             `json:"method"`
+			Params ExportCatalogInput `json:"params"`
+		}
+		_ = json.Unmarshal(msg.Data, &req)
+		var result interface{}
+		switch {
+		case req.Method == "exportCatalog" && req.Params.Routing:
+			result = ExportCatalogOutput{Capabilities: []CatalogCapability{}, Routing: routing}
+		case req.Method == "exportCatalog":
+			result = ExportCatalogOutput{Capabilities: caps, Pagination: Pagination{Page: 1, Limit: mirrorPageLimit, Total: len(caps), TotalPages: 1}}
+		case req.Method == "changesSince":
+			result = ChangesSinceOutput{Changes: []events.RegistryChangedEvent{}, Next: 3, Latest: 3}
+		}
+		data, _ := json.Marshal(map[string]interface{}{"ok": true, "result": result})
+		_ = msg.Respond(data)
+	})
+	if err != nil {
+		t.Fatalf("%s - subscribe failed: %v", localStoreTestPrefix, err)
+	}
+	t.Cleanup(func() { _ = sub.Unsubscribe() })
+}
+
+func TestSyncMirror_LocalStore(t *testing.T) {
+	ctx := context.Background()
+	nc := startProbeServer(t)
+	serveUpstream(t, nc, "registry.central", []CatalogCapability{testCatalogCapability()})
+
+	path := filepath.Join(t.TempDir(), "catalog.json")
+	store, _ := NewLocalStore(path)
+	cfg := DefaultConfig()
+	cfg.MirrorUpstream = "central"
+	cfg.MirrorUpstreamURL = nc.ConnectedUrl()
+	cfg.MirrorUpstreamSubject = "registry.central"
+	r := NewRegistry(NewRegistryParams{Config: cfg, Catalog: store})
+	defer r.Close()
+
+	if err := r.SyncMirror(ctx); err != nil {
+		t.Fatalf("%s - SyncMirror failed: %v", localStoreTestPrefix, err)
+	}
+
+	resolved, err := r.Resolve(ctx, &ResolveInput{Cap: "more0.doc.ingest"})
+	if err != nil || resolved.ResolvedVersion != "2.0.0" || resolved.NatsUrl != "nats://a.example:4222" {
+		t.Errorf("%s - resolve: %+v, %v", localStoreTestPrefix, resolved, err)
+	}
+	discovered, err := r.Discover(ctx, &DiscoverInput{Query: "ingest"})
+	if err != nil || len(discovered.Capabilities) != 1 {
+		t.Errorf("%s - discover: %+v, %v", localStoreTestPrefix, discovered, err)
+	}
+	described, err := r.Describe(ctx, &DescribeInput{Cap: "more0.doc.ingest", Version: "2.0.0"})
+	if err != nil || len(described.Methods) != 2 || described.Methods[0].Name != "cancel" {
+		t.Errorf("%s - describe: %+v, %v", localStoreTestPrefix, described, err)
+	}
+
+	// The copy survives a restart without the upstream
+	reloaded, err := NewLocalStore(path)
+	if err != nil {
+		t.Fatalf("%s - reload failed: %v", localStoreTestPrefix, err)
+	}
+	if cap, _ := reloaded.GetCapability(ctx, "more0", "doc.ingest"); cap == nil {
+		t.Errorf("%s - SyncMirror must flush the store", localStoreTestPrefix)
+	}
+}
+
+func TestSyncMirror_LocalStoreEnforcesTenantRules(t *testing.T) {
+	ctx := context.Background()
+	nc := startProbeServer(t)
+	denied := "6f1c2a5e-0000-4000-8000-000000000001"
+	c := testCatalogCapability()
+	c.TenantRules = []CatalogTenantRule{{TenantID: denied, RuleType: "deny", DeniedMajors: []int{2}, Priority: 10}}
+	serveUpstream(t, nc, "registry.central", []CatalogCapability{c})
+
+	path := filepath.Join(t.TempDir(), "catalog.json")
+	store, _ := NewLocalStore(path)
+	cfg := DefaultConfig()
+	cfg.MirrorUpstream = "central"
+	cfg.MirrorUpstreamURL = nc.ConnectedUrl()
+	cfg.MirrorUpstreamSubject = "registry.central"
+	r := NewRegistry(NewRegistryParams{Config: cfg, Catalog: store})
+	defer r.Close()
+	if err := r.SyncMirror(ctx); err != nil {
+		t.Fatalf("%s - SyncMirror failed: %v", localStoreTestPrefix, err)
+	}
+
+	_, err := r.Resolve(ctx, &ResolveInput{Cap: "more0.doc.ingest", Ctx: &ResolutionContext{TenantID: denied}})
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "FORBIDDEN" {
+		t.Errorf("%s - the upstream deny rule must be enforced, got %v", localStoreTestPrefix, err)
+	}
+	if out, err := r.Resolve(ctx, &ResolveInput{Cap: "more0.doc.ingest", Ctx: &ResolutionContext{TenantID: "6f1c2a5e-0000-4000-8000-000000000002"}}); err != nil || out.Major != 2 {
+		t.Errorf("%s - other tenants resolve as before: %+v, %v", localStoreTestPrefix, out, err)
+	}
+
+	// The rules are persisted with the catalog
+	reloaded, _ := NewLocalStore(path)
+	cap, _ := reloaded.GetCapability(ctx, "more0", "doc.ingest")
+	if allowed, _ := reloaded.CheckTenantAccess(ctx, cap.ID, 2, db.ResolutionContext{TenantID: denied}); allowed {
+		t.Errorf("%s - reloaded store must keep the tenant rules", localStoreTestPrefix)
+	}
+}
+
+func TestLocalStore_ApplyCatalogRouting(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "catalog.json")
+	s, _ := NewLocalStore(path)
+	if _, err := s.ApplyCatalogCapability(ctx, testCatalogCapability()); err != nil {
+		t.Fatalf("%s - apply failed: %v", localStoreTestPrefix, err)
+	}
+	routing := CatalogRouting{
+		Cells:       []CatalogCell{{Name: "eu", NatsUrl: "nats://eu.example:4222", SubjectPrefix: "eu"}},
+		TenantCells: []CatalogTenantCell{{TenantID: "tenant-a", Cell: "eu"}},
+		Releases: []CatalogRelease{
+			{Name: "2026.10", Pins: []CatalogReleasePin{{Cap: "more0.doc.ingest", Version: "1.4.0"}}},
+			{Name: "2026.11", Pins: []CatalogReleasePin{{Cap: "more0.doc.ingest", Version: "3.0.0"}}},
+		},
+	}
+	if err := s.ApplyCatalogRouting(ctx, routing); err != nil {
+		t.Fatalf("%s - ApplyCatalogRouting failed: %v", localStoreTestPrefix, err)
+	}
+
+	cell, _ := s.GetTenantCell(ctx, "tenant-a")
+	if cell == nil || cell.NatsUrl != "nats://eu.example:4222" || ptrStringOr(cell.SubjectPrefix, "") != "eu" {
+		t.Errorf("%s - tenant cell = %+v", localStoreTestPrefix, cell)
+	}
+	rel, _ := s.GetReleaseByName(ctx, "2026.10")
+	if rel == nil || rel.PinCount != 1 {
+		t.Fatalf("%s - release = %+v", localStoreTestPrefix, rel)
+	}
+	cap, _ := s.GetCapability(ctx, "more0", "doc.ingest")
+	pin, _ := s.GetReleasePin(ctx, rel.ID, cap.ID)
+	if pin == nil || pin.VersionString != "1.4.0" || pin.Major != 1 || pin.VersionStatus != "deprecated" {
+		t.Errorf("%s - pin = %+v", localStoreTestPrefix, pin)
+	}
+	if rel, _ := s.GetReleaseByName(ctx, "2026.11"); rel != nil {
+		t.Errorf("%s - a release pinning a version that is not stored must wait, got %+v", localStoreTestPrefix, rel)
+	}
+
+	// The tenant moves away and the cell goes; the stored release is immutable
+	routing.TenantCells = nil
+	routing.Cells = nil
+	routing.Releases = []CatalogRelease{{Name: "2026.10", Pins: []CatalogReleasePin{{Cap: "more0.doc.ingest", Version: "2.0.0"}}}}
+	if err := s.ApplyCatalogRouting(ctx, routing); err != nil {
+		t.Fatalf("%s - ApplyCatalogRouting failed: %v", localStoreTestPrefix, err)
+	}
+	if cell, _ := s.GetTenantCell(ctx, "tenant-a"); cell != nil {
+		t.Errorf("%s - expected no cell after the tenant was removed, got %+v", localStoreTestPrefix, cell)
+	}
+	if err := s.Flush(ctx); err != nil {
+		t.Fatalf("%s - Flush failed: %v", localStoreTestPrefix, err)
+	}
+	reloaded, _ := NewLocalStore(path)
+	releases, _ := reloaded.ListReleases(ctx)
+	if len(releases) != 1 {
+		t.Fatalf("%s - reloaded releases = %+v", localStoreTestPrefix, releases)
+	}
+	if pins, _ := reloaded.GetReleasePins(ctx, releases[0].ID); len(pins) != 1 || pins[0].VersionString != "1.4.0" {
+		t.Errorf("%s - reloaded pins = %+v", localStoreTestPrefix, pins)
+	}
+}
+
+func TestSyncMirror_LocalStoreRouting(t *testing.T) {
+	ctx := context.Background()
+	nc := startProbeServer(t)
+	tenant := "6f1c2a5e-0000-4000-8000-000000000003"
+	c := testCatalogCapability()
+	c.Aliases = []CatalogAlias{{Alias: "more0.docs.ingest", Kind: db.CapabilityAliasRename}}
+	c.Shadow = &CatalogShadow{TargetVersion: "1.4.0", SampleRate: 0.25}
+	serveUpstreamRouting(t, nc, "registry.central", []CatalogCapability{c}, &CatalogRouting{
+		Cells:       []CatalogCell{{Name: "eu", NatsUrl: "nats://eu.example:4222"}},
+		TenantCells: []CatalogTenantCell{{TenantID: tenant, Cell: "eu"}},
+		Releases:    []CatalogRelease{{Name: "2026.10", Pins: []CatalogReleasePin{{Cap: "more0.doc.ingest", Version: "1.4.0"}}}},
+	})
+
+	store, _ := NewLocalStore("")
+	cfg := DefaultConfig()
+	cfg.MirrorUpstream = "central"
+	cfg.MirrorUpstreamURL = nc.ConnectedUrl()
+	cfg.MirrorUpstreamSubject = "registry.central"
+	r := NewRegistry(NewRegistryParams{Config: cfg, Catalog: store})
+	defer r.Close()
+	if err := r.SyncMirror(ctx); err != nil {
+		t.Fatalf("%s - SyncMirror failed: %v", localStoreTestPrefix, err)
+	}
+
+	renamed, err := r.Resolve(ctx, &ResolveInput{Cap: "more0.docs.ingest"})
+	if err != nil || renamed.ResolvedVersion != "2.0.0" || len(renamed.Warnings) == 0 || renamed.Warnings[0].Code != "renamed" {
+		t.Errorf("%s - the mirrored alias must be followed: %+v, %v", localStoreTestPrefix, renamed, err)
+	}
+	if renamed != nil && (renamed.Shadow == nil || renamed.Shadow.ResolvedVersion != "1.4.0" || renamed.Shadow.SampleRate != 0.25) {
+		t.Errorf("%s - the mirrored shadow must apply, got %+v", localStoreTestPrefix, renamed.Shadow)
+	}
+	pinned, err := r.Resolve(ctx, &ResolveInput{Cap: "more0.doc.ingest", Ctx: &ResolutionContext{Release: "2026.10"}})
+	if err != nil || pinned.ResolvedVersion != "1.4.0" {
+		t.Errorf("%s - ctx.release must resolve the mirrored pin: %+v, %v", localStoreTestPrefix, pinned, err)
+	}
+	if natsUrl, cell := r.TenantNatsUrl(ctx, tenant); natsUrl != "nats://eu.example:4222" || cell != "eu" {
+		t.Errorf("%s - tenant URL = %s (%s), want the mirrored cell", localStoreTestPrefix, natsUrl, cell)
+	}
+	if described, err := r.DescribeRelease(ctx, &DescribeReleaseInput{Name: "2026.10"}); err != nil || len(described.Pins) != 1 {
+		t.Errorf("%s - describeRelease: %+v, %v", localStoreTestPrefix, described, err)
+	}
+}
@@ -0,0 +1,201 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/morezero/capabilities-registry/pkg/semver"
+)
+
+const (
+	lockLogPrefix   = "registry:lock"
+	lockfileVersion = 1
+	maxLockEntries  = 1000
+)
+
+// Lock resolves each requested capability ref against the local registry and returns a
+// lockfile with exact versions, subjects and content digests.
+func (r *Registry) Lock(ctx context.Context, input *LockInput) (*Lockfile, error) {
+	slog.Info(fmt.Sprintf("%s - lock caps=%d", lockLogPrefix, len(input.Caps)))
+
+	if err := r.requireCatalog(); err != nil {
+		return nil, err
+	}
+	if len(input.Caps) == 0 {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: "at least one cap is required"}
+	}
+	if len(input.Caps) > maxLockEntries {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("caps count exceeds maximum %d", maxLockEntries)}
+	}
+
+	defaultAlias := r.defaultAlias()
+	lock := &Lockfile{
+		LockfileVersion: lockfileVersion,
+		Generated:       time.Now().UTC().Format(time.RFC3339),
+		Ctx:             input.Ctx,
+		Entries:         make([]LockEntry, 0, len(input.Caps)),
+	}
+	for _, ref := range input.Caps {
+		alias, capRef := extractAlias(ref)
+		if alias != "" && alias != defaultAlias {
+			return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("lockfiles only cover local capabilities: %s", ref)}
+		}
+		if capRef == "" {
+			capRef = ref
+		}
+		// Lock the current capability name, even when the ref uses a capability alias
+		parsed, _, _, regErr := r.lookupCapabilityFollowingAliases(ctx, capRef)
+		if regErr != nil {
+			return nil, regErr
+		}
+
+		out, err := r.resolveLocal(ctx, &ResolveInput{
+			Cap:            capRef,
+			Ctx:            input.Ctx,
+			IncludeMethods: true,
+			IncludeSchemas: true,
+		}, defaultAlias, capRef)
+		if err != nil {
+			return nil, err
+		}
+		lock.Entries = append(lock.Entries, LockEntry{
+			Ref:               ref,
+			Cap:               parsed.Full,
+			CanonicalIdentity: out.CanonicalIdentity,
+			Version:           out.ResolvedVersion,
+			Major:             out.Major,
+			Subject:           out.Subject,
+			Status:            out.Status,
+			Digest:            lockDigest(out),
+		})
+	}
+	return lock, nil
+}
+
+// VerifyLock re-checks each lock entry against the live registry and reports entries whose
+// version became deprecated, disabled, yanked, missing or unavailable in the lock's env,
+// or whose content changed.
+// Deprecated and renamed entries are reported but do not make the lock invalid.
+func (r *Registry) VerifyLock(ctx context.Context, input *VerifyLockInput) (*VerifyLockOutput, error) {
+	slog.Info(fmt.Sprintf("%s - verifyLock entries=%d", lockLogPrefix, len(input.Lock.Entries)))
+
+	if err := r.requireCatalog(); err != nil {
+		return nil, err
+	}
+	if len(input.Lock.Entries) == 0 {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: "lock has no entries"}
+	}
+	if len(input.Lock.Entries) > maxLockEntries {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("lock entries exceed maximum %d", maxLockEntries)}
+	}
+
+	// Entries name exact versions, so verification must not be redirected by a release.
+	var rctx *ResolutionContext
+	if input.Lock.Ctx != nil {
+		c := *input.Lock.Ctx
+		c.Release = ""
+		rctx = &c
+	}
+
+	defaultAlias := r.defaultAlias()
+	out := &VerifyLockOutput{Valid: true, Issues: []LockIssue{}}
+	for _, entry := range input.Lock.Entries {
+		out.Checked++
+		parsed, err := semver.ParseCapabilityRef(entry.Cap)
+		if err != nil {
+			return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: err.Error()}
+		}
+		if !semver.IsExactVersion(entry.Version) {
+			return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("lock entry for %s must name an exact version", parsed.Full)}
+		}
+
+		issue := func(problem, message string) {
+			out.Issues = append(out.Issues, LockIssue{Cap: parsed.Full, Version: entry.Version, Problem: problem, Message: message})
+			if problem != "deprecated" && problem != "renamed" {
+				out.Valid = false
+			}
+		}
+
+		_, cap, warnings, regErr := r.lookupCapabilityFollowingAliases(ctx, parsed.Full)
+		if regErr != nil {
+			if regErr.Code != "NOT_FOUND" {
+				return nil, regErr
+			}
+			issue("missing", fmt.Sprintf("Capability not found: %s", parsed.Full))
+			continue
+		}
+		for _, w := range warnings {
+			issue(w.Code, w.Message)
+		}
+		versions, err := r.catalog.GetVersions(ctx, cap.ID)
+		if err != nil {
+			return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+		}
+		var status string
+		available := true
+		for _, rec := range dbVersionsToRecords(versions) {
+			if rec.VersionString == entry.Version {
+				status = rec.Status
+				available = rec.AvailableIn(r.getEnv(rctx))
+				break
+			}
+		}
+		switch status {
+		case "":
+			issue("yanked", fmt.Sprintf("Version %s no longer exists for: %s", entry.Version, parsed.Full))
+			continue
+		case "disabled":
+			issue("disabled", fmt.Sprintf("Version %s of %s is disabled", entry.Version, parsed.Full))
+			continue
+		case "deprecated":
+			issue("deprecated", fmt.Sprintf("Version %s of %s is deprecated", entry.Version, parsed.Full))
+		}
+		if !available {
+			issue("unavailable", fmt.Sprintf("Version %s of %s is not available in env %s", entry.Version, parsed.Full, r.getEnv(rctx)))
+			continue
+		}
+
+		if entry.Digest == "" {
+			continue
+		}
+		live, err := r.resolveLocal(ctx, &ResolveInput{
+			Cap:            parsed.Full,
+			Ver:            entry.Version,
+			Ctx:            rctx,
+			IncludeMethods: true,
+			IncludeSchemas: true,
+		}, defaultAlias, parsed.Full)
+		if err != nil {
+			// The version cannot be served to the lock's context (e.g. a tenant rule or the
+			// liveness policy); report it and keep checking the other entries
+			regErr, ok := err.(*RegistryError)
+			if !ok || regErr.Code == "INTERNAL_ERROR" {
+				return nil, err
+			}
+			issue("unavailable", fmt.Sprintf("Version %s of %s cannot be resolved: %s", entry.Version, parsed.Full, regErr.Message))
+			continue
+		}
+		if digest := lockDigest(live); digest != entry.Digest {
+			issue("changed", fmt.Sprintf("Content of %s@%s changed (digest %s, locked %s)", parsed.Full, entry.Version, digest, entry.Digest))
+		}
+	}
+	return out, nil
+}
+
+// lockDigest hashes the resolved version together with its methods and schemas.
+// Methods arrive sorted by name and map keys marshal sorted, so the digest is stable.
+func lockDigest(out *ResolveOutput) string {
+	payload := struct {
+		Version string            `json:"version"`
+		Methods []MethodInfo      `json:"methods"`
+		Schemas map[string]Schema `json:"schemas"`
+	}{out.ResolvedVersion, out.Methods, out.Schemas}
+	b, _ := json.Marshal(payload)
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
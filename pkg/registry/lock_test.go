@@ -0,0 +1,63 @@
+package registry
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const lockTestPrefix = "registry:lock_test"
+
+func TestLockMethods_RequireRepo(t *testing.T) {
+	reg := NewRegistry(NewRegistryParams{Repo: nil, Publisher: nil, Config: DefaultConfig()})
+	ctx := context.Background()
+
+	_, err := reg.Lock(ctx, &LockInput{Caps: []string{"more0.doc.ingest@^3"}})
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INTERNAL_ERROR" {
+		t.Errorf("%s - Lock: expected INTERNAL_ERROR, got %v", lockTestPrefix, err)
+	}
+	_, err = reg.VerifyLock(ctx, &VerifyLockInput{})
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INTERNAL_ERROR" {
+		t.Errorf("%s - VerifyLock: expected INTERNAL_ERROR, got %v", lockTestPrefix, err)
+	}
+}
+
+func TestLockDigest(t *testing.T) {
+	base := func() *ResolveOutput {
+		return &ResolveOutput{
+			ResolvedVersion: "3.2.1",
+			Methods:         []MethodInfo{{Name: "ingest", Modes: []string{"sync"}, Tags: []string{}}},
+			Schemas: map[string]Schema{
+				"ingest": {Input: map[string]interface{}{"type": "object"}, Output: map[string]interface{}{"type": "object"}},
+			},
+		}
+	}
+
+	d1 := lockDigest(base())
+	if !strings.HasPrefix(d1, "sha256:") || len(d1) != len("sha256:")+64 {
+		t.Fatalf("%s - unexpected digest format %q", lockTestPrefix, d1)
+	}
+	if d2 := lockDigest(base()); d2 != d1 {
+		t.Errorf("%s - digest not stable: %q != %q", lockTestPrefix, d1, d2)
+	}
+
+	changedVersion := base()
+	changedVersion.ResolvedVersion = "3.2.2"
+	if lockDigest(changedVersion) == d1 {
+		t.Errorf("%s - digest should change with version", lockTestPrefix)
+	}
+
+	changedSchema := base()
+	changedSchema.Schemas["ingest"].Input["required"] = []string{"url"}
+	if lockDigest(changedSchema) == d1 {
+		t.Errorf("%s - digest should change with schema", lockTestPrefix)
+	}
+
+	// Fields outside the content (subject, ttl) must not affect the digest.
+	other := base()
+	other.Subject = "cap.more0.doc_ingest.v3"
+	other.TTLSeconds = 60
+	if lockDigest(other) != d1 {
+		t.Errorf("%s - digest should ignore subject and ttl", lockTestPrefix)
+	}
+}
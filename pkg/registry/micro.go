@@ -0,0 +1,281 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	masterminds "github.com/Masterminds/semver/v3"
+	comms "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+
+	"github.com/morezero/capabilities-registry/pkg/commsutil"
+	"github.com/morezero/capabilities-registry/pkg/db"
+)
+
+const (
+	microLogPrefix = "registry:micro"
+	// microImportUserID is recorded as created_by/modified_by of imported entries.
+	microImportUserID = "00000000-0000-0000-0000-000000000001"
+	// microEndpointMethodKey is the endpoint metadata key that overrides the method name.
+	microEndpointMethodKey = "method"
+)
+
+// microService is one capability version discovered through $SRV.INFO, merged across the
+// instances (and services) that report it.
+type microService struct {
+	ServiceName     string
+	App             string
+	Name            string
+	Version         *masterminds.Version
+	Description     string
+	SubjectTemplate string
+	Methods         []string
+}
+
+// ImportMicroServices asks every NATS micro service for $SRV.INFO and upserts each reported
+// service version as a capability version whose methods are the service endpoints. Imported
+// capabilities have source "micro". Capabilities registered through upsert are never
+// overwritten, and versions whose methods did not change are left alone.
+func (r *Registry) ImportMicroServices(ctx context.Context) (*MicroImportOutput, error) {
+	if err := r.requireRepo(); err != nil {
+		return nil, err
+	}
+	if r.nc == nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: "micro service import is not configured"}
+	}
+
+	infos, err := collectMicroInfo(ctx, r.nc, r.config.ProbeTimeout)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	services, skipped := groupMicroServices(infos)
+
+	out := &MicroImportOutput{Services: len(infos), Imported: []string{}, Skipped: skipped}
+	for _, svc := range services {
+		ref := fmt.Sprintf("%s.%s@%s", svc.App, svc.Name, svc.Version.String())
+		imported, reason, regErr := r.importMicroService(ctx, svc)
+		switch {
+		case regErr != nil:
+			out.Skipped = append(out.Skipped, MicroImportSkip{Service: svc.ServiceName, Reason: regErr.Message})
+		case reason != "":
+			out.Skipped = append(out.Skipped, MicroImportSkip{Service: svc.ServiceName, Reason: reason})
+		case imported:
+			out.Imported = append(out.Imported, ref)
+		default:
+			out.Unchanged++
+		}
+	}
+	slog.Info(fmt.Sprintf("%s - import: %d service instances, %d imported, %d unchanged, %d skipped",
+		microLogPrefix, out.Services, len(out.Imported), out.Unchanged, len(out.Skipped)))
+	return out, nil
+}
+
+// StartMicroImporter runs ImportMicroServices every interval until ctx is cancelled. It does
+// nothing without a repository, without a COMMS connection or with a non-positive interval.
+func (r *Registry) StartMicroImporter(ctx context.Context, interval time.Duration) {
+	if r.repo == nil || r.nc == nil || interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := r.ImportMicroServices(ctx); err != nil {
+					slog.Error(fmt.Sprintf("%s - import failed: %v", microLogPrefix, err))
+				}
+			}
+		}
+	}()
+}
+
+// importMicroService upserts one discovered service version. It returns imported=false with
+// an empty reason when the version is already registered with the same methods.
+func (r *Registry) importMicroService(ctx context.Context, svc microService) (imported bool, reason string, regErr *RegistryError) {
+	existing, err := r.repo.GetCapability(ctx, svc.App, svc.Name)
+	if err != nil {
+		return false, "", &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	if existing != nil && existing.Source != db.CapabilitySourceMicro {
+		return false, fmt.Sprintf("%s.%s is registered through upsert", svc.App, svc.Name), nil
+	}
+
+	var prerelease *string
+	if pre := svc.Version.Prerelease(); pre != "" {
+		prerelease = &pre
+	}
+	if existing != nil {
+		version, err := r.repo.GetVersion(ctx, db.GetVersionParams{
+			CapabilityID: existing.ID,
+			Major:        int(svc.Version.Major()),
+			Minor:        int(svc.Version.Minor()),
+			Patch:        int(svc.Version.Patch()),
+			Prerelease:   prerelease,
+		})
+		if err != nil {
+			return false, "", &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+		}
+		if version != nil {
+			methods, err := r.repo.GetMethods(ctx, version.ID)
+			if err != nil {
+				return false, "", &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+			}
+			if sameMethodNames(methods, svc.Methods) {
+				return false, "", nil
+			}
+		}
+	}
+
+	methods := make([]MethodDefinition, len(svc.Methods))
+	for i, m := range svc.Methods {
+		methods[i] = MethodDefinition{Name: m, Modes: []string{"sync"}}
+	}
+	_, err = r.Upsert(ctx, &UpsertInput{
+		App:         svc.App,
+		Name:        svc.Name,
+		Description: svc.Description,
+		Version: VersionInput{
+			Major:       int(svc.Version.Major()),
+			Minor:       int(svc.Version.Minor()),
+			Patch:       int(svc.Version.Patch()),
+			Prerelease:  svc.Version.Prerelease(),
+			Description: svc.Description,
+			Metadata:    map[string]interface{}{"importedFrom": "micro", "service": svc.ServiceName},
+		},
+		Methods:         methods,
+		SetAsDefault:    existing == nil,
+		SubjectTemplate: svc.SubjectTemplate,
+		source:          db.CapabilitySourceMicro,
+	}, microImportUserID)
+	if err != nil {
+		if e, ok := err.(*RegistryError); ok {
+			return false, "", e
+		}
+		return false, "", &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	return true, "", nil
+}
+
+// collectMicroInfo publishes one $SRV.INFO request and gathers the replies that arrive
+// within wait.
+func collectMicroInfo(ctx context.Context, nc *comms.Conn, wait time.Duration) ([]micro.Info, error) {
+	subject, err := micro.ControlSubject(micro.InfoVerb, "", "")
+	if err != nil {
+		return nil, err
+	}
+	inbox := nc.NewInbox()
+	sub, err := nc.SubscribeSync(inbox)
+	if err != nil {
+		return nil, fmt.Errorf("%s - subscribe failed: %w", microLogPrefix, err)
+	}
+	defer sub.Unsubscribe()
+	if err := nc.PublishRequest(subject, inbox, nil); err != nil {
+		return nil, fmt.Errorf("%s - %s request failed: %w", microLogPrefix, subject, err)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	var infos []micro.Info
+	for {
+		msg, err := sub.NextMsgWithContext(waitCtx)
+		if err != nil {
+			if waitCtx.Err() != nil {
+				return infos, nil
+			}
+			return infos, fmt.Errorf("%s - reading %s replies failed: %w", microLogPrefix, subject, err)
+		}
+		var info micro.Info
+		if err := json.Unmarshal(msg.Data, &info); err != nil || info.Type != micro.InfoResponseType {
+			slog.Debug(fmt.Sprintf("%s - ignoring malformed %s reply", microLogPrefix, subject))
+			continue
+		}
+		infos = append(infos, info)
+	}
+}
+
+// groupMicroServices maps INFO replies to capability versions, merging instances of the same
+// service version and unioning their endpoints. Services that cannot be mapped are skipped.
+func groupMicroServices(infos []micro.Info) ([]microService, []MicroImportSkip) {
+	byKey := make(map[string]*microService)
+	var keys []string
+	var skipped []MicroImportSkip
+	for _, info := range infos {
+		app, name, ok := commsutil.CapabilityFromMicroService(info.Name, info.Metadata)
+		if !ok {
+			skipped = append(skipped, MicroImportSkip{Service: info.Name, Reason: "service name does not map to app_name and has no capability metadata"})
+			continue
+		}
+		version, err := masterminds.StrictNewVersion(info.Version)
+		if err != nil {
+			skipped = append(skipped, MicroImportSkip{Service: info.Name, Reason: fmt.Sprintf("invalid version %q", info.Version)})
+			continue
+		}
+		key := fmt.Sprintf("%s.%s@%s", app, name, version.String())
+		svc, ok := byKey[key]
+		if !ok {
+			svc = &microService{
+				ServiceName:     info.Name,
+				App:             app,
+				Name:            name,
+				Version:         version,
+				Description:     info.Description,
+				SubjectTemplate: info.Metadata[commsutil.MicroMetadataSubjectTemplate],
+			}
+			byKey[key] = svc
+			keys = append(keys, key)
+		}
+		for _, ep := range info.Endpoints {
+			method := ep.Name
+			if m := ep.Metadata[microEndpointMethodKey]; m != "" {
+				method = m
+			}
+			svc.Methods = appendUnique(svc.Methods, method)
+		}
+	}
+
+	sort.Strings(keys)
+	out := make([]microService, 0, len(keys))
+	for _, k := range keys {
+		svc := byKey[k]
+		if len(svc.Methods) == 0 {
+			skipped = append(skipped, MicroImportSkip{Service: svc.ServiceName, Reason: "service has no endpoints"})
+			continue
+		}
+		sort.Strings(svc.Methods)
+		out = append(out, *svc)
+	}
+	return out, skipped
+}
+
+// sameMethodNames reports whether the stored methods are exactly the given method names.
+func sameMethodNames(stored []db.CapabilityMethod, names []string) bool {
+	if len(stored) != len(names) {
+		return false
+	}
+	want := make(map[string]bool, len(names))
+	for _, n := range names {
+		want[n] = true
+	}
+	for _, m := range stored {
+		if !want[m.Name] {
+			return false
+		}
+	}
+	return true
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,102 @@
+package registry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go/micro"
+
+	"github.com/morezero/capabilities-registry/pkg/commsutil"
+	"github.com/morezero/capabilities-registry/pkg/db"
+)
+
+const microTestPrefix = "registry:micro_test"
+
+func microInfo(name, version string, metadata map[string]string, endpoints ...string) micro.Info {
+	info := micro.Info{
+		ServiceIdentity: micro.ServiceIdentity{Name: name, Version: version, Metadata: metadata},
+		Type:            micro.InfoResponseType,
+	}
+	for _, e := range endpoints {
+		info.Endpoints = append(info.Endpoints, micro.EndpointInfo{Name: e})
+	}
+	return info
+}
+
+func TestGroupMicroServices(t *testing.T) {
+	infos := []micro.Info{
+		microInfo("more0_doc_ingest", "1.2.0", nil, "ingest"),
+		// Second instance of the same version with one more endpoint: merged
+		microInfo("more0_doc_ingest", "1.2.0", nil, "ingest", "status"),
+		microInfo("worker", "2.0.0", map[string]string{commsutil.MicroMetadataCapability: "more0.worker"}, "run"),
+		microInfo("nameless", "1.0.0", nil, "run"),
+		microInfo("more0_bad", "v1", nil, "run"),
+		microInfo("more0_empty", "1.0.0", nil),
+	}
+
+	services, skipped := groupMicroServices(infos)
+
+	if len(services) != 2 {
+		t.Fatalf("%s - expected 2 services, got %+v", microTestPrefix, services)
+	}
+	ingest := services[0]
+	if ingest.App != "more0" || ingest.Name != "doc.ingest" || ingest.Version.String() != "1.2.0" {
+		t.Errorf("%s - unexpected first service: %+v", microTestPrefix, ingest)
+	}
+	if len(ingest.Methods) != 2 || ingest.Methods[0] != "ingest" || ingest.Methods[1] != "status" {
+		t.Errorf("%s - expected merged methods [ingest status], got %v", microTestPrefix, ingest.Methods)
+	}
+	if services[1].App != "more0" || services[1].Name != "worker" {
+		t.Errorf("%s - expected metadata capability more0.worker, got %+v", microTestPrefix, services[1])
+	}
+	if len(skipped) != 3 {
+		t.Errorf("%s - expected 3 skipped services, got %+v", microTestPrefix, skipped)
+	}
+}
+
+func TestSameMethodNames(t *testing.T) {
+	stored := []db.CapabilityMethod{{Name: "run"}, {Name: "status"}}
+	if !sameMethodNames(stored, []string{"status", "run"}) {
+		t.Errorf("%s - expected same methods regardless of order", microTestPrefix)
+	}
+	if sameMethodNames(stored, []string{"run"}) || sameMethodNames(stored, []string{"run", "stop"}) {
+		t.Errorf("%s - expected different methods", microTestPrefix)
+	}
+}
+
+func TestCollectMicroInfo(t *testing.T) {
+	nc := startProbeServer(t)
+	svc, err := micro.AddService(nc, micro.Config{
+		Name:    "more0_doc_ingest",
+		Version: "1.0.0",
+		Endpoint: &micro.EndpointConfig{
+			Subject: "cap.more0.doc_ingest.v1",
+			Handler: micro.HandlerFunc(func(req micro.Request) { _ = req.Respond(nil) }),
+		},
+	})
+	if err != nil {
+		t.Fatalf("%s - AddService failed: %v", microTestPrefix, err)
+	}
+	defer svc.Stop()
+
+	infos, err := collectMicroInfo(context.Background(), nc, 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("%s - collectMicroInfo failed: %v", microTestPrefix, err)
+	}
+	if len(infos) != 1 || infos[0].Name != "more0_doc_ingest" || len(infos[0].Endpoints) != 1 {
+		t.Errorf("%s - unexpected infos: %+v", microTestPrefix, infos)
+	}
+}
+
+func TestImportMicroServices_RequiresRepoAndConn(t *testing.T) {
+	reg := NewRegistry(NewRegistryParams{Repo: nil, Publisher: nil, Config: DefaultConfig()})
+	ctx := context.Background()
+
+	_, err := reg.ImportMicroServices(ctx)
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INTERNAL_ERROR" {
+		t.Errorf("%s - expected INTERNAL_ERROR, got %v", microTestPrefix, err)
+	}
+	// No repo and no connection: the importer does not start.
+	reg.StartMicroImporter(ctx, time.Millisecond)
+}
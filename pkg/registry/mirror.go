@@ -0,0 +1,480 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	comms "github.com/nats-io/nats.go"
+
+	"github.com/morezero/capabilities-registry/pkg/db"
+	"github.com/morezero/capabilities-registry/pkg/events"
+	"github.com/morezero/capabilities-registry/pkg/semver"
+)
+
+const (
+	mirrorLogPrefix = "registry:mirror"
+	// mirrorUserID is recorded as created_by/modified_by of mirrored entries.
+	mirrorUserID = "00000000-0000-0000-0000-000000000002"
+	// mirrorPageLimit is the exportCatalog page size of a full sync.
+	mirrorPageLimit = exportMaxLimit
+	// mirrorRequestTimeout bounds each exportCatalog request to the upstream.
+	mirrorRequestTimeout = 30 * time.Second
+
+	// Replication states reported in MirrorHealth.
+	MirrorSyncing = "syncing"
+	MirrorCurrent = "current"
+	MirrorLagging = "lagging"
+)
+
+// mirrorState is the replication state of a registry in mirror mode.
+type mirrorState struct {
+	mu sync.Mutex
+	// applied is the catalogFingerprint last applied per "app.name"; a capability whose
+	// export has the same fingerprint is not written again.
+	applied map[string]string
+	// nc is the upstream connection changes is subscribed on, and reconnects its reconnect
+	// count at the last full sync: a reconnect since then may have lost change events.
+	nc         *comms.Conn
+	changes    *comms.Subscription
+	reconnects uint64
+	// syncStarted is when the last successful full sync started, synced when it finished.
+	syncStarted time.Time
+	synced      time.Time
+	lastEvent   time.Time
+	// missed is set when a change event could not be applied since the last sync.
+	missed bool
+	// upstreamSeq is the last upstream change sequence the mirror applied; 0 before the
+	// first full sync or when the upstream does not serve changesSince.
+	upstreamSeq  int64
+	capabilities int
+	lastError    string
+	now          func() time.Time
+}
+
+func newMirrorState() *mirrorState {
+	return &mirrorState{applied: make(map[string]string), now: time.Now}
+}
+
+// ReadOnly reports whether the registry is a mirror, which rejects catalog mutations.
+func (r *Registry) ReadOnly() bool {
+	return r.config.MirrorUpstream != ""
+}
+
+// SyncMirror brings the catalog store up to date with the upstream registry alias and
+// subscribes to the upstream's change events so each changed capability is copied as soon
+// as it changes. Once the mirror knows the upstream's change sequence it catches up through
+// changesSince; the first sync, and any sync after the upstream pruned the changes the
+// mirror missed, copies the full catalog page by page through exportCatalog. Capabilities
+// already at the upstream revision are skipped; capabilities registered locally (not by the
+// mirror) are never overwritten. Every sync then copies the cells, tenant cells and
+// releases. What was copied is flushed to the store even when the sync fails part way.
+func (r *Registry) SyncMirror(ctx context.Context) error {
+	if err := r.requireCatalog(); err != nil {
+		return err
+	}
+	if r.mirror == nil || r.federationPool == nil {
+		return &RegistryError{Code: "INTERNAL_ERROR", Message: "mirror mode is not configured"}
+	}
+	m := r.mirror
+	started := m.now()
+
+	err := r.syncMirror(ctx)
+	if flushErr := r.catalog.Flush(ctx); flushErr != nil && err == nil {
+		err = flushErr
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		m.lastError = err.Error()
+		slog.Error(fmt.Sprintf("%s - sync from %s failed: %v", mirrorLogPrefix, r.config.MirrorUpstream, err))
+		return err
+	}
+	m.syncStarted, m.synced = started, m.now()
+	m.missed, m.lastError = false, ""
+	if m.nc != nil {
+		m.reconnects = m.nc.Stats().Reconnects
+	}
+	slog.Info(fmt.Sprintf("%s - synced from %s at sequence %d", mirrorLogPrefix, r.config.MirrorUpstream, m.upstreamSeq))
+	return nil
+}
+
+// syncMirror subscribes to the upstream's change events, then catches up through
+// changesSince or, when that is not possible, applies every exported page.
+func (r *Registry) syncMirror(ctx context.Context) error {
+	entry, regErr := r.federationPool.lookupAlias(ctx, r.config.MirrorUpstream)
+	if regErr != nil {
+		return regErr
+	}
+	nc, err := r.federationPool.getOrConnect(entry)
+	if err != nil {
+		return &RegistryError{Code: "REGISTRY_UNAVAILABLE", Message: fmt.Sprintf("Failed to connect to upstream registry %s: %v", entry.Alias, err)}
+	}
+	if err := r.subscribeUpstream(nc, parseRemoteConfig(entry).ChangeEventSubject); err != nil {
+		return &RegistryError{Code: "REGISTRY_UNAVAILABLE", Message: fmt.Sprintf("Failed to subscribe to change events of %s: %v", entry.Alias, err)}
+	}
+
+	caughtUp, err := r.mirrorCatchUp(ctx, entry)
+	if err != nil {
+		return err
+	}
+	if caughtUp {
+		return r.mirrorRouting(ctx, entry)
+	}
+
+	// Changes after this sequence are either in the export or arrive as events
+	latest := r.upstreamSequence(ctx, entry)
+	count := 0
+	for page := 1; ; page++ {
+		out, err := r.exportUpstream(ctx, entry, &ExportCatalogInput{Page: page, Limit: mirrorPageLimit})
+		if err != nil {
+			return err
+		}
+		for _, c := range out.Capabilities {
+			if regErr := r.applyCatalogCapability(ctx, c); regErr != nil {
+				return regErr
+			}
+		}
+		count += len(out.Capabilities)
+		if len(out.Capabilities) == 0 || page >= out.Pagination.TotalPages {
+			break
+		}
+	}
+
+	if err := r.mirrorRouting(ctx, entry); err != nil {
+		return err
+	}
+
+	r.mirror.mu.Lock()
+	r.mirror.capabilities = count
+	r.mirror.mu.Unlock()
+	r.mirror.advanceSequence(latest)
+	return nil
+}
+
+// mirrorCatchUp copies the capabilities changed upstream after the last sequence the mirror
+// applied, and the routing when a tenant cell changed. It returns false when the mirror has no sequence yet or the upstream answers
+// resync, so a full sync is needed.
+func (r *Registry) mirrorCatchUp(ctx context.Context, entry *db.RegistryEntry) (bool, error) {
+	r.mirror.mu.Lock()
+	since := r.mirror.upstreamSeq
+	r.mirror.mu.Unlock()
+	if since == 0 {
+		return false, nil
+	}
+	for {
+		reqCtx, cancel := context.WithTimeout(ctx, mirrorRequestTimeout)
+		var out ChangesSinceOutput
+		err := r.federationPool.callEntry(reqCtx, entry, "changesSince", &ChangesSinceInput{Since: since, Limit: changesMaxLimit}, &out)
+		cancel()
+		if err != nil {
+			return false, err
+		}
+		if out.Resync {
+			slog.Warn(fmt.Sprintf("%s - changes after %d are no longer available from %s; copying the full catalog", mirrorLogPrefix, since, entry.Alias))
+			return false, nil
+		}
+		var changed []string
+		routing := false
+		for _, event := range out.Changes {
+			if event.App == remoteRegistryEventApp && event.Capability == remoteRegistryEventCapability {
+				routing = true
+				continue
+			}
+			if ref := event.App + "." + event.Capability; !slices.Contains(changed, ref) {
+				changed = append(changed, ref)
+			}
+		}
+		for _, ref := range changed {
+			if err := r.mirrorCapability(ctx, entry, ref); err != nil {
+				return false, err
+			}
+		}
+		if routing {
+			if err := r.mirrorRouting(ctx, entry); err != nil {
+				return false, err
+			}
+		}
+		since = out.Next
+		r.mirror.advanceSequence(since)
+		if !out.HasMore {
+			return true, nil
+		}
+	}
+}
+
+// mirrorCapability copies one capability ("app.name") from the upstream.
+func (r *Registry) mirrorCapability(ctx context.Context, entry *db.RegistryEntry, ref string) error {
+	out, err := r.exportUpstream(ctx, entry, &ExportCatalogInput{Cap: ref})
+	if err != nil {
+		return err
+	}
+	for _, c := range out.Capabilities {
+		if regErr := r.applyCatalogCapability(ctx, c); regErr != nil {
+			return regErr
+		}
+	}
+	return nil
+}
+
+// mirrorRouting copies the upstream's cells, tenant cells and releases. An upstream that
+// does not export them answers without routing, which leaves the store's copy alone.
+func (r *Registry) mirrorRouting(ctx context.Context, entry *db.RegistryEntry) error {
+	out, err := r.exportUpstream(ctx, entry, &ExportCatalogInput{Routing: true})
+	if err != nil {
+		return err
+	}
+	if out.Routing == nil {
+		return nil
+	}
+	if err := r.catalog.ApplyCatalogRouting(ctx, *out.Routing); err != nil {
+		return &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	return nil
+}
+
+// upstreamSequence returns the upstream's latest change sequence, or 0 when it does not
+// serve changesSince.
+func (r *Registry) upstreamSequence(ctx context.Context, entry *db.RegistryEntry) int64 {
+	reqCtx, cancel := context.WithTimeout(ctx, mirrorRequestTimeout)
+	defer cancel()
+	var out ChangesSinceOutput
+	if err := r.federationPool.callEntry(reqCtx, entry, "changesSince", &ChangesSinceInput{Limit: 1}, &out); err != nil {
+		slog.Warn(fmt.Sprintf("%s - %s does not serve changesSince; every sync copies the full catalog: %v", mirrorLogPrefix, entry.Alias, err))
+		return 0
+	}
+	return out.Latest
+}
+
+// exportUpstream requests one exportCatalog page from the upstream.
+func (r *Registry) exportUpstream(ctx context.Context, entry *db.RegistryEntry, input *ExportCatalogInput) (*ExportCatalogOutput, error) {
+	reqCtx, cancel := context.WithTimeout(ctx, mirrorRequestTimeout)
+	defer cancel()
+	var out ExportCatalogOutput
+	if err := r.federationPool.callEntry(reqCtx, entry, "exportCatalog", input, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// subscribeUpstream subscribes to change events on the upstream connection nc, replacing
+// the subscription of an earlier connection.
+func (r *Registry) subscribeUpstream(nc *comms.Conn, subject string) error {
+	m := r.mirror
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.nc == nc && m.changes != nil && m.changes.IsValid() {
+		return nil
+	}
+	if m.changes != nil {
+		_ = m.changes.Unsubscribe()
+	}
+	if subject == "" {
+		subject = remoteChangeSubject
+	}
+	sub, err := nc.Subscribe(subject, r.onUpstreamChange)
+	if err != nil {
+		m.nc, m.changes = nil, nil
+		return err
+	}
+	m.nc, m.changes = nc, sub
+	// A new connection may have missed events; the sync in progress catches up
+	m.reconnects = nc.Stats().Reconnects
+	return nil
+}
+
+// onUpstreamChange copies the capability of an upstream change event. An event whose
+// sequence skips ahead of the last one applied means events were missed: the mirror
+// backfills them through changesSince. A registry-level event (tenant cells) copies the
+// routing instead. A failure leaves the mirror lagging until the next sync.
+func (r *Registry) onUpstreamChange(msg *comms.Msg) {
+	event, err := events.DecodeChangeEvent(msg)
+	if err != nil || event.App == "" || event.Capability == "" {
+		return
+	}
+	m := r.mirror
+	m.mu.Lock()
+	last := m.upstreamSeq
+	m.mu.Unlock()
+	if event.Sequence > 0 && event.Sequence <= last {
+		// Already applied by a catch-up
+		return
+	}
+
+	ctx := context.Background()
+	err = func() error {
+		entry, regErr := r.federationPool.lookupAlias(ctx, r.config.MirrorUpstream)
+		if regErr != nil {
+			return regErr
+		}
+		if event.Sequence > last+1 && last > 0 {
+			slog.Warn(fmt.Sprintf("%s - missed changes %d to %d of %s; backfilling", mirrorLogPrefix, last+1, event.Sequence-1, entry.Alias))
+			caughtUp, err := r.mirrorCatchUp(ctx, entry)
+			if err != nil {
+				return err
+			}
+			if !caughtUp {
+				return fmt.Errorf("changes after %d are no longer available; waiting for the next full sync", last)
+			}
+			return nil
+		}
+		if event.App == remoteRegistryEventApp && event.Capability == remoteRegistryEventCapability {
+			if err := r.mirrorRouting(ctx, entry); err != nil {
+				return err
+			}
+		} else if err := r.mirrorCapability(ctx, entry, event.App+"."+event.Capability); err != nil {
+			return err
+		}
+		m.advanceSequence(event.Sequence)
+		return nil
+	}()
+	if flushErr := r.catalog.Flush(ctx); flushErr != nil && err == nil {
+		err = flushErr
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastEvent = m.now()
+	if err != nil {
+		m.missed, m.lastError = true, err.Error()
+		slog.Error(fmt.Sprintf("%s - applying change of %s.%s failed: %v", mirrorLogPrefix, event.App, event.Capability, err))
+	}
+}
+
+// applyCatalogCapability writes one exported capability with its versions, methods,
+// endpoints, statuses, defaults, tenant rules, shadow and aliases to the catalog store and publishes a change event.
+// Versions and defaults that no longer exist upstream are left in place.
+func (r *Registry) applyCatalogCapability(ctx context.Context, c CatalogCapability) *RegistryError {
+	key := c.App + "." + c.Name
+	fingerprint := catalogFingerprint(c)
+	m := r.mirror
+	m.mu.Lock()
+	applied, seen := m.applied[key]
+	m.mu.Unlock()
+	if seen && applied == fingerprint {
+		return nil
+	}
+
+	cap, err := r.catalog.ApplyCatalogCapability(ctx, c)
+	if err != nil {
+		return &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	if cap == nil {
+		// Registered locally: never overwritten
+		m.setApplied(key, fingerprint)
+		return nil
+	}
+
+	var majors []int
+	var versions []string
+	for _, v := range c.Versions {
+		if !slices.Contains(majors, v.Major) {
+			majors = append(majors, v.Major)
+		}
+		versions = append(versions, semver.ToVersionString(v.Major, v.Minor, v.Patch, v.Prerelease))
+	}
+	if err := r.publisher.PublishChanged(ctx, &events.RegistryChangedEvent{
+		Kind:             events.KindMirrored,
+		App:              c.App,
+		Capability:       c.Name,
+		ChangedFields:    []string{"version", "methods", "endpoints", "status", "defaults"},
+		AffectedMajors:   majors,
+		AffectedVersions: versions,
+		Revision:         cap.Revision,
+		Etag:             fmt.Sprintf("%s-%d", cap.ID, cap.Revision),
+		Timestamp:        time.Now().UTC().Format(time.RFC3339),
+	}); err != nil {
+		slog.Error(fmt.Sprintf("%s - PublishChanged failed: %v", mirrorLogPrefix, err))
+	}
+	m.setApplied(key, fingerprint)
+	return nil
+}
+
+// advanceSequence records seq as applied unless a later sequence already is.
+func (m *mirrorState) advanceSequence(seq int64) {
+	m.mu.Lock()
+	if seq > m.upstreamSeq {
+		m.upstreamSeq = seq
+	}
+	m.mu.Unlock()
+}
+
+func (m *mirrorState) setApplied(key, fingerprint string) {
+	m.mu.Lock()
+	m.applied[key] = fingerprint
+	m.mu.Unlock()
+}
+
+// catalogFingerprint identifies an exported capability for the applied cache: its upstream
+// revision and, since tenant rules change without a new revision, a digest of its rules.
+func catalogFingerprint(c CatalogCapability) string {
+	rules, _ := json.Marshal(c.TenantRules)
+	sum := sha256.Sum256(rules)
+	return fmt.Sprintf("%d:%x", c.Revision, sum[:8])
+}
+
+// StartMirror runs SyncMirror now and then every interval until ctx is cancelled; change
+// events keep the mirror current in between. A non-positive interval syncs only once. It
+// does nothing without a catalog store or outside mirror mode.
+func (r *Registry) StartMirror(ctx context.Context, interval time.Duration) {
+	if r.catalog == nil || r.mirror == nil {
+		return
+	}
+	go func() {
+		_ = r.SyncMirror(ctx)
+		if interval <= 0 {
+			return
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				_ = r.SyncMirror(ctx)
+			}
+		}
+	}()
+}
+
+// mirrorHealth returns the replication state reported by health, or nil outside mirror mode.
+// The mirror is current while every change event since the last full sync was applied over
+// the same unbroken connection; otherwise its lag is the time since that sync started.
+func (r *Registry) mirrorHealth() *MirrorHealth {
+	m := r.mirror
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h := &MirrorHealth{
+		Upstream:     r.config.MirrorUpstream,
+		Status:       MirrorSyncing,
+		Capabilities: m.capabilities,
+		LastError:    m.lastError,
+	}
+	if !m.lastEvent.IsZero() {
+		h.LastEvent = m.lastEvent.UTC().Format(time.RFC3339)
+	}
+	if m.synced.IsZero() {
+		return h
+	}
+	h.LastSync = m.synced.UTC().Format(time.RFC3339)
+	lag := 0
+	connected := m.nc != nil && m.nc.IsConnected() && m.nc.Stats().Reconnects == m.reconnects
+	if connected && !m.missed {
+		h.Status = MirrorCurrent
+	} else {
+		h.Status = MirrorLagging
+		lag = int(m.now().Sub(m.syncStarted).Seconds())
+	}
+	h.LagSeconds = &lag
+	return h
+}
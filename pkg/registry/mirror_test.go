@@ -0,0 +1,129 @@
+package registry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/morezero/capabilities-registry/pkg/db"
+)
+
+const mirrorTestPrefix = "registry:mirror_test"
+
+func newMirrorRegistry() *Registry {
+	cfg := DefaultConfig()
+	cfg.MirrorUpstream = "upstream"
+	return NewRegistry(NewRegistryParams{Config: cfg})
+}
+
+func TestReadOnly(t *testing.T) {
+	if NewRegistry(NewRegistryParams{Config: DefaultConfig()}).ReadOnly() {
+		t.Errorf("%s - a registry without an upstream must be writable", mirrorTestPrefix)
+	}
+	if !newMirrorRegistry().ReadOnly() {
+		t.Errorf("%s - a mirror must be read-only", mirrorTestPrefix)
+	}
+}
+
+func TestExportCatalog_RequiresRepo(t *testing.T) {
+	r := NewRegistry(NewRegistryParams{Config: DefaultConfig()})
+	_, err := r.ExportCatalog(context.Background(), &ExportCatalogInput{})
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INTERNAL_ERROR" {
+		t.Errorf("%s - expected INTERNAL_ERROR, got %v", mirrorTestPrefix, err)
+	}
+}
+
+func TestSyncMirror_RequiresCatalog(t *testing.T) {
+	err := newMirrorRegistry().SyncMirror(context.Background())
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INTERNAL_ERROR" {
+		t.Errorf("%s - expected INTERNAL_ERROR, got %v", mirrorTestPrefix, err)
+	}
+}
+
+func TestMirrorHealth(t *testing.T) {
+	if NewRegistry(NewRegistryParams{Config: DefaultConfig()}).mirrorHealth() != nil {
+		t.Errorf("%s - expected no mirror health outside mirror mode", mirrorTestPrefix)
+	}
+
+	r := newMirrorRegistry()
+	m := r.mirror
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	if h := r.mirrorHealth(); h.Status != MirrorSyncing || h.LagSeconds != nil || h.Upstream != "upstream" {
+		t.Errorf("%s - before the first sync: %+v", mirrorTestPrefix, h)
+	}
+
+	nc := startProbeServer(t)
+	m.nc, m.reconnects = nc, nc.Stats().Reconnects
+	m.syncStarted, m.synced, m.capabilities = now.Add(-5*time.Second), now, 12
+	if h := r.mirrorHealth(); h.Status != MirrorCurrent || h.LagSeconds == nil || *h.LagSeconds != 0 || h.Capabilities != 12 {
+		t.Errorf("%s - after a sync over a live connection: %+v", mirrorTestPrefix, h)
+	}
+
+	m.missed = true
+	now = now.Add(time.Minute)
+	if h := r.mirrorHealth(); h.Status != MirrorLagging || h.LagSeconds == nil || *h.LagSeconds != 65 {
+		t.Errorf("%s - after a missed event: %+v, want lagging by 65s", mirrorTestPrefix, h)
+	}
+
+	m.missed = false
+	nc.Close()
+	if h := r.mirrorHealth(); h.Status != MirrorLagging {
+		t.Errorf("%s - after losing the upstream connection: %+v, want lagging", mirrorTestPrefix, h)
+	}
+}
+
+func TestCatalogVersion(t *testing.T) {
+	pre, reason := "beta.1", "use v2"
+	v := db.CapabilityVersion{
+		Major: 1, Minor: 2, Patch: 3, Prerelease: &pre, Status: "deprecated", DeprecationReason: &reason,
+		Metadata: []byte(`{"team":"billing"}`), Envs: []string{"staging"},
+	}
+	methods := []db.CapabilityMethod{{Name: "create", InputSchema: []byte(`{"type":"object"}`), Modes: []string{"sync"}}}
+	region := "eu-west"
+	endpoints := []db.CapabilityEndpoint{{NatsUrl: "nats://eu.example:4222", Region: &region, Priority: 1, Weight: 50}}
+
+	cv := catalogVersion(v, methods, endpoints)
+	if cv.Prerelease != "beta.1" || cv.Status != "deprecated" || cv.DeprecationReason != "use v2" || cv.Metadata["team"] != "billing" {
+		t.Errorf("%s - catalogVersion = %+v", mirrorTestPrefix, cv)
+	}
+	if len(cv.Methods) != 1 || cv.Methods[0].InputSchema["type"] != "object" {
+		t.Errorf("%s - methods = %+v", mirrorTestPrefix, cv.Methods)
+	}
+	if len(cv.Endpoints) != 1 || cv.Endpoints[0].Region != "eu-west" || cv.Endpoints[0].Weight == nil || *cv.Endpoints[0].Weight != 50 {
+		t.Errorf("%s - endpoints = %+v", mirrorTestPrefix, cv.Endpoints)
+	}
+	if params := endpointParams(cv.Endpoints); params[0].Weight != 50 || *params[0].Region != "eu-west" {
+		t.Errorf("%s - exported endpoints should round-trip, got %+v", mirrorTestPrefix, params[0])
+	}
+}
+
+func TestCatalogFingerprint(t *testing.T) {
+	c := CatalogCapability{App: "more0", Name: "doc.ingest", Revision: 4}
+	before := catalogFingerprint(c)
+	if catalogFingerprint(c) != before {
+		t.Fatalf("%s - fingerprint must be stable", mirrorTestPrefix)
+	}
+	// Tenant rules change without a new revision and must still be applied again
+	c.TenantRules = []CatalogTenantRule{{RuleType: "deny", Priority: 10}}
+	if catalogFingerprint(c) == before {
+		t.Errorf("%s - a tenant rule change must change the fingerprint", mirrorTestPrefix)
+	}
+}
+
+func TestCatalogTenantRules_RoundTrip(t *testing.T) {
+	tenant, env := "6f1c2a5e-0000-4000-8000-000000000001", "production"
+	stored := []db.CapabilityTenantRule{{TenantID: &tenant, Env: &env, RuleType: "allow", AllowedMajors: []int{1}, RequiredFeatures: []string{"beta"}, Priority: 5}}
+
+	exported := catalogTenantRules(stored)
+	if len(exported) != 1 || exported[0].TenantID != tenant || exported[0].Env != env || exported[0].Aud != "" {
+		t.Fatalf("%s - catalogTenantRules = %+v", mirrorTestPrefix, exported)
+	}
+	if empty := catalogTenantRules(nil); empty == nil {
+		t.Errorf("%s - no rules must export as an empty list so mirrors clear theirs", mirrorTestPrefix)
+	}
+	rows := tenantRuleRows("local:more0.doc.ingest", exported)
+	if *rows[0].TenantID != tenant || rows[0].Aud != nil || rows[0].AllowedMajors[0] != 1 || rows[0].RequiredFeatures[0] != "beta" {
+		t.Errorf("%s - tenantRuleRows = %+v", mirrorTestPrefix, rows[0])
+	}
+}
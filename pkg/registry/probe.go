@@ -0,0 +1,324 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	comms "github.com/nats-io/nats.go"
+
+	"github.com/morezero/capabilities-registry/pkg/commsutil"
+	"github.com/morezero/capabilities-registry/pkg/db"
+	"github.com/morezero/capabilities-registry/pkg/semver"
+)
+
+const probeLogPrefix = "registry:probe"
+
+// probeResult is the outcome of one probe request.
+type probeResult struct {
+	Status    string
+	LatencyMs *int
+	Error     string
+}
+
+// probeEnvelope is the request sent to a capability subject. Providers may answer it like
+// any other request; any reply, including an error reply, proves a responder is listening.
+type probeEnvelope struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Cap     string `json:"cap"`
+	Version string `json:"version"`
+}
+
+// Probe sends a probe request to the subject of one exact version of a capability, or of
+// every non-disabled version, records the results and returns them. A version whose subject
+// has no responders is reported unreachable by describe and discover until a later probe
+// reaches it.
+func (r *Registry) Probe(ctx context.Context, input *ProbeInput) (*ProbeOutput, error) {
+	slog.Info(fmt.Sprintf("%s - probe cap=%s version=%s", probeLogPrefix, input.Cap, input.Version))
+
+	if err := r.requireRepo(); err != nil {
+		return nil, err
+	}
+	if r.nc == nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: "responder probing is not configured"}
+	}
+	if input.Version != "" && !semver.IsExactVersion(input.Version) {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: "version must be an exact version"}
+	}
+
+	parsed, cap, regErr := r.lookupCapability(ctx, input.Cap)
+	if regErr != nil {
+		return nil, regErr
+	}
+	versions, err := r.repo.GetVersions(ctx, cap.ID)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	var targets []semver.VersionRecord
+	for _, v := range dbVersionsToRecords(versions) {
+		if input.Version != "" && v.VersionString == input.Version {
+			targets = append(targets, v)
+			break
+		}
+		if input.Version == "" && v.Status != "disabled" {
+			targets = append(targets, v)
+		}
+	}
+	if len(targets) == 0 {
+		if input.Version != "" {
+			return nil, &RegistryError{Code: "NOT_FOUND", Message: fmt.Sprintf("Version %s not found for: %s", input.Version, parsed.Full)}
+		}
+		return nil, &RegistryError{Code: "NOT_FOUND", Message: fmt.Sprintf("No probeable versions found for: %s", parsed.Full)}
+	}
+
+	return &ProbeOutput{
+		Cap:      fmt.Sprintf("%s.%s", cap.App, cap.Name),
+		Versions: r.probeVersions(ctx, cap, targets),
+	}, nil
+}
+
+// ProbeAll probes every non-disabled version of every Active capability and returns the
+// number of versions probed.
+func (r *Registry) ProbeAll(ctx context.Context) (int, error) {
+	if err := r.requireRepo(); err != nil {
+		return 0, err
+	}
+	if r.nc == nil {
+		return 0, nil
+	}
+
+	probed := 0
+	for page := 1; ; page++ {
+		caps, total, err := r.repo.ListCapabilities(ctx, db.ListCapabilitiesParams{
+			Status: "Active",
+			Page:   page,
+			Limit:  db.MaxDiscoverLimit,
+		})
+		if err != nil {
+			return probed, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+		}
+		capIDs := make([]string, len(caps))
+		for i, c := range caps {
+			capIDs[i] = c.ID
+		}
+		versionsByCap, err := r.repo.GetVersionsByCapabilityIDs(ctx, capIDs)
+		if err != nil {
+			return probed, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+		}
+		for i := range caps {
+			var targets []semver.VersionRecord
+			for _, v := range dbVersionsToRecords(versionsByCap[caps[i].ID]) {
+				if v.Status != "disabled" {
+					targets = append(targets, v)
+				}
+			}
+			probed += len(r.probeVersions(ctx, &caps[i], targets))
+		}
+		if len(caps) == 0 || page*db.MaxDiscoverLimit >= total {
+			break
+		}
+	}
+	slog.Info(fmt.Sprintf("%s - probed %d versions", probeLogPrefix, probed))
+	return probed, nil
+}
+
+// StartProber runs ProbeAll every interval until ctx is cancelled. It does nothing without
+// a repository, without a COMMS connection or with a non-positive interval.
+func (r *Registry) StartProber(ctx context.Context, interval time.Duration) {
+	if r.repo == nil || r.nc == nil || interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := r.ProbeAll(ctx); err != nil {
+					slog.Error(fmt.Sprintf("%s - probe round failed: %v", probeLogPrefix, err))
+				}
+			}
+		}
+	}()
+}
+
+// probeVersions probes and records each version on the subjects it is served on through
+// the registry's own NATS connection. Versions sharing a subject (e.g. minors under the
+// default per-major scheme) share one probe request. A version served only on other NATS
+// deployments, or one without responders here that is also served elsewhere, is recorded
+// as unknown rather than unreachable.
+func (r *Registry) probeVersions(ctx context.Context, cap *db.Capability, versions []semver.VersionRecord) []VersionProbe {
+	capName := fmt.Sprintf("%s.%s", cap.App, cap.Name)
+	versionIDs := make([]string, len(versions))
+	for i, v := range versions {
+		versionIDs[i] = v.ID
+	}
+	endpointsByVersion, err := r.repo.ListEndpointsForVersions(ctx, versionIDs)
+	if err != nil {
+		slog.Error(fmt.Sprintf("%s - ListEndpointsForVersions failed for %s: %v", probeLogPrefix, capName, err))
+	}
+	cells, err := r.repo.ListCells(ctx)
+	if err != nil {
+		slog.Error(fmt.Sprintf("%s - ListCells failed: %v", probeLogPrefix, err))
+	}
+
+	bySubject := make(map[string]probeResult)
+	out := make([]VersionProbe, 0, len(versions))
+	for _, v := range versions {
+		local, remote := r.probeSubjects(subjectTemplate(cap), commsutil.SubjectParams{
+			App:   cap.App,
+			Name:  cap.Name,
+			Major: v.Major,
+			Minor: v.Minor,
+			Env:   r.config.DefaultEnv,
+		}, endpointsByVersion[v.ID], cells)
+
+		var subject string
+		var res probeResult
+		for _, s := range local {
+			sres, ok := bySubject[s]
+			if !ok {
+				sres = probeSubject(ctx, r.nc, s, probeEnvelope{
+					ID:      "probe-" + newInstanceID(),
+					Type:    "ping",
+					Cap:     capName,
+					Version: v.VersionString,
+				}, r.config.ProbeTimeout)
+				bySubject[s] = sres
+			}
+			if subject == "" || (sres.Status == db.ProbeStatusReachable && res.Status != db.ProbeStatusReachable) {
+				subject, res = s, sres
+			}
+		}
+		switch {
+		case len(local) == 0:
+			subject = remote[0]
+			res = probeResult{Status: db.ProbeStatusUnknown, Error: "served only on other NATS deployments"}
+		case res.Status != db.ProbeStatusReachable && len(remote) > 0:
+			res = probeResult{Status: db.ProbeStatusUnknown, Error: fmt.Sprintf("%s on the registry's NATS; also served on other NATS deployments", res.Error)}
+		}
+
+		probe, err := r.repo.RecordProbe(ctx, db.RecordProbeParams{
+			VersionID: v.ID,
+			Subject:   subject,
+			Status:    res.Status,
+			LatencyMs: res.LatencyMs,
+			Error:     optionalString(res.Error),
+			ProbedAt:  time.Now().UTC(),
+		})
+		if err != nil {
+			slog.Error(fmt.Sprintf("%s - RecordProbe failed for %s@%s: %v", probeLogPrefix, capName, v.VersionString, err))
+			continue
+		}
+		out = append(out, VersionProbe{Version: v.VersionString, Availability: *probeToAvailability(probe)})
+	}
+	return out
+}
+
+// probeSubjects returns the subjects a version is served on through the registry's own
+// NATS connection (local) and on other NATS deployments (remote), as resolve would hand
+// them out: registered endpoints on their URL and subject, otherwise the capability subject
+// on the registry's URL, and for every cell the same under the cell's subject prefix (on the
+// cell's URL when there are no endpoints).
+func (r *Registry) probeSubjects(template string, params commsutil.SubjectParams, endpoints []db.CapabilityEndpoint, cells []db.Cell) (local, remote []string) {
+	add := func(natsUrl, subject string) {
+		if r.isLocalNatsUrl(natsUrl) {
+			if !slices.Contains(local, subject) {
+				local = append(local, subject)
+			}
+		} else if !slices.Contains(remote, subject) {
+			remote = append(remote, subject)
+		}
+	}
+	type scope struct{ natsUrl, prefix string }
+	scopes := []scope{{natsUrl: r.localNatsUrl()}}
+	for _, c := range cells {
+		scopes = append(scopes, scope{natsUrl: c.NatsUrl, prefix: ptrStringOr(c.SubjectPrefix, "")})
+	}
+	for _, sc := range scopes {
+		p := params
+		p.Prefix = sc.prefix
+		subject := r.buildSubject(template, p)
+		if len(endpoints) == 0 {
+			add(sc.natsUrl, subject)
+			continue
+		}
+		for _, e := range endpoints {
+			epSubject := subject
+			if e.Subject != nil && *e.Subject != "" {
+				epSubject = r.buildSubject(*e.Subject, p)
+			}
+			add(e.NatsUrl, epSubject)
+		}
+	}
+	return local, remote
+}
+
+// isLocalNatsUrl reports whether natsUrl is the registry's own NATS server.
+func (r *Registry) isLocalNatsUrl(natsUrl string) bool {
+	return strings.TrimSuffix(natsUrl, "/") == strings.TrimSuffix(r.localNatsUrl(), "/")
+}
+
+// probeSubject sends one probe request on nc and classifies the outcome: any reply is
+// reachable, "no responders" is unreachable, an expired timeout is timeout.
+func probeSubject(ctx context.Context, nc *comms.Conn, subject string, payload probeEnvelope, timeout time.Duration) probeResult {
+	data, err := commsutil.EncodePayload(payload)
+	if err != nil {
+		return probeResult{Status: db.ProbeStatusError, Error: err.Error()}
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	_, err = nc.RequestWithContext(reqCtx, subject, data)
+	latency := int(time.Since(start).Milliseconds())
+	switch {
+	case err == nil:
+		return probeResult{Status: db.ProbeStatusReachable, LatencyMs: &latency}
+	case errors.Is(err, comms.ErrNoResponders):
+		return probeResult{Status: db.ProbeStatusUnreachable, Error: "no responders"}
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, comms.ErrTimeout):
+		return probeResult{Status: db.ProbeStatusTimeout, Error: fmt.Sprintf("no reply within %s", timeout)}
+	default:
+		return probeResult{Status: db.ProbeStatusError, Error: err.Error()}
+	}
+}
+
+// versionAvailability returns the latest probe of a version, or nil when it was never probed,
+// the lookup fails or there is no repository.
+func (r *Registry) versionAvailability(ctx context.Context, versionID string) *Availability {
+	if r.repo == nil {
+		return nil
+	}
+	probes, err := r.repo.GetProbes(ctx, []string{versionID})
+	if err != nil {
+		slog.Error(fmt.Sprintf("%s - GetProbes failed for version %s: %v", probeLogPrefix, versionID, err))
+		return nil
+	}
+	return probeToAvailability(probes[versionID])
+}
+
+func probeToAvailability(p *db.CapabilityProbe) *Availability {
+	if p == nil {
+		return nil
+	}
+	a := &Availability{
+		Status:     p.Status,
+		Reachable:  p.Status == db.ProbeStatusReachable,
+		Subject:    p.Subject,
+		LatencyMs:  p.LatencyMs,
+		LastProbed: p.LastProbed.UTC().Format(time.RFC3339),
+		Error:      ptrStringOr(p.Error, ""),
+	}
+	if p.LastSeen != nil {
+		a.LastSeen = p.LastSeen.UTC().Format(time.RFC3339)
+	}
+	return a
+}
@@ -0,0 +1,166 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	commsserver "github.com/nats-io/nats-server/v2/server"
+	comms "github.com/nats-io/nats.go"
+
+	"github.com/morezero/capabilities-registry/pkg/commsutil"
+	"github.com/morezero/capabilities-registry/pkg/db"
+)
+
+const probeTestPrefix = "registry:probe_test"
+
+// startProbeServer starts an embedded NATS server on a random port and connects to it.
+func startProbeServer(t *testing.T) *comms.Conn {
+	t.Helper()
+	ns, err := commsserver.NewServer(&commsserver.Options{Host: "127.0.0.1", Port: -1, NoLog: true, NoSigs: true})
+	if err != nil {
+		t.Fatalf("%s - failed to create NATS server: %v", probeTestPrefix, err)
+	}
+	go ns.Start()
+	if !ns.ReadyForConnections(10 * time.Second) {
+		t.Fatalf("%s - NATS server failed to start", probeTestPrefix)
+	}
+	nc, err := comms.Connect(ns.ClientURL(), comms.Timeout(5*time.Second))
+	if err != nil {
+		ns.Shutdown()
+		t.Fatalf("%s - failed to connect: %v", probeTestPrefix, err)
+	}
+	t.Cleanup(func() {
+		nc.Close()
+		ns.Shutdown()
+	})
+	return nc
+}
+
+func TestProbeSubject_ResponderIsReachable(t *testing.T) {
+	nc := startProbeServer(t)
+	var got probeEnvelope
+	sub, err := nc.Subscribe("cap.more0.doc.ingest.v1", func(msg *comms.Msg) {
+		_ = json.Unmarshal(msg.Data, &got)
+		_ = msg.Respond([]byte(`{"ok":false,"error":{"code":"UNKNOWN_METHOD"}}`))
+	})
+	if err != nil {
+		t.Fatalf("%s - subscribe failed: %v", probeTestPrefix, err)
+	}
+	defer sub.Unsubscribe()
+
+	res := probeSubject(context.Background(), nc, "cap.more0.doc.ingest.v1",
+		probeEnvelope{ID: "probe-1", Type: "ping", Cap: "more0.doc.ingest", Version: "1.0.0"}, time.Second)
+
+	if res.Status != db.ProbeStatusReachable {
+		t.Fatalf("%s - Status = %q, want reachable (error %q)", probeTestPrefix, res.Status, res.Error)
+	}
+	if res.LatencyMs == nil {
+		t.Errorf("%s - expected LatencyMs for a reachable probe", probeTestPrefix)
+	}
+	if got.Type != "ping" || got.Cap != "more0.doc.ingest" || got.Version != "1.0.0" {
+		t.Errorf("%s - responder received %+v", probeTestPrefix, got)
+	}
+}
+
+func TestProbeSubject_NoRespondersIsUnreachable(t *testing.T) {
+	nc := startProbeServer(t)
+
+	res := probeSubject(context.Background(), nc, "cap.more0.nobody.v1", probeEnvelope{Type: "ping"}, time.Second)
+
+	if res.Status != db.ProbeStatusUnreachable {
+		t.Fatalf("%s - Status = %q, want unreachable (error %q)", probeTestPrefix, res.Status, res.Error)
+	}
+	if res.LatencyMs != nil {
+		t.Errorf("%s - expected no LatencyMs for an unreachable probe", probeTestPrefix)
+	}
+}
+
+func TestProbeSubject_SilentResponderTimesOut(t *testing.T) {
+	nc := startProbeServer(t)
+	sub, err := nc.Subscribe("cap.more0.slow.v1", func(*comms.Msg) {})
+	if err != nil {
+		t.Fatalf("%s - subscribe failed: %v", probeTestPrefix, err)
+	}
+	defer sub.Unsubscribe()
+
+	res := probeSubject(context.Background(), nc, "cap.more0.slow.v1", probeEnvelope{Type: "ping"}, 50*time.Millisecond)
+
+	if res.Status != db.ProbeStatusTimeout {
+		t.Errorf("%s - Status = %q, want timeout (error %q)", probeTestPrefix, res.Status, res.Error)
+	}
+}
+
+func TestProbeToAvailability(t *testing.T) {
+	if probeToAvailability(nil) != nil {
+		t.Errorf("%s - expected nil availability for a version never probed", probeTestPrefix)
+	}
+
+	seen := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	msg := "no responders"
+	a := probeToAvailability(&db.CapabilityProbe{
+		Subject:    "cap.more0.doc.ingest.v1",
+		Status:     db.ProbeStatusUnreachable,
+		Error:      &msg,
+		LastProbed: seen.Add(time.Minute),
+		LastSeen:   &seen,
+	})
+	if a.Reachable || a.Status != "unreachable" || a.Error != msg {
+		t.Errorf("%s - availability = %+v", probeTestPrefix, a)
+	}
+	if a.LastSeen != "2026-01-02T03:04:05Z" || a.LastProbed != "2026-01-02T03:05:05Z" {
+		t.Errorf("%s - times = %q / %q", probeTestPrefix, a.LastSeen, a.LastProbed)
+	}
+}
+
+func TestProbeSubjects(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.NatsUrl = "nats://registry:4222"
+	r := NewRegistry(NewRegistryParams{Config: cfg})
+	params := commsutil.SubjectParams{App: "more0", Name: "doc", Major: 1, Env: "production"}
+	euPrefix, localPrefix := "eu", "blue"
+	cells := []db.Cell{
+		{Name: "eu", NatsUrl: "nats://eu:4222", SubjectPrefix: &euPrefix},
+		{Name: "blue", NatsUrl: "nats://registry:4222/", SubjectPrefix: &localPrefix},
+	}
+
+	local, remote := r.probeSubjects("", params, nil, nil)
+	if !reflect.DeepEqual(local, []string{"cap.more0.doc.v1"}) || len(remote) != 0 {
+		t.Errorf("%s - capability subject: local %v, remote %v", probeTestPrefix, local, remote)
+	}
+	local, remote = r.probeSubjects("", params, nil, cells)
+	if !reflect.DeepEqual(local, []string{"cap.more0.doc.v1", "blue.more0.doc.v1"}) || !reflect.DeepEqual(remote, []string{"eu.more0.doc.v1"}) {
+		t.Errorf("%s - cells: local %v, remote %v", probeTestPrefix, local, remote)
+	}
+
+	override := "{prefix}.{app}.{name}.v{major}.edge"
+	endpoints := []db.CapabilityEndpoint{
+		{NatsUrl: "nats://edge:4222", Subject: &override},
+		{NatsUrl: "nats://registry:4222"},
+	}
+	local, remote = r.probeSubjects("", params, endpoints, nil)
+	if !reflect.DeepEqual(local, []string{"cap.more0.doc.v1"}) || !reflect.DeepEqual(remote, []string{"cap.more0.doc.v1.edge"}) {
+		t.Errorf("%s - endpoints: local %v, remote %v", probeTestPrefix, local, remote)
+	}
+	local, remote = r.probeSubjects("", params, endpoints[:1], nil)
+	if len(local) != 0 || !reflect.DeepEqual(remote, []string{"cap.more0.doc.v1.edge"}) {
+		t.Errorf("%s - remote endpoints only: local %v, remote %v", probeTestPrefix, local, remote)
+	}
+}
+
+func TestProbe_RequiresRepoAndConn(t *testing.T) {
+	reg := NewRegistry(NewRegistryParams{Repo: nil, Publisher: nil, Config: DefaultConfig()})
+	ctx := context.Background()
+
+	_, err := reg.Probe(ctx, &ProbeInput{Cap: "more0.doc.ingest"})
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INTERNAL_ERROR" {
+		t.Errorf("%s - Probe: expected INTERNAL_ERROR, got %v", probeTestPrefix, err)
+	}
+	if _, err := reg.ProbeAll(ctx); err == nil {
+		t.Errorf("%s - ProbeAll: expected error without repo", probeTestPrefix)
+	}
+	// No repo and no connection: the prober does not start.
+	reg.StartProber(ctx, time.Millisecond)
+}
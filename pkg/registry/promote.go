@@ -0,0 +1,152 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/morezero/capabilities-registry/pkg/db"
+	"github.com/morezero/capabilities-registry/pkg/events"
+	"github.com/morezero/capabilities-registry/pkg/semver"
+)
+
+const promoteLogPrefix = "registry:promote"
+
+// Promote makes an exact version available in the target env and optionally sets its
+// major as the default there. Every promotion is recorded with the promoting user.
+func (r *Registry) Promote(ctx context.Context, input *PromoteInput, userID string) (*PromoteOutput, error) {
+	slog.Info(fmt.Sprintf("%s - cap=%s version=%s from=%s to=%s", promoteLogPrefix, input.Cap, input.Version, input.FromEnv, input.ToEnv))
+
+	if err := r.requireRepo(); err != nil {
+		return nil, err
+	}
+
+	parsed, err := semver.ParseCapabilityRef(input.Cap)
+	if err != nil {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: err.Error()}
+	}
+	version := input.Version
+	if version == "" {
+		version = parsed.Range
+	}
+	if !semver.IsExactVersion(version) {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: "promote requires an exact version"}
+	}
+	if input.ToEnv == "" {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: "toEnv is required"}
+	}
+	if input.FromEnv == input.ToEnv {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: "fromEnv and toEnv must differ"}
+	}
+
+	cap, err := r.repo.GetCapability(ctx, parsed.App, parsed.Name)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	if cap == nil {
+		return nil, &RegistryError{Code: "NOT_FOUND", Message: fmt.Sprintf("Capability not found: %s", parsed.Full)}
+	}
+	versions, err := r.repo.GetVersions(ctx, cap.ID)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	var match *semver.VersionRecord
+	records := dbVersionsToRecords(versions)
+	for i := range records {
+		if records[i].VersionString == version {
+			match = &records[i]
+			break
+		}
+	}
+	if match == nil {
+		return nil, &RegistryError{Code: "NOT_FOUND", Message: fmt.Sprintf("Version %s not found for: %s", version, parsed.Full)}
+	}
+	if match.Status == "disabled" {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("cannot promote disabled version %s of %s", version, parsed.Full)}
+	}
+	if input.FromEnv != "" && !match.AvailableIn(input.FromEnv) {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("%s@%s is not available in env %s", parsed.Full, version, input.FromEnv)}
+	}
+
+	var previousDefault *db.CapabilityDefault
+	if input.SetDefault {
+		previousDefault, _ = r.repo.GetDefault(ctx, cap.ID, input.ToEnv)
+	}
+
+	var fromEnv *string
+	if input.FromEnv != "" {
+		fromEnv = &input.FromEnv
+	}
+	promotion, err := r.repo.PromoteVersion(ctx, db.PromoteVersionParams{
+		CapabilityID: cap.ID,
+		VersionID:    match.ID,
+		Major:        match.Major,
+		FromEnv:      fromEnv,
+		ToEnv:        input.ToEnv,
+		SetDefault:   input.SetDefault,
+		UserID:       userID,
+	})
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+
+	// Publish event
+	changed := []string{"envs"}
+	previous := map[string]interface{}{"envs": match.Envs}
+	next := map[string]interface{}{"envs": promotedEnvs(match.Envs, input.ToEnv)}
+	var newDefault *int
+	if input.SetDefault {
+		changed = append(changed, "defaultMajor")
+		major := match.Major
+		newDefault = &major
+		next["defaultMajor"] = major
+		if previousDefault != nil {
+			previous["defaultMajor"] = previousDefault.DefaultMajor
+		}
+	}
+	revision, _ := r.repo.IncrementRevision(ctx, cap.ID)
+	_ = r.publisher.PublishChanged(ctx, &events.RegistryChangedEvent{
+		Kind:             events.KindPromoted,
+		App:              parsed.App,
+		Capability:       parsed.Name,
+		ChangedFields:    changed,
+		NewDefaultMajor:  newDefault,
+		AffectedMajors:   []int{match.Major},
+		AffectedVersions: []string{version},
+		Previous:         previous,
+		New:              next,
+		Revision:         revision,
+		Etag:             fmt.Sprintf("%s-%d", cap.ID, revision),
+		Timestamp:        time.Now().UTC().Format(time.RFC3339),
+		Env:              input.ToEnv,
+	})
+
+	result := &PromoteOutput{
+		Cap:        parsed.Full,
+		Version:    version,
+		FromEnv:    input.FromEnv,
+		ToEnv:      input.ToEnv,
+		Envs:       promotedEnvs(match.Envs, input.ToEnv),
+		DefaultSet: input.SetDefault,
+		PromotedBy: promotion.CreatedBy,
+		PromotedAt: promotion.Created.UTC().Format(time.RFC3339),
+	}
+	if previousDefault != nil {
+		result.PreviousMajor = &previousDefault.DefaultMajor
+	}
+	return result, nil
+}
+
+// promotedEnvs returns the availability after adding env; nil (all envs) stays nil.
+func promotedEnvs(envs []string, env string) []string {
+	if len(envs) == 0 {
+		return nil
+	}
+	for _, e := range envs {
+		if e == env {
+			return envs
+		}
+	}
+	return append(append([]string{}, envs...), env)
+}
@@ -0,0 +1,38 @@
+package registry
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+const promoteTestPrefix = "registry:promote_test"
+
+func TestPromote_RequiresRepo(t *testing.T) {
+	reg := NewRegistry(NewRegistryParams{Repo: nil, Publisher: nil, Config: DefaultConfig()})
+
+	_, err := reg.Promote(context.Background(), &PromoteInput{Cap: "more0.doc.ingest", Version: "3.2.1", ToEnv: "production"}, "test-user")
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INTERNAL_ERROR" {
+		t.Errorf("%s - expected INTERNAL_ERROR, got %v", promoteTestPrefix, err)
+	}
+}
+
+func TestPromotedEnvs(t *testing.T) {
+	tests := []struct {
+		name string
+		envs []string
+		env  string
+		want []string
+	}{
+		{"unrestricted stays unrestricted", nil, "production", nil},
+		{"adds target env", []string{"staging"}, "production", []string{"staging", "production"}},
+		{"already available", []string{"staging", "production"}, "production", []string{"staging", "production"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := promotedEnvs(tt.envs, tt.env); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s - promotedEnvs = %v, want %v", promoteTestPrefix, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/morezero/capabilities-registry/pkg/commsutil"
+	"github.com/morezero/capabilities-registry/pkg/db"
+	"github.com/morezero/capabilities-registry/pkg/semver"
+)
+
+const registriesLogPrefix = "registry:registries"
+
+// AddRegistry creates a registry alias. An alias with a NATS URL points at a remote
+// registry that @alias refs are forwarded to; its subject defaults to cap.system.registry.v1.
+func (r *Registry) AddRegistry(ctx context.Context, input *AddRegistryInput) (*RegistryInfo, error) {
+	slog.Info(fmt.Sprintf("%s - addRegistry alias=%s natsUrl=%s", registriesLogPrefix, input.Alias, input.NatsUrl))
+
+	if err := r.requireRepo(); err != nil {
+		return nil, err
+	}
+	if !semver.ValidateAppName(input.Alias) {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: "alias must be lowercase alphanumeric with hyphens only"}
+	}
+	subject := input.RegistrySubject
+	if input.NatsUrl != "" && subject == "" {
+		subject = defaultRemoteRegistrySubject()
+	}
+	if regErr := validateRegistryEndpoint(input.NatsUrl, subject); regErr != nil {
+		return nil, regErr
+	}
+	config, regErr := registryConfigJSON(input.Config)
+	if regErr != nil {
+		return nil, regErr
+	}
+	if regErr := validateRemoteConfig(input.Alias, config); regErr != nil {
+		return nil, regErr
+	}
+
+	entry, err := r.repo.CreateRegistry(ctx, db.SaveRegistryParams{
+		Alias:           input.Alias,
+		NatsUrl:         optionalString(input.NatsUrl),
+		RegistrySubject: optionalString(subject),
+		IsDefault:       &input.IsDefault,
+		Config:          config,
+	})
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	if entry == nil {
+		return nil, &RegistryError{Code: "ALREADY_EXISTS", Message: fmt.Sprintf("Registry alias already exists: %s", input.Alias)}
+	}
+	info := r.registryToInfo(entry)
+	return &info, nil
+}
+
+// UpdateRegistry changes the URL, subject, default flag or config of a registry alias.
+// A new config replaces the old one. The pooled connection, circuit state and cached
+// answers of the alias are reset.
+func (r *Registry) UpdateRegistry(ctx context.Context, input *UpdateRegistryInput) (*RegistryInfo, error) {
+	slog.Info(fmt.Sprintf("%s - updateRegistry alias=%s", registriesLogPrefix, input.Alias))
+
+	if err := r.requireRepo(); err != nil {
+		return nil, err
+	}
+	if input.Alias == "" {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: "alias is required"}
+	}
+	existing, err := r.repo.GetRegistryByAlias(ctx, input.Alias)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	if existing == nil {
+		return nil, &RegistryError{Code: "NOT_FOUND", Message: fmt.Sprintf("Unknown registry alias: %s", input.Alias)}
+	}
+
+	natsUrl := ptrStringOr(existing.NatsUrl, "")
+	if input.NatsUrl != nil {
+		natsUrl = *input.NatsUrl
+	}
+	subject := ptrStringOr(existing.RegistrySubject, "")
+	if input.RegistrySubject != nil {
+		subject = *input.RegistrySubject
+	}
+	if natsUrl != "" && subject == "" {
+		subject = defaultRemoteRegistrySubject()
+	}
+	if regErr := validateRegistryEndpoint(natsUrl, subject); regErr != nil {
+		return nil, regErr
+	}
+	var config []byte
+	if input.Config != nil {
+		// A config read back from listRegistries keeps the stored token
+		if input.Config["token"] == redactedToken {
+			input.Config["token"] = jsonBytesToMap(existing.Config)["token"]
+		}
+		var regErr *RegistryError
+		if config, regErr = registryConfigJSON(input.Config); regErr != nil {
+			return nil, regErr
+		}
+		if regErr = validateRemoteConfig(input.Alias, config); regErr != nil {
+			return nil, regErr
+		}
+	}
+
+	entry, err := r.repo.UpdateRegistry(ctx, db.SaveRegistryParams{
+		Alias:           input.Alias,
+		NatsUrl:         &natsUrl,
+		RegistrySubject: &subject,
+		IsDefault:       input.IsDefault,
+		Config:          config,
+	})
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	if entry == nil {
+		return nil, &RegistryError{Code: "NOT_FOUND", Message: fmt.Sprintf("Unknown registry alias: %s", input.Alias)}
+	}
+	if r.federationPool != nil {
+		r.federationPool.Forget(input.Alias)
+	}
+	info := r.registryToInfo(entry)
+	return &info, nil
+}
+
+// RemoveRegistry deletes a registry alias and closes its pooled connection. The default
+// alias cannot be removed until another alias is made the default.
+func (r *Registry) RemoveRegistry(ctx context.Context, input *RemoveRegistryInput) (*RemoveRegistryOutput, error) {
+	slog.Info(fmt.Sprintf("%s - removeRegistry alias=%s", registriesLogPrefix, input.Alias))
+
+	if err := r.requireRepo(); err != nil {
+		return nil, err
+	}
+	if input.Alias == "" {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: "alias is required"}
+	}
+	existing, err := r.repo.GetRegistryByAlias(ctx, input.Alias)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	if existing != nil && existing.IsDefault {
+		return nil, &RegistryError{
+			Code:    "INVALID_ARGUMENT",
+			Message: fmt.Sprintf("Registry alias %s is the default; make another alias the default before removing it", input.Alias),
+		}
+	}
+	removed, err := r.repo.DeleteRegistry(ctx, input.Alias)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	if r.federationPool != nil {
+		r.federationPool.Forget(input.Alias)
+	}
+	return &RemoveRegistryOutput{Removed: removed}, nil
+}
+
+// ListRegistries returns all registry aliases with the health of their remotes.
+func (r *Registry) ListRegistries(ctx context.Context) (*ListRegistriesOutput, error) {
+	slog.Info(fmt.Sprintf("%s - listRegistries", registriesLogPrefix))
+
+	if err := r.requireRepo(); err != nil {
+		return nil, err
+	}
+	entries, err := r.repo.ListRegistries(ctx)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	out := &ListRegistriesOutput{Registries: make([]RegistryInfo, len(entries))}
+	for i := range entries {
+		out.Registries[i] = r.registryToInfo(&entries[i])
+	}
+	return out, nil
+}
+
+// CheckRegistries health checks every remote registry alias once.
+func (r *Registry) CheckRegistries(ctx context.Context) ([]RemoteRegistryHealth, error) {
+	if err := r.requireRepo(); err != nil {
+		return nil, err
+	}
+	health, err := r.federationPool.CheckHealth(ctx, r.defaultAlias(), r.config.ProbeTimeout)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	return health, nil
+}
+
+// StartRegistryHealthChecker runs CheckRegistries every interval until ctx is cancelled.
+// It does nothing without a repository or with a non-positive interval.
+func (r *Registry) StartRegistryHealthChecker(ctx context.Context, interval time.Duration) {
+	if r.repo == nil || interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := r.CheckRegistries(ctx); err != nil {
+					slog.Error(fmt.Sprintf("%s - health check round failed: %v", registriesLogPrefix, err))
+				}
+			}
+		}
+	}()
+}
+
+// remoteRegistryHealth returns the health of every remote alias seen so far; nil without
+// a federation pool.
+func (r *Registry) remoteRegistryHealth() []RemoteRegistryHealth {
+	if r.federationPool == nil {
+		return nil
+	}
+	return r.federationPool.RemoteHealthAll()
+}
+
+func (r *Registry) registryToInfo(e *db.RegistryEntry) RegistryInfo {
+	info := RegistryInfo{
+		Alias:           e.Alias,
+		NatsUrl:         ptrStringOr(e.NatsUrl, ""),
+		RegistrySubject: ptrStringOr(e.RegistrySubject, ""),
+		IsDefault:       e.IsDefault,
+		Modified:        e.Modified.UTC().Format(time.RFC3339),
+	}
+	if len(e.Config) > 0 {
+		if m := jsonBytesToMap(e.Config); len(m) > 0 {
+			info.Config = redactRemoteConfig(m)
+		}
+	}
+	if r.federationPool != nil {
+		info.Health = r.federationPool.RemoteHealth(e.Alias)
+	}
+	return info
+}
+
+// defaultRemoteRegistrySubject is the subject a remote registry serves when none is given.
+func defaultRemoteRegistrySubject() string {
+	return commsutil.BuildSubject("", commsutil.SubjectParams{App: "system", Name: "registry", Major: 1})
+}
+
+// validateRegistryEndpoint checks an alias's NATS URL and subject; both may be empty.
+func validateRegistryEndpoint(natsUrl, subject string) *RegistryError {
+	if natsUrl != "" {
+		if err := validateNatsUrl(natsUrl); err != nil {
+			return &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("natsUrl %v", err)}
+		}
+	}
+	if subject != "" {
+		if strings.ContainsAny(subject, "{}") {
+			return &RegistryError{Code: "INVALID_ARGUMENT", Message: "registrySubject must not contain template tokens"}
+		}
+		if err := commsutil.ValidateSubjectTemplate(subject); err != nil {
+			return &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("registrySubject: %v", err)}
+		}
+	}
+	return nil
+}
+
+func registryConfigJSON(config map[string]interface{}) ([]byte, *RegistryError) {
+	if config == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(config)
+	if err != nil {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("config: %v", err)}
+	}
+	return b, nil
+}
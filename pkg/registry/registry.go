@@ -3,8 +3,11 @@ package registry
 import (
 	"context"
 	"fmt"
-	"strings"
+	"time"
 
+	comms "github.com/nats-io/nats.go"
+
+	"github.com/morezero/capabilities-registry/pkg/commsutil"
 	"github.com/morezero/capabilities-registry/pkg/db"
 	"github.com/morezero/capabilities-registry/pkg/events"
 )
@@ -12,8 +15,13 @@ import (
 const (
 	defaultTTLSeconds    = 300
 	defaultEnv           = "production"
-	defaultSubjectPrefix = "cap"
+	defaultSubjectPrefix = commsutil.DefaultSubjectPrefix
 	defaultAlias         = "main"
+	defaultTenantShards  = 16
+	defaultInstanceTTL   = 30
+	defaultProbeTimeout  = 2 * time.Second
+	// defaultWatchInboxPrefix is the prefix of NATS reply inboxes (comms.NewInbox).
+	defaultWatchInboxPrefix = "_INBOX."
 )
 
 // Config holds registry configuration.
@@ -22,6 +30,38 @@ type Config struct {
 	DefaultEnv        string
 	SubjectPrefix     string
 	DefaultAlias string
+	// TenantShards is the number of buckets a tenant is hashed into for the {tenantShard}
+	// subject template token.
+	TenantShards int
+	// InstanceTTLSeconds is the lease length for registerInstance and heartbeat
+	// when the provider does not send one.
+	InstanceTTLSeconds int
+	// ProbeTimeout bounds each responder probe request and remote registry health check.
+	ProbeTimeout time.Duration
+	// FederationFailureThreshold is the number of consecutive failed requests to a remote
+	// registry that opens its circuit; FederationCooldown is how long it then fails fast.
+	FederationFailureThreshold int
+	FederationCooldown         time.Duration
+	// FederationMaxStale is how long past their TTL cached federated resolve answers may
+	// be served while the remote is unavailable; 0 disables stale answers.
+	FederationMaxStale time.Duration
+	// FederationID identifies this registry in the trace of federated requests, and
+	// FederationMaxHops is how many registry-to-registry forwards a request may take.
+	FederationID      string
+	FederationMaxHops int
+	// MirrorUpstream is the registry alias a mirror copies its catalog from; empty runs a
+	// normal, writable registry.
+	MirrorUpstream string
+	// MirrorUpstreamURL and MirrorUpstreamSubject are the NATS URL and registry subject of the
+	// upstream alias, for a mirror without a registries table to look the alias up in.
+	MirrorUpstreamURL     string
+	MirrorUpstreamSubject string
+	// WatchInboxPrefix is the subject prefix watch inboxes must have besides "_INBOX.", for
+	// clients whose connection uses a custom inbox prefix; empty allows only "_INBOX.".
+	WatchInboxPrefix string
+	// WebhookAllowPrivate lets webhooks target loopback, private and link-local addresses
+	// (including cloud metadata endpoints); off by default so setWebhook cannot reach them.
+	WebhookAllowPrivate bool
 	// NatsUrl is the NATS server URL for the local/default registry.
 	// Included in resolve responses so clients know which NATS to connect to.
 	NatsUrl string
@@ -30,19 +70,36 @@ type Config struct {
 // DefaultConfig returns the default registry configuration.
 func DefaultConfig() Config {
 	return Config{
-		DefaultTTLSeconds: defaultTTLSeconds,
-		DefaultEnv:        defaultEnv,
-		SubjectPrefix:     defaultSubjectPrefix,
-		DefaultAlias:      defaultAlias,
+		DefaultTTLSeconds:  defaultTTLSeconds,
+		DefaultEnv:         defaultEnv,
+		SubjectPrefix:      defaultSubjectPrefix,
+		DefaultAlias:       defaultAlias,
+		TenantShards:       defaultTenantShards,
+		InstanceTTLSeconds: defaultInstanceTTL,
+		ProbeTimeout:       defaultProbeTimeout,
+		FederationMaxStale: defaultFederationMaxStale,
 	}
 }
 
 // Registry is the main registry service containing all business logic methods.
 type Registry struct {
 	repo           *db.Repository
+	catalog        CatalogStore
 	publisher      events.EventPublisher
 	config         Config
 	federationPool *FederationPool
+	// recorder is the change feed recorder in the publisher chain; nil without a repository.
+	recorder *changeRecorder
+	// nc is the COMMS connection for responder probes and micro service import; nil disables both.
+	nc *comms.Conn
+	// mirror is the replication state in mirror mode; nil otherwise.
+	mirror *mirrorState
+	// resolutionKV is the materialized resolution bucket; nil unless EnableResolutionKV was called.
+	resolutionKV *resolutionKV
+	// watches holds the watches of this replica.
+	watches *watchHub
+	// webhookWake wakes the webhook dispatcher when deliveries are enqueued.
+	webhookWake chan struct{}
 }
 
 // NewRegistry creates a new Registry instance.
@@ -60,23 +117,74 @@ func NewRegistry(params NewRegistryParams) *Registry {
 	if cfg.DefaultAlias == "" {
 		cfg.DefaultAlias = defaultAlias
 	}
+	if cfg.TenantShards == 0 {
+		cfg.TenantShards = defaultTenantShards
+	}
+	if cfg.InstanceTTLSeconds == 0 {
+		cfg.InstanceTTLSeconds = defaultInstanceTTL
+	}
+	if cfg.ProbeTimeout <= 0 {
+		cfg.ProbeTimeout = defaultProbeTimeout
+	}
 
 	pub := params.Publisher
 	if pub == nil {
 		pub = &events.NoOpPublisher{}
 	}
+	var recorder *changeRecorder
+	if params.Repo != nil {
+		// Every change gets a global sequence in the change feed before it is published
+		recorder = &changeRecorder{repo: params.Repo, next: pub}
+		pub = recorder
+	}
+
+	catalog := params.Catalog
+	if catalog == nil && params.Repo != nil {
+		catalog = repoCatalog{params.Repo}
+	}
 
 	var fedPool *FederationPool
-	if params.Repo != nil {
-		fedPool = NewFederationPool(params.Repo)
+	if params.Repo != nil || cfg.MirrorUpstreamURL != "" {
+		opts := FederationPoolOptions{
+			FailureThreshold: cfg.FederationFailureThreshold,
+			Cooldown:         cfg.FederationCooldown,
+			MaxStale:         cfg.FederationMaxStale,
+			SelfID:           cfg.FederationID,
+			MaxHops:          cfg.FederationMaxHops,
+		}
+		if cfg.MirrorUpstream != "" && cfg.MirrorUpstreamURL != "" {
+			// The upstream of a mirror without a registries table is configured directly
+			opts.Static = []db.RegistryEntry{{
+				Alias:           cfg.MirrorUpstream,
+				NatsUrl:         &cfg.MirrorUpstreamURL,
+				RegistrySubject: &cfg.MirrorUpstreamSubject,
+			}}
+		}
+		fedPool = NewFederationPool(params.Repo, opts)
 	}
 
-	return &Registry{
+	var mirror *mirrorState
+	if cfg.MirrorUpstream != "" {
+		mirror = newMirrorState()
+	}
+
+	r := &Registry{
 		repo:           params.Repo,
+		catalog:        catalog,
 		publisher:      pub,
+		recorder:       recorder,
 		config:         cfg,
 		federationPool: fedPool,
+		nc:             params.Conn,
+		mirror:         mirror,
+		webhookWake:    make(chan struct{}, 1),
 	}
+	if params.Repo != nil {
+		// Webhook deliveries are enqueued once the change has its sequence
+		r.publisher = &webhookPublisher{r: r, next: r.publisher}
+	}
+	r.watches = newWatchHub(r)
+	return r
 }
 
 // NewRegistryParams holds parameters for NewRegistry.
@@ -84,13 +192,37 @@ type NewRegistryParams struct {
 	Repo      *db.Repository
 	Publisher events.EventPublisher
 	Config    Config
+	// Catalog is the store the catalog is served from and mirrored into; nil uses Repo. A
+	// mirror without Repo passes a LocalStore.
+	Catalog CatalogStore
+	// Conn enables responder probing and micro service import over this connection.
+	Conn *comms.Conn
+}
+
+// buildSubject renders a capability's subject template ("" = default scheme) with the
+// registry's tenant shard count and, unless p sets one, the registry's prefix.
+func (r *Registry) buildSubject(template string, p commsutil.SubjectParams) string {
+	if p.Prefix == "" {
+		p.Prefix = r.config.SubjectPrefix
+	}
+	p.TenantShards = r.config.TenantShards
+	return commsutil.BuildSubject(template, p)
 }
 
-// buildSubject builds a COMMS subject from components.
-func (r *Registry) buildSubject(app, name string, major int) string {
-	// Normalize: replace dots in name with underscores for subject
-	safeName := strings.ReplaceAll(name, ".", "_")
-	return fmt.Sprintf("%s.%s.%s.v%d", r.config.SubjectPrefix, app, safeName, major)
+// subjectTemplate returns the capability's stored subject template, or "" for the default.
+func subjectTemplate(cap *db.Capability) string {
+	if cap == nil || cap.SubjectTemplate == nil {
+		return ""
+	}
+	return *cap.SubjectTemplate
+}
+
+// tenantOf returns the tenant of a resolution context, or "" when there is none.
+func tenantOf(rctx *ResolutionContext) string {
+	if rctx == nil {
+		return ""
+	}
+	return rctx.TenantID
 }
 
 // getEnv returns the environment from context or default.
@@ -109,11 +241,21 @@ func (r *Registry) requireRepo() *RegistryError {
 	return nil
 }
 
+// requireCatalog returns an error if no catalog store is configured (no repository and no
+// local store).
+func (r *Registry) requireCatalog() *RegistryError {
+	if r.catalog == nil {
+		return &RegistryError{Code: "INTERNAL_ERROR", Message: "catalog store not configured"}
+	}
+	return nil
+}
+
 // Close cleans up resources (e.g., federated connections).
 func (r *Registry) Close() {
 	if r.federationPool != nil {
 		r.federationPool.CloseAll()
 	}
+	r.watches.close()
 }
 
 // LoadRegistryAliases loads all registry aliases from the database.
@@ -126,40 +268,69 @@ func (r *Registry) LoadRegistryAliases(ctx context.Context) (map[string]string,
 }
 
 // GetBootstrapCapabilities returns capabilities from the database in the same shape as resolve:
-// ResolveOutput per capability (canonicalIdentity, natsUrl, subject, major, resolvedVersion, status, ttlSeconds=0, etag, methods, optional schemas).
-func (r *Registry) GetBootstrapCapabilities(ctx context.Context, env string, includeMethods, includeSchemas bool) (map[string]*ResolveOutput, error) {
-	if r.repo == nil {
+// ResolveOutput per capability (canonicalIdentity, natsUrl, subject, major, resolvedVersion, status, ttlSeconds=0, etag, endpoints, methods, optional schemas).
+// rctx selects the env (default when nil) and, when it names a release, answers from that release's pins.
+func (r *Registry) GetBootstrapCapabilities(ctx context.Context, rctx *ResolutionContext, includeMethods, includeSchemas bool) (map[string]*ResolveOutput, error) {
+	if r.catalog == nil {
 		return map[string]*ResolveOutput{}, nil
 	}
-	entries, err := r.repo.ListBootstrapEntries(ctx, env)
+	entries, err := r.catalog.ListBootstrapEntries(ctx, r.getEnv(rctx))
 	if err != nil {
 		return nil, err
 	}
-	out := make(map[string]*ResolveOutput, len(entries))
-	natsUrl := r.config.NatsUrl
-	if natsUrl == "" {
-		natsUrl = "nats://127.0.0.1:4222"
+	release, regErr := r.releaseFromContext(ctx, rctx)
+	if regErr != nil {
+		return nil, regErr
+	}
+	if release != nil {
+		pins, err := r.catalog.GetReleasePins(ctx, release.ID)
+		if err != nil {
+			return nil, err
+		}
+		entries = applyReleasePins(entries, pins)
 	}
-	alias := r.config.DefaultAlias
-	if alias == "" {
-		alias = defaultAlias
+	return r.bootstrapOutputs(ctx, rctx, entries, includeMethods, includeSchemas)
+}
+
+// bootstrapOutputs builds the resolve-shaped bootstrap answer of each entry, keyed by "app.name".
+func (r *Registry) bootstrapOutputs(ctx context.Context, rctx *ResolutionContext, entries []db.BootstrapEntry, includeMethods, includeSchemas bool) (map[string]*ResolveOutput, error) {
+	versionIDs := make([]string, len(entries))
+	for i, e := range entries {
+		versionIDs[i] = e.VersionID
 	}
+	endpointsByVersion, err := r.catalog.ListEndpointsForVersions(ctx, versionIDs)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]*ResolveOutput, len(entries))
+	alias := r.defaultAlias()
+	cell := r.tenantCell(ctx, rctx)
 	for _, e := range entries {
 		capRef := e.App + "." + e.Name
-		subject := r.buildSubject(e.App, e.Name, e.DefaultMajor)
+		endpoints := r.selectEndpoints(endpointsByVersion[e.VersionID], e.SubjectTemplate, commsutil.SubjectParams{
+			App:      e.App,
+			Name:     e.Name,
+			Major:    e.DefaultMajor,
+			Minor:    e.Minor,
+			Env:      r.getEnv(rctx),
+			TenantID: tenantOf(rctx),
+		}, rctx, cell)
 		canonicalIdentity := fmt.Sprintf("cap:@%s/%s/%s@%s", alias, e.App, e.Name, e.VersionString)
 		ro := &ResolveOutput{
 			CanonicalIdentity: canonicalIdentity,
-			NatsUrl:           natsUrl,
-			Subject:           subject,
+			NatsUrl:           endpoints.NatsUrl,
+			Subject:           endpoints.Subject,
 			Major:             e.DefaultMajor,
 			ResolvedVersion:   e.VersionString,
 			Status:            e.VersionStatus,
 			TTLSeconds:        0,
 			Etag:              "bootstrap",
+			Region:            endpoints.Region,
+			Cell:              cellName(cell),
+			Alternates:        endpoints.Alternates,
 		}
 		if includeMethods || includeSchemas {
-			methods, err := r.repo.GetMethods(ctx, e.VersionID)
+			methods, err := r.catalog.GetMethods(ctx, e.VersionID)
 			if err == nil {
 				if includeMethods {
 					ro.Methods = make([]MethodInfo, len(methods))
@@ -2,8 +2,11 @@ package registry
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
+	"github.com/morezero/capabilities-registry/pkg/commsutil"
+	"github.com/morezero/capabilities-registry/pkg/db"
 	"github.com/morezero/capabilities-registry/pkg/events"
 )
 
@@ -135,7 +138,7 @@ func TestBuildSubject(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := reg.buildSubject(tt.app, tt.capN, tt.major)
+			got := reg.buildSubject("", commsutil.SubjectParams{App: tt.app, Name: tt.capN, Major: tt.major})
 			if got != tt.want {
 				t.Errorf("registry:registry_test - buildSubject(%q, %q, %d) = %q, want %q",
 					tt.app, tt.capN, tt.major, got, tt.want)
@@ -155,7 +158,7 @@ func TestBuildSubject_CustomPrefix(t *testing.T) {
 		},
 	})
 
-	got := reg.buildSubject("more0", "registry", 1)
+	got := reg.buildSubject("", commsutil.SubjectParams{App: "more0", Name: "registry", Major: 1})
 	want := "svc.more0.registry.v1"
 	if got != want {
 		t.Errorf("registry:registry_test - buildSubject with custom prefix = %q, want %q", got, want)
@@ -257,7 +260,7 @@ func TestRequireRepo_ReturnsErrorWhenNil(t *testing.T) {
 func TestGetBootstrapCapabilities_NilRepo_ReturnsEmpty(t *testing.T) {
 	reg := NewRegistry(NewRegistryParams{Repo: nil, Publisher: nil, Config: DefaultConfig()})
 	ctx := context.Background()
-	out, err := reg.GetBootstrapCapabilities(ctx, "production", false, false)
+	out, err := reg.GetBootstrapCapabilities(ctx, nil, false, false)
 	if err != nil {
 		t.Fatalf("registry:registry_test - unexpected error: %v", err)
 	}
@@ -283,3 +286,31 @@ func TestLoadRegistryAliases_NilFederationPool_ReturnsDefaultAlias(t *testing.T)
 		t.Errorf("registry:registry_test - defaultAlias = %q, want main", defaultAlias)
 	}
 }
+
+func TestBuildSubject_Template(t *testing.T) {
+	reg := NewRegistry(NewRegistryParams{Config: DefaultConfig()})
+
+	got := reg.buildSubject("{env}.{prefix}.{app}.{name}.v{major}", commsutil.SubjectParams{
+		App: "more0", Name: "doc.ingest", Major: 2, Env: "staging",
+	})
+	if want := "staging.cap.more0.doc_ingest.v2"; got != want {
+		t.Errorf("registry:registry_test - buildSubject env template = %q, want %q", got, want)
+	}
+
+	tmpl := "{prefix}.{app}.{name}.v{major}.s{tenantShard}"
+	p := commsutil.SubjectParams{App: "more0", Name: "auth", Major: 1, TenantID: "tenant-a"}
+	want := fmt.Sprintf("cap.more0.auth.v1.s%d", commsutil.TenantShard("tenant-a", defaultTenantShards))
+	if got := reg.buildSubject(tmpl, p); got != want {
+		t.Errorf("registry:registry_test - buildSubject shard template = %q, want %q", got, want)
+	}
+}
+
+func TestSubjectTemplate(t *testing.T) {
+	if got := subjectTemplate(nil); got != "" {
+		t.Errorf("registry:registry_test - subjectTemplate(nil) = %q, want empty", got)
+	}
+	tmpl := "{env}.{app}.{name}.v{major}"
+	if got := subjectTemplate(&db.Capability{SubjectTemplate: &tmpl}); got != tmpl {
+		t.Errorf("registry:registry_test - subjectTemplate = %q, want %q", got, tmpl)
+	}
+}
@@ -0,0 +1,267 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/morezero/capabilities-registry/pkg/db"
+	"github.com/morezero/capabilities-registry/pkg/semver"
+)
+
+const (
+	releaseLogPrefix = "registry:release"
+	maxReleasePins   = 1000
+)
+
+var releaseNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,127}$`)
+
+// validateCreateReleaseInput checks the release name and pin count.
+func validateCreateReleaseInput(input *CreateReleaseInput) *RegistryError {
+	if !releaseNameRegex.MatchString(input.Name) {
+		return &RegistryError{Code: "INVALID_ARGUMENT", Message: "release name must be lowercase alphanumeric with dots, hyphens, underscores (max 128 chars)"}
+	}
+	if len(input.Pins) == 0 {
+		return &RegistryError{Code: "INVALID_ARGUMENT", Message: "at least one pin is required"}
+	}
+	if len(input.Pins) > maxReleasePins {
+		return &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("pins count exceeds maximum %d", maxReleasePins)}
+	}
+	return nil
+}
+
+// CreateRelease creates a named, immutable release pinning each listed capability to an exact version.
+func (r *Registry) CreateRelease(ctx context.Context, input *CreateReleaseInput, userID string) (*CreateReleaseOutput, error) {
+	slog.Info(fmt.Sprintf("%s - createRelease name=%s pins=%d", releaseLogPrefix, input.Name, len(input.Pins)))
+
+	if err := r.requireRepo(); err != nil {
+		return nil, err
+	}
+	if err := validateCreateReleaseInput(input); err != nil {
+		return nil, err
+	}
+
+	existing, err := r.repo.GetReleaseByName(ctx, input.Name)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	if existing != nil {
+		return nil, &RegistryError{Code: "ALREADY_EXISTS", Message: fmt.Sprintf("Release already exists: %s (releases are immutable)", input.Name)}
+	}
+
+	pins := make([]db.CreateReleasePin, 0, len(input.Pins))
+	seen := make(map[string]bool, len(input.Pins))
+	for _, p := range input.Pins {
+		parsed, err := semver.ParseCapabilityRef(p.Cap)
+		if err != nil {
+			return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: err.Error()}
+		}
+		version := p.Version
+		if version == "" {
+			version = parsed.Range
+		}
+		if !semver.IsExactVersion(version) {
+			return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("pin for %s must name an exact version", parsed.Full)}
+		}
+		if seen[parsed.Full] {
+			return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("capability pinned more than once: %s", parsed.Full)}
+		}
+		seen[parsed.Full] = true
+
+		cap, err := r.repo.GetCapability(ctx, parsed.App, parsed.Name)
+		if err != nil {
+			return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+		}
+		if cap == nil {
+			return nil, &RegistryError{Code: "NOT_FOUND", Message: fmt.Sprintf("Capability not found: %s", parsed.Full)}
+		}
+		versions, err := r.repo.GetVersions(ctx, cap.ID)
+		if err != nil {
+			return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+		}
+		var match *semver.VersionRecord
+		records := dbVersionsToRecords(versions)
+		for i := range records {
+			if records[i].VersionString == version {
+				match = &records[i]
+				break
+			}
+		}
+		if match == nil {
+			return nil, &RegistryError{Code: "NOT_FOUND", Message: fmt.Sprintf("Version %s not found for: %s", version, parsed.Full)}
+		}
+		if match.Status == "disabled" {
+			return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("cannot pin disabled version %s of %s", version, parsed.Full)}
+		}
+		pins = append(pins, db.CreateReleasePin{CapabilityID: cap.ID, VersionID: match.ID})
+	}
+
+	var desc *string
+	if input.Description != "" {
+		desc = &input.Description
+	}
+	rel, err := r.repo.CreateRelease(ctx, db.CreateReleaseParams{
+		Name:        input.Name,
+		Description: desc,
+		Pins:        pins,
+		UserID:      userID,
+	})
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	if rel == nil {
+		// A concurrent createRelease took the name after the check above
+		return nil, &RegistryError{Code: "ALREADY_EXISTS", Message: fmt.Sprintf("Release already exists: %s (releases are immutable)", input.Name)}
+	}
+
+	return &CreateReleaseOutput{
+		Name:     rel.Name,
+		PinCount: rel.PinCount,
+		Created:  rel.Created.UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// DescribeRelease returns a release and all of its pinned versions.
+func (r *Registry) DescribeRelease(ctx context.Context, input *DescribeReleaseInput) (*DescribeReleaseOutput, error) {
+	slog.Info(fmt.Sprintf("%s - describeRelease name=%s", releaseLogPrefix, input.Name))
+
+	if err := r.requireCatalog(); err != nil {
+		return nil, err
+	}
+
+	rel, regErr := r.getRelease(ctx, input.Name)
+	if regErr != nil {
+		return nil, regErr
+	}
+	pins, err := r.catalog.GetReleasePins(ctx, rel.ID)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+
+	out := &DescribeReleaseOutput{
+		Name:        rel.Name,
+		Description: ptrStringOr(rel.Description, ""),
+		Created:     rel.Created.UTC().Format(time.RFC3339),
+		CreatedBy:   rel.CreatedBy,
+		Pins:        make([]ReleasePinInfo, len(pins)),
+	}
+	for i, p := range pins {
+		out.Pins[i] = ReleasePinInfo{
+			Cap:     fmt.Sprintf("%s.%s", p.App, p.Name),
+			Version: p.VersionString,
+			Major:   p.Major,
+			Status:  p.VersionStatus,
+		}
+	}
+	return out, nil
+}
+
+// ListReleases returns all releases, newest first.
+func (r *Registry) ListReleases(ctx context.Context) (*ListReleasesOutput, error) {
+	slog.Info(fmt.Sprintf("%s - listReleases", releaseLogPrefix))
+
+	if err := r.requireCatalog(); err != nil {
+		return nil, err
+	}
+
+	releases, err := r.catalog.ListReleases(ctx)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	out := &ListReleasesOutput{Releases: make([]ReleaseSummary, len(releases))}
+	for i, rel := range releases {
+		out.Releases[i] = ReleaseSummary{
+			Name:        rel.Name,
+			Description: ptrStringOr(rel.Description, ""),
+			Created:     rel.Created.UTC().Format(time.RFC3339),
+			PinCount:    rel.PinCount,
+		}
+	}
+	return out, nil
+}
+
+// getRelease looks up a release by name, returning NOT_FOUND when it does not exist.
+func (r *Registry) getRelease(ctx context.Context, name string) (*db.Release, *RegistryError) {
+	rel, err := r.catalog.GetReleaseByName(ctx, name)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	if rel == nil {
+		return nil, &RegistryError{Code: "NOT_FOUND", Message: fmt.Sprintf("Release not found: %s", name)}
+	}
+	return rel, nil
+}
+
+// releaseFromContext returns the release named in the resolution context, or nil when none is requested.
+func (r *Registry) releaseFromContext(ctx context.Context, rctx *ResolutionContext) (*db.Release, *RegistryError) {
+	if rctx == nil || rctx.Release == "" {
+		return nil, nil
+	}
+	return r.getRelease(ctx, rctx.Release)
+}
+
+// resolvePinned picks the pinned version out of records and checks it against the env and requested range.
+func resolvePinned(records []semver.VersionRecord, pin *db.ReleasePin, rangeStr, env, capFull, releaseName string) (*semver.VersionRecord, *RegistryError) {
+	for i := range records {
+		if records[i].ID != pin.VersionID {
+			continue
+		}
+		if records[i].Status == "disabled" {
+			return nil, &RegistryError{
+				Code:    "NOT_FOUND",
+				Message: fmt.Sprintf("Release %s pins %s@%s, which is disabled", releaseName, capFull, records[i].VersionString),
+			}
+		}
+		if !records[i].AvailableIn(env) {
+			return nil, &RegistryError{
+				Code:    "NOT_FOUND",
+				Message: fmt.Sprintf("Release %s pins %s@%s, which is not available in env %s", releaseName, capFull, records[i].VersionString, env),
+			}
+		}
+		if rangeStr != "" && !semver.SatisfiesRange(records[i].VersionString, rangeStr) {
+			return nil, &RegistryError{
+				Code:    "NOT_FOUND",
+				Message: fmt.Sprintf("Release %s pins %s@%s, which does not satisfy %s", releaseName, capFull, records[i].VersionString, rangeStr),
+			}
+		}
+		return &records[i], nil
+	}
+	return nil, &RegistryError{
+		Code:    "NOT_FOUND",
+		Message: fmt.Sprintf("Release %s pins a version of %s that no longer exists", releaseName, capFull),
+	}
+}
+
+// applyReleasePins replaces bootstrap entries with the release's pinned versions and adds
+// pinned capabilities that have no default in the env.
+func applyReleasePins(entries []db.BootstrapEntry, pins []db.ReleasePin) []db.BootstrapEntry {
+	index := make(map[string]int, len(entries))
+	for i, e := range entries {
+		index[e.App+"."+e.Name] = i
+	}
+	for _, p := range pins {
+		if p.VersionStatus == "disabled" {
+			continue
+		}
+		pinned := db.BootstrapEntry{
+			App:             p.App,
+			Name:            p.Name,
+			DefaultMajor:    p.Major,
+			VersionString:   p.VersionString,
+			VersionStatus:   p.VersionStatus,
+			VersionID:       p.VersionID,
+			Minor:           p.Minor,
+			SubjectTemplate: p.SubjectTemplate,
+		}
+		if i, ok := index[p.App+"."+p.Name]; ok {
+			pinned.Description = entries[i].Description
+			entries[i] = pinned
+			continue
+		}
+		index[p.App+"."+p.Name] = len(entries)
+		entries = append(entries, pinned)
+	}
+	return entries
+}
@@ -0,0 +1,131 @@
+package registry
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/morezero/capabilities-registry/pkg/db"
+)
+
+const releaseTestPrefix = "registry:release_test"
+
+func TestValidateCreateReleaseInput(t *testing.T) {
+	pins := []ReleasePinInput{{Cap: "more0.doc.ingest", Version: "3.2.1"}}
+	tests := []struct {
+		name      string
+		input     CreateReleaseInput
+		expectErr bool
+	}{
+		{"valid", CreateReleaseInput{Name: "release-2026.10", Pins: pins}, false},
+		{"uppercase name", CreateReleaseInput{Name: "Release-1", Pins: pins}, true},
+		{"empty name", CreateReleaseInput{Name: "", Pins: pins}, true},
+		{"name with space", CreateReleaseInput{Name: "rel 1", Pins: pins}, true},
+		{"name too long", CreateReleaseInput{Name: strings.Repeat("a", 129), Pins: pins}, true},
+		{"no pins", CreateReleaseInput{Name: "release-1"}, true},
+		{"too many pins", CreateReleaseInput{Name: "release-1", Pins: make([]ReleasePinInput, maxReleasePins+1)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCreateReleaseInput(&tt.input)
+			if tt.expectErr && err == nil {
+				t.Errorf("%s - expected error", releaseTestPrefix)
+			}
+			if !tt.expectErr && err != nil {
+				t.Errorf("%s - unexpected error: %v", releaseTestPrefix, err)
+			}
+			if err != nil && err.Code != "INVALID_ARGUMENT" {
+				t.Errorf("%s - Code = %q, want INVALID_ARGUMENT", releaseTestPrefix, err.Code)
+			}
+		})
+	}
+}
+
+func TestReleaseMethods_RequireRepo(t *testing.T) {
+	reg := NewRegistry(NewRegistryParams{Repo: nil, Publisher: nil, Config: DefaultConfig()})
+	ctx := context.Background()
+
+	_, err := reg.CreateRelease(ctx, &CreateReleaseInput{Name: "release-1"}, "test-user")
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INTERNAL_ERROR" {
+		t.Errorf("%s - CreateRelease: expected INTERNAL_ERROR, got %v", releaseTestPrefix, err)
+	}
+	_, err = reg.DescribeRelease(ctx, &DescribeReleaseInput{Name: "release-1"})
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INTERNAL_ERROR" {
+		t.Errorf("%s - DescribeRelease: expected INTERNAL_ERROR, got %v", releaseTestPrefix, err)
+	}
+	_, err = reg.ListReleases(ctx)
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INTERNAL_ERROR" {
+		t.Errorf("%s - ListReleases: expected INTERNAL_ERROR, got %v", releaseTestPrefix, err)
+	}
+}
+
+func TestResolvePinned(t *testing.T) {
+	records := dbVersionsToRecords([]db.CapabilityVersion{
+		{ID: "v3", Major: 3, Minor: 1, Patch: 0, Status: "active"},
+		{ID: "v2", Major: 2, Minor: 4, Patch: 0, Status: "deprecated"},
+		{ID: "v1", Major: 1, Minor: 0, Patch: 0, Status: "disabled"},
+		{ID: "v4", Major: 4, Minor: 0, Patch: 0, Status: "active", Envs: []string{"staging"}},
+	})
+
+	tests := []struct {
+		name      string
+		versionID string
+		rangeStr  string
+		wantVer   string
+		wantErr   bool
+	}{
+		{"pinned older major ignores default", "v2", "", "2.4.0", false},
+		{"pin satisfies range", "v2", "^2.0.0", "2.4.0", false},
+		{"pin outside range", "v2", "3", "", true},
+		{"pinned version disabled", "v1", "", "", true},
+		{"pinned version missing", "v9", "", "", true},
+		{"pinned version not in env", "v4", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolvePinned(records, &db.ReleasePin{VersionID: tt.versionID}, tt.rangeStr, "production", "more0.doc.ingest", "release-1")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("%s - expected error", releaseTestPrefix)
+				}
+				if err.Code != "NOT_FOUND" {
+					t.Errorf("%s - Code = %q, want NOT_FOUND", releaseTestPrefix, err.Code)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s - unexpected error: %v", releaseTestPrefix, err)
+			}
+			if got.VersionString != tt.wantVer {
+				t.Errorf("%s - VersionString = %q, want %q", releaseTestPrefix, got.VersionString, tt.wantVer)
+			}
+		})
+	}
+}
+
+func TestApplyReleasePins(t *testing.T) {
+	entries := []db.BootstrapEntry{
+		{App: "more0", Name: "doc.ingest", Description: "Ingest", DefaultMajor: 3, VersionString: "3.1.0", VersionStatus: "active", VersionID: "v3"},
+		{App: "system", Name: "registry", DefaultMajor: 1, VersionString: "1.0.0", VersionStatus: "active", VersionID: "r1"},
+	}
+	pins := []db.ReleasePin{
+		{App: "more0", Name: "doc.ingest", Major: 2, VersionString: "2.4.0", VersionStatus: "deprecated", VersionID: "v2"},
+		{App: "tool", Name: "search", Major: 1, VersionString: "1.2.0", VersionStatus: "active", VersionID: "s1"},
+		{App: "tool", Name: "old", Major: 1, VersionString: "1.0.0", VersionStatus: "disabled", VersionID: "o1"},
+	}
+
+	got := applyReleasePins(entries, pins)
+
+	if len(got) != 3 {
+		t.Fatalf("%s - expected 3 entries, got %d", releaseTestPrefix, len(got))
+	}
+	if got[0].VersionID != "v2" || got[0].DefaultMajor != 2 || got[0].Description != "Ingest" {
+		t.Errorf("%s - pinned entry not replaced: %+v", releaseTestPrefix, got[0])
+	}
+	if got[1].VersionID != "r1" {
+		t.Errorf("%s - unpinned entry changed: %+v", releaseTestPrefix, got[1])
+	}
+	if got[2].App != "tool" || got[2].Name != "search" || got[2].VersionString != "1.2.0" {
+		t.Errorf("%s - pinned capability without default not added: %+v", releaseTestPrefix, got[2])
+	}
+}
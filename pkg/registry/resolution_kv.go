@@ -0,0 +1,234 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sync"
+
+	comms "github.com/nats-io/nats.go"
+
+	"github.com/morezero/capabilities-registry/pkg/db"
+	"github.com/morezero/capabilities-registry/pkg/events"
+)
+
+const resolutionKVLogPrefix = "registry:resolutionKV"
+
+// kvKeyPattern matches the keys JetStream KV accepts.
+var kvKeyPattern = regexp.MustCompile(`^[-/_=a-zA-Z0-9]+(\.[-/_=a-zA-Z0-9]+)*$`)
+
+// resolutionKV is the KV bucket holding the bootstrap-shaped resolve answer of every
+// capability with a default version, under "<env>.<app>.<name>".
+type resolutionKV struct {
+	kv comms.KeyValue
+	// mu serializes writes so an older answer never overwrites a newer one.
+	mu sync.Mutex
+	// envs is every env written so far, so keys of an env that lost its last default are deleted.
+	envs map[string]bool
+}
+
+// resolutionKVPublisher updates the capability's keys in the resolution bucket before
+// publishing each change event, so clients reading the bucket on the event see the change.
+type resolutionKVPublisher struct {
+	r    *Registry
+	next events.EventPublisher
+}
+
+// PublishChanged updates the resolution bucket, then publishes event.
+func (p *resolutionKVPublisher) PublishChanged(ctx context.Context, event *events.RegistryChangedEvent) error {
+	if event.App != "" && event.Capability != "" {
+		if err := p.r.updateResolutionKV(ctx, event.App, event.Capability); err != nil {
+			slog.Error(fmt.Sprintf("%s - updating %s.%s failed: %v", resolutionKVLogPrefix, event.App, event.Capability, err))
+		}
+	}
+	return p.next.PublishChanged(ctx, event)
+}
+
+// EnableResolutionKV materializes the default resolution of every capability and env into
+// the JetStream KV bucket, creating it if needed, and keeps it updated on every mutation.
+// The bucket is fully reconciled before EnableResolutionKV returns. It must be called
+// before the registry serves requests.
+func (r *Registry) EnableResolutionKV(ctx context.Context, bucket string) error {
+	if err := r.requireRepo(); err != nil {
+		return err
+	}
+	if r.nc == nil {
+		return &RegistryError{Code: "INTERNAL_ERROR", Message: "resolution bucket needs a NATS connection"}
+	}
+	js, err := r.nc.JetStream()
+	if err != nil {
+		return fmt.Errorf("%s - JetStream unavailable: %w", resolutionKVLogPrefix, err)
+	}
+	kv, err := js.KeyValue(bucket)
+	if errors.Is(err, comms.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&comms.KeyValueConfig{
+			Bucket:      bucket,
+			Description: "Default resolution per env and capability (<env>.<app>.<name>)",
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("%s - opening bucket %s failed: %w", resolutionKVLogPrefix, bucket, err)
+	}
+
+	r.resolutionKV = &resolutionKV{kv: kv, envs: map[string]bool{}}
+	r.publisher = &resolutionKVPublisher{r: r, next: r.publisher}
+	return r.ReconcileResolutionKV(ctx)
+}
+
+// ReconcileResolutionKV rewrites the resolution bucket from the repository: keys whose
+// answer changed are put and keys without a default version are deleted.
+func (r *Registry) ReconcileResolutionKV(ctx context.Context) error {
+	m := r.resolutionKV
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	envs, err := r.resolutionEnvs(ctx)
+	if err != nil {
+		return err
+	}
+	want := map[string][]byte{}
+	for _, env := range envs {
+		entries, err := r.repo.ListBootstrapEntries(ctx, env)
+		if err != nil {
+			return err
+		}
+		if err := r.resolutionValues(ctx, env, entries, want); err != nil {
+			return err
+		}
+	}
+
+	keys, err := m.kv.Keys(comms.Context(ctx))
+	if err != nil && !errors.Is(err, comms.ErrNoKeysFound) {
+		return fmt.Errorf("%s - listing keys failed: %w", resolutionKVLogPrefix, err)
+	}
+	deleted := 0
+	for _, key := range keys {
+		if _, ok := want[key]; !ok {
+			if err := m.kv.Delete(key); err != nil {
+				return fmt.Errorf("%s - deleting %s failed: %w", resolutionKVLogPrefix, key, err)
+			}
+			deleted++
+		}
+	}
+	put := 0
+	for key, value := range want {
+		changed, err := m.put(key, value)
+		if err != nil {
+			return err
+		}
+		if changed {
+			put++
+		}
+	}
+	slog.Info(fmt.Sprintf("%s - reconciled bucket %s: %d keys, %d written, %d deleted", resolutionKVLogPrefix, m.kv.Bucket(), len(want), put, deleted))
+	return nil
+}
+
+// updateResolutionKV rewrites the keys of capability app.name in every env.
+func (r *Registry) updateResolutionKV(ctx context.Context, app, name string) error {
+	m := r.resolutionKV
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	envs, err := r.resolutionEnvs(ctx)
+	if err != nil {
+		return err
+	}
+	for _, env := range envs {
+		entries, err := r.repo.ListBootstrapEntriesFor(ctx, env, app, name)
+		if err != nil {
+			return err
+		}
+		want := map[string][]byte{}
+		if err := r.resolutionValues(ctx, env, entries, want); err != nil {
+			return err
+		}
+		key := resolutionKey(env, app+"."+name)
+		value, ok := want[key]
+		if !ok {
+			if err := m.delete(key); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := m.put(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolutionEnvs returns the envs with a default version, the default env, and every env
+// written before. The caller holds r.resolutionKV.mu.
+func (r *Registry) resolutionEnvs(ctx context.Context) ([]string, error) {
+	m := r.resolutionKV
+	envs, err := r.repo.ListDefaultEnvs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m.envs[r.config.DefaultEnv] = true
+	for _, env := range envs {
+		m.envs[env] = true
+	}
+	out := make([]string, 0, len(m.envs))
+	for env := range m.envs {
+		out = append(out, env)
+	}
+	return out, nil
+}
+
+// resolutionValues adds the encoded bootstrap answer of each entry of env to values.
+func (r *Registry) resolutionValues(ctx context.Context, env string, entries []db.BootstrapEntry, values map[string][]byte) error {
+	outs, err := r.bootstrapOutputs(ctx, &ResolutionContext{Env: env}, entries, true, false)
+	if err != nil {
+		return err
+	}
+	for ref, out := range outs {
+		key := resolutionKey(env, ref)
+		if !kvKeyPattern.MatchString(key) {
+			slog.Warn(fmt.Sprintf("%s - %s in %s cannot be a KV key; skipped", resolutionKVLogPrefix, ref, env))
+			continue
+		}
+		data, err := json.Marshal(out)
+		if err != nil {
+			return err
+		}
+		values[key] = data
+	}
+	return nil
+}
+
+// put writes value under key unless the bucket already holds it, so watchers only see
+// real changes. It reports whether the key was written.
+func (m *resolutionKV) put(key string, value []byte) (bool, error) {
+	if entry, err := m.kv.Get(key); err == nil && bytes.Equal(entry.Value(), value) {
+		return false, nil
+	}
+	if _, err := m.kv.Put(key, value); err != nil {
+		return false, fmt.Errorf("%s - writing %s failed: %w", resolutionKVLogPrefix, key, err)
+	}
+	return true, nil
+}
+
+// delete removes key unless the bucket does not hold it, so watchers only see real deletes.
+func (m *resolutionKV) delete(key string) error {
+	if _, err := m.kv.Get(key); errors.Is(err, comms.ErrKeyNotFound) {
+		return nil
+	}
+	if err := m.kv.Delete(key); err != nil {
+		return fmt.Errorf("%s - deleting %s failed: %w", resolutionKVLogPrefix, key, err)
+	}
+	return nil
+}
+
+// resolutionKey is the bucket key of capability ref ("app.name") in env.
+func resolutionKey(env, ref string) string {
+	return env + "." + ref
+}
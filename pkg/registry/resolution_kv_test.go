@@ -0,0 +1,44 @@
+package registry
+
+import (
+	"context"
+	"testing"
+)
+
+const resolutionKVTestPrefix = "registry:resolution_kv_test"
+
+func TestEnableResolutionKV_RequiresRepo(t *testing.T) {
+	r := NewRegistry(NewRegistryParams{Config: DefaultConfig()})
+	err := r.EnableResolutionKV(context.Background(), "registry-resolution")
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INTERNAL_ERROR" {
+		t.Errorf("%s - expected INTERNAL_ERROR, got %v", resolutionKVTestPrefix, err)
+	}
+	if r.resolutionKV != nil {
+		t.Errorf("%s - the bucket must stay disabled after a failure", resolutionKVTestPrefix)
+	}
+}
+
+func TestReconcileResolutionKV_DisabledIsNoOp(t *testing.T) {
+	r := NewRegistry(NewRegistryParams{Config: DefaultConfig()})
+	if err := r.ReconcileResolutionKV(context.Background()); err != nil {
+		t.Errorf("%s - expected no error without a bucket, got %v", resolutionKVTestPrefix, err)
+	}
+}
+
+func TestResolutionKey(t *testing.T) {
+	tests := []struct {
+		env, ref string
+		valid    bool
+	}{
+		{"production", "more0.doc-ingest", true},
+		{"staging", "acme.billing.invoices", true},
+		{"production", "more0.doc ingest", false},
+		{"", "more0.doc-ingest", false},
+	}
+	for _, tt := range tests {
+		key := resolutionKey(tt.env, tt.ref)
+		if got := kvKeyPattern.MatchString(key); got != tt.valid {
+			t.Errorf("%s - key %q valid = %v, want %v", resolutionKVTestPrefix, key, got, tt.valid)
+		}
+	}
+}
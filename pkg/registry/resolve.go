@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"strings"
 
+	"github.com/morezero/capabilities-registry/pkg/commsutil"
 	"github.com/morezero/capabilities-registry/pkg/db"
 	"github.com/morezero/capabilities-registry/pkg/semver"
 )
@@ -21,21 +22,18 @@ const resolveLogPrefix = "registry:resolve"
 //  2. If the reference contains an @alias prefix (e.g. "@partner/my.app/cap"),
 //     check if that alias is different from the default
 //  3. If the alias is remote, delegate to the federation pool
-//  4. If local or no alias, resolve normally from the local database
+//  4. If local or no alias, resolve normally from the local catalog store
 //  5. All responses include natsUrl — the NATS server where the subject lives
 func (r *Registry) Resolve(ctx context.Context, input *ResolveInput) (*ResolveOutput, error) {
 	slog.Info(fmt.Sprintf("%s - cap=%s ver=%s", resolveLogPrefix, input.Cap, input.Ver))
 
-	if err := r.requireRepo(); err != nil {
+	if err := r.requireCatalog(); err != nil {
 		return nil, err
 	}
 
 	// Check for alias prefix (e.g. "@partner/my.app/my.cap")
 	alias, capRef := extractAlias(input.Cap)
-	defaultAlias := "main"
-	if r.config.DefaultAlias != "" {
-		defaultAlias = r.config.DefaultAlias
-	}
+	defaultAlias := r.defaultAlias()
 
 	// If alias is present and different from default, try federated resolution
 	if alias != "" && alias != defaultAlias {
@@ -46,7 +44,7 @@ func (r *Registry) Resolve(ctx context.Context, input *ResolveInput) (*ResolveOu
 	return r.resolveLocal(ctx, input, defaultAlias, capRef)
 }
 
-// resolveLocal resolves a capability from the local database.
+// resolveLocal resolves a capability from the local catalog store.
 func (r *Registry) resolveLocal(ctx context.Context, input *ResolveInput, defaultAlias string, capRef string) (*ResolveOutput, error) {
 	// Use the original cap if capRef is empty (no alias was extracted)
 	resolveRef := capRef
@@ -54,9 +52,10 @@ func (r *Registry) resolveLocal(ctx context.Context, input *ResolveInput, defaul
 		resolveRef = input.Cap
 	}
 
-	parsed, err := semver.ParseCapabilityRef(resolveRef)
-	if err != nil {
-		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: err.Error()}
+	// Get capability (following capability aliases for renamed refs)
+	parsed, cap, warnings, regErr := r.lookupCapabilityFollowingAliases(ctx, resolveRef)
+	if regErr != nil {
+		return nil, regErr
 	}
 
 	rangeStr := input.Ver
@@ -64,17 +63,8 @@ func (r *Registry) resolveLocal(ctx context.Context, input *ResolveInput, defaul
 		rangeStr = parsed.Range
 	}
 
-	// Get capability
-	cap, err := r.repo.GetCapability(ctx, parsed.App, parsed.Name)
-	if err != nil {
-		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
-	}
-	if cap == nil {
-		return nil, &RegistryError{Code: "NOT_FOUND", Message: fmt.Sprintf("Capability not found: %s", parsed.Full)}
-	}
-
 	// Get versions
-	versions, err := r.repo.GetVersions(ctx, cap.ID)
+	versions, err := r.catalog.GetVersions(ctx, cap.ID)
 	if err != nil {
 		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
 	}
@@ -84,7 +74,7 @@ func (r *Registry) resolveLocal(ctx context.Context, input *ResolveInput, defaul
 
 	// Get default major
 	env := r.getEnv(input.Ctx)
-	defaultEntry, _ := r.repo.GetDefault(ctx, cap.ID, env)
+	defaultEntry, _ := r.catalog.GetDefault(ctx, cap.ID, env)
 	defaultMajor := -1
 	if defaultEntry != nil {
 		defaultMajor = defaultEntry.DefaultMajor
@@ -93,14 +83,39 @@ func (r *Registry) resolveLocal(ctx context.Context, input *ResolveInput, defaul
 	// Convert to VersionRecords
 	records := dbVersionsToRecords(versions)
 
+	// A release named in ctx answers from its pins instead of defaults;
+	// capabilities the release does not pin fall back to normal resolution.
+	var resolved *semver.VersionRecord
+	release, regErr := r.releaseFromContext(ctx, input.Ctx)
+	if regErr != nil {
+		return nil, regErr
+	}
+	if release != nil {
+		pin, err := r.catalog.GetReleasePin(ctx, release.ID, cap.ID)
+		if err != nil {
+			return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+		}
+		if pin != nil {
+			resolved, regErr = resolvePinned(records, pin, rangeStr, env, parsed.Full, release.Name)
+			if regErr != nil {
+				return nil, regErr
+			}
+		}
+	}
+
 	// Resolve
-	resolved := semver.ResolveVersion(semver.ResolveVersionParams{
+	resolveParams := semver.ResolveVersionParams{
 		Versions:          records,
 		Range:             rangeStr,
 		DefaultMajor:      defaultMajor,
 		IncludeDeprecated: true,
 		ExcludeDisabled:   true,
-	})
+		Env:               env,
+	}
+	pinned := resolved != nil
+	if resolved == nil {
+		resolved = semver.ResolveVersion(resolveParams)
+	}
 
 	if resolved == nil {
 		return nil, &RegistryError{
@@ -109,9 +124,16 @@ func (r *Registry) resolveLocal(ctx context.Context, input *ResolveInput, defaul
 		}
 	}
 
-	// Check tenant access
+	// Versions without live provider instances are warned about or skipped per the capability's policy
+	resolved, livenessWarnings, regErr := r.applyLivenessPolicy(ctx, cap, parsed.Full, resolveParams, resolved, pinned)
+	if regErr != nil {
+		return nil, regErr
+	}
+	warnings = append(warnings, livenessWarnings...)
+
+	// Check tenant access (a mirror enforces the tenant rules copied from its upstream)
 	if input.Ctx != nil && input.Ctx.TenantID != "" {
-		allowed, reason := r.repo.CheckTenantAccess(ctx, cap.ID, resolved.Major, db.ResolutionContext{
+		allowed, reason := r.catalog.CheckTenantAccess(ctx, cap.ID, resolved.Major, db.ResolutionContext{
 			TenantID: input.Ctx.TenantID,
 			Env:      input.Ctx.Env,
 			Aud:      input.Ctx.Aud,
@@ -122,29 +144,38 @@ func (r *Registry) resolveLocal(ctx context.Context, input *ResolveInput, defaul
 		}
 	}
 
-	// Build response — always include natsUrl (local server URL)
-	subject := r.buildSubject(parsed.App, parsed.Name, resolved.Major)
+	// Build response — preferred provider endpoint, or the tenant's cell / local server URL when none are registered
+	cell := r.tenantCell(ctx, input.Ctx)
+	endpoints := r.selectEndpoints(r.versionEndpoints(ctx, resolved.ID), subjectTemplate(cap), commsutil.SubjectParams{
+		App:      cap.App,
+		Name:     cap.Name,
+		Major:    resolved.Major,
+		Minor:    resolved.Minor,
+		Env:      env,
+		TenantID: tenantOf(input.Ctx),
+	}, input.Ctx, cell)
 	canonicalIdentity := fmt.Sprintf("cap:@%s/%s/%s@%s", defaultAlias, parsed.App, parsed.Name, resolved.VersionString)
-	natsUrl := r.config.NatsUrl
-	if natsUrl == "" {
-		natsUrl = "nats://127.0.0.1:4222"
-	}
 
 	result := &ResolveOutput{
 		CanonicalIdentity: canonicalIdentity,
-		NatsUrl:           natsUrl,
-		Subject:           subject,
+		NatsUrl:           endpoints.NatsUrl,
+		Subject:           endpoints.Subject,
 		Major:             resolved.Major,
 		ResolvedVersion:   resolved.VersionString,
 		Status:            resolved.Status,
 		TTLSeconds:        r.config.DefaultTTLSeconds,
 		Etag:              fmt.Sprintf("%s-%d", cap.ID, cap.Revision),
+		Warnings:          warnings,
+		Shadow:            r.resolveShadow(ctx, cap, records, resolved, env, input.Ctx),
+		Region:            endpoints.Region,
+		Cell:              cellName(cell),
+		Alternates:        endpoints.Alternates,
 	}
 
 	// Include methods if requested
 	if input.IncludeMethods || input.IncludeSchemas {
 		versionID := resolved.ID
-		methods, err := r.repo.GetMethods(ctx, versionID)
+		methods, err := r.catalog.GetMethods(ctx, versionID)
 		if err == nil {
 			if input.IncludeMethods {
 				result.Methods = make([]MethodInfo, len(methods))
@@ -199,9 +230,27 @@ func (r *Registry) resolveRemote(ctx context.Context, input *ResolveInput, alias
 		Status:            fedResult.Status,
 		TTLSeconds:        fedResult.TTLSeconds,
 		Etag:              fedResult.Etag,
+		Warnings:          fedResult.Warnings,
+		FederationPath:    fedResult.FederationPath,
 	}, nil
 }
 
+// localNatsUrl returns the NATS URL where local capability subjects live.
+func (r *Registry) localNatsUrl() string {
+	if r.config.NatsUrl != "" {
+		return r.config.NatsUrl
+	}
+	return "nats://127.0.0.1:4222"
+}
+
+// defaultAlias returns the alias under which local capabilities are published.
+func (r *Registry) defaultAlias() string {
+	if r.config.DefaultAlias != "" {
+		return r.config.DefaultAlias
+	}
+	return defaultAlias
+}
+
 // extractAlias extracts an @alias prefix from a capability reference.
 // Returns (alias, remaining) or ("", original) if no alias found.
 // Examples:
@@ -237,6 +286,7 @@ func dbVersionsToRecords(versions []db.CapabilityVersion) []semver.VersionRecord
 			Prerelease:    pre,
 			Status:        v.Status,
 			VersionString: semver.ToVersionString(v.Major, v.Minor, v.Patch, pre),
+			Envs:          v.Envs,
 		}
 	}
 	return records
@@ -107,3 +107,24 @@ func TestResolveOutput_NatsUrlField(t *testing.T) {
 		t.Errorf("expected CanonicalIdentity to be set, got %q", output.CanonicalIdentity)
 	}
 }
+
+func TestDottedCapRef(t *testing.T) {
+	tests := map[string]string{
+		"my.app/my.cap":           "my.app.my.cap",
+		"partner/image.resize":    "partner.image.resize",
+		"partner.image.resize":    "partner.image.resize",
+		"partner/image/resize@^1": "partner.image/resize@^1",
+		"@c/billing/invoice@^1":   "@c/billing/invoice@^1",
+	}
+	for in, want := range tests {
+		if got := dottedCapRef(in); got != want {
+			t.Errorf("dottedCapRef(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestQualifiedCap(t *testing.T) {
+	if got := qualifiedCap("partner", "billing", "invoice.create"); got != "@partner/billing/invoice.create" {
+		t.Errorf("qualifiedCap = %q", got)
+	}
+}
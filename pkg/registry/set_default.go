@@ -54,16 +54,23 @@ func (r *Registry) SetDefaultMajor(ctx context.Context, input *SetDefaultMajorIn
 	// Publish event
 	revision, _ := r.repo.IncrementRevision(ctx, cap.ID)
 	newMajor := input.Major
-	_ = r.publisher.PublishChanged(ctx, &events.RegistryChangedEvent{
+	event := &events.RegistryChangedEvent{
+		Kind:            events.KindDefaultChanged,
 		App:             parsed.App,
 		Capability:      parsed.Name,
 		ChangedFields:   []string{"defaultMajor"},
 		NewDefaultMajor: &newMajor,
 		AffectedMajors:  []int{input.Major},
+		New:             map[string]interface{}{"defaultMajor": input.Major},
 		Revision:        revision,
 		Etag:            fmt.Sprintf("%s-%d", cap.ID, revision),
 		Timestamp:       time.Now().UTC().Format(time.RFC3339),
-	})
+		Env:             env,
+	}
+	if existingDefault != nil {
+		event.Previous = map[string]interface{}{"defaultMajor": existingDefault.DefaultMajor}
+	}
+	_ = r.publisher.PublishChanged(ctx, event)
 
 	result := &SetDefaultMajorOutput{
 		Success:  true,
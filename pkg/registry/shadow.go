@@ -0,0 +1,274 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/morezero/capabilities-registry/pkg/commsutil"
+	"github.com/morezero/capabilities-registry/pkg/db"
+	"github.com/morezero/capabilities-registry/pkg/events"
+	"github.com/morezero/capabilities-registry/pkg/semver"
+)
+
+const (
+	shadowLogPrefix  = "registry:shadow"
+	maxShadowTenants = 1000
+)
+
+// validateSetShadowInput checks the target and sampling rate.
+func validateSetShadowInput(input *SetShadowInput) *RegistryError {
+	if (input.TargetVersion == "") == (input.TargetMajor == nil) {
+		return &RegistryError{Code: "INVALID_ARGUMENT", Message: "exactly one of targetVersion or targetMajor is required"}
+	}
+	if input.TargetVersion != "" && !semver.IsExactVersion(input.TargetVersion) {
+		return &RegistryError{Code: "INVALID_ARGUMENT", Message: "targetVersion must be an exact version"}
+	}
+	if input.TargetMajor != nil && *input.TargetMajor < 0 {
+		return &RegistryError{Code: "INVALID_ARGUMENT", Message: "targetMajor must be >= 0"}
+	}
+	if input.SampleRate < 0 || input.SampleRate > 1 {
+		return &RegistryError{Code: "INVALID_ARGUMENT", Message: "sampleRate must be between 0 and 1"}
+	}
+	if len(input.Tenants) > maxShadowTenants {
+		return &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("tenants count exceeds maximum %d", maxShadowTenants)}
+	}
+	return nil
+}
+
+// SetShadow creates or replaces the shadow-traffic config of a capability.
+func (r *Registry) SetShadow(ctx context.Context, input *SetShadowInput, userID string) (*ShadowConfig, error) {
+	slog.Info(fmt.Sprintf("%s - setShadow cap=%s rate=%g", shadowLogPrefix, input.Cap, input.SampleRate))
+
+	if err := r.requireRepo(); err != nil {
+		return nil, err
+	}
+	if err := validateSetShadowInput(input); err != nil {
+		return nil, err
+	}
+
+	parsed, cap, regErr := r.lookupCapability(ctx, input.Cap)
+	if regErr != nil {
+		return nil, regErr
+	}
+	versions, err := r.repo.GetVersions(ctx, cap.ID)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	target := semver.ResolveVersion(semver.ResolveVersionParams{
+		Versions:          dbVersionsToRecords(versions),
+		Range:             shadowRange(input.TargetVersion, input.TargetMajor),
+		DefaultMajor:      -1,
+		IncludeDeprecated: true,
+		ExcludeDisabled:   true,
+	})
+	if target == nil {
+		return nil, &RegistryError{
+			Code:    "NOT_FOUND",
+			Message: fmt.Sprintf("No matching version for shadow target %s@%s", parsed.Full, shadowRange(input.TargetVersion, input.TargetMajor)),
+		}
+	}
+
+	previous, err := r.repo.GetShadow(ctx, cap.ID)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	var targetVersion *string
+	if input.TargetVersion != "" {
+		targetVersion = &input.TargetVersion
+	}
+	shadow, err := r.repo.UpsertShadow(ctx, db.UpsertShadowParams{
+		CapabilityID:  cap.ID,
+		TargetVersion: targetVersion,
+		TargetMajor:   input.TargetMajor,
+		SampleRate:    input.SampleRate,
+		TenantIDs:     input.Tenants,
+		UserID:        userID,
+	})
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+
+	r.publishShadowChanged(ctx, parsed, cap, []int{target.Major}, previous, shadow)
+	return shadowToConfig(parsed.Full, shadow), nil
+}
+
+// GetShadow returns the shadow-traffic config of a capability, if any.
+func (r *Registry) GetShadow(ctx context.Context, input *ShadowInput) (*GetShadowOutput, error) {
+	slog.Info(fmt.Sprintf("%s - getShadow cap=%s", shadowLogPrefix, input.Cap))
+
+	if err := r.requireCatalog(); err != nil {
+		return nil, err
+	}
+	parsed, cap, regErr := r.lookupCapability(ctx, input.Cap)
+	if regErr != nil {
+		return nil, regErr
+	}
+	shadow, err := r.catalog.GetShadow(ctx, cap.ID)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	if shadow == nil {
+		return &GetShadowOutput{}, nil
+	}
+	return &GetShadowOutput{Shadow: shadowToConfig(parsed.Full, shadow)}, nil
+}
+
+// RemoveShadow deletes the shadow-traffic config of a capability.
+func (r *Registry) RemoveShadow(ctx context.Context, input *ShadowInput) (*RemoveShadowOutput, error) {
+	slog.Info(fmt.Sprintf("%s - removeShadow cap=%s", shadowLogPrefix, input.Cap))
+
+	if err := r.requireRepo(); err != nil {
+		return nil, err
+	}
+	parsed, cap, regErr := r.lookupCapability(ctx, input.Cap)
+	if regErr != nil {
+		return nil, regErr
+	}
+	previous, err := r.repo.GetShadow(ctx, cap.ID)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	removed, err := r.repo.DeleteShadow(ctx, cap.ID)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	if removed {
+		r.publishShadowChanged(ctx, parsed, cap, []int{}, previous, nil)
+	}
+	return &RemoveShadowOutput{Removed: removed}, nil
+}
+
+// lookupCapability parses a capability ref and loads the capability, returning NOT_FOUND when missing.
+func (r *Registry) lookupCapability(ctx context.Context, capRef string) (*semver.ParsedCapabilityRef, *db.Capability, *RegistryError) {
+	parsed, err := semver.ParseCapabilityRef(capRef)
+	if err != nil {
+		return nil, nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: err.Error()}
+	}
+	cap, err := r.catalog.GetCapability(ctx, parsed.App, parsed.Name)
+	if err != nil {
+		return nil, nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	if cap == nil {
+		return nil, nil, &RegistryError{Code: "NOT_FOUND", Message: fmt.Sprintf("Capability not found: %s", parsed.Full)}
+	}
+	return parsed, cap, nil
+}
+
+// publishShadowChanged publishes a shadowChanged event carrying the shadow config before
+// and after the change (nil when there was none or it was removed).
+func (r *Registry) publishShadowChanged(ctx context.Context, parsed *semver.ParsedCapabilityRef, cap *db.Capability, affected []int, previous, next *db.CapabilityShadow) {
+	revision, _ := r.repo.IncrementRevision(ctx, cap.ID)
+	event := &events.RegistryChangedEvent{
+		Kind:           events.KindShadowChanged,
+		App:            parsed.App,
+		Capability:     parsed.Name,
+		ChangedFields:  []string{"shadow"},
+		AffectedMajors: affected,
+		Revision:       revision,
+		Etag:           fmt.Sprintf("%s-%d", cap.ID, revision),
+		Timestamp:      time.Now().UTC().Format(time.RFC3339),
+	}
+	if previous != nil {
+		event.Previous = map[string]interface{}{"shadow": shadowToConfig(parsed.Full, previous)}
+	}
+	if next != nil {
+		event.New = map[string]interface{}{"shadow": shadowToConfig(parsed.Full, next)}
+	}
+	_ = r.publisher.PublishChanged(ctx, event)
+}
+
+// resolveShadow returns the shadow target for a resolved capability, or nil when no shadow
+// applies (none configured, rate 0, tenant filtered out, or target equals the primary).
+// Failures are logged and yield nil so shadowing never breaks resolution.
+func (r *Registry) resolveShadow(ctx context.Context, cap *db.Capability, records []semver.VersionRecord, primary *semver.VersionRecord, env string, rctx *ResolutionContext) *ShadowTarget {
+	shadow, err := r.catalog.GetShadow(ctx, cap.ID)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("%s - GetShadow failed cap=%s.%s: %v", shadowLogPrefix, cap.App, cap.Name, err))
+		return nil
+	}
+	if shadow == nil || shadow.SampleRate <= 0 {
+		return nil
+	}
+	tenantID := tenantOf(rctx)
+	if !shadowAppliesToTenant(shadow.TenantIDs, tenantID) {
+		return nil
+	}
+
+	target := semver.ResolveVersion(semver.ResolveVersionParams{
+		Versions:          records,
+		Range:             shadowRange(ptrStringOr(shadow.TargetVersion, ""), shadow.TargetMajor),
+		DefaultMajor:      -1,
+		IncludeDeprecated: true,
+		ExcludeDisabled:   true,
+		Env:               env,
+	})
+	if target == nil || target.ID == primary.ID {
+		return nil
+	}
+	if tenantID != "" {
+		allowed, _ := r.catalog.CheckTenantAccess(ctx, cap.ID, target.Major, db.ResolutionContext{
+			TenantID: rctx.TenantID,
+			Env:      rctx.Env,
+			Aud:      rctx.Aud,
+			Features: rctx.Features,
+		})
+		if !allowed {
+			return nil
+		}
+	}
+
+	endpoints := r.selectEndpoints(r.versionEndpoints(ctx, target.ID), subjectTemplate(cap), commsutil.SubjectParams{
+		App:      cap.App,
+		Name:     cap.Name,
+		Major:    target.Major,
+		Minor:    target.Minor,
+		Env:      env,
+		TenantID: tenantID,
+	}, rctx, r.tenantCell(ctx, rctx))
+	return &ShadowTarget{
+		Subject:         endpoints.Subject,
+		NatsUrl:         endpoints.NatsUrl,
+		Major:           target.Major,
+		ResolvedVersion: target.VersionString,
+		SampleRate:      shadow.SampleRate,
+	}
+}
+
+// shadowRange converts a shadow target into a resolvable range.
+func shadowRange(version string, major *int) string {
+	if version != "" {
+		return version
+	}
+	if major != nil {
+		return strconv.Itoa(*major)
+	}
+	return ""
+}
+
+// shadowAppliesToTenant reports whether traffic from tenantID is mirrored; an empty filter matches all.
+func shadowAppliesToTenant(tenants []string, tenantID string) bool {
+	if len(tenants) == 0 {
+		return true
+	}
+	for _, t := range tenants {
+		if t == tenantID {
+			return true
+		}
+	}
+	return false
+}
+
+func shadowToConfig(capFull string, s *db.CapabilityShadow) *ShadowConfig {
+	return &ShadowConfig{
+		Cap:           capFull,
+		TargetVersion: ptrStringOr(s.TargetVersion, ""),
+		TargetMajor:   s.TargetMajor,
+		SampleRate:    s.SampleRate,
+		Tenants:       s.TenantIDs,
+		Modified:      s.Modified.UTC().Format(time.RFC3339),
+		ModifiedBy:    s.ModifiedBy,
+	}
+}
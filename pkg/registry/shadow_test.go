@@ -0,0 +1,79 @@
+package registry
+
+import (
+	"context"
+	"testing"
+)
+
+const shadowTestPrefix = "registry:shadow_test"
+
+func TestValidateSetShadowInput(t *testing.T) {
+	major := 4
+	negative := -1
+	tests := []struct {
+		name      string
+		input     SetShadowInput
+		expectErr bool
+	}{
+		{"major target", SetShadowInput{Cap: "more0.doc.ingest", TargetMajor: &major, SampleRate: 0.05}, false},
+		{"version target", SetShadowInput{Cap: "more0.doc.ingest", TargetVersion: "4.0.0-rc.1", SampleRate: 1}, false},
+		{"no target", SetShadowInput{Cap: "more0.doc.ingest", SampleRate: 0.1}, true},
+		{"both targets", SetShadowInput{Cap: "more0.doc.ingest", TargetVersion: "4.0.0", TargetMajor: &major, SampleRate: 0.1}, true},
+		{"range target", SetShadowInput{Cap: "more0.doc.ingest", TargetVersion: "^4.0.0", SampleRate: 0.1}, true},
+		{"negative major", SetShadowInput{Cap: "more0.doc.ingest", TargetMajor: &negative, SampleRate: 0.1}, true},
+		{"rate above one", SetShadowInput{Cap: "more0.doc.ingest", TargetMajor: &major, SampleRate: 1.5}, true},
+		{"negative rate", SetShadowInput{Cap: "more0.doc.ingest", TargetMajor: &major, SampleRate: -0.1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSetShadowInput(&tt.input)
+			if tt.expectErr && err == nil {
+				t.Errorf("%s - expected error", shadowTestPrefix)
+			}
+			if !tt.expectErr && err != nil {
+				t.Errorf("%s - unexpected error: %v", shadowTestPrefix, err)
+			}
+		})
+	}
+}
+
+func TestShadowAppliesToTenant(t *testing.T) {
+	if !shadowAppliesToTenant(nil, "") || !shadowAppliesToTenant(nil, "acme") {
+		t.Errorf("%s - empty filter should match all tenants", shadowTestPrefix)
+	}
+	if !shadowAppliesToTenant([]string{"acme", "globex"}, "globex") {
+		t.Errorf("%s - listed tenant should match", shadowTestPrefix)
+	}
+	if shadowAppliesToTenant([]string{"acme"}, "globex") || shadowAppliesToTenant([]string{"acme"}, "") {
+		t.Errorf("%s - unlisted tenant should not match", shadowTestPrefix)
+	}
+}
+
+func TestShadowRange(t *testing.T) {
+	major := 4
+	if got := shadowRange("4.1.0", nil); got != "4.1.0" {
+		t.Errorf("%s - shadowRange(version) = %q", shadowTestPrefix, got)
+	}
+	if got := shadowRange("", &major); got != "4" {
+		t.Errorf("%s - shadowRange(major) = %q", shadowTestPrefix, got)
+	}
+}
+
+func TestShadowMethods_RequireRepo(t *testing.T) {
+	reg := NewRegistry(NewRegistryParams{Repo: nil, Publisher: nil, Config: DefaultConfig()})
+	ctx := context.Background()
+	major := 2
+
+	_, err := reg.SetShadow(ctx, &SetShadowInput{Cap: "more0.doc.ingest", TargetMajor: &major, SampleRate: 0.1}, "test-user")
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INTERNAL_ERROR" {
+		t.Errorf("%s - SetShadow: expected INTERNAL_ERROR, got %v", shadowTestPrefix, err)
+	}
+	_, err = reg.GetShadow(ctx, &ShadowInput{Cap: "more0.doc.ingest"})
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INTERNAL_ERROR" {
+		t.Errorf("%s - GetShadow: expected INTERNAL_ERROR, got %v", shadowTestPrefix, err)
+	}
+	_, err = reg.RemoveShadow(ctx, &ShadowInput{Cap: "more0.doc.ingest"})
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INTERNAL_ERROR" {
+		t.Errorf("%s - RemoveShadow: expected INTERNAL_ERROR, got %v", shadowTestPrefix, err)
+	}
+}
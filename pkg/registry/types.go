@@ -1,6 +1,8 @@
 // Package registry implements the core registry business logic.
 package registry
 
+import "github.com/morezero/capabilities-registry/pkg/events"
+
 // ResolveInput holds parameters for the resolve method.
 type ResolveInput struct {
 	Cap            string             `json:"cap"`
@@ -23,6 +25,51 @@ type ResolveOutput struct {
 	ExpiresAt         string            `json:"expiresAt,omitempty"`
 	Methods           []MethodInfo      `json:"methods,omitempty"`
 	Schemas           map[string]Schema `json:"schemas,omitempty"`
+	Warnings          []Warning         `json:"warnings,omitempty"`
+	// Shadow, when present, asks the client to mirror a sampled share of requests
+	// (fire-and-forget) to another version; its responses must be ignored.
+	Shadow *ShadowTarget `json:"shadow,omitempty"`
+	// Region is the region label of the preferred endpoint (natsUrl/subject above).
+	Region string `json:"region,omitempty"`
+	// Cell is the caller tenant's NATS cell when it is routed to one.
+	Cell string `json:"cell,omitempty"`
+	// Alternates are the version's other provider endpoints, in preference order.
+	Alternates []Endpoint `json:"alternates,omitempty"`
+	// FederationPath lists the registry aliases a federated answer came through, each
+	// named by the registry before it, e.g. ["@b", "@c"] for a ref forwarded to b and on to c.
+	FederationPath []string `json:"federationPath,omitempty"`
+}
+
+// Endpoint is a provider endpoint for a capability version.
+type Endpoint struct {
+	NatsUrl  string `json:"natsUrl"`
+	Subject  string `json:"subject"`
+	Region   string `json:"region,omitempty"`
+	Zone     string `json:"zone,omitempty"`
+	Priority int    `json:"priority"`
+	Weight   int    `json:"weight"`
+}
+
+// EndpointInput registers a provider endpoint for the upserted version.
+type EndpointInput struct {
+	NatsUrl string `json:"natsUrl"`
+	// Subject is an optional subject template override for this endpoint.
+	Subject string `json:"subject,omitempty"`
+	Region  string `json:"region,omitempty"`
+	Zone    string `json:"zone,omitempty"`
+	// Priority orders endpoints after region/zone affinity; lower is preferred.
+	Priority int `json:"priority,omitempty"`
+	// Weight splits traffic between endpoints of equal priority (default 100).
+	Weight *int `json:"weight,omitempty"`
+}
+
+// ShadowTarget is the resolved shadow-traffic destination returned from resolve.
+type ShadowTarget struct {
+	Subject         string  `json:"subject"`
+	NatsUrl         string  `json:"natsUrl"`
+	Major           int     `json:"major"`
+	ResolvedVersion string  `json:"resolvedVersion"`
+	SampleRate      float64 `json:"sampleRate"`
 }
 
 // MethodInfo holds basic method information.
@@ -49,12 +96,24 @@ type DiscoverInput struct {
 	Ctx            *ResolutionContext `json:"ctx,omitempty"`
 	Page           int                `json:"page,omitempty"`
 	Limit          int                `json:"limit,omitempty"`
+	// Aliases selects the registries to search, e.g. ["main", "partner"]; empty searches
+	// only this registry. Remote registries are queried concurrently.
+	Aliases []string `json:"aliases,omitempty"`
 }
 
 // DiscoverOutput holds the result of the discover method.
 type DiscoverOutput struct {
 	Capabilities []DiscoveredCapability `json:"capabilities"`
 	Pagination   Pagination            `json:"pagination"`
+	// AliasErrors lists the selected registries that could not be searched.
+	AliasErrors []AliasError `json:"aliasErrors,omitempty"`
+}
+
+// AliasError reports a registry alias that failed during a federated call.
+type AliasError struct {
+	Alias   string `json:"alias"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
 
 // DiscoveredCapability holds discovery result for a single capability.
@@ -68,6 +127,18 @@ type DiscoveredCapability struct {
 	LatestVersion string   `json:"latestVersion"`
 	Majors        []int    `json:"majors"`
 	Status        string   `json:"status"`
+	// ReleaseVersion is the version pinned by the release named in ctx (empty when not pinned).
+	ReleaseVersion string `json:"releaseVersion,omitempty"`
+	// Instances is the number of live provider instances across the listed versions.
+	Instances int `json:"instances"`
+	// Availability is the latest probe status of LatestVersion (empty if never probed).
+	Availability string `json:"availability,omitempty"`
+	// UnreachableVersions lists listed versions whose latest probe found no responders.
+	UnreachableVersions []string `json:"unreachableVersions,omitempty"`
+	// Source is "registry", or "micro" for a capability imported from a NATS micro service.
+	Source string `json:"source"`
+	// Alias is the registry the capability was found in; set only when discover selected aliases.
+	Alias string `json:"alias,omitempty"`
 }
 
 // Pagination holds pagination information.
@@ -97,6 +168,15 @@ type DescribeOutput struct {
 	Methods     []MethodDescription `json:"methods"`
 	Tags        []string            `json:"tags"`
 	Changelog   string              `json:"changelog,omitempty"`
+	Warnings    []Warning           `json:"warnings,omitempty"`
+	// Instances is the number of live provider instances of the described version.
+	Instances int `json:"instances"`
+	// LivenessPolicy is how resolve treats versions without live instances.
+	LivenessPolicy string `json:"livenessPolicy"`
+	// Availability is the latest responder probe of the described version; nil if never probed.
+	Availability *Availability `json:"availability,omitempty"`
+	// Source is "registry", or "micro" for a capability imported from a NATS micro service.
+	Source string `json:"source"`
 }
 
 // MethodDescription holds detailed method information.
@@ -120,6 +200,19 @@ type UpsertInput struct {
 	Methods     []MethodDefinition `json:"methods"`
 	SetAsDefault bool              `json:"setAsDefault,omitempty"`
 	Env          string            `json:"env,omitempty"`
+	// SubjectTemplate sets the capability's COMMS subject template, e.g.
+	// "{env}.{prefix}.{app}.{name}.v{major}". Empty keeps the current template.
+	SubjectTemplate string `json:"subjectTemplate,omitempty"`
+	// Endpoints replaces the version's provider endpoints when present; an empty
+	// list removes them. Omitted keeps the current endpoints.
+	Endpoints []EndpointInput `json:"endpoints,omitempty"`
+	// LivenessPolicy sets how resolve treats versions with no live instances:
+	// "none" (ignore), "warn" (add a warning) or "skip" (pick another version).
+	// Empty keeps the current policy.
+	LivenessPolicy string `json:"livenessPolicy,omitempty"`
+
+	// source is set by the micro service importer; clients cannot send it.
+	source string
 }
 
 // VersionInput holds version parameters for upsert.
@@ -169,6 +262,297 @@ type SetDefaultMajorOutput struct {
 	NewMajor      int  `json:"newMajor"`
 }
 
+// PromoteInput holds parameters for the promote method.
+type PromoteInput struct {
+	Cap     string `json:"cap"`
+	Version string `json:"version,omitempty"`
+	// FromEnv, when set, must be an env the version is already available in.
+	FromEnv    string `json:"fromEnv,omitempty"`
+	ToEnv      string `json:"toEnv"`
+	SetDefault bool   `json:"setDefault,omitempty"`
+}
+
+// PromoteOutput holds the result of the promote method.
+type PromoteOutput struct {
+	Cap           string   `json:"cap"`
+	Version       string   `json:"version"`
+	FromEnv       string   `json:"fromEnv,omitempty"`
+	ToEnv         string   `json:"toEnv"`
+	Envs          []string `json:"envs,omitempty"`
+	DefaultSet    bool     `json:"defaultSet"`
+	PreviousMajor *int     `json:"previousMajor,omitempty"`
+	PromotedBy    string   `json:"promotedBy"`
+	PromotedAt    string   `json:"promotedAt"`
+}
+
+// SetShadowInput holds parameters for the setShadow method. Exactly one of
+// TargetVersion or TargetMajor must be set.
+type SetShadowInput struct {
+	Cap           string   `json:"cap"`
+	TargetVersion string   `json:"targetVersion,omitempty"`
+	TargetMajor   *int     `json:"targetMajor,omitempty"`
+	SampleRate    float64  `json:"sampleRate"`
+	Tenants       []string `json:"tenants,omitempty"`
+}
+
+// ShadowInput holds parameters for the getShadow and removeShadow methods.
+type ShadowInput struct {
+	Cap string `json:"cap"`
+}
+
+// ShadowConfig holds the shadow-traffic configuration of a capability.
+type ShadowConfig struct {
+	Cap           string   `json:"cap"`
+	TargetVersion string   `json:"targetVersion,omitempty"`
+	TargetMajor   *int     `json:"targetMajor,omitempty"`
+	SampleRate    float64  `json:"sampleRate"`
+	Tenants       []string `json:"tenants,omitempty"`
+	Modified      string   `json:"modified"`
+	ModifiedBy    string   `json:"modifiedBy"`
+}
+
+// GetShadowOutput holds the result of the getShadow method; Shadow is nil when none is set.
+type GetShadowOutput struct {
+	Shadow *ShadowConfig `json:"shadow"`
+}
+
+// RemoveShadowOutput holds the result of the removeShadow method.
+type RemoveShadowOutput struct {
+	Removed bool `json:"removed"`
+}
+
+// Warning is a non-fatal notice attached to a response (e.g. Code "renamed" when a
+// capability was reached through an alias).
+type Warning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// AddCapabilityAliasInput holds parameters for the addCapabilityAlias method.
+type AddCapabilityAliasInput struct {
+	// Alias is the old reference (e.g. "oldapp.doc.ingest") that should keep resolving.
+	Alias string `json:"alias"`
+	// Target is the current capability (app.name).
+	Target string `json:"target"`
+}
+
+// CapabilityAliasInfo describes one capability alias.
+type CapabilityAliasInfo struct {
+	Alias  string `json:"alias"`
+	Target string `json:"target"`
+	Source string `json:"source"`
+	// Kind is "rename" (resolving the alias warns) or "shorthand" (a bootstrap short name).
+	Kind    string `json:"kind"`
+	Created string `json:"created"`
+}
+
+// RemoveCapabilityAliasInput holds parameters for the removeCapabilityAlias method.
+type RemoveCapabilityAliasInput struct {
+	Alias string `json:"alias"`
+}
+
+// RemoveCapabilityAliasOutput holds the result of the removeCapabilityAlias method.
+type RemoveCapabilityAliasOutput struct {
+	Removed bool `json:"removed"`
+}
+
+// SetCellInput holds parameters for the setCell method.
+type SetCellInput struct {
+	Name    string `json:"name"`
+	NatsUrl string `json:"natsUrl"`
+	// SubjectPrefix replaces the registry subject prefix for tenants in this cell.
+	SubjectPrefix string `json:"subjectPrefix,omitempty"`
+	Description   string `json:"description,omitempty"`
+}
+
+// CellInfo describes one NATS cell.
+type CellInfo struct {
+	Name          string `json:"name"`
+	NatsUrl       string `json:"natsUrl"`
+	SubjectPrefix string `json:"subjectPrefix,omitempty"`
+	Description   string `json:"description,omitempty"`
+	TenantCount   int    `json:"tenantCount"`
+	Modified      string `json:"modified"`
+}
+
+// RemoveCellInput holds parameters for the removeCell method.
+type RemoveCellInput struct {
+	Name string `json:"name"`
+}
+
+// RemoveCellOutput holds the result of the removeCell method.
+type RemoveCellOutput struct {
+	Removed bool `json:"removed"`
+}
+
+// ListCellsOutput holds the result of the listCells method.
+type ListCellsOutput struct {
+	Cells []CellInfo `json:"cells"`
+}
+
+// AddRegistryInput holds parameters for the addRegistry method.
+type AddRegistryInput struct {
+	Alias string `json:"alias"`
+	// NatsUrl is where the remote registry is reached; empty for the local registry's alias.
+	NatsUrl string `json:"natsUrl,omitempty"`
+	// RegistrySubject defaults to cap.system.registry.v1 when NatsUrl is set.
+	RegistrySubject string                 `json:"registrySubject,omitempty"`
+	IsDefault       bool                   `json:"isDefault,omitempty"`
+	Config          map[string]interface{} `json:"config,omitempty"`
+}
+
+// UpdateRegistryInput holds parameters for the updateRegistry method. Omitted fields are
+// left unchanged.
+type UpdateRegistryInput struct {
+	Alias           string                 `json:"alias"`
+	NatsUrl         *string                `json:"natsUrl,omitempty"`
+	RegistrySubject *string                `json:"registrySubject,omitempty"`
+	IsDefault       *bool                  `json:"isDefault,omitempty"`
+	Config          map[string]interface{} `json:"config,omitempty"`
+}
+
+// RemoveRegistryInput holds parameters for the removeRegistry method.
+type RemoveRegistryInput struct {
+	Alias string `json:"alias"`
+}
+
+// RemoveRegistryOutput holds the result of the removeRegistry method.
+type RemoveRegistryOutput struct {
+	Removed bool `json:"removed"`
+}
+
+// RegistryInfo describes one registry alias.
+type RegistryInfo struct {
+	Alias           string                 `json:"alias"`
+	NatsUrl         string                 `json:"natsUrl,omitempty"`
+	RegistrySubject string                 `json:"registrySubject,omitempty"`
+	IsDefault       bool                   `json:"isDefault"`
+	Config          map[string]interface{} `json:"config,omitempty"`
+	Modified        string                 `json:"modified"`
+	// Health is nil until the alias was health checked or called.
+	Health *RemoteRegistryHealth `json:"health,omitempty"`
+}
+
+// ListRegistriesOutput holds the result of the listRegistries method.
+type ListRegistriesOutput struct {
+	Registries []RegistryInfo `json:"registries"`
+}
+
+// AssignTenantCellInput holds parameters for the assignTenantCell method.
+type AssignTenantCellInput struct {
+	TenantID string `json:"tenantId"`
+	Cell     string `json:"cell"`
+}
+
+// RemoveTenantCellInput holds parameters for the removeTenantCell method.
+type RemoveTenantCellInput struct {
+	TenantID string `json:"tenantId"`
+}
+
+// TenantCellOutput holds the result of assignTenantCell and removeTenantCell.
+type TenantCellOutput struct {
+	TenantID     string `json:"tenantId"`
+	Cell         string `json:"cell,omitempty"`
+	PreviousCell string `json:"previousCell,omitempty"`
+	// NatsUrl is where the tenant's clients connect now.
+	NatsUrl string `json:"natsUrl"`
+	// Moved is true when the tenant's cell changed and a reconnect event was published.
+	Moved bool `json:"moved"`
+}
+
+// RegisterInstanceInput holds parameters for the registerInstance method.
+type RegisterInstanceInput struct {
+	Cap     string `json:"cap"`
+	Version string `json:"version"`
+	// InstanceID identifies the instance across heartbeats; generated when empty.
+	// Registering an existing ID renews its lease and moves it to this version.
+	InstanceID string `json:"instanceId,omitempty"`
+	// TTLSeconds is the lease length; the default comes from registry config.
+	TTLSeconds int    `json:"ttlSeconds,omitempty"`
+	NatsUrl    string `json:"natsUrl,omitempty"`
+	Region     string `json:"region,omitempty"`
+	Zone       string `json:"zone,omitempty"`
+}
+
+// HeartbeatInput holds parameters for the heartbeat method.
+type HeartbeatInput struct {
+	InstanceID string `json:"instanceId"`
+	// TTLSeconds extends the lease by this long from now; the default comes from registry config.
+	TTLSeconds int `json:"ttlSeconds,omitempty"`
+}
+
+// DeregisterInstanceInput holds parameters for the deregisterInstance method.
+type DeregisterInstanceInput struct {
+	InstanceID string `json:"instanceId"`
+}
+
+// InstanceLease is the result of registerInstance and heartbeat.
+type InstanceLease struct {
+	InstanceID     string `json:"instanceId"`
+	Cap            string `json:"cap"`
+	Version        string `json:"version"`
+	Major          int    `json:"major"`
+	TTLSeconds     int    `json:"ttlSeconds"`
+	LeaseExpiresAt string `json:"leaseExpiresAt"`
+	// LiveInstances is the version's live instance count including this one.
+	LiveInstances int `json:"liveInstances"`
+}
+
+// DeregisterInstanceOutput holds the result of the deregisterInstance method.
+type DeregisterInstanceOutput struct {
+	Removed       bool `json:"removed"`
+	LiveInstances int  `json:"liveInstances"`
+}
+
+// Availability is the latest responder probe of a version subject.
+type Availability struct {
+	// Status is reachable, unreachable (no responders), timeout, error, or unknown when the
+	// version is served on other NATS deployments the registry cannot probe.
+	Status     string `json:"status"`
+	Reachable  bool   `json:"reachable"`
+	Subject    string `json:"subject"`
+	LatencyMs  *int   `json:"latencyMs,omitempty"`
+	LastSeen   string `json:"lastSeen,omitempty"`
+	LastProbed string `json:"lastProbed"`
+	Error      string `json:"error,omitempty"`
+}
+
+// ProbeInput holds parameters for the probe method.
+type ProbeInput struct {
+	Cap string `json:"cap"`
+	// Version probes one exact version; empty probes every non-disabled version.
+	Version string `json:"version,omitempty"`
+}
+
+// ProbeOutput holds the result of the probe method.
+type ProbeOutput struct {
+	Cap      string         `json:"cap"`
+	Versions []VersionProbe `json:"versions"`
+}
+
+// VersionProbe is the probe result of one version.
+type VersionProbe struct {
+	Version      string       `json:"version"`
+	Availability Availability `json:"availability"`
+}
+
+// MicroImportOutput is the result of one NATS micro service import round.
+type MicroImportOutput struct {
+	// Services is the number of $SRV.INFO replies (service instances) received.
+	Services int `json:"services"`
+	// Imported lists the capability versions created or updated, as app.name@version.
+	Imported []string `json:"imported"`
+	// Unchanged counts versions already registered with the same methods.
+	Unchanged int               `json:"unchanged"`
+	Skipped   []MicroImportSkip `json:"skipped,omitempty"`
+}
+
+// MicroImportSkip explains why a micro service was not imported.
+type MicroImportSkip struct {
+	Service string `json:"service"`
+	Reason  string `json:"reason"`
+}
+
 // DeprecateInput holds parameters for the deprecate method.
 type DeprecateInput struct {
 	Cap     string `json:"cap"`
@@ -205,7 +589,8 @@ type ListMajorsInput struct {
 
 // ListMajorsOutput holds the result of the listMajors method.
 type ListMajorsOutput struct {
-	Majors []MajorInfo `json:"majors"`
+	Majors   []MajorInfo `json:"majors"`
+	Warnings []Warning   `json:"warnings,omitempty"`
 }
 
 // MajorInfo holds information about a major version.
@@ -222,6 +607,55 @@ type HealthOutput struct {
 	Status    string       `json:"status"`
 	Checks    HealthChecks `json:"checks"`
 	Timestamp string       `json:"timestamp"`
+	// Availability summarizes the latest probes of served versions; nil without a repository.
+	Availability *AvailabilitySummary `json:"availability,omitempty"`
+	// Registries is the health of the remote registries behind aliases, from the periodic
+	// health checks and federated requests.
+	Registries []RemoteRegistryHealth `json:"registries,omitempty"`
+	// Mirror is the replication state of a registry in mirror mode; nil otherwise.
+	Mirror *MirrorHealth `json:"mirror,omitempty"`
+}
+
+// MirrorHealth is the replication state of a mirror registry.
+type MirrorHealth struct {
+	// Upstream is the registry alias the mirror syncs from.
+	Upstream string `json:"upstream"`
+	// Status is "syncing" before the first full sync, "current" while change events are
+	// applied over an unbroken connection since the last full sync, and "lagging" otherwise.
+	Status string `json:"status"`
+	// LagSeconds is 0 while current, otherwise the time since the last full sync started;
+	// nil before the first full sync.
+	LagSeconds   *int   `json:"lagSeconds,omitempty"`
+	LastSync     string `json:"lastSync,omitempty"`
+	LastEvent    string `json:"lastEvent,omitempty"`
+	Capabilities int    `json:"capabilities"`
+	LastError    string `json:"lastError,omitempty"`
+}
+
+// RemoteRegistryHealth is the health and circuit breaker state of one remote registry alias.
+type RemoteRegistryHealth struct {
+	Alias string `json:"alias"`
+	// Status is the remote's own health status, "unreachable" when its health check got no
+	// reply, or "unknown" before the first check.
+	Status string `json:"status"`
+	// Circuit is closed, open (requests fail fast with REGISTRY_UNAVAILABLE) or half-open
+	// (the next request is a trial).
+	Circuit             string `json:"circuit"`
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+	LatencyMs           *int   `json:"latencyMs,omitempty"`
+	LastChecked         string `json:"lastChecked,omitempty"`
+	LastSuccess         string `json:"lastSuccess,omitempty"`
+	LastError           string `json:"lastError,omitempty"`
+}
+
+// AvailabilitySummary counts served versions by their latest probe status.
+type AvailabilitySummary struct {
+	Reachable   int    `json:"reachable"`
+	Unreachable int    `json:"unreachable"`
+	Timeout     int    `json:"timeout"`
+	Error       int    `json:"error"`
+	Unknown     int    `json:"unknown"`
+	LastProbed  string `json:"lastProbed,omitempty"`
 }
 
 // HealthChecks holds individual health check results.
@@ -230,12 +664,391 @@ type HealthChecks struct {
 	COMMS    bool `json:"comms,omitempty"`
 }
 
+// CreateReleaseInput holds parameters for the createRelease method.
+type CreateReleaseInput struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Pins        []ReleasePinInput `json:"pins"`
+}
+
+// ReleasePinInput pins a capability to an exact version. Version may be omitted
+// when Cap carries it (e.g. "more0.doc.ingest@3.2.1").
+type ReleasePinInput struct {
+	Cap     string `json:"cap"`
+	Version string `json:"version,omitempty"`
+}
+
+// CreateReleaseOutput holds the result of the createRelease method.
+type CreateReleaseOutput struct {
+	Name     string `json:"name"`
+	PinCount int    `json:"pinCount"`
+	Created  string `json:"created"`
+}
+
+// DescribeReleaseInput holds parameters for the describeRelease method.
+type DescribeReleaseInput struct {
+	Name string `json:"name"`
+}
+
+// DescribeReleaseOutput holds the result of the describeRelease method.
+type DescribeReleaseOutput struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Created     string           `json:"created"`
+	CreatedBy   string           `json:"createdBy"`
+	Pins        []ReleasePinInfo `json:"pins"`
+}
+
+// ReleasePinInfo holds one pinned capability version of a release.
+type ReleasePinInfo struct {
+	Cap     string `json:"cap"`
+	Version string `json:"version"`
+	Major   int    `json:"major"`
+	Status  string `json:"status"`
+}
+
+// ListReleasesOutput holds the result of the listReleases method.
+type ListReleasesOutput struct {
+	Releases []ReleaseSummary `json:"releases"`
+}
+
+// ReleaseSummary holds list information about a release.
+type ReleaseSummary struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Created     string `json:"created"`
+	PinCount    int    `json:"pinCount"`
+}
+
+// LockInput holds parameters for the lock method.
+type LockInput struct {
+	// Caps are capability refs with optional ranges (e.g. "more0.doc.ingest@^3.2.0").
+	Caps []string           `json:"caps"`
+	Ctx  *ResolutionContext `json:"ctx,omitempty"`
+}
+
+// Lockfile is the reproducible result of the lock method and the input to verifyLock.
+type Lockfile struct {
+	LockfileVersion int                `json:"lockfileVersion"`
+	Generated       string             `json:"generated"`
+	Ctx             *ResolutionContext `json:"ctx,omitempty"`
+	Entries         []LockEntry        `json:"entries"`
+}
+
+// LockEntry holds the exact resolution of one requested capability ref.
+type LockEntry struct {
+	Ref               string `json:"ref"`
+	Cap               string `json:"cap"`
+	CanonicalIdentity string `json:"canonicalIdentity"`
+	Version           string `json:"version"`
+	Major             int    `json:"major"`
+	Subject           string `json:"subject"`
+	Status            string `json:"status"`
+	// Digest is a sha256 over the version's method names, modes and schemas.
+	Digest string `json:"digest"`
+}
+
+// VerifyLockInput holds parameters for the verifyLock method.
+type VerifyLockInput struct {
+	Lock Lockfile `json:"lock"`
+}
+
+// VerifyLockOutput holds the result of the verifyLock method.
+type VerifyLockOutput struct {
+	Valid   bool        `json:"valid"`
+	Checked int         `json:"checked"`
+	Issues  []LockIssue `json:"issues"`
+}
+
+// LockIssue reports a lock entry that no longer matches the live registry.
+// Problem is one of: deprecated, disabled, yanked (version removed), missing
+// (capability removed), unavailable (not available in the lock's env, or not resolvable for
+// the lock's context), changed (digest differs),
+// renamed (capability now reached through an alias).
+type LockIssue struct {
+	Cap     string `json:"cap"`
+	Version string `json:"version"`
+	Problem string `json:"problem"`
+	Message string `json:"message"`
+}
+
+// ExportCatalogInput holds parameters for the exportCatalog method.
+type ExportCatalogInput struct {
+	// Cap exports a single capability ("app.name"); App exports one app's capabilities.
+	Cap   string `json:"cap,omitempty"`
+	App   string `json:"app,omitempty"`
+	Page  int    `json:"page,omitempty"`
+	Limit int    `json:"limit,omitempty"`
+	// Routing exports the registry-wide cells, tenant cells and releases instead of
+	// capabilities.
+	Routing bool `json:"routing,omitempty"`
+}
+
+// ExportCatalogOutput holds a page of the catalog, ordered by app and name.
+type ExportCatalogOutput struct {
+	Capabilities []CatalogCapability `json:"capabilities"`
+	Pagination   Pagination          `json:"pagination"`
+	// Routing is set only when the input asked for it.
+	Routing *CatalogRouting `json:"routing,omitempty"`
+}
+
+// CatalogRouting is the registry-wide state a mirror copies besides capabilities.
+type CatalogRouting struct {
+	Cells       []CatalogCell       `json:"cells"`
+	TenantCells []CatalogTenantCell `json:"tenantCells"`
+	Releases    []CatalogRelease    `json:"releases"`
+}
+
+// CatalogCell is a cell tenants can be mapped to.
+type CatalogCell struct {
+	Name          string `json:"name"`
+	NatsUrl       string `json:"natsUrl"`
+	SubjectPrefix string `json:"subjectPrefix,omitempty"`
+	Description   string `json:"description,omitempty"`
+}
+
+// CatalogTenantCell maps a tenant to a cell by name.
+type CatalogTenantCell struct {
+	TenantID string `json:"tenantId"`
+	Cell     string `json:"cell"`
+}
+
+// CatalogRelease is a named release with its pinned versions.
+type CatalogRelease struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description,omitempty"`
+	Pins        []CatalogReleasePin `json:"pins"`
+}
+
+// CatalogReleasePin pins a capability ("app.name") to one version string.
+type CatalogReleasePin struct {
+	Cap     string `json:"cap"`
+	Version string `json:"version"`
+}
+
+// CatalogCapability is a capability with all its versions and defaults, as copied by a mirror.
+type CatalogCapability struct {
+	App             string           `json:"app"`
+	Name            string           `json:"name"`
+	Description     string           `json:"description,omitempty"`
+	Tags            []string         `json:"tags"`
+	SubjectTemplate string           `json:"subjectTemplate,omitempty"`
+	LivenessPolicy  string           `json:"livenessPolicy,omitempty"`
+	Revision        int              `json:"revision"`
+	Defaults        []CatalogDefault `json:"defaults"`
+	Versions        []CatalogVersion `json:"versions"`
+	// TenantRules replace the mirror's tenant rules of the capability; absent (from an
+	// upstream that does not export them) leaves the stored rules alone.
+	TenantRules []CatalogTenantRule `json:"tenantRules"`
+	// Shadow is the capability's shadow config; absent means none.
+	Shadow *CatalogShadow `json:"shadow,omitempty"`
+	// Aliases are the capability aliases pointing at the capability. Like TenantRules,
+	// absent leaves the mirror's copied aliases alone.
+	Aliases []CatalogAlias `json:"aliases"`
+}
+
+// CatalogShadow is a shadow config: a sample of resolves is also routed to the target.
+type CatalogShadow struct {
+	TargetVersion string   `json:"targetVersion,omitempty"`
+	TargetMajor   *int     `json:"targetMajor,omitempty"`
+	SampleRate    float64  `json:"sampleRate"`
+	TenantIDs     []string `json:"tenantIds,omitempty"`
+}
+
+// CatalogAlias is an old "app.name" that resolves to the capability.
+type CatalogAlias struct {
+	Alias string `json:"alias"`
+	Kind  string `json:"kind"`
+}
+
+// CatalogTenantRule is a tenant access rule of a capability, evaluated in priority order
+// (lower first) by resolve. Empty TenantID and Env match every tenant and env.
+type CatalogTenantRule struct {
+	TenantID         string   `json:"tenantId,omitempty"`
+	Env              string   `json:"env,omitempty"`
+	Aud              string   `json:"aud,omitempty"`
+	RuleType         string   `json:"ruleType"`
+	AllowedMajors    []int    `json:"allowedMajors,omitempty"`
+	DeniedMajors     []int    `json:"deniedMajors,omitempty"`
+	RequiredFeatures []string `json:"requiredFeatures,omitempty"`
+	Priority         int      `json:"priority"`
+}
+
+// CatalogDefault is the default major of a capability in one env.
+type CatalogDefault struct {
+	Env   string `json:"env"`
+	Major int    `json:"major"`
+}
+
+// CatalogVersion is one capability version with its methods and provider endpoints.
+type CatalogVersion struct {
+	Major             int                    `json:"major"`
+	Minor             int                    `json:"minor"`
+	Patch             int                    `json:"patch"`
+	Prerelease        string                 `json:"prerelease,omitempty"`
+	Status            string                 `json:"status"`
+	DeprecationReason string                 `json:"deprecationReason,omitempty"`
+	Description       string                 `json:"description,omitempty"`
+	Changelog         string                 `json:"changelog,omitempty"`
+	Metadata          map[string]interface{} `json:"metadata,omitempty"`
+	Envs              []string               `json:"envs,omitempty"`
+	Methods           []MethodDefinition     `json:"methods"`
+	Endpoints         []EndpointInput        `json:"endpoints"`
+}
+
+// ChangesSinceInput holds parameters for the changesSince method.
+type ChangesSinceInput struct {
+	// Since is the last sequence the caller applied; 0 reads the feed from its start.
+	Since int64 `json:"since"`
+	Limit int   `json:"limit,omitempty"`
+}
+
+// ChangesSinceOutput holds the change events after a sequence, oldest first.
+type ChangesSinceOutput struct {
+	Changes []events.RegistryChangedEvent `json:"changes"`
+	// Next is the sequence to pass as since on the following call.
+	Next int64 `json:"next"`
+	// Latest is the newest sequence of the registry.
+	Latest  int64 `json:"latest"`
+	HasMore bool  `json:"hasMore"`
+	// Resync means the changes after since are no longer available (or since is ahead of
+	// the registry): rebuild state from discover/exportCatalog and continue from Latest.
+	Resync bool `json:"resync"`
+}
+
+// WatchInput holds parameters for the watch method. With WatchID set, it renews that
+// watch's lease instead of creating a watch, and the other fields are ignored.
+type WatchInput struct {
+	// Refs are local capability refs with optional ranges (e.g. "more0.doc.ingest@^3").
+	Refs []string           `json:"refs,omitempty"`
+	Ctx  *ResolutionContext `json:"ctx,omitempty"`
+	// Inbox is the reply inbox WatchUpdates are published to, under "_INBOX." or the
+	// configured WatchInboxPrefix.
+	Inbox string `json:"inbox,omitempty"`
+	// LeaseSeconds is how long the watch lives without a renewal; the default is 60 and the maximum 600.
+	LeaseSeconds int    `json:"leaseSeconds,omitempty"`
+	WatchID      string `json:"watchId,omitempty"`
+}
+
+// WatchOutput holds a created or renewed watch. Resolutions and Errors hold the current
+// answer of each ref when the watch is created.
+type WatchOutput struct {
+	WatchID      string                    `json:"watchId"`
+	LeaseSeconds int                       `json:"leaseSeconds"`
+	ExpiresAt    string                    `json:"expiresAt"`
+	Resolutions  map[string]*ResolveOutput `json:"resolutions,omitempty"`
+	Errors       map[string]*RegistryError `json:"errors,omitempty"`
+}
+
+// WatchUpdate is published to a watch's inbox when the resolved subject, version or status
+// of a ref changes (Resolution), when the ref stops resolving (Error), and once when the
+// watch ends (Ended: "unwatched" or "expired").
+type WatchUpdate struct {
+	WatchID    string         `json:"watchId"`
+	Ref        string         `json:"ref,omitempty"`
+	Resolution *ResolveOutput `json:"resolution,omitempty"`
+	Error      *RegistryError `json:"error,omitempty"`
+	Ended      string         `json:"ended,omitempty"`
+}
+
+// UnwatchInput holds parameters for the unwatch method.
+type UnwatchInput struct {
+	WatchID string `json:"watchId"`
+}
+
+// UnwatchOutput holds the result of the unwatch method.
+type UnwatchOutput struct {
+	Removed bool `json:"removed"`
+}
+
+// SetWebhookInput holds parameters for the setWebhook method.
+type SetWebhookInput struct {
+	Name string `json:"name"`
+	// URL receives change events as signed HTTP POSTs (http or https).
+	URL string `json:"url"`
+	// Secret is the HMAC-SHA256 signing key. Empty generates one for a new webhook and
+	// keeps the current one of an existing webhook.
+	Secret string `json:"secret,omitempty"`
+	// App, Capability and ChangedFields filter the events; empty matches every event. An
+	// event matches ChangedFields when it changed any of them.
+	App           string   `json:"app,omitempty"`
+	Capability    string   `json:"capability,omitempty"`
+	ChangedFields []string `json:"changedFields,omitempty"`
+	Description   string   `json:"description,omitempty"`
+}
+
+// WebhookInfo describes one webhook. Secret is only returned by setWebhook, when it was
+// set or generated by that call.
+type WebhookInfo struct {
+	Name                string   `json:"name"`
+	URL                 string   `json:"url"`
+	Secret              string   `json:"secret,omitempty"`
+	App                 string   `json:"app,omitempty"`
+	Capability          string   `json:"capability,omitempty"`
+	ChangedFields       []string `json:"changedFields"`
+	Description         string   `json:"description,omitempty"`
+	Enabled             bool     `json:"enabled"`
+	ConsecutiveFailures int      `json:"consecutiveFailures"`
+	DisabledReason      string   `json:"disabledReason,omitempty"`
+	Modified            string   `json:"modified"`
+}
+
+// RemoveWebhookInput holds parameters for the removeWebhook method.
+type RemoveWebhookInput struct {
+	Name string `json:"name"`
+}
+
+// RemoveWebhookOutput holds the result of the removeWebhook method.
+type RemoveWebhookOutput struct {
+	Removed bool `json:"removed"`
+}
+
+// ListWebhooksOutput holds the result of the listWebhooks method.
+type ListWebhooksOutput struct {
+	Webhooks []WebhookInfo `json:"webhooks"`
+}
+
+// ListWebhookDeliveriesInput holds parameters for the listWebhookDeliveries method.
+type ListWebhookDeliveriesInput struct {
+	Name  string `json:"name"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+// WebhookDeliveryInfo is one change event sent to a webhook: pending (being retried),
+// succeeded, failed (out of attempts) or cancelled (the webhook was disabled).
+type WebhookDeliveryInfo struct {
+	ID         string `json:"id"`
+	Sequence   int64  `json:"sequence,omitempty"`
+	App        string `json:"app"`
+	Capability string `json:"capability"`
+	Status     string `json:"status"`
+	Attempts   int    `json:"attempts"`
+	// StatusCode and Error describe the last attempt.
+	StatusCode  int    `json:"statusCode,omitempty"`
+	Error       string `json:"error,omitempty"`
+	LastAttempt string `json:"lastAttempt,omitempty"`
+	NextAttempt string `json:"nextAttempt,omitempty"`
+	Created     string `json:"created"`
+}
+
+// ListWebhookDeliveriesOutput holds a webhook's deliveries, newest first.
+type ListWebhookDeliveriesOutput struct {
+	Name       string                `json:"name"`
+	Deliveries []WebhookDeliveryInfo `json:"deliveries"`
+}
+
 // ResolutionContext provides multi-tenant context for resolution.
 type ResolutionContext struct {
 	TenantID string   `json:"tenantId,omitempty"`
 	Env      string   `json:"env,omitempty"`
 	Aud      string   `json:"aud,omitempty"`
 	Features []string `json:"features,omitempty"`
+	// Release pins resolution to a named release bundle instead of defaults.
+	Release string `json:"release,omitempty"`
+	// Region and Zone are the caller's placement hints; endpoints in the same
+	// zone, then region, are preferred.
+	Region string `json:"region,omitempty"`
+	Zone   string `json:"zone,omitempty"`
 }
 
 // RegistryError is a structured error from the registry.
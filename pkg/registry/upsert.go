@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"time"
 
+	"github.com/morezero/capabilities-registry/pkg/commsutil"
 	"github.com/morezero/capabilities-registry/pkg/db"
 	"github.com/morezero/capabilities-registry/pkg/events"
 	"github.com/morezero/capabilities-registry/pkg/semver"
@@ -29,6 +30,17 @@ func validateUpsertInput(input *UpsertInput) *RegistryError {
 	if !semver.ValidateCapabilityName(input.Name) {
 		return &RegistryError{Code: "INVALID_ARGUMENT", Message: "name must start with a letter and contain only letters, digits, dots, hyphens, underscores"}
 	}
+	if input.SubjectTemplate != "" {
+		if err := commsutil.ValidateSubjectTemplate(input.SubjectTemplate); err != nil {
+			return &RegistryError{Code: "INVALID_ARGUMENT", Message: err.Error()}
+		}
+	}
+	if err := validateEndpoints(input.Endpoints); err != nil {
+		return err
+	}
+	if err := validateLivenessPolicy(input.LivenessPolicy); err != nil {
+		return err
+	}
 	v := &input.Version
 	if v.Major < 0 || v.Major > maxVersionComponent || v.Minor < 0 || v.Minor > maxVersionComponent || v.Patch < 0 || v.Patch > maxVersionComponent {
 		return &RegistryError{Code: "INVALID_ARGUMENT", Message: "version major, minor, patch must be 0-9999"}
@@ -114,12 +126,19 @@ func (r *Registry) Upsert(ctx context.Context, input *UpsertInput, userID string
 	if input.Description != "" {
 		desc = &input.Description
 	}
+	var subjectTmpl *string
+	if input.SubjectTemplate != "" {
+		subjectTmpl = &input.SubjectTemplate
+	}
 	cap, err := r.repo.UpsertCapability(ctx, db.UpsertCapabilityParams{
-		App:         input.App,
-		Name:        input.Name,
-		Description: desc,
-		Tags:        input.Tags,
-		UserID:      userID,
+		App:             input.App,
+		Name:            input.Name,
+		Description:     desc,
+		Tags:            input.Tags,
+		SubjectTemplate: subjectTmpl,
+		LivenessPolicy:  optionalString(input.LivenessPolicy),
+		Source:          orDefault(input.source, db.CapabilitySourceRegistry),
+		UserID:          userID,
 	})
 	if err != nil {
 		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
@@ -143,6 +162,7 @@ func (r *Registry) Upsert(ctx context.Context, input *UpsertInput, userID string
 		Description:  vDesc,
 		Changelog:    vChangelog,
 		Metadata:     input.Version.Metadata,
+		Envs:         upsertEnvs(input.Env),
 		UserID:       userID,
 	})
 	if err != nil {
@@ -176,12 +196,32 @@ func (r *Registry) Upsert(ctx context.Context, input *UpsertInput, userID string
 		}
 	}
 
+	// Replace provider endpoints when the caller sent a list (empty clears them)
+	changedFields := []string{"version", "methods"}
+	if input.Endpoints != nil {
+		if _, err := r.repo.ReplaceEndpoints(ctx, version.ID, endpointParams(input.Endpoints), userID); err != nil {
+			return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+		}
+		changedFields = append(changedFields, "endpoints")
+	}
+	if input.LivenessPolicy != "" && input.LivenessPolicy != livenessPolicy(existingCap) {
+		changedFields = append(changedFields, "livenessPolicy")
+	}
+	if existingCap != nil && existingCap.Source != cap.Source {
+		changedFields = append(changedFields, "source")
+	}
+
 	// Set as default if requested
+	var newDefault *int
+	var previous, next map[string]interface{}
 	if input.SetAsDefault {
 		env := input.Env
 		if env == "" {
 			env = r.config.DefaultEnv
 		}
+		if existingDefault, _ := r.repo.GetDefault(ctx, cap.ID, env); existingDefault != nil {
+			previous = map[string]interface{}{"defaultMajor": existingDefault.DefaultMajor}
+		}
 		_, err := r.repo.SetDefault(ctx, db.SetDefaultParams{
 			CapabilityID: cap.ID,
 			Major:        input.Version.Major,
@@ -191,6 +231,10 @@ func (r *Registry) Upsert(ctx context.Context, input *UpsertInput, userID string
 		if err != nil {
 			return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
 		}
+		major := input.Version.Major
+		newDefault = &major
+		next = map[string]interface{}{"defaultMajor": major}
+		changedFields = append(changedFields, "defaultMajor")
 	}
 
 	// Increment revision and publish event
@@ -199,23 +243,40 @@ func (r *Registry) Upsert(ctx context.Context, input *UpsertInput, userID string
 		slog.Error(fmt.Sprintf("%s - IncrementRevision failed: %v", upsertLogPrefix, revErr))
 		revision = cap.Revision
 	}
+	pre := ""
+	if prerelease != nil {
+		pre = *prerelease
+	}
+	versionString := semver.ToVersionString(input.Version.Major, input.Version.Minor, input.Version.Patch, pre)
+	kind := events.KindVersionPublished
+	if existingVersion != nil {
+		kind = events.KindVersionUpdated
+	}
 	if err := r.publisher.PublishChanged(ctx, &events.RegistryChangedEvent{
-		App:            input.App,
-		Capability:     input.Name,
-		ChangedFields:  []string{"version", "methods"},
-		AffectedMajors: []int{input.Version.Major},
-		Revision:       revision,
-		Etag:           fmt.Sprintf("%s-%d", cap.ID, revision),
-		Timestamp:      time.Now().UTC().Format(time.RFC3339),
+		Kind:             kind,
+		App:              input.App,
+		Capability:       input.Name,
+		ChangedFields:    changedFields,
+		NewDefaultMajor:  newDefault,
+		AffectedMajors:   []int{input.Version.Major},
+		AffectedVersions: []string{versionString},
+		Previous:         previous,
+		New:              next,
+		Revision:         revision,
+		Etag:             fmt.Sprintf("%s-%d", cap.ID, revision),
+		Timestamp:        time.Now().UTC().Format(time.RFC3339),
+		Env:              input.Env,
 	}); err != nil {
 		slog.Error(fmt.Sprintf("%s - PublishChanged failed: %v", upsertLogPrefix, err))
 	}
 
-	pre := ""
-	if prerelease != nil {
-		pre = *prerelease
-	}
-	subject := r.buildSubject(input.App, input.Name, input.Version.Major)
+	subject := r.buildSubject(subjectTemplate(cap), commsutil.SubjectParams{
+		App:   input.App,
+		Name:  input.Name,
+		Major: input.Version.Major,
+		Minor: input.Version.Minor,
+		Env:   orDefault(input.Env, r.config.DefaultEnv),
+	})
 
 	action := "created"
 	if existingCap != nil || existingVersion != nil {
@@ -227,7 +288,16 @@ func (r *Registry) Upsert(ctx context.Context, input *UpsertInput, userID string
 		CapabilityID: cap.ID,
 		VersionID:    version.ID,
 		Cap:          fmt.Sprintf("%s.%s", input.App, input.Name),
-		Version:      semver.ToVersionString(input.Version.Major, input.Version.Minor, input.Version.Patch, pre),
+		Version:      versionString,
 		Subject:      subject,
 	}, nil
 }
+
+// upsertEnvs scopes a newly created version to the env it was published for.
+// Without an env the version is available everywhere; use promote to widen a scoped version.
+func upsertEnvs(env string) []string {
+	if env == "" {
+		return nil
+	}
+	return []string{env}
+}
@@ -244,3 +244,31 @@ func TestValidateUpsertInput_ExamplesTooLarge(t *testing.T) {
 		t.Errorf("%s - Code = %q, want INVALID_ARGUMENT", upsertTestPrefix, err.Code)
 	}
 }
+
+func TestValidateUpsertInput_SubjectTemplate(t *testing.T) {
+	tests := []struct {
+		template  string
+		expectErr bool
+	}{
+		{"", false},
+		{"{env}.{prefix}.{app}.{name}.v{major}", false},
+		{"{prefix}.{app}.{name}.v{major}.s{tenantShard}", false},
+		{"{prefix}.{app}.{nme}.v{major}", true},
+		{"{prefix}.{app}.*", true},
+		{"{prefix}..{name}", true},
+	}
+	for _, tt := range tests {
+		err := validateUpsertInput(&UpsertInput{
+			App: "validapp", Name: "validname",
+			Version:         VersionInput{Major: 1},
+			Methods:         []MethodDefinition{{Name: "run", Modes: []string{"sync"}}},
+			SubjectTemplate: tt.template,
+		})
+		if tt.expectErr && (err == nil || err.Code != "INVALID_ARGUMENT") {
+			t.Errorf("%s - subjectTemplate %q: expected INVALID_ARGUMENT, got %v", upsertTestPrefix, tt.template, err)
+		}
+		if !tt.expectErr && err != nil {
+			t.Errorf("%s - subjectTemplate %q: unexpected error %v", upsertTestPrefix, tt.template, err)
+		}
+	}
+}
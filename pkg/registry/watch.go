@@ -0,0 +1,452 @@
+package registry
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	comms "github.com/nats-io/nats.go"
+
+	"github.com/morezero/capabilities-registry/pkg/commsutil"
+	"github.com/morezero/capabilities-registry/pkg/events"
+	"github.com/morezero/capabilities-registry/pkg/semver"
+)
+
+const (
+	watchLogPrefix    = "registry:watch"
+	watchDefaultLease = 60 * time.Second
+	watchMaxLease     = 10 * time.Minute
+	maxWatchRefs      = 100
+	// watchControlPrefix is followed by the watch ID: the registry replica holding a watch
+	// answers its renew and unwatch requests there, whichever replica received them.
+	watchControlPrefix  = "registry.watch."
+	watchControlTimeout = 2 * time.Second
+	// watchResolveTimeout bounds the re-resolution of a watch after a change event.
+	watchResolveTimeout = 10 * time.Second
+)
+
+// watchHub holds the watches of this registry replica and the change event subscription
+// that refreshes them.
+type watchHub struct {
+	mu      sync.Mutex
+	watches map[string]*watch
+	changes *comms.Subscription
+	// resolve answers one ref of a watch; replaced in tests.
+	resolve func(ctx context.Context, ref string, rctx *ResolutionContext) (*ResolveOutput, error)
+}
+
+// watch is one client's watch over a set of refs. The lease fields are guarded by watchHub.mu.
+type watch struct {
+	id    string
+	inbox string
+	rctx  *ResolutionContext
+	nc    *comms.Conn
+	// mu serializes refreshes so updates reach the inbox in order.
+	mu      sync.Mutex
+	refs    []*watchRef
+	lease   time.Duration
+	expires time.Time
+	timer   *time.Timer
+	control *comms.Subscription
+}
+
+// watchRef is one watched ref with the capability its change events arrive for.
+type watchRef struct {
+	ref string
+	// cap is the "app.name" the ref currently resolves to.
+	cap string
+	// last is the watchState last sent for the ref.
+	last string
+}
+
+// watchControl is a renew or unwatch request on a watch's control subject.
+type watchControl struct {
+	Op           string `json:"op"`
+	LeaseSeconds int    `json:"leaseSeconds,omitempty"`
+}
+
+func newWatchHub(r *Registry) *watchHub {
+	return &watchHub{watches: map[string]*watch{}, resolve: r.resolveWatchRef}
+}
+
+// watchReservedPrefixes are subjects a watch inbox may never have: the registry publishes
+// updates with its own credentials, so an inbox there would forge capability calls, change
+// events or system requests.
+var watchReservedPrefixes = []string{"registry.", "system.", "$"}
+
+// validateWatchInbox checks that inbox is a reply inbox: a subject without wildcards under
+// "_INBOX." or the configured WatchInboxPrefix, and not a registry or capability subject.
+func (r *Registry) validateWatchInbox(inbox string) *RegistryError {
+	if inbox == "" || strings.ContainsAny(inbox, "*> \t") {
+		return &RegistryError{Code: "INVALID_ARGUMENT", Message: "inbox must be a subject without wildcards"}
+	}
+	prefixes := []string{defaultWatchInboxPrefix}
+	if r.config.WatchInboxPrefix != "" {
+		prefixes = append(prefixes, strings.TrimSuffix(r.config.WatchInboxPrefix, ".")+".")
+	}
+	inboxPrefix := false
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(inbox, prefix) && len(inbox) > len(prefix) {
+			inboxPrefix = true
+		}
+	}
+	if !inboxPrefix {
+		return &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("inbox must be a reply inbox under %s", strings.Join(prefixes, " or "))}
+	}
+	for _, reserved := range append(watchReservedPrefixes, r.config.SubjectPrefix+".") {
+		if strings.HasPrefix(inbox, reserved) {
+			return &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("inbox must not be a %s subject", strings.TrimSuffix(reserved, "."))}
+		}
+	}
+	return nil
+}
+
+// resolveWatchRef resolves a local ref for a watch.
+func (r *Registry) resolveWatchRef(ctx context.Context, ref string, rctx *ResolutionContext) (*ResolveOutput, error) {
+	return r.resolveLocal(ctx, &ResolveInput{Cap: ref, Ctx: rctx}, r.defaultAlias(), ref)
+}
+
+// Watch creates a watch: the current resolution of each ref is returned, and a WatchUpdate
+// is published to input.Inbox whenever the resolved subject, version or status of a ref
+// changes, until unwatch or the lease runs out. With input.WatchID set, it renews that
+// watch's lease instead.
+func (r *Registry) Watch(ctx context.Context, input *WatchInput) (*WatchOutput, error) {
+	if input.WatchID != "" {
+		slog.Info(fmt.Sprintf("%s - renew watch=%s", watchLogPrefix, input.WatchID))
+		var out WatchOutput
+		if regErr := r.watchRequest(input.WatchID, &watchControl{Op: "renew", LeaseSeconds: input.LeaseSeconds}, &out); regErr != nil {
+			return nil, regErr
+		}
+		return &out, nil
+	}
+	slog.Info(fmt.Sprintf("%s - watch refs=%d inbox=%s", watchLogPrefix, len(input.Refs), input.Inbox))
+
+	if err := r.requireCatalog(); err != nil {
+		return nil, err
+	}
+	if r.nc == nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: "watch needs a NATS connection"}
+	}
+	if len(input.Refs) == 0 {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: "at least one ref is required"}
+	}
+	if len(input.Refs) > maxWatchRefs {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("refs count exceeds maximum %d", maxWatchRefs)}
+	}
+	if regErr := r.validateWatchInbox(input.Inbox); regErr != nil {
+		return nil, regErr
+	}
+	lease, regErr := watchLease(input.LeaseSeconds)
+	if regErr != nil {
+		return nil, regErr
+	}
+
+	w := &watch{id: newWatchID(), inbox: input.Inbox, rctx: input.Ctx, nc: r.nc, lease: lease}
+	defaultAlias := r.defaultAlias()
+	for _, ref := range input.Refs {
+		alias, capRef := extractAlias(ref)
+		if alias != "" && alias != defaultAlias {
+			return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("watch only covers local capabilities: %s", ref)}
+		}
+		if capRef == "" {
+			capRef = ref
+		}
+		wr := &watchRef{ref: capRef}
+		// Match change events of the capability the ref reaches, also through an alias; a
+		// capability not registered yet is watched under its own name
+		parsed, _, _, regErr := r.lookupCapabilityFollowingAliases(ctx, capRef)
+		if regErr != nil {
+			if regErr.Code != "NOT_FOUND" {
+				return nil, regErr
+			}
+			if parsed, err := semver.ParseCapabilityRef(capRef); err == nil {
+				wr.cap = parsed.App + "." + parsed.Name
+			}
+		} else {
+			wr.cap = parsed.App + "." + parsed.Name
+		}
+		w.refs = append(w.refs, wr)
+	}
+
+	// Register the watch before resolving so no change between the two is missed; a refresh
+	// waits for the initial answers
+	if err := r.watches.subscribe(r.nc); err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: fmt.Sprintf("Failed to subscribe to change events: %v", err)}
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := r.addWatch(w); err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: fmt.Sprintf("Failed to start watch: %v", err)}
+	}
+	out := &WatchOutput{WatchID: w.id, LeaseSeconds: int(lease / time.Second), ExpiresAt: w.expires.UTC().Format(time.RFC3339), Resolutions: map[string]*ResolveOutput{}}
+	for i, wr := range w.refs {
+		res, err := r.watches.resolve(ctx, wr.ref, w.rctx)
+		wr.last = watchState(res, err)
+		if err != nil {
+			if out.Errors == nil {
+				out.Errors = map[string]*RegistryError{}
+			}
+			out.Errors[input.Refs[i]] = asRegistryError(err)
+			continue
+		}
+		out.Resolutions[input.Refs[i]] = res
+	}
+	return out, nil
+}
+
+// Unwatch ends a watch on whichever registry replica holds it. Removed is false when the
+// watch does not exist or already expired.
+func (r *Registry) Unwatch(_ context.Context, input *UnwatchInput) (*UnwatchOutput, error) {
+	slog.Info(fmt.Sprintf("%s - unwatch watch=%s", watchLogPrefix, input.WatchID))
+
+	var out UnwatchOutput
+	if regErr := r.watchRequest(input.WatchID, &watchControl{Op: "unwatch"}, &out); regErr != nil {
+		if regErr.Code == "NOT_FOUND" {
+			return &UnwatchOutput{Removed: false}, nil
+		}
+		return nil, regErr
+	}
+	return &out, nil
+}
+
+// watchRequest sends a control request to the replica holding watch id and decodes its answer into out.
+func (r *Registry) watchRequest(id string, control *watchControl, out interface{}) *RegistryError {
+	if id == "" || strings.ContainsAny(id, ".*> ") {
+		return &RegistryError{Code: "INVALID_ARGUMENT", Message: "a valid watchId is required"}
+	}
+	if r.nc == nil {
+		return &RegistryError{Code: "INTERNAL_ERROR", Message: "watch needs a NATS connection"}
+	}
+	data, _ := json.Marshal(control)
+	msg, err := r.nc.Request(watchControlPrefix+id, data, watchControlTimeout)
+	if errors.Is(err, comms.ErrNoResponders) {
+		return &RegistryError{Code: "NOT_FOUND", Message: fmt.Sprintf("Watch not found or expired: %s", id)}
+	}
+	if err != nil {
+		return &RegistryError{Code: "INTERNAL_ERROR", Message: fmt.Sprintf("Watch %s did not answer: %v", id, err)}
+	}
+	var regErr RegistryError
+	if json.Unmarshal(msg.Data, &regErr) == nil && regErr.Code != "" {
+		return &regErr
+	}
+	if err := json.Unmarshal(msg.Data, out); err != nil {
+		return &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	return nil
+}
+
+// addWatch registers w and starts its lease timer and control subscription.
+func (r *Registry) addWatch(w *watch) error {
+	h := r.watches
+	h.mu.Lock()
+	w.expires = time.Now().Add(w.lease)
+	w.timer = time.AfterFunc(w.lease, func() { r.endWatch(w, "expired") })
+	h.watches[w.id] = w
+	h.mu.Unlock()
+
+	control, err := w.nc.Subscribe(watchControlPrefix+w.id, func(msg *comms.Msg) {
+		r.onWatchControl(w, msg)
+	})
+	if err != nil {
+		h.mu.Lock()
+		w.timer.Stop()
+		delete(h.watches, w.id)
+		h.mu.Unlock()
+		return err
+	}
+	h.mu.Lock()
+	w.control = control
+	h.mu.Unlock()
+	return nil
+}
+
+// onWatchControl answers a renew or unwatch request for w.
+func (r *Registry) onWatchControl(w *watch, msg *comms.Msg) {
+	var control watchControl
+	var reply interface{}
+	if err := json.Unmarshal(msg.Data, &control); err != nil {
+		reply = &RegistryError{Code: "INVALID_ARGUMENT", Message: "invalid watch control request"}
+	} else {
+		switch control.Op {
+		case "renew":
+			lease, regErr := watchLease(control.LeaseSeconds)
+			if regErr != nil {
+				reply = regErr
+				break
+			}
+			r.watches.mu.Lock()
+			w.lease = lease
+			w.expires = time.Now().Add(lease)
+			w.timer.Reset(lease)
+			reply = &WatchOutput{WatchID: w.id, LeaseSeconds: int(lease / time.Second), ExpiresAt: w.expires.UTC().Format(time.RFC3339)}
+			r.watches.mu.Unlock()
+		case "unwatch":
+			r.endWatch(w, "unwatched")
+			reply = &UnwatchOutput{Removed: true}
+		default:
+			reply = &RegistryError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("unknown watch control op: %s", control.Op)}
+		}
+	}
+	data, _ := json.Marshal(reply)
+	_ = msg.Respond(data)
+}
+
+// endWatch removes w and tells its inbox why it ended.
+func (r *Registry) endWatch(w *watch, reason string) {
+	h := r.watches
+	h.mu.Lock()
+	_, ok := h.watches[w.id]
+	delete(h.watches, w.id)
+	w.timer.Stop()
+	control := w.control
+	h.mu.Unlock()
+	if !ok {
+		return
+	}
+	if control != nil {
+		_ = control.Unsubscribe()
+	}
+	publishWatchUpdate(w, &WatchUpdate{WatchID: w.id, Ended: reason})
+	slog.Info(fmt.Sprintf("%s - watch %s %s", watchLogPrefix, w.id, reason))
+}
+
+// subscribe subscribes the hub to the granular change events of all capabilities, once.
+func (h *watchHub) subscribe(nc *comms.Conn) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.changes != nil && h.changes.IsValid() {
+		return nil
+	}
+	sub, err := nc.Subscribe(commsutil.SubjectChangeEvent+".>", func(msg *comms.Msg) {
+		event, err := events.DecodeChangeEvent(msg)
+		if err != nil || event.App == "" || event.Capability == "" {
+			return
+		}
+		h.onChange(event.App + "." + event.Capability)
+	})
+	if err != nil {
+		return err
+	}
+	h.changes = sub
+	return nil
+}
+
+// onChange refreshes the refs of every watch on capability, or all refs after a
+// registry-level event (tenant cells).
+func (h *watchHub) onChange(capability string) {
+	all := capability == remoteRegistryEventApp+"."+remoteRegistryEventCapability
+	h.mu.Lock()
+	watches := make([]*watch, 0, len(h.watches))
+	for _, w := range h.watches {
+		watches = append(watches, w)
+	}
+	h.mu.Unlock()
+
+	for _, w := range watches {
+		var refs []*watchRef
+		for _, wr := range w.refs {
+			if all || wr.cap == capability {
+				refs = append(refs, wr)
+			}
+		}
+		if len(refs) > 0 {
+			go h.refresh(w, refs)
+		}
+	}
+}
+
+// refresh re-resolves refs of w and publishes the ones whose answer really changed.
+func (h *watchHub) refresh(w *watch, refs []*watchRef) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), watchResolveTimeout)
+	defer cancel()
+	for _, wr := range refs {
+		res, err := h.resolve(ctx, wr.ref, w.rctx)
+		state := watchState(res, err)
+		if state == wr.last {
+			continue
+		}
+		wr.last = state
+		update := &WatchUpdate{WatchID: w.id, Ref: wr.ref, Resolution: res}
+		if err != nil {
+			update.Resolution, update.Error = nil, asRegistryError(err)
+		}
+		publishWatchUpdate(w, update)
+	}
+}
+
+// publishWatchUpdate publishes update to w's inbox.
+func publishWatchUpdate(w *watch, update *WatchUpdate) {
+	data, err := json.Marshal(update)
+	if err != nil {
+		return
+	}
+	if err := w.nc.Publish(w.inbox, data); err != nil {
+		slog.Error(fmt.Sprintf("%s - publishing to %s failed: %v", watchLogPrefix, w.inbox, err))
+	}
+}
+
+// close ends the change subscription and the lease timers of every watch, without notice.
+func (h *watchHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.changes != nil {
+		_ = h.changes.Unsubscribe()
+		h.changes = nil
+	}
+	for id, w := range h.watches {
+		w.timer.Stop()
+		if w.control != nil {
+			_ = w.control.Unsubscribe()
+		}
+		delete(h.watches, id)
+	}
+}
+
+// watchState is what a watch compares to decide whether a ref's answer changed: the
+// resolved subject, version and status, or the error code.
+func watchState(res *ResolveOutput, err error) string {
+	if err != nil {
+		return "error:" + asRegistryError(err).Code
+	}
+	return res.Subject + "|" + res.ResolvedVersion + "|" + res.Status
+}
+
+// watchLease returns the lease for leaseSeconds (0 = default).
+func watchLease(leaseSeconds int) (time.Duration, *RegistryError) {
+	if leaseSeconds < 0 {
+		return 0, &RegistryError{Code: "INVALID_ARGUMENT", Message: "leaseSeconds must not be negative"}
+	}
+	if leaseSeconds == 0 {
+		return watchDefaultLease, nil
+	}
+	lease := time.Duration(leaseSeconds) * time.Second
+	if lease > watchMaxLease {
+		lease = watchMaxLease
+	}
+	return lease, nil
+}
+
+// asRegistryError returns err as a RegistryError, wrapping other errors as INTERNAL_ERROR.
+func asRegistryError(err error) *RegistryError {
+	if regErr, ok := err.(*RegistryError); ok {
+		return regErr
+	}
+	return &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+}
+
+func newWatchID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("watch-%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
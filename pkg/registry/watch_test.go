@@ -0,0 +1,193 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	comms "github.com/nats-io/nats.go"
+)
+
+const watchTestPrefix = "registry:watch_test"
+
+func TestWatch_RequiresRepo(t *testing.T) {
+	r := NewRegistry(NewRegistryParams{Config: DefaultConfig()})
+	_, err := r.Watch(context.Background(), &WatchInput{Refs: []string{"more0.doc"}, Inbox: "_INBOX.client"})
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INTERNAL_ERROR" {
+		t.Errorf("%s - expected INTERNAL_ERROR, got %v", watchTestPrefix, err)
+	}
+}
+
+func TestValidateWatchInbox(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.WatchInboxPrefix = "_CLIENT_INBOX"
+	r := NewRegistry(NewRegistryParams{Config: cfg})
+	for _, inbox := range []string{"_INBOX.abc123", "_INBOX.abc.1", "_CLIENT_INBOX.xyz"} {
+		if regErr := r.validateWatchInbox(inbox); regErr != nil {
+			t.Errorf("%s - %q: unexpected error %v", watchTestPrefix, inbox, regErr)
+		}
+	}
+	for _, inbox := range []string{"", "_INBOX.*", "_INBOX.>", "_INBOX.", "registry.changed.more0.doc", "cap.more0.doc.v1", "cap.more0.registry.v1", "system.registry.bootstrap", "$JS.API.STREAM.DELETE.X", "client.updates"} {
+		regErr := r.validateWatchInbox(inbox)
+		if regErr == nil || regErr.Code != "INVALID_ARGUMENT" {
+			t.Errorf("%s - %q: expected INVALID_ARGUMENT, got %v", watchTestPrefix, inbox, regErr)
+		}
+	}
+
+	// A configured prefix cannot open up registry subjects
+	cfg.WatchInboxPrefix = "registry"
+	r = NewRegistry(NewRegistryParams{Config: cfg})
+	if regErr := r.validateWatchInbox("registry.changed.more0.doc"); regErr == nil {
+		t.Errorf("%s - expected a registry subject to be rejected under a registry prefix", watchTestPrefix)
+	}
+}
+
+func TestWatchLease(t *testing.T) {
+	tests := []struct {
+		seconds int
+		want    time.Duration
+		wantErr bool
+	}{
+		{0, watchDefaultLease, false},
+		{30, 30 * time.Second, false},
+		{3600, watchMaxLease, false},
+		{-1, 0, true},
+	}
+	for _, tt := range tests {
+		got, regErr := watchLease(tt.seconds)
+		if (regErr != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("%s - watchLease(%d) = %v, %v; want %v", watchTestPrefix, tt.seconds, got, regErr, tt.want)
+		}
+	}
+}
+
+func TestWatchState(t *testing.T) {
+	v1 := &ResolveOutput{Subject: "cap.more0.doc.v1", ResolvedVersion: "1.0.0", Status: "active", Etag: "cap-1-1"}
+	v1Again := &ResolveOutput{Subject: "cap.more0.doc.v1", ResolvedVersion: "1.0.0", Status: "active", Etag: "cap-1-2", TTLSeconds: 30}
+	if watchState(v1, nil) != watchState(v1Again, nil) {
+		t.Errorf("%s - a new etag or TTL alone is not a change", watchTestPrefix)
+	}
+	deprecated := *v1
+	deprecated.Status = "deprecated"
+	if watchState(v1, nil) == watchState(&deprecated, nil) {
+		t.Errorf("%s - a status change must be a change", watchTestPrefix)
+	}
+	if got := watchState(nil, &RegistryError{Code: "NOT_FOUND"}); got != "error:NOT_FOUND" {
+		t.Errorf("%s - error state = %q", watchTestPrefix, got)
+	}
+}
+
+// startTestWatch registers a watch on more0.doc whose answers come from *current.
+func startTestWatch(t *testing.T, nc *comms.Conn, current **ResolveOutput, mu *sync.Mutex) (*Registry, *watch, <-chan WatchUpdate) {
+	t.Helper()
+	r := NewRegistry(NewRegistryParams{Config: DefaultConfig(), Conn: nc})
+	t.Cleanup(r.Close)
+	r.watches.resolve = func(_ context.Context, _ string, _ *ResolutionContext) (*ResolveOutput, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return *current, nil
+	}
+
+	updates := make(chan WatchUpdate, 10)
+	sub, err := nc.Subscribe("_INBOX.watch_test", func(msg *comms.Msg) {
+		var update WatchUpdate
+		if err := json.Unmarshal(msg.Data, &update); err == nil {
+			updates <- update
+		}
+	})
+	if err != nil {
+		t.Fatalf("%s - subscribe failed: %v", watchTestPrefix, err)
+	}
+	t.Cleanup(func() { _ = sub.Unsubscribe() })
+
+	w := &watch{id: newWatchID(), inbox: "_INBOX.watch_test", nc: nc, lease: time.Minute}
+	w.refs = []*watchRef{{ref: "more0.doc@^1", cap: "more0.doc", last: watchState(*current, nil)}}
+	if err := r.watches.subscribe(nc); err != nil {
+		t.Fatalf("%s - subscribe to changes failed: %v", watchTestPrefix, err)
+	}
+	if err := r.addWatch(w); err != nil {
+		t.Fatalf("%s - addWatch failed: %v", watchTestPrefix, err)
+	}
+	return r, w, updates
+}
+
+func TestWatch_PushesRealChangesUntilUnwatch(t *testing.T) {
+	nc := startProbeServer(t)
+	var mu sync.Mutex
+	current := &ResolveOutput{Subject: "cap.more0.doc.v1", ResolvedVersion: "1.0.0", Status: "active"}
+	r, w, updates := startTestWatch(t, nc, &current, &mu)
+	ctx := context.Background()
+
+	// Neither an unrelated capability nor an unchanged answer is pushed
+	_ = nc.Publish("registry.changed.more0.other", []byte(`{"app":"more0","capability":"other"}`))
+	_ = nc.Publish("registry.changed.more0.doc", []byte(`{"app":"more0","capability":"doc","changedFields":["tags"]}`))
+	_ = nc.Flush()
+	time.Sleep(100 * time.Millisecond)
+
+	mu.Lock()
+	current = &ResolveOutput{Subject: "cap.more0.doc.v1", ResolvedVersion: "1.1.0", Status: "active"}
+	mu.Unlock()
+	_ = nc.Publish("registry.changed.more0.doc", []byte(`{"app":"more0","capability":"doc","changedFields":["version"]}`))
+
+	select {
+	case update := <-updates:
+		if update.WatchID != w.id || update.Ref != "more0.doc@^1" || update.Resolution == nil || update.Resolution.ResolvedVersion != "1.1.0" {
+			t.Errorf("%s - unexpected update: %+v", watchTestPrefix, update)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s - timeout waiting for the version change", watchTestPrefix)
+	}
+
+	renewed, err := r.Watch(ctx, &WatchInput{WatchID: w.id, LeaseSeconds: 120})
+	if err != nil || renewed.LeaseSeconds != 120 || renewed.WatchID != w.id {
+		t.Errorf("%s - renew: %+v, %v", watchTestPrefix, renewed, err)
+	}
+
+	out, err := r.Unwatch(ctx, &UnwatchInput{WatchID: w.id})
+	if err != nil || !out.Removed {
+		t.Fatalf("%s - unwatch: %+v, %v", watchTestPrefix, out, err)
+	}
+	select {
+	case update := <-updates:
+		if update.Ended != "unwatched" {
+			t.Errorf("%s - expected the unwatched notice, got %+v", watchTestPrefix, update)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s - timeout waiting for the unwatched notice", watchTestPrefix)
+	}
+
+	out, err = r.Unwatch(ctx, &UnwatchInput{WatchID: w.id})
+	if err != nil || out.Removed {
+		t.Errorf("%s - a second unwatch: %+v, %v; want removed=false", watchTestPrefix, out, err)
+	}
+	if _, err := r.Watch(ctx, &WatchInput{WatchID: w.id}); err == nil || err.(*RegistryError).Code != "NOT_FOUND" {
+		t.Errorf("%s - renewing an ended watch: %v, want NOT_FOUND", watchTestPrefix, err)
+	}
+}
+
+func TestWatch_LeaseExpires(t *testing.T) {
+	nc := startProbeServer(t)
+	var mu sync.Mutex
+	current := &ResolveOutput{Subject: "cap.more0.doc.v1", ResolvedVersion: "1.0.0", Status: "active"}
+	r, w, updates := startTestWatch(t, nc, &current, &mu)
+
+	r.watches.mu.Lock()
+	w.timer.Reset(50 * time.Millisecond)
+	r.watches.mu.Unlock()
+
+	select {
+	case update := <-updates:
+		if update.Ended != "expired" {
+			t.Errorf("%s - expected the expired notice, got %+v", watchTestPrefix, update)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s - timeout waiting for the lease to expire", watchTestPrefix)
+	}
+	r.watches.mu.Lock()
+	defer r.watches.mu.Unlock()
+	if len(r.watches.watches) != 0 {
+		t.Errorf("%s - the expired watch must be removed", watchTestPrefix)
+	}
+}
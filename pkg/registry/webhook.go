@@ -0,0 +1,514 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/morezero/capabilities-registry/pkg/db"
+	"github.com/morezero/capabilities-registry/pkg/events"
+	"github.com/morezero/capabilities-registry/pkg/semver"
+)
+
+const (
+	webhookLogPrefix = "registry:webhook"
+
+	// Headers of a webhook delivery. The signature is "sha256=" and the hex HMAC-SHA256 of
+	// "<timestamp>.<body>" keyed with the webhook secret.
+	WebhookSignatureHeader = "X-Registry-Signature"
+	WebhookTimestampHeader = "X-Registry-Timestamp"
+	WebhookDeliveryHeader  = "X-Registry-Delivery"
+	WebhookEventHeader     = "X-Registry-Event"
+
+	webhookEventType = "registry.changed"
+	// webhookPollInterval is how often due deliveries are claimed when no event wakes the dispatcher.
+	webhookPollInterval = 5 * time.Second
+	webhookClaimLimit   = 50
+	// webhookRetryBase is the delay before the second attempt; it doubles up to webhookRetryMax.
+	webhookRetryBase = 30 * time.Second
+	webhookRetryMax  = time.Hour
+	// webhookErrorLimit caps the response body or error stored for an attempt.
+	webhookErrorLimit             = 512
+	webhookDeliveriesDefaultLimit = 50
+	webhookDeliveriesMaxLimit     = 500
+)
+
+// WebhookOptions configures webhook delivery. Zero values use the defaults.
+type WebhookOptions struct {
+	// Timeout is the HTTP timeout of one attempt (default 10s).
+	Timeout time.Duration
+	// MaxAttempts is how many times a delivery is tried before it fails (default 8).
+	MaxAttempts int
+	// DisableAfter disables a webhook after this many failed deliveries in a row; 0 never does.
+	DisableAfter int
+}
+
+// webhookDispatcher sends due webhook deliveries.
+type webhookDispatcher struct {
+	r            *Registry
+	client       *http.Client
+	maxAttempts  int
+	disableAfter int
+	retryBase    time.Duration
+}
+
+// webhookPublisher enqueues a delivery of each change event to every matching webhook after
+// publishing it, so the event carries its change feed sequence.
+type webhookPublisher struct {
+	r    *Registry
+	next events.EventPublisher
+}
+
+// PublishChanged publishes event, then enqueues its webhook deliveries.
+func (p *webhookPublisher) PublishChanged(ctx context.Context, event *events.RegistryChangedEvent) error {
+	err := p.next.PublishChanged(ctx, event)
+	if enqueueErr := p.r.enqueueWebhooks(ctx, event); enqueueErr != nil {
+		slog.Error(fmt.Sprintf("%s - enqueueing deliveries of %s.%s failed: %v", webhookLogPrefix, event.App, event.Capability, enqueueErr))
+	}
+	return err
+}
+
+// SetWebhook creates a webhook or updates its URL, filter, secret and description. Setting a
+// webhook enables it again and resets its failure count.
+func (r *Registry) SetWebhook(ctx context.Context, input *SetWebhookInput, userID string) (*WebhookInfo, error) {
+	slog.Info(fmt.Sprintf("%s - setWebhook name=%s url=%s", webhookLogPrefix, input.Name, input.URL))
+
+	if err := r.requireRepo(); err != nil {
+		return nil, err
+	}
+	if err := validateSetWebhookInput(input, r.config.WebhookAllowPrivate); err != nil {
+		return nil, err
+	}
+
+	secret := input.Secret
+	if secret == "" {
+		existing, err := r.repo.GetWebhook(ctx, input.Name)
+		if err != nil {
+			return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+		}
+		if existing == nil {
+			if secret, err = newWebhookSecret(); err != nil {
+				return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+			}
+		}
+	}
+	w, err := r.repo.UpsertWebhook(ctx, db.UpsertWebhookParams{
+		Name:          input.Name,
+		URL:           input.URL,
+		Secret:        secret,
+		App:           optionalString(input.App),
+		Capability:    optionalString(input.Capability),
+		ChangedFields: input.ChangedFields,
+		Description:   optionalString(input.Description),
+		UserID:        userID,
+	})
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	info := webhookToInfo(w)
+	info.Secret = secret
+	return &info, nil
+}
+
+// RemoveWebhook deletes a webhook with its delivery log.
+func (r *Registry) RemoveWebhook(ctx context.Context, input *RemoveWebhookInput) (*RemoveWebhookOutput, error) {
+	slog.Info(fmt.Sprintf("%s - removeWebhook name=%s", webhookLogPrefix, input.Name))
+
+	if err := r.requireRepo(); err != nil {
+		return nil, err
+	}
+	if input.Name == "" {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: "name is required"}
+	}
+	removed, err := r.repo.DeleteWebhook(ctx, input.Name)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	return &RemoveWebhookOutput{Removed: removed}, nil
+}
+
+// ListWebhooks returns all webhooks, without their secrets.
+func (r *Registry) ListWebhooks(ctx context.Context) (*ListWebhooksOutput, error) {
+	slog.Info(fmt.Sprintf("%s - listWebhooks", webhookLogPrefix))
+
+	if err := r.requireRepo(); err != nil {
+		return nil, err
+	}
+	webhooks, err := r.repo.ListWebhooks(ctx, false)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	out := &ListWebhooksOutput{Webhooks: make([]WebhookInfo, len(webhooks))}
+	for i := range webhooks {
+		out.Webhooks[i] = webhookToInfo(&webhooks[i])
+	}
+	return out, nil
+}
+
+// ListWebhookDeliveries returns the delivery log of a webhook, newest first.
+func (r *Registry) ListWebhookDeliveries(ctx context.Context, input *ListWebhookDeliveriesInput) (*ListWebhookDeliveriesOutput, error) {
+	slog.Info(fmt.Sprintf("%s - listWebhookDeliveries name=%s", webhookLogPrefix, input.Name))
+
+	if err := r.requireRepo(); err != nil {
+		return nil, err
+	}
+	if input.Name == "" {
+		return nil, &RegistryError{Code: "INVALID_ARGUMENT", Message: "name is required"}
+	}
+	limit := input.Limit
+	if limit < 1 {
+		limit = webhookDeliveriesDefaultLimit
+	}
+	if limit > webhookDeliveriesMaxLimit {
+		limit = webhookDeliveriesMaxLimit
+	}
+	w, err := r.repo.GetWebhook(ctx, input.Name)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	if w == nil {
+		return nil, &RegistryError{Code: "NOT_FOUND", Message: fmt.Sprintf("Webhook not found: %s", input.Name)}
+	}
+	deliveries, err := r.repo.ListWebhookDeliveries(ctx, w.ID, limit)
+	if err != nil {
+		return nil, &RegistryError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	out := &ListWebhookDeliveriesOutput{Name: w.Name, Deliveries: make([]WebhookDeliveryInfo, len(deliveries))}
+	for i := range deliveries {
+		out.Deliveries[i] = webhookDeliveryToInfo(&deliveries[i])
+	}
+	return out, nil
+}
+
+// enqueueWebhooks adds a pending delivery of event to each enabled webhook it matches and
+// wakes the dispatcher.
+func (r *Registry) enqueueWebhooks(ctx context.Context, event *events.RegistryChangedEvent) error {
+	webhooks, err := r.repo.ListWebhooks(ctx, true)
+	if err != nil {
+		return err
+	}
+	var ids []string
+	for i := range webhooks {
+		if webhookMatches(&webhooks[i], event) {
+			ids = append(ids, webhooks[i].ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if err := r.repo.EnqueueWebhookDeliveries(ctx, ids, event.Sequence, event.App, event.Capability, payload); err != nil {
+		return err
+	}
+	select {
+	case r.webhookWake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// StartWebhookDispatcher sends due webhook deliveries until ctx is cancelled: whenever this
+// replica enqueues deliveries and every few seconds for retries and the deliveries of other
+// replicas. Replicas claim deliveries in the database, so each attempt is made once. It
+// does nothing without a repository.
+func (r *Registry) StartWebhookDispatcher(ctx context.Context, opts WebhookOptions) {
+	if r.repo == nil {
+		return
+	}
+	d := newWebhookDispatcher(r, opts)
+	go func() {
+		ticker := time.NewTicker(webhookPollInterval)
+		defer ticker.Stop()
+		for {
+			d.dispatch(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-r.webhookWake:
+			}
+		}
+	}()
+}
+
+func newWebhookDispatcher(r *Registry, opts WebhookOptions) *webhookDispatcher {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 8
+	}
+	client := &http.Client{Timeout: timeout}
+	if !r.config.WebhookAllowPrivate {
+		// Dial directly (no proxy) so the address check sees the receiver's address
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = nil
+		transport.DialContext = (&net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second, Control: webhookDialControl}).DialContext
+		client.Transport = transport
+	}
+	return &webhookDispatcher{
+		r:            r,
+		client:       client,
+		maxAttempts:  maxAttempts,
+		disableAfter: opts.DisableAfter,
+		retryBase:    webhookRetryBase,
+	}
+}
+
+// dispatch sends the due deliveries, concurrently, until none are left.
+func (d *webhookDispatcher) dispatch(ctx context.Context) {
+	for ctx.Err() == nil {
+		// A claim outlives the slowest attempt, so a delivery is not sent twice at once
+		claimed, err := d.r.repo.ClaimWebhookDeliveries(ctx, webhookClaimLimit, d.client.Timeout+time.Minute)
+		if err != nil {
+			slog.Error(fmt.Sprintf("%s - claiming deliveries failed: %v", webhookLogPrefix, err))
+			return
+		}
+		var wg sync.WaitGroup
+		for i := range claimed {
+			wg.Add(1)
+			go func(c *db.ClaimedWebhookDelivery) {
+				defer wg.Done()
+				d.attempt(ctx, c)
+			}(&claimed[i])
+		}
+		wg.Wait()
+		if len(claimed) < webhookClaimLimit {
+			return
+		}
+	}
+}
+
+// attempt sends one delivery and records the outcome: succeeded on a 2xx response, otherwise
+// retried with exponential backoff until it runs out of attempts.
+func (d *webhookDispatcher) attempt(ctx context.Context, c *db.ClaimedWebhookDelivery) {
+	statusCode, err := d.send(ctx, c)
+	params := db.WebhookAttemptParams{
+		DeliveryID:   c.ID,
+		WebhookID:    c.WebhookID,
+		Status:       "succeeded",
+		NextAttempt:  time.Now().UTC(),
+		DisableAfter: d.disableAfter,
+	}
+	if statusCode > 0 {
+		params.StatusCode = &statusCode
+	}
+	if err != nil {
+		msg := err.Error()
+		params.Error = &msg
+		attempts := c.Attempts + 1
+		if attempts >= d.maxAttempts {
+			params.Status = "failed"
+		} else {
+			params.Status = "pending"
+			params.NextAttempt = params.NextAttempt.Add(webhookBackoff(d.retryBase, attempts))
+		}
+		slog.Warn(fmt.Sprintf("%s - delivery %s to %s (attempt %d) failed: %v", webhookLogPrefix, c.ID, c.URL, attempts, err))
+	}
+	disabled, err := d.r.repo.RecordWebhookAttempt(ctx, params)
+	if err != nil {
+		slog.Error(fmt.Sprintf("%s - recording delivery %s failed: %v", webhookLogPrefix, c.ID, err))
+		return
+	}
+	if disabled {
+		slog.Warn(fmt.Sprintf("%s - webhook %s disabled after %d failed deliveries in a row", webhookLogPrefix, c.URL, d.disableAfter))
+	}
+}
+
+// send POSTs the delivery's payload with its signature headers. Any status other than 2xx is
+// an error; the status code is returned whenever a response was received.
+func (d *webhookDispatcher) send(ctx context.Context, c *db.ClaimedWebhookDelivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(c.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "capabilities-registry-webhook")
+	req.Header.Set(WebhookEventHeader, webhookEventType)
+	req.Header.Set(WebhookDeliveryHeader, c.ID)
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(c.Secret, timestamp, c.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookErrorLimit))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if len(body) > 0 {
+			return resp.StatusCode, fmt.Errorf("HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+		}
+		return resp.StatusCode, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// SignWebhookPayload returns the signature header value of a delivery body: "sha256=" and the
+// hex HMAC-SHA256 of "<timestamp>.<body>" keyed with secret. Receivers recompute it to
+// verify a delivery and reject stale timestamps to prevent replays.
+func SignWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookBackoff is the delay after the given number of failed attempts: base, doubling
+// per attempt, at most webhookRetryMax.
+func webhookBackoff(base time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < webhookRetryMax; i++ {
+		delay *= 2
+	}
+	if delay > webhookRetryMax {
+		delay = webhookRetryMax
+	}
+	return delay
+}
+
+// webhookMatches reports whether event passes the webhook's filter.
+func webhookMatches(w *db.Webhook, event *events.RegistryChangedEvent) bool {
+	if w.App != nil && *w.App != event.App {
+		return false
+	}
+	if w.Capability != nil && *w.Capability != event.Capability {
+		return false
+	}
+	if len(w.ChangedFields) == 0 {
+		return true
+	}
+	for _, field := range event.ChangedFields {
+		for _, want := range w.ChangedFields {
+			if field == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func validateSetWebhookInput(input *SetWebhookInput, allowPrivate bool) *RegistryError {
+	if !semver.ValidateAppName(input.Name) {
+		return &RegistryError{Code: "INVALID_ARGUMENT", Message: "name must be lowercase alphanumeric with hyphens only"}
+	}
+	u, err := url.Parse(input.URL)
+	if input.URL == "" || err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return &RegistryError{Code: "INVALID_ARGUMENT", Message: "url must be an http or https URL with a host"}
+	}
+	if !allowPrivate && blockedWebhookHost(u.Hostname()) {
+		return &RegistryError{Code: "INVALID_ARGUMENT", Message: "url must not point at a loopback, private or link-local address"}
+	}
+	if input.Capability != "" && input.App == "" {
+		return &RegistryError{Code: "INVALID_ARGUMENT", Message: "capability requires app"}
+	}
+	return nil
+}
+
+// blockedWebhookHost reports whether a webhook host is localhost or a literal loopback,
+// private or link-local address. Names are checked again after DNS resolution when the
+// delivery connects (webhookDialControl).
+func blockedWebhookHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	if i := strings.IndexByte(host, '%'); i >= 0 {
+		host = host[:i]
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && blockedWebhookIP(ip)
+}
+
+// blockedWebhookIP reports whether a webhook may not connect to ip: loopback, private
+// (RFC 1918 and IPv6 unique local), link-local (including 169.254.169.254, the cloud
+// metadata endpoint) and unspecified addresses.
+func blockedWebhookIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified()
+}
+
+// webhookDialControl refuses a delivery connection to a blocked address. It runs after DNS
+// resolution, so a public name that resolves (or redirects) to a private address is refused too.
+func webhookDialControl(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || blockedWebhookIP(ip) {
+		return fmt.Errorf("webhook address %s is loopback, private or link-local", host)
+	}
+	return nil
+}
+
+func webhookToInfo(w *db.Webhook) WebhookInfo {
+	changedFields := w.ChangedFields
+	if changedFields == nil {
+		changedFields = []string{}
+	}
+	return WebhookInfo{
+		Name:                w.Name,
+		URL:                 w.URL,
+		App:                 ptrStringOr(w.App, ""),
+		Capability:          ptrStringOr(w.Capability, ""),
+		ChangedFields:       changedFields,
+		Description:         ptrStringOr(w.Description, ""),
+		Enabled:             w.Enabled,
+		ConsecutiveFailures: w.ConsecutiveFailures,
+		DisabledReason:      ptrStringOr(w.DisabledReason, ""),
+		Modified:            w.Modified.UTC().Format(time.RFC3339),
+	}
+}
+
+func webhookDeliveryToInfo(d *db.WebhookDelivery) WebhookDeliveryInfo {
+	info := WebhookDeliveryInfo{
+		ID:         d.ID,
+		Sequence:   d.Seq,
+		App:        d.App,
+		Capability: d.Capability,
+		Status:     d.Status,
+		Attempts:   d.Attempts,
+		Error:      ptrStringOr(d.Error, ""),
+		Created:    d.Created.UTC().Format(time.RFC3339),
+	}
+	if d.StatusCode != nil {
+		info.StatusCode = *d.StatusCode
+	}
+	if d.LastAttempt != nil {
+		info.LastAttempt = d.LastAttempt.UTC().Format(time.RFC3339)
+	}
+	if d.Status == "pending" {
+		info.NextAttempt = d.NextAttempt.UTC().Format(time.RFC3339)
+	}
+	return info
+}
@@ -0,0 +1,202 @@
+package registry
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/morezero/capabilities-registry/pkg/db"
+	"github.com/morezero/capabilities-registry/pkg/events"
+)
+
+const webhookTestPrefix = "registry:webhook_test"
+
+func TestSetWebhook_RequiresRepo(t *testing.T) {
+	r := NewRegistry(NewRegistryParams{Config: DefaultConfig()})
+	_, err := r.SetWebhook(context.Background(), &SetWebhookInput{Name: "chat-ops", URL: "https://hooks.example/registry"}, "system")
+	if regErr, ok := err.(*RegistryError); !ok || regErr.Code != "INTERNAL_ERROR" {
+		t.Errorf("%s - expected INTERNAL_ERROR, got %v", webhookTestPrefix, err)
+	}
+}
+
+func TestValidateSetWebhookInput(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     SetWebhookInput
+		expectErr bool
+	}{
+		{"valid", SetWebhookInput{Name: "chat-ops", URL: "https://hooks.example/registry"}, false},
+		{"valid filter", SetWebhookInput{Name: "ci", URL: "http://ci.internal:8080/hook", App: "more0", Capability: "doc-ingest"}, false},
+		{"bad name", SetWebhookInput{Name: "Chat Ops", URL: "https://hooks.example/registry"}, true},
+		{"missing url", SetWebhookInput{Name: "chat-ops"}, true},
+		{"nats url", SetWebhookInput{Name: "chat-ops", URL: "nats://hooks.example:4222"}, true},
+		{"capability without app", SetWebhookInput{Name: "chat-ops", URL: "https://hooks.example/registry", Capability: "doc-ingest"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSetWebhookInput(&tt.input, false)
+			if tt.expectErr && (err == nil || err.Code != "INVALID_ARGUMENT") {
+				t.Errorf("%s - expected INVALID_ARGUMENT, got %v", webhookTestPrefix, err)
+			}
+			if !tt.expectErr && err != nil {
+				t.Errorf("%s - unexpected error: %v", webhookTestPrefix, err)
+			}
+		})
+	}
+}
+
+func TestWebhookMatches(t *testing.T) {
+	app, capability, otherApp, otherCapability := "more0", "doc-ingest", "acme", "billing"
+	event := &events.RegistryChangedEvent{App: "more0", Capability: "doc-ingest", ChangedFields: []string{"status", "deprecated"}}
+	tests := []struct {
+		name    string
+		webhook db.Webhook
+		want    bool
+	}{
+		{"no filter", db.Webhook{}, true},
+		{"app", db.Webhook{App: &app}, true},
+		{"app and capability", db.Webhook{App: &app, Capability: &capability}, true},
+		{"other app", db.Webhook{App: &otherApp}, false},
+		{"other capability", db.Webhook{App: &app, Capability: &otherCapability}, false},
+		{"changed field", db.Webhook{ChangedFields: []string{"defaultMajor", "deprecated"}}, true},
+		{"other changed fields", db.Webhook{ChangedFields: []string{"defaultMajor"}}, false},
+	}
+	for _, tt := range tests {
+		if got := webhookMatches(&tt.webhook, event); got != tt.want {
+			t.Errorf("%s - %s: webhookMatches = %v, want %v", webhookTestPrefix, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{4, 4 * time.Minute},
+		{20, webhookRetryMax},
+	}
+	for _, tt := range tests {
+		if got := webhookBackoff(webhookRetryBase, tt.attempts); got != tt.want {
+			t.Errorf("%s - webhookBackoff(%d) = %v, want %v", webhookTestPrefix, tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestSignWebhookPayload(t *testing.T) {
+	got := SignWebhookPayload("topsecret", "1700000000", []byte(`{"app":"more0"}`))
+	want := "sha256=ef3c66e8e00981c89cf8f8d8f86a0495d930bf64ba1faa3ad8abcc41fd914951"
+	if got != want {
+		t.Errorf("%s - signature = %s, want %s", webhookTestPrefix, got, want)
+	}
+}
+
+func TestWebhookDispatcher_SendSignsAndReportsStatus(t *testing.T) {
+	status := http.StatusNoContent
+	var got *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		got = req
+		body, _ = io.ReadAll(req.Body)
+		w.WriteHeader(status)
+		if status >= 300 {
+			_, _ = w.Write([]byte("receiver down\n"))
+		}
+	}))
+	defer server.Close()
+
+	// The test receiver listens on loopback
+	cfg := DefaultConfig()
+	cfg.WebhookAllowPrivate = true
+	d := newWebhookDispatcher(NewRegistry(NewRegistryParams{Config: cfg}), WebhookOptions{})
+	delivery := &db.ClaimedWebhookDelivery{
+		WebhookDelivery: db.WebhookDelivery{ID: "d-1", Payload: []byte(`{"app":"more0","capability":"doc-ingest","sequence":7}`)},
+		URL:             server.URL,
+		Secret:          "topsecret",
+	}
+
+	code, err := d.send(context.Background(), delivery)
+	if err != nil || code != http.StatusNoContent {
+		t.Fatalf("%s - send = %d, %v", webhookTestPrefix, code, err)
+	}
+	if got.Method != http.MethodPost || got.Header.Get(WebhookDeliveryHeader) != "d-1" || got.Header.Get(WebhookEventHeader) != "registry.changed" {
+		t.Errorf("%s - unexpected request: %s %v", webhookTestPrefix, got.Method, got.Header)
+	}
+	want := SignWebhookPayload("topsecret", got.Header.Get(WebhookTimestampHeader), body)
+	if got.Header.Get(WebhookSignatureHeader) != want || string(body) != string(delivery.Payload) {
+		t.Errorf("%s - signature %q does not match the body", webhookTestPrefix, got.Header.Get(WebhookSignatureHeader))
+	}
+
+	status = http.StatusBadGateway
+	code, err = d.send(context.Background(), delivery)
+	if code != http.StatusBadGateway || err == nil || !strings.Contains(err.Error(), "receiver down") {
+		t.Errorf("%s - failed send = %d, %v", webhookTestPrefix, code, err)
+	}
+}
+
+func TestValidateSetWebhookInput_PrivateHosts(t *testing.T) {
+	hosts := []string{
+		"http://localhost:8080/hook",
+		"http://api.localhost/hook",
+		"http://127.0.0.1/hook",
+		"http://10.1.2.3/hook",
+		"http://172.16.0.5/hook",
+		"https://192.168.1.10/hook",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://0.0.0.0/hook",
+		"http://[::1]:8080/hook",
+		"http://[fd00:ec2::254]/hook",
+		"http://[fe80::1%25eth0]/hook",
+		"http://[::ffff:127.0.0.1]/hook",
+	}
+	for _, u := range hosts {
+		input := SetWebhookInput{Name: "chat-ops", URL: u}
+		if err := validateSetWebhookInput(&input, false); err == nil || err.Code != "INVALID_ARGUMENT" {
+			t.Errorf("%s - %s: expected INVALID_ARGUMENT, got %v", webhookTestPrefix, u, err)
+		}
+		if err := validateSetWebhookInput(&input, true); err != nil {
+			t.Errorf("%s - %s: expected the opt-in to allow it, got %v", webhookTestPrefix, u, err)
+		}
+	}
+	public := SetWebhookInput{Name: "chat-ops", URL: "https://203.0.113.7/hook"}
+	if err := validateSetWebhookInput(&public, false); err != nil {
+		t.Errorf("%s - public address rejected: %v", webhookTestPrefix, err)
+	}
+}
+
+func TestWebhookDispatcher_RefusesPrivateAddresses(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	// A name resolving to loopback is refused when the delivery connects
+	d := newWebhookDispatcher(NewRegistry(NewRegistryParams{Config: DefaultConfig()}), WebhookOptions{})
+	for _, u := range []string{server.URL, strings.Replace(server.URL, "127.0.0.1", "localhost", 1)} {
+		code, err := d.send(context.Background(), &db.ClaimedWebhookDelivery{
+			WebhookDelivery: db.WebhookDelivery{ID: "d-1", Payload: []byte(`{}`)},
+			URL:             u,
+		})
+		if err == nil || code != 0 || !strings.Contains(err.Error(), "loopback, private or link-local") {
+			t.Errorf("%s - send to %s = %d, %v; want a refused connection", webhookTestPrefix, u, code, err)
+		}
+	}
+	if called {
+		t.Errorf("%s - the receiver must not be reached", webhookTestPrefix)
+	}
+}
+
+func TestNewRegistry_EnqueuesWebhooksOnlyWithRepo(t *testing.T) {
+	r := NewRegistry(NewRegistryParams{Config: DefaultConfig()})
+	if _, ok := r.publisher.(*webhookPublisher); ok {
+		t.Errorf("%s - a registry without a repository must not enqueue webhooks", webhookTestPrefix)
+	}
+}
@@ -18,6 +18,32 @@ type VersionRecord struct {
 	Prerelease    string
 	Status        string // "active", "deprecated", "disabled"
 	VersionString string
+	// Envs lists the environments the version is available in; empty means all environments.
+	Envs []string
+}
+
+// AvailableIn reports whether the version may be resolved in env. An empty env matches any version.
+func (v VersionRecord) AvailableIn(env string) bool {
+	if env == "" || len(v.Envs) == 0 {
+		return true
+	}
+	for _, e := range v.Envs {
+		if e == env {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterByEnv returns the versions available in env, preserving order.
+func FilterByEnv(versions []VersionRecord, env string) []VersionRecord {
+	filtered := make([]VersionRecord, 0, len(versions))
+	for _, v := range versions {
+		if v.AvailableIn(env) {
+			filtered = append(filtered, v)
+		}
+	}
+	return filtered
 }
 
 // ToVersionString converts version components to a version string.
@@ -51,6 +77,7 @@ type ResolveVersionParams struct {
 	DefaultMajor      int    // -1 means no default
 	IncludeDeprecated bool
 	ExcludeDisabled   bool
+	Env               string // when set, versions not available in Env are excluded
 }
 
 // ResolveVersion finds the best matching version for a given range.
@@ -61,6 +88,9 @@ func ResolveVersion(params ResolveVersionParams) *VersionRecord {
 		if params.ExcludeDisabled && v.Status == "disabled" {
 			continue
 		}
+		if !v.AvailableIn(params.Env) {
+			continue
+		}
 		filtered = append(filtered, v)
 	}
 
@@ -115,6 +115,63 @@ func TestResolveVersion_ExcludeDisabled(t *testing.T) {
 	}
 }
 
+func TestResolveVersion_EnvAvailability(t *testing.T) {
+	versions := makeVersions()
+	// 3.4.2 has only been released to staging
+	versions[0].Envs = []string{"staging"}
+
+	result := ResolveVersion(ResolveVersionParams{
+		Versions:          versions,
+		Range:             "^3.0.0",
+		DefaultMajor:      -1,
+		IncludeDeprecated: true,
+		ExcludeDisabled:   true,
+		Env:               "production",
+	})
+	if result == nil || result.VersionString != "3.3.0" {
+		t.Errorf("expected 3.3.0 in production, got %v", result)
+	}
+
+	result = ResolveVersion(ResolveVersionParams{
+		Versions:          versions,
+		Range:             "3.4.2",
+		DefaultMajor:      -1,
+		IncludeDeprecated: true,
+		ExcludeDisabled:   true,
+		Env:               "production",
+	})
+	if result != nil {
+		t.Errorf("expected nil for exact version unavailable in production, got %s", result.VersionString)
+	}
+
+	result = ResolveVersion(ResolveVersionParams{
+		Versions:          versions,
+		Range:             "^3.0.0",
+		DefaultMajor:      -1,
+		IncludeDeprecated: true,
+		ExcludeDisabled:   true,
+		Env:               "staging",
+	})
+	if result == nil || result.VersionString != "3.4.2" {
+		t.Errorf("expected 3.4.2 in staging, got %v", result)
+	}
+}
+
+func TestFilterByEnv(t *testing.T) {
+	versions := []VersionRecord{
+		{ID: "a", Envs: nil},
+		{ID: "b", Envs: []string{"staging"}},
+		{ID: "c", Envs: []string{"staging", "production"}},
+	}
+	got := FilterByEnv(versions, "production")
+	if len(got) != 2 || got[0].ID != "a" || got[1].ID != "c" {
+		t.Errorf("expected [a c], got %v", got)
+	}
+	if got := FilterByEnv(versions, ""); len(got) != 3 {
+		t.Errorf("expected all versions for empty env, got %d", len(got))
+	}
+}
+
 func TestResolveVersion_NoMatch(t *testing.T) {
 	versions := makeVersions()
 